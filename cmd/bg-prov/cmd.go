@@ -2,24 +2,120 @@ package main
 
 import (
 	"bytes"
+	gocontext "context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/amd/psp"
+	"github.com/9elements/converged-security-suite/v2/pkg/coreboot/cbfs"
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/common/pretty"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
 	"github.com/9elements/converged-security-suite/v2/pkg/provisioning/bg"
 	"github.com/9elements/converged-security-suite/v2/pkg/tools"
 )
 
 type context struct {
-	Debug bool
+	Debug      bool
+	Quiet      bool
+	TPMTimeout time.Duration
+	// PubKeyFormat is the format of the public key files, selected by
+	// --pubkey-format.
+	PubKeyFormat bg.PubKeyFormat
+	// Output renders the results of the commands, selected by --output-format.
+	Output bg.OutputFormatter
+	// Parse selects the checks of the parsed manifests, the layout of the
+	// images and the collector of the warnings, see --strict, --flash-size
+	// and --strict-warnings.
+	Parse bg.ParseOptions
+}
+
+// warnings returns the collector of the warnings of the command.
+func (c *context) warnings() *bg.WarningCollector {
+	if c.Parse.Warnings == nil {
+		c.Parse.Warnings = &bg.WarningCollector{Out: os.Stderr}
+	}
+	return c.Parse.Warnings
+}
+
+// warnf prints and records a warning of the command.
+func (c *context) warnf(format string, args ...interface{}) {
+	c.warnings().Warnf(format, args...)
+}
+
+// formatter returns the formatter of the results of a command, JSON if its
+// --json flag is set.
+func (c *context) formatter(asJSON bool) bg.OutputFormatter {
+	if asJSON {
+		return bg.JSONFormatter{}
+	}
+	if c.Output == nil {
+		return bg.TextFormatter{}
+	}
+	return c.Output
+}
+
+// structured returns true if a command prints its result in a machine-readable
+// format instead of its own text output.
+func (c *context) structured(asJSON bool) bool {
+	_, text := c.formatter(asJSON).(bg.TextFormatter)
+	return !text
+}
+
+// writeResult renders the result of a command to stdout.
+func (c *context) writeResult(result interface{}, asJSON bool) error {
+	return c.formatter(asJSON).Format(os.Stdout, result)
+}
+
+// readPubKey reads a public key file of the format of --pubkey-format.
+func (c *context) readPubKey(path string) (crypto.PublicKey, error) {
+	return bg.ReadPubKeyFormat(path, c.PubKeyFormat)
+}
+
+// exitCodeError makes bg-prov exit with code instead of the exit code 1 of
+// other errors.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// infof prints output which isn't a result of the command, e.g. banners and
+// details, unless --quiet is set.
+func (c *context) infof(format string, args ...interface{}) {
+	if !c.Quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+// tpmContext returns a context which is done when TPM operations run longer
+// than the --tpm-timeout.
+func (c *context) tpmContext() (gocontext.Context, gocontext.CancelFunc) {
+	if c.TPMTimeout <= 0 {
+		return gocontext.WithCancel(gocontext.Background())
+	}
+	return gocontext.WithTimeout(gocontext.Background(), c.TPMTimeout)
 }
 
 type versionCmd struct {
@@ -58,18 +154,57 @@ type templateCmd struct {
 
 type kmPrintCmd struct {
 	Path string `arg required name:"path" help:"Path to the Key Manifest binary file." type:"path"`
+	Raw  bool   `flag optional name:"raw" help:"Also print the little-endian bytes of integer fields as stored in the binary."`
+	Env  bool   `flag optional name:"env" help:"Print the important fields as BG_KM_* shell variable assignments for sourcing or eval."`
 }
 
 type bpmPrintCmd struct {
-	Path string `arg required name:"path" help:"Path to the Boot Policy Manifest binary file." type:"path"`
+	Path      string `arg required name:"path" help:"Path to the Boot Policy Manifest binary file." type:"path"`
+	Raw       bool   `flag optional name:"raw" help:"Also print the little-endian bytes of integer fields as stored in the binary."`
+	Env       bool   `flag optional name:"env" help:"Print the important fields as BG_BPM_* and BG_ACM_* shell variable assignments for sourcing or eval."`
+	SpecOrder bool   `flag optional name:"spec-order" help:"Print the fields in the order and with the names of the BootGuard specification tables."`
+	DecodeTXT bool   `flag optional name:"decode-txt" help:"Print what the CMOS and ACPI fields of the TXT element point at and flag suspicious values."`
+	ACPIBase  uint16 `flag optional name:"acpi-base" help:"ACPI PM I/O base of the platform the ACPI base of the TXT element is compared against with --decode-txt."`
+	PwrMBase  uint32 `flag optional name:"pwrm-base" help:"PWRM MMIO base of the platform the PWRM base of the TXT element is compared against with --decode-txt."`
+	Measure   bool   `flag optional name:"measurements" help:"Print the IBB digests and segments and the OBB digest of the IBBS elements."`
+	Debug     bool   `flag optional name:"debug-policy" help:"Decode the debug related flags and warn if debug interfaces are left enabled, fail with --strict."`
+	ACM       string `flag optional name:"acm" help:"Path to the ACM binary file whose build type is checked with --debug-policy." type:"path"`
 }
 
 type acmPrintCmd struct {
+	Path string `arg required name:"path" help:"Path to the ACM binary file, which may be an LZMA compressed firmware file section." type:"path"`
+}
+
+type acmVerifyCmd struct {
+	Path   string `arg required name:"path" help:"Path to the ACM binary file, which may be an LZMA compressed firmware file section." type:"path"`
+	PubKey string `flag optional name:"pubkey" help:"PEM encoded RSA public key the ACM has to be signed with. Defaults to the key in the ACM header." type:"path"`
+	JSON   bool   `flag optional name:"json" help:"Print the result as JSON."`
+
+	VendorID   uint16 `flag optional name:"vid" help:"Chipset vendor ID (TXT.DIDVID) the ACM has to support."`
+	DeviceID   uint16 `flag optional name:"did" help:"Chipset device ID (TXT.DIDVID) the ACM has to support."`
+	RevisionID uint16 `flag optional name:"rid" help:"Chipset revision ID (TXT.DIDVID) the ACM has to support."`
+	FMS        uint32 `flag optional name:"fms" help:"CPU signature (CPUID leaf 1 EAX) the ACM has to support."`
+	PlatformID uint64 `flag optional name:"platform-id" help:"IA32_PLATFORM_ID MSR value the ACM has to support."`
+	TPM        string `flag optional name:"tpm" help:"TPM family the ACM has to support: 1.2, 2.0 or ptt."`
+}
+
+type acmErrorCmd struct {
+	Code string `arg required name:"code" help:"ACM error code as read from TXT.ERRORCODE or the ACM status register, e.g. 0xc00004c1."`
+}
+
+type acmDumpCmd struct {
 	Path string `arg required name:"path" help:"Path to the ACM binary file." type:"path"`
+	Out  string `arg required name:"out" help:"Path to the newly generated JSON file." type:"path"`
+}
+
+type acmLoadCmd struct {
+	Path string `arg required name:"path" help:"Path to the JSON file generated by acm-dump." type:"path"`
+	Out  string `arg required name:"out" help:"Path to the newly generated ACM binary file." type:"path"`
 }
 
 type biosPrintCmd struct {
-	Path string `arg required name:"path" help:"Path to the full BIOS binary file." type:"path"`
+	Path      string `arg optional name:"path" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
 }
 
 type acmExportCmd struct {
@@ -88,25 +223,36 @@ type bpmExportCmd struct {
 }
 
 type generateKMCmd struct {
-	KM         string             `arg required name:"km" help:"Path to the newly generated Key Manifest binary file." type:"path"`
-	Key        string             `arg required name:"key" help:"Public signing key"`
-	Config     string             `flag optional name:"config" help:"Path to the JSON config file." type:"path"`
-	Revision   uint8              `flag optional name:"revision" help:"Platform Manufacturer’s BPM revision number."`
-	SVN        manifest.SVN       `flag optional name:"svn" help:"Boot Policy Manifest Security Version Number"`
-	ID         uint8              `flag optional name:"id" help:"The key Manifest Identifier"`
-	PKHashAlg  manifest.Algorithm `flag optional name:"pkhashalg" help:"Hash algorithm of OEM public key digest"`
-	KMHashes   []key.Hash         `flag optional name:"kmhashes" help:"Key hashes for BPM, ACM, uCode etc"`
-	BpmPubkey  string             `flag optional name:"bpmpubkey" help:"Path to bpm public signing key"`
-	BpmHashAlg manifest.Algorithm `flag optional name:"bpmhashalgo" help:"Hash algorithm for bpm public signing key"`
-	Out        string             `flag optional name:"out" help:"Path to write applied config to"`
-	Cut        bool               `flag optional name:"cut" help:"Cuts the signature before writing to binary."`
-	PrintME    bool               `flag optional name:"printme" help:"Prints the hash of KM public signing key"`
+	KM             string             `arg required name:"km" help:"Path to the newly generated Key Manifest binary file." type:"path"`
+	Key            string             `arg required name:"key" help:"Public signing key"`
+	Config         []string           `flag optional name:"config" help:"Path or http(s) URL of the JSON config file. Repeat to merge overlays into a base config in order."`
+	Revision       uint8              `flag optional name:"revision" help:"Platform Manufacturer’s BPM revision number."`
+	SVN            manifest.SVN       `flag optional name:"svn" help:"Boot Policy Manifest Security Version Number"`
+	ID             uint8              `flag optional name:"id" help:"The key Manifest Identifier"`
+	PKHashAlg      manifest.Algorithm `flag optional name:"pkhashalg" help:"Hash algorithm of OEM public key digest"`
+	KMHashes       []key.Hash         `flag optional name:"kmhashes" help:"Key hashes for BPM, ACM, uCode etc"`
+	BpmPubkey      string             `flag optional name:"bpmpubkey" help:"Path to bpm public signing key"`
+	BpmHashAlg     manifest.Algorithm `flag optional name:"bpmhashalgo" help:"Hash algorithm for bpm public signing key, independent of pkhashalg. Defaults to pkhashalg."`
+	ACM            string             `flag optional name:"acm" help:"Path to the ACM the hash algorithms are checked against." type:"path"`
+	Out            string             `flag optional name:"out" help:"Path to write applied config to"`
+	Cut            bool               `flag optional name:"cut" help:"Cuts the signature before writing to binary."`
+	SigPlaceholder string             `flag optional name:"sig-placeholder" help:"Writes a zeroed signature of the size of the given key type, e.g. RSA3072, so the KM has its final size before it is signed. The KM key has to be of the key type."`
+	PrintME        bool               `flag optional name:"printme" help:"Prints the hash of KM public signing key"`
+	PadTo          uint32             `flag optional name:"pad-to" help:"Pads the KM binary to the given size in bytes."`
+	PadFF          bool               `flag optional name:"pad-ff" help:"Pads with 0xFF instead of zeros."`
+	CHeader        string             `flag optional name:"c-header" help:"Path to additionally write the KM binary to as C header." type:"path"`
+	CName          string             `flag optional name:"c-name" default:"km" help:"Name of the array in the C header."`
+	AllowZero      []string           `flag optional name:"allow-zero" help:"Config keys of fields confirmed to be intentionally zero, e.g. km_hash."`
+	Attest         string             `flag optional name:"attest" help:"Path to write an in-toto statement with the SLSA provenance of the KM to." type:"path"`
+	AttestKey      string             `flag optional name:"attest-key" help:"Path to the encrypted PKCS8 private key file to sign the statement with. The statement is written in a DSSE envelope then."`
+	AttestPassword string             `flag optional name:"attest-password" help:"Password to decrypt the --attest-key, or '-' to read it from stdin."`
+	AttestBuilder  string             `flag optional name:"attest-builder" default:"https://github.com/9elements/converged-security-suite/cmd/bg-prov" help:"Builder ID recorded in the statement, e.g. the URI of the CI pipeline."`
 }
 
 type generateBPMCmd struct {
-	BPM    string `arg required name:"bpm" help:"Path to the newly generated Boot Policy Manifest binary file." type:"path"`
-	BIOS   string `arg required name:"bios" help:"Path to the full BIOS binary file." type:"path"`
-	Config string `flag optional name:"config" help:"Path to the JSON config file." type:"path"`
+	BPM    string   `arg required name:"bpm" help:"Path to the newly generated Boot Policy Manifest binary file." type:"path"`
+	BIOS   string   `arg required name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	Config []string `flag optional name:"config" help:"Path or http(s) URL of the JSON config file. Repeat to merge overlays into a base config in order."`
 	//BootGuard Manifest Header args
 	Revision uint8             `flag optional name:"revision" help:"Platform Manufacturer’s BPM revision number."`
 	SVN      manifest.SVN      `flag optional name:"svn" help:"Boot Policy Manifest Security Version Number"`
@@ -126,6 +272,8 @@ type generateBPMCmd struct {
 	IbbSegbase  uint32               `flag optional name:"ibbsegbase" help:"Value for IbbSegment structure"`
 	IbbSegsize  uint32               `flag optional name:"ibbsegsize" help:"Value for IBB segment structure"`
 	IbbSegFlag  uint16               `flag optional name:"ibbsegflag" help:"Reducted"`
+	OBBBase     uint32               `flag optional name:"obb-base" help:"Flash address of the OBB whose digest is stored as OBB hash. Overrides the OBB regions of the config."`
+	OBBSize     uint32               `flag optional name:"obb-size" help:"Size of the OBB, see --obb-base."`
 	// TXT args
 	SintMin           uint8                       `flag optional name:"sintmin" help:"OEM authorized SinitMinSvn value"`
 	TXTFlags          bootpolicy.TXTControlFlags  `flag optional name:"txtflags" help:"TXT Element control flags"`
@@ -134,28 +282,227 @@ type generateBPMCmd struct {
 	PowermBaseOffset  uint32                      `flag optional name:"powermbaseoffset" help:"ACPI MMIO offset."`
 	CMOSOff0          uint8                       `flag optional name:"cmosoff0" help:"CMOS byte in bank 0 to store platform wakeup time"`
 	CMOSOff1          uint8                       `flag optional name:"cmosoff1" help:"Second CMOS byte in bank 0 to store platform wakeup time"`
-
-	Out string `flag optional name:"out" help:"Path to write applied config to"`
-	Cut bool   `flag optional name:"cut" help:"Cuts the signature before writing to binary."`
+	// PM args
+	PMData string `flag optional name:"pmdata" help:"Path to a file with opaque platform manufacturer data to carry in the PM element. Overrides the PM element of the config." type:"path"`
+
+	Out            string   `flag optional name:"out" help:"Path to write applied config to"`
+	Cut            bool     `flag optional name:"cut" help:"Cuts the signature before writing to binary."`
+	SigPlaceholder string   `flag optional name:"sig-placeholder" help:"Writes a zeroed key and signature of the size of the given key type, e.g. RSA3072, so the BPM has its final size before it is signed."`
+	PadTo          uint32   `flag optional name:"pad-to" help:"Pads the BPM binary to the given size in bytes."`
+	PadFF          bool     `flag optional name:"pad-ff" help:"Pads with 0xFF instead of zeros."`
+	NoAlignChecks  bool     `flag optional name:"no-align-checks" help:"Skips the alignment checks of MCHBAR, VT-d BAR, DMA protected ranges and IBB segments."`
+	PrevBPM        string   `flag optional name:"prev-bpm" help:"Path to the previous BPM binary. Its BPMSVN and ACMSVNAuth must not be decreased." type:"path"`
+	AllowRollback  bool     `flag optional name:"allow-rollback" help:"Allows decreasing BPMSVN and ACMSVNAuth compared to --prev-bpm."`
+	SortIBB        bool     `flag optional name:"sort-ibb" help:"Sorts the IBB segments by base address before computing the IBB digest."`
+	NoNEMCheck     bool     `flag optional name:"no-nem-check" help:"Skips checking that the NEM data stack and the measured IBB segments fit into the LLC."`
+	CHeader        string   `flag optional name:"c-header" help:"Path to additionally write the BPM binary to as C header." type:"path"`
+	CName          string   `flag optional name:"c-name" default:"bpm" help:"Name of the array in the C header."`
+	AllowZero      []string `flag optional name:"allow-zero" help:"Config keys of fields confirmed to be intentionally zero, e.g. se_IBBEntry."`
+	Attest         string   `flag optional name:"attest" help:"Path to write an in-toto statement with the SLSA provenance of the BPM to." type:"path"`
+	AttestKey      string   `flag optional name:"attest-key" help:"Path to the encrypted PKCS8 private key file to sign the statement with. The statement is written in a DSSE envelope then."`
+	AttestPassword string   `flag optional name:"attest-password" help:"Password to decrypt the --attest-key, or '-' to read it from stdin."`
+	AttestBuilder  string   `flag optional name:"attest-builder" default:"https://github.com/9elements/converged-security-suite/cmd/bg-prov" help:"Builder ID recorded in the statement, e.g. the URI of the CI pipeline."`
 }
 
 type signKMCmd struct {
 	KmIn     string `arg required name:"kmin" help:"Path to the generated Key Manifest binary file." type:"path"`
 	KmOut    string `arg required name:"kmout" help:"Path to write the signed KM to"`
-	Key      string `arg required name:"km-keyfile" help:"Path to the encrypted PKCS8 private key file." type:"path"`
-	Password string `arg required name:"password" help:"Password to decrypted PKCS8 private key file"`
+	Key      string `arg required name:"km-keyfile" help:"Path to the encrypted PKCS8 private key file, or '-' to read it from stdin."`
+	Password string `arg required name:"password" help:"Password to decrypted PKCS8 private key file, or '-' to read it from stdin"`
 }
 
 type signBPMCmd struct {
 	BpmIn    string `arg required name:"bpmin" help:"Path to the newly generated Boot Policy Manifest binary file." type:"path"`
 	BpmOut   string `arg required name."bpmout" help:"Path to write the signed BPM to"`
-	Key      string `arg required name:"bpm-keyfile" help:"Path to the encrypted PKCS8 private key file." type:"path"`
-	Password string `arg required name:"password" help:"Password to decrypt PKCS8 private key file"`
+	Key      string `arg required name:"bpm-keyfile" help:"Path to the encrypted PKCS8 private key file, or '-' to read it from stdin."`
+	Password string `arg required name:"password" help:"Password to decrypt PKCS8 private key file, or '-' to read it from stdin"`
+
+	PrevBPM       string `flag optional name:"prev-bpm" help:"Path to the previous BPM binary. Its BPMSVN and ACMSVNAuth must not be decreased." type:"path"`
+	AllowRollback bool   `flag optional name:"allow-rollback" help:"Allows decreasing BPMSVN and ACMSVNAuth compared to --prev-bpm."`
+	Force         bool   `flag optional name:"force" help:"Signs even if the key type differs from the key the BPM declares or --km doesn't accept the key."`
+	KM            string `flag optional name:"km" help:"Path to the KM binary which must hold the hash of the signing key." type:"path"`
 }
 
-type readConfigCmd struct {
+type cosignCmd struct {
+	Path string `arg required name:"path" help:"Path to the signed KM or BPM binary file." type:"path"`
+}
+
+type kmCheckACMCmd struct {
+	KM   string `arg required name:"km" help:"Path to the Key Manifest binary file." type:"path"`
+	ACM  string `arg required name:"acm" help:"Path to the ACM binary file." type:"path"`
+	JSON bool   `flag optional name:"json" help:"Print the result as JSON."`
+}
+
+type bpmCheckACMCmd struct {
+	BPM  string `arg required name:"bpm" help:"Path to the Boot Policy Manifest binary file." type:"path"`
+	ACM  string `arg required name:"acm" help:"Path to the ACM binary file." type:"path"`
+	JSON bool   `flag optional name:"json" help:"Print the result as JSON."`
+}
+
+type sizeCmd struct {
+	Path string `arg required name:"path" help:"Path to the KM or BPM binary file." type:"path"`
+}
+
+type ibbSegmentsCmd struct {
+	Path string `arg required name:"path" help:"Path to the BPM binary file or the JSON config file." type:"path"`
+}
+
+type coverageCmd struct {
+	Path      string `arg required name:"path" help:"Path to the KM or BPM binary file." type:"path"`
+	SignedOut string `flag optional name:"signed-out" help:"Path to write the exact signed bytes to." type:"path"`
+	Digest    bool   `flag optional name:"digest" help:"Write the digest of the signed bytes to --signed-out instead of the bytes, for signers signing a pre-computed digest."`
+}
+
+type sizePlanCmd struct {
+	Config string `arg required name:"config" help:"Path or http(s) URL of the JSON config file."`
+	ACM    string `flag optional name:"acm" help:"Path to the ACM binary file." type:"path"`
+	JSON   bool   `flag optional name:"json" help:"Print the sizes as JSON."`
+}
+
+type checkHashesCmd struct {
+	Path      string `arg required name:"path" help:"Path to the KM or BPM binary file." type:"path"`
+	BPM       string `flag optional name:"bpm" help:"Path to the BPM binary file to recompute the BPM key hashes of a KM from." type:"path"`
+	BIOS      string `flag optional name:"bios" help:"Path to the full BIOS binary file to recompute the IBB digests of a BPM from." type:"path"`
+	FromFlash bool   `flag optional name:"from-flash" help:"Recompute the IBB digests of a BPM from the SPI flash instead of --bios (Linux only, requires root)."`
+	JSON      bool   `flag optional name:"json" help:"Print the results as JSON."`
+}
+
+type redactCmd struct {
+	Path    string `arg required name:"path" help:"Path to the KM or BPM binary file." type:"path"`
+	Out     string `arg required name:"out" help:"Path to write the redacted copy to." type:"path"`
+	ClearPM bool   `flag optional name:"clear-pm" help:"Also zeroes the data of the platform manufacturer element of a BPM."`
+}
+
+type reconcileCmd struct {
+	KM   string `arg required name:"km" help:"Path to the KM binary file." type:"path"`
+	BPM  string `arg required name:"bpm" help:"Path to the BPM binary file the KM should authorize." type:"path"`
+	JSON bool   `flag optional name:"json" help:"Print the results as JSON."`
+}
+
+type keyChainCmd struct {
+	BIOS         string `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash    bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
+	OEMKeyHash   string `flag optional name:"oem-key-hash" help:"Hex encoded OEM key hash fused into the FPF to check the KM signing key against."`
+	CompareFuses bool   `flag optional name:"compare-fuses" help:"Check the KM signing key against the OEM key hash fused into the running system (requires root)."`
+	FuseNVIndex  string `flag optional name:"fuse-nv-index" help:"TPM NV index the firmware mirrors the fused OEM key hash into, used by --compare-fuses."`
+	JSON         bool   `flag optional name:"json" help:"Print the key chain as JSON."`
+}
+
+type compareCmd struct {
+	A    string `arg required name:"a" help:"Path to the first KM, BPM or BIOS binary file." type:"path"`
+	B    string `arg required name:"b" help:"Path to the second KM, BPM or BIOS binary file." type:"path"`
+	JSON bool   `flag optional name:"json" help:"Print the comparison as JSON."`
+}
+
+type pcr0DiffCmd struct {
+	A               string `arg required name:"a" help:"Path to the first BIOS binary file." type:"path"`
+	B               string `arg required name:"b" help:"Path to the second BIOS binary file." type:"path"`
+	Bank            string `flag optional name:"bank" default:"sha1" help:"PCR bank to predict PCR0 for: sha1, sha256, sha384 or sm3_256."`
+	ACMPolicyStatus string `flag optional name:"acm-policy-status" default:"0" help:"Value of the ACM policy status register of the platform, measured into PCR0."`
+	JSON            bool   `flag optional name:"json" help:"Print the comparison as JSON."`
+}
+
+type configDiffCmd struct {
+	A    string `arg required name:"a" help:"Path or http(s) URL of the first JSON config file."`
+	B    string `arg required name:"b" help:"Path or http(s) URL of the second JSON config file."`
+	JSON bool   `flag optional name:"json" help:"Print the changes as JSON."`
+}
+
+type pcr7Cmd struct {
+	BIOS            string `arg required name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	Bank            string `flag optional name:"bank" default:"sha1" help:"PCR bank to predict PCR7 for: sha1, sha256, sha384 or sm3_256."`
+	ACMPolicyStatus string `flag optional name:"acm-policy-status" default:"0" help:"Value of the ACM policy status register of the platform, measured into PCR7."`
+}
+
+type fitCmd struct {
+	BIOS      string `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
+	JSON      bool   `flag optional name:"json" help:"Print the annotated FIT as JSON."`
+}
+
+type cryptoReportCmd struct {
+	BIOS      string `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
+	JSON      bool   `flag optional name:"json" help:"Print the algorithm inventory as JSON."`
+}
+
+type treeCmd struct {
+	BIOS       string `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash  bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
+	OEMKeyHash string `flag optional name:"oem-key-hash" help:"Hex encoded OEM key hash fused into the FPF to check the KM signing key against."`
+	Plain      bool   `flag optional name:"plain" help:"Never print broken links in color, even on a terminal."`
+}
+
+type kmVerifyCmd struct {
+	Path            string `arg required name:"path" help:"Path to the signed Key Manifest binary file." type:"path"`
+	JSON            bool   `flag optional name:"json" help:"Print the result as JSON."`
+	TrustAnchor     string `flag optional name:"trust-anchor" help:"Path to the public key held out of band the KM must be signed with." type:"path"`
+	TrustAnchorHash string `flag optional name:"trust-anchor-hash" help:"Hex encoded OEM key hash held out of band the hash of the KM signing key must match."`
+	PubKey          string `flag optional name:"pubkey" help:"Path to the public key to verify the signature with instead of the key embedded in the KM." type:"path"`
+}
+
+type bpmVerifyCmd struct {
+	Path   string `arg required name:"path" help:"Path to the signed Boot Policy Manifest binary file." type:"path"`
+	JSON   bool   `flag optional name:"json" help:"Print the result as JSON."`
+	PubKey string `flag optional name:"pubkey" help:"Path to the public key to verify the signature with instead of the key embedded in the BPM." type:"path"`
+}
+
+type bpmMatchCmd struct {
+	BIOS      string `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	BPM       string `flag required name:"bpm" help:"Path to the intended Boot Policy Manifest binary file." type:"path"`
+	FromFlash bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
+	JSON      bool   `flag optional name:"json" help:"Print the result as JSON."`
+}
+
+type verifyAllCmd struct {
+	BIOS       string   `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash  bool     `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
+	KMPubKey   string   `flag optional name:"km-pubkey" help:"Path to the public key to verify the KM signature with instead of the key embedded in the KM." type:"path"`
+	BPMPubKey  string   `flag optional name:"bpm-pubkey" help:"Path to the public key to verify the BPM signature with instead of the key embedded in the BPM." type:"path"`
+	ACMPubKey  string   `flag optional name:"acm-pubkey" help:"Path to the RSA public key the ACM has to be signed with. Defaults to the key in the ACM header." type:"path"`
+	OEMKeyHash string   `flag optional name:"oem-key-hash" help:"Hex encoded OEM key hash fused into the FPF to check the KM signing key against."`
+	Skip       []string `flag optional name:"skip" help:"Comma separated checks not to run: fit, signatures, km-bpm-binding, ibb-digest, acm, acm-signature or fuse-hash."`
+	JSON       bool     `flag optional name:"json" help:"Print the results as JSON."`
+}
+
+type testVectorsCmd struct {
+	Dir string `arg required name:"dir" help:"Directory to write a subdirectory per test vector to." type:"path"`
+}
+
+type lintConfigCmd struct {
+	Config        []string `arg required name:"config" help:"Path or http(s) URL of the JSON config file. Several configs are merged into the first one in order."`
+	Generation    string   `flag optional name:"acm-generation" default:"cbnt" help:"BootGuard generation the config is for: cbnt or legacy."`
+	NoAlignChecks bool     `flag optional name:"no-align-checks" help:"Skips the alignment checks of MCHBAR, VT-d BAR, DMA protected ranges and IBB segments."`
+	NoNEMCheck    bool     `flag optional name:"no-nem-check" help:"Skips checking that the NEM data stack and the measured IBB segments fit into the LLC."`
+	JSON          bool     `flag optional name:"json" help:"Print the results as JSON."`
+}
+
+type fmtConfigCmd struct {
 	Config string `arg required name:"config" help:"Path to the JSON config file." type:"path"`
-	BIOS   string `arg required name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	Write  bool   `flag optional name:"write" short:"w" help:"Rewrites the config file instead of printing it."`
+	Check  bool   `flag optional name:"check" help:"Fails if the config is not in canonical form, without printing or rewriting it."`
+}
+
+type migrateConfigCmd struct {
+	Legacy string `arg required name:"legacy" help:"Path to the BootGuard 1.0 JSON config file." type:"path"`
+	Out    string `arg required name:"out" help:"Path to write the CBnT JSON config to." type:"path"`
+}
+
+type configXMLCmd struct {
+	Config string `arg required name:"config" help:"Path or http(s) URL of the JSON config file."`
+	Out    string `arg required name:"out" help:"Path to write the MEU-style XML config to." type:"path"`
+}
+
+type cHeaderCmd struct {
+	Path string `arg required name:"path" help:"Path to the KM or BPM binary file." type:"path"`
+	Out  string `arg required name:"out" help:"Path to write the C header to." type:"path"`
+	Name string `flag optional name:"name" default:"manifest" help:"Name of the array in the C header."`
+}
+
+type readConfigCmd struct {
+	Config    string `arg required name:"config" help:"Path to the JSON config file." type:"path"`
+	BIOS      string `arg optional name:"bios" help:"Path to the full BIOS binary file." type:"path"`
+	FromFlash bool   `flag optional name:"from-flash" help:"Read the BIOS image from the SPI flash instead of a file (Linux only, requires root)."`
 }
 
 type stitchingKMCmd struct {
@@ -177,12 +524,36 @@ type stitchingCmd struct {
 	ACM  string `arg required name:"acm" help:"Path to the ACM binary file." type:"path"`
 	KM   string `arg required name:"km" help:"Path to the Key Manifest binary file." type:"path"`
 	BPM  string `arg required name:"bpm" help:"Path to the Boot Policy Manifest binary file." type:"path"`
+
+	Report string `flag optional name:"report" help:"Path to write a provisioning report to. Written as JSON if the path ends with .json, as text otherwise."`
+	Config string `flag optional name:"config" help:"Path or URL of the JSON config whose shared identifiers (IDs) the KM and BPM are expected to have."`
+
+	VendorID       uint16 `flag optional name:"vid" help:"Chipset vendor ID (TXT.DIDVID) of the target platform the ACM has to support."`
+	DeviceID       uint16 `flag optional name:"did" help:"Chipset device ID (TXT.DIDVID) of the target platform the ACM has to support."`
+	RevisionID     uint16 `flag optional name:"rid" help:"Chipset revision ID (TXT.DIDVID) of the target platform the ACM has to support."`
+	FMS            uint32 `flag optional name:"fms" help:"CPU signature (CPUID leaf 1 EAX) of the target platform the ACM has to support."`
+	PlatformID     uint64 `flag optional name:"platform-id" help:"IA32_PLATFORM_ID MSR value of the target platform the ACM has to support."`
+	DetectPlatform bool   `flag optional name:"detect-platform" help:"Read the chipset and processor IDs the ACM has to support from the running system (requires root)."`
+	TPM            string `flag optional name:"tpm" help:"TPM family of the target platform: 1.2, 2.0 or ptt. A warning is printed if the ACM doesn't support it."`
+
+	FillZero bool `flag optional name:"fill-zero" help:"Fills the unused bytes of the KM and BPM regions with zeros instead of 0xFF, the value of erased flash."`
+}
+
+type liveVerifyCmd struct {
+	BIOS string `flag optional name:"bios" help:"Path to a full BIOS binary file to use instead of reading the flash." type:"path"`
+	JSON bool   `flag optional name:"json" help:"Print the results as JSON."`
 }
 
 type keygenCmd struct {
 	Algo     string `arg require name:"algo" help:"Select crypto algorithm for key generation. Options: RSA2048. RSA3072, ECC224, ECC256"`
-	Password string `arg required name:"password" help:"Password for AES256 encryption of private keys"`
+	Password string `arg required name:"password" help:"Password for AES256 encryption of private keys, or '-' to read it from stdin"`
 	Path     string `flag optional name:"path" help:"Path to store keys. File names are 'yourname_bpm/yourname_bpm.pub' and 'yourname_km/yourname_km.pub' respectivly"`
+
+	KDF           string `flag optional name:"kdf" default:"pbkdf2" help:"Key derivation function for the AES256 key. Options: pbkdf2, scrypt"`
+	KDFIterations int    `flag optional name:"kdf-iterations" default:"600000" help:"PBKDF2 iteration count"`
+	ScryptN       int    `flag optional name:"scrypt-n" default:"32768" help:"scrypt CPU/memory cost parameter N, a power of two"`
+	ScryptR       int    `flag optional name:"scrypt-r" default:"8" help:"scrypt block size parameter r"`
+	ScryptP       int    `flag optional name:"scrypt-p" default:"1" help:"scrypt parallelization parameter p"`
 }
 
 func (v *versionCmd) Run(ctx *context) error {
@@ -196,11 +567,21 @@ func (kmp *kmPrintCmd) Run(ctx *context) error {
 		return err
 	}
 	reader := bytes.NewReader(data)
-	km, err := bg.ParseKM(reader)
+	km, err := ctx.Parse.ParseKM(reader)
 	if err != nil {
 		return err
 	}
-	km.Print()
+	if kmp.Env {
+		return bg.WriteEnv(os.Stdout, bg.KMEnv(km))
+	}
+	if ctx.structured(false) {
+		// the fields as in the KeyManifest object of a config
+		return ctx.writeResult(km, false)
+	}
+	km.Print(pretty.OptionRawBytes(kmp.Raw))
+	if bg.IsPlaceholderSignature(km.KeyAndSignature.Signature.Data) {
+		fmt.Println("Signature: placeholder/empty, the KM is not signed")
+	}
 	if km.KeyAndSignature.Signature.DataTotalSize() > 1 {
 		if err := km.KeyAndSignature.Key.PrintKMPubKey(km.PubKeyHashAlg); err != nil {
 			return err
@@ -215,11 +596,61 @@ func (bpmp *bpmPrintCmd) Run(ctx *context) error {
 		return err
 	}
 	reader := bytes.NewReader(data)
-	bpm, err := bg.ParseBPM(reader)
+	bpm, err := ctx.Parse.ParseBPM(reader)
 	if err != nil {
 		return err
 	}
-	bpm.Print()
+	if bpmp.Env {
+		return bg.WriteEnv(os.Stdout, bg.BPMEnv(bpm))
+	}
+	if ctx.structured(false) {
+		// the fields as in the BootPolicyManifest object of a config
+		return ctx.writeResult(bpm, false)
+	}
+	if bpmp.SpecOrder {
+		fmt.Print(bpm.SpecOrderString())
+		return nil
+	}
+	if bpmp.DecodeTXT {
+		if bpm.TXTE == nil {
+			return fmt.Errorf("the BPM has no TXT element")
+		}
+		d := bg.DecodeTXT(bpm.TXTE, bg.TXTPlatformBases{ACPIBase: bpmp.ACPIBase, PwrMBase: bpmp.PwrMBase})
+		d.PrettyPrint(os.Stdout)
+		for _, warning := range d.Warnings {
+			ctx.warnings().Add(warning)
+		}
+		return nil
+	}
+	if bpmp.Measure {
+		bg.PrintMeasurements(os.Stdout, bpm)
+		return nil
+	}
+	if bpmp.Debug {
+		var header *tools.ACMHeader
+		if bpmp.ACM != "" {
+			acm, err := ioutil.ReadFile(bpmp.ACM)
+			if err != nil {
+				return err
+			}
+			if header, err = tools.ParseACMHeader(acm); err != nil {
+				return err
+			}
+		}
+		p := bg.DecodeDebugPolicy(bpm, header)
+		p.PrettyPrint(os.Stdout)
+		if ctx.Parse.StrictReserved {
+			return p.Err()
+		}
+		for _, finding := range p.Findings {
+			ctx.warnings().Add(finding)
+		}
+		return nil
+	}
+	bpm.Print(pretty.OptionRawBytes(bpmp.Raw))
+	if bg.IsPlaceholderSignature(bpm.PMSE.Signature.Data) {
+		fmt.Println("Signature: placeholder/empty, the BPM is not signed")
+	}
 	if bpm.PMSE.Signature.DataTotalSize() > 1 {
 		if err := bpm.PMSE.KeySignature.Key.PrintBPMPubKey(bpm.PMSE.Signature.HashAlg); err != nil {
 			return err
@@ -228,8 +659,92 @@ func (bpmp *bpmPrintCmd) Run(ctx *context) error {
 	return nil
 }
 
+func (a *acmErrorCmd) Run(ctx *context) error {
+	code, err := strconv.ParseUint(a.Code, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid ACM error code %q: %w", a.Code, err)
+	}
+	fmt.Printf("0x%08x: %s\n", code, tools.DecodeACMError(uint32(code)))
+	return nil
+}
+
+func (v *acmVerifyCmd) Run(ctx *context) error {
+	data, err := readACM(v.Path)
+	if err != nil {
+		return err
+	}
+	var pubKey *rsa.PublicKey
+	if v.PubKey != "" {
+		key, err := ctx.readPubKey(v.PubKey)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if pubKey, ok = key.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("ACMs are signed with RSA keys, but %s contains a %T", v.PubKey, key)
+		}
+	}
+	scheme, err := tools.ACMSignatureScheme(data)
+	if err != nil {
+		return err
+	}
+	acmKey, err := tools.ACMPublicKey(data)
+	if err != nil {
+		return err
+	}
+	verifyErr := tools.VerifyACMSignature(data, pubKey)
+	tpm, err := parseTPMFamily(v.TPM)
+	if err != nil {
+		return err
+	}
+	platform, err := bg.CheckACMPlatform(data, bg.ACMPlatform{
+		VendorID:   v.VendorID,
+		DeviceID:   v.DeviceID,
+		RevisionID: v.RevisionID,
+		FMS:        v.FMS,
+		PlatformID: v.PlatformID,
+		TPM:        tpm,
+	})
+	if err != nil {
+		return err
+	}
+	if ctx.structured(v.JSON) {
+		result := bg.NewCheckResult("acm-signature", verifyErr, scheme.String())
+		result.Actual = fmt.Sprintf("%x", tools.ACMPublicKeyHash(acmKey))
+		if pubKey != nil {
+			result.Expected = fmt.Sprintf("%x", tools.ACMPublicKeyHash(pubKey))
+		}
+		results := bg.NewCheckResults(result)
+		for _, c := range platform.Checks {
+			results.Add(c)
+		}
+		return writeCheckResults(ctx, v.JSON, results)
+	}
+	ctx.infof("ACM signing scheme: %s\n", scheme)
+	ctx.infof("ACM public key hash (SHA256 of the modulus): %x\n", tools.ACMPublicKeyHash(acmKey))
+	if verifyErr != nil {
+		return fmt.Errorf("ACM signature verification failed: %w", verifyErr)
+	}
+	if pubKey == nil {
+		fmt.Println("ACM signature is valid for the key in the ACM header (no --pubkey given, origin not verified)")
+	} else {
+		fmt.Println("ACM signature is valid")
+	}
+	for _, c := range platform.Checks {
+		if c.Pass {
+			fmt.Printf("ACM supports the platform %s\n", c.Actual)
+		} else {
+			fmt.Printf("ACM doesn't support the platform %s: %s\n", c.Actual, c.Detail)
+		}
+	}
+	if !platform.Pass {
+		return fmt.Errorf("ACM is not valid for the given platform IDs")
+	}
+	return nil
+}
+
 func (acmp *acmPrintCmd) Run(ctx *context) error {
-	data, err := ioutil.ReadFile(acmp.Path)
+	data, err := readACM(acmp.Path)
 	if err != nil {
 		return err
 	}
@@ -240,6 +755,14 @@ func (acmp *acmPrintCmd) Run(ctx *context) error {
 	if err2 != nil {
 		return err2
 	}
+	if ctx.structured(false) {
+		// the fields as acm-dump writes them
+		dump, err := tools.NewACMDump(data)
+		if err != nil {
+			return err
+		}
+		return ctx.writeResult(dump, false)
+	}
 	acm.PrettyPrint()
 	chipsets.PrettyPrint()
 	processors.PrettyPrint()
@@ -247,77 +770,148 @@ func (acmp *acmPrintCmd) Run(ctx *context) error {
 	return nil
 }
 
-func (biosp *biosPrintCmd) Run(ctx *context) error {
-	data, err := ioutil.ReadFile(biosp.Path)
+// readACM reads an ACM file, which may also hold the ACM compressed in a
+// firmware file section.
+func readACM(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	acm, err := tools.DecompressACM(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ACM %s: %w", path, err)
+	}
+	return acm, nil
+}
+
+func (acmd *acmDumpCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(acmd.Path)
 	if err != nil {
 		return err
 	}
-	err = bg.PrintFIT(data)
+	dump, err := tools.NewACMDump(data)
 	if err != nil {
 		return err
 	}
-	err = bg.PrintBootGuardStructures(data)
+	out, err := json.MarshalIndent(dump, "", "  ")
 	if err != nil {
 		return err
 	}
-	return nil
+	return bg.WriteFileAtomic(acmd.Out, out, 0644)
 }
 
-func (acme *acmExportCmd) Run(ctx *context) error {
-	data, err := ioutil.ReadFile(acme.BIOS)
+func (acml *acmLoadCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(acml.Path)
 	if err != nil {
 		return err
 	}
-	acmfile, err := os.Create(acme.Out)
-	if err != nil {
+	var dump tools.ACMDump
+	if err := json.Unmarshal(data, &dump); err != nil {
 		return err
 	}
-	err = bg.WriteBootGuardStructures(data, nil, nil, acmfile)
+	acm, err := dump.Bytes()
 	if err != nil {
 		return err
 	}
+	if err := bg.WriteFileAtomic(acml.Out, acm, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "WARNING: the reconstructed ACM is for analysis only, its signature is invalid if any field was modified")
 	return nil
 }
 
-func (kme *kmExportCmd) Run(ctx *context) error {
-	data, err := ioutil.ReadFile(kme.BIOS)
+func (biosp *biosPrintCmd) Run(ctx *context) error {
+	data, err := readImage(biosp.Path, biosp.FromFlash)
 	if err != nil {
 		return err
 	}
-	kmfile, err := os.Create(kme.Out)
+	if psp.IsAMDImage(data) {
+		if ctx.structured(false) {
+			return fmt.Errorf("the PSP structures of AMD images are only shown as text")
+		}
+		ctx.infof("AMD image detected: showing the PSP structures (read-only, no BootGuard structures)\n\n")
+		return psp.PrintStructures(data)
+	}
+	if ctx.structured(false) {
+		structures, err := ctx.Parse.ReadBootGuardStructures(data)
+		if err != nil {
+			return err
+		}
+		return ctx.writeResult(structures, false)
+	}
+	if cbfs.IsCorebootImage(data) {
+		ctx.infof("coreboot image detected.\n\n")
+		if err := cbfs.PrintStructures(data); err != nil {
+			return err
+		}
+		// coreboot images may not reference the manifests in the FIT, but
+		// the FIT still holds the microcode and the ACM if there is one
+		if err := ctx.Parse.PrintFIT(data); err != nil {
+			ctx.warnf("unable to print the FIT: %v", err)
+		}
+		return ctx.Parse.PrintBootGuardStructures(data)
+	}
+	ctx.infof("Intel image detected\n\n")
+	err = ctx.Parse.PrintFIT(data)
 	if err != nil {
 		return err
 	}
-	err = bg.WriteBootGuardStructures(data, nil, kmfile, nil)
+	err = ctx.Parse.PrintBootGuardStructures(data)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (bpme *bpmExportCmd) Run(ctx *context) error {
-	data, err := ioutil.ReadFile(bpme.BIOS)
+func (acme *acmExportCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(acme.BIOS)
 	if err != nil {
 		return err
 	}
-	bpmfile, err := os.Create(bpme.Out)
+	return bg.WriteFileAtomicFunc(acme.Out, 0644, func(f *os.File) error {
+		return ctx.Parse.WriteBootGuardStructures(data, nil, nil, f)
+	})
+}
+
+func (kme *kmExportCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(kme.BIOS)
 	if err != nil {
 		return err
 	}
-	err = bg.WriteBootGuardStructures(data, bpmfile, nil, nil)
+	return bg.WriteFileAtomicFunc(kme.Out, 0644, func(f *os.File) error {
+		return ctx.Parse.WriteBootGuardStructures(data, nil, f, nil)
+	})
+}
+
+func (bpme *bpmExportCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(bpme.BIOS)
 	if err != nil {
 		return err
 	}
-	return nil
+	return bg.WriteFileAtomicFunc(bpme.Out, 0644, func(f *os.File) error {
+		return ctx.Parse.WriteBootGuardStructures(data, f, nil, nil)
+	})
 }
 
 func (g *generateKMCmd) Run(ctx *context) error {
+	var acm []byte
+	if g.ACM != "" {
+		var err error
+		if acm, err = ioutil.ReadFile(g.ACM); err != nil {
+			return err
+		}
+	}
 	var options *bg.BootGuardOptions
-	if g.Config != "" {
-		bgo, err := bg.ParseConfig(g.Config)
+	var configs [][]byte
+	if len(g.Config) > 0 {
+		bgo, data, err := bg.ParseConfigsData(g.Config)
 		if err != nil {
 			return err
 		}
+		configs = data
+		if err := bg.CheckMigrationTODO(bgo); err != nil {
+			return err
+		}
 		options = bgo
 	} else {
 		var bgo bg.BootGuardOptions
@@ -329,7 +923,11 @@ func (g *generateKMCmd) Run(ctx *context) error {
 		tmpKM.Hash = g.KMHashes
 		// Create KM_Hash for BPM pub signing key
 		if g.BpmPubkey != "" {
-			kh, err := bg.GetBPMPubHash(g.BpmPubkey, g.BpmHashAlg)
+			bpmHashAlg := g.BpmHashAlg
+			if bpmHashAlg.IsNull() {
+				bpmHashAlg = g.PKHashAlg
+			}
+			kh, err := bg.GetBPMPubHashFormat(g.BpmPubkey, ctx.PubKeyFormat, bpmHashAlg)
 			if err != nil {
 				return err
 			}
@@ -338,8 +936,20 @@ func (g *generateKMCmd) Run(ctx *context) error {
 		bgo.KeyManifest = *tmpKM
 		options = &bgo
 	}
+	if err := bg.ValidateKMHashAlgs(&options.KeyManifest); err != nil {
+		return err
+	}
+	if len(acm) > 0 {
+		algs, err := bg.ACMHashAlgorithms(acm)
+		if err != nil {
+			return err
+		}
+		if err := bg.CheckKMHashAlgsSupported(&options.KeyManifest, algs); err != nil {
+			return err
+		}
+	}
 
-	key, err := bg.ReadPubKey(g.Key)
+	key, err := ctx.readPubKey(g.Key)
 	if err != nil {
 		return err
 	}
@@ -347,6 +957,15 @@ func (g *generateKMCmd) Run(ctx *context) error {
 	if err := options.KeyManifest.KeyAndSignature.Key.SetPubKey(key); err != nil {
 		return err
 	}
+	if g.SigPlaceholder != "" {
+		keyType, err := parseSigPlaceholder(g.SigPlaceholder, g.Cut)
+		if err != nil {
+			return err
+		}
+		if err := bg.SetKMSignaturePlaceholder(&options.KeyManifest, keyType); err != nil {
+			return err
+		}
+	}
 	if g.PrintME {
 		if options.KeyManifest.KeyAndSignature.Signature.DataTotalSize() > 1 {
 			if err := options.KeyManifest.KeyAndSignature.Key.PrintKMPubKey(options.KeyManifest.PubKeyHashAlg); err != nil {
@@ -354,37 +973,72 @@ func (g *generateKMCmd) Run(ctx *context) error {
 			}
 		}
 	}
+	auditZeroFields(ctx, bg.AuditKMZeroFields(&options.KeyManifest), g.AllowZero)
 	bKM, err := bg.WriteKM(&options.KeyManifest)
 	if err != nil {
 		return err
 	}
 	if g.Out != "" {
-		out, err := os.Create(g.Out)
+		err := bg.WriteFileAtomicFunc(g.Out, 0644, func(f *os.File) error {
+			return bg.WriteConfig(f, options)
+		})
 		if err != nil {
 			return err
 		}
-		if err := bg.WriteConfig(out, options); err != nil {
-			return err
-		}
 	}
 
 	if g.Cut == true {
 		//Cut signature from binary
 		bKM = bKM[:int(options.KeyManifest.KeyManifestSignatureOffset)]
 	}
-	if err = ioutil.WriteFile(g.KM, bKM, 0600); err != nil {
+	if g.PadTo > 0 {
+		bKM, err = bg.PadManifest(bKM, g.PadTo, padByte(g.PadFF))
+		if err != nil {
+			return err
+		}
+	}
+	if err = bg.WriteFileAtomic(g.KM, bKM, 0600); err != nil {
 		return fmt.Errorf("unable to write KM to file: %w", err)
 	}
+	if g.Cut {
+		if err := bg.WriteCutSidecar(g.KM, "KM", bKM); err != nil {
+			return fmt.Errorf("unable to write the KM sidecar: %w", err)
+		}
+	}
+	if g.CHeader != "" {
+		if err := bg.WriteCHeaderFile(g.CHeader, g.CName, bKM); err != nil {
+			return fmt.Errorf("unable to write the KM C header: %w", err)
+		}
+	}
+	if g.Attest != "" {
+		statement := bg.NewAttestation(g.AttestBuilder, "km-gen")
+		for idx, path := range g.Config {
+			statement.AddMaterial(bg.MaterialURI(path), configs[idx])
+		}
+		if err := addFileMaterials(statement, g.Key, g.BpmPubkey, g.ACM); err != nil {
+			return err
+		}
+		statement.AddSubject(g.KM, bKM)
+		if err := writeAttestation(g.Attest, g.AttestKey, g.AttestPassword, statement); err != nil {
+			return fmt.Errorf("unable to write the KM attestation: %w", err)
+		}
+	}
+	printManifestSize(ctx, bKM)
 	return nil
 }
 
 func (g *generateBPMCmd) Run(ctx *context) error {
 	var options *bg.BootGuardOptions
-	if g.Config != "" {
-		bgo, err := bg.ParseConfig(g.Config)
+	var configs [][]byte
+	if len(g.Config) > 0 {
+		bgo, data, err := bg.ParseConfigsData(g.Config)
 		if err != nil {
 			return err
 		}
+		configs = data
+		if err := bg.CheckMigrationTODO(bgo); err != nil {
+			return err
+		}
 		options = bgo
 	} else {
 		var bgo bg.BootGuardOptions
@@ -431,122 +1085,1146 @@ func (g *generateBPMCmd) Run(ctx *context) error {
 
 		options = &bgo
 	}
-
-	bpm, err := bg.GenerateBPM(options, g.BIOS)
-	if err != nil {
-		return err
-	}
-
-	// This section is hacky, just to make the parsing work
-	bpm.PMSE.Key.KeyAlg = 0x01
-	bpm.PMSE.Signature.HashAlg = 0x01
-	// End of hacky section
-	if g.Out != "" {
-		out, err := os.Create(g.Out)
+	if g.PMData != "" {
+		data, err := ioutil.ReadFile(g.PMData)
 		if err != nil {
 			return err
 		}
-		if err := bg.WriteConfig(out, options); err != nil {
+		if options.BootPolicyManifest.PME, err = bg.NewPMElement(data); err != nil {
 			return err
 		}
 	}
-	bBPM, err := bg.WriteBPM(bpm)
+	if g.OBBSize != 0 {
+		options.OBB = bg.OBBRegions{{Base: g.OBBBase, Size: g.OBBSize}}
+	}
+
+	for idx := range options.BootPolicyManifest.SE {
+		se := &options.BootPolicyManifest.SE[idx]
+		if g.SortIBB {
+			bg.SortIBBSegments(se.IBBSegments)
+		}
+		for _, warning := range bg.ReportIBBSegments(se.IBBSegments).Warnings() {
+			ctx.warnf("SE %d: %s", idx, warning)
+		}
+	}
+	if !g.NoAlignChecks {
+		for idx := range options.BootPolicyManifest.SE {
+			if err := bg.ValidateSEAddresses(&options.BootPolicyManifest.SE[idx]); err != nil {
+				return fmt.Errorf("invalid SE %d: %w (use --no-align-checks to skip)", idx, err)
+			}
+			if err := bg.ACMGenerationCBnT.ValidateIBBAlignment(&options.BootPolicyManifest.SE[idx]); err != nil {
+				return fmt.Errorf("invalid SE %d: %w (use --no-align-checks to skip)", idx, err)
+			}
+		}
+	}
+	if !g.NoNEMCheck {
+		for idx := range options.BootPolicyManifest.SE {
+			if err := bg.ValidateNEMSize(options.BootPolicyManifest.BPMH.NEMDataStack, &options.BootPolicyManifest.SE[idx]); err != nil {
+				return fmt.Errorf("invalid SE %d: %w (use --no-nem-check to override)", idx, err)
+			}
+		}
+	}
+
+	bpm, err := ctx.Parse.GenerateBPM(options, g.BIOS)
+	if err != nil {
+		return err
+	}
+	if err := bg.ACMGenerationCBnT.ValidateBPM(bpm); err != nil {
+		return err
+	}
+	auditZeroFields(ctx, bg.AuditBPMZeroFields(bpm), g.AllowZero)
+	if err := checkBPMRollback(ctx, g.PrevBPM, &bpm.BPMH, g.AllowRollback); err != nil {
+		return err
+	}
+
+	// This section is hacky, just to make the parsing work
+	bpm.PMSE.Key.KeyAlg = 0x01
+	bpm.PMSE.Signature.HashAlg = 0x01
+	// End of hacky section
+	if g.SigPlaceholder != "" {
+		keyType, err := parseSigPlaceholder(g.SigPlaceholder, g.Cut)
+		if err != nil {
+			return err
+		}
+		bg.SetBPMSignaturePlaceholder(bpm, keyType)
+	}
+	if g.Out != "" {
+		err := bg.WriteFileAtomicFunc(g.Out, 0644, func(f *os.File) error {
+			return bg.WriteConfig(f, options)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	bBPM, err := bg.WriteBPM(bpm)
+	if err != nil {
+		return err
+	}
+	if g.Cut {
+		bBPM = bBPM[:bpm.KeySignatureOffset]
+	}
+	if g.PadTo > 0 {
+		bBPM, err = bg.PadManifest(bBPM, g.PadTo, padByte(g.PadFF))
+		if err != nil {
+			return err
+		}
+	}
+	if err = bg.WriteFileAtomic(g.BPM, bBPM, 0600); err != nil {
+		return fmt.Errorf("unable to write BPM to file: %w", err)
+	}
+	if g.Cut {
+		if err := bg.WriteCutSidecar(g.BPM, "BPM", bBPM); err != nil {
+			return fmt.Errorf("unable to write the BPM sidecar: %w", err)
+		}
+	}
+	if g.CHeader != "" {
+		if err := bg.WriteCHeaderFile(g.CHeader, g.CName, bBPM); err != nil {
+			return fmt.Errorf("unable to write the BPM C header: %w", err)
+		}
+	}
+	if g.Attest != "" {
+		statement := bg.NewAttestation(g.AttestBuilder, "bpm-gen")
+		for idx, path := range g.Config {
+			statement.AddMaterial(bg.MaterialURI(path), configs[idx])
+		}
+		if err := addFileMaterials(statement, g.BIOS, g.PMData); err != nil {
+			return err
+		}
+		statement.AddSubject(g.BPM, bBPM)
+		if err := writeAttestation(g.Attest, g.AttestKey, g.AttestPassword, statement); err != nil {
+			return fmt.Errorf("unable to write the BPM attestation: %w", err)
+		}
+	}
+	printManifestSize(ctx, bBPM)
+	return nil
+}
+
+// parseSigPlaceholder returns the key type of --sig-placeholder, which can't
+// be combined with --cut.
+func parseSigPlaceholder(name string, cut bool) (bg.SigningKeyType, error) {
+	if cut {
+		return bg.SigningKeyType{}, fmt.Errorf("--sig-placeholder can't be combined with --cut")
+	}
+	return bg.ParseSigningKeyType(name)
+}
+
+// addFileMaterials adds the files at paths to the materials of the
+// statement, skipping empty paths of unset flags.
+func addFileMaterials(statement *bg.InTotoStatement, paths ...string) error {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		statement.AddMaterial(bg.MaterialURI(path), data)
+	}
+	return nil
+}
+
+// writeAttestation writes the statement to path, signed with the key at
+// keyPath if it is set.
+func writeAttestation(path, keyPath, password string, statement *bg.InTotoStatement) error {
+	var signer crypto.Signer
+	if keyPath != "" {
+		key, err := readSigningKey(keyPath, password)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if signer, ok = key.(crypto.Signer); !ok {
+			return fmt.Errorf("the attestation key of type %T can't sign", key)
+		}
+	}
+	return bg.WriteAttestation(path, statement, signer)
+}
+
+// auditZeroFields lists the fields of a generated manifest left at zero on
+// stderr and warns about the suspicious ones not confirmed with --allow-zero.
+func auditZeroFields(ctx *context, fields []bg.ZeroField, allowed []string) {
+	for _, f := range fields {
+		if !f.Suspicious {
+			fmt.Fprintf(os.Stderr, "zero default: %s\n", f)
+		}
+	}
+	for _, f := range bg.SuspiciousZeroFields(fields, allowed) {
+		ctx.warnf("%s (confirm with --allow-zero=%s)", f, f.Name)
+	}
+}
+
+// printManifestSize prints the sizes of a KM or BPM to stderr, so they
+// don't mix with the output of the commands.
+func printManifestSize(ctx *context, data []byte) {
+	kind, size, err := ctx.Parse.ManifestSizeOf(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to determine the manifest size: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s size: %s\n", kind, size)
+}
+
+// checkBPMRollback compares the SVNs of the BPM header with the ones of the
+// previous BPM, if given, and prints both values for the audit log.
+func checkBPMRollback(ctx *context, prevPath string, bpmh *bootpolicy.BPMH, allowRollback bool) error {
+	if prevPath == "" {
+		return nil
+	}
+	prevRaw, err := ioutil.ReadFile(prevPath)
+	if err != nil {
+		return err
+	}
+	prev, err := ctx.Parse.ParseBPM(bytes.NewReader(prevRaw))
+	if err != nil {
+		return fmt.Errorf("unable to parse previous BPM: %w", err)
+	}
+	changes, err := bg.CheckBPMRollback(&prev.BPMH, bpmh)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if err != nil {
+		if !allowRollback {
+			return fmt.Errorf("SVN rollback detected: %w (use --allow-rollback to override)", err)
+		}
+		ctx.warnf("SVN rollback allowed: %v", err)
+	}
+	return nil
+}
+
+func padByte(ff bool) byte {
+	if ff {
+		return 0xff
+	}
+	return 0x00
+}
+
+// readSigningKey reads and decrypts the private key. Either the key or the
+// password may be read from stdin, but not both.
+func readSigningKey(path, password string) (crypto.PrivateKey, error) {
+	if path == bg.StdinPath && password == bg.StdinPath {
+		return nil, fmt.Errorf("key and password can't both be read from stdin")
+	}
+	password, err := bg.ReadPassword(password, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := bg.ReadSecret(path, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return bg.DecryptPrivKey(encKey, password)
+}
+
+func (s *signKMCmd) Run(ctx *context) error {
+	privkey, err := readSigningKey(s.Key, s.Password)
+	if err != nil {
+		return err
+	}
+	kmRaw, err := ioutil.ReadFile(s.KmIn)
+	if err != nil {
+		return err
+	}
+	var km key.Manifest
+	r := bytes.NewReader(kmRaw)
+	_, err = km.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+	if ctx.Parse.StrictReserved {
+		if err := bg.CheckKMReserved(&km); err != nil {
+			return err
+		}
+	}
+	km.RehashRecursive()
+	unsignedKM := kmRaw[:km.KeyAndSignatureOffset()]
+	if err = km.SetSignature(0, privkey.(crypto.Signer), unsignedKM); err != nil {
+		return err
+	}
+	// SetSignature overwrites PubKeyHashAlg with the signature hash algorithm
+	if err := bg.ValidateKMHashAlgs(&km); err != nil {
+		return fmt.Errorf("KM doesn't match the signing key: %w", err)
+	}
+	bKMSigned, err := bg.WriteKM(&km)
+	if err != nil {
+		return err
+	}
+	if err := bg.WriteFileAtomic(s.KmOut, bKMSigned, 0600); err != nil {
+		return err
+	}
+	printManifestSize(ctx, bKMSigned)
+	return nil
+}
+
+// checkKMAcceptsKey checks that the KM at kmPath holds the hash of the BPM
+// signing key pub.
+func checkKMAcceptsKey(ctx *context, kmPath string, pub crypto.PublicKey) error {
+	data, err := ioutil.ReadFile(kmPath)
+	if err != nil {
+		return err
+	}
+	km, err := ctx.Parse.ParseKM(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	ok, err := bg.KMAcceptsKey(km, pub)
+	if err != nil {
+		return fmt.Errorf("unable to check the signing key against the KM: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("KM %s doesn't hold the hash of the signing key with BPM signing usage", kmPath)
+	}
+	return nil
+}
+
+func (s *signBPMCmd) Run(ctx *context) error {
+	key, err := readSigningKey(s.Key, s.Password)
+	if err != nil {
+		return err
+	}
+	bpmRaw, err := ioutil.ReadFile(s.BpmIn)
+	if err != nil {
+		return err
+	}
+
+	var bpm bootpolicy.Manifest
+	r := bytes.NewReader(bpmRaw)
+	if _, err = bpm.ReadFrom(r); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if ctx.Parse.StrictReserved {
+		if err := bg.CheckBPMReserved(&bpm); err != nil {
+			return err
+		}
+	}
+	if err := checkBPMRollback(ctx, s.PrevBPM, &bpm.BPMH, s.AllowRollback); err != nil {
+		return err
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		if err := bg.CheckSigningKey(&bpm.PMSE.Key, signer.Public()); err != nil {
+			if !s.Force {
+				return fmt.Errorf("%w (use --force to sign anyway)", err)
+			}
+			ctx.warnf("%v", err)
+		}
+		if s.KM != "" {
+			if err := checkKMAcceptsKey(ctx, s.KM, signer.Public()); err != nil {
+				if !s.Force {
+					return fmt.Errorf("%w (use --force to sign anyway)", err)
+				}
+				ctx.warnf("%v", err)
+			}
+		}
+	}
+	kAs := bootpolicy.NewSignature()
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		kAs.Key.SetPubKey(key.Public())
+	case *ecdsa.PrivateKey:
+		kAs.Key.SetPubKey(key.Public())
+	default:
+		return fmt.Errorf("Invalid key type")
+	}
+	bpm.PMSE = *kAs
+	bpmRaw, err = bg.WriteBPM(&bpm)
+	if err != nil {
+		return err
+	}
+	bpm.RehashRecursive()
+	unsignedBPM := bpmRaw[:bpm.KeySignatureOffset]
+	//err = bpm.PMSE.SetSignature(0, key.(crypto.Signer), unsignedBPM)
+	err = bpm.PMSE.Signature.SetSignature(0, key.(crypto.Signer), unsignedBPM)
+	if err != nil {
+		return fmt.Errorf("unable to make a signature: %w", err)
+	}
+	bBPMSigned, err := bg.WriteBPM(&bpm)
+	if err != nil {
+		return err
+	}
+	if err = bg.WriteFileAtomic(s.BpmOut, bBPMSigned, 0600); err != nil {
+		return fmt.Errorf("unable to write BPM to file: %w", err)
+	}
+	printManifestSize(ctx, bBPMSigned)
+	return nil
+}
+
+func (c *cosignCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	return bg.Cosign(data)
+}
+
+func (c *kmCheckACMCmd) Run(ctx *context) error {
+	km, err := ioutil.ReadFile(c.KM)
+	if err != nil {
+		return err
+	}
+	acm, err := ioutil.ReadFile(c.ACM)
+	if err != nil {
+		return err
+	}
+	err = ctx.Parse.CheckKMAgainstACM(km, acm)
+	if ctx.structured(c.JSON) {
+		return writeCheckResults(ctx, c.JSON, bg.NewCheckResults(bg.NewCheckResult("km-acm-hash-algorithms", err, "")))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("The ACM supports the hash algorithms of the KM")
+	return nil
+}
+
+func (c *bpmCheckACMCmd) Run(ctx *context) error {
+	bpm, err := ioutil.ReadFile(c.BPM)
+	if err != nil {
+		return err
+	}
+	acm, err := ioutil.ReadFile(c.ACM)
+	if err != nil {
+		return err
+	}
+	err = ctx.Parse.CheckBPMAgainstACM(bpm, acm)
+	if ctx.structured(c.JSON) {
+		return writeCheckResults(ctx, c.JSON, bg.NewCheckResults(bg.NewCheckResult("bpm-acm-svn", err, "")))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("The ACM meets the minimum ACM SVN of the BPM")
+	return nil
+}
+
+func (s *sizeCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return err
+	}
+	kind, size, err := ctx.Parse.ManifestSizeOf(data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Type: %s\n", kind)
+	fmt.Printf("File size: %d bytes\n", size.File)
+	fmt.Printf("Manifest size: %d bytes\n", size.Manifest)
+	fmt.Printf("Signed region size: %d bytes\n", size.SignedRegion)
+	fmt.Printf("Key and signature size: %d bytes\n", size.KeySignature)
+	fmt.Printf("Padding: %d bytes\n", size.File-size.Manifest)
+	return nil
+}
+
+func (i *ibbSegmentsCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(i.Path)
+	if err != nil {
+		return err
+	}
+	var ses []bootpolicy.SE
+	if bytes.HasPrefix(data, []byte(bootpolicy.StructureIDBPMH)) {
+		bpm, err := ctx.Parse.ParseBPM(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		ses = bpm.SE
+	} else {
+		bgo, err := bg.ParseConfig(i.Path)
+		if err != nil {
+			return err
+		}
+		ses = bgo.BootPolicyManifest.SE
+	}
+	for idx := range ses {
+		fmt.Printf("SE %d:\n", idx)
+		report := bg.ReportIBBSegments(ses[idx].IBBSegments)
+		report.PrettyPrint(os.Stdout)
+		for _, warning := range report.Warnings() {
+			ctx.warnings().Add(warning)
+		}
+	}
+	return nil
+}
+
+func (c *coverageCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	kind, cov, err := ctx.Parse.SignatureCoverageOf(data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Type: %s\n", kind)
+	fmt.Printf("Signed region: %s\n", cov.Signed)
+	if cov.KeySignature.Size == 0 {
+		fmt.Println("Key and signature: none, manifest is unsigned")
+	} else {
+		fmt.Printf("Key and signature: %s\n", cov.KeySignature)
+		fmt.Printf("  Public key: %s\n", cov.Key)
+		fmt.Printf("  Signature: %s\n", cov.Signature)
+	}
+	fmt.Printf("Trailing bytes: %s\n", cov.Trailing)
+	digest, err := cov.SigningInput(data, true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Signed digest (%s): %x\n", cov.HashAlg, digest)
+	if c.Digest && c.SignedOut == "" {
+		return fmt.Errorf("--digest requires --signed-out")
+	}
+	if c.SignedOut != "" {
+		input, err := cov.SigningInput(data, c.Digest)
+		if err != nil {
+			return err
+		}
+		return bg.WriteFileAtomic(c.SignedOut, input, 0644)
+	}
+	return nil
+}
+
+func (s *sizePlanCmd) Run(ctx *context) error {
+	bgo, err := bg.ParseConfig(s.Config)
+	if err != nil {
+		return err
+	}
+	var acm []byte
+	if s.ACM != "" {
+		if acm, err = ioutil.ReadFile(s.ACM); err != nil {
+			return err
+		}
+	}
+	plan, err := bg.PlanSizes(bgo, acm)
+	if err != nil {
+		return err
+	}
+	return ctx.writeResult(plan, s.JSON)
+}
+
+// Exit codes of the compare command besides 0 for identical outputs.
+const (
+	exitSignaturesDiffer = 2
+	exitContentDiffers   = 3
+)
+
+func (c *compareCmd) Run(ctx *context) error {
+	a, err := ioutil.ReadFile(c.A)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(c.B)
+	if err != nil {
+		return err
+	}
+	cmp, err := ctx.Parse.CompareManifests(a, b)
+	if err != nil {
+		return err
+	}
+	err = ctx.writeResult(cmp, c.JSON)
+	if err != nil {
+		return err
+	}
+	switch cmp.Result {
+	case bg.CompareSignaturesDiffer:
+		return &exitCodeError{code: exitSignaturesDiffer, err: fmt.Errorf("the outputs are not reproducible: %s", cmp.Result)}
+	case bg.CompareContentDiffers:
+		return &exitCodeError{code: exitContentDiffers, err: fmt.Errorf("the outputs are not reproducible: %s", cmp.Result)}
+	}
+	return nil
+}
+
+func (c *pcr0DiffCmd) Run(ctx *context) error {
+	bank, err := bg.ParsePCRBank(c.Bank)
+	if err != nil {
+		return err
+	}
+	status, err := strconv.ParseUint(c.ACMPolicyStatus, 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid --acm-policy-status: %w", err)
+	}
+	a, err := ioutil.ReadFile(c.A)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(c.B)
+	if err != nil {
+		return err
+	}
+	diff, err := ctx.Parse.ComparePCR0(a, b, status, bank)
+	if err != nil {
+		return err
+	}
+	err = ctx.writeResult(diff, c.JSON)
+	if err != nil {
+		return err
+	}
+	if diff.Differ() {
+		return fmt.Errorf("PCR0 differs: %d measurements changed", len(diff.Changes))
+	}
+	return nil
+}
+
+// Exit code of the config-diff command besides 0 for identical configs.
+const exitConfigsDiffer = 2
+
+func (c *configDiffCmd) Run(ctx *context) error {
+	a, err := bg.ParseConfig(c.A)
+	if err != nil {
+		return err
+	}
+	b, err := bg.ParseConfig(c.B)
+	if err != nil {
+		return err
+	}
+	diff := bg.DiffConfigs(a, b)
+	err = ctx.writeResult(diff, c.JSON)
+	if err != nil {
+		return err
+	}
+	if diff.Differ() {
+		return &exitCodeError{code: exitConfigsDiffer, err: fmt.Errorf("the configs differ: %d fields changed", len(diff.Changes))}
+	}
+	return nil
+}
+
+func (c *pcr7Cmd) Run(ctx *context) error {
+	bank, err := bg.ParsePCRBank(c.Bank)
+	if err != nil {
+		return err
+	}
+	status, err := strconv.ParseUint(c.ACMPolicyStatus, 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid --acm-policy-status: %w", err)
+	}
+	image, err := ioutil.ReadFile(c.BIOS)
+	if err != nil {
+		return err
+	}
+	data, pcr7, err := ctx.Parse.PredictPCR7(image, status, bank)
+	if err != nil {
+		return err
+	}
+	if err := ctx.writeResult(bg.NewPCR7Prediction(bank, data, pcr7), false); err != nil {
+		return err
+	}
+	if !ctx.structured(false) {
+		ctx.infof("Secure Boot variables and authorities extended by the firmware are not included\n")
+	}
+	return nil
+}
+
+// Exit codes of the bpm-match command besides 0 for a match.
+const (
+	exitBPMNotPresent = 2
+	exitBPMDiffers    = 3
+)
+
+func (c *bpmMatchCmd) Run(ctx *context) error {
+	image, err := readImage(c.BIOS, c.FromFlash)
+	if err != nil {
+		return err
+	}
+	expected, err := ioutil.ReadFile(c.BPM)
+	if err != nil {
+		return err
+	}
+	m, err := ctx.Parse.MatchBPM(image, expected)
+	if err != nil {
+		return err
+	}
+	err = ctx.writeResult(m, c.JSON)
+	if err != nil {
+		return err
+	}
+	switch m.Result {
+	case bg.BPMNotPresent:
+		return &exitCodeError{code: exitBPMNotPresent, err: fmt.Errorf("the image contains no BPM")}
+	case bg.BPMDiffers:
+		return &exitCodeError{code: exitBPMDiffers, err: fmt.Errorf("the BPM of the image differs from %s", c.BPM)}
+	}
+	return nil
+}
+
+func (c *fitCmd) Run(ctx *context) error {
+	image, err := readImage(c.BIOS, c.FromFlash)
+	if err != nil {
+		return err
+	}
+	report, err := ctx.Parse.AnnotateFIT(image)
+	if err != nil {
+		return err
+	}
+	err = ctx.writeResult(report, c.JSON)
+	if err != nil {
+		return err
+	}
+	if !report.Pass() {
+		return fmt.Errorf("FIT entries reference invalid structures or are duplicated")
+	}
+	return nil
+}
+
+func (c *cryptoReportCmd) Run(ctx *context) error {
+	image, err := readImage(c.BIOS, c.FromFlash)
+	if err != nil {
+		return err
+	}
+	report := ctx.Parse.CryptoReportOfImage(image)
+	err = ctx.writeResult(report, c.JSON)
+	if err != nil {
+		return err
+	}
+	for _, a := range report.Deprecated() {
+		ctx.warnf("deprecated %s algorithm %s used %d times", a.Kind, a.Algorithm, a.Count)
+	}
+	return nil
+}
+
+func (c *treeCmd) Run(ctx *context) error {
+	image, err := readImage(c.BIOS, c.FromFlash)
+	if err != nil {
+		return err
+	}
+	var oemKeyHash []byte
+	if c.OEMKeyHash != "" {
+		if oemKeyHash, err = hex.DecodeString(strings.TrimPrefix(c.OEMKeyHash, "0x")); err != nil {
+			return fmt.Errorf("invalid --oem-key-hash: %w", err)
+		}
+	}
+	bpm, km, _, err := ctx.Parse.ParseFITEntries(image)
+	if err != nil {
+		return err
+	}
+	tree, err := ctx.Parse.NewTrustTree(km, bpm, image, oemKeyHash)
+	if err != nil {
+		return err
+	}
+	color := false
+	if fi, err := os.Stdout.Stat(); err == nil && !c.Plain {
+		color = fi.Mode()&os.ModeCharDevice != 0
+	}
+	if err := tree.Render(os.Stdout, color); err != nil {
+		return err
+	}
+	if tree.IsBroken() {
+		return fmt.Errorf("the trust chain is broken")
+	}
+	return nil
+}
+
+func (c *verifyAllCmd) Run(ctx *context) error {
+	opts := bg.VerifyAllOptions{Skip: c.Skip}
+	if err := opts.ValidateSkip(); err != nil {
+		return fmt.Errorf("invalid --skip: %w", err)
+	}
+	image, err := readImage(c.BIOS, c.FromFlash)
+	if err != nil {
+		return err
+	}
+	if c.OEMKeyHash != "" {
+		if opts.OEMKeyHash, err = hex.DecodeString(strings.TrimPrefix(c.OEMKeyHash, "0x")); err != nil {
+			return fmt.Errorf("invalid --oem-key-hash: %w", err)
+		}
+	}
+	if c.KMPubKey != "" {
+		if opts.KMPubKey, err = ctx.readPubKey(c.KMPubKey); err != nil {
+			return fmt.Errorf("invalid --km-pubkey: %w", err)
+		}
+	}
+	if c.BPMPubKey != "" {
+		if opts.BPMPubKey, err = ctx.readPubKey(c.BPMPubKey); err != nil {
+			return fmt.Errorf("invalid --bpm-pubkey: %w", err)
+		}
+	}
+	if c.ACMPubKey != "" {
+		key, err := ctx.readPubKey(c.ACMPubKey)
+		if err != nil {
+			return fmt.Errorf("invalid --acm-pubkey: %w", err)
+		}
+		var ok bool
+		if opts.ACMPubKey, ok = key.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("invalid --acm-pubkey: ACMs are signed with RSA keys, but %s contains a %T", c.ACMPubKey, key)
+		}
+	}
+	return writeCheckResults(ctx, c.JSON, ctx.Parse.VerifyAll(image, opts))
+}
+
+func (t *testVectorsCmd) Run(ctx *context) error {
+	vectors, err := bg.GenerateTestVectors()
+	if err != nil {
+		return err
+	}
+	for _, v := range vectors {
+		if err := v.WriteFiles(t.Dir); err != nil {
+			return fmt.Errorf("unable to write test vector %s: %w", v.Name, err)
+		}
+		ctx.infof("%s: expected verdict %s\n", filepath.Join(t.Dir, v.Name), v.Verdict)
+	}
+	return nil
+}
+
+func (c *keyChainCmd) Run(ctx *context) error {
+	image, err := readImage(c.BIOS, c.FromFlash)
+	if err != nil {
+		return err
+	}
+	if c.CompareFuses && c.OEMKeyHash != "" {
+		return fmt.Errorf("either --oem-key-hash or --compare-fuses can be used, not both")
+	}
+	var oemKeyHash []byte
+	if c.OEMKeyHash != "" {
+		if oemKeyHash, err = hex.DecodeString(strings.TrimPrefix(c.OEMKeyHash, "0x")); err != nil {
+			return fmt.Errorf("invalid --oem-key-hash: %w", err)
+		}
+	}
+	bpm, km, _, err := ctx.Parse.ParseFITEntries(image)
+	if err != nil {
+		return err
+	}
+	if c.CompareFuses {
+		if oemKeyHash, err = c.readFusedOEMKeyHash(ctx, km); err != nil {
+			return err
+		}
+	}
+	chain, err := ctx.Parse.ReportKeyChain(km, bpm, oemKeyHash)
+	if err != nil {
+		return err
+	}
+	if err := ctx.writeResult(chain, c.JSON); err != nil {
+		return err
+	}
+	if !chain.Checks.Pass {
+		return fmt.Errorf("the key chain is broken: %d of %d checks failed", len(chain.Checks.Failed()), len(chain.Checks.Checks))
+	}
+	return nil
+}
+
+// readFusedOEMKeyHash reads the OEM key hash fused into the running system
+// with the hash algorithm of the KM.
+func (c *keyChainCmd) readFusedOEMKeyHash(ctx *context, kmData []byte) ([]byte, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("--compare-fuses must be run as root to access the MSRs and the TPM")
+	}
+	var nvIndex uint64
+	if c.FuseNVIndex != "" {
+		var err error
+		if nvIndex, err = strconv.ParseUint(c.FuseNVIndex, 0, 32); err != nil {
+			return nil, fmt.Errorf("invalid --fuse-nv-index: %w", err)
+		}
+	}
+	km, err := ctx.Parse.ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		return nil, err
+	}
+	tpmCtx, cancel := ctx.tpmContext()
+	defer cancel()
+	return bg.ReadFusedOEMKeyHash(tpmCtx, hwapi.GetAPI(), uint32(nvIndex), km.PubKeyHashAlg)
+}
+
+func (c *checkHashesCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	kind, _, err := ctx.Parse.ManifestSizeOf(data)
+	if err != nil {
+		return err
+	}
+	var results *bg.CheckResults
+	switch kind {
+	case "KM":
+		km, err := ctx.Parse.ParseKM(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		var bpm *bootpolicy.Manifest
+		if c.BPM != "" {
+			bpmData, err := ioutil.ReadFile(c.BPM)
+			if err != nil {
+				return err
+			}
+			if bpm, err = ctx.Parse.ParseBPM(bytes.NewReader(bpmData)); err != nil {
+				return err
+			}
+		}
+		results = bg.CheckKMHashes(km, bpm)
+	case "BPM":
+		bpm, err := ctx.Parse.ParseBPM(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		var image []byte
+		if c.BIOS != "" || c.FromFlash {
+			if image, err = readImage(c.BIOS, c.FromFlash); err != nil {
+				return err
+			}
+		}
+		results = ctx.Parse.CheckBPMHashes(bpm, image)
+	}
+	if err := ctx.writeResult(results, c.JSON); err != nil {
+		return err
+	}
+	if !results.Pass {
+		return fmt.Errorf("%d of %d stored values don't match the recomputed values", len(results.Failed()), len(results.Checks))
+	}
+	return nil
+}
+
+func (r *redactCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(r.Path)
 	if err != nil {
 		return err
 	}
-	if g.Cut {
-		bBPM = bBPM[:bpm.KeySignatureOffset]
+	kind, _, err := ctx.Parse.ManifestSizeOf(data)
+	if err != nil {
+		return err
 	}
-	if err = ioutil.WriteFile(g.BPM, bBPM, 0600); err != nil {
-		return fmt.Errorf("unable to write BPM to file: %w", err)
+	var redacted []string
+	var out []byte
+	switch kind {
+	case "KM":
+		if r.ClearPM {
+			return fmt.Errorf("--clear-pm only applies to a BPM")
+		}
+		km, err := ctx.Parse.ParseKM(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		redacted = bg.RedactKM(km)
+		if out, err = bg.WriteKM(km); err != nil {
+			return err
+		}
+	case "BPM":
+		bpm, err := ctx.Parse.ParseBPM(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		redacted = bg.RedactBPM(bpm, r.ClearPM)
+		if out, err = bg.WriteBPM(bpm); err != nil {
+			return err
+		}
+	}
+	if err := bg.WriteFileAtomic(r.Out, out, 0644); err != nil {
+		return err
+	}
+	for _, field := range redacted {
+		ctx.infof("Zeroed the %s\n", field)
 	}
 	return nil
 }
 
-func (s *signKMCmd) Run(ctx *context) error {
-	encKey, err := ioutil.ReadFile(s.Key)
+func (r *reconcileCmd) Run(ctx *context) error {
+	kmData, err := ioutil.ReadFile(r.KM)
 	if err != nil {
 		return err
 	}
-	privkey, err := bg.DecryptPrivKey(encKey, s.Password)
+	km, err := ctx.Parse.ParseKM(bytes.NewReader(kmData))
 	if err != nil {
 		return err
 	}
-	kmRaw, err := ioutil.ReadFile(s.KmIn)
+	bpmData, err := ioutil.ReadFile(r.BPM)
 	if err != nil {
 		return err
 	}
-	var km key.Manifest
-	r := bytes.NewReader(kmRaw)
-	_, err = km.ReadFrom(r)
+	bpm, err := ctx.Parse.ParseBPM(bytes.NewReader(bpmData))
 	if err != nil {
 		return err
 	}
-	km.RehashRecursive()
-	unsignedKM := kmRaw[:km.KeyAndSignatureOffset()]
-	if err = km.SetSignature(0, privkey.(crypto.Signer), unsignedKM); err != nil {
+	results := bg.ReconcileManifests(km, bpm)
+	if err := ctx.writeResult(results, r.JSON); err != nil {
 		return err
 	}
-	bKMSigned, err := bg.WriteKM(&km)
+	if !results.Pass {
+		return fmt.Errorf("%d of %d reconciliation points failed", len(results.Failed()), len(results.Checks))
+	}
+	return nil
+}
+
+func (v *kmVerifyCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(v.Path)
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(s.KmOut, bKMSigned, 0600); err != nil {
+	pub, err := readVerificationKey(ctx, v.PubKey)
+	if err != nil {
 		return err
 	}
+	scheme, err := ctx.Parse.VerifyKMWithKey(data, pub)
+	results := bg.NewCheckResults(bg.NewCheckResult("km-signature", err, scheme.String()))
+	if v.TrustAnchor != "" || v.TrustAnchorHash != "" {
+		anchorResult, anchorErr := v.checkTrustAnchor(ctx, data)
+		if anchorErr != nil {
+			return anchorErr
+		}
+		results.Add(anchorResult)
+	}
+	if ctx.structured(v.JSON) {
+		return writeCheckResults(ctx, v.JSON, results)
+	}
+	if err != nil {
+		return fmt.Errorf("KM signature verification failed: %w", err)
+	}
+	fmt.Printf("KM signature is valid (scheme: %s%s)\n", scheme, verificationKeyNote(v.PubKey))
+	if !results.Pass {
+		return fmt.Errorf("KM trust anchor verification failed: %s", results.Failed()[0].Detail)
+	}
+	if len(results.Checks) > 1 {
+		fmt.Printf("KM is signed by the trust anchor (%s)\n", results.Checks[1].Detail)
+	}
 	return nil
 }
 
-func (s *signBPMCmd) Run(ctx *context) error {
-	encKey, err := ioutil.ReadFile(s.Key)
+// checkTrustAnchor checks the KM signing key against the public key or the
+// hash given out of band. Errors are returned for unusable anchors only.
+func (v *kmVerifyCmd) checkTrustAnchor(ctx *context, data []byte) (bg.CheckResult, error) {
+	if v.TrustAnchor != "" && v.TrustAnchorHash != "" {
+		return bg.CheckResult{}, fmt.Errorf("either --trust-anchor or --trust-anchor-hash can be used, not both")
+	}
+	km, err := ctx.Parse.ParseKM(bytes.NewReader(data))
+	if err != nil {
+		return bg.CheckResult{}, err
+	}
+	if v.TrustAnchorHash != "" {
+		hash, err := hex.DecodeString(strings.TrimPrefix(v.TrustAnchorHash, "0x"))
+		if err != nil {
+			return bg.CheckResult{}, fmt.Errorf("invalid --trust-anchor-hash: %w", err)
+		}
+		return bg.NewCheckResult("km-trust-anchor", bg.CheckKMTrustAnchorHash(km, hash), "key hash"), nil
+	}
+	anchor, err := ctx.readPubKey(v.TrustAnchor)
+	if err != nil {
+		return bg.CheckResult{}, fmt.Errorf("invalid --trust-anchor: %w", err)
+	}
+	return bg.NewCheckResult("km-trust-anchor", bg.CheckKMTrustAnchor(km, anchor), "public key"), nil
+}
+
+func (v *bpmVerifyCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(v.Path)
 	if err != nil {
 		return err
 	}
-	key, err := bg.DecryptPrivKey(encKey, s.Password)
+	pub, err := readVerificationKey(ctx, v.PubKey)
 	if err != nil {
 		return err
 	}
-	bpmRaw, err := ioutil.ReadFile(s.BpmIn)
+	scheme, err := ctx.Parse.VerifyBPMWithKey(data, pub)
+	if ctx.structured(v.JSON) {
+		return writeCheckResults(ctx, v.JSON, bg.NewCheckResults(bg.NewCheckResult("bpm-signature", err, scheme.String())))
+	}
+	if err != nil {
+		return fmt.Errorf("BPM signature verification failed: %w", err)
+	}
+	fmt.Printf("BPM signature is valid (scheme: %s%s)\n", scheme, verificationKeyNote(v.PubKey))
+	return nil
+}
+
+// readVerificationKey reads the public key of --pubkey, if given.
+func readVerificationKey(ctx *context, path string) (crypto.PublicKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	pub, err := ctx.readPubKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --pubkey: %w", err)
+	}
+	return pub, nil
+}
+
+// verificationKeyNote notes the external key of --pubkey in the verdict.
+func verificationKeyNote(path string) string {
+	if path == "" {
+		return ""
+	}
+	return ", key: " + path
+}
+
+func (l *lintConfigCmd) Run(ctx *context) error {
+	generation, err := bg.ParseACMGeneration(l.Generation)
 	if err != nil {
 		return err
 	}
+	options, err := bg.ParseConfigs(l.Config)
+	if err != nil {
+		return err
+	}
+	for idx := range options.BootPolicyManifest.SE {
+		for _, warning := range bg.ReportIBBSegments(options.BootPolicyManifest.SE[idx].IBBSegments).Warnings() {
+			ctx.warnf("SE %d: %s", idx, warning)
+		}
+	}
+	results := bg.LintConfig(options, bg.LintOptions{
+		Generation:     generation,
+		NoAlignChecks:  l.NoAlignChecks,
+		NoNEMCheck:     l.NoNEMCheck,
+		StrictReserved: ctx.Parse.StrictReserved,
+	})
+	return writeCheckResults(ctx, l.JSON, results)
+}
 
-	var bpm bootpolicy.Manifest
-	r := bytes.NewReader(bpmRaw)
-	if _, err = bpm.ReadFrom(r); err != nil && !errors.Is(err, io.EOF) {
+func (f *fmtConfigCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(f.Config)
+	if err != nil {
 		return err
 	}
-	kAs := bootpolicy.NewSignature()
-	switch key := key.(type) {
-	case *rsa.PrivateKey:
-		kAs.Key.SetPubKey(key.Public())
-	case *ecdsa.PrivateKey:
-		kAs.Key.SetPubKey(key.Public())
-	default:
-		return fmt.Errorf("Invalid key type")
+	formatted, err := bg.FormatConfig(data)
+	if err != nil {
+		return err
 	}
-	bpm.PMSE = *kAs
-	bpmRaw, err = bg.WriteBPM(&bpm)
+	switch {
+	case f.Check:
+		if !bytes.Equal(data, formatted) {
+			return fmt.Errorf("config %s is not in canonical form", f.Config)
+		}
+		return nil
+	case f.Write:
+		if bytes.Equal(data, formatted) {
+			return nil
+		}
+		return bg.WriteFileAtomic(f.Config, formatted, 0644)
+	}
+	_, err = os.Stdout.Write(formatted)
+	return err
+}
+
+func (m *migrateConfigCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(m.Legacy)
 	if err != nil {
 		return err
 	}
-	bpm.RehashRecursive()
-	unsignedBPM := bpmRaw[:bpm.KeySignatureOffset]
-	//err = bpm.PMSE.SetSignature(0, key.(crypto.Signer), unsignedBPM)
-	err = bpm.PMSE.Signature.SetSignature(0, key.(crypto.Signer), unsignedBPM)
+	legacy, err := bg.ParseLegacyConfig(data)
 	if err != nil {
-		return fmt.Errorf("unable to make a signature: %w", err)
+		return err
 	}
-	bBPMSigned, err := bg.WriteBPM(&bpm)
+	bgo := bg.MigrateConfig(legacy)
+	cfg, err := bg.CanonicalConfig(bgo)
 	if err != nil {
 		return err
 	}
-	if err = ioutil.WriteFile(s.BpmOut, bBPMSigned, 0600); err != nil {
-		return fmt.Errorf("unable to write BPM to file: %w", err)
+	if err := bg.WriteFileAtomic(m.Out, cfg, 0644); err != nil {
+		return err
+	}
+	for _, todo := range bgo.TODO {
+		ctx.warnf("TODO %s", todo)
 	}
 	return nil
 }
 
+func (c *configXMLCmd) Run(ctx *context) error {
+	bgo, err := bg.ParseConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	return bg.WriteFileAtomicFunc(c.Out, 0644, func(f *os.File) error {
+		return bg.WriteConfigXML(f, bgo)
+	})
+}
+
+func (c *cHeaderCmd) Run(ctx *context) error {
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	return bg.WriteCHeaderFile(c.Out, c.Name, data)
+}
+
 func (t *templateCmd) Run(ctx *context) error {
 	var bgo bg.BootGuardOptions
 	bgo.BootPolicyManifest.BPMH.BPMRevision = t.Revision
@@ -584,26 +2262,30 @@ func (t *templateCmd) Run(ctx *context) error {
 
 	bgo.BootPolicyManifest.TXTE = txt
 
-	out, err := os.Create(t.Path)
-	if err != nil {
-		return err
-	}
-	if err := bg.WriteConfig(out, &bgo); err != nil {
-		return err
-	}
-	return nil
+	return bg.WriteFileAtomicFunc(t.Path, 0644, func(f *os.File) error {
+		return bg.WriteConfig(f, &bgo)
+	})
 }
 
 func (rc *readConfigCmd) Run(ctx *context) error {
-	f, err := os.Create(rc.Config)
-	if err != nil {
+	return bg.WriteFileAtomicFunc(rc.Config, 0644, func(f *os.File) error {
+		image, err := readImage(rc.BIOS, rc.FromFlash)
+		if err != nil {
+			return err
+		}
+		_, err = ctx.Parse.ReadConfigFromImage(image, f)
 		return err
-	}
-	_, err = bg.ReadConfigFromBIOSImage(rc.BIOS, f)
+	})
+}
+
+// readImage reads the firmware image from path, or from the SPI flash if
+// fromFlash is set.
+func readImage(path string, fromFlash bool) ([]byte, error) {
+	src, err := bg.NewImageSource(path, fromFlash)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return src.ReadImage()
 }
 
 func (s *stitchingKMCmd) Run(ctx *context) error {
@@ -615,15 +2297,18 @@ func (s *stitchingKMCmd) Run(ctx *context) error {
 	if err != nil {
 		return err
 	}
-	pub, err := bg.ReadPubKey(s.PubKey)
+	pub, err := ctx.readPubKey(s.PubKey)
 	if err != nil {
 		return err
 	}
 	if len(kmData) < 1 || len(sig) < 1 {
 		return fmt.Errorf("loaded files are empty")
 	}
+	if err := bg.CheckCutSidecar(s.KM, "KM", kmData); err != nil {
+		return err
+	}
 	reader := bytes.NewReader(kmData)
-	km, err := bg.ParseKM(reader)
+	km, err := ctx.Parse.ParseKM(reader)
 	if err != nil {
 		return err
 	}
@@ -631,7 +2316,7 @@ func (s *stitchingKMCmd) Run(ctx *context) error {
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(s.Out, kmRaw, 0644); err != nil {
+	if err := bg.WriteFileAtomic(s.Out, kmRaw, 0644); err != nil {
 		return err
 	}
 	return nil
@@ -646,15 +2331,18 @@ func (s *stitchingBPMCmd) Run(ctx *context) error {
 	if err != nil {
 		return err
 	}
-	pub, err := bg.ReadPubKey(s.PubKey)
+	pub, err := ctx.readPubKey(s.PubKey)
 	if err != nil {
 		return err
 	}
 	if len(bpmData) < 1 || len(sig) < 1 {
 		return fmt.Errorf("loaded files are empty")
 	}
+	if err := bg.CheckCutSidecar(s.BPM, "BPM", bpmData); err != nil {
+		return err
+	}
 	reader := bytes.NewReader(bpmData)
-	bpm, err := bg.ParseBPM(reader)
+	bpm, err := ctx.Parse.ParseBPM(reader)
 	if err != nil {
 		return err
 	}
@@ -662,7 +2350,7 @@ func (s *stitchingBPMCmd) Run(ctx *context) error {
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(s.Out, bpmRaw, 0644); err != nil {
+	if err := bg.WriteFileAtomic(s.Out, bpmRaw, 0644); err != nil {
 		return err
 	}
 	return nil
@@ -675,81 +2363,380 @@ func (s *stitchingCmd) Run(ctx *context) error {
 	if len(acm) == 0 && len(km) == 0 && len(bpm) == 0 {
 		return fmt.Errorf("at least one optional parameter required")
 	}
-	if err := bg.StitchFITEntries(s.BIOS, acm, bpm, km); err != nil {
+	acmData := acm
+	if len(acmData) == 0 && (len(km) > 0 || len(bpm) > 0) {
+		// Check against the ACM already present in the image
+		if image, err := ioutil.ReadFile(s.BIOS); err == nil {
+			_, _, acmData, _ = ctx.Parse.ParseFITEntries(image)
+		}
+	}
+	if len(km) > 0 && len(acmData) > 0 {
+		if err := checkKMAgainstACM(ctx, km, acmData); err != nil {
+			return err
+		}
+	}
+	if len(bpm) > 0 && len(acmData) > 0 {
+		if err := ctx.Parse.CheckBPMAgainstACM(bpm, acmData); err != nil {
+			return err
+		}
+		if err := checkBPMHashAlgsAgainstACM(ctx, bpm, acmData); err != nil {
+			return err
+		}
+	}
+	if err := s.checkACMPlatform(ctx, acm); err != nil {
+		return err
+	}
+	if len(km) > 0 && len(bpm) > 0 {
+		if err := checkManifestIDs(ctx, km, bpm, s.Config); err != nil {
+			return err
+		}
+	}
+	bios, err := ioutil.ReadFile(s.BIOS)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(s.BIOS)
+	if err != nil {
+		return err
+	}
+	var report *bg.ProvisioningReport
+	if s.Report != "" {
+		report = bg.NewProvisioningReport(programName, gittag, gitcommit)
+		report.AddInput("BIOS", s.BIOS, bios)
+		if len(acm) > 0 {
+			report.AddInput("ACM", s.ACM, acm)
+			if err := report.AddACM(acm); err != nil {
+				return fmt.Errorf("unable to add ACM to report: %w", err)
+			}
+		}
+		if len(km) > 0 {
+			report.AddInput("KM", s.KM, km)
+			if err := report.AddKM(km); err != nil {
+				return fmt.Errorf("unable to add KM to report: %w", err)
+			}
+		}
+		if len(bpm) > 0 {
+			report.AddInput("BPM", s.BPM, bpm)
+			if err := report.AddBPM(bpm); err != nil {
+				return fmt.Errorf("unable to add BPM to report: %w", err)
+			}
+		}
+	}
+	stitched, err := bg.AssembleProvisionedBIOS(bios, acm, km, bpm, bg.StitchOptions{Fill: true, FillByte: padByte(!s.FillZero), Layout: ctx.Parse.Layout})
+	if err != nil {
+		return err
+	}
+	// Stitch into a copy of the image, so a failure leaves the original intact
+	if err := bg.WriteFileAtomic(s.BIOS, stitched, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if report == nil {
+		return nil
+	}
+	report.AddOutput("BIOS", s.BIOS, stitched)
+	return writeReport(s.Report, report)
+}
+
+// checkACMPlatform fails if the stitched ACM, or the ACM of the image if no
+// ACM is stitched, doesn't support the target platform, and warns if it
+// doesn't support the TPM of the platform.
+func (s *stitchingCmd) checkACMPlatform(ctx *context, acm []byte) error {
+	tpm, err := parseTPMFamily(s.TPM)
+	if err != nil {
+		return err
+	}
+	platform := bg.ACMPlatform{
+		VendorID:   s.VendorID,
+		DeviceID:   s.DeviceID,
+		RevisionID: s.RevisionID,
+		FMS:        s.FMS,
+		PlatformID: s.PlatformID,
+	}
+	if s.DetectPlatform {
+		if platform != (bg.ACMPlatform{}) {
+			return fmt.Errorf("--detect-platform can't be combined with the platform ID flags")
+		}
+		if platform, err = bg.DetectACMPlatform(hwapi.GetAPI()); err != nil {
+			return err
+		}
+	}
+	platform.TPM = tpm
+	if platform == (bg.ACMPlatform{}) {
+		return nil
+	}
+	if len(acm) == 0 {
+		image, err := ioutil.ReadFile(s.BIOS)
+		if err != nil {
+			return err
+		}
+		if _, _, acm, err = ctx.Parse.ParseFITEntries(image); err != nil {
+			return err
+		}
+	}
+	return bg.ValidateACMPlatform(acm, platform, ctx.warnings())
+}
+
+// parseTPMFamily parses the --tpm flag, no TPM family is checked if it's empty.
+func parseTPMFamily(name string) (tools.TPMFamily, error) {
+	if name == "" {
+		return 0, nil
+	}
+	family, err := tools.ParseTPMFamily(name)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --tpm: %w", err)
+	}
+	return family, nil
+}
+
+// checkKMAgainstACM returns an error if the ACM doesn't support the hash
+// algorithms of the KM and warns if the ACM doesn't list its algorithms.
+func checkKMAgainstACM(ctx *context, km, acm []byte) error {
+	err := ctx.Parse.CheckKMAgainstACM(km, acm)
+	if errors.Is(err, bg.ErrACMHashAlgsUnknown) {
+		ctx.warnf("%v, skipping the KM hash algorithm check", err)
+		return nil
+	}
+	return err
+}
+
+// checkBPMHashAlgsAgainstACM returns an error if the ACM supports none of the
+// IBB digest algorithms of the BPM and warns if the ACM doesn't list its
+// algorithms.
+func checkBPMHashAlgsAgainstACM(ctx *context, bpm, acm []byte) error {
+	err := ctx.Parse.CheckBPMHashAlgsAgainstACM(bpm, acm)
+	if errors.Is(err, bg.ErrACMHashAlgsUnknown) {
+		ctx.warnf("%v, skipping the IBB digest algorithm check", err)
+		return nil
+	}
+	return err
+}
+
+// checkManifestIDs warns if the KM doesn't reference the signing key of the
+// BPM or if the identifiers don't match the shared identifiers of the config.
+func checkManifestIDs(ctx *context, kmData, bpmData []byte, config string) error {
+	var expected *bg.ManifestIDs
+	if config != "" {
+		bgo, err := bg.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		if bgo.IDs == nil {
+			return fmt.Errorf("config %s sets no shared identifiers (IDs)", config)
+		}
+		expected = bgo.IDs
+	}
+	km, err := ctx.Parse.ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		return err
+	}
+	bpm, err := ctx.Parse.ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		return err
+	}
+	for _, c := range bg.CheckManifestIDs(km, bpm, expected).Failed() {
+		if c.Expected != "" {
+			ctx.warnf("%s is %s, expected %s", c.Check, c.Actual, c.Expected)
+			continue
+		}
+		ctx.warnf("%s: %s", c.Check, c.Detail)
+	}
+	return nil
+}
+
+func writeReport(path string, report *bg.ProvisioningReport) error {
+	return bg.WriteFileAtomicFunc(path, 0644, func(f *os.File) error {
+		if strings.HasSuffix(path, ".json") {
+			return report.WriteJSON(f)
+		}
+		return report.WriteText(f)
+	})
+}
+
+func (l *liveVerifyCmd) Run(ctx *context) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("live-verify must be run as root to access the flash, the TXT registers and the TPM")
+	}
+	image, err := readImage(l.BIOS, l.BIOS == "")
+	if err != nil {
+		return err
+	}
+	tpmCtx, cancel := ctx.tpmContext()
+	defer cancel()
+	m, err := ctx.Parse.VerifyLiveMeasurements(tpmCtx, hwapi.GetAPI(), image)
+	if err != nil {
+		return err
+	}
+	if ctx.structured(l.JSON) {
+		return writeCheckResults(ctx, l.JSON, m.CheckResults())
+	}
+	fmt.Printf("PCR bank: %s\n", m.Bank)
+	fmt.Printf("PCR0: 0x%x\n", m.PCR0)
+	fmt.Printf("Expected PCR0: 0x%x\n", m.ExpectedPCR0)
+	if m.PCR0Matches() {
+		fmt.Println("PCR0 matches the manifests of the firmware image: BootGuard is enforcing")
+	} else {
+		fmt.Println("PCR0 doesn't match the manifests of the firmware image")
+	}
+	fmt.Printf("PCR7: 0x%x\n", m.PCR7)
+	if m.AuthorityMeasure {
+		fmt.Printf("Expected PCR7: 0x%x\n", m.ExpectedPCR7)
+		if !m.PCR7Matches() {
+			fmt.Println("PCR7 doesn't hold the authority measurement of the manifests, or the firmware extended further measurements into it")
+		}
+	}
+	if !m.PCR0Matches() {
+		return fmt.Errorf("live measurements don't match the firmware image")
+	}
+	return nil
+}
+
+// writeCheckResults prints the results in the selected output format to stdout
+// and fails if any check failed.
+func writeCheckResults(ctx *context, asJSON bool, results *bg.CheckResults) error {
+	if err := ctx.writeResult(results, asJSON); err != nil {
 		return err
 	}
+	if !results.Pass {
+		return fmt.Errorf("%d of %d checks failed", len(results.Failed()), len(results.Checks))
+	}
 	return nil
 }
 
 func (k *keygenCmd) Run(ctx *context) error {
-	kmPubFile, err := os.Create(k.Path + "km_pub.pem")
+	password, err := bg.ReadPassword(k.Password, os.Stdin)
+	if err != nil {
+		return err
+	}
+	kmPub, err := bg.CreateAtomicFile(k.Path+"km_pub.pem", 0644)
 	if err != nil {
 		return err
 	}
-	kmPrivFile, err := os.Create(k.Path + "km_priv.pem")
+	defer kmPub.Abort()
+	kmPriv, err := bg.CreateAtomicFile(k.Path+"km_priv.pem", 0600)
 	if err != nil {
 		return err
 	}
-	bpmPubFile, err := os.Create(k.Path + "bpm_pub.pem")
+	defer kmPriv.Abort()
+	bpmPub, err := bg.CreateAtomicFile(k.Path+"bpm_pub.pem", 0644)
 	if err != nil {
 		return err
 	}
-	bpmPrivFile, err := os.Create(k.Path + "bpm_priv.pem")
+	defer bpmPub.Abort()
+	bpmPriv, err := bg.CreateAtomicFile(k.Path+"bpm_priv.pem", 0600)
 	if err != nil {
 		return err
 	}
+	defer bpmPriv.Abort()
+	kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile := kmPub.File, kmPriv.File, bpmPub.File, bpmPriv.File
+	kdf := bg.KDFParams{
+		KDF:        k.KDF,
+		Iterations: k.KDFIterations,
+		ScryptN:    k.ScryptN,
+		ScryptR:    k.ScryptR,
+		ScryptP:    k.ScryptP,
+	}
 
 	switch k.Algo {
 	case "RSA2048":
-		err := bg.GenRSAKey(2048, k.Password, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
+		err := bg.GenRSAKey(2048, password, kdf, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
 		if err != nil {
 			return err
 		}
 	case "RSA3072":
-		err := bg.GenRSAKey(3072, k.Password, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
+		err := bg.GenRSAKey(3072, password, kdf, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
 		if err != nil {
 			return err
 		}
 	case "ECC224":
-		err := bg.GenECCKey(224, k.Password, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
+		err := bg.GenECCKey(224, password, kdf, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
 		if err != nil {
 			return err
 		}
 	case "ECC256":
-		err := bg.GenECCKey(256, k.Password, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
+		err := bg.GenECCKey(256, password, kdf, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile)
 		if err != nil {
 			return err
 		}
+	case "Ed25519", "ED25519":
+		return fmt.Errorf("%w: Ed25519, the KM and BPM carry RSA, ECC and SM2 keys only", manifest.ErrUnsupportedKeyType)
 	default:
 		return fmt.Errorf("Chosen algorithm invlid. Options are: RSA2048, RSA3072, ECC224, ECC256")
 	}
 
+	for _, f := range []*bg.AtomicFile{kmPub, kmPriv, bpmPub, bpmPriv} {
+		if err := f.Commit(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 var cli struct {
-	Debug                    bool `help:"Enable debug mode."`
-	ManifestStrictOrderCheck bool `help:"Enable checking of manifest elements order"`
-
-	KMShow   kmPrintCmd     `cmd help:"Prints Key Manifest binary in human-readable format"`
-	KMGen    generateKMCmd  `cmd help:"Generate KM file based von json configuration"`
-	KMSign   signKMCmd      `cmd help:"Sign key manifest with given key"`
-	KMStitch stitchingKMCmd `cmd help:"Stitches KM Signatue into unsigned KM"`
-	KMExport kmExportCmd    `cmd help:"Exports KM structures from BIOS image into file"`
-
-	BPMShow   bpmPrintCmd     `cmd help:"Prints Boot Policy Manifest binary in human-readable format"`
-	BPMGen    generateBPMCmd  `cmd help:"Generate BPM file based von json configuration"`
-	BPMSign   signBPMCmd      `cmd help:"Sign Boot Policy Manifest with given key"`
-	BPMStitch stitchingBPMCmd `cmd help:"Stitches BPM Signatue into unsigned BPM"`
-	BPMExport bpmExportCmd    `cmd help:"Exports BPM structures from BIOS image into file"`
+	Debug                    bool          `help:"Enable debug mode."`
+	ManifestStrictOrderCheck bool          `help:"Enable checking of manifest elements order"`
+	Strict                   bool          `help:"Reject KM and BPM with non-zero reserved fields, flags or padding"`
+	StrictWarnings           bool          `name:"strict-warnings" help:"Treat validation warnings as errors and exit non-zero if any was issued"`
+	Quiet                    bool          `short:"q" help:"Print only the results of the command, e.g. a verification verdict or a JSON document, and errors"`
+	TPMTimeout               time.Duration `name:"tpm-timeout" default:"30s" help:"Abort TPM operations which don't complete in time, e.g. on a wedged TPM. 0 disables the timeout"`
+	TPMRetries               int           `name:"tpm-retries" default:"5" help:"Retry NV reads the TPM answers with a retry later or rate limit response code up to this many times"`
+	TPMRetryDelay            time.Duration `name:"tpm-retry-delay" default:"10ms" help:"Delay before the first retry of a TPM NV read, doubled with every further retry"`
+	FlashSize                uint64        `name:"flash-size" help:"Size of the flash chip, for images which are only a part of the flash, e.g. a BIOS region dump. The end of the flash is mapped to 4GiB"`
+	RegionOffset             uint64        `name:"region-offset" help:"Flash offset of the first byte of the image, used with --flash-size"`
+	OutputFormat             string        `name:"output-format" default:"text" help:"Format of the results of the show, verify and check commands: text, json or yaml. --json of a command selects json"`
+	PubKeyFormat             string        `name:"pubkey-format" default:"auto" help:"Format of the public key files: auto detects PEM, DER and SEC1 points, rsa-modulus reads a raw big-endian 2048 or 3072 bit RSA modulus"`
+
+	KMShow     kmPrintCmd     `cmd help:"Prints Key Manifest binary in human-readable format"`
+	KMGen      generateKMCmd  `cmd help:"Generate KM file based von json configuration"`
+	KMSign     signKMCmd      `cmd help:"Sign key manifest with given key"`
+	KMStitch   stitchingKMCmd `cmd help:"Stitches KM Signatue into unsigned KM"`
+	KMVerify   kmVerifyCmd    `cmd help:"Verifies the signature of a signed KM and reports the signature scheme"`
+	KMCheckACM kmCheckACMCmd  `cmd help:"Checks that the ACM supports the hash algorithms of the KM"`
+	KMExport   kmExportCmd    `cmd help:"Exports KM structures from BIOS image into file"`
+
+	BPMShow     bpmPrintCmd     `cmd help:"Prints Boot Policy Manifest binary in human-readable format"`
+	BPMGen      generateBPMCmd  `cmd help:"Generate BPM file based von json configuration"`
+	BPMSign     signBPMCmd      `cmd help:"Sign Boot Policy Manifest with given key"`
+	BPMStitch   stitchingBPMCmd `cmd help:"Stitches BPM Signatue into unsigned BPM"`
+	BPMVerify   bpmVerifyCmd    `cmd help:"Verifies the signature of a signed BPM and reports the signature scheme"`
+	BPMCheckACM bpmCheckACMCmd  `cmd help:"Checks that the SVN of the ACM meets the minimum ACM SVN (ACMSVNAuth) of the BPM"`
+	BPMExport   bpmExportCmd    `cmd help:"Exports BPM structures from BIOS image into file"`
+	BPMMatch    bpmMatchCmd     `cmd help:"Checks that a BIOS image contains exactly the given BPM, e.g. after stitching"`
 
 	ACMExport acmExportCmd `cmd help:"Exports ACM structures from BIOS image into file"`
 	ACMShow   acmPrintCmd  `cmd help:"Prints ACM binary in human-readable format"`
-
-	ShowAll    biosPrintCmd  `cmd help:"Prints BPM, KM, FIT and ACM from BIOS binary in human-readable format"`
-	Stitch     stitchingCmd  `cmd help:"Stitches BPM, KM and ACM into given BIOS image file"`
-	KeyGen     keygenCmd     `cmd help:"Generates key for KM and BPM signing"`
-	Template   templateCmd   `cmd help:"Writes template JSON configuration into file"`
-	ReadConfig readConfigCmd `cmd help:"Reads config from existing BIOS file and translates it to a JSON configuration"`
-	Version    versionCmd    `cmd help:"Prints the version of the program"`
+	ACMDump   acmDumpCmd   `cmd help:"Dumps ACM binary into an editable JSON file"`
+	ACMLoad   acmLoadCmd   `cmd help:"Reconstructs ACM binary from a JSON file generated by acm-dump (not validly signed)"`
+	ACMVerify acmVerifyCmd `cmd help:"Verifies the RSA signature of an ACM binary"`
+	ACMError  acmErrorCmd  `cmd help:"Decodes an ACM error code reported at boot into a human-readable description"`
+
+	Cosign        cosignCmd        `cmd help:"Adds a second signature to a signed KM or BPM (unsupported by the manifest formats)"`
+	Size          sizeCmd          `cmd help:"Prints the total, signed region and signature sizes of a KM or BPM binary"`
+	SizePlan      sizePlanCmd      `cmd help:"Prints the KM and BPM sizes of a config for every signing key type and the ACM size"`
+	IBBSegments   ibbSegmentsCmd   `cmd help:"Lists the IBB segments of a BPM or config in address order and reports gaps and overlaps"`
+	Coverage      coverageCmd      `cmd help:"Prints the byte ranges of a KM or BPM covered by the signature"`
+	CheckHashes   checkHashesCmd   `cmd help:"Recomputes the hashes, sizes and offsets stored in a KM or BPM and reports stale ones"`
+	Redact        redactCmd        `cmd help:"Writes a copy of a KM or BPM for sharing with the signature and optionally the platform manufacturer data zeroed"`
+	Reconcile     reconcileCmd     `cmd help:"Checks that a KM and the BPM it should authorize agree on the BPM key hash, the SVNs and the algorithms"`
+	KeyChain      keyChainCmd      `cmd help:"Reports the OEM, KM and BPM key hashes of a BIOS image side by side and checks the chain"`
+	VerifyAll     verifyAllCmd     `cmd help:"Runs every consistency check of a BIOS image and fails if any of them fails"`
+	TestVectors   testVectorsCmd   `cmd name:"gen-testvectors" help:"Writes BIOS images signed with ephemeral keys and the verify-all verdict expected for each, to test verifiers"`
+	Compare       compareCmd       `cmd help:"Compares two KMs, BPMs or BIOS images ignoring the signatures to check builds for reproducibility"`
+	PCR0Diff      pcr0DiffCmd      `cmd name:"pcr0-diff" help:"Predicts PCR0 for two BIOS images and reports which measurements differ"`
+	PCR7          pcr7Cmd          `cmd name:"pcr7" help:"Predicts the BootGuard authority measurement the S-ACM extends into PCR7 for a BIOS image"`
+	FIT           fitCmd           `cmd help:"Lists the FIT entries of a BIOS image with their BootGuard role and checks the referenced structures parse"`
+	CryptoReport  cryptoReportCmd  `cmd help:"Lists the hash, key and signature algorithms used by the KM, BPM and ACM of a BIOS image"`
+	Tree          treeCmd          `cmd help:"Shows the trust chain of a BIOS image from the fused OEM key hash to the IBB entry point as a tree"`
+	ShowAll       biosPrintCmd     `cmd help:"Prints BPM, KM, FIT and ACM from BIOS binary in human-readable format"`
+	Stitch        stitchingCmd     `cmd help:"Stitches BPM, KM and ACM into given BIOS image file"`
+	LiveVerify    liveVerifyCmd    `cmd help:"Verifies the live PCR0/PCR7 measurements against the booted firmware image (requires root)"`
+	KeyGen        keygenCmd        `cmd help:"Generates key for KM and BPM signing"`
+	Template      templateCmd      `cmd help:"Writes template JSON configuration into file"`
+	ReadConfig    readConfigCmd    `cmd help:"Reads config from existing BIOS file and translates it to a JSON configuration"`
+	LintConfig    lintConfigCmd    `cmd help:"Checks a JSON config against the KM and BPM constraints without BIOS image or keys"`
+	ConfigDiff    configDiffCmd    `cmd help:"Reports the fields which differ between two JSON configs grouped by header, IBB, TXT and keys"`
+	FmtConfig     fmtConfigCmd     `cmd help:"Rewrites a JSON config in canonical form with sorted keys and consistent indentation"`
+	MigrateConfig migrateConfigCmd `cmd help:"Migrates a BootGuard 1.0 JSON config to a CBnT config, marking the fields which need manual attention as TODO"`
+	ConfigXML     configXMLCmd     `cmd help:"Writes a config in an XML layout approximating the one of Intel's MEU"`
+	CHeader       cHeaderCmd       `cmd help:"Writes a KM or BPM binary as C header for embedding it into a bootloader build"`
+	Version       versionCmd       `cmd help:"Prints the version of the program"`
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const testACMPath = "../../pkg/tools/tests/sinit_acm.bin"
+
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() failed: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	return string(out)
+}
+
+func TestQuietACMVerify(t *testing.T) {
+	var err error
+	out := captureStdout(t, func() {
+		err = (&acmVerifyCmd{Path: testACMPath}).Run(&context{Quiet: true})
+	})
+	if err != nil {
+		t.Fatalf("acm-verify failed: %v", err)
+	}
+	want := "ACM signature is valid for the key in the ACM header (no --pubkey given, origin not verified)\n"
+	if out != want {
+		t.Errorf("acm-verify --quiet printed %q, want only the verdict %q", out, want)
+	}
+
+	out = captureStdout(t, func() {
+		err = (&acmVerifyCmd{Path: testACMPath}).Run(&context{})
+	})
+	if err != nil {
+		t.Fatalf("acm-verify failed: %v", err)
+	}
+	if out == want {
+		t.Errorf("acm-verify without --quiet printed only the verdict, want the signing details too")
+	}
+}
+
+func TestQuietACMVerifyJSON(t *testing.T) {
+	var err error
+	out := captureStdout(t, func() {
+		err = (&acmVerifyCmd{Path: testACMPath, JSON: true}).Run(&context{Quiet: true})
+	})
+	if err != nil {
+		t.Fatalf("acm-verify failed: %v", err)
+	}
+	var result struct {
+		Pass bool `json:"pass"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("acm-verify --quiet --json didn't print only a JSON document: %v\n%s", err, out)
+	}
+	if !result.Pass {
+		t.Errorf("acm-verify --json reported a failure:\n%s", out)
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
-	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
+	"github.com/9elements/converged-security-suite/v2/pkg/provisioning/bg"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
 	"github.com/alecthomas/kong"
 )
 
@@ -24,7 +30,34 @@ func main() {
 			Compact: true,
 			Summary: true,
 		}))
-	manifest.StrictOrderCheck = cli.ManifestStrictOrderCheck
-	err := ctx.Run(&context{Debug: cli.Debug})
+	parse := bg.ParseOptions{
+		StrictReserved: cli.Strict,
+		StrictOrder:    cli.ManifestStrictOrderCheck,
+		Warnings:       &bg.WarningCollector{Out: os.Stderr, Strict: cli.StrictWarnings},
+	}
+	hwapi.DefaultTPMRetryPolicy = hwapi.TPMRetryPolicy{MaxRetries: cli.TPMRetries, BaseDelay: cli.TPMRetryDelay}
+	if cli.FlashSize != 0 {
+		parse.Layout = &tools.FlashLayout{FlashSize: cli.FlashSize, RegionOffset: cli.RegionOffset}
+	} else if cli.RegionOffset != 0 {
+		ctx.Fatalf("--region-offset requires --flash-size")
+	}
+	pubKeyFormat := bg.PubKeyFormat(cli.PubKeyFormat)
+	if pubKeyFormat != bg.PubKeyFormatAuto && pubKeyFormat != bg.PubKeyFormatRSAModulus {
+		ctx.Fatalf("invalid --pubkey-format '%s', available: %s, %s", cli.PubKeyFormat, bg.PubKeyFormatAuto, bg.PubKeyFormatRSAModulus)
+	}
+	output, err := bg.NewOutputFormatter(cli.OutputFormat)
+	ctx.FatalIfErrorf(err)
+	err = ctx.Run(&context{Debug: cli.Debug, Quiet: cli.Quiet, TPMTimeout: cli.TPMTimeout, Output: output, PubKeyFormat: pubKeyFormat, Parse: parse})
+	if err == nil {
+		err = parse.Warnings.Err()
+	}
+	if errors.Is(err, tools.ErrAddressOutOfImage) && parse.Layout == nil {
+		err = fmt.Errorf("%w (if the image is a partial dump, e.g. of the BIOS region only, pass its position in the flash with --flash-size and --region-offset)", err)
+	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		fmt.Fprintf(os.Stderr, "%s: error: %v\n", programName, err)
+		os.Exit(exitErr.code)
+	}
 	ctx.FatalIfErrorf(err)
 }
@@ -1,32 +1,40 @@
 module github.com/9elements/converged-security-suite/v2
 
-go 1.13
+go 1.18
 
 require (
 	github.com/alecthomas/kong v0.2.11
 	github.com/creasty/defaults v1.5.1
-	github.com/dsnet/compress v0.0.1 // indirect
 	github.com/dustin/go-humanize v1.0.0
 	github.com/fatih/camelcase v1.0.0
-	github.com/fatih/structtag v1.2.0 // indirect
 	github.com/fearful-symmetry/gomsr v0.0.1
-	github.com/frankban/quicktest v1.11.3 // indirect
-	github.com/golang/snappy v0.0.2 // indirect
 	github.com/google/go-tpm v0.3.3-0.20210120190357-1ff48daca32f
 	github.com/intel-go/cpuid v0.0.0-20200819041909-2aa72927c3e2
 	github.com/linuxboot/fiano v5.0.0+incompatible
 	github.com/logrusorgru/aurora v2.0.3+incompatible
 	github.com/mholt/archiver v3.1.1+incompatible
-	github.com/nwaples/rardecode v1.1.0 // indirect
-	github.com/pierrec/lz4 v2.6.0+incompatible // indirect
 	github.com/spf13/pflag v1.0.3
 	github.com/stretchr/testify v1.2.2
 	github.com/tidwall/pretty v1.0.2
 	github.com/tjfoc/gmsm v1.4.0
-	github.com/ulikunitz/xz v0.5.8 // indirect
+	github.com/ulikunitz/xz v0.5.8
 	github.com/xaionaro-go/gosrc v0.0.0-20201124181305-3fdf8476a735
+	golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dsnet/compress v0.0.1 // indirect
+	github.com/fatih/structtag v1.2.0 // indirect
+	github.com/frankban/quicktest v1.11.3 // indirect
+	github.com/golang/snappy v0.0.2 // indirect
+	github.com/nwaples/rardecode v1.1.0 // indirect
+	github.com/pierrec/lz4 v2.6.0+incompatible // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/xaionaro-go/unsafetools v0.0.0-20200202162159-021b112c4d30 // indirect
 	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
-	golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee
 	golang.org/x/sys v0.0.0-20210218155724-8ebf48af031b // indirect
+	golang.org/x/text v0.3.3 // indirect
 )
@@ -0,0 +1,338 @@
+package psp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DirectoryCookie identifies the type of a directory.
+type DirectoryCookie string
+
+// Directory cookies as found in the directory headers.
+const (
+	PSPDirectoryCookie        DirectoryCookie = "$PSP"
+	PSPLevel2DirectoryCookie  DirectoryCookie = "$PL2"
+	BIOSDirectoryCookie       DirectoryCookie = "$BHD"
+	BIOSLevel2DirectoryCookie DirectoryCookie = "$BL2"
+	PSPComboDirectoryCookie   DirectoryCookie = "2PSP"
+	BIOSComboDirectoryCookie  DirectoryCookie = "2BHD"
+)
+
+// IsPSP returns true for PSP directories, false for BIOS directories.
+func (c DirectoryCookie) IsPSP() bool {
+	return c == PSPDirectoryCookie || c == PSPLevel2DirectoryCookie || c == PSPComboDirectoryCookie
+}
+
+// IsCombo returns true for combo directories, which point to a directory per SoC.
+func (c DirectoryCookie) IsCombo() bool {
+	return c == PSPComboDirectoryCookie || c == BIOSComboDirectoryCookie
+}
+
+// Entry types which point to a further directory.
+const (
+	PSPLevel2DirectoryEntry  uint8 = 0x40
+	BIOSLevel2DirectoryEntry uint8 = 0x70
+)
+
+var pspEntryTypeNames = map[uint8]string{
+	0x00:                    "AMD public key",
+	0x01:                    "PSP boot loader",
+	0x02:                    "PSP secure OS",
+	0x03:                    "PSP recovery boot loader",
+	0x04:                    "PSP non-volatile data",
+	0x08:                    "SMU firmware",
+	0x09:                    "AMD secure debug key",
+	0x0A:                    "OEM public key",
+	0x0B:                    "Soft fuse chain",
+	0x0C:                    "PSP trustlets",
+	0x0D:                    "PSP trustlet key",
+	0x12:                    "SMU firmware 2",
+	0x13:                    "PSP early secure unlock debug",
+	0x1A:                    "PSP S3 non-volatile data",
+	0x21:                    "Wrapped iKEK",
+	0x22:                    "PSP token unlock data",
+	0x24:                    "Security policy",
+	0x25:                    "MP2 firmware",
+	0x28:                    "PSP system driver",
+	0x30:                    "AGESA boot loader 0",
+	0x31:                    "AGESA boot loader 1",
+	0x32:                    "AGESA boot loader 2",
+	0x33:                    "AGESA boot loader 3",
+	0x34:                    "AGESA boot loader 4",
+	0x35:                    "AGESA boot loader 5",
+	0x36:                    "AGESA boot loader 6",
+	0x37:                    "AGESA boot loader 7",
+	PSPLevel2DirectoryEntry: "PSP level 2 directory",
+}
+
+var biosEntryTypeNames = map[uint8]string{
+	0x05:                     "BIOS public key",
+	0x07:                     "BIOS signature",
+	0x60:                     "AGESA PSP customization block (APCB)",
+	0x61:                     "AGESA PSP output block (APOB)",
+	0x62:                     "BIOS binary",
+	0x63:                     "APOB non-volatile copy",
+	0x64:                     "PMU firmware instructions",
+	0x65:                     "PMU firmware data",
+	0x66:                     "Microcode patch",
+	0x67:                     "Core machine exception data",
+	0x68:                     "APCB backup",
+	BIOSLevel2DirectoryEntry: "BIOS level 2 directory",
+}
+
+// DirectoryHeader is the common header of PSP and BIOS directories.
+type DirectoryHeader struct {
+	Cookie     [4]byte
+	Checksum   uint32
+	NumEntries uint32
+	// AdditionalInfo holds the directory size and flags for PSP and BIOS
+	// directories and the lookup mode for combo directories.
+	AdditionalInfo uint32
+}
+
+// PSPDirectoryEntry is an entry of a PSP directory.
+type PSPDirectoryEntry struct {
+	Type       uint8
+	SubProgram uint8
+	Flags      uint16
+	Size       uint32
+	Location   uint64
+}
+
+// BIOSDirectoryEntry is an entry of a BIOS directory.
+type BIOSDirectoryEntry struct {
+	Type        uint8
+	RegionType  uint8
+	Flags       uint8
+	SubProgram  uint8
+	Size        uint32
+	Source      uint64
+	Destination uint64
+}
+
+// ComboDirectoryEntry points to the directory of a SoC in a combo directory.
+type ComboDirectoryEntry struct {
+	IDSelect  uint32
+	ID        uint32
+	Directory uint64
+}
+
+// DirectoryEntry is an entry of any directory type in a uniform form.
+type DirectoryEntry struct {
+	Type        uint8
+	SubProgram  uint8
+	Size        uint32
+	Location    uint64
+	Destination uint64
+}
+
+// Directory is a parsed PSP, BIOS or combo directory.
+type Directory struct {
+	Offset  uint64
+	Header  DirectoryHeader
+	Entries []DirectoryEntry
+}
+
+// Cookie returns the cookie of the directory.
+func (d *Directory) Cookie() DirectoryCookie {
+	return DirectoryCookie(d.Header.Cookie[:])
+}
+
+// ChecksumValid returns true if the Fletcher-32 checksum of the directory is valid.
+func (d *Directory) ChecksumValid(image []byte) bool {
+	start := d.Offset + 8
+	end := d.Offset + d.Size()
+	if end > uint64(len(image)) {
+		return false
+	}
+	return Fletcher32(image[start:end]) == d.Header.Checksum
+}
+
+// Size returns the size of the header and the entries of the directory.
+func (d *Directory) Size() uint64 {
+	size := uint64(binary.Size(d.Header)) + uint64(d.Header.NumEntries)*d.entrySize()
+	if d.Cookie().IsCombo() {
+		size += comboReservedSize
+	}
+	return size
+}
+
+func (d *Directory) entrySize() uint64 {
+	switch {
+	case d.Cookie().IsCombo():
+		return uint64(binary.Size(ComboDirectoryEntry{}))
+	case d.Cookie().IsPSP():
+		return uint64(binary.Size(PSPDirectoryEntry{}))
+	}
+	return uint64(binary.Size(BIOSDirectoryEntry{}))
+}
+
+// TypeName returns a human readable name of the entry type.
+func (d *Directory) TypeName(e DirectoryEntry) string {
+	names := biosEntryTypeNames
+	if d.Cookie().IsPSP() {
+		names = pspEntryTypeNames
+	}
+	if d.Cookie().IsCombo() {
+		return "SoC directory"
+	}
+	if name, ok := names[e.Type]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// Fletcher32 calculates the Fletcher-32 checksum over the little-endian 16 bit
+// words of data as used by the PSP and BIOS directories.
+func Fletcher32(data []byte) uint32 {
+	var c0, c1 uint32 = 0xFFFF, 0xFFFF
+	words := len(data) / 2
+	for i := 0; i < words; {
+		// 359 words is the maximum before c1 may overflow
+		blockEnd := i + 359
+		if blockEnd > words {
+			blockEnd = words
+		}
+		for ; i < blockEnd; i++ {
+			c0 += uint32(binary.LittleEndian.Uint16(data[2*i:]))
+			c1 += c0
+		}
+		c0 = (c0 & 0xFFFF) + (c0 >> 16)
+		c1 = (c1 & 0xFFFF) + (c1 >> 16)
+	}
+	c0 = (c0 & 0xFFFF) + (c0 >> 16)
+	c1 = (c1 & 0xFFFF) + (c1 >> 16)
+	return c1<<16 | c0
+}
+
+// comboReservedSize is the size of the reserved bytes between the header and
+// the entries of combo directories.
+const comboReservedSize = 16
+
+// maxDirectoryEntries bounds the entries read from a directory header to
+// avoid huge allocations for corrupted headers.
+const maxDirectoryEntries = 0x400
+
+// ParseDirectory parses the directory at addr in the image.
+func ParseDirectory(image []byte, addr uint64) (*Directory, error) {
+	offset, err := ImageOffset(image, addr)
+	if err != nil {
+		return nil, err
+	}
+	d := &Directory{Offset: offset}
+	r := bytes.NewReader(image[offset:])
+	if err := binary.Read(r, binary.LittleEndian, &d.Header); err != nil {
+		return nil, fmt.Errorf("unable to read the directory header at 0x%x: %w", offset, err)
+	}
+	switch d.Cookie() {
+	case PSPDirectoryCookie, PSPLevel2DirectoryCookie, BIOSDirectoryCookie, BIOSLevel2DirectoryCookie,
+		PSPComboDirectoryCookie, BIOSComboDirectoryCookie:
+	default:
+		return nil, fmt.Errorf("unknown directory cookie %q at 0x%x", d.Header.Cookie[:], offset)
+	}
+	if d.Header.NumEntries > maxDirectoryEntries {
+		return nil, fmt.Errorf("directory at 0x%x has too many entries: %d", offset, d.Header.NumEntries)
+	}
+	if d.Cookie().IsCombo() {
+		if _, err := r.Seek(comboReservedSize, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	for i := uint32(0); i < d.Header.NumEntries; i++ {
+		var e DirectoryEntry
+		switch {
+		case d.Cookie().IsCombo():
+			var ce ComboDirectoryEntry
+			err = binary.Read(r, binary.LittleEndian, &ce)
+			e = DirectoryEntry{Location: ce.Directory}
+		case d.Cookie().IsPSP():
+			var pe PSPDirectoryEntry
+			err = binary.Read(r, binary.LittleEndian, &pe)
+			e = DirectoryEntry{Type: pe.Type, SubProgram: pe.SubProgram, Size: pe.Size, Location: pe.Location}
+		default:
+			var be BIOSDirectoryEntry
+			err = binary.Read(r, binary.LittleEndian, &be)
+			e = DirectoryEntry{Type: be.Type, SubProgram: be.SubProgram, Size: be.Size, Location: be.Source, Destination: be.Destination}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read entry %d of the directory at 0x%x: %w", i, offset, err)
+		}
+		d.Entries = append(d.Entries, e)
+	}
+	return d, nil
+}
+
+// PrettyPrint prints a human readable representation of the directory.
+func (d *Directory) PrettyPrint(image []byte) {
+	fmt.Printf("----AMD %s Directory----\n", d.Cookie())
+	fmt.Println()
+	fmt.Printf("   Offset: 0x%08x\n", d.Offset)
+	fmt.Printf("   Entries: %d\n", d.Header.NumEntries)
+	fmt.Printf("   Checksum: 0x%08x (valid: %v)\n", d.Header.Checksum, d.ChecksumValid(image))
+	for idx, e := range d.Entries {
+		fmt.Printf("   Entry %d: type 0x%02x (%s), sub program %d, size 0x%x, location 0x%x",
+			idx, e.Type, d.TypeName(e), e.SubProgram, e.Size, e.Location)
+		if e.Destination != 0 && e.Destination != ^uint64(0) {
+			fmt.Printf(", destination 0x%x", e.Destination)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+// Directories parses all directories reachable from the EFS, following combo
+// and level 2 directory entries. Directories which can't be parsed are
+// reported in errs, the others are still returned.
+func Directories(image []byte, efs *EmbeddedFirmwareStructure) (dirs []*Directory, errs []error) {
+	visited := map[uint64]bool{}
+	var visit func(addr uint64)
+	visit = func(addr uint64) {
+		if addr == 0 || addr == 0xFFFFFFFF {
+			return
+		}
+		d, err := ParseDirectory(image, addr)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if visited[d.Offset] {
+			return
+		}
+		visited[d.Offset] = true
+		dirs = append(dirs, d)
+		for _, e := range d.Entries {
+			switch {
+			case d.Cookie().IsCombo(),
+				d.Cookie().IsPSP() && e.Type == PSPLevel2DirectoryEntry,
+				!d.Cookie().IsPSP() && e.Type == BIOSLevel2DirectoryEntry:
+				visit(e.Location)
+			}
+		}
+	}
+	for _, addr := range []uint32{
+		efs.PSPDirectory, efs.ComboPSPDirectory,
+		efs.BIOSDirectory0, efs.BIOSDirectory1, efs.BIOSDirectory2, efs.BIOSDirectory3,
+	} {
+		visit(uint64(addr))
+	}
+	return dirs, errs
+}
+
+// PrintStructures prints the EFS of the image and all directories reachable from it.
+func PrintStructures(image []byte) error {
+	efs, offset, err := FindEFS(image)
+	if err != nil {
+		return err
+	}
+	efs.PrettyPrint(offset)
+	dirs, errs := Directories(image, efs)
+	for _, d := range dirs {
+		d.PrettyPrint(image)
+	}
+	for _, err := range errs {
+		fmt.Printf("Unable to parse directory: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package psp
+
+import (
+	"testing"
+)
+
+func TestFletcher32(t *testing.T) {
+	if sum := Fletcher32([]byte("abcdef")); sum != 0x56502D2A {
+		t.Errorf("Fletcher32(abcdef) = 0x%08x, expected 0x56502d2a", sum)
+	}
+	if sum := Fletcher32(make([]byte, 4096)); sum != 0xFFFFFFFF {
+		t.Errorf("Fletcher32(zeros) = 0x%08x, expected 0xffffffff", sum)
+	}
+}
+
+func TestDirectories(t *testing.T) {
+	image := testImage()
+	efs, _, err := FindEFS(image)
+	if err != nil {
+		t.Fatalf("FindEFS() failed: %v", err)
+	}
+	dirs, errs := Directories(image, efs)
+	if len(errs) != 0 {
+		t.Errorf("Directories() reported errors: %v", errs)
+	}
+	var cookies []DirectoryCookie
+	for _, d := range dirs {
+		cookies = append(cookies, d.Cookie())
+		if !d.ChecksumValid(image) {
+			t.Errorf("Checksum of %s directory is invalid", d.Cookie())
+		}
+	}
+	expected := []DirectoryCookie{PSPDirectoryCookie, PSPLevel2DirectoryCookie, BIOSDirectoryCookie}
+	if len(cookies) != len(expected) {
+		t.Fatalf("Directories() returned %v, expected %v", cookies, expected)
+	}
+	for i := range expected {
+		if cookies[i] != expected[i] {
+			t.Errorf("Directories() returned %v, expected %v", cookies, expected)
+		}
+	}
+
+	bios := dirs[2]
+	if len(bios.Entries) != 1 || bios.TypeName(bios.Entries[0]) != "BIOS binary" || bios.Entries[0].Destination != 0x09F00000 {
+		t.Errorf("BIOS directory entries are %+v", bios.Entries)
+	}
+	if name := dirs[0].TypeName(dirs[0].Entries[1]); name != "PSP level 2 directory" {
+		t.Errorf("TypeName() of the level 2 entry is %q", name)
+	}
+
+	image[dirs[0].Offset+16] ^= 0xff
+	if dirs[0].ChecksumValid(image) {
+		t.Errorf("Checksum of a corrupted directory is valid")
+	}
+}
+
+func TestComboDirectory(t *testing.T) {
+	image := testImage()
+	putDirectory(image, 0x50000, PSPComboDirectoryCookie,
+		ComboDirectoryEntry{ID: 0xBC0A0000, Directory: 0x10000},
+	)
+	d, err := ParseDirectory(image, 0x50000)
+	if err != nil {
+		t.Fatalf("ParseDirectory() failed: %v", err)
+	}
+	if !d.Cookie().IsCombo() || len(d.Entries) != 1 || d.Entries[0].Location != 0x10000 || !d.ChecksumValid(image) {
+		t.Errorf("ParseDirectory() of a combo directory returned %+v", d)
+	}
+
+	if _, err := ParseDirectory(image, 0x60000); err == nil {
+		t.Errorf("ParseDirectory() succeeded without a directory cookie")
+	}
+}
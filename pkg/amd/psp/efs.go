@@ -0,0 +1,129 @@
+// Package psp parses the AMD Platform Security Processor (PSP) firmware
+// structures of an AMD firmware image: the Embedded Firmware Structure (EFS),
+// which plays the role of the Intel FIT, and the PSP and BIOS directories it
+// points to. The package is read-only, it doesn't generate or modify images.
+package psp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// EFSSignature is the signature at the start of the Embedded Firmware Structure.
+	EFSSignature uint32 = 0x55AA55AA
+
+	// FourGiB is the top of the 32 bit address space the flash is mapped below.
+	FourGiB uint64 = 0x100000000
+)
+
+// EFSAddresses are the addresses below 4GiB where the AMD firmware looks for the EFS.
+var EFSAddresses = []uint64{0xFFFA0000, 0xFFF20000, 0xFFE20000, 0xFFC20000, 0xFF820000, 0xFF020000}
+
+// ErrEFSNotFound is returned if the image doesn't contain an Embedded Firmware Structure.
+var ErrEFSNotFound = errors.New("AMD Embedded Firmware Structure not found")
+
+// EmbeddedFirmwareStructure is the AMD table pointing to the firmware
+// components of the image, analogous to the Intel FIT.
+type EmbeddedFirmwareStructure struct {
+	Signature           uint32
+	IMCEntry            uint32
+	GECEntry            uint32
+	XHCIEntry           uint32
+	PSPDirectory        uint32
+	ComboPSPDirectory   uint32
+	BIOSDirectory0      uint32
+	BIOSDirectory1      uint32
+	BIOSDirectory2      uint32
+	SecondGenEFS        uint32
+	BIOSDirectory3      uint32
+	Reserved2C          uint32
+	PromontoryFirmware  uint32
+	LowPowerPromontory  uint32
+	Reserved38          uint32
+	Reserved3C          uint32
+	SPIReadModeF15M60   uint8
+	SPIFastSpeedF15M60  uint8
+	Reserved42          uint8
+	SPIReadModeF17M00   uint8
+	SPIFastSpeedF17M00  uint8
+	QPRDummyCycleF17M00 uint8
+	Reserved46          uint8
+	SPIReadModeF17M30   uint8
+	SPIFastSpeedF17M30  uint8
+	MicronDetectF17M30  uint8
+	Reserved4A          uint8
+	Reserved4B          uint8
+	Reserved4C          uint32
+}
+
+// IsSecondGen returns true for the second generation EFS used since family 17h
+// model 30h, which is indicated by a cleared bit 0.
+func (efs *EmbeddedFirmwareStructure) IsSecondGen() bool {
+	return efs.SecondGenEFS&1 == 0
+}
+
+// ImageOffset translates an address found in the AMD structures into an offset
+// in image. The address is either mapped below 4GiB or already a flash offset.
+func ImageOffset(image []byte, addr uint64) (uint64, error) {
+	size := uint64(len(image))
+	switch {
+	case addr == 0 || addr == 0xFFFFFFFF:
+		return 0, fmt.Errorf("address 0x%x is not set", addr)
+	case addr >= FourGiB-size && addr < FourGiB:
+		return addr - (FourGiB - size), nil
+	case addr < size:
+		return addr, nil
+	}
+	return 0, fmt.Errorf("address 0x%x is outside of the image of %d bytes", addr, size)
+}
+
+// FindEFS returns the Embedded Firmware Structure of the image and its offset.
+func FindEFS(image []byte) (*EmbeddedFirmwareStructure, uint64, error) {
+	for _, addr := range EFSAddresses {
+		offset, err := ImageOffset(image, addr)
+		if err != nil {
+			continue
+		}
+		var efs EmbeddedFirmwareStructure
+		if offset+uint64(binary.Size(efs)) > uint64(len(image)) {
+			continue
+		}
+		if binary.LittleEndian.Uint32(image[offset:]) != EFSSignature {
+			continue
+		}
+		if err := binary.Read(bytes.NewReader(image[offset:]), binary.LittleEndian, &efs); err != nil {
+			return nil, 0, fmt.Errorf("unable to read the EFS at 0x%x: %w", offset, err)
+		}
+		return &efs, offset, nil
+	}
+	return nil, 0, ErrEFSNotFound
+}
+
+// IsAMDImage returns true if the image contains an AMD Embedded Firmware Structure.
+func IsAMDImage(image []byte) bool {
+	_, _, err := FindEFS(image)
+	return err == nil
+}
+
+// PrettyPrint prints a human readable representation of the EFS.
+func (efs *EmbeddedFirmwareStructure) PrettyPrint(offset uint64) {
+	fmt.Println("----AMD Embedded Firmware Structure----")
+	fmt.Println()
+	fmt.Printf("   Offset: 0x%08x\n", offset)
+	fmt.Printf("   Second Generation: %v\n", efs.IsSecondGen())
+	fmt.Printf("   IMC Firmware: 0x%08x\n", efs.IMCEntry)
+	fmt.Printf("   GbE Firmware: 0x%08x\n", efs.GECEntry)
+	fmt.Printf("   xHCI Firmware: 0x%08x\n", efs.XHCIEntry)
+	fmt.Printf("   PSP Directory: 0x%08x\n", efs.PSPDirectory)
+	fmt.Printf("   Combo PSP Directory: 0x%08x\n", efs.ComboPSPDirectory)
+	fmt.Printf("   BIOS Directory 0: 0x%08x\n", efs.BIOSDirectory0)
+	fmt.Printf("   BIOS Directory 1: 0x%08x\n", efs.BIOSDirectory1)
+	fmt.Printf("   BIOS Directory 2: 0x%08x\n", efs.BIOSDirectory2)
+	fmt.Printf("   BIOS Directory 3: 0x%08x\n", efs.BIOSDirectory3)
+	fmt.Printf("   Promontory Firmware: 0x%08x\n", efs.PromontoryFirmware)
+	fmt.Printf("   Low Power Promontory Firmware: 0x%08x\n", efs.LowPowerPromontory)
+	fmt.Println()
+}
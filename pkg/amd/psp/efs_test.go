@@ -0,0 +1,97 @@
+package psp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const testImageSize = 0x100000
+
+// putDirectory writes a directory with the given cookie and raw entries at
+// offset and sets its checksum.
+func putDirectory(image []byte, offset int, cookie DirectoryCookie, entries ...interface{}) {
+	var buf bytes.Buffer
+	hdr := DirectoryHeader{NumEntries: uint32(len(entries))}
+	copy(hdr.Cookie[:], cookie)
+	binary.Write(&buf, binary.LittleEndian, hdr)
+	if cookie.IsCombo() {
+		buf.Write(make([]byte, comboReservedSize))
+	}
+	for _, e := range entries {
+		binary.Write(&buf, binary.LittleEndian, e)
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], Fletcher32(data[8:]))
+	copy(image[offset:], data)
+}
+
+// testImage returns a 1MiB AMD image with an EFS pointing to a PSP directory
+// with a level 2 directory and to a BIOS directory.
+func testImage() []byte {
+	image := make([]byte, testImageSize)
+	for i := range image {
+		image[i] = 0xff
+	}
+	efs := EmbeddedFirmwareStructure{
+		Signature:         EFSSignature,
+		PSPDirectory:      0xFFF10000,
+		ComboPSPDirectory: 0xFFFFFFFF,
+		BIOSDirectory0:    0x30000,
+		SecondGenEFS:      0xFFFFFFFE,
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, efs)
+	copy(image[0xA0000:], buf.Bytes())
+
+	putDirectory(image, 0x10000, PSPDirectoryCookie,
+		PSPDirectoryEntry{Type: 0x01, Size: 0x1000, Location: 0x40000},
+		PSPDirectoryEntry{Type: PSPLevel2DirectoryEntry, Size: 0x400, Location: 0x20000},
+	)
+	putDirectory(image, 0x20000, PSPLevel2DirectoryCookie,
+		PSPDirectoryEntry{Type: 0x08, Size: 0x2000, Location: 0xFFF50000},
+	)
+	putDirectory(image, 0x30000, BIOSDirectoryCookie,
+		BIOSDirectoryEntry{Type: 0x62, Size: 0x10000, Source: 0x60000, Destination: 0x09F00000},
+	)
+	return image
+}
+
+func TestFindEFS(t *testing.T) {
+	image := testImage()
+	efs, offset, err := FindEFS(image)
+	if err != nil {
+		t.Fatalf("FindEFS() failed: %v", err)
+	}
+	if offset != 0xA0000 || efs.PSPDirectory != 0xFFF10000 || !efs.IsSecondGen() {
+		t.Errorf("FindEFS() returned %+v at 0x%x", efs, offset)
+	}
+	if !IsAMDImage(image) {
+		t.Errorf("IsAMDImage() returned false for an AMD image")
+	}
+	if IsAMDImage(make([]byte, testImageSize)) {
+		t.Errorf("IsAMDImage() returned true for an empty image")
+	}
+}
+
+func TestImageOffset(t *testing.T) {
+	image := make([]byte, testImageSize)
+	for _, tc := range []struct {
+		addr   uint64
+		offset uint64
+		valid  bool
+	}{
+		{0xFFF00000, 0, true},
+		{0xFFFA0000, 0xA0000, true},
+		{0x30000, 0x30000, true},
+		{0xFFE00000, 0, false},
+		{0x100000, 0, false},
+		{0xFFFFFFFF, 0, false},
+		{0, 0, false},
+	} {
+		offset, err := ImageOffset(image, tc.addr)
+		if (err == nil) != tc.valid || offset != tc.offset {
+			t.Errorf("ImageOffset(0x%x) returned 0x%x, %v", tc.addr, offset, err)
+		}
+	}
+}
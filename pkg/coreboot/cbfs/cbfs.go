@@ -0,0 +1,278 @@
+// Package cbfs locates the files of the coreboot filesystem (CBFS) of a
+// coreboot image by the CBFS master header and the file headers. The package
+// is read-only, it doesn't generate or modify images.
+package cbfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// HeaderMagic is the magic of the CBFS master header, "ORBC".
+	HeaderMagic uint32 = 0x4F524243
+
+	// TypeNull is the type of the empty space between CBFS files.
+	TypeNull uint32 = 0xFFFFFFFF
+
+	// AttrTagCompression is the tag of the file attribute holding the
+	// compression algorithm.
+	AttrTagCompression uint32 = 0x42435A4C
+
+	// CompressionNone is the compression algorithm of an uncompressed file.
+	CompressionNone uint32 = 0
+)
+
+// FileMagic is the magic at the start of every CBFS file header.
+var FileMagic = []byte("LARCHIVE")
+
+var (
+	// ErrHeaderNotFound is returned if the image doesn't contain a CBFS master header.
+	ErrHeaderNotFound = errors.New("CBFS master header not found")
+	// ErrFileNotFound is returned if the CBFS doesn't contain a file of the requested name.
+	ErrFileNotFound = errors.New("CBFS file not found")
+)
+
+// Header is the CBFS master header. All fields are big endian.
+type Header struct {
+	Magic         uint32
+	Version       uint32
+	ROMSize       uint32
+	BootBlockSize uint32
+	Align         uint32
+	Offset        uint32
+	Architecture  uint32
+	Pad           uint32
+}
+
+// fileHeader is the fixed part of a CBFS file header, followed by the zero
+// terminated file name and the attributes. All fields are big endian.
+type fileHeader struct {
+	Magic            [8]byte
+	Len              uint32
+	Type             uint32
+	AttributesOffset uint32
+	Offset           uint32
+}
+
+// File is a file of the CBFS.
+type File struct {
+	Name string
+	Type uint32
+	// Offset is the offset of the file data in the image.
+	Offset uint64
+	// Compression is the compression algorithm of the data, CompressionNone
+	// if the data is stored uncompressed.
+	Compression uint32
+	Data        []byte
+}
+
+// typeNames are the names of the common CBFS file types.
+var typeNames = map[uint32]string{
+	0x01:     "bootblock",
+	0x02:     "cbfs header",
+	0x10:     "stage",
+	0x20:     "simple elf",
+	0x30:     "optionrom",
+	0x40:     "bootsplash",
+	0x50:     "raw",
+	0x51:     "vsa",
+	0x52:     "mbi",
+	0x53:     "microcode",
+	0x60:     "fsp",
+	0x61:     "mrc",
+	0x62:     "mma",
+	0x63:     "efi",
+	0x64:     "struct",
+	0xAA:     "cmos_default",
+	0x1AA:    "cmos_layout",
+	TypeNull: "null",
+}
+
+// TypeName returns the name of the CBFS file type t.
+func TypeName(t uint32) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", t)
+}
+
+// FindHeader returns the CBFS master header of the image and its offset. The
+// last dword of an x86 coreboot image points to the master header, either as
+// an address mapped below 4GiB or as a negative offset from the end of the
+// image, which both translate to the same offset.
+func FindHeader(image []byte) (*Header, uint64, error) {
+	if len(image) < 4 {
+		return nil, 0, ErrHeaderNotFound
+	}
+	ptr := int32(binary.LittleEndian.Uint32(image[len(image)-4:]))
+	offset := int64(len(image)) + int64(ptr)
+	var hdr Header
+	if ptr >= 0 || offset < 0 || offset+int64(binary.Size(hdr)) > int64(len(image)) {
+		return nil, 0, ErrHeaderNotFound
+	}
+	if binary.BigEndian.Uint32(image[offset:]) != HeaderMagic {
+		return nil, 0, ErrHeaderNotFound
+	}
+	if err := binary.Read(bytes.NewReader(image[offset:]), binary.BigEndian, &hdr); err != nil {
+		return nil, 0, fmt.Errorf("unable to read the CBFS master header at 0x%x: %w", offset, err)
+	}
+	return &hdr, uint64(offset), nil
+}
+
+// IsCorebootImage returns true if the image contains a CBFS master header.
+func IsCorebootImage(image []byte) bool {
+	_, _, err := FindHeader(image)
+	return err == nil
+}
+
+// Files returns the files of the CBFS of the image, including the empty space
+// between files of type TypeNull. The CBFS ends at the bootblock at the top
+// of the image.
+func Files(image []byte) ([]File, error) {
+	hdr, _, err := FindHeader(image)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Align == 0 {
+		return nil, fmt.Errorf("invalid CBFS master header: alignment is zero")
+	}
+	if uint64(hdr.ROMSize) > uint64(len(image)) || hdr.BootBlockSize > hdr.ROMSize || hdr.Offset > hdr.ROMSize-hdr.BootBlockSize {
+		return nil, fmt.Errorf("invalid CBFS master header: ROM size 0x%x, bootblock size 0x%x and offset 0x%x don't fit into the image of %d bytes",
+			hdr.ROMSize, hdr.BootBlockSize, hdr.Offset, len(image))
+	}
+	// the ROM is mapped to the end of the image
+	base := uint64(len(image)) - uint64(hdr.ROMSize)
+	end := base + uint64(hdr.ROMSize-hdr.BootBlockSize)
+	align := uint64(hdr.Align)
+
+	var files []File
+	for pos := base + uint64(hdr.Offset); pos < end; {
+		if !bytes.HasPrefix(image[pos:end], FileMagic) {
+			pos += align
+			continue
+		}
+		f, next, err := parseFile(image[:end], pos)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+		// the next file starts at the next alignment boundary
+		pos = base + (next-base+align-1)/align*align
+	}
+	return files, nil
+}
+
+// parseFile parses the CBFS file at offset pos of image and returns the file
+// and the offset following its data.
+func parseFile(image []byte, pos uint64) (*File, uint64, error) {
+	var fh fileHeader
+	size := uint64(binary.Size(fh))
+	if pos+size > uint64(len(image)) {
+		return nil, 0, fmt.Errorf("CBFS file header at 0x%x is truncated", pos)
+	}
+	if err := binary.Read(bytes.NewReader(image[pos:]), binary.BigEndian, &fh); err != nil {
+		return nil, 0, fmt.Errorf("unable to read the CBFS file header at 0x%x: %w", pos, err)
+	}
+	dataStart := pos + uint64(fh.Offset)
+	dataEnd := dataStart + uint64(fh.Len)
+	if uint64(fh.Offset) < size || dataEnd > uint64(len(image)) {
+		return nil, 0, fmt.Errorf("CBFS file at 0x%x: data at offset 0x%x of 0x%x bytes is outside of the CBFS", pos, fh.Offset, fh.Len)
+	}
+
+	// the name ends at the attributes or, without attributes, at the data
+	nameEnd := dataStart
+	if fh.AttributesOffset != 0 {
+		if uint64(fh.AttributesOffset) < size || fh.AttributesOffset > fh.Offset {
+			return nil, 0, fmt.Errorf("CBFS file at 0x%x: attributes offset 0x%x is outside of the header", pos, fh.AttributesOffset)
+		}
+		nameEnd = pos + uint64(fh.AttributesOffset)
+	}
+	name := image[pos+size : nameEnd]
+	if idx := bytes.IndexByte(name, 0); idx >= 0 {
+		name = name[:idx]
+	}
+
+	f := &File{
+		Name:   string(name),
+		Type:   fh.Type,
+		Offset: dataStart,
+		Data:   image[dataStart:dataEnd],
+	}
+	if fh.AttributesOffset != 0 {
+		f.Compression = compression(image[nameEnd:dataStart])
+	}
+	return f, dataEnd, nil
+}
+
+// compression returns the compression algorithm of the file attributes attrs.
+func compression(attrs []byte) uint32 {
+	for len(attrs) >= 8 {
+		tag := binary.BigEndian.Uint32(attrs)
+		size := binary.BigEndian.Uint32(attrs[4:])
+		if size < 8 || uint64(size) > uint64(len(attrs)) {
+			break
+		}
+		if tag == AttrTagCompression && size >= 12 {
+			return binary.BigEndian.Uint32(attrs[8:])
+		}
+		attrs = attrs[size:]
+	}
+	return CompressionNone
+}
+
+// Lookup returns the CBFS file of the given name.
+func Lookup(image []byte, name string) (*File, error) {
+	files, err := Files(image)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range files {
+		if files[idx].Type != TypeNull && files[idx].Name == name {
+			return &files[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+}
+
+// PrettyPrint prints a human readable representation of the master header.
+func (hdr *Header) PrettyPrint(offset uint64) {
+	fmt.Println("----coreboot CBFS Master Header----")
+	fmt.Println()
+	fmt.Printf("   Offset: 0x%08x\n", offset)
+	fmt.Printf("   Version: 0x%08x\n", hdr.Version)
+	fmt.Printf("   ROM Size: 0x%x\n", hdr.ROMSize)
+	fmt.Printf("   Bootblock Size: 0x%x\n", hdr.BootBlockSize)
+	fmt.Printf("   Alignment: 0x%x\n", hdr.Align)
+	fmt.Printf("   CBFS Offset: 0x%x\n", hdr.Offset)
+	fmt.Println()
+}
+
+// PrintStructures prints the CBFS master header and the files of the image.
+func PrintStructures(image []byte) error {
+	hdr, offset, err := FindHeader(image)
+	if err != nil {
+		return err
+	}
+	hdr.PrettyPrint(offset)
+	files, err := Files(image)
+	if err != nil {
+		return err
+	}
+	fmt.Println("----coreboot CBFS Files----")
+	fmt.Println()
+	for idx, f := range files {
+		if f.Type == TypeNull {
+			continue
+		}
+		fmt.Printf("   File %d: %s, type %s, offset 0x%x, size 0x%x", idx, f.Name, TypeName(f.Type), f.Offset, len(f.Data))
+		if f.Compression != CompressionNone {
+			fmt.Printf(", compression %d", f.Compression)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	return nil
+}
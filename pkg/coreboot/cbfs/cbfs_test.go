@@ -0,0 +1,165 @@
+package cbfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+const (
+	testImageSize     = 0x10000
+	testBootBlockSize = 0x1000
+	testAlign         = 0x40
+)
+
+// putFile writes a CBFS file with the given name, type, attributes and data at
+// offset and returns the offset of the next file.
+func putFile(image []byte, offset int, name string, typ uint32, attrs []byte, data []byte) int {
+	nameLen := (len(name) + 1 + 15) &^ 15
+	hdrLen := binary.Size(fileHeader{}) + nameLen
+	fh := fileHeader{
+		Len:    uint32(len(data)),
+		Type:   typ,
+		Offset: uint32(hdrLen + len(attrs)),
+	}
+	if len(attrs) > 0 {
+		fh.AttributesOffset = uint32(hdrLen)
+	}
+	copy(fh.Magic[:], FileMagic)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, fh)
+	buf.WriteString(name)
+	buf.Write(make([]byte, nameLen-len(name)))
+	buf.Write(attrs)
+	buf.Write(data)
+	copy(image[offset:], buf.Bytes())
+	return (offset + buf.Len() + testAlign - 1) &^ (testAlign - 1)
+}
+
+// testImage returns a 64KiB coreboot image with a master header in the
+// bootblock and the files "config", "fallback/romstage" compressed and
+// "key_manifest.bin" separated by empty space.
+func testImage() []byte {
+	image := make([]byte, testImageSize)
+	for i := range image {
+		image[i] = 0xff
+	}
+	hdr := Header{
+		Magic:         HeaderMagic,
+		Version:       0x31313132,
+		ROMSize:       testImageSize,
+		BootBlockSize: testBootBlockSize,
+		Align:         testAlign,
+		Offset:        0x200,
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, hdr)
+	hdrOffset := testImageSize - testBootBlockSize + 0x100
+	copy(image[hdrOffset:], buf.Bytes())
+	// the pointer is the address of the header mapped below 4GiB
+	binary.LittleEndian.PutUint32(image[testImageSize-4:], uint32(0x100000000-testImageSize+hdrOffset))
+
+	lzma := make([]byte, 16)
+	binary.BigEndian.PutUint32(lzma, AttrTagCompression)
+	binary.BigEndian.PutUint32(lzma[4:], 16)
+	binary.BigEndian.PutUint32(lzma[8:], 1)
+	binary.BigEndian.PutUint32(lzma[12:], 0x100)
+
+	next := putFile(image, 0x200, "config", 0x50, nil, []byte("CONFIG_VENDOR_EMULATION=y\n"))
+	next = putFile(image, next, "fallback/romstage", 0x10, lzma, bytes.Repeat([]byte{0xaa}, 0x30))
+	next = putFile(image, next, "", TypeNull, nil, make([]byte, 0x100))
+	putFile(image, next, "key_manifest.bin", 0x50, nil, bytes.Repeat([]byte{0x55}, 0x20))
+	return image
+}
+
+func TestFindHeader(t *testing.T) {
+	image := testImage()
+	hdr, offset, err := FindHeader(image)
+	if err != nil {
+		t.Fatalf("FindHeader() failed: %v", err)
+	}
+	if offset != testImageSize-testBootBlockSize+0x100 || hdr.Align != testAlign || hdr.Offset != 0x200 {
+		t.Errorf("FindHeader() returned %+v at 0x%x", hdr, offset)
+	}
+	if !IsCorebootImage(image) {
+		t.Errorf("IsCorebootImage() returned false for a coreboot image")
+	}
+
+	// a negative offset from the end of the image points to the same header
+	rel := int32(-(testBootBlockSize - 0x100))
+	binary.LittleEndian.PutUint32(image[testImageSize-4:], uint32(rel))
+	if _, relOffset, err := FindHeader(image); err != nil || relOffset != offset {
+		t.Errorf("FindHeader() with a relative pointer returned 0x%x, %v, expected 0x%x", relOffset, err, offset)
+	}
+
+	for name, image := range map[string][]byte{
+		"empty":     make([]byte, testImageSize),
+		"erased":    bytes.Repeat([]byte{0xff}, testImageSize),
+		"truncated": {0xfc, 0xff},
+	} {
+		if IsCorebootImage(image) {
+			t.Errorf("IsCorebootImage() returned true for the %s image", name)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	files, err := Files(testImage())
+	if err != nil {
+		t.Fatalf("Files() failed: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	expected := []string{"config", "fallback/romstage", "", "key_manifest.bin"}
+	if len(names) != len(expected) {
+		t.Fatalf("Files() returned the files %q, expected %q", names, expected)
+	}
+	for idx := range expected {
+		if names[idx] != expected[idx] {
+			t.Errorf("Files() returned the files %q, expected %q", names, expected)
+			break
+		}
+	}
+	if files[1].Compression != 1 || files[0].Compression != CompressionNone {
+		t.Errorf("Files() returned the compression %d and %d, expected 1 and none", files[1].Compression, files[0].Compression)
+	}
+	if files[2].Type != TypeNull {
+		t.Errorf("Files() returned the type %s for the empty space", TypeName(files[2].Type))
+	}
+}
+
+func TestLookup(t *testing.T) {
+	image := testImage()
+	f, err := Lookup(image, "key_manifest.bin")
+	if err != nil {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	if !bytes.Equal(f.Data, bytes.Repeat([]byte{0x55}, 0x20)) || !bytes.Equal(image[f.Offset:f.Offset+0x20], f.Data) {
+		t.Errorf("Lookup() returned the data %x at 0x%x", f.Data, f.Offset)
+	}
+	if _, err := Lookup(image, "boot_policy_manifest.bin"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Lookup() of a missing file returned %v, expected ErrFileNotFound", err)
+	}
+	if _, err := Lookup(make([]byte, testImageSize), "key_manifest.bin"); !errors.Is(err, ErrHeaderNotFound) {
+		t.Errorf("Lookup() in a non coreboot image returned %v, expected ErrHeaderNotFound", err)
+	}
+}
+
+func TestFilesInvalid(t *testing.T) {
+	image := testImage()
+	// a file claiming more data than the CBFS holds
+	binary.BigEndian.PutUint32(image[0x200+8:], testImageSize)
+	if _, err := Files(image); err == nil {
+		t.Errorf("Files() accepted a file with data outside of the CBFS")
+	}
+
+	image = testImage()
+	_, offset, _ := FindHeader(image)
+	binary.BigEndian.PutUint32(image[offset+16:], 0)
+	if _, err := Files(image); err == nil {
+		t.Errorf("Files() accepted a master header with zero alignment")
+	}
+}
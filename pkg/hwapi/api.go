@@ -30,6 +30,7 @@ type APIInterfaces interface {
 	AllowsVMXInSMX() (bool, error)
 	TXTLeavesAreEnabled() (bool, error)
 	IA32DebugInterfaceEnabledOrLocked() (*IA32Debug, error)
+	BootGuardSACMInfo() (*BootGuardSACMInfo, error)
 
 	// pci.go
 	PCIReadConfigSpace(bus int, device int, devFn int, off int, buf interface{}) error
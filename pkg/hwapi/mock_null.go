@@ -79,6 +79,10 @@ func (n nullmock) IA32DebugInterfaceEnabledOrLocked() (*IA32Debug, error) {
 	return nil, fmt.Errorf("Not implemented")
 }
 
+func (n nullmock) BootGuardSACMInfo() (*BootGuardSACMInfo, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
 func (n nullmock) PCIReadConfigSpace(bus int, device int, devFn int, off int, buf interface{}) error {
 	return fmt.Errorf("Not implemented")
 }
@@ -83,6 +83,10 @@ func (n pcmock) IA32DebugInterfaceEnabledOrLocked() (*IA32Debug, error) {
 	return nil, fmt.Errorf("Not implemented")
 }
 
+func (n pcmock) BootGuardSACMInfo() (*BootGuardSACMInfo, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
 func (n pcmock) PCIReadConfigSpace(bus int, device int, devFn int, off int, buf interface{}) error {
 	return fmt.Errorf("Not implemented")
 }
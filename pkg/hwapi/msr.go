@@ -16,6 +16,7 @@ const (
 	msrFeatureControl     int64 = 0x3A
 	msrPlatformID         int64 = 0x17
 	msrIA32DebugInterface int64 = 0xC80
+	msrBootGuardSACMInfo  int64 = 0x13A
 )
 
 // IA32Debug feature msr
@@ -25,6 +26,19 @@ type IA32Debug struct {
 	PCHStrap bool
 }
 
+// BootGuardSACMInfo holds the fields of MSR_BOOT_GUARD_SACM_INFO, which the
+// startup ACM sets according to the BootGuard fuses and the policy it enforced
+type BootGuardSACMInfo struct {
+	NEMEnabled bool
+	TPMType    uint8
+	TPMSuccess bool
+	Measured   bool
+	Verified   bool
+	Revoked    bool
+	// Capability is set if the CPU supports BootGuard
+	Capability bool
+}
+
 func readMSR(msr int64) (uint64, error) {
 	var data uint64
 	for i := 0; i < runtime.NumCPU(); i++ {
@@ -139,3 +153,21 @@ func (t TxtAPI) IA32DebugInterfaceEnabledOrLocked() (*IA32Debug, error) {
 	debugMSR.PCHStrap = (debugInterfaceCtrl>>31)&1 != 0
 	return &debugMSR, nil
 }
+
+//BootGuardSACMInfo returns the MSR_BOOT_GUARD_SACM_INFO msr
+func (t TxtAPI) BootGuardSACMInfo() (*BootGuardSACMInfo, error) {
+	sacmInfo, err := readMSR(msrBootGuardSACMInfo)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot access MSR BOOT_GUARD_SACM_INFO: %s", err)
+	}
+
+	return &BootGuardSACMInfo{
+		NEMEnabled: sacmInfo&(1<<0) != 0,
+		TPMType:    uint8((sacmInfo >> 1) & 0x3),
+		TPMSuccess: sacmInfo&(1<<3) != 0,
+		Measured:   sacmInfo&(1<<5) != 0,
+		Verified:   sacmInfo&(1<<6) != 0,
+		Revoked:    sacmInfo&(1<<7) != 0,
+		Capability: sacmInfo&(1<<32) != 0,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package hwapi
+
+import (
+	"errors"
+	"time"
+
+	tpm2 "github.com/google/go-tpm/tpm2"
+)
+
+// TPMRetryPolicy configures how often a TPM command failing with a transient
+// response code is retried. The delay before the first retry is BaseDelay
+// and doubles with every further retry.
+type TPMRetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+}
+
+// DefaultTPMRetryPolicy is the retry policy of the TPMs opened by NewTPM.
+var DefaultTPMRetryPolicy = TPMRetryPolicy{MaxRetries: 5, BaseDelay: 10 * time.Millisecond}
+
+// tpmRetryWarnings are the TPM 2.0 warnings telling the caller to resend the
+// command later, because the TPM is busy, testing itself or rate limits
+// NV accesses.
+var tpmRetryWarnings = map[tpm2.RCWarn]bool{
+	tpm2.RCYielded:       true,
+	tpm2.RCCanceled:      true,
+	tpm2.RCTesting:       true,
+	tpm2.RCNVRate:        true,
+	tpm2.RCRetry:         true,
+	tpm2.RCNVUnavailable: true,
+}
+
+// tpmRetrySleep is replaced by the tests to not wait for the delays.
+var tpmRetrySleep = time.Sleep
+
+// IsTPMRetryable returns true if err is a TPM response code asking to retry
+// the command later. Real errors, e.g. an authorization failure or lockout,
+// are not retryable.
+func IsTPMRetryable(err error) bool {
+	var warning tpm2.Warning
+	if errors.As(err, &warning) {
+		return tpmRetryWarnings[warning.Code]
+	}
+	return false
+}
+
+// Do runs op until it succeeds, fails with an error which is not retryable
+// or the retries are exhausted, and returns the last error.
+func (p TPMRetryPolicy) Do(op func() error) error {
+	delay := p.BaseDelay
+	for retry := 0; ; retry++ {
+		err := op()
+		if err == nil || retry >= p.MaxRetries || !IsTPMRetryable(err) {
+			return err
+		}
+		tpmRetrySleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,85 @@
+package hwapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	tpm2 "github.com/google/go-tpm/tpm2"
+)
+
+// retryStub fails with err for the first failures calls.
+type retryStub struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *retryStub) op() error {
+	s.calls++
+	if s.calls <= s.failures {
+		return s.err
+	}
+	return nil
+}
+
+// withoutRetrySleep records the delays instead of waiting until restore is called.
+func withoutRetrySleep() (delays *[]time.Duration, restore func()) {
+	delays = &[]time.Duration{}
+	tpmRetrySleep = func(d time.Duration) { *delays = append(*delays, d) }
+	return delays, func() { tpmRetrySleep = time.Sleep }
+}
+
+func TestTPMRetryPolicy(t *testing.T) {
+	delays, restore := withoutRetrySleep()
+	defer restore()
+	policy := TPMRetryPolicy{MaxRetries: 5, BaseDelay: time.Millisecond}
+	stub := &retryStub{failures: 3, err: tpm2.Warning{Code: tpm2.RCRetry}}
+	if err := policy.Do(stub.op); err != nil {
+		t.Fatalf("Do() failed after %d retry responses: %v", stub.failures, err)
+	}
+	if stub.calls != 4 {
+		t.Errorf("Do() ran the command %d times, expected 4", stub.calls)
+	}
+	expected := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}
+	if !reflect.DeepEqual(*delays, expected) {
+		t.Errorf("Do() waited %v, expected %v", *delays, expected)
+	}
+}
+
+func TestTPMRetryPolicyExhausted(t *testing.T) {
+	_, restore := withoutRetrySleep()
+	defer restore()
+	policy := TPMRetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+	stub := &retryStub{failures: 10, err: fmt.Errorf("NV read: %w", tpm2.Warning{Code: tpm2.RCNVRate})}
+	err := policy.Do(stub.op)
+	if !IsTPMRetryable(err) {
+		t.Errorf("Do() returned %v, expected the last rate limit response", err)
+	}
+	if stub.calls != 3 {
+		t.Errorf("Do() ran the command %d times, expected 3", stub.calls)
+	}
+}
+
+func TestTPMRetryPolicyRealErrors(t *testing.T) {
+	delays, restore := withoutRetrySleep()
+	defer restore()
+	policy := TPMRetryPolicy{MaxRetries: 5, BaseDelay: time.Millisecond}
+	for name, err := range map[string]error{
+		"lockout": tpm2.Warning{Code: tpm2.RCLockout},
+		"other":   errors.New("authorization failure"),
+	} {
+		stub := &retryStub{failures: 1, err: err}
+		if got := policy.Do(stub.op); got != err {
+			t.Errorf("Do() of the %s error returned %v", name, got)
+		}
+		if stub.calls != 1 {
+			t.Errorf("Do() retried the %s error %d times", name, stub.calls-1)
+		}
+	}
+	if len(*delays) != 0 {
+		t.Errorf("Do() waited %v for errors which are not retryable", *delays)
+	}
+}
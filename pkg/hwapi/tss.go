@@ -67,6 +67,10 @@ type TPM struct {
 
 	SysPath string
 	RWC     io.ReadWriteCloser
+
+	// Retry is the policy of retrying NV accesses the TPM answers with a
+	// transient response code, e.g. when it is busy.
+	Retry TPMRetryPolicy
 }
 
 // probedTPM identifies a TPM device on the system, which
@@ -190,6 +194,7 @@ func newTPM(pTPM probedTPM) (*TPM, error) {
 		Interf:  interf,
 		SysPath: pTPM.Path,
 		RWC:     rwc,
+		Retry:   DefaultTPMRetryPolicy,
 	}, nil
 }
 
@@ -433,13 +438,20 @@ func (t *TPM) Close() error {
 // Type and byte oder for TPM2.0 interface:
 // (authhandle uint32)
 func (t *TPM) NVReadValue(index uint32, ownerPassword string, size, offhandle uint32) ([]byte, error) {
-	switch t.Version {
-	case TPMVersion12:
-		return nvRead12(t.RWC, index, offhandle, size, ownerPassword)
-	case TPMVersion20:
-		return nvRead20(t.RWC, tpmutil.Handle(index), tpmutil.Handle(offhandle), ownerPassword, int(size))
-	}
-	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+	var data []byte
+	err := t.Retry.Do(func() error {
+		var err error
+		switch t.Version {
+		case TPMVersion12:
+			data, err = nvRead12(t.RWC, index, offhandle, size, ownerPassword)
+		case TPMVersion20:
+			data, err = nvRead20(t.RWC, tpmutil.Handle(index), tpmutil.Handle(offhandle), ownerPassword, int(size))
+		default:
+			err = fmt.Errorf("unsupported TPM version: %x", t.Version)
+		}
+		return err
+	})
+	return data, err
 }
 
 // GetCapability requests the TPMs capability function and returns an interface.
@@ -475,7 +487,10 @@ func (t *TPM) ReadNVPublic(index uint32) ([]byte, error) {
 		}
 		return raw, nil
 	case TPMVersion20:
-		raw, err = readNVPublic20(t.RWC, index)
+		err = t.Retry.Do(func() error {
+			raw, err = readNVPublic20(t.RWC, index)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -5,8 +5,8 @@
 package bootpolicy
 
 import (
-	"encoding/binary"
 	"fmt"
+	binary "github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/common/pooledbinary"
 	"io"
 	"strings"
 
@@ -130,7 +130,7 @@ func (s *Manifest) ReadFrom(r io.Reader) (int64, error) {
 			// TODO: report error "unknown structure ID: '"+structID+"'"
 			continue
 		}
-		if manifest.StrictOrderCheck && fieldIndex < previousFieldIndex {
+		if manifest.IsStrictOrderCheck(r) && fieldIndex < previousFieldIndex {
 			return totalN, fmt.Errorf("invalid order of fields (%d < %d): structure '%s' is out of order", fieldIndex, previousFieldIndex, structID)
 		}
 		missingFieldsByIndices[fieldIndex] = false
@@ -423,7 +423,7 @@ func (s *Manifest) PrettyString(depth uint, withHeader bool, opts ...pretty.Opti
 	// ManifestFieldType is elementList
 	lines = append(lines, pretty.Header(depth+1, fmt.Sprintf("SE: Array of \"IBB Segments Element\" of length %d", len(s.SE)), s.SE))
 	for i := 0; i < len(s.SE); i++ {
-		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i)+strings.TrimSpace(s.SE[i].PrettyString(depth+2, true)))
+		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i)+strings.TrimSpace(s.SE[i].PrettyString(depth+2, true, opts...)))
 	}
 	if depth < 1 {
 		lines = append(lines, "")
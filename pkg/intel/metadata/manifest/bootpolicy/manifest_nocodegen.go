@@ -19,33 +19,33 @@ func (bpm *Manifest) rehashedBPMH() BPMH {
 }
 
 // Print prints the Manifest
-func (bpm Manifest) Print() {
-	fmt.Printf("%v", bpm.BPMH.PrettyString(1, true))
+func (bpm Manifest) Print(opts ...pretty.Option) {
+	fmt.Printf("%v", bpm.BPMH.PrettyString(1, true, opts...))
 	for _, item := range bpm.SE {
-		fmt.Printf("%v", item.PrettyString(1, true))
+		fmt.Printf("%v", item.PrettyString(1, true, opts...))
 	}
 	if bpm.TXTE != nil {
-		fmt.Printf("%v\n", bpm.TXTE.PrettyString(1, true))
+		fmt.Printf("%v\n", bpm.TXTE.PrettyString(1, true, opts...))
 	} else {
 		fmt.Printf("  --TXTE--\n\t not set!(optional)\n")
 	}
 
 	if bpm.PCDE != nil {
-		fmt.Printf("%v\n", bpm.PCDE.PrettyString(1, true))
+		fmt.Printf("%v\n", bpm.PCDE.PrettyString(1, true, opts...))
 	} else {
 		fmt.Println("  --PCDE-- \n\tnot set!(optional)")
 	}
 
 	if bpm.PME != nil {
-		fmt.Printf("%v\n", bpm.PME.PrettyString(1, true))
+		fmt.Printf("%v\n", bpm.PME.PrettyString(1, true, opts...))
 	} else {
 		fmt.Println("  --PME--\n\tnot set!(optional)")
 	}
 
 	if bpm.PMSE.Signature.DataTotalSize() < 1 {
-		fmt.Printf("%v\n", bpm.PMSE.PrettyString(1, true, pretty.OptionOmitKeySignature(true)))
+		fmt.Printf("%v\n", bpm.PMSE.PrettyString(1, true, append(opts, pretty.OptionOmitKeySignature(true))...))
 		fmt.Printf("  --PMSE--\n\tBoot Policy Manifest not signed!\n\n")
 	} else {
-		fmt.Printf("%v\n", bpm.PMSE.PrettyString(1, true, pretty.OptionOmitKeySignature(false)))
+		fmt.Printf("%v\n", bpm.PMSE.PrettyString(1, true, append(opts, pretty.OptionOmitKeySignature(false))...))
 	}
 }
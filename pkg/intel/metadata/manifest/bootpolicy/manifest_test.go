@@ -1,6 +1,8 @@
 package bootpolicy
 
 import (
+	"bytes"
+	"io/ioutil"
 	"testing"
 
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/common/unittest"
@@ -9,3 +11,22 @@ import (
 func TestReadWrite(t *testing.T) {
 	unittest.ManifestReadWrite(t, &Manifest{}, "testdata/bpm.bin")
 }
+
+func TestReadWritePM(t *testing.T) {
+	unittest.ManifestReadWrite(t, &Manifest{}, "testdata/bpm_pm.bin")
+}
+
+func BenchmarkReadFrom(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/bpm.bin")
+	if err != nil {
+		b.Fatalf("Failed to read file: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var bpm Manifest
+		if _, err := bpm.ReadFrom(bytes.NewReader(data)); err != nil {
+			b.Fatalf("ReadFrom() failed: %v", err)
+		}
+	}
+}
@@ -116,6 +116,19 @@ type IBBSegment struct {
 	Size     uint32
 }
 
+// IBBSegmentFlagNotMeasured marks a segment which is not hashed into the IBB digest.
+const IBBSegmentFlagNotMeasured = uint16(1 << 0)
+
+// IsMeasured returns true if the segment is hashed into the IBB digest.
+func (s IBBSegment) IsMeasured() bool {
+	return s.Flags&IBBSegmentFlagNotMeasured == 0
+}
+
+// Contains returns true if the address is inside the segment.
+func (s IBBSegment) Contains(addr uint32) bool {
+	return uint64(addr) >= uint64(s.Base) && uint64(addr) < uint64(s.Base)+uint64(s.Size)
+}
+
 type CachingType uint8
 
 const (
@@ -5,8 +5,8 @@
 package bootpolicy
 
 import (
-	"encoding/binary"
 	"fmt"
+	binary "github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/common/pooledbinary"
 	"io"
 	"strings"
 
@@ -948,7 +948,7 @@ func (s *SE) PrettyString(depth uint, withHeader bool, opts ...pretty.Option) st
 	// ManifestFieldType is list
 	lines = append(lines, pretty.Header(depth+1, fmt.Sprintf("IBBSegments: Array of \"IBB Segment\" of length %d", len(s.IBBSegments)), s.IBBSegments))
 	for i := 0; i < len(s.IBBSegments); i++ {
-		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i)+strings.TrimSpace(s.IBBSegments[i].PrettyString(depth+2, true)))
+		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i)+strings.TrimSpace(s.IBBSegments[i].PrettyString(depth+2, true, opts...)))
 	}
 	if depth < 1 {
 		lines = append(lines, "")
@@ -0,0 +1,160 @@
+package bootpolicy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+// specTable collects the rows of one element as the tables of document
+// #575623 present them.
+type specTable struct {
+	lines []string
+}
+
+func newSpecTable(title string, s StructInfo) *specTable {
+	t := &specTable{lines: []string{title}}
+	t.add("StructureID", "%s", s.ID)
+	t.add("StructVersion", "0x%02X", s.Version)
+	return t
+}
+
+func (t *specTable) add(name, format string, args ...interface{}) {
+	t.lines = append(t.lines, fmt.Sprintf("  %-22s "+format, append([]interface{}{name}, args...)...))
+}
+
+func (t *specTable) addDigest(name string, h manifest.HashStructure) {
+	if len(h.HashBuffer) == 0 {
+		t.add(name, "%s empty", h.HashAlg)
+		return
+	}
+	t.add(name, "%s 0x%X", h.HashAlg, h.HashBuffer)
+}
+
+func (t *specTable) addDigestList(l manifest.HashList) {
+	t.add("DigestList.Size", "0x%04X", l.Size)
+	t.add("DigestList.Count", "%d", len(l.List))
+	for idx, h := range l.List {
+		t.addDigest(fmt.Sprintf("DigestList.Digest[%d]", idx), h)
+	}
+}
+
+func (t *specTable) String() string {
+	return strings.Join(t.lines, "\n") + "\n"
+}
+
+// SpecOrderString returns the BPM with the fields of every element in the
+// order and with the names of the tables of document #575623, for reviewing
+// the BPM against the specification. Print orders the fields by struct
+// layout instead.
+func (bpm *Manifest) SpecOrderString() string {
+	var b strings.Builder
+
+	t := newSpecTable("BPMH: Boot Policy Manifest Header", bpm.BPMH.StructInfo)
+	t.add("HdrStructVersion", "0x%02X", bpm.BPMH.Variable0)
+	t.add("HdrSize", "0x%04X", bpm.BPMH.ElementSize)
+	t.add("KeySignatureOffset", "0x%04X", bpm.KeySignatureOffset)
+	t.add("BpmRevision", "0x%02X", bpm.BPMRevision)
+	t.add("BpmRevocation", "%d", bpm.BPMSVN)
+	t.add("AcmRevocation", "%d", bpm.ACMSVNAuth)
+	t.add("Reserved", "0x%X", bpm.BPMH.Reserved0)
+	t.add("NemPages", "%d", bpm.NEMDataStack)
+	b.WriteString(t.String())
+
+	for idx := range bpm.SE {
+		se := &bpm.SE[idx]
+		t := newSpecTable(fmt.Sprintf("IBBS: IBB Element #%d", idx), se.StructInfo)
+		t.add("Reserved", "0x%02X", se.Variable0)
+		t.add("ElementSize", "0x%04X", se.ElementSize)
+		t.add("Reserved", "0x%X", se.Reserved0)
+		t.add("SetNumber", "%d", se.SetNumber)
+		t.add("Reserved", "0x%X", se.Reserved1)
+		t.add("PbetValue", "0x%02X", uint8(se.PBETValue))
+		t.add("Flags", "0x%08X", uint32(se.Flags))
+		t.add("IbbMchBar", "0x%016X", se.IBBMCHBAR)
+		t.add("VtdBar", "0x%016X", se.VTdBAR)
+		t.add("DmaProtBase0", "0x%08X", se.DMAProtBase0)
+		t.add("DmaProtLimit0", "0x%08X", se.DMAProtLimit0)
+		t.add("DmaProtBase1", "0x%016X", se.DMAProtBase1)
+		t.add("DmaProtLimit1", "0x%016X", se.DMAProtLimit1)
+		t.addDigest("PostIbbHash", se.PostIBBHash)
+		t.add("IbbEntryPoint", "0x%08X", se.IBBEntryPoint)
+		t.addDigestList(se.DigestList)
+		t.addDigest("ObbHash", se.OBBHash)
+		t.add("Reserved", "0x%X", se.Reserved2)
+		t.add("SegmentCount", "%d", len(se.IBBSegments))
+		for segIdx, seg := range se.IBBSegments {
+			t.add(fmt.Sprintf("IbbSegment[%d]", segIdx), "Reserved 0x%X Flags 0x%04X Base 0x%08X Size 0x%08X", seg.Reserved, seg.Flags, seg.Base, seg.Size)
+		}
+		b.WriteString(t.String())
+	}
+
+	if bpm.TXTE != nil {
+		txt := bpm.TXTE
+		t := newSpecTable("TXTE: TXT Element", txt.StructInfo)
+		t.add("Reserved", "0x%02X", txt.Variable0)
+		t.add("ElementSize", "0x%04X", txt.ElementSize)
+		t.add("Reserved", "0x%X", txt.Reserved0)
+		t.add("SetNumber", "0x%X", txt.SetNumber)
+		t.add("SinitMinSvn", "%d", txt.SInitMinSVNAuth)
+		t.add("Reserved", "0x%X", txt.Reserved1)
+		t.add("ControlFlags", "0x%08X", uint32(txt.ControlFlags))
+		t.add("PwrDownInterval", "0x%04X", uint16(txt.PwrDownInterval))
+		t.add("PttCmosOffset0", "0x%02X", txt.PTTCMOSOffset0)
+		t.add("PttCmosOffset1", "0x%02X", txt.PTTCMOSOffset1)
+		t.add("AcpiBaseOffset", "0x%04X", txt.ACPIBaseOffset)
+		t.add("Reserved", "0x%X", txt.Reserved2)
+		t.add("PwrmBaseOffset", "0x%08X", txt.PwrMBaseOffset)
+		t.addDigestList(txt.DigestList)
+		t.add("Reserved", "0x%X", txt.Reserved3)
+		t.add("SegmentCount", "%d", txt.SegmentCount)
+		b.WriteString(t.String())
+	}
+
+	if bpm.Res != nil {
+		t := newSpecTable("PFRS: Reserved Element", bpm.Res.StructInfo)
+		t.add("Reserved", "0x%02X", bpm.Res.Variable0)
+		t.add("ElementSize", "0x%04X", bpm.Res.ElementSize)
+		t.add("ReservedData", "0x%X", bpm.Res.ReservedData)
+		b.WriteString(t.String())
+	}
+
+	if bpm.PCDE != nil {
+		t := newSpecTable("PCDE: Platform Config Data Element", bpm.PCDE.StructInfo)
+		t.add("Reserved", "0x%02X", bpm.PCDE.Variable0)
+		t.add("ElementSize", "0x%04X", bpm.PCDE.ElementSize)
+		t.add("Reserved", "0x%X", bpm.PCDE.Reserved0)
+		t.add("SizeOfData", "0x%04X", len(bpm.PCDE.Data))
+		t.add("Data", "0x%X", bpm.PCDE.Data)
+		b.WriteString(t.String())
+	}
+
+	if bpm.PME != nil {
+		t := newSpecTable("PMDA: Platform Manufacturer Element", bpm.PME.StructInfo)
+		t.add("Reserved", "0x%02X", bpm.PME.Variable0)
+		t.add("ElementSize", "0x%04X", bpm.PME.ElementSize)
+		t.add("Reserved", "0x%X", bpm.PME.Reserved0)
+		t.add("PmDataSize", "0x%04X", len(bpm.PME.Data))
+		t.add("PmData", "0x%X", bpm.PME.Data)
+		b.WriteString(t.String())
+	}
+
+	ks := &bpm.PMSE.KeySignature
+	t = newSpecTable("PMSE: Boot Policy Manifest Signature Element", bpm.PMSE.StructInfo)
+	t.add("Reserved", "0x%02X", bpm.PMSE.Variable0)
+	t.add("Reserved", "0x%04X", bpm.PMSE.ElementSize)
+	t.add("KeySignature.Version", "0x%02X", ks.Version)
+	t.add("Key.KeyAlg", "%s", ks.Key.KeyAlg)
+	t.add("Key.Version", "0x%02X", ks.Key.Version)
+	t.add("Key.KeySizeBits", "%d", ks.Key.KeySize.InBits())
+	t.add("Key.Data", "0x%X", ks.Key.Data)
+	t.add("SigScheme", "%s", ks.Signature.SigScheme)
+	t.add("Signature.Version", "0x%02X", ks.Signature.Version)
+	t.add("Signature.KeySizeBits", "%d", ks.Signature.KeySize.InBits())
+	t.add("Signature.HashAlg", "%s", ks.Signature.HashAlg)
+	t.add("Signature.Data", "0x%X", ks.Signature.Data)
+	b.WriteString(t.String())
+
+	return b.String()
+}
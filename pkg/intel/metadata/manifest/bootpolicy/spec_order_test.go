@@ -0,0 +1,25 @@
+package bootpolicy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSpecOrderStringGolden(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/bpm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm := &Manifest{}
+	if _, err := bpm.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatalf("ReadFrom() failed: %v", err)
+	}
+	golden, err := ioutil.ReadFile("testdata/bpm_spec_order.txt")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if s := bpm.SpecOrderString(); s != string(golden) {
+		t.Errorf("SpecOrderString() doesn't match testdata/bpm_spec_order.txt:\n%s", s)
+	}
+}
@@ -7,7 +7,7 @@ package {{ .Package.Name }}
 
 import (
 {{- if not .EnableTracing }}
-	"encoding/binary"
+	binary "github.com/9elements/converged-security-suite/pkg/intel/metadata/manifest/common/pooledbinary"
 {{- else }}
 	binary "github.com/9elements/converged-security-suite/pkg/intel/metadata/manifest/common/tracedbinary"
 {{- end }}
@@ -192,7 +192,7 @@ func (s *{{ $struct.Name }}) ReadFrom(r io.Reader) (int64, error) {
 			// TODO: report error "unknown structure ID: '"+structID+"'"
 			continue
 		}
-		if {{ $manifestRootPath }}StrictOrderCheck && fieldIndex < previousFieldIndex {
+		if {{ $manifestRootPath }}IsStrictOrderCheck(r) && fieldIndex < previousFieldIndex {
 			return totalN, fmt.Errorf("invalid order of fields (%d < %d): structure '%s' is out of order", fieldIndex, previousFieldIndex, structID)
 		}
 		missingFieldsByIndices[fieldIndex] = false
@@ -499,7 +499,7 @@ func (s *{{ $struct.Name }}) PrettyString(depth uint, withHeader bool, opts ...p
   {{- if or (eq $fieldType "list") (eq $fieldType "elementList") }}
 	lines = append(lines, pretty.Header(depth+1, fmt.Sprintf({{ printf "%s: Array of \"%s\" of length %%d" $field.Name $field.Struct.PrettyString | printf "%q"}}, len(s.{{ $field.Name }})), s.{{ $field.Name }}))
 	for i := 0; i<len(s.{{ $field.Name }}); i++ {
-		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i) + strings.TrimSpace(s.{{ $field.Name }}[i].PrettyString(depth+2, true)))
+		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i) + strings.TrimSpace(s.{{ $field.Name }}[i].PrettyString(depth+2, true, opts...)))
 	}
 	if depth < 1 {
 		lines = append(lines, "")
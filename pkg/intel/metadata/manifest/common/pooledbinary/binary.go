@@ -0,0 +1,77 @@
+// Package pooledbinary is a drop-in replacement of encoding/binary for the
+// generated manifest code. binary.Read allocates a buffer for every field
+// read, which makes parsing many manifests, e.g. when scanning a fleet of
+// firmware images, dominated by small allocations.
+package pooledbinary
+
+import (
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sync"
+)
+
+type ByteOrder = binary.ByteOrder
+
+var (
+	LittleEndian = binary.LittleEndian
+)
+
+// bufPool holds the buffers integers are decoded from.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		return new([8]byte)
+	},
+}
+
+// Read reads structured binary data from r into data like binary.Read.
+// Byte slices and byte arrays are read in place and unsigned integers are
+// decoded from a pooled buffer, all other data is read by binary.Read.
+func Read(r io.Reader, order ByteOrder, data interface{}) error {
+	if b, ok := data.([]byte); ok {
+		_, err := io.ReadFull(r, b)
+		return err
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return binary.Read(r, order, data)
+	}
+	v = v.Elem()
+	switch v.Kind() {
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		_, err := io.ReadFull(r, v.Slice(0, v.Len()).Bytes())
+		return err
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf := bufPool.Get().(*[8]byte)
+		defer bufPool.Put(buf)
+		size := int(v.Type().Size())
+		if _, err := io.ReadFull(r, buf[:size]); err != nil {
+			return err
+		}
+		switch size {
+		case 1:
+			v.SetUint(uint64(buf[0]))
+		case 2:
+			v.SetUint(uint64(order.Uint16(buf[:])))
+		case 4:
+			v.SetUint(uint64(order.Uint32(buf[:])))
+		case 8:
+			v.SetUint(order.Uint64(buf[:]))
+		}
+		return nil
+	}
+	return binary.Read(r, order, data)
+}
+
+// Write is binary.Write.
+func Write(w io.Writer, order ByteOrder, data interface{}) error {
+	return binary.Write(w, order, data)
+}
+
+// Size is binary.Size.
+func Size(v interface{}) int {
+	return binary.Size(v)
+}
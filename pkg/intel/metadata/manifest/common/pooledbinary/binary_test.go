@@ -0,0 +1,70 @@
+package pooledbinary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type namedUint16 uint16
+
+type testStruct struct {
+	A uint16
+	B [3]byte
+}
+
+func TestReadMatchesBinaryRead(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a}
+	for _, expected := range []interface{}{
+		new(uint8),
+		new(uint16),
+		new(namedUint16),
+		new(uint32),
+		new(uint64),
+		new([8]byte),
+		make([]byte, 5),
+		new(testStruct),
+		make([]uint16, 2),
+	} {
+		actual := reflect.New(reflect.TypeOf(expected)).Elem()
+		if actual.Kind() == reflect.Slice {
+			actual.Set(reflect.MakeSlice(actual.Type(), reflect.ValueOf(expected).Len(), reflect.ValueOf(expected).Len()))
+		} else {
+			actual.Set(reflect.New(actual.Type().Elem()))
+		}
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, expected); err != nil {
+			t.Fatalf("binary.Read(%T) failed: %v", expected, err)
+		}
+		if err := Read(bytes.NewReader(data), LittleEndian, actual.Interface()); err != nil {
+			t.Fatalf("Read(%T) failed: %v", expected, err)
+		}
+		if !reflect.DeepEqual(actual.Interface(), expected) {
+			t.Errorf("Read(%T) returned %v, binary.Read() returned %v", expected, actual.Interface(), expected)
+		}
+	}
+}
+
+func TestReadShort(t *testing.T) {
+	if err := Read(bytes.NewReader(nil), LittleEndian, new(uint32)); err != io.EOF {
+		t.Errorf("Read() of an empty reader returned %v, expected %v", err, io.EOF)
+	}
+	if err := Read(bytes.NewReader([]byte{1}), LittleEndian, new([4]byte)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Read() of a short reader returned %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	data := make([]byte, 8)
+	r := bytes.NewReader(data)
+	var v uint32
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if err := Read(r, LittleEndian, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
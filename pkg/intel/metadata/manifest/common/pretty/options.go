@@ -10,8 +10,16 @@ func (opt OptionOmitKeySignature) apply(cfg *config) {
 	cfg.OmitKeySignature = bool(opt)
 }
 
+// OptionRawBytes shows the little-endian on-wire bytes next to integer values.
+type OptionRawBytes bool
+
+func (opt OptionRawBytes) apply(cfg *config) {
+	cfg.RawBytes = bool(opt)
+}
+
 type config struct {
 	OmitKeySignature bool
+	RawBytes         bool
 }
 
 func getConfig(opts []Option) config {
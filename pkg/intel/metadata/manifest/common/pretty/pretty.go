@@ -1,6 +1,7 @@
 package pretty
 
 import (
+	"encoding/binary"
 	"fmt"
 	"reflect"
 	"strings"
@@ -64,26 +65,11 @@ func getDescriptionForValue(depth uint, value interface{}, opts ...Option) strin
 	v = reflect.Indirect(v)
 	switch v.Kind() {
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		i := v.Uint()
-		var hexFmt string
-		switch v.Type().Size() {
-		case 1:
-			hexFmt = "0x%02X"
-		case 2:
-			hexFmt = "0x%04X"
-		case 4:
-			hexFmt = "0x%08X"
-		case 8:
-			hexFmt = "0x%16X"
-		}
-		switch {
-		case i < 10:
-			return fmt.Sprintf(hexFmt, i)
-		case i < 65536:
-			return fmt.Sprintf(hexFmt+" (%d)", i, i)
-		default:
-			return fmt.Sprintf(hexFmt+" (%d: %s)", i, i, humanize.IBytes(i))
+		description := formatUint(v.Uint(), int(v.Type().Size()))
+		if getConfig(opts).RawBytes {
+			description += " " + formatRawBytes(v.Uint(), int(v.Type().Size()))
 		}
+		return description
 
 	case reflect.Array:
 		return fmt.Sprintf("0x%X", v.Interface())
@@ -97,3 +83,25 @@ func getDescriptionForValue(depth uint, value interface{}, opts ...Option) strin
 
 	return fmt.Sprintf("%#+v (%T)", value, value)
 }
+
+// formatUint formats the logical value of an unsigned integer of the given
+// size in bytes as zero-padded hex, followed by its decimal value.
+func formatUint(i uint64, size int) string {
+	hexFmt := fmt.Sprintf("0x%%0%dX", size*2)
+	switch {
+	case i < 10:
+		return fmt.Sprintf(hexFmt, i)
+	case i < 65536:
+		return fmt.Sprintf(hexFmt+" (%d)", i, i)
+	default:
+		return fmt.Sprintf(hexFmt+" (%d: %s)", i, i, humanize.IBytes(i))
+	}
+}
+
+// formatRawBytes formats an unsigned integer of the given size in bytes as
+// it is stored in the manifest binary (little-endian).
+func formatRawBytes(i uint64, size int) string {
+	raw := make([]byte, 8)
+	binary.LittleEndian.PutUint64(raw, i)
+	return fmt.Sprintf("[raw: % X]", raw[:size])
+}
@@ -0,0 +1,29 @@
+package pretty
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubValueAddresses(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value interface{}
+		opts  []Option
+		want  string
+	}{
+		{"uint8", uint8(0x05), nil, "    Flags: 0x05"},
+		{"uint16", uint16(0x0102), nil, "    Flags: 0x0102 (258)"},
+		{"uint32", uint32(0xFFFC0000), nil, "    Flags: 0xFFFC0000 (4294705152: 4.0 GiB)"},
+		{"uint64", uint64(0x1000), nil, "    Flags: 0x0000000000001000 (4096)"},
+		{"uint64Small", uint64(1), nil, "    Flags: 0x0000000000000001"},
+		{"uint16Raw", uint16(0x0102), []Option{OptionRawBytes(true)}, "    Flags: 0x0102 (258) [raw: 02 01]"},
+		{"uint32Raw", uint32(0xFFFC0000), []Option{OptionRawBytes(true)}, "    Flags: 0xFFFC0000 (4294705152: 4.0 GiB) [raw: 00 00 FC FF]"},
+		{"uint64Raw", uint64(0x1000), []Option{OptionRawBytes(true)}, "    Flags: 0x0000000000001000 (4096) [raw: 00 10 00 00 00 00 00 00]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, []string{tc.want}, SubValue(2, "Flags", "", tc.value, tc.opts...))
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package manifest
 
+import "io"
+
 var (
 	// StrictOrderCheck defines if elements order checks should be performed.
 	// For example in the Boot Policy Manifest elements could be in a wrong
@@ -12,5 +14,25 @@ var (
 	//
 	// > The order of the elements and the order of the fields within each
 	// > element are architectural and must be followed.
+	//
+	// StrictOrderCheck is a process-wide default, which must not be changed
+	// while manifests are parsed. Readers implementing StrictOrderChecker
+	// select the check for a single manifest instead.
 	StrictOrderCheck = true
 )
+
+// StrictOrderChecker is implemented by readers which select the elements
+// order check for the manifest read from them, overriding StrictOrderCheck.
+// This allows concurrent parsers to use different settings.
+type StrictOrderChecker interface {
+	StrictOrderCheck() bool
+}
+
+// IsStrictOrderCheck returns true if the elements order of the manifest read
+// from r has to be checked.
+func IsStrictOrderCheck(r io.Reader) bool {
+	if c, ok := r.(StrictOrderChecker); ok {
+		return c.StrictOrderCheck()
+	}
+	return StrictOrderCheck
+}
@@ -39,14 +39,14 @@ const (
 )
 
 var hashInfo = []struct {
-	alg  Algorithm
-	hash hash.Hash
+	alg     Algorithm
+	newHash func() hash.Hash
 }{
-	{AlgSHA1, crypto.SHA1.New()},
-	{AlgSHA256, crypto.SHA256.New()},
-	{AlgSHA384, crypto.SHA384.New()},
-	{AlgSHA512, crypto.SHA512.New()},
-	{AlgSM3_256, sm3.New()},
+	{AlgSHA1, crypto.SHA1.New},
+	{AlgSHA256, crypto.SHA256.New},
+	{AlgSHA384, crypto.SHA384.New},
+	{AlgSHA512, crypto.SHA512.New},
+	{AlgSM3_256, sm3.New},
 }
 
 // IsNull returns true if a is AlgNull or zero (unset).
@@ -54,20 +54,36 @@ func (a Algorithm) IsNull() bool {
 	return a == AlgNull || a == AlgUnknown
 }
 
-// Hash returns a crypto.Hash based on the given id.
+// Hash returns a new crypto.Hash based on the given id.
 // An error is returned if the given algorithm is not a hash algorithm or is not available.
 func (a Algorithm) Hash() (hash.Hash, error) {
 	for _, info := range hashInfo {
 		if info.alg == a {
-			if info.hash == nil {
+			if info.newHash == nil {
 				return nil, fmt.Errorf("go hash algorithm #%snot available", info.alg.String())
 			}
-			return info.hash, nil
+			return info.newHash(), nil
 		}
 	}
 	return nil, fmt.Errorf("hash algorithm not supported: %s", a.String())
 }
 
+// cryptoHash returns the crypto.Hash of a, if a is a hash algorithm of the
+// standard library.
+func (a Algorithm) cryptoHash() (crypto.Hash, bool) {
+	switch a {
+	case AlgSHA1:
+		return crypto.SHA1, true
+	case AlgSHA256:
+		return crypto.SHA256, true
+	case AlgSHA384:
+		return crypto.SHA384, true
+	case AlgSHA512:
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
 func (a Algorithm) String() string {
 	var s strings.Builder
 	var err error
@@ -2,12 +2,30 @@
 
 package manifest
 
-// HashStructure describes a digest.
+import "fmt"
+
+// HashStructure describes a digest. Digests of algorithms unknown to
+// Algorithm.Hash are kept as opaque bytes of the declared length, so the
+// manifest still parses and is written back unchanged.
 type HashStructure struct {
-	HashAlg    Algorithm `default:"0x10" json:"hs_Alg"`
+	HashAlg    Algorithm `default:"0x10" prettyValue:"hashAlgPrettyValue()" json:"hs_Alg"`
 	HashBuffer []byte    `json:"hs_Buffer"`
 }
 
+// unknownHashAlg is a hash algorithm ID without a known hash function.
+type unknownHashAlg Algorithm
+
+func (a unknownHashAlg) String() string {
+	return fmt.Sprintf("unknown hash alg (0x%02x)", uint16(a))
+}
+
+func (s HashStructure) hashAlgPrettyValue() interface{} {
+	if _, err := s.HashAlg.Hash(); err != nil && !s.HashAlg.IsNull() {
+		return unknownHashAlg(s.HashAlg)
+	}
+	return &s.HashAlg
+}
+
 // HashList describes multiple digests
 type HashList struct {
 	Size uint16 `rehashValue:"TotalSize()"`
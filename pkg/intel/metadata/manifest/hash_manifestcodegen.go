@@ -5,8 +5,8 @@
 package manifest
 
 import (
-	"encoding/binary"
 	"fmt"
+	binary "github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/common/pooledbinary"
 	"io"
 	"strings"
 
@@ -176,7 +176,7 @@ func (s *HashList) PrettyString(depth uint, withHeader bool, opts ...pretty.Opti
 	// ManifestFieldType is list
 	lines = append(lines, pretty.Header(depth+1, fmt.Sprintf("List: Array of \"Hash Structure\" of length %d", len(s.List)), s.List))
 	for i := 0; i < len(s.List); i++ {
-		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i)+strings.TrimSpace(s.List[i].PrettyString(depth+2, true)))
+		lines = append(lines, fmt.Sprintf("%sitem #%d: ", strings.Repeat("  ", int(depth+2)), i)+strings.TrimSpace(s.List[i].PrettyString(depth+2, true, opts...)))
 	}
 	if depth < 1 {
 		lines = append(lines, "")
@@ -323,7 +323,7 @@ func (s *HashStructure) PrettyString(depth uint, withHeader bool, opts ...pretty
 		return strings.Join(lines, "\n")
 	}
 	// ManifestFieldType is endValue
-	lines = append(lines, pretty.SubValue(depth+1, "Hash Alg", "", &s.HashAlg, opts...)...)
+	lines = append(lines, pretty.SubValue(depth+1, "Hash Alg", "", s.hashAlgPrettyValue(), opts...)...)
 	// ManifestFieldType is arrayDynamic
 	lines = append(lines, pretty.SubValue(depth+1, "Hash Buffer", "", &s.HashBuffer, opts...)...)
 	if depth < 2 {
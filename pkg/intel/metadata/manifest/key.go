@@ -3,18 +3,22 @@
 package manifest
 
 import (
-	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/tjfoc/gmsm/sm2"
 )
 
+// ErrUnsupportedKeyType is returned for keys of a type the manifests can't
+// carry, like Ed25519 keys: the KM and BPM define RSA, ECC and SM2 keys only.
+var ErrUnsupportedKeyType = errors.New("unsupported key type for this manifest version")
+
 // Key is a public key of an asymmetric crypto keypair.
 type Key struct {
 	KeyAlg  Algorithm `json:"key_alg"`
@@ -47,9 +51,12 @@ func (ks *BitSize) SetInBytes(amountOfBytes uint16) {
 }
 
 // keyDataSize returns the expected length of Data for specified
-// KeyAlg and KeySize.
+// KeyAlg and KeySize. A key which isn't set yet, e.g. the one of an unsigned
+// manifest, has no data.
 func (k Key) keyDataSize() int64 {
 	switch k.KeyAlg {
+	case AlgUnknown:
+		return 0
 	case AlgRSA:
 		return int64(k.KeySize.InBytes()) + 4
 	case AlgECC, AlgSM2:
@@ -61,7 +68,7 @@ func (k Key) keyDataSize() int64 {
 // PubKey parses Data into crypto.PublicKey.
 func (k Key) PubKey() (crypto.PublicKey, error) {
 	expectedSize := int(k.keyDataSize())
-	if expectedSize < 0 {
+	if expectedSize < 0 || k.KeyAlg == AlgUnknown {
 		return nil, fmt.Errorf("unexpected algorithm: %s", k.KeyAlg)
 	}
 	if len(k.Data) != expectedSize {
@@ -121,13 +128,15 @@ func (k *Key) SetPubKey(key crypto.PublicKey) error {
 		}
 		k.KeySize.SetInBits(256)
 		xB, yB := x.Bytes(), y.Bytes()
-		if len(xB) != int(k.KeySize.InBytes()) || len(yB) != int(k.KeySize.InBytes()) {
-			return fmt.Errorf("the pubkey '%#+v' is invalid: len(x)<%d> != %d || len(y)<%d> == %d",
+		if len(xB) > int(k.KeySize.InBytes()) || len(yB) > int(k.KeySize.InBytes()) {
+			return fmt.Errorf("the pubkey '%#+v' is invalid: len(x)<%d> > %d || len(y)<%d> > %d",
 				key, len(xB), int(k.KeySize.InBytes()), len(yB), int(k.KeySize.InBytes()))
 		}
+		// the coordinates are zero padded, they are shorter if their
+		// leading bytes are zero
 		k.Data = make([]byte, 2*k.KeySize.InBytes())
 		copy(k.Data[:], reverseBytes(xB))
-		copy(k.Data[len(xB):], reverseBytes(yB))
+		copy(k.Data[k.KeySize.InBytes():], reverseBytes(yB))
 		return nil
 
 	case *sm2.PublicKey:
@@ -139,40 +148,78 @@ func (k *Key) SetPubKey(key crypto.PublicKey) error {
 		}
 		k.KeySize.SetInBits(256)
 		xB, yB := x.Bytes(), y.Bytes()
-		if len(xB) != int(k.KeySize.InBytes()) || len(yB) != int(k.KeySize.InBytes()) {
-			return fmt.Errorf("the pubkey '%#+v' is invalid: len(x)<%d> != %d || len(y)<%d> == %d",
+		if len(xB) > int(k.KeySize.InBytes()) || len(yB) > int(k.KeySize.InBytes()) {
+			return fmt.Errorf("the pubkey '%#+v' is invalid: len(x)<%d> > %d || len(y)<%d> > %d",
 				key, len(xB), int(k.KeySize.InBytes()), len(yB), int(k.KeySize.InBytes()))
 		}
+		// the coordinates are zero padded, they are shorter if their
+		// leading bytes are zero
 		k.Data = make([]byte, 2*k.KeySize.InBytes())
 		copy(k.Data[:], reverseBytes(xB))
-		copy(k.Data[len(xB):], reverseBytes(yB))
+		copy(k.Data[k.KeySize.InBytes():], reverseBytes(yB))
 		return nil
+
+	case ed25519.PublicKey:
+		return fmt.Errorf("%w: Ed25519, there is no EdDSA key algorithm in the manifest format", ErrUnsupportedKeyType)
 	}
 
-	return fmt.Errorf("unexpected key type: %T", key)
+	return fmt.Errorf("%w: %T", ErrUnsupportedKeyType, key)
+}
+
+// BPMPubKeyHash returns the hash of the BPM public signing key as it is
+// stored in the KM.
+func (k *Key) BPMPubKeyHash(bpmAlg Algorithm) ([]byte, error) {
+	var data []byte
+	switch k.KeyAlg {
+	case AlgRSA:
+		if len(k.Data) <= 4 {
+			return nil, fmt.Errorf("no public key set")
+		}
+		data = k.Data[4:]
+	case AlgSM2, AlgECC:
+		data = k.Data
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm: %v", k.KeyAlg)
+	}
+	hash, err := bpmAlg.Hash()
+	if err != nil {
+		return nil, err
+	}
+	hash.Write(data)
+	return hash.Sum(nil), nil
+}
+
+// KMPubKeyHash returns the hash of the KM public signing key to fuse into the Intel ME.
+func (k *Key) KMPubKeyHash(kmAlg Algorithm) ([]byte, error) {
+	if k.KeyAlg != AlgRSA {
+		return nil, fmt.Errorf("unsupported key algorithm: %v", k.KeyAlg)
+	}
+	if len(k.Data) <= 4 {
+		return nil, fmt.Errorf("no public key set")
+	}
+	if kmAlg != AlgSHA256 {
+		return nil, fmt.Errorf("KM public key hash algorithm must be SHA256")
+	}
+	hash, err := kmAlg.Hash()
+	if err != nil {
+		return nil, err
+	}
+	hash.Write(k.Data[4:])
+	hash.Write(k.Data[:4])
+	return hash.Sum(nil), nil
 }
 
 //PrintBPMPubKey prints the BPM public signing key hash to fuse into the Intel ME
 func (k *Key) PrintBPMPubKey(bpmAlg Algorithm) error {
-	buf := new(bytes.Buffer)
 	if len(k.Data) > 1 {
-		hash, err := bpmAlg.Hash()
-		if err != nil {
-			return err
-		}
-		if k.KeyAlg == AlgRSA {
-			if err := binary.Write(buf, binary.LittleEndian, k.Data[4:]); err != nil {
-				return err
-			}
-			hash.Write(buf.Bytes())
-			fmt.Printf("   Boot Policy Manifest Pubkey Hash: 0x%x\n", hash.Sum(nil))
-		} else if k.KeyAlg == AlgSM2 || k.KeyAlg == AlgECC {
-			if err := binary.Write(buf, binary.LittleEndian, k.Data); err != nil {
+		switch k.KeyAlg {
+		case AlgRSA, AlgSM2, AlgECC:
+			hash, err := k.BPMPubKeyHash(bpmAlg)
+			if err != nil {
 				return err
 			}
-			hash.Write(buf.Bytes())
-			fmt.Printf("   Boot Policy Manifest Pubkey Hash: 0x%x\n", hash.Sum(nil))
-		} else {
+			fmt.Printf("   Boot Policy Manifest Pubkey Hash: 0x%x\n", hash)
+		default:
 			fmt.Printf("   Boot Policy Manifest Pubkey Hash: Unknown Algorithm\n")
 		}
 	} else {
@@ -184,24 +231,13 @@ func (k *Key) PrintBPMPubKey(bpmAlg Algorithm) error {
 
 //PrintKMPubKey prints the KM public signing key hash to fuse into the Intel ME
 func (k *Key) PrintKMPubKey(kmAlg Algorithm) error {
-	buf := new(bytes.Buffer)
 	if len(k.Data) > 1 {
 		if k.KeyAlg == AlgRSA {
-			if err := binary.Write(buf, binary.LittleEndian, k.Data[4:]); err != nil {
-				return err
-			}
-			if err := binary.Write(buf, binary.LittleEndian, k.Data[:4]); err != nil {
-				return err
-			}
-			if kmAlg != AlgSHA256 {
-				return fmt.Errorf("KM public key hash algorithm must be SHA256")
-			}
-			hash, err := kmAlg.Hash()
+			hash, err := k.KMPubKeyHash(kmAlg)
 			if err != nil {
 				return err
 			}
-			hash.Write(buf.Bytes())
-			fmt.Printf("   Key Manifest Pubkey Hash: 0x%x\n", hash.Sum(nil))
+			fmt.Printf("   Key Manifest Pubkey Hash: 0x%x\n", hash)
 		} else {
 			fmt.Printf("   Key Manifest Pubkey Hash: Unsupported Algorithm\n")
 		}
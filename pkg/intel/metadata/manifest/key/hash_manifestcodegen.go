@@ -5,8 +5,8 @@
 package key
 
 import (
-	"encoding/binary"
 	"fmt"
+	binary "github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/common/pooledbinary"
 	"io"
 	"strings"
 
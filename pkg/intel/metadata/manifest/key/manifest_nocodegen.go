@@ -12,11 +12,11 @@ import (
 )
 
 // Print prints the Key Manifest.
-func (m *Manifest) Print() {
+func (m *Manifest) Print(opts ...pretty.Option) {
 	if m.KeyAndSignature.Signature.DataTotalSize() < 1 {
-		fmt.Printf("%v\n", m.PrettyString(1, true, pretty.OptionOmitKeySignature(true)))
+		fmt.Printf("%v\n", m.PrettyString(1, true, append(opts, pretty.OptionOmitKeySignature(true))...))
 		fmt.Printf("  --KeyAndSignature--\n\tKey Manifest not signed!\n\n")
 	} else {
-		fmt.Printf("%v\n", m.PrettyString(1, true, pretty.OptionOmitKeySignature(false)))
+		fmt.Printf("%v\n", m.PrettyString(1, true, append(opts, pretty.OptionOmitKeySignature(false))...))
 	}
 }
@@ -4,6 +4,7 @@ package manifest
 
 import (
 	"crypto"
+	"crypto/rsa"
 	"fmt"
 )
 
@@ -16,19 +17,68 @@ type KeySignature struct {
 
 // Verify verifies the builtin signature with the builtin public key.
 func (m *KeySignature) Verify(signedData []byte) error {
-	sig, err := m.Signature.SignatureData()
-	if err != nil {
-		return fmt.Errorf("invalid signature: %w", err)
-	}
+	_, err := m.VerifyAuto(signedData)
+	return err
+}
+
+// VerifyAuto verifies the builtin signature with the builtin public key and
+// returns the signature scheme the verification succeeded with.
+//
+// The scheme recorded in the signature structure is used if it is set.
+// Otherwise RSASSA (PKCS#1 v1.5) and RSAPSS are tried for an RSA key.
+func (m *KeySignature) VerifyAuto(signedData []byte) (Algorithm, error) {
 	pk, err := m.Key.PubKey()
 	if err != nil {
-		return fmt.Errorf("invalid public key: %w", err)
+		return AlgUnknown, fmt.Errorf("invalid public key: %w", err)
 	}
-	err = sig.Verify(pk, signedData)
-	if err != nil {
-		return fmt.Errorf("verification failed: %w", err)
+	schemes := []Algorithm{m.Signature.SigScheme}
+	if m.Signature.SigScheme.IsNull() {
+		if m.Key.KeyAlg != AlgRSA {
+			return AlgUnknown, fmt.Errorf("signature scheme is not set and can't be detected for key algorithm %s", m.Key.KeyAlg)
+		}
+		schemes = []Algorithm{AlgRSASSA, AlgRSAPSS}
+	}
+	for _, scheme := range schemes {
+		sig := m.Signature
+		sig.SigScheme = scheme
+		var sigData SignatureDataInterface
+		sigData, err = sig.SignatureData()
+		if err != nil {
+			err = fmt.Errorf("invalid signature: %w", err)
+			continue
+		}
+		if err = verifyWithHashAlg(sigData, pk, signedData, m.Signature.HashAlg); err != nil {
+			err = fmt.Errorf("verification failed: %w", err)
+			continue
+		}
+		return scheme, nil
+	}
+	return AlgUnknown, err
+}
+
+// verifyWithHashAlg verifies an RSA signature with hashAlg, e.g. the SHA384
+// a KM records when it's signed with its PubKeyHashAlg, defaulting to SHA256
+// if hashAlg is unset. Other signatures are verified as is.
+func verifyWithHashAlg(sig SignatureDataInterface, pk crypto.PublicKey, signedData []byte, hashAlg Algorithm) error {
+	hashFunc := crypto.SHA256
+	if !hashAlg.IsNull() {
+		var ok bool
+		if hashFunc, ok = hashAlg.cryptoHash(); !ok {
+			return fmt.Errorf("hash algorithm %s is not supported for RSA signatures", hashAlg)
+		}
+	}
+	rsaKey, isRSA := pk.(*rsa.PublicKey)
+	switch sig := sig.(type) {
+	case SignatureRSAPSS:
+		if isRSA {
+			return sig.verifyHash(rsaKey, signedData, hashFunc)
+		}
+	case SignatureRSAASA:
+		if isRSA {
+			return sig.verifyHash(rsaKey, signedData, hashFunc)
+		}
 	}
-	return nil
+	return sig.Verify(pk, signedData)
 }
 
 // SetSignature generates a signature and sets all the values of KeyManifest,
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySignatureVerifyAuto(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signedData := []byte("signed data")
+
+	for _, scheme := range []Algorithm{AlgRSASSA, AlgRSAPSS} {
+		var ks KeySignature
+		require.NoError(t, ks.SetSignature(scheme, privKey, signedData))
+		require.Equal(t, scheme, ks.Signature.SigScheme)
+
+		// The scheme recorded in the signature structure is used.
+		detected, err := ks.VerifyAuto(signedData)
+		require.NoError(t, err)
+		require.Equal(t, scheme, detected)
+
+		// Without a recorded scheme both RSA schemes are tried.
+		ks.Signature.SigScheme = AlgNull
+		detected, err = ks.VerifyAuto(signedData)
+		require.NoError(t, err)
+		require.Equal(t, scheme, detected)
+
+		_, err = ks.VerifyAuto([]byte("other data"))
+		require.Error(t, err)
+	}
+}
+
+func TestKeySignatureKeyTypes(t *testing.T) {
+	signedData := []byte("signed data")
+
+	eccKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	var ks KeySignature
+	require.NoError(t, ks.SetSignatureAuto(eccKey, signedData))
+	require.Equal(t, AlgECC, ks.Key.KeyAlg)
+	require.Equal(t, AlgECDSA, ks.Signature.SigScheme)
+
+	// The manifests have no EdDSA algorithm, Ed25519 keys are rejected
+	// before anything is written.
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	var k Key
+	require.True(t, errors.Is(k.SetPubKey(pubKey), ErrUnsupportedKeyType))
+	require.True(t, k.KeyAlg.IsNull())
+	for _, scheme := range []Algorithm{0, AlgRSASSA, AlgECDSA} {
+		_, err = NewSignatureData(scheme, privKey, signedData)
+		require.True(t, errors.Is(err, ErrUnsupportedKeyType), "scheme %s: %v", scheme, err)
+	}
+	ks = KeySignature{}
+	require.True(t, errors.Is(ks.SetSignatureAuto(privKey, signedData), ErrUnsupportedKeyType))
+	require.Empty(t, ks.Signature.Data)
+}
+
+func TestSetSignatureDataShortComponents(t *testing.T) {
+	// R and S with leading zero bytes are padded to the curve width.
+	r := big.NewInt(0x1234)
+	s := new(big.Int).Lsh(big.NewInt(1), 255)
+	var sig Signature
+	require.NoError(t, sig.SetSignatureByData(SignatureECDSA{R: r, S: s}, AlgSHA256))
+	require.Len(t, sig.Data, 64)
+	require.Equal(t, uint16(256), sig.KeySize.InBits())
+	data, err := sig.SignatureData()
+	require.NoError(t, err)
+	require.Equal(t, 0, r.Cmp(data.(SignatureECDSA).R))
+	require.Equal(t, 0, s.Cmp(data.(SignatureECDSA).S))
+
+	s = new(big.Int).Lsh(big.NewInt(1), 300)
+	require.NoError(t, sig.SetSignatureByData(SignatureECDSA{R: r, S: s}, AlgSHA384))
+	require.Len(t, sig.Data, 96)
+	require.Equal(t, uint16(384), sig.KeySize.InBits())
+
+	s = new(big.Int).Lsh(big.NewInt(1), 400)
+	require.Error(t, sig.SetSignatureByData(SignatureECDSA{R: r, S: s}, AlgSHA384))
+}
+
+func TestSetPubKeyShortCoordinates(t *testing.T) {
+	// coordinates with leading zero bytes are padded to the key size
+	eccKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub := eccKey.PublicKey
+	pub.X = big.NewInt(0x1234)
+	var k Key
+	require.NoError(t, k.SetPubKey(&pub))
+	require.Len(t, k.Data, 64)
+	parsed, err := k.PubKey()
+	require.NoError(t, err)
+	require.Equal(t, 0, pub.X.Cmp(parsed.(ecdsa.PublicKey).X))
+	require.Equal(t, 0, pub.Y.Cmp(parsed.(ecdsa.PublicKey).Y))
+}
@@ -96,7 +96,7 @@ func (m *Signature) SetSignatureByData(sig SignatureDataInterface, hashAlgo Algo
 		} else {
 			m.HashAlg = hashAlgo
 		}
-		m.KeySize.SetInBits(uint16(sig.R.BitLen()))
+		m.KeySize.SetInBits(uint16(len(m.Data) / 2 * 8))
 	case SignatureSM2:
 		m.SigScheme = AlgSM2
 		if hashAlgo.IsNull() {
@@ -104,7 +104,7 @@ func (m *Signature) SetSignatureByData(sig SignatureDataInterface, hashAlgo Algo
 		} else {
 			m.HashAlg = hashAlgo
 		}
-		m.KeySize.SetInBits(uint16(sig.R.BitLen()))
+		m.KeySize.SetInBits(uint16(len(m.Data) / 2 * 8))
 	default:
 		return fmt.Errorf("unexpected signature type: %T", sig)
 	}
@@ -133,21 +133,37 @@ func (m *Signature) SetSignatureData(sig SignatureDataInterface) error {
 		default:
 			return fmt.Errorf("internal error")
 		}
-		if r.BitLen() != s.BitLen() {
-			return fmt.Errorf("the length of component R (%d) is not equal to the length of component S (%d)", r.BitLen(), s.BitLen())
+		size, err := eccComponentSize(r, s)
+		if err != nil {
+			return err
 		}
-		if r.BitLen() != 256 && r.BitLen() != 384 {
-			return fmt.Errorf("component R (or S) size should be 256 or 384 bites (not %d)", r.BitLen())
-		}
-		m.Data = make([]byte, r.BitLen()/8+s.BitLen()/8)
+		// R and S are stored little-endian and zero padded to the curve
+		// width, they may be shorter if their leading bytes are zero.
+		m.Data = make([]byte, 2*size)
 		copy(m.Data[:], reverseBytes(r.Bytes()))
-		copy(m.Data[r.BitLen()/8:], reverseBytes(s.Bytes()))
+		copy(m.Data[size:], reverseBytes(s.Bytes()))
 	default:
 		return fmt.Errorf("unexpected signature type: %T", sig)
 	}
 	return nil
 }
 
+// eccComponentSize returns the size in bytes of the R and S components of an
+// ECC signature over a 256 or 384 bit curve.
+func eccComponentSize(r, s *big.Int) (int, error) {
+	bitLen := r.BitLen()
+	if s.BitLen() > bitLen {
+		bitLen = s.BitLen()
+	}
+	switch {
+	case bitLen <= 256:
+		return 256 / 8, nil
+	case bitLen <= 384:
+		return 384 / 8, nil
+	}
+	return 0, fmt.Errorf("component R (or S) size should be at most 384 bits (not %d)", bitLen)
+}
+
 // SetSignature calculates the signature accordingly to arguments signAlgo,
 // privKey and signedData; and sets all the fields of the structure Signature.
 //
@@ -3,6 +3,7 @@ package manifest
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
 	"fmt"
@@ -23,6 +24,10 @@ func NewSignatureData(
 	privKey crypto.Signer,
 	signedData []byte,
 ) (SignatureDataInterface, error) {
+	switch privKey.(type) {
+	case ed25519.PrivateKey, *ed25519.PrivateKey:
+		return nil, fmt.Errorf("%w: Ed25519, there is no EdDSA signing algorithm in the manifest format", ErrUnsupportedKeyType)
+	}
 	if signAlgo == 0 {
 		// auto-detect the sign algorithm, based on the provided signing key
 		switch privKey.(type) {
@@ -114,6 +119,8 @@ func NewSignatureByData(
 			signAlgo = AlgECDSA
 		case *sm2.PublicKey:
 			signAlgo = AlgSM2
+		case ed25519.PublicKey:
+			return nil, fmt.Errorf("%w: Ed25519, there is no EdDSA signing algorithm in the manifest format", ErrUnsupportedKeyType)
 		}
 	}
 	switch signAlgo {
@@ -158,15 +165,19 @@ func (s SignatureRSAPSS) Verify(pkIface crypto.PublicKey, signedData []byte) err
 	if !ok {
 		return fmt.Errorf("expected public key of type %T, but received %T", pk, pkIface)
 	}
-	h := sha256.New()
+	return s.verifyHash(pk, signedData, crypto.SHA256)
+}
+
+func (s SignatureRSAPSS) verifyHash(pk *rsa.PublicKey, signedData []byte, hashFunc crypto.Hash) error {
+	h := hashFunc.New()
 	h.Write(signedData)
 	hash := h.Sum(nil)
 
 	pss := rsa.PSSOptions{
 		SaltLength: rsa.PSSSaltLengthAuto,
-		Hash:       crypto.SHA256,
+		Hash:       hashFunc,
 	}
-	err := rsa.VerifyPSS(pk, crypto.SHA256, hash, s, &pss)
+	err := rsa.VerifyPSS(pk, hashFunc, hash, s, &pss)
 	if err != nil {
 		return fmt.Errorf("data was not signed by the key: %w", err)
 	}
@@ -188,11 +199,15 @@ func (s SignatureRSAASA) Verify(pkIface crypto.PublicKey, signedData []byte) err
 		return fmt.Errorf("expected public key of type %T, but received %T", pk, pkIface)
 	}
 
-	h := sha256.New()
+	return s.verifyHash(pk, signedData, crypto.SHA256)
+}
+
+func (s SignatureRSAASA) verifyHash(pk *rsa.PublicKey, signedData []byte, hashFunc crypto.Hash) error {
+	h := hashFunc.New()
 	h.Write(signedData)
 	hash := h.Sum(nil)
 
-	err := rsa.VerifyPKCS1v15(pk, crypto.SHA256, hash, s)
+	err := rsa.VerifyPKCS1v15(pk, hashFunc, hash, s)
 	if err != nil {
 		return fmt.Errorf("data was not signed by the key: %w", err)
 	}
@@ -0,0 +1,277 @@
+package bg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// ErrACMSVNTooLow is returned if the SVN of the ACM is below the ACMSVNAuth
+// of the BPM.
+var ErrACMSVNTooLow = errors.New("ACM SVN is below the minimum authorized by the BPM")
+
+// ErrACMHashAlgsUnknown is returned if the ACM doesn't list the algorithms it supports.
+var ErrACMHashAlgsUnknown = errors.New("ACM doesn't list its supported hash algorithms")
+
+// ErrIBBHashAlgsUnsupported is returned if the ACM supports none of the
+// algorithms of the IBB digests of an IBB element of the BPM.
+var ErrIBBHashAlgsUnsupported = errors.New("ACM supports none of the IBB digest algorithms")
+
+// ACMHashAlgorithms returns the hash algorithms listed in the TPM info list of the ACM.
+func ACMHashAlgorithms(acm []byte) ([]manifest.Algorithm, error) {
+	_, _, _, tpms, err, err2 := tools.ParseACM(acm)
+	if err == nil {
+		err = err2
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ACM: %w", err)
+	}
+	if tpms == nil || len(tpms.AlgID) == 0 {
+		return nil, ErrACMHashAlgsUnknown
+	}
+	algs := make([]manifest.Algorithm, 0, len(tpms.AlgID))
+	for _, alg := range tpms.AlgID {
+		// TPM and manifest algorithms share the TCG algorithm IDs
+		algs = append(algs, manifest.Algorithm(alg))
+	}
+	return algs, nil
+}
+
+// CheckKMHashAlgsSupported checks that the PubKeyHashAlg and the hash entries of
+// the KM only use algorithms in algs. A KM using another algorithm is rejected by
+// the ACM and the platform won't boot.
+func CheckKMHashAlgsSupported(km *key.Manifest, algs []manifest.Algorithm) error {
+	supported := func(alg manifest.Algorithm) bool {
+		for _, a := range algs {
+			if a == alg {
+				return true
+			}
+		}
+		return false
+	}
+	if !supported(km.PubKeyHashAlg) {
+		return fmt.Errorf("KM PubKeyHashAlg %s is not supported by the ACM, supported: %v", km.PubKeyHashAlg, algs)
+	}
+	for idx, h := range km.Hash {
+		if !supported(h.Digest.HashAlg) {
+			return fmt.Errorf("KM hash entry %d (%s) uses %s, which is not supported by the ACM, supported: %v",
+				idx, h.Usage, h.Digest.HashAlg, algs)
+		}
+	}
+	return nil
+}
+
+// CheckKMAgainstACM checks that the ACM accepts the hash algorithms of the KM.
+func CheckKMAgainstACM(kmData, acmData []byte) error {
+	return DefaultParseOptions().CheckKMAgainstACM(kmData, acmData)
+}
+
+// CheckKMAgainstACM is CheckKMAgainstACM with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) CheckKMAgainstACM(kmData, acmData []byte) error {
+	km, err := o.ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		return err
+	}
+	algs, err := ACMHashAlgorithms(acmData)
+	if err != nil {
+		return err
+	}
+	return CheckKMHashAlgsSupported(km, algs)
+}
+
+// CheckIBBDigestAlgsSupported checks that every IBB element of the BPM has an
+// IBB digest of an algorithm in algs. The ACM can't measure the IBB with the
+// other algorithms and the platform won't boot.
+func CheckIBBDigestAlgsSupported(bpm *bootpolicy.Manifest, algs []manifest.Algorithm) error {
+	for idx, se := range bpm.SE {
+		var digestAlgs []manifest.Algorithm
+		supported := false
+		for _, d := range se.DigestList.List {
+			digestAlgs = append(digestAlgs, d.HashAlg)
+			supported = supported || containsAlg(algs, d.HashAlg)
+		}
+		if !supported {
+			return fmt.Errorf("%w: SE %d has IBB digests of %v, the ACM supports %v",
+				ErrIBBHashAlgsUnsupported, idx, digestAlgs, algs)
+		}
+	}
+	return nil
+}
+
+// CheckBPMHashAlgsAgainstACM checks that the ACM supports an algorithm of the
+// IBB digests of every IBB element of the BPM.
+func CheckBPMHashAlgsAgainstACM(bpmData, acmData []byte) error {
+	return DefaultParseOptions().CheckBPMHashAlgsAgainstACM(bpmData, acmData)
+}
+
+// CheckBPMHashAlgsAgainstACM is CheckBPMHashAlgsAgainstACM with the options
+// o instead of the DefaultParseOptions.
+func (o ParseOptions) CheckBPMHashAlgsAgainstACM(bpmData, acmData []byte) error {
+	bpm, err := o.ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		return err
+	}
+	algs, err := ACMHashAlgorithms(acmData)
+	if err != nil {
+		return err
+	}
+	return CheckIBBDigestAlgsSupported(bpm, algs)
+}
+
+// CheckBPMAgainstACM checks that the SVN of the ACM meets the minimum ACM SVN
+// the BPM authorizes (ACMSVNAuth), otherwise the platform doesn't boot with
+// the ACM. The ACM header only carries the SVN of the ACM itself, the minimum
+// KMSVN and BPMSVN are fused and can't be checked against the ACM.
+func CheckBPMAgainstACM(bpmData, acmData []byte) error {
+	return DefaultParseOptions().CheckBPMAgainstACM(bpmData, acmData)
+}
+
+// CheckBPMAgainstACM is CheckBPMAgainstACM with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) CheckBPMAgainstACM(bpmData, acmData []byte) error {
+	bpm, err := o.ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		return err
+	}
+	header, err := tools.ParseACMHeader(acmData)
+	if err != nil {
+		return fmt.Errorf("unable to parse ACM: %w", err)
+	}
+	return CheckACMSVN(bpm, header)
+}
+
+// CheckACMSVN checks that the SVN of the ACM header meets the ACMSVNAuth of
+// the BPM header.
+func CheckACMSVN(bpm *bootpolicy.Manifest, header *tools.ACMHeader) error {
+	if required := bpm.BPMH.ACMSVNAuth.SVN(); uint16(required) > header.TxtSVN {
+		return fmt.Errorf("%w: the BPM requires ACMSVNAuth %d, the ACM has SVN %d, %d below",
+			ErrACMSVNTooLow, required, header.TxtSVN, uint16(required)-header.TxtSVN)
+	}
+	return nil
+}
+
+// ACMPlatform are the IDs of a platform an ACM has to support. The chipset is
+// only checked if VendorID or DeviceID are set, the processor only if FMS is
+// set and the TPM only if TPM is set.
+type ACMPlatform struct {
+	VendorID   uint16
+	DeviceID   uint16
+	RevisionID uint16
+	FMS        uint32
+	PlatformID uint64
+	TPM        tools.TPMFamily
+}
+
+// CheckACMPlatform checks that the chipset and processor ID lists of the ACM
+// contain the platform and that its TPM info list declares support for the
+// TPM of the platform, e.g. to validate a standalone SINIT ACM before it is
+// deployed.
+func CheckACMPlatform(acm []byte, p ACMPlatform) (*CheckResults, error) {
+	results := NewCheckResults()
+	if p == (ACMPlatform{}) {
+		return results, nil
+	}
+	_, chipsets, processors, tpms, err, err2 := tools.ParseACM(acm)
+	if err == nil {
+		err = err2
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ACM: %w", err)
+	}
+	if p.VendorID != 0 || p.DeviceID != 0 {
+		result := CheckResult{
+			Check:  "acm-chipset",
+			Pass:   chipsets.Match(p.VendorID, p.DeviceID, p.RevisionID),
+			Actual: fmt.Sprintf("VID 0x%04x DID 0x%04x RID 0x%x", p.VendorID, p.DeviceID, p.RevisionID),
+		}
+		if !result.Pass {
+			result.Detail = "chipset is not in the chipset ID list of the ACM"
+		}
+		results.Add(result)
+	}
+	if p.FMS != 0 {
+		result := CheckResult{
+			Check:  "acm-processor",
+			Pass:   processors.Match(p.FMS, p.PlatformID),
+			Actual: fmt.Sprintf("FMS 0x%08x platform ID 0x%x", p.FMS, p.PlatformID),
+		}
+		if !result.Pass {
+			result.Detail = "processor is not in the processor ID list of the ACM"
+		}
+		results.Add(result)
+	}
+	if p.TPM != 0 {
+		results.Add(checkACMTPM(tpms, p.TPM))
+	}
+	return results, nil
+}
+
+// checkACMTPM checks that the TPM info list of the ACM declares support for
+// the TPM family. ACMs without TPM info list declare no support and pass.
+func checkACMTPM(tpms *tools.TPMs, family tools.TPMFamily) CheckResult {
+	result := CheckResult{Check: "acm-tpm", Actual: family.String()}
+	families := tpms.Families()
+	if len(families) == 0 {
+		result.Pass = true
+		result.Detail = "the ACM declares no supported TPM families"
+		return result
+	}
+	var names []string
+	for _, f := range families {
+		names = append(names, f.String())
+	}
+	result.Expected = strings.Join(names, ", ")
+	result.Pass = tools.ACMSupportsTPM(tpms, family)
+	if !result.Pass {
+		result.Detail = fmt.Sprintf("the ACM only supports %s", result.Expected)
+	}
+	return result
+}
+
+// ValidateACMPlatform returns an error if the chipset or processor ID lists of
+// the ACM don't contain the platform, e.g. before the ACM is stitched into an
+// image which wouldn't boot on the platform. A warning is issued to warnings,
+// or printed to os.Stderr if it is nil, if the ACM doesn't support the TPM of
+// the platform.
+func ValidateACMPlatform(acm []byte, p ACMPlatform, warnings *WarningCollector) error {
+	results, err := CheckACMPlatform(acm, p)
+	if err != nil {
+		return err
+	}
+	for _, failed := range results.Failed() {
+		if failed.Check == "acm-tpm" {
+			collectorOrDefault(warnings).Warnf("ACM doesn't support the %s of the platform: %s", failed.Actual, failed.Detail)
+			continue
+		}
+		return fmt.Errorf("ACM doesn't support the platform %s: %s", failed.Actual, failed.Detail)
+	}
+	return nil
+}
+
+// DetectACMPlatform reads the chipset IDs from TXT.DIDVID and the CPU
+// signature and IA32_PLATFORM_ID of the running system.
+func DetectACMPlatform(txtAPI hwapi.APIInterfaces) (ACMPlatform, error) {
+	var p ACMPlatform
+	buf, err := tools.FetchTXTRegs(txtAPI)
+	if err != nil {
+		return p, fmt.Errorf("unable to read the TXT registers: %w", err)
+	}
+	regs, err := tools.ParseTXTRegs(buf)
+	if err != nil {
+		return p, fmt.Errorf("unable to parse the TXT registers: %w", err)
+	}
+	p.VendorID, p.DeviceID, p.RevisionID = regs.Vid, regs.Did, regs.Rid
+	p.FMS = txtAPI.CPUSignature()
+	if p.PlatformID, err = txtAPI.IA32PlatformID(); err != nil {
+		return p, fmt.Errorf("unable to read IA32_PLATFORM_ID: %w", err)
+	}
+	return p, nil
+}
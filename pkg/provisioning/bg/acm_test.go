@@ -0,0 +1,249 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+func TestCheckKMHashAlgsSupported(t *testing.T) {
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA384
+	km.Hash = []key.Hash{kmHash(manifest.AlgSHA384, 48)}
+
+	if err := CheckKMHashAlgsSupported(km, []manifest.Algorithm{manifest.AlgSHA256, manifest.AlgSHA384}); err != nil {
+		t.Errorf("CheckKMHashAlgsSupported() failed on a compatible pairing: %v", err)
+	}
+	if err := CheckKMHashAlgsSupported(km, []manifest.Algorithm{manifest.AlgSHA1, manifest.AlgSHA256}); err == nil {
+		t.Errorf("CheckKMHashAlgsSupported() accepted an incompatible PubKeyHashAlg")
+	}
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	if err := CheckKMHashAlgsSupported(km, []manifest.Algorithm{manifest.AlgSHA256}); err == nil {
+		t.Errorf("CheckKMHashAlgsSupported() accepted an incompatible hash entry")
+	}
+}
+
+func TestACMHashAlgorithmsInvalid(t *testing.T) {
+	if _, err := ACMHashAlgorithms([]byte("short")); err == nil || errors.Is(err, ErrACMHashAlgsUnknown) {
+		t.Errorf("ACMHashAlgorithms() returned %v on a truncated ACM, expected a parse error", err)
+	}
+	km, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if err := CheckKMAgainstACM(km, []byte("short")); err == nil {
+		t.Errorf("CheckKMAgainstACM() succeeded with a truncated ACM")
+	}
+}
+
+func TestCheckBPMAgainstACM(t *testing.T) {
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	// The fixture ACM has SVN 1, the BPM requires ACMSVNAuth 2
+	err = CheckBPMAgainstACM(bpm, acm)
+	if !errors.Is(err, ErrACMSVNTooLow) {
+		t.Fatalf("CheckBPMAgainstACM() of an ACM below ACMSVNAuth returned %v, expected %v", err, ErrACMSVNTooLow)
+	}
+	if expected := "the BPM requires ACMSVNAuth 2, the ACM has SVN 1, 1 below"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("CheckBPMAgainstACM() returned %q, expected it to contain %q", err, expected)
+	}
+
+	binary.LittleEndian.PutUint16(acm[28:], 2)
+	if err := CheckBPMAgainstACM(bpm, acm); err != nil {
+		t.Errorf("CheckBPMAgainstACM() of an ACM meeting ACMSVNAuth returned %v", err)
+	}
+	if err := CheckBPMAgainstACM(bpm, []byte("short")); err == nil {
+		t.Errorf("CheckBPMAgainstACM() succeeded with a truncated ACM")
+	}
+}
+
+func TestPrintBootGuardStructuresACMSVN(t *testing.T) {
+	image, err := ioutil.ReadFile(testCorebootPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	_, _, acm, err := ParseFITEntries(image)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() failed: %v", err)
+	}
+	svnOffset := bytes.Index(image, acm[:64]) + 28
+
+	var out bytes.Buffer
+	// The BPM of the fixture requires ACMSVNAuth 2
+	for svn, warned := range map[uint16]bool{0: true, 2: false} {
+		out.Reset()
+		opts := ParseOptions{StrictOrder: true, Warnings: &WarningCollector{Out: &out}}
+		binary.LittleEndian.PutUint16(image[svnOffset:], svn)
+		captureStdout(t, func() {
+			if err := opts.PrintBootGuardStructures(image); err != nil {
+				t.Errorf("PrintBootGuardStructures() failed: %v", err)
+			}
+		})
+		if strings.Contains(out.String(), ErrACMSVNTooLow.Error()) != warned {
+			t.Errorf("PrintBootGuardStructures() with ACM SVN %d warned %q", svn, out.String())
+		}
+	}
+}
+
+func TestCheckIBBDigestAlgsSupported(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	// The fixture ACM supports SHA1 and SHA256, the BPM has SHA256, SHA1, SHA384 and SM3 digests
+	if err := CheckBPMHashAlgsAgainstACM(data, acm); err != nil {
+		t.Errorf("CheckBPMHashAlgsAgainstACM() failed on a compatible pairing: %v", err)
+	}
+
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	algs, err := ACMHashAlgorithms(acm)
+	if err != nil {
+		t.Fatalf("ACMHashAlgorithms() failed: %v", err)
+	}
+	var sha384 []manifest.HashStructure
+	for _, d := range bpm.SE[0].DigestList.List {
+		if d.HashAlg == manifest.AlgSHA384 {
+			sha384 = append(sha384, d)
+		}
+	}
+	bpm.SE[0].DigestList.List = sha384
+	err = CheckIBBDigestAlgsSupported(bpm, algs)
+	if !errors.Is(err, ErrIBBHashAlgsUnsupported) {
+		t.Fatalf("CheckIBBDigestAlgsSupported() of SHA384 IBB digests returned %v, expected %v", err, ErrIBBHashAlgsUnsupported)
+	}
+	if expected := "SE 0 has IBB digests of [SHA384]"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("CheckIBBDigestAlgsSupported() returned %q, expected it to contain %q", err, expected)
+	}
+	if err := CheckIBBDigestAlgsSupported(bpm, []manifest.Algorithm{manifest.AlgSHA384}); err != nil {
+		t.Errorf("CheckIBBDigestAlgsSupported() of SHA384 IBB digests and a SHA384 ACM returned %v", err)
+	}
+}
+
+func TestCheckACMPlatform(t *testing.T) {
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	_, chipsets, processors, _, err, err2 := tools.ParseACM(acm)
+	if err != nil || err2 != nil {
+		t.Fatalf("ParseACM() failed: %v, %v", err, err2)
+	}
+	if len(chipsets.IDList) == 0 || len(processors.IDList) == 0 {
+		t.Fatalf("SINIT fixture has no chipset or processor IDs")
+	}
+	ch, cpu := chipsets.IDList[0], processors.IDList[0]
+	platform := ACMPlatform{
+		VendorID:   ch.VendorID,
+		DeviceID:   ch.DeviceID,
+		RevisionID: ch.RevisionID,
+		FMS:        cpu.FMS,
+		PlatformID: cpu.PlatformID,
+	}
+	r, err := CheckACMPlatform(acm, platform)
+	if err != nil {
+		t.Fatalf("CheckACMPlatform() failed: %v", err)
+	}
+	if !r.Pass || len(r.Checks) != 2 {
+		t.Errorf("CheckACMPlatform() of a supported platform returned %+v", r)
+	}
+
+	platform.DeviceID ^= 0xffff
+	platform.FMS = ^cpu.FMSMask
+	if r, err = CheckACMPlatform(acm, platform); err != nil || r.Pass || len(r.Failed()) != 2 {
+		t.Errorf("CheckACMPlatform() of an unsupported platform returned %+v, %v", r, err)
+	}
+
+	if r, err = CheckACMPlatform(acm, ACMPlatform{}); err != nil || !r.Pass || len(r.Checks) != 0 {
+		t.Errorf("CheckACMPlatform() without IDs returned %+v, %v", r, err)
+	}
+}
+
+func TestCheckACMPlatformTPM(t *testing.T) {
+	// an ACM supporting TPM 2.0 only, discrete and PTT
+	acm20 := NewStubACM(StubACMParams{ChipsetACMType: tools.ACMChipsetTypeBios, TPMCapabilities: 0x29})
+	r, err := CheckACMPlatform(acm20, ACMPlatform{TPM: tools.TPMFamilyPTT})
+	if err != nil {
+		t.Fatalf("CheckACMPlatform() failed: %v", err)
+	}
+	if !r.Pass || len(r.Checks) != 1 || r.Checks[0].Check != "acm-tpm" {
+		t.Errorf("CheckACMPlatform() of a supported TPM returned %+v", r)
+	}
+	if r, err = CheckACMPlatform(acm20, ACMPlatform{TPM: tools.TPMFamilyDiscrete12}); err != nil || r.Pass {
+		t.Errorf("CheckACMPlatform() of a TPM 1.2 platform and a TPM 2.0 ACM returned %+v, %v", r, err)
+	}
+
+	// the mismatch is only a warning when provisioning
+	var out bytes.Buffer
+	warnings := &WarningCollector{Out: &out}
+	if err := ValidateACMPlatform(acm20, ACMPlatform{TPM: tools.TPMFamilyDiscrete12}, warnings); err != nil {
+		t.Errorf("ValidateACMPlatform() of a TPM 1.2 platform and a TPM 2.0 ACM failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "dTPM 1.2") || warnings.Count() != 1 {
+		t.Errorf("ValidateACMPlatform() didn't warn about the TPM, printed %q", out.String())
+	}
+
+	// an ACM without TPM families isn't rejected
+	acm := NewStubACM(StubACMParams{ChipsetACMType: tools.ACMChipsetTypeBios, TPMCapabilities: 0x01})
+	if r, err = CheckACMPlatform(acm, ACMPlatform{TPM: tools.TPMFamilyDiscrete12}); err != nil || !r.Pass {
+		t.Errorf("CheckACMPlatform() of an ACM without TPM families returned %+v, %v", r, err)
+	}
+}
+
+func TestValidateACMPlatform(t *testing.T) {
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	_, chipsets, _, _, err, err2 := tools.ParseACM(acm)
+	if err != nil || err2 != nil {
+		t.Fatalf("ParseACM() failed: %v, %v", err, err2)
+	}
+	ch := chipsets.IDList[0]
+	platform := ACMPlatform{VendorID: ch.VendorID, DeviceID: ch.DeviceID, RevisionID: ch.RevisionID}
+	if err := ValidateACMPlatform(acm, platform, nil); err != nil {
+		t.Errorf("ValidateACMPlatform() of a supported chipset failed: %v", err)
+	}
+	platform.DeviceID ^= 0xffff
+	if err := ValidateACMPlatform(acm, platform, nil); err == nil {
+		t.Errorf("ValidateACMPlatform() of an unsupported chipset succeeded")
+	}
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() failed: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,38 @@
+package bg
+
+import (
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// AlgorithmField is a hash, key or signature algorithm in the JSON outputs.
+// It holds both the TCG algorithm ID and its name, so consumers neither need
+// a table of the IDs nor have to parse the names.
+type AlgorithmField struct {
+	ID   manifest.Algorithm `json:"algId"`
+	Name string             `json:"algName"`
+}
+
+// NewAlgorithmField returns the output field of alg.
+func NewAlgorithmField(alg manifest.Algorithm) AlgorithmField {
+	return AlgorithmField{ID: alg, Name: alg.String()}
+}
+
+// String returns the name of the algorithm.
+func (a AlgorithmField) String() string {
+	return a.Name
+}
+
+// acmHeaderVersion3 is the ACM header version introduced with the 3072 bit
+// ACM keys of the CBnT ACMs.
+const acmHeaderVersion3 = 0x30000
+
+// acmSignatureAlgorithms returns the signature scheme and hash algorithm of an
+// ACM, which the header version determines: RSASSA with SHA256 up to version
+// 3.0, RSAPSS with SHA384 from version 3.0 on.
+func acmSignatureAlgorithms(header *tools.ACMHeader) (scheme, hashAlg manifest.Algorithm) {
+	if header.HeaderVersion >= acmHeaderVersion3 {
+		return manifest.AlgRSAPSS, manifest.AlgSHA384
+	}
+	return manifest.AlgRSASSA, manifest.AlgSHA256
+}
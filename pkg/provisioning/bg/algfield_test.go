@@ -0,0 +1,101 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+// algorithmKeys are the JSON keys of the algorithm fields of the outputs.
+var algorithmKeys = map[string]bool{
+	"algorithm":             true,
+	"key_algorithm":         true,
+	"scheme":                true,
+	"hash_algorithm":        true,
+	"pubkey_hash_algorithm": true,
+	"hash_alg":              true,
+}
+
+// checkAlgorithmFields checks that every algorithm field of the decoded JSON
+// value v holds both algId and algName, and returns the number of fields.
+func checkAlgorithmFields(t *testing.T, path string, v interface{}) int {
+	count := 0
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if algorithmKeys[key] {
+				field, ok := value.(map[string]interface{})
+				_, hasID := field["algId"].(float64)
+				_, hasName := field["algName"].(string)
+				if !ok || !hasID || !hasName {
+					t.Errorf("%s.%s is %v, expected algId and algName", path, key, value)
+				}
+				count++
+				continue
+			}
+			count += checkAlgorithmFields(t, path+"."+key, value)
+		}
+	case []interface{}:
+		for _, value := range v {
+			count += checkAlgorithmFields(t, path+"[]", value)
+		}
+	}
+	return count
+}
+
+func testAlgorithmFieldsJSON(t *testing.T, name string, writeJSON func(w *bytes.Buffer) error, expected int) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() of the %s failed: %v", name, err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		t.Fatalf("the %s is no valid JSON: %v", name, err)
+	}
+	if count := checkAlgorithmFields(t, name, v); count != expected {
+		t.Errorf("the %s holds %d algorithm fields, expected %d", name, count, expected)
+	}
+}
+
+func TestAlgorithmFieldsJSON(t *testing.T) {
+	km, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	report := &ProvisioningReport{}
+	if err := report.AddKM(km); err != nil {
+		t.Fatalf("AddKM() failed: %v", err)
+	}
+	if err := report.AddBPM(bpm); err != nil {
+		t.Fatalf("AddBPM() failed: %v", err)
+	}
+	if err := report.AddACM(acm); err != nil {
+		t.Fatalf("AddACM() failed: %v", err)
+	}
+	// KM: public key hash algorithm, the hashes and the signature, BPM: the
+	// IBB digests and the signature, ACM: the signature
+	expected := 1 + len(report.KM.KeyHashes) + 3 + len(report.BPM.IBBDigests) + 3 + 2
+	testAlgorithmFieldsJSON(t, "provisioning report", func(w *bytes.Buffer) error { return report.WriteJSON(w) }, expected)
+	if report.ACM.Scheme.Name != "RSASSA" || report.ACM.Scheme.ID != 0x14 {
+		t.Errorf("the ACM scheme is %+v, expected RSASSA with ID 0x14", report.ACM.Scheme)
+	}
+
+	chain, err := ReportKeyChain(km, bpm, nil)
+	if err != nil {
+		t.Fatalf("ReportKeyChain() failed: %v", err)
+	}
+	testAlgorithmFieldsJSON(t, "key chain", func(w *bytes.Buffer) error { return chain.WriteJSON(w) }, len(chain.Hashes))
+
+	crypto := NewCryptoReport(km, bpm, acm)
+	testAlgorithmFieldsJSON(t, "crypto report", func(w *bytes.Buffer) error { return crypto.WriteJSON(w) }, len(crypto.Algorithms))
+}
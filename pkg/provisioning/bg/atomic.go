@@ -0,0 +1,84 @@
+package bg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is a temporary file in the directory of the target file, which
+// replaces the target file on Commit. Until then the target file is left
+// untouched, so a failing command never leaves a truncated output behind.
+type AtomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+// CreateAtomicFile creates a temporary file which replaces path on Commit.
+// Call Abort in a defer statement to remove the temporary file on errors.
+func CreateAtomicFile(path string, perm os.FileMode) (*AtomicFile, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &AtomicFile{File: f, path: path}, nil
+}
+
+// Commit flushes the temporary file and renames it to the target path.
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return fmt.Errorf("%s is already committed or aborted", f.path)
+	}
+	f.done = true
+	err := f.Sync()
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("unable to write %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Abort removes the temporary file. It does nothing after Commit.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// WriteFileAtomicFunc calls write with a temporary file and replaces path
+// with it if write succeeds. On any error the temporary file is removed.
+func WriteFileAtomicFunc(path string, perm os.FileMode, write func(f *os.File) error) error {
+	f, err := CreateAtomicFile(path, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if err := write(f.File); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// WriteFileAtomic is like ioutil.WriteFile, but never leaves a partially written file behind.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteFileAtomicFunc(path, perm, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
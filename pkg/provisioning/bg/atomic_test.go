@@ -0,0 +1,51 @@
+package bg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomic")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bpm.bin")
+
+	errInjected := errors.New("injected write failure")
+	err = WriteFileAtomicFunc(path, 0600, func(f *os.File) error {
+		if _, err := f.Write([]byte("partial")); err != nil {
+			return err
+		}
+		return errInjected
+	})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("WriteFileAtomicFunc() returned %v, expected the injected error", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Failed write left %d files behind, first: %s", len(files), files[0].Name())
+	}
+
+	if err := WriteFileAtomic(path, []byte("complete"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic() failed: %v", err)
+	}
+	_ = WriteFileAtomicFunc(path, 0600, func(f *os.File) error {
+		f.Write([]byte("partial"))
+		return errInjected
+	})
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "complete" {
+		t.Errorf("Failed write modified the existing file: %q", data)
+	}
+}
@@ -0,0 +1,187 @@
+package bg
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// InTotoStatementType is the type of the in-toto statements written by
+	// WriteAttestation.
+	InTotoStatementType = "https://in-toto.io/Statement/v0.1"
+	// SLSAProvenanceType is the predicate type of the statements.
+	SLSAProvenanceType = "https://slsa.dev/provenance/v0.2"
+	// DSSEPayloadType is the payload type of the signed statements.
+	DSSEPayloadType = "application/vnd.in-toto+json"
+	// AttestationBuildType identifies the manifest generation of bg-prov as
+	// build type of the provenance.
+	AttestationBuildType = "https://github.com/9elements/converged-security-suite/bg-prov/manifest-gen@v1"
+)
+
+// DigestSet maps hash algorithm names to hex encoded digests.
+type DigestSet map[string]string
+
+func newDigestSet(data []byte) DigestSet {
+	sum := sha256.Sum256(data)
+	return DigestSet{"sha256": hex.EncodeToString(sum[:])}
+}
+
+// AttestationSubject is an artifact produced by the build, e.g. a manifest.
+type AttestationSubject struct {
+	Name   string    `json:"name"`
+	Digest DigestSet `json:"digest"`
+}
+
+// AttestationMaterial is an input of the build, e.g. the config or the BIOS
+// image.
+type AttestationMaterial struct {
+	URI    string    `json:"uri"`
+	Digest DigestSet `json:"digest"`
+}
+
+// ProvenanceBuilder identifies the tool which produced the subjects.
+type ProvenanceBuilder struct {
+	ID string `json:"id"`
+}
+
+// ProvenanceInvocation holds the command the subjects were produced with.
+type ProvenanceInvocation struct {
+	Parameters map[string]string `json:"parameters"`
+}
+
+// Provenance is the SLSA provenance predicate of a statement.
+type Provenance struct {
+	Builder    ProvenanceBuilder     `json:"builder"`
+	BuildType  string                `json:"buildType"`
+	Invocation ProvenanceInvocation  `json:"invocation"`
+	Materials  []AttestationMaterial `json:"materials"`
+}
+
+// InTotoStatement attests how the manifests were produced: from which
+// materials, by which command, with which result.
+type InTotoStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []AttestationSubject `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Provenance           `json:"predicate"`
+}
+
+// NewAttestation returns a statement without subjects and materials for the
+// given bg-prov command, e.g. km-gen, built by builderID.
+func NewAttestation(builderID, command string) *InTotoStatement {
+	return &InTotoStatement{
+		Type:          InTotoStatementType,
+		Subject:       []AttestationSubject{},
+		PredicateType: SLSAProvenanceType,
+		Predicate: Provenance{
+			Builder:    ProvenanceBuilder{ID: builderID},
+			BuildType:  AttestationBuildType,
+			Invocation: ProvenanceInvocation{Parameters: map[string]string{"command": command}},
+			Materials:  []AttestationMaterial{},
+		},
+	}
+}
+
+// AddSubject adds an output of the build with the SHA256 digest of data.
+func (s *InTotoStatement) AddSubject(name string, data []byte) {
+	s.Subject = append(s.Subject, AttestationSubject{Name: name, Digest: newDigestSet(data)})
+}
+
+// AddMaterial adds an input of the build with the SHA256 digest of data.
+func (s *InTotoStatement) AddMaterial(uri string, data []byte) {
+	s.Predicate.Materials = append(s.Predicate.Materials, AttestationMaterial{URI: uri, Digest: newDigestSet(data)})
+}
+
+// MaterialURI returns the URI a material at path is recorded with, so the
+// statement doesn't depend on the machine it was built on: URLs as they are,
+// files relative to the working directory, or by name if they are outside of
+// it.
+func MaterialURI(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.Base(path)
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// DSSESignature is a signature of a DSSE envelope.
+type DSSESignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// DSSEEnvelope is a signed statement in the Dead Simple Signing Envelope
+// format in-toto uses.
+type DSSEEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []DSSESignature `json:"signatures"`
+}
+
+// DSSEPreAuthEncoding returns the bytes a DSSE signature is computed over.
+func DSSEPreAuthEncoding(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// Sign returns the statement in a DSSE envelope signed with the SHA256
+// digest of its pre-authentication encoding. The key ID is the SHA256 hash
+// of the DER encoded public key.
+func (s *InTotoStatement) Sign(signer crypto.Signer) (*DSSEEnvelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the attestation key: %w", err)
+	}
+	keyID := sha256.Sum256(pub)
+	digest := sha256.Sum256(DSSEPreAuthEncoding(DSSEPayloadType, payload))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign the attestation: %w", err)
+	}
+	return &DSSEEnvelope{
+		PayloadType: DSSEPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []DSSESignature{{KeyID: hex.EncodeToString(keyID[:]), Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// WriteAttestation writes the statement to path, in a signed DSSE envelope if
+// signer is not nil.
+func WriteAttestation(path string, s *InTotoStatement, signer crypto.Signer) error {
+	var v interface{} = s
+	if signer != nil {
+		envelope, err := s.Sign(signer)
+		if err != nil {
+			return err
+		}
+		v = envelope
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,131 @@
+package bg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testAttestation() *InTotoStatement {
+	s := NewAttestation("https://example.com/ci", "bpm-gen")
+	s.AddMaterial("config", []byte(`{"KeyManifest": {}}`))
+	s.AddMaterial("bios.bin", []byte("bios"))
+	s.AddSubject("bpm.bin", []byte("bpm"))
+	return s
+}
+
+func TestAttestationStatement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attest")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bpm.intoto.json")
+	if err := WriteAttestation(path, testAttestation(), nil); err != nil {
+		t.Fatalf("WriteAttestation() failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	var statement struct {
+		Type    string `json:"_type"`
+		Subject []struct {
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+		PredicateType string `json:"predicateType"`
+		Predicate     struct {
+			Builder    struct{ ID string } `json:"builder"`
+			BuildType  string              `json:"buildType"`
+			Invocation struct {
+				Parameters map[string]string `json:"parameters"`
+			} `json:"invocation"`
+			Materials []struct {
+				URI    string            `json:"uri"`
+				Digest map[string]string `json:"digest"`
+			} `json:"materials"`
+		} `json:"predicate"`
+	}
+	if err := json.Unmarshal(data, &statement); err != nil {
+		t.Fatalf("the attestation is no valid JSON: %v", err)
+	}
+	if statement.Type != InTotoStatementType || statement.PredicateType != SLSAProvenanceType {
+		t.Errorf("the attestation has the type %q and predicate type %q", statement.Type, statement.PredicateType)
+	}
+	bpmSum := sha256.Sum256([]byte("bpm"))
+	if len(statement.Subject) != 1 || statement.Subject[0].Name != "bpm.bin" || statement.Subject[0].Digest["sha256"] != hex.EncodeToString(bpmSum[:]) {
+		t.Errorf("the attestation has the subjects %+v", statement.Subject)
+	}
+	p := statement.Predicate
+	if p.Builder.ID != "https://example.com/ci" || p.BuildType != AttestationBuildType || p.Invocation.Parameters["command"] != "bpm-gen" {
+		t.Errorf("the attestation has the predicate %+v", p)
+	}
+	if len(p.Materials) != 2 || p.Materials[1].URI != "bios.bin" || len(p.Materials[1].Digest["sha256"]) != 64 {
+		t.Errorf("the attestation has the materials %+v", p.Materials)
+	}
+}
+
+func TestAttestationSign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	statement := testAttestation()
+	envelope, err := statement.Sign(key)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if envelope.PayloadType != DSSEPayloadType || len(envelope.Signatures) != 1 {
+		t.Fatalf("Sign() returned the envelope %+v", envelope)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		t.Fatalf("the payload is not base64 encoded: %v", err)
+	}
+	var decoded InTotoStatement
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.Subject[0].Name != "bpm.bin" {
+		t.Errorf("the payload is not the statement: %s", payload)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	if err != nil {
+		t.Fatalf("the signature is not base64 encoded: %v", err)
+	}
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+		t.Fatalf("the signature is no ASN.1 ECDSA signature: %v", err)
+	}
+	digest := sha256.Sum256(DSSEPreAuthEncoding(envelope.PayloadType, payload))
+	if !ecdsa.Verify(&key.PublicKey, digest[:], rs.R, rs.S) {
+		t.Errorf("the signature doesn't verify")
+	}
+}
+
+func TestMaterialURI(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() failed: %v", err)
+	}
+	for path, expected := range map[string]string{
+		"https://example.com/bg.json":                   "https://example.com/bg.json",
+		filepath.Join(wd, "testdata", "coreboot.bin"):   "testdata/coreboot.bin",
+		filepath.Join("testdata", "coreboot.bin"):       "testdata/coreboot.bin",
+		filepath.Join(filepath.Dir(wd), "bg-prov.json"): "bg-prov.json",
+	} {
+		if uri := MaterialURI(path); uri != expected {
+			t.Errorf("MaterialURI(%q) returned %q, expected %q", path, uri, expected)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package bg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/coreboot/cbfs"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// BPMMatchResult is the outcome of matching the BPM of a BIOS image against
+// the intended BPM.
+type BPMMatchResult int
+
+const (
+	// BPMMatches means the image contains exactly the intended BPM.
+	BPMMatches BPMMatchResult = iota
+	// BPMNotPresent means neither the FIT nor the CBFS of the image
+	// references a BPM.
+	BPMNotPresent
+	// BPMDiffers means the image contains a BPM, but a different one.
+	BPMDiffers
+)
+
+func (r BPMMatchResult) String() string {
+	switch r {
+	case BPMMatches:
+		return "match"
+	case BPMNotPresent:
+		return "not present"
+	case BPMDiffers:
+		return "present but different"
+	}
+	return fmt.Sprintf("BPMMatchResult(%d)", int(r))
+}
+
+// MarshalJSON encodes the result as its string.
+func (r BPMMatchResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// BPMMatch is the result of MatchBPM. The sizes and SHA256 hashes are those
+// of the manifests without the padding after them.
+type BPMMatch struct {
+	Result         BPMMatchResult `json:"result"`
+	ExpectedSize   int            `json:"expected_size"`
+	ExpectedSHA256 string         `json:"expected_sha256"`
+	ImageSize      int            `json:"image_size,omitempty"`
+	ImageSHA256    string         `json:"image_sha256,omitempty"`
+	// Detail locates the first difference or tells why no BPM was found.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Matches returns true if the image contains exactly the intended BPM.
+func (m *BPMMatch) Matches() bool {
+	return m.Result == BPMMatches
+}
+
+// MatchBPM extracts the BPM from a BIOS image, like ParseFITEntries from the
+// FIT or the CBFS of a coreboot image, and compares it byte by byte with the
+// intended BPM, e.g. to verify an image after stitching. The padding after
+// the manifests is ignored. An error is returned if the intended BPM can't
+// be parsed; a BPM in the image which doesn't parse is reported as different.
+func MatchBPM(image, expected []byte) (*BPMMatch, error) {
+	return DefaultParseOptions().MatchBPM(image, expected)
+}
+
+// MatchBPM is MatchBPM with the options o instead of the DefaultParseOptions.
+func (o ParseOptions) MatchBPM(image, expected []byte) (*BPMMatch, error) {
+	expected, err := o.trimBPM(expected)
+	if err != nil {
+		return nil, fmt.Errorf("intended BPM: %w", err)
+	}
+	m := &BPMMatch{
+		ExpectedSize:   len(expected),
+		ExpectedSHA256: sha256Hex(expected),
+	}
+	data, err := o.imageBPM(image)
+	if err != nil {
+		m.Result = BPMNotPresent
+		m.Detail = err.Error()
+		return m, nil
+	}
+	if trimmed, err := o.trimBPM(data); err == nil {
+		data = trimmed
+	} else {
+		m.Detail = fmt.Sprintf("the BPM of the image doesn't parse: %v", err)
+	}
+	m.ImageSize = len(data)
+	m.ImageSHA256 = sha256Hex(data)
+	if off := firstDifference(expected, data); off >= 0 {
+		m.Result = BPMDiffers
+		if m.Detail == "" {
+			m.Detail = fmt.Sprintf("first difference at offset 0x%x", off)
+		}
+	}
+	return m, nil
+}
+
+// errBPMNotPresent is returned by imageBPM if the image references no BPM.
+var errBPMNotPresent = errors.New("the image references no BPM")
+
+// imageBPM returns the BPM the FIT of the image references, or for coreboot
+// images without such FIT entry the BPM CBFS file.
+func (o ParseOptions) imageBPM(image []byte) ([]byte, error) {
+	entries, fitErr := tools.ExtractFit(image)
+	for _, entry := range entries {
+		if entry.Type() != tools.BootPolicyManifest {
+			continue
+		}
+		off, err := tools.CalcImageOffsetWithLayout(image, entry.Address, o.Layout)
+		if err != nil {
+			return nil, err
+		}
+		if off >= uint64(len(image)) || uint64(entry.Size()) > uint64(len(image))-off {
+			return nil, fmt.Errorf("FIT BPM entry at 0x%x: %w", entry.Address, ErrOutOfBounds)
+		}
+		return image[off : off+uint64(entry.Size())], nil
+	}
+	if cbfs.IsCorebootImage(image) {
+		f, err := cbfs.Lookup(image, CBFSBPMName)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errBPMNotPresent, err)
+		}
+		return f.Data, nil
+	}
+	if fitErr != nil {
+		return nil, fmt.Errorf("%w: %v", errBPMNotPresent, fitErr)
+	}
+	return nil, fmt.Errorf("%w: the FIT has no BPM entry", errBPMNotPresent)
+}
+
+// trimBPM validates the BPM structure and returns the BPM without the padding
+// after it.
+func (o ParseOptions) trimBPM(data []byte) ([]byte, error) {
+	c, err := o.BPMCoverage(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:c.Trailing.Offset], nil
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// WriteJSON writes the match result as indented JSON.
+func (m *BPMMatch) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// PrettyPrint writes the result and the sizes and hashes of both BPMs.
+func (m *BPMMatch) PrettyPrint(w io.Writer) error {
+	fmt.Fprintf(w, "Intended BPM: %d bytes, SHA256 %s\n", m.ExpectedSize, m.ExpectedSHA256)
+	if m.Result != BPMNotPresent {
+		fmt.Fprintf(w, "Image BPM:    %d bytes, SHA256 %s\n", m.ImageSize, m.ImageSHA256)
+	}
+	if m.Detail != "" {
+		_, err := fmt.Fprintf(w, "Result: %s (%s)\n", m.Result, m.Detail)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Result: %s\n", m.Result)
+	return err
+}
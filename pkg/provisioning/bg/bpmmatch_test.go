@@ -0,0 +1,83 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMatchBPM(t *testing.T) {
+	image, err := ioutil.ReadFile(testCorebootPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, _, _, err := ParseFITEntries(image)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() failed: %v", err)
+	}
+	bpm, err = DefaultParseOptions().trimBPM(bpm)
+	if err != nil {
+		t.Fatalf("DefaultParseOptions().trimBPM() failed: %v", err)
+	}
+
+	// the padding of an exported BPM doesn't matter
+	padded := append(append([]byte{}, bpm...), bytes.Repeat([]byte{0xff}, 64)...)
+	m, err := MatchBPM(image, padded)
+	if err != nil {
+		t.Fatalf("MatchBPM() failed: %v", err)
+	}
+	if !m.Matches() || m.ImageSHA256 != m.ExpectedSHA256 || m.ImageSize != len(bpm) {
+		t.Errorf("MatchBPM() of the BPM of the image returned %+v", m)
+	}
+
+	// a single modified byte is detected, in the intended BPM and in the image
+	last := len(bpm) - 1
+	modified := append([]byte{}, bpm...)
+	modified[last] ^= 0x01
+	m, err = MatchBPM(image, modified)
+	if err != nil {
+		t.Fatalf("MatchBPM() failed: %v", err)
+	}
+	if m.Result != BPMDiffers || m.ImageSHA256 == m.ExpectedSHA256 || !strings.Contains(m.Detail, "offset 0x") {
+		t.Errorf("MatchBPM() of a modified BPM returned %+v", m)
+	}
+	modifiedImage := append([]byte{}, image...)
+	modifiedImage[bytes.Index(image, bpm)+last] ^= 0x01
+	if m, err = MatchBPM(modifiedImage, bpm); err != nil || m.Result != BPMDiffers {
+		t.Errorf("MatchBPM() of a modified image returned %+v, %v", m, err)
+	}
+
+	// an image without BPM isn't reported as a different BPM
+	noBPM := append([]byte{}, image...)
+	noBPM[bytes.Index(image, []byte(CBFSBPMName))] = 'x'
+	m, err = MatchBPM(noBPM, bpm)
+	if err != nil {
+		t.Fatalf("MatchBPM() failed: %v", err)
+	}
+	if m.Result != BPMNotPresent || m.ImageSHA256 != "" {
+		t.Errorf("MatchBPM() of an image without BPM returned %+v", m)
+	}
+
+	for name, invalid := range map[string][]byte{
+		"text":      []byte("no BPM"),
+		"erased":    bytes.Repeat([]byte{0xff}, len(bpm)),
+		"truncated": bpm[:16],
+	} {
+		if _, err := MatchBPM(image, invalid); err == nil {
+			t.Errorf("MatchBPM() accepted a %s intended BPM", name)
+		}
+	}
+
+	// an erased BPM in the image is reported as different, not as a match
+	// of garbage
+	erasedImage := append([]byte{}, image...)
+	copy(erasedImage[bytes.Index(image, bpm):], bytes.Repeat([]byte{0xff}, len(bpm)))
+	m, err = MatchBPM(erasedImage, bpm)
+	if err != nil {
+		t.Fatalf("MatchBPM() failed: %v", err)
+	}
+	if m.Result != BPMDiffers || !strings.Contains(m.Detail, "doesn't parse") {
+		t.Errorf("MatchBPM() of an image with an erased BPM returned %+v", m)
+	}
+}
@@ -0,0 +1,53 @@
+package bg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// cHeaderBytesPerLine is the number of bytes per line of the array written by
+// WriteCHeader, as xxd -i does.
+const cHeaderBytesPerLine = 12
+
+var cIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WriteCHeader writes data as a C header defining the array
+// "static const uint8_t name[]" and its length as the macro NAME_LEN, for
+// embedding a manifest into a bootloader build. name must be a C identifier.
+func WriteCHeader(w io.Writer, name string, data []byte) error {
+	if !cIdentifier.MatchString(name) {
+		return fmt.Errorf("%q is not a valid C identifier", name)
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("C doesn't allow empty arrays")
+	}
+	guard := strings.ToUpper(name) + "_H"
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "/* Generated by bg-prov, do not edit. */\n")
+	fmt.Fprintf(bw, "#ifndef %s\n#define %s\n\n", guard, guard)
+	fmt.Fprintf(bw, "#include <stdint.h>\n\n")
+	fmt.Fprintf(bw, "#define %s_LEN %d\n\n", strings.ToUpper(name), len(data))
+	fmt.Fprintf(bw, "static const uint8_t %s[] = {", name)
+	for idx, b := range data {
+		if idx%cHeaderBytesPerLine == 0 {
+			fmt.Fprintf(bw, "\n\t")
+		} else {
+			fmt.Fprintf(bw, " ")
+		}
+		fmt.Fprintf(bw, "0x%02x,", b)
+	}
+	fmt.Fprintf(bw, "\n};\n\n#endif /* %s */\n", guard)
+	return bw.Flush()
+}
+
+// WriteCHeaderFile atomically writes data as C header to path, see
+// WriteCHeader.
+func WriteCHeaderFile(path, name string, data []byte) error {
+	return WriteFileAtomicFunc(path, 0644, func(f *os.File) error {
+		return WriteCHeader(f, name, data)
+	})
+}
@@ -0,0 +1,88 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var cHeaderByte = regexp.MustCompile(`0x([0-9a-f]{2}),`)
+
+// parseCHeader returns the bytes of the array and the length macro of a
+// header written by WriteCHeader.
+func parseCHeader(t *testing.T, header []byte, name string) ([]byte, int) {
+	m := regexp.MustCompile(`#define ` + regexp.QuoteMeta(name) + `_LEN (\d+)`).FindSubmatch(header)
+	if m == nil {
+		t.Fatalf("no length macro in header:\n%s", header)
+	}
+	length, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		t.Fatalf("invalid length macro %s: %v", m[1], err)
+	}
+	var data []byte
+	for _, b := range cHeaderByte.FindAllSubmatch(header, -1) {
+		v, err := strconv.ParseUint(string(b[1]), 16, 8)
+		if err != nil {
+			t.Fatalf("invalid byte %s: %v", b[1], err)
+		}
+		data = append(data, byte(v))
+	}
+	return data, length
+}
+
+func TestWriteCHeader(t *testing.T) {
+	data, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	var header bytes.Buffer
+	if err := WriteCHeader(&header, "km", data); err != nil {
+		t.Fatalf("WriteCHeader() failed: %v", err)
+	}
+	parsed, length := parseCHeader(t, header.Bytes(), "KM")
+	if length != len(data) || !bytes.Equal(parsed, data) {
+		t.Errorf("header holds %d bytes with KM_LEN %d, expected the %d bytes of the KM", len(parsed), length, len(data))
+	}
+
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler found, skipping the syntax check")
+	}
+	dir, err := ioutil.TempDir("", "cheader")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "km.h"), header.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	src := filepath.Join(dir, "km.c")
+	program := "#include \"km.h\"\n#include \"km.h\"\n_Static_assert(sizeof(km) == KM_LEN, \"length\");\nint main(void) { return km[0]; }\n"
+	if err := ioutil.WriteFile(src, []byte(program), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if out, err := exec.Command(cc, "-std=c11", "-Wall", "-Werror", "-fsyntax-only", src).CombinedOutput(); err != nil {
+		t.Errorf("the header doesn't compile: %v\n%s", err, out)
+	}
+}
+
+func TestWriteCHeaderInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		name string
+		data []byte
+	}{
+		"not-an-identifier": {"1st-km", []byte{1}},
+		"empty":             {"km", nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := WriteCHeader(ioutil.Discard, tc.name, tc.data); err == nil {
+				t.Errorf("WriteCHeader() accepted %q with %d bytes", tc.name, len(tc.data))
+			}
+		})
+	}
+}
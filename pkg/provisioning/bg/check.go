@@ -0,0 +1,92 @@
+package bg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CheckResult is the machine-readable result of a single verification.
+type CheckResult struct {
+	Check    string `json:"check"`
+	Pass     bool   `json:"pass"`
+	Detail   string `json:"detail,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	// Skipped is set for checks which weren't run, they pass.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// CheckResults collects the results of the checks run by a command. Pass is
+// only true if all checks passed.
+type CheckResults struct {
+	Pass   bool          `json:"pass"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// NewCheckResult returns a result which passes if err is nil. The error or
+// the passDetail becomes the detail of the result.
+func NewCheckResult(check string, err error, passDetail string) CheckResult {
+	if err != nil {
+		return CheckResult{Check: check, Detail: err.Error()}
+	}
+	return CheckResult{Check: check, Pass: true, Detail: passDetail}
+}
+
+// NewCheckResults returns the results of the given checks.
+func NewCheckResults(checks ...CheckResult) *CheckResults {
+	r := &CheckResults{Pass: true, Checks: []CheckResult{}}
+	for _, c := range checks {
+		r.Add(c)
+	}
+	return r
+}
+
+// Add appends the result of a check.
+func (r *CheckResults) Add(c CheckResult) {
+	r.Checks = append(r.Checks, c)
+	r.Pass = r.Pass && c.Pass
+}
+
+// Failed returns the results of the checks which didn't pass.
+func (r *CheckResults) Failed() []CheckResult {
+	var failed []CheckResult
+	for _, c := range r.Checks {
+		if !c.Pass {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// WriteJSON writes the results as indented JSON.
+func (r *CheckResults) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// PrettyPrint writes one line per check, OK, SKIP or FAIL, its name and
+// detail, or the expected and actual value of a failed check without detail.
+func (r *CheckResults) PrettyPrint(w io.Writer) error {
+	for _, c := range r.Checks {
+		status := "OK  "
+		switch {
+		case c.Skipped:
+			status = "SKIP"
+		case !c.Pass:
+			status = "FAIL"
+		}
+		line := fmt.Sprintf("%s   %s", status, c.Check)
+		switch {
+		case c.Detail != "":
+			line += ": " + c.Detail
+		case !c.Pass && !c.Skipped && c.Expected != "":
+			line += fmt.Sprintf(": expected %s, got %s", c.Expected, c.Actual)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
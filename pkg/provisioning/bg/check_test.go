@@ -0,0 +1,75 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckResultsJSON(t *testing.T) {
+	results := NewCheckResults(
+		NewCheckResult("km-signature", nil, "RSASSA"),
+		CheckResult{Check: "pcr0", Pass: false, Expected: "aa", Actual: "bb"},
+	)
+	if results.Pass {
+		t.Errorf("Pass is true although a check failed")
+	}
+	if failed := results.Failed(); len(failed) != 1 || failed[0].Check != "pcr0" {
+		t.Errorf("Failed() returned %v, expected the pcr0 check", failed)
+	}
+
+	var buf bytes.Buffer
+	if err := results.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+	expected := map[string]interface{}{
+		"pass": false,
+		"checks": []interface{}{
+			map[string]interface{}{"check": "km-signature", "pass": true, "detail": "RSASSA"},
+			map[string]interface{}{"check": "pcr0", "pass": false, "expected": "aa", "actual": "bb"},
+		},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("WriteJSON() wrote %s, expected %v", buf.String(), expected)
+	}
+}
+
+func TestCheckResultsEmptyAndError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewCheckResults().WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	if got := buf.String(); got != "{\n  \"pass\": true,\n  \"checks\": []\n}\n" {
+		t.Errorf("WriteJSON() of no checks wrote %q", got)
+	}
+
+	c := NewCheckResult("km-signature", errors.New("invalid signature"), "RSASSA")
+	if c.Pass || c.Detail != "invalid signature" {
+		t.Errorf("NewCheckResult() with an error returned %+v", c)
+	}
+}
+
+func TestLiveMeasurementsCheckResults(t *testing.T) {
+	m := &LiveMeasurements{
+		PCR0:             []byte{1, 2},
+		ExpectedPCR0:     []byte{1, 2},
+		PCR7:             []byte{0, 0},
+		AuthorityMeasure: true,
+	}
+	results := m.CheckResults()
+	if len(results.Checks) != 2 || results.Checks[0].Check != "pcr0" || results.Checks[1].Check != "pcr7-authority" {
+		t.Fatalf("CheckResults() returned %+v", results.Checks)
+	}
+	if !results.Checks[0].Pass || results.Checks[0].Expected != "0102" || results.Checks[0].Actual != "0102" {
+		t.Errorf("pcr0 check is %+v", results.Checks[0])
+	}
+	if results.Checks[1].Pass || results.Pass {
+		t.Errorf("pcr7-authority check passed although PCR7 is not extended")
+	}
+}
@@ -0,0 +1,218 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// CompareResult is the outcome of comparing two build outputs.
+type CompareResult int
+
+const (
+	// CompareIdentical means all BootGuard relevant bytes are identical.
+	CompareIdentical CompareResult = iota
+	// CompareSignaturesDiffer means the content is identical, but the
+	// signatures differ, e.g. due to non-deterministic signing.
+	CompareSignaturesDiffer
+	// CompareContentDiffers means the signed or unsigned content differs.
+	CompareContentDiffers
+)
+
+func (r CompareResult) String() string {
+	switch r {
+	case CompareIdentical:
+		return "identical"
+	case CompareSignaturesDiffer:
+		return "content identical, signatures differ"
+	case CompareContentDiffers:
+		return "content differs"
+	}
+	return fmt.Sprintf("CompareResult(%d)", int(r))
+}
+
+// MarshalJSON encodes the result as its string.
+func (r CompareResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// ComparedStructure is the comparison of a single KM, BPM or ACM.
+type ComparedStructure struct {
+	Name   string        `json:"name"`
+	Result CompareResult `json:"result"`
+	// Detail locates the first difference.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Comparison is the comparison of two KMs, BPMs or BIOS images. Result is
+// the worst result of all structures.
+type Comparison struct {
+	Structures []ComparedStructure `json:"structures"`
+	Result     CompareResult       `json:"result"`
+}
+
+func (c *Comparison) add(s ComparedStructure) {
+	c.Structures = append(c.Structures, s)
+	if s.Result > c.Result {
+		c.Result = s.Result
+	}
+}
+
+// CompareManifests compares two KM or BPM binaries, or two BIOS images,
+// ignoring the signature bytes and the padding after the manifests. For BIOS
+// images the KM, BPM and ACM referenced by the FIT are compared, the IBB is
+// covered by the digests of the BPM.
+func CompareManifests(a, b []byte) (*Comparison, error) {
+	return DefaultParseOptions().CompareManifests(a, b)
+}
+
+// CompareManifests is CompareManifests with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) CompareManifests(a, b []byte) (*Comparison, error) {
+	c := &Comparison{}
+	typeA, typeB := manifestType(a), manifestType(b)
+	if typeA != "" || typeB != "" {
+		if typeA != typeB {
+			return nil, fmt.Errorf("can't compare a %s with a %s", compareTypeName(typeA), compareTypeName(typeB))
+		}
+		s, err := o.compareManifest(typeA, a, b)
+		if err != nil {
+			return nil, err
+		}
+		c.add(s)
+		return c, nil
+	}
+
+	bpmA, kmA, acmA, err := o.ParseFITEntries(a)
+	if err != nil {
+		return nil, fmt.Errorf("first image: %w", err)
+	}
+	bpmB, kmB, acmB, err := o.ParseFITEntries(b)
+	if err != nil {
+		return nil, fmt.Errorf("second image: %w", err)
+	}
+	for _, m := range []struct {
+		name string
+		a, b []byte
+	}{{"KM", kmA, kmB}, {"BPM", bpmA, bpmB}} {
+		s, err := o.compareManifest(m.name, m.a, m.b)
+		if err != nil {
+			return nil, err
+		}
+		c.add(s)
+	}
+	// the ACM is signed by Intel, so its signature can't differ on its own
+	acm := ComparedStructure{Name: "ACM", Result: CompareIdentical}
+	if off := firstDifference(acmA, acmB); off >= 0 {
+		acm.Result = CompareContentDiffers
+		acm.Detail = fmt.Sprintf("first difference at offset 0x%x", off)
+	}
+	c.add(acm)
+	return c, nil
+}
+
+// manifestType returns "KM" or "BPM" if data starts with the structure ID of
+// a KM or BPM, and "" otherwise, e.g. for a BIOS image.
+func manifestType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte(key.StructureIDManifest)):
+		return "KM"
+	case bytes.HasPrefix(data, []byte(bootpolicy.StructureIDBPMH)):
+		return "BPM"
+	}
+	return ""
+}
+
+func compareTypeName(t string) string {
+	if t == "" {
+		return "BIOS image"
+	}
+	return t
+}
+
+// compareManifest compares the bytes of two KMs or BPMs with the signature
+// data masked out. If only one of them parses, their content differs.
+func (o ParseOptions) compareManifest(name string, a, b []byte) (ComparedStructure, error) {
+	s := ComparedStructure{Name: name, Result: CompareIdentical}
+	contentA, sigA, errA := o.manifestContent(name, a)
+	contentB, sigB, errB := o.manifestContent(name, b)
+	if errA != nil && errB != nil {
+		return s, fmt.Errorf("first %s: %w", name, errA)
+	}
+	if errA != nil {
+		s.Result = CompareContentDiffers
+		s.Detail = fmt.Sprintf("first %s doesn't parse: %v", name, errA)
+	} else if errB != nil {
+		s.Result = CompareContentDiffers
+		s.Detail = fmt.Sprintf("second %s doesn't parse: %v", name, errB)
+	} else if off := firstDifference(contentA, contentB); off >= 0 {
+		s.Result = CompareContentDiffers
+		s.Detail = fmt.Sprintf("first difference at offset 0x%x", off)
+	} else if !bytes.Equal(sigA, sigB) {
+		s.Result = CompareSignaturesDiffer
+	}
+	return s, nil
+}
+
+// manifestContent returns the manifest without trailing padding and with the
+// signature data zeroed, and the signature data.
+func (o ParseOptions) manifestContent(name string, data []byte) ([]byte, []byte, error) {
+	var c *SignatureCoverage
+	var err error
+	switch name {
+	case "KM":
+		c, err = o.KMCoverage(data)
+	case "BPM":
+		c, err = o.BPMCoverage(data)
+	default:
+		return nil, nil, fmt.Errorf("unknown manifest type %s", name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	content := append([]byte{}, data[:c.Trailing.Offset]...)
+	sig := data[c.Signature.Offset:c.Signature.End()]
+	for i := c.Signature.Offset; i < c.Signature.End(); i++ {
+		content[i] = 0
+	}
+	return content, sig, nil
+}
+
+// firstDifference returns the offset of the first differing byte of a and
+// b, or -1 if they are identical.
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return len(a)
+		}
+		return len(b)
+	}
+	return -1
+}
+
+// WriteJSON writes the comparison as indented JSON.
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// PrettyPrint writes the result of every structure and the overall result.
+func (c *Comparison) PrettyPrint(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, s := range c.Structures {
+		fmt.Fprintf(tw, "%s:\t%s\t%s\n", s.Name, s.Result, s.Detail)
+	}
+	fmt.Fprintf(tw, "Result:\t%s\t\n", c.Result)
+	return tw.Flush()
+}
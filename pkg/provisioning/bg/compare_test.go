@@ -0,0 +1,57 @@
+package bg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCompareManifests(t *testing.T) {
+	signed := signedTestKM(t)
+	c, err := KMCoverage(signed)
+	if err != nil {
+		t.Fatalf("KMCoverage() failed: %v", err)
+	}
+	padded, err := PadManifest(signed, uint32(len(signed)+100), 0xff)
+	if err != nil {
+		t.Fatalf("PadManifest() failed: %v", err)
+	}
+	resigned := append([]byte{}, signed...)
+	resigned[c.Signature.Offset] ^= 0xff
+	changed := append([]byte{}, signed...)
+	changed[19] ^= 0xff // KMID
+	// the last signed byte is the high byte of the hash count, the KM is truncated
+	truncated := append([]byte{}, signed...)
+	truncated[c.Signed.End()-1] ^= 0xff
+
+	for _, tc := range []struct {
+		name     string
+		b        []byte
+		expected CompareResult
+		detail   string
+	}{
+		{"padded", padded, CompareIdentical, ""},
+		{"resigned", resigned, CompareSignaturesDiffer, ""},
+		{"changed", changed, CompareContentDiffers, "first difference at offset 0x13"},
+		{"truncated", truncated, CompareContentDiffers, "second KM doesn't parse"},
+	} {
+		cmp, err := CompareManifests(signed, tc.b)
+		if err != nil {
+			t.Fatalf("%s: CompareManifests() failed: %v", tc.name, err)
+		}
+		if cmp.Result != tc.expected || !strings.HasPrefix(cmp.Structures[0].Detail, tc.detail) {
+			t.Errorf("%s: CompareManifests() returned %q (%s), expected %q", tc.name, cmp.Result, cmp.Structures[0].Detail, tc.expected)
+		}
+	}
+	if _, err := CompareManifests(truncated, truncated); err == nil {
+		t.Errorf("CompareManifests() of two truncated KMs succeeded")
+	}
+
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if _, err := CompareManifests(signed, bpm); err == nil {
+		t.Errorf("CompareManifests() of a KM and a BPM succeeded")
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
@@ -38,21 +41,113 @@ type KeyHash struct {
 type BootGuardOptions struct {
 	BootPolicyManifest bootpolicy.Manifest
 	KeyManifest        key.Manifest
+	// IDs are optional identifiers applied to both the KM and the BPM.
+	IDs *ManifestIDs `json:",omitempty"`
+	// OBB are the optional regions of the OEM boot block whose digest is
+	// stored as OBB hash of the IBBS element.
+	OBB OBBRegions `json:",omitempty"`
+	// TODO lists the fields of a config migrated from BootGuard 1.0 which
+	// need manual attention, see MigrateConfig.
+	TODO []string `json:",omitempty"`
 }
 
-// ParseConfig parses a boot guard option json file
+// ConfigAuthHeaderEnv is the environment variable holding the value of the
+// Authorization header sent when fetching the config from an https URL.
+const ConfigAuthHeaderEnv = "BG_PROV_CONFIG_AUTH"
+
+// ConfigFetchTimeout limits the time to fetch the config from a URL.
+const ConfigFetchTimeout = 30 * time.Second
+
+// MaxConfigSize limits the size of a config fetched from a URL.
+const MaxConfigSize int64 = 1 << 20
+
+// configTransport is the transport of the requests fetching configs, the
+// default transport if nil.
+var configTransport http.RoundTripper
+
+// ParseConfig parses a boot guard option json file. The config is fetched if
+// filepath is an http(s) URL.
 func ParseConfig(filepath string) (*BootGuardOptions, error) {
-	var bgo BootGuardOptions
-	data, err := ioutil.ReadFile(filepath)
+	data, err := readConfig(filepath)
 	if err != nil {
 		return nil, err
 	}
-	if err = json.Unmarshal(data, &bgo); err != nil {
+	return parseConfigData(filepath, data)
+}
+
+func parseConfigData(filepath string, data []byte) (*BootGuardOptions, error) {
+	var bgo BootGuardOptions
+	if strings.HasSuffix(strings.ToLower(filepath), ".xml") {
+		return ReadConfigXML(bytes.NewReader(data))
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("config %s is not valid JSON", filepath)
+	}
+	if err := json.Unmarshal(data, &bgo); err != nil {
 		return nil, err
 	}
+	if err := ApplyManifestIDs(&bgo); err != nil {
+		return nil, fmt.Errorf("config %s: %w", filepath, err)
+	}
 	return &bgo, nil
 }
 
+// CanonicalConfig returns the config in canonical form: sorted keys indented
+// by two spaces. Formatting a canonical config again yields the same bytes.
+func CanonicalConfig(bgo *BootGuardOptions) ([]byte, error) {
+	cfg, err := json.Marshal(bgo)
+	if err != nil {
+		return nil, err
+	}
+	return pretty.PrettyOptions(cfg, &pretty.Options{Width: 80, Indent: "  ", SortKeys: true}), nil
+}
+
+// FormatConfig parses a config and returns it in canonical form. Unknown
+// keys are rejected instead of being dropped silently.
+func FormatConfig(data []byte) ([]byte, error) {
+	var bgo BootGuardOptions
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&bgo); err != nil {
+		return nil, fmt.Errorf("unable to parse config: %w", err)
+	}
+	return CanonicalConfig(&bgo)
+}
+
+func readConfig(path string) ([]byte, error) {
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		return ioutil.ReadFile(path)
+	}
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL %s: %w", path, err)
+	}
+	if auth := os.Getenv(ConfigAuthHeaderEnv); auth != "" {
+		// the credentials must not be sent in clear text
+		if req.URL.Scheme != "https" {
+			return nil, fmt.Errorf("refusing to send the %s credentials to %s without TLS, use an https URL", ConfigAuthHeaderEnv, path)
+		}
+		req.Header.Set("Authorization", auth)
+	}
+	client := &http.Client{Timeout: ConfigFetchTimeout, Transport: configTransport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch the config from %s: %s", path, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the config from %s: %w", path, err)
+	}
+	if int64(len(data)) > MaxConfigSize {
+		return nil, fmt.Errorf("the config from %s exceeds the maximum size of %d bytes", path, MaxConfigSize)
+	}
+	return data, nil
+}
+
 func setBPMHeader(bgo *BootGuardOptions, bpm *bootpolicy.Manifest) (*bootpolicy.BPMH, error) {
 	header := bootpolicy.NewBPMH()
 	if err := defaults.Set(header); err != nil {
@@ -67,15 +162,15 @@ func setBPMHeader(bgo *BootGuardOptions, bpm *bootpolicy.Manifest) (*bootpolicy.
 	return header, nil
 }
 
-func getIBBSegment(ibbs []bootpolicy.IBBSegment, image []byte) ([][]byte, error) {
+func getIBBSegment(ibbs []bootpolicy.IBBSegment, image []byte, layout *tools.FlashLayout) ([][]byte, error) {
 	reader := bytes.NewReader(image)
 	ibbSegments := make([][]byte, len(ibbs))
 	for idx, ibb := range ibbs {
-		if ibb.Flags&(1<<0) != 0 {
+		if !ibb.IsMeasured() {
 			continue
 		}
 		//offset := uint64(ibb.BaseOffset())
-		addr, err := tools.CalcImageOffset(image, uint64(ibb.Base))
+		addr, err := tools.CalcImageOffsetWithLayout(image, uint64(ibb.Base), layout)
 		if err != nil {
 			return nil, err
 		}
@@ -93,12 +188,12 @@ func getIBBSegment(ibbs []bootpolicy.IBBSegment, image []byte) ([][]byte, error)
 	return ibbSegments, nil
 }
 
-func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Algorithm) ([]byte, error) {
+func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Algorithm, layout *tools.FlashLayout) ([]byte, error) {
 	var hash []byte
 	switch algo {
 	case manifest.AlgSHA1:
 		h := sha1.New()
-		segments, err := getIBBSegment(ibbs, image)
+		segments, err := getIBBSegment(ibbs, image, layout)
 		if err != nil {
 			return nil, err
 		}
@@ -111,7 +206,7 @@ func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Alg
 		hash = h.Sum(nil)
 	case manifest.AlgSHA256:
 		h := sha256.New()
-		segments, err := getIBBSegment(ibbs, image)
+		segments, err := getIBBSegment(ibbs, image, layout)
 		if err != nil {
 			return nil, err
 		}
@@ -124,7 +219,7 @@ func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Alg
 		hash = h.Sum(nil)
 	case manifest.AlgSHA384:
 		h := sha512.New384()
-		segments, err := getIBBSegment(ibbs, image)
+		segments, err := getIBBSegment(ibbs, image, layout)
 		if err != nil {
 			return nil, err
 		}
@@ -137,7 +232,7 @@ func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Alg
 		hash = h.Sum(nil)
 	case manifest.AlgSHA512:
 		h := sha512.New512_256()
-		segments, err := getIBBSegment(ibbs, image)
+		segments, err := getIBBSegment(ibbs, image, layout)
 		if err != nil {
 			return nil, err
 		}
@@ -150,7 +245,7 @@ func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Alg
 		hash = h.Sum(nil)
 	case manifest.AlgSM3_256:
 		h := sm3.New()
-		segments, err := getIBBSegment(ibbs, image)
+		segments, err := getIBBSegment(ibbs, image, layout)
 		if err != nil {
 			return nil, err
 		}
@@ -169,17 +264,170 @@ func getIBBsDigest(ibbs []bootpolicy.IBBSegment, image []byte, algo manifest.Alg
 	return hash, nil
 }
 
-func setIBBSegment(bgo *BootGuardOptions, image []byte) (*bootpolicy.SE, error) {
-	for iterator, item := range bgo.BootPolicyManifest.SE[0].DigestList.List {
-		d, err := getIBBsDigest(bgo.BootPolicyManifest.SE[0].IBBSegments, image, item.HashAlg)
-		if err != nil {
+// AddressField describes an address field of the BPM with its hardware
+// alignment requirement and its bit width.
+type AddressField struct {
+	Name      string
+	Value     uint64
+	Alignment uint64
+	Width     uint
+}
+
+// Validate checks the alignment and the bit width of the address.
+func (f AddressField) Validate() error {
+	if f.Width < 64 && f.Value>>f.Width != 0 {
+		return fmt.Errorf("%s 0x%x exceeds the %d bit width of the field", f.Name, f.Value, f.Width)
+	}
+	if f.Alignment != 0 && f.Value%f.Alignment != 0 {
+		return fmt.Errorf("%s 0x%x is not aligned to 0x%x", f.Name, f.Value, f.Alignment)
+	}
+	return nil
+}
+
+// SEAddressFields returns the address fields of an SE element with their requirements.
+func SEAddressFields(se *bootpolicy.SE) []AddressField {
+	return []AddressField{
+		{Name: "MCHBAR", Value: se.IBBMCHBAR, Alignment: 0x10000, Width: 39},
+		{Name: "VT-d BAR", Value: se.VTdBAR, Alignment: 0x1000, Width: 39},
+		{Name: "DMA protection 0 base", Value: uint64(se.DMAProtBase0), Alignment: 0x1000, Width: 32},
+		{Name: "DMA protection 0 limit", Value: uint64(se.DMAProtLimit0), Alignment: 0x1000, Width: 32},
+		{Name: "DMA protection 1 base", Value: se.DMAProtBase1, Alignment: 0x1000, Width: 64},
+		{Name: "DMA protection 1 limit", Value: se.DMAProtLimit1, Alignment: 0x1000, Width: 64},
+	}
+}
+
+// ValidateSEAddresses checks the alignment of all address fields of an SE element
+// and that the DMA protected ranges are not inverted.
+func ValidateSEAddresses(se *bootpolicy.SE) error {
+	for _, field := range SEAddressFields(se) {
+		if err := field.Validate(); err != nil {
+			return err
+		}
+	}
+	if se.DMAProtLimit0 != 0 && se.DMAProtLimit0 < se.DMAProtBase0 {
+		return fmt.Errorf("DMA protection 0 limit 0x%x is below its base 0x%x", se.DMAProtLimit0, se.DMAProtBase0)
+	}
+	if se.DMAProtLimit1 != 0 && se.DMAProtLimit1 < se.DMAProtBase1 {
+		return fmt.Errorf("DMA protection 1 limit 0x%x is below its base 0x%x", se.DMAProtLimit1, se.DMAProtBase1)
+	}
+	return nil
+}
+
+// ValidateNEMSize checks that the NEM data stack of nems 4K pages is usable.
+// It has to hold at least the manifests and the stack CalculateNEMSize
+// accounts for. The ACM caches the measured IBB segments next to the data
+// stack in the cache running in no-eviction mode (NEM), so both together,
+// plus the NEM headroom, must fit into the last level cache.
+func ValidateNEMSize(nems bootpolicy.Size4K, se *bootpolicy.SE) error {
+	if nems == 0 {
+		return fmt.Errorf("NEM data stack must not be zero")
+	}
+	if nems.InBytes() < minNEMDataStackSize {
+		return fmt.Errorf("NEM data stack of %d pages (0x%x bytes) is below the minimum of 0x%x bytes for the manifests and the stack",
+			nems, nems.InBytes(), minNEMDataStackSize)
+	}
+	var footprint uint64
+	for _, seg := range se.IBBSegments {
+		if seg.IsMeasured() {
+			footprint += uint64(seg.Size)
+		}
+	}
+	total := uint64(nems.InBytes()) + footprint + additionalNEMSize
+	if total > defaultLLCSize {
+		return fmt.Errorf("NEM data stack of %d pages (0x%x bytes), the 0x%x bytes of measured IBB segments and the 0x%x bytes of NEM headroom don't fit into the 0x%x bytes of LLC",
+			nems, nems.InBytes(), footprint, additionalNEMSize, defaultLLCSize)
+	}
+	return nil
+}
+
+// validateIBBSegments checks the IBB segment set of an SE element for
+// contradictory flags and ensures the entry point is measured.
+func validateIBBSegments(se *bootpolicy.SE) error {
+	if len(se.IBBSegments) == 0 {
+		return fmt.Errorf("IBB segment set is empty")
+	}
+	for idx, seg := range se.IBBSegments {
+		if seg.Size == 0 {
+			return fmt.Errorf("IBB segment %d at 0x%x has size zero", idx, seg.Base)
+		}
+		if uint64(seg.Base)+uint64(seg.Size) > tools.FourGiB {
+			return fmt.Errorf("IBB segment %d at 0x%x with size 0x%x exceeds 4GiB", idx, seg.Base, seg.Size)
+		}
+	}
+	for idx, seg := range se.IBBSegments {
+		if !seg.IsMeasured() {
+			continue
+		}
+		for otherIdx, other := range se.IBBSegments {
+			if other.IsMeasured() {
+				continue
+			}
+			if seg.Contains(other.Base) || other.Contains(seg.Base) {
+				return fmt.Errorf("measured IBB segment %d at 0x%x overlaps not measured IBB segment %d at 0x%x", idx, seg.Base, otherIdx, other.Base)
+			}
+		}
+	}
+	for idx, seg := range se.IBBSegments {
+		if !seg.Contains(se.IBBEntryPoint) {
+			continue
+		}
+		if !seg.IsMeasured() {
+			return fmt.Errorf("IBB segment %d at 0x%x contains the entry point 0x%x but is marked as not measured", idx, seg.Base, se.IBBEntryPoint)
+		}
+		return nil
+	}
+	return fmt.Errorf("IBB entry point 0x%x is not covered by any IBB segment", se.IBBEntryPoint)
+}
+
+func (o ParseOptions) setIBBSegment(bgo *BootGuardOptions, image []byte) (*bootpolicy.SE, error) {
+	se := copySE(&bgo.BootPolicyManifest.SE[0])
+	if err := o.MeasureIBB(se, image); err != nil {
+		return nil, err
+	}
+	if len(bgo.OBB) > 0 {
+		if err := o.MeasureOBB(se, bgo.OBB, image); err != nil {
 			return nil, err
 		}
-		bgo.BootPolicyManifest.SE[0].DigestList.List[iterator].HashBuffer = make([]byte, len(d))
-		copy(bgo.BootPolicyManifest.SE[0].DigestList.List[iterator].HashBuffer, d)
 	}
+	return se, nil
+}
+
+// copySE returns a copy of se sharing no memory with it, so measuring the
+// copy leaves the config untouched and each generated BPM owns its digests.
+func copySE(se *bootpolicy.SE) *bootpolicy.SE {
+	c := *se
+	c.IBBSegments = append(se.IBBSegments[:0:0], se.IBBSegments...)
+	c.DigestList.List = append(se.DigestList.List[:0:0], se.DigestList.List...)
+	for idx, d := range se.DigestList.List {
+		c.DigestList.List[idx].HashBuffer = append(d.HashBuffer[:0:0], d.HashBuffer...)
+	}
+	c.OBBHash.HashBuffer = append(se.OBBHash.HashBuffer[:0:0], se.OBBHash.HashBuffer...)
+	c.PostIBBHash.HashBuffer = append(se.PostIBBHash.HashBuffer[:0:0], se.PostIBBHash.HashBuffer...)
+	return &c
+}
 
-	return &bgo.BootPolicyManifest.SE[0], nil
+// MeasureIBB recomputes every IBB digest of se from the IBB segments of the
+// BIOS image, e.g. after the segments were edited. The digests are left
+// untouched if one of them can't be computed.
+func MeasureIBB(se *bootpolicy.SE, image []byte) error {
+	return DefaultParseOptions().MeasureIBB(se, image)
+}
+
+// MeasureIBB recomputes the IBB digests as the function MeasureIBB does,
+// mapping the image with the layout of the options.
+func (o ParseOptions) MeasureIBB(se *bootpolicy.SE, image []byte) error {
+	digests := make([][]byte, len(se.DigestList.List))
+	for idx, item := range se.DigestList.List {
+		d, err := getIBBsDigest(se.IBBSegments, image, item.HashAlg, o.Layout)
+		if err != nil {
+			return fmt.Errorf("unable to measure the IBB with %s: %w", item.HashAlg, err)
+		}
+		digests[idx] = d
+	}
+	for idx, d := range digests {
+		se.DigestList.List[idx].HashBuffer = append([]byte{}, d...)
+	}
+	return nil
 }
 
 func setTXTElement(bgo *BootGuardOptions) (*bootpolicy.TXT, error) {
@@ -197,13 +445,28 @@ func setPCDElement(bgo *BootGuardOptions) (*bootpolicy.PCD, error) {
 	return pcde, nil
 }
 
-func setPMElement(bgo *BootGuardOptions) (*bootpolicy.PM, error) {
+// MaxPMDataSize is the maximum size of the platform manufacturer data, limited
+// by the 16 bit element size of the PM element, which includes the 12 byte
+// StructInfo, 2 reserved bytes and the 2 byte data size.
+const MaxPMDataSize = 0xffff - 16
+
+// NewPMElement returns a platform manufacturer element carrying data. The data
+// is opaque to the ACM and is kept as is.
+func NewPMElement(data []byte) (*bootpolicy.PM, error) {
+	if len(data) > MaxPMDataSize {
+		return nil, fmt.Errorf("platform manufacturer data of %d bytes exceeds the maximum of %d bytes", len(data), MaxPMDataSize)
+	}
 	pme := bootpolicy.NewPM()
+	pme.Data = data
+	pme.Rehash()
+	return pme, nil
+}
+
+func setPMElement(bgo *BootGuardOptions) (*bootpolicy.PM, error) {
 	if bgo.BootPolicyManifest.PME == nil {
 		return nil, nil
 	}
-	pme.Data = bgo.BootPolicyManifest.PME.Data
-	return pme, nil
+	return NewPMElement(bgo.BootPolicyManifest.PME.Data)
 }
 
 func setPMSElement(bgo *BootGuardOptions, bpm *bootpolicy.Manifest) (*bootpolicy.Signature, error) {
@@ -218,17 +481,74 @@ func SetKM(bgo *BootGuardOptions) (*key.Manifest, error) {
 	return km, nil
 }
 
+// ValidateKMHashAlgs checks that every KM hash entry holds a digest of the size
+// of its hash algorithm. The entries don't have to use PubKeyHashAlg, e.g. the
+// BPM key hash may use a stronger algorithm than the KM key digest. If
+// PubKeyHashAlg is unset, it is taken from the first hash entry.
+func ValidateKMHashAlgs(km *key.Manifest) error {
+	for idx, h := range km.Hash {
+		hash, err := h.Digest.HashAlg.Hash()
+		if err != nil {
+			return fmt.Errorf("KM hash entry %d (%s): %w", idx, h.Usage, err)
+		}
+		if len(h.Digest.HashBuffer) != hash.Size() {
+			return fmt.Errorf("KM hash entry %d (%s) has a %d bytes digest, but %s digests are %d bytes",
+				idx, h.Usage, len(h.Digest.HashBuffer), h.Digest.HashAlg, hash.Size())
+		}
+		if km.PubKeyHashAlg.IsNull() {
+			km.PubKeyHashAlg = h.Digest.HashAlg
+		}
+	}
+	return nil
+}
+
 // GenerateBPM generates a Boot Policy Manifest with the given config and firmware image
 func GenerateBPM(bgo *BootGuardOptions, biosFilepath string) (*bootpolicy.Manifest, error) {
-	bpm := bootpolicy.NewManifest()
+	return DefaultParseOptions().GenerateBPM(bgo, biosFilepath)
+}
+
+// GenerateBPM is GenerateBPM with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) GenerateBPM(bgo *BootGuardOptions, biosFilepath string) (*bootpolicy.Manifest, error) {
 	data, err := ioutil.ReadFile(biosFilepath)
 	if err != nil {
 		return nil, err
 	}
-	se, err := setIBBSegment(bgo, data)
+	return o.GenerateBPMFromImage(bgo, data)
+}
+
+// GenerateBPMFromImage generates a Boot Policy Manifest with the given config
+// over the firmware image in memory. The IBB digests are computed over exactly
+// the given bytes, so build flows modifying the image after the IBB is final,
+// e.g. to insert a logo, don't have to write it to disk first. The image is
+// not modified; the BPM is signed and stitched back into it as usual, e.g.
+// with StitchBPM and AssembleProvisionedBIOS.
+func GenerateBPMFromImage(bgo *BootGuardOptions, image []byte) (*bootpolicy.Manifest, error) {
+	return DefaultParseOptions().GenerateBPMFromImage(bgo, image)
+}
+
+// GenerateBPMFromImage is GenerateBPMFromImage with the options o instead of
+// the DefaultParseOptions.
+func (o ParseOptions) GenerateBPMFromImage(bgo *BootGuardOptions, image []byte) (*bootpolicy.Manifest, error) {
+	if len(bgo.BootPolicyManifest.SE) == 0 {
+		return nil, fmt.Errorf("no IBB segments element (SE) configured")
+	}
+	for idx := range bgo.BootPolicyManifest.SE {
+		if err := validateIBBSegments(&bgo.BootPolicyManifest.SE[idx]); err != nil {
+			return nil, fmt.Errorf("invalid SE %d: %w", idx, err)
+		}
+	}
+	se, err := o.setIBBSegment(bgo, image)
 	if err != nil {
 		return nil, err
 	}
+	return assembleBPM(bgo, se)
+}
+
+// assembleBPM builds the BPM of the config around the given SE element.
+func assembleBPM(bgo *BootGuardOptions, se *bootpolicy.SE) (*bootpolicy.Manifest, error) {
+	var err error
+	bpm := bootpolicy.NewManifest()
 	bpm.SE = append(bpm.SE, *se)
 	bpm.TXTE, err = setTXTElement(bgo)
 	if err != nil {
@@ -256,30 +576,56 @@ func GenerateBPM(bgo *BootGuardOptions, biosFilepath string) (*bootpolicy.Manife
 	return bpm, nil
 }
 
-// WriteConfig writes a BootGuard config file to the given path with given options.
-func WriteConfig(f *os.File, bgo *BootGuardOptions) error {
+// MarshalConfig returns the BootGuard config file of the given options as
+// WriteConfig writes it, e.g. to hash it.
+func MarshalConfig(bgo *BootGuardOptions) ([]byte, error) {
 	cfg, err := json.Marshal(bgo)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	json := pretty.Pretty(cfg)
-	if _, err := f.Write(json); err != nil {
+	return pretty.Pretty(cfg), nil
+}
+
+// WriteConfig writes a BootGuard config file with given options to w. To
+// write it to several destinations at once pass an io.MultiWriter.
+func WriteConfig(w io.Writer, bgo *BootGuardOptions) error {
+	cfg, err := MarshalConfig(bgo)
+	if err != nil {
 		return err
 	}
-	return nil
+	_, err = w.Write(cfg)
+	return err
 }
 
 // ReadConfigFromBIOSImage reads boot guard options, boot policy manifest and key manifest from a given firmware image
 // and writes that to a given file in json format
 func ReadConfigFromBIOSImage(biosFilepath string, configFilepath *os.File) (*BootGuardOptions, error) {
-	var bgo BootGuardOptions
-	var bpm *bootpolicy.Manifest
-	var km *key.Manifest
+	return DefaultParseOptions().ReadConfigFromBIOSImage(biosFilepath, configFilepath)
+}
+
+// ReadConfigFromBIOSImage is ReadConfigFromBIOSImage with the options o
+// instead of the DefaultParseOptions.
+func (o ParseOptions) ReadConfigFromBIOSImage(biosFilepath string, configFilepath *os.File) (*BootGuardOptions, error) {
 	bios, err := ioutil.ReadFile(biosFilepath)
 	if err != nil {
 		return nil, err
 	}
-	bpmBuf, kmBuf, _, err := ParseFITEntries(bios)
+	return o.ReadConfigFromImage(bios, configFilepath)
+}
+
+// ReadConfigFromImage is ReadConfigFromBIOSImage for an image already read,
+// e.g. from the SPI flash.
+func ReadConfigFromImage(bios []byte, configFilepath *os.File) (*BootGuardOptions, error) {
+	return DefaultParseOptions().ReadConfigFromImage(bios, configFilepath)
+}
+
+// ReadConfigFromImage is ReadConfigFromImage with the options o instead of
+// the DefaultParseOptions.
+func (o ParseOptions) ReadConfigFromImage(bios []byte, configFilepath *os.File) (*BootGuardOptions, error) {
+	var bgo BootGuardOptions
+	var bpm *bootpolicy.Manifest
+	var km *key.Manifest
+	bpmBuf, kmBuf, _, err := o.ParseFITEntries(bios)
 	if err != nil {
 		return nil, err
 	}
@@ -289,7 +635,7 @@ func ReadConfigFromBIOSImage(biosFilepath string, configFilepath *os.File) (*Boo
 	}
 
 	reader := bytes.NewReader(bpmBuf)
-	bpm, err = ParseBPM(reader)
+	bpm, err = o.ParseBPM(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -299,7 +645,7 @@ func ReadConfigFromBIOSImage(biosFilepath string, configFilepath *os.File) (*Boo
 	}
 
 	reader = bytes.NewReader(kmBuf)
-	km, err = ParseKM(reader)
+	km, err = o.ParseKM(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -309,12 +655,7 @@ func ReadConfigFromBIOSImage(biosFilepath string, configFilepath *os.File) (*Boo
 
 	/* Key Manifest */
 	bgo.KeyManifest = *km
-	data, err := json.Marshal(bgo)
-	if err != nil {
-		return nil, err
-	}
-	json := pretty.Pretty(data)
-	if _, err = configFilepath.Write(json); err != nil {
+	if err = WriteConfig(configFilepath, &bgo); err != nil {
 		return nil, err
 	}
 	return &bgo, nil
@@ -323,12 +664,12 @@ func ReadConfigFromBIOSImage(biosFilepath string, configFilepath *os.File) (*Boo
 // GetBPMPubHash takes the path to public BPM signing key and hash algorithm
 // and returns a hash with hashAlg of pub BPM singing key
 func GetBPMPubHash(path string, hashAlg manifest.Algorithm) ([]key.Hash, error) {
-	var data []byte
-	pubkey, err := ReadPubKey(path)
-	if err != nil {
-		return nil, err
-	}
-	hash, err := hashAlg.Hash()
+	return GetBPMPubHashFormat(path, PubKeyFormatAuto, hashAlg)
+}
+
+// GetBPMPubHashFormat is GetBPMPubHash for a key file of the given format.
+func GetBPMPubHashFormat(path string, format PubKeyFormat, hashAlg manifest.Algorithm) ([]key.Hash, error) {
+	pubkey, err := ReadPubKeyFormat(path, format)
 	if err != nil {
 		return nil, err
 	}
@@ -336,11 +677,11 @@ func GetBPMPubHash(path string, hashAlg manifest.Algorithm) ([]key.Hash, error)
 	if err := kAs.SetPubKey(pubkey); err != nil {
 		return nil, err
 	}
-	k := kAs.Data[4:]
-	if _, err := hash.Write(k); err != nil {
+	// hash the key the same way the ACM does when it checks the BPM
+	data, err := kAs.BPMPubKeyHash(hashAlg)
+	if err != nil {
 		return nil, err
 	}
-	data = hash.Sum(nil)
 	var keyHashes []key.Hash
 	hStruc := &manifest.HashStructure{
 		HashAlg: manifest.Algorithm(hashAlg),
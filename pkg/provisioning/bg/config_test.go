@@ -1,6 +1,24 @@
 package bg
 
-import "testing"
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
 
 func TestParseConfigValid(T *testing.T) {
 
@@ -10,6 +28,82 @@ func TestParseConfigInvalid(T *testing.T) {
 
 }
 
+func TestParseConfigURL(t *testing.T) {
+	const auth = "Bearer secret"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != auth {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/config.json":
+			fmt.Fprint(w, `{"KeyManifest": {"km_Revision": 3}}`)
+		case "/invalid.json":
+			fmt.Fprint(w, `<html>login</html>`)
+		case "/large.json":
+			fmt.Fprintf(w, `{"KeyManifest": {"km_Revision": 3}}%s`, strings.Repeat(" ", int(MaxConfigSize)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	configTransport = server.Client().Transport
+	defer func() { configTransport = nil }()
+
+	os.Setenv(ConfigAuthHeaderEnv, auth)
+	defer os.Unsetenv(ConfigAuthHeaderEnv)
+	bgo, err := ParseConfig(server.URL + "/config.json")
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	if bgo.KeyManifest.Revision != 3 {
+		t.Errorf("ParseConfig() returned KM revision %d, expected 3", bgo.KeyManifest.Revision)
+	}
+
+	for _, tc := range []struct {
+		path string
+		err  string
+	}{
+		{"/invalid.json", "not valid JSON"},
+		{"/missing.json", "404 Not Found"},
+		{"/large.json", "exceeds the maximum size"},
+	} {
+		_, err := ParseConfig(server.URL + tc.path)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("ParseConfig(%s) returned %v, expected error containing %q", tc.path, err, tc.err)
+		}
+	}
+
+	os.Unsetenv(ConfigAuthHeaderEnv)
+	if _, err := ParseConfig(server.URL + "/config.json"); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("ParseConfig() without auth header returned %v", err)
+	}
+}
+
+func TestParseConfigURLAuthWithoutTLS(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"KeyManifest": {"km_Revision": 3}}`)
+	}))
+	defer server.Close()
+
+	os.Setenv(ConfigAuthHeaderEnv, "Bearer secret")
+	defer os.Unsetenv(ConfigAuthHeaderEnv)
+	if _, err := ParseConfig(server.URL + "/config.json"); err == nil || !strings.Contains(err.Error(), "without TLS") {
+		t.Errorf("ParseConfig() of an http URL with auth header returned %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("ParseConfig() sent the request with the auth header over http")
+	}
+
+	// without credentials http URLs are fetched
+	os.Unsetenv(ConfigAuthHeaderEnv)
+	if _, err := ParseConfig(server.URL + "/config.json"); err != nil {
+		t.Errorf("ParseConfig() of an http URL failed: %v", err)
+	}
+}
+
 func TestSetBPMHeaderValid(T *testing.T) {
 
 }
@@ -34,6 +128,239 @@ func TestSetIBBSegmentInvalidImage(T *testing.T) {
 
 }
 
+func ibbSegment(base, size uint32, flags uint16) bootpolicy.IBBSegment {
+	seg := *bootpolicy.NewIBBSegment()
+	seg.Base = base
+	seg.Size = size
+	seg.Flags = flags
+	return seg
+}
+
+func TestMeasureIBB(t *testing.T) {
+	image := make([]byte, 0x10000)
+	for idx := range image {
+		image[idx] = byte(idx * 7)
+	}
+	se := bootpolicy.NewSE()
+	// the image is mapped to 0xffff0000-0xffffffff
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(0xffff8000, 0x1000, 0)}
+	se.DigestList.List = []manifest.HashStructure{{HashAlg: manifest.AlgSHA1}, {HashAlg: manifest.AlgSHA256}}
+	if err := MeasureIBB(se, image); err != nil {
+		t.Fatalf("MeasureIBB() failed: %v", err)
+	}
+	sha1Digest := sha1.Sum(image[0x8000:0x9000])
+	sha256Digest := sha256.Sum256(image[0x8000:0x9000])
+	if !bytes.Equal(se.DigestList.List[0].HashBuffer, sha1Digest[:]) || !bytes.Equal(se.DigestList.List[1].HashBuffer, sha256Digest[:]) {
+		t.Errorf("MeasureIBB() stored the digests %x and %x, expected %x and %x",
+			se.DigestList.List[0].HashBuffer, se.DigestList.List[1].HashBuffer, sha1Digest, sha256Digest)
+	}
+
+	// the segment is moved and a second one added, the digests cover both in order
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(0xffffa000, 0x800, 0), ibbSegment(0xffffc000, 0x400, 0)}
+	if err := MeasureIBB(se, image); err != nil {
+		t.Fatalf("MeasureIBB() failed: %v", err)
+	}
+	edited := append(append([]byte{}, image[0xa000:0xa800]...), image[0xc000:0xc400]...)
+	sha256Digest = sha256.Sum256(edited)
+	if !bytes.Equal(se.DigestList.List[1].HashBuffer, sha256Digest[:]) {
+		t.Errorf("MeasureIBB() of the edited segments stored %x, expected %x", se.DigestList.List[1].HashBuffer, sha256Digest)
+	}
+}
+
+func TestMeasureIBBInvalidImage(t *testing.T) {
+	se := bootpolicy.NewSE()
+	// the image is mapped to 0xffff0000-0xffffffff
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(0xfffe0000, 0x10000, 0)}
+	stored := []byte{1, 2, 3}
+	se.DigestList.List = []manifest.HashStructure{{HashAlg: manifest.AlgSHA256, HashBuffer: stored}}
+	if err := MeasureIBB(se, make([]byte, 0x10000)); err == nil {
+		t.Fatalf("MeasureIBB() succeeded with an IBB segment outside the image")
+	}
+	if !bytes.Equal(se.DigestList.List[0].HashBuffer, stored) {
+		t.Errorf("MeasureIBB() modified the digest although it failed")
+	}
+}
+
+func TestGenerateBPMFromImage(t *testing.T) {
+	const base = tools.FourGiB - 0x10000
+	image := bytes.Repeat([]byte{0xff}, 0x10000)
+	bgo := lintTestConfig(t)
+	bgo.OBB = nil
+	se := &bgo.BootPolicyManifest.SE[0]
+	se.IBBEntryPoint = 0xfffffff0
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(uint32(base+0x8000), 0x8000, 0)}
+	orig, err := GenerateBPMFromImage(bgo, image)
+	if err != nil {
+		t.Fatalf("GenerateBPMFromImage() failed: %v", err)
+	}
+
+	// insert a logo into the IBB of the buffer after the IBB was finalized
+	copy(image[0x9000:], "logo")
+	bpm, err := GenerateBPMFromImage(bgo, image)
+	if err != nil {
+		t.Fatalf("GenerateBPMFromImage() failed: %v", err)
+	}
+	sha256Digest := sha256.Sum256(image[0x8000:])
+	for idx, d := range bpm.SE[0].DigestList.List {
+		expected, err := getIBBsDigest(se.IBBSegments, image, d.HashAlg, nil)
+		if err != nil {
+			t.Fatalf("getIBBsDigest() failed: %v", err)
+		}
+		if !bytes.Equal(d.HashBuffer, expected) {
+			t.Errorf("the %s IBB digest isn't the one of the modified image", d.HashAlg)
+		}
+		if bytes.Equal(d.HashBuffer, orig.SE[0].DigestList.List[idx].HashBuffer) {
+			t.Errorf("the %s IBB digest didn't change with the image", d.HashAlg)
+		}
+		if d.HashAlg == manifest.AlgSHA256 && !bytes.Equal(d.HashBuffer, sha256Digest[:]) {
+			t.Errorf("the SHA256 IBB digest is %x, expected %x", d.HashBuffer, sha256Digest)
+		}
+	}
+
+	// the BPM is stitched back into the same buffer, outside the IBB
+	data, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	entries := []tools.FitEntry{fitEntry(tools.BootPolicyManifest, base+0x1000, 0x1000)}
+	stitched, err := stitchFIT(image, entries, func(addr uint64) (uint64, error) {
+		return addr - base, nil
+	}, nil, nil, data, StitchOptions{})
+	if err != nil {
+		t.Fatalf("stitchFIT() failed: %v", err)
+	}
+	for _, c := range CheckBPMHashes(bpm, stitched).Checks {
+		if strings.Contains(c.Check, "ibb-digest") && !c.Pass {
+			t.Errorf("the BPM doesn't match the image it is stitched into: %s: %s", c.Check, c.Detail)
+		}
+	}
+}
+
+func TestValidateIBBSegmentsValid(t *testing.T) {
+	se := bootpolicy.NewSE()
+	se.IBBEntryPoint = 0xfffffff0
+	se.IBBSegments = []bootpolicy.IBBSegment{
+		ibbSegment(0xffe00000, 0x100000, bootpolicy.IBBSegmentFlagNotMeasured),
+		ibbSegment(0xfff00000, 0x100000, 0),
+	}
+	if err := validateIBBSegments(se); err != nil {
+		t.Errorf("validateIBBSegments() failed: %v", err)
+	}
+}
+
+func TestValidateIBBSegmentsInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		entryPoint uint32
+		segments   []bootpolicy.IBBSegment
+		err        string
+	}{
+		{"empty", 0xfffffff0, nil, "empty"},
+		{"zero size", 0xfffffff0, []bootpolicy.IBBSegment{
+			ibbSegment(0xfff00000, 0x100000, 0),
+			ibbSegment(0xffe00000, 0, 0),
+		}, "size zero"},
+		{"exceeds 4GiB", 0xfffffff0, []bootpolicy.IBBSegment{
+			ibbSegment(0xfff00000, 0x200000, 0),
+		}, "exceeds 4GiB"},
+		{"entry point not measured", 0xfffffff0, []bootpolicy.IBBSegment{
+			ibbSegment(0xfff00000, 0x100000, bootpolicy.IBBSegmentFlagNotMeasured),
+		}, "not measured"},
+		{"entry point not covered", 0xffd00000, []bootpolicy.IBBSegment{
+			ibbSegment(0xfff00000, 0x100000, 0),
+		}, "not covered"},
+		{"measured overlaps not measured", 0xfffffff0, []bootpolicy.IBBSegment{
+			ibbSegment(0xfff00000, 0x100000, 0),
+			ibbSegment(0xfff80000, 0x1000, bootpolicy.IBBSegmentFlagNotMeasured),
+		}, "overlaps"},
+	} {
+		se := bootpolicy.NewSE()
+		se.IBBEntryPoint = tc.entryPoint
+		se.IBBSegments = tc.segments
+		err := validateIBBSegments(se)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: validateIBBSegments() returned %v, expected error containing %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestValidateSEAddresses(t *testing.T) {
+	se := bootpolicy.NewSE()
+	se.IBBMCHBAR = 0xfed10000
+	se.VTdBAR = 0xfed90000
+	se.DMAProtBase0 = 0x100000
+	se.DMAProtLimit0 = 0x200000
+	if err := ValidateSEAddresses(se); err != nil {
+		t.Errorf("ValidateSEAddresses() failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(se *bootpolicy.SE)
+		err    string
+	}{
+		{"MCHBAR unaligned", func(se *bootpolicy.SE) { se.IBBMCHBAR = 0xfed18000 }, "MCHBAR 0xfed18000 is not aligned"},
+		{"MCHBAR too wide", func(se *bootpolicy.SE) { se.IBBMCHBAR = 1 << 40 }, "bit width"},
+		{"VT-d BAR unaligned", func(se *bootpolicy.SE) { se.VTdBAR = 0xfed90800 }, "VT-d BAR 0xfed90800 is not aligned"},
+		{"DMA base unaligned", func(se *bootpolicy.SE) { se.DMAProtBase0 = 0x100010 }, "DMA protection 0 base"},
+		{"DMA range inverted", func(se *bootpolicy.SE) { se.DMAProtBase0 = 0x300000 }, "below its base"},
+	} {
+		se := *se
+		tc.modify(&se)
+		err := ValidateSEAddresses(&se)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: ValidateSEAddresses() returned %v, expected error containing %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestValidateNEMSize(t *testing.T) {
+	se := bootpolicy.NewSE()
+	se.IBBSegments = []bootpolicy.IBBSegment{
+		ibbSegment(0xffc00000, 0x300000, 0),
+		ibbSegment(0xfff00000, 0x100000, 0),
+		// not measured segments aren't loaded into NEM
+		ibbSegment(0xff800000, 0x400000, bootpolicy.IBBSegmentFlagNotMeasured),
+	}
+	if err := ValidateNEMSize(0x180, se); err != nil {
+		t.Errorf("ValidateNEMSize() failed with a NEM data stack fitting into the LLC: %v", err)
+	}
+	if err := ValidateNEMSize(0x380, se); err != nil {
+		t.Errorf("ValidateNEMSize() failed with a NEM data stack filling the LLC: %v", err)
+	}
+	err := ValidateNEMSize(0x381, se)
+	if err == nil || !strings.Contains(err.Error(), "don't fit into the 0x800000 bytes of LLC") {
+		t.Errorf("ValidateNEMSize() with an oversized NEM data stack returned %v", err)
+	}
+	for _, nems := range []bootpolicy.Size4K{1, 3} {
+		err := ValidateNEMSize(nems, se)
+		if err == nil || !strings.Contains(err.Error(), "below the minimum") {
+			t.Errorf("ValidateNEMSize() with an undersized NEM data stack of %d pages returned %v", nems, err)
+		}
+	}
+	if err := ValidateNEMSize(4, se); err != nil {
+		t.Errorf("ValidateNEMSize() failed with the minimal NEM data stack: %v", err)
+	}
+	err = ValidateNEMSize(0, se)
+	if err == nil || !strings.Contains(err.Error(), "must not be zero") {
+		t.Errorf("ValidateNEMSize() with an empty NEM data stack returned %v", err)
+	}
+
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	for idx := range bpm.SE {
+		if err := ValidateNEMSize(bpm.BPMH.NEMDataStack, &bpm.SE[idx]); err != nil {
+			t.Errorf("ValidateNEMSize() failed on SE %d of a real platform BPM: %v", idx, err)
+		}
+	}
+}
+
 func TestTXTElementValid(T *testing.T) {
 
 }
@@ -50,10 +377,171 @@ func TestSetPCDElementInvalidBGO(T *testing.T) {
 
 }
 
-func TestPMElementValid(T *testing.T) {
+func TestPMElementValid(t *testing.T) {
+	var bgo BootGuardOptions
+	if pme, err := setPMElement(&bgo); err != nil || pme != nil {
+		t.Errorf("setPMElement() without PM element returned %v, %v", pme, err)
+	}
+	data := []byte("OEM data")
+	bgo.BootPolicyManifest.PME = &bootpolicy.PM{Data: data}
+	pme, err := setPMElement(&bgo)
+	if err != nil {
+		t.Fatalf("setPMElement() failed: %v", err)
+	}
+	if !bytes.Equal(pme.Data, data) || pme.ID.String() != bootpolicy.StructureIDPM || pme.ElementSize != uint16(16+len(data)) {
+		t.Errorf("setPMElement() returned %+v", pme)
+	}
+}
+
+func TestPMElementInvalidBGO(t *testing.T) {
+	var bgo BootGuardOptions
+	bgo.BootPolicyManifest.PME = &bootpolicy.PM{Data: make([]byte, MaxPMDataSize+1)}
+	if _, err := setPMElement(&bgo); err == nil {
+		t.Errorf("setPMElement() accepted %d bytes of data", MaxPMDataSize+1)
+	}
+	if _, err := NewPMElement(make([]byte, MaxPMDataSize)); err != nil {
+		t.Errorf("NewPMElement() rejected %d bytes of data: %v", MaxPMDataSize, err)
+	}
+}
+
+func kmHash(alg manifest.Algorithm, size int) key.Hash {
+	return key.Hash{
+		Usage:  key.UsageBPMSigningPKD,
+		Digest: manifest.HashStructure{HashAlg: alg, HashBuffer: make([]byte, size)},
+	}
+}
+
+func TestValidateKMHashAlgs(t *testing.T) {
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	km.Hash = []key.Hash{kmHash(manifest.AlgSHA256, 32)}
+	if err := ValidateKMHashAlgs(km); err != nil {
+		t.Errorf("ValidateKMHashAlgs() failed on a consistent KM: %v", err)
+	}
+
+	km.Hash = []key.Hash{kmHash(manifest.AlgSHA384, 48)}
+	if err := ValidateKMHashAlgs(km); err != nil || km.PubKeyHashAlg != manifest.AlgSHA256 {
+		t.Errorf("ValidateKMHashAlgs() rejected a hash entry not using PubKeyHashAlg: %v", err)
+	}
+
+	km.PubKeyHashAlg = manifest.AlgUnknown
+	if err := ValidateKMHashAlgs(km); err != nil || km.PubKeyHashAlg != manifest.AlgSHA384 {
+		t.Errorf("ValidateKMHashAlgs() didn't take the unset PubKeyHashAlg from the entries: %v, %s", err, km.PubKeyHashAlg)
+	}
+
+	for _, tc := range []struct {
+		name string
+		hash key.Hash
+		err  string
+	}{
+		{"digest size", kmHash(manifest.AlgSHA256, 48), "48 bytes digest"},
+		{"no hash alg", kmHash(manifest.AlgRSA, 32), "hash algorithm not supported"},
+	} {
+		km.PubKeyHashAlg = manifest.AlgSHA256
+		km.Hash = []key.Hash{tc.hash}
+		err := ValidateKMHashAlgs(km)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: ValidateKMHashAlgs() returned %v, expected error containing %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestFormatConfig(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	bgo := &BootGuardOptions{KeyManifest: *km, BootPolicyManifest: *bpm}
+	// a hand-edited config with struct order keys and compact formatting
+	cfg, err := json.Marshal(bgo)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+
+	formatted, err := FormatConfig(cfg)
+	if err != nil {
+		t.Fatalf("FormatConfig() failed: %v", err)
+	}
+	again, err := FormatConfig(formatted)
+	if err != nil {
+		t.Fatalf("FormatConfig() failed on a formatted config: %v", err)
+	}
+	if !bytes.Equal(formatted, again) {
+		t.Errorf("FormatConfig() is not idempotent")
+	}
+	if bytes.Index(formatted, []byte(`"BootPolicyManifest"`)) > bytes.Index(formatted, []byte(`"KeyManifest"`)) {
+		t.Errorf("FormatConfig() didn't sort the keys")
+	}
+
+	var canonical BootGuardOptions
+	if err := json.Unmarshal(formatted, &canonical); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	origKM, err := WriteKM(&bgo.KeyManifest)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	newKM, err := WriteKM(&canonical.KeyManifest)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if !bytes.Equal(origKM, newKM) {
+		t.Errorf("Canonical config generates another KM")
+	}
+	origBPM, err := assembleBPM(bgo, &bgo.BootPolicyManifest.SE[0])
+	if err != nil {
+		t.Fatalf("assembleBPM() failed: %v", err)
+	}
+	newBPM, err := assembleBPM(&canonical, &canonical.BootPolicyManifest.SE[0])
+	if err != nil {
+		t.Fatalf("assembleBPM() failed: %v", err)
+	}
+	origBPMData, err := WriteBPM(origBPM)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	newBPMData, err := WriteBPM(newBPM)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(origBPMData, newBPMData) {
+		t.Errorf("Canonical config generates another BPM")
+	}
 
+	if _, err := FormatConfig([]byte(`{"KeyManifest": {"km_Revison": 1}}`)); err == nil {
+		t.Errorf("FormatConfig() dropped an unknown key")
+	}
 }
 
-func TestPMElementInvalidBGO(T *testing.T) {
+func TestMarshalConfig(t *testing.T) {
+	bgo := lintTestConfig(t)
+	cfg, err := MarshalConfig(bgo)
+	if err != nil {
+		t.Fatalf("MarshalConfig() failed: %v", err)
+	}
 
+	// the config is written and hashed in one go
+	var out bytes.Buffer
+	h := sha256.New()
+	if err := WriteConfig(io.MultiWriter(&out, h), bgo); err != nil {
+		t.Fatalf("WriteConfig() failed: %v", err)
+	}
+	if !bytes.Equal(cfg, out.Bytes()) {
+		t.Errorf("MarshalConfig() returned other bytes than WriteConfig() wrote")
+	}
+	if expected := sha256.Sum256(cfg); !bytes.Equal(h.Sum(nil), expected[:]) {
+		t.Errorf("the hash of the written config is %x, expected %x", h.Sum(nil), expected)
+	}
 }
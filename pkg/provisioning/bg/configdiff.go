@@ -0,0 +1,318 @@
+package bg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// Groups of the fields compared by DiffConfigs, in the order they are printed.
+const (
+	ConfigGroupHeader = "Header"
+	ConfigGroupIBB    = "IBB"
+	ConfigGroupTXT    = "TXT"
+	ConfigGroupKeys   = "Keys"
+	ConfigGroupData   = "Platform data"
+)
+
+var configGroups = []string{ConfigGroupHeader, ConfigGroupIBB, ConfigGroupTXT, ConfigGroupKeys, ConfigGroupData}
+
+// configNone is the value of a field missing in one of the configs, e.g. an
+// IBB segment only the other config has.
+const configNone = "(none)"
+
+// ConfigChange is a field differing between two configs.
+type ConfigChange struct {
+	Group string `json:"group"`
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+	// Detail decodes a flags change symbolically, listing the settings
+	// removed with - and the ones added with +.
+	Detail string `json:"detail,omitempty"`
+}
+
+// ConfigDiff is the comparison of two configs field by field.
+type ConfigDiff struct {
+	Changes []ConfigChange `json:"changes"`
+}
+
+// Differ returns true if any field differs.
+func (d *ConfigDiff) Differ() bool {
+	return len(d.Changes) > 0
+}
+
+func (d *ConfigDiff) add(group, field string, a, b interface{}) {
+	valueA, valueB := fmt.Sprint(a), fmt.Sprint(b)
+	if valueA != valueB {
+		d.Changes = append(d.Changes, ConfigChange{Group: group, Field: field, A: valueA, B: valueB})
+	}
+}
+
+// addFlags adds a change of the flags a and b, whose settings are decoded by
+// names, with the settings which changed as detail.
+func (d *ConfigDiff) addFlags(group, field string, a, b uint64, names func(uint64) []string) {
+	if a == b {
+		return
+	}
+	d.Changes = append(d.Changes, ConfigChange{
+		Group:  group,
+		Field:  field,
+		A:      fmt.Sprintf("0x%x", a),
+		B:      fmt.Sprintf("0x%x", b),
+		Detail: symbolicChange(names(a), names(b)),
+	})
+}
+
+// DiffConfigs compares the KM and BPM of two configs field by field, ignoring
+// the signatures and the digests computed at generation. Flags are decoded so
+// a changed bit is reported by its meaning.
+func DiffConfigs(a, b *BootGuardOptions) *ConfigDiff {
+	d := &ConfigDiff{Changes: []ConfigChange{}}
+	kmA, kmB := &a.KeyManifest, &b.KeyManifest
+	bpmA, bpmB := &a.BootPolicyManifest, &b.BootPolicyManifest
+
+	d.add(ConfigGroupHeader, "KM revision", kmA.Revision, kmB.Revision)
+	d.add(ConfigGroupHeader, "KM SVN", kmA.KMSVN.SVN(), kmB.KMSVN.SVN())
+	d.add(ConfigGroupHeader, "KM ID", kmA.KMID, kmB.KMID)
+	d.add(ConfigGroupHeader, "BPM revision", bpmA.BPMRevision, bpmB.BPMRevision)
+	d.add(ConfigGroupHeader, "BPM SVN", bpmA.BPMSVN.SVN(), bpmB.BPMSVN.SVN())
+	d.add(ConfigGroupHeader, "ACM SVN", bpmA.ACMSVNAuth.SVN(), bpmB.ACMSVNAuth.SVN())
+	d.add(ConfigGroupHeader, "NEM data stack (4K pages)", bpmA.NEMDataStack, bpmB.NEMDataStack)
+
+	diffSEs(d, bpmA.SE, bpmB.SE)
+	diffOBB(d, a.OBB, b.OBB)
+	diffTXT(d, bpmA.TXTE, bpmB.TXTE)
+
+	d.add(ConfigGroupKeys, "OEM key hash algorithm", kmA.PubKeyHashAlg, kmB.PubKeyHashAlg)
+	d.add(ConfigGroupKeys, "KM signing key algorithm", kmA.KeyAndSignature.Key.KeyAlg, kmB.KeyAndSignature.Key.KeyAlg)
+	d.add(ConfigGroupKeys, "BPM signing key algorithm", bpmA.PMSE.Key.KeyAlg, bpmB.PMSE.Key.KeyAlg)
+	d.add(ConfigGroupKeys, "BPM signature hash algorithm", bpmA.PMSE.Signature.HashAlg, bpmB.PMSE.Signature.HashAlg)
+	for idx := 0; idx < len(kmA.Hash) || idx < len(kmB.Hash); idx++ {
+		var hashA, hashB key.Hash
+		presentA, presentB := idx < len(kmA.Hash), idx < len(kmB.Hash)
+		if presentA {
+			hashA = kmA.Hash[idx]
+		}
+		if presentB {
+			hashB = kmB.Hash[idx]
+		}
+		field := fmt.Sprintf("KM hash %d", idx)
+		if presentA && presentB {
+			d.addFlags(ConfigGroupKeys, field+" usage", uint64(hashA.Usage), uint64(hashB.Usage), keyUsageNames)
+		}
+		d.add(ConfigGroupKeys, field, configOptional(presentA, hashString(hashA.Digest)), configOptional(presentB, hashString(hashB.Digest)))
+	}
+
+	var pcdA, pcdB, pmA, pmB []byte
+	if bpmA.PCDE != nil {
+		pcdA = bpmA.PCDE.Data
+	}
+	if bpmB.PCDE != nil {
+		pcdB = bpmB.PCDE.Data
+	}
+	if bpmA.PME != nil {
+		pmA = bpmA.PME.Data
+	}
+	if bpmB.PME != nil {
+		pmB = bpmB.PME.Data
+	}
+	d.add(ConfigGroupData, "platform config data", configOptional(bpmA.PCDE != nil, fmt.Sprintf("%x", pcdA)), configOptional(bpmB.PCDE != nil, fmt.Sprintf("%x", pcdB)))
+	d.add(ConfigGroupData, "platform manufacturer data", configOptional(bpmA.PME != nil, fmt.Sprintf("%x", pmA)), configOptional(bpmB.PME != nil, fmt.Sprintf("%x", pmB)))
+	return d
+}
+
+func diffSEs(d *ConfigDiff, a, b []bootpolicy.SE) {
+	d.add(ConfigGroupIBB, "IBBS elements", len(a), len(b))
+	for idx := 0; idx < len(a) && idx < len(b); idx++ {
+		seA, seB := &a[idx], &b[idx]
+		prefix := fmt.Sprintf("SE %d ", idx)
+		d.addFlags(ConfigGroupIBB, prefix+"flags", uint64(seA.Flags), uint64(seB.Flags), seFlagNames)
+		d.add(ConfigGroupIBB, prefix+"PBET", seA.PBETValue.PBETValue(), seB.PBETValue.PBETValue())
+		d.add(ConfigGroupIBB, prefix+"IBB entry point", fmt.Sprintf("0x%08x", seA.IBBEntryPoint), fmt.Sprintf("0x%08x", seB.IBBEntryPoint))
+		fieldsA, fieldsB := SEAddressFields(seA), SEAddressFields(seB)
+		for i := range fieldsA {
+			d.add(ConfigGroupIBB, prefix+fieldsA[i].Name, fmt.Sprintf("0x%x", fieldsA[i].Value), fmt.Sprintf("0x%x", fieldsB[i].Value))
+		}
+		d.add(ConfigGroupIBB, prefix+"IBB digest algorithms", digestAlgs(seA.DigestList), digestAlgs(seB.DigestList))
+		d.add(ConfigGroupIBB, prefix+"OBB hash algorithm", seA.OBBHash.HashAlg, seB.OBBHash.HashAlg)
+		for i := 0; i < len(seA.IBBSegments) || i < len(seB.IBBSegments); i++ {
+			field := fmt.Sprintf("%sIBB segment %d", prefix, i)
+			var segA, segB bootpolicy.IBBSegment
+			presentA, presentB := i < len(seA.IBBSegments), i < len(seB.IBBSegments)
+			if presentA {
+				segA = seA.IBBSegments[i]
+			}
+			if presentB {
+				segB = seB.IBBSegments[i]
+			}
+			d.add(ConfigGroupIBB, field,
+				configOptional(presentA, fmt.Sprintf("0x%08x+0x%x", segA.Base, segA.Size)),
+				configOptional(presentB, fmt.Sprintf("0x%08x+0x%x", segB.Base, segB.Size)))
+			if presentA && presentB {
+				d.addFlags(ConfigGroupIBB, field+" flags", uint64(segA.Flags), uint64(segB.Flags), ibbSegmentFlagNames)
+			}
+		}
+	}
+}
+
+func diffOBB(d *ConfigDiff, a, b OBBRegions) {
+	for idx := 0; idx < len(a) || idx < len(b); idx++ {
+		var valueA, valueB string
+		if idx < len(a) {
+			valueA = fmt.Sprintf("0x%08x+0x%x", a[idx].Base, a[idx].Size)
+		}
+		if idx < len(b) {
+			valueB = fmt.Sprintf("0x%08x+0x%x", b[idx].Base, b[idx].Size)
+		}
+		d.add(ConfigGroupIBB, fmt.Sprintf("OBB region %d", idx), configOptional(idx < len(a), valueA), configOptional(idx < len(b), valueB))
+	}
+}
+
+func diffTXT(d *ConfigDiff, a, b *bootpolicy.TXT) {
+	d.add(ConfigGroupTXT, "TXT element", configOptional(a != nil, "present"), configOptional(b != nil, "present"))
+	if a == nil || b == nil {
+		return
+	}
+	d.addFlags(ConfigGroupTXT, "control flags", uint64(a.ControlFlags), uint64(b.ControlFlags), txtFlagNames)
+	d.add(ConfigGroupTXT, "SINIT min SVN", a.SInitMinSVNAuth, b.SInitMinSVNAuth)
+	d.add(ConfigGroupTXT, "power down interval", a.PwrDownInterval, b.PwrDownInterval)
+	d.add(ConfigGroupTXT, "PTT CMOS offset 0", a.PTTCMOSOffset0, b.PTTCMOSOffset0)
+	d.add(ConfigGroupTXT, "PTT CMOS offset 1", a.PTTCMOSOffset1, b.PTTCMOSOffset1)
+	d.add(ConfigGroupTXT, "ACPI base offset", fmt.Sprintf("0x%x", a.ACPIBaseOffset), fmt.Sprintf("0x%x", b.ACPIBaseOffset))
+	d.add(ConfigGroupTXT, "PWRM base offset", fmt.Sprintf("0x%x", a.PwrMBaseOffset), fmt.Sprintf("0x%x", b.PwrMBaseOffset))
+}
+
+func configOptional(present bool, value string) string {
+	if !present {
+		return configNone
+	}
+	return value
+}
+
+func hashString(h manifest.HashStructure) string {
+	if len(h.HashBuffer) == 0 {
+		return h.HashAlg.String()
+	}
+	return fmt.Sprintf("%s %x", h.HashAlg, h.HashBuffer)
+}
+
+func digestAlgs(l manifest.HashList) string {
+	var algs []string
+	for _, h := range l.List {
+		algs = append(algs, h.HashAlg.String())
+	}
+	return strings.Join(algs, ",")
+}
+
+// reservedBits returns the name of the reserved bits of flags outside known.
+func reservedBits(flags, known uint64) []string {
+	if reserved := flags &^ known; reserved != 0 {
+		return []string{fmt.Sprintf("reserved bits 0x%x", reserved)}
+	}
+	return nil
+}
+
+func seFlagNames(v uint64) []string {
+	flags := bootpolicy.SEFlags(v)
+	var names []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{flags.DMAProtection(), "DMA protection"},
+		{flags.Locality3Startup(), "TPM startup from locality 3"},
+		{flags.AuthorityMeasure(), "authority measurements into PCR7"},
+		{flags.TPMFailureLeavesHierarchiesEnabled(), "TPM failure leaves hierarchies enabled"},
+		{flags.SupportsTopSwapRemediation(), "Top Swap remediation"},
+	} {
+		if f.set {
+			names = append(names, f.name)
+		}
+	}
+	return append(names, reservedBits(v, 0x1f)...)
+}
+
+func txtFlagNames(v uint64) []string {
+	flags := bootpolicy.TXTControlFlags(v)
+	names := []string{
+		"execution profile " + flags.ExecutionProfile().String(),
+		"memory scrubbing by " + flags.MemoryScrubbingPolicy().String(),
+		"backup action " + flags.BackupActionPolicy().String(),
+		flags.ResetAUXControl().String(),
+	}
+	if flags.IsSACMRequestedToExtendStaticPCRs() {
+		names = append(names, "S-ACM extends static PCRs")
+	}
+	return append(names, reservedBits(v, 0x800003ff)...)
+}
+
+func ibbSegmentFlagNames(v uint64) []string {
+	var names []string
+	if uint16(v)&bootpolicy.IBBSegmentFlagNotMeasured != 0 {
+		names = append(names, "not measured")
+	}
+	return append(names, reservedBits(v, uint64(bootpolicy.IBBSegmentFlagNotMeasured))...)
+}
+
+func keyUsageNames(v uint64) []string {
+	if v == 0 {
+		return nil
+	}
+	return strings.Split(key.Usage(v).String(), ",")
+}
+
+// symbolicChange returns the settings only in a prefixed with - and the ones
+// only in b prefixed with +.
+func symbolicChange(a, b []string) string {
+	var changes []string
+	for _, name := range a {
+		if !containsString(b, name) {
+			changes = append(changes, "-"+name)
+		}
+	}
+	for _, name := range b {
+		if !containsString(a, name) {
+			changes = append(changes, "+"+name)
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// WriteJSON writes the comparison as indented JSON.
+func (d *ConfigDiff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// PrettyPrint writes the changed fields grouped by header, IBB, TXT, keys
+// and platform data.
+func (d *ConfigDiff) PrettyPrint(w io.Writer) error {
+	if !d.Differ() {
+		_, err := fmt.Fprintln(w, "The configs are the same")
+		return err
+	}
+	for _, group := range configGroups {
+		printed := false
+		for _, c := range d.Changes {
+			if c.Group != group {
+				continue
+			}
+			if !printed {
+				fmt.Fprintf(w, "%s:\n", group)
+				printed = true
+			}
+			fmt.Fprintf(w, "  %s: %s -> %s\n", c.Field, c.A, c.B)
+			if c.Detail != "" {
+				fmt.Fprintf(w, "    %s\n", c.Detail)
+			}
+		}
+	}
+	return nil
+}
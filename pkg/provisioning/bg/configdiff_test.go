@@ -0,0 +1,75 @@
+package bg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	a, b := lintTestConfig(t), lintTestConfig(t)
+	if diff := DiffConfigs(a, b); diff.Differ() {
+		t.Errorf("DiffConfigs() of the same config returned %+v", diff.Changes)
+	}
+
+	// only the authority measurement bit of the SE flags differs
+	b.BootPolicyManifest.SE[0].Flags = a.BootPolicyManifest.SE[0].Flags ^ 0x04
+	diff := DiffConfigs(a, b)
+	if len(diff.Changes) != 1 {
+		t.Fatalf("DiffConfigs() returned %+v, expected the SE flags change", diff.Changes)
+	}
+	c := diff.Changes[0]
+	if c.Group != ConfigGroupIBB || c.Field != "SE 0 flags" {
+		t.Errorf("DiffConfigs() reported %s %s, expected IBB SE 0 flags", c.Group, c.Field)
+	}
+	expected := "+authority measurements into PCR7"
+	if a.BootPolicyManifest.SE[0].Flags.AuthorityMeasure() {
+		expected = "-authority measurements into PCR7"
+	}
+	if c.Detail != expected {
+		t.Errorf("DiffConfigs() decoded the flags change as %q, expected %q", c.Detail, expected)
+	}
+
+	var out bytes.Buffer
+	if err := diff.PrettyPrint(&out); err != nil {
+		t.Fatalf("PrettyPrint() failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "IBB:\n  SE 0 flags: ") || !strings.Contains(out.String(), "    "+expected+"\n") {
+		t.Errorf("PrettyPrint() wrote\n%s", out.String())
+	}
+}
+
+func TestDiffConfigsGroups(t *testing.T) {
+	a, b := lintTestConfig(t), lintTestConfig(t)
+	b.BootPolicyManifest.BPMSVN++
+	b.BootPolicyManifest.SE[0].IBBSegments = append(b.BootPolicyManifest.SE[0].IBBSegments, ibbSegment(0xffff0000, 0x1000, bootpolicy.IBBSegmentFlagNotMeasured))
+	b.KeyManifest.PubKeyHashAlg = 0
+	if a.BootPolicyManifest.TXTE != nil {
+		txt := *a.BootPolicyManifest.TXTE
+		txt.ControlFlags ^= 0x60
+		b.BootPolicyManifest.TXTE = &txt
+	}
+
+	changes := make(map[string]ConfigChange)
+	for _, c := range DiffConfigs(a, b).Changes {
+		changes[c.Group+": "+c.Field] = c
+	}
+	if c := changes["Header: BPM SVN"]; c.B == "" {
+		t.Errorf("DiffConfigs() didn't report the BPM SVN change: %+v", changes)
+	}
+	segment := changes["IBB: SE 0 IBB segment 4"]
+	if segment.A != configNone || segment.B != "0xffff0000+0x1000" {
+		t.Errorf("DiffConfigs() reported the added IBB segment as %+v", segment)
+	}
+	if _, ok := changes["Keys: OEM key hash algorithm"]; !ok {
+		t.Errorf("DiffConfigs() didn't report the OEM key hash algorithm change: %+v", changes)
+	}
+	if a.BootPolicyManifest.TXTE != nil {
+		c, ok := changes["TXT: control flags"]
+		if !ok || !strings.Contains(c.Detail, "memory scrubbing by") {
+			t.Errorf("DiffConfigs() reported the TXT control flags change as %+v", c)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MergeConfigJSON merges JSON configs in order, later configs override earlier
+// ones. Objects are merged key by key, so an overlay only needs to hold the
+// keys it changes, e.g. {"BootPolicyManifest": {"bpm_Header": {"bpmh_SNV": 2}}}.
+// Arrays, e.g. the IBB segments, and all other values replace the earlier
+// value as a whole. A null value resets the key to its default.
+func MergeConfigJSON(configs ...[]byte) ([]byte, error) {
+	var merged interface{}
+	for idx, data := range configs {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("config %d is not valid JSON: %w", idx, err)
+		}
+		if _, ok := v.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("config %d is not a JSON object", idx)
+		}
+		merged = mergeJSONValue(merged, v)
+	}
+	return json.Marshal(merged)
+}
+
+func mergeJSONValue(base, overlay interface{}) interface{} {
+	baseObj, ok := base.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	overlayObj, ok := overlay.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	for k, v := range overlayObj {
+		if v == nil {
+			delete(baseObj, k)
+			continue
+		}
+		baseObj[k] = mergeJSONValue(baseObj[k], v)
+	}
+	return baseObj
+}
+
+// ParseConfigs parses a base config and overlays, merged in order as
+// described by MergeConfigJSON. Each config may be an http(s) URL. XML configs
+// can't be merged and are only accepted on their own.
+func ParseConfigs(paths []string) (*BootGuardOptions, error) {
+	bgo, _, err := ParseConfigsData(paths)
+	return bgo, err
+}
+
+// ParseConfigsData is ParseConfigs which also returns the configs as read, in
+// the order of paths, e.g. to attest them as inputs of the build.
+func ParseConfigsData(paths []string) (*BootGuardOptions, [][]byte, error) {
+	configs := make([][]byte, len(paths))
+	for idx, path := range paths {
+		if len(paths) > 1 && strings.HasSuffix(strings.ToLower(path), ".xml") {
+			return nil, nil, fmt.Errorf("XML config %s can't be merged with other configs", path)
+		}
+		data, err := readConfig(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		configs[idx] = data
+	}
+	if len(paths) == 1 {
+		bgo, err := parseConfigData(paths[0], configs[0])
+		return bgo, configs, err
+	}
+	for idx, path := range paths {
+		if !json.Valid(configs[idx]) {
+			return nil, nil, fmt.Errorf("config %s is not valid JSON", path)
+		}
+	}
+	merged, err := MergeConfigJSON(configs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bgo BootGuardOptions
+	if err := json.Unmarshal(merged, &bgo); err != nil {
+		return nil, nil, fmt.Errorf("merged config: %w", err)
+	}
+	if err := ApplyManifestIDs(&bgo); err != nil {
+		return nil, nil, fmt.Errorf("merged config: %w", err)
+	}
+	return &bgo, configs, nil
+}
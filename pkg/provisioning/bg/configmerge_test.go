@@ -0,0 +1,101 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigsOverlay(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	base := &BootGuardOptions{KeyManifest: *km, BootPolicyManifest: *bpm}
+	baseData, err := json.Marshal(base)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	overlay := `{
+		"BootPolicyManifest": {"bpm_Header": {"bpmh_SNV": 7}},
+		"KeyManifest": {"km_SVN": 3}
+	}`
+
+	dir, err := ioutil.TempDir("", "configmerge")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	basePath := filepath.Join(dir, "base.json")
+	overlayPath := filepath.Join(dir, "overlay.json")
+	if err := ioutil.WriteFile(basePath, baseData, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := ioutil.WriteFile(overlayPath, []byte(overlay), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	merged, err := ParseConfigs([]string{basePath, overlayPath})
+	if err != nil {
+		t.Fatalf("ParseConfigs() failed: %v", err)
+	}
+	expected, err := ParseConfig(basePath)
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	expected.BootPolicyManifest.BPMSVN = 7
+	expected.KeyManifest.KMSVN = 3
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("ParseConfigs() didn't keep the fields of the base config missing in the overlay")
+	}
+
+	if _, err := ParseConfigs([]string{basePath, filepath.Join(dir, "overlay.xml")}); err == nil {
+		t.Errorf("ParseConfigs() merged an XML config")
+	}
+}
+
+func TestMergeConfigJSON(t *testing.T) {
+	for name, tc := range map[string]struct {
+		configs  []string
+		expected string
+	}{
+		"nested":         {[]string{`{"a": {"b": 1, "c": 2}}`, `{"a": {"c": 3}}`}, `{"a":{"b":1,"c":3}}`},
+		"array-replaced": {[]string{`{"a": [1, 2, 3]}`, `{"a": [4]}`}, `{"a":[4]}`},
+		"null-resets":    {[]string{`{"a": 1, "b": 2}`, `{"a": null}`}, `{"b":2}`},
+		"three-configs":  {[]string{`{"a": 1}`, `{"a": 2}`, `{"a": 3}`}, `{"a":3}`},
+		"large-number":   {[]string{`{"a": 18446744073709551615}`, `{}`}, `{"a":18446744073709551615}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			configs := make([][]byte, len(tc.configs))
+			for idx, c := range tc.configs {
+				configs[idx] = []byte(c)
+			}
+			merged, err := MergeConfigJSON(configs...)
+			if err != nil {
+				t.Fatalf("MergeConfigJSON() failed: %v", err)
+			}
+			if string(merged) != tc.expected {
+				t.Errorf("MergeConfigJSON() returned %s, expected %s", merged, tc.expected)
+			}
+		})
+	}
+	if _, err := MergeConfigJSON([]byte(`{}`), []byte(`[1]`)); err == nil {
+		t.Errorf("MergeConfigJSON() accepted a config which is not an object")
+	}
+}
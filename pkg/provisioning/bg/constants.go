@@ -5,4 +5,7 @@ const (
 	defaultStackAndDataSize    = 4096
 	defaultLLCSize             = 0x800000
 	additionalNEMSize          = 0x80000
+	// the KM and BPM signatures, the FIT and the stack CalculateNEMSize
+	// accounts for next to the IBB and the ACM
+	minNEMDataStackSize = 3*keySignatureElementMaxSize + 2048 + defaultStackAndDataSize
 )
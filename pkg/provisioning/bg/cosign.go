@@ -0,0 +1,29 @@
+package bg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxSigners is the number of signatures a KM or BPM can carry. Both formats
+// (document #575623) have exactly one key and signature structure at the end
+// of the manifest, which is covered neither by the signed region nor by any
+// count field, so there is no room for a second signer.
+const MaxSigners = 1
+
+// ErrCosignUnsupported is returned when trying to add a further signature to a manifest.
+var ErrCosignUnsupported = errors.New("co-signing is unsupported: the manifest format allows only one signature")
+
+// Cosign would add a further signature to a signed KM or BPM without
+// invalidating the existing one. Since neither format can carry more than
+// MaxSigners signatures, it validates the input and returns ErrCosignUnsupported.
+func Cosign(data []byte) error {
+	kind, size, err := ManifestSizeOf(data)
+	if err != nil {
+		return err
+	}
+	if size.KeySignature == 0 {
+		return fmt.Errorf("%s is not signed yet: %w", kind, ErrCosignUnsupported)
+	}
+	return fmt.Errorf("%s supports %d signer: %w", kind, MaxSigners, ErrCosignUnsupported)
+}
@@ -0,0 +1,23 @@
+package bg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCosignUnsupported(t *testing.T) {
+	signed := signedTestKM(t)
+	if err := Cosign(signed); !errors.Is(err, ErrCosignUnsupported) {
+		t.Errorf("Cosign() returned %v, expected ErrCosignUnsupported", err)
+	}
+	size, err := KMSize(signed)
+	if err != nil {
+		t.Fatalf("KMSize() failed: %v", err)
+	}
+	if err := Cosign(signed[:size.SignedRegion]); !errors.Is(err, ErrCosignUnsupported) {
+		t.Errorf("Cosign() returned %v on unsigned KM, expected ErrCosignUnsupported", err)
+	}
+	if err := Cosign([]byte("garbage")); err == nil || errors.Is(err, ErrCosignUnsupported) {
+		t.Errorf("Cosign() returned %v on invalid data, expected a parse error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package bg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// Region is the byte range [Offset, Offset+Size) of a manifest binary.
+type Region struct {
+	Offset int
+	Size   int
+}
+
+// End returns the offset of the first byte after the region.
+func (r Region) End() int {
+	return r.Offset + r.Size
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("[0x%04x, 0x%04x) %d bytes", r.Offset, r.End(), r.Size)
+}
+
+// SignatureCoverage describes which bytes of a KM or BPM binary are covered by
+// its signature.
+type SignatureCoverage struct {
+	// Signed is the region the signature is computed over, it always starts
+	// at offset 0 and ends at the key and signature structure.
+	Signed Region
+	// KeySignature is the key and signature structure following the signed
+	// region. It is empty for unsigned manifests.
+	KeySignature Region
+	// Key is the public key data within KeySignature.
+	Key Region
+	// Signature is the signature data within KeySignature.
+	Signature Region
+	// Trailing are the bytes after the manifest, e.g. padding, which are
+	// neither signed nor part of the signature.
+	Trailing Region
+	// HashAlg is the hash algorithm the signature is computed with: the
+	// HashAlg of the signature, or for unsigned manifests the one StitchKM
+	// and StitchBPM record, i.e. the PubKeyHashAlg of a KM and SHA256 for a
+	// BPM.
+	HashAlg manifest.Algorithm
+}
+
+// SignedBytes returns the bytes of data covered by the signature.
+func (c *SignatureCoverage) SignedBytes(data []byte) []byte {
+	return data[c.Signed.Offset:c.Signed.End()]
+}
+
+// SigningInput returns what an external signer, e.g. an HSM, has to sign for
+// data: the signed bytes for signers hashing internally, or if digest is set
+// their HashAlg digest for signers signing a pre-computed digest. Both yield
+// the same signature, which StitchKM and StitchBPM reassemble.
+func (c *SignatureCoverage) SigningInput(data []byte, digest bool) ([]byte, error) {
+	signed := c.SignedBytes(data)
+	if !digest {
+		return signed, nil
+	}
+	h, err := c.HashAlg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("unable to digest the signed bytes: %w", err)
+	}
+	h.Write(signed)
+	return h.Sum(nil), nil
+}
+
+func newSignatureCoverage(data []byte, signedRegion int, ks *manifest.KeySignature, hashAlg manifest.Algorithm) (*SignatureCoverage, error) {
+	if signedRegion > len(data) {
+		return nil, fmt.Errorf("manifest is truncated: signed region is %d bytes, but got %d bytes", signedRegion, len(data))
+	}
+	c := &SignatureCoverage{Signed: Region{Offset: 0, Size: signedRegion}, HashAlg: hashAlg}
+	end := signedRegion
+	if len(data) > signedRegion && len(ks.Signature.Data) > 0 {
+		c.HashAlg = ks.Signature.HashAlg
+		c.KeySignature = Region{Offset: signedRegion, Size: int(ks.TotalSize())}
+		c.Key = Region{
+			Offset: signedRegion + int(ks.KeyOffset()+ks.Key.DataOffset()),
+			Size:   len(ks.Key.Data),
+		}
+		c.Signature = Region{
+			Offset: signedRegion + int(ks.SignatureOffset()+ks.Signature.DataOffset()),
+			Size:   len(ks.Signature.Data),
+		}
+		end = c.KeySignature.End()
+	}
+	if end > len(data) {
+		return nil, fmt.Errorf("manifest is truncated: expected %d bytes, but got %d bytes", end, len(data))
+	}
+	c.Trailing = Region{Offset: end, Size: len(data) - end}
+	return c, nil
+}
+
+// KMCoverage returns the signed and signature regions of a key manifest binary
+// after validating its structure.
+func KMCoverage(data []byte) (*SignatureCoverage, error) {
+	return DefaultParseOptions().KMCoverage(data)
+}
+
+// KMCoverage is KMCoverage with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) KMCoverage(data []byte) (*SignatureCoverage, error) {
+	km, err := o.ParseKM(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := km.Validate(); err != nil {
+		return nil, &ParseError{Manifest: "KM", Err: err}
+	}
+	hashAlg := km.PubKeyHashAlg
+	if hashAlg.IsNull() {
+		hashAlg = manifest.AlgSHA256
+	}
+	return newSignatureCoverage(data, int(km.KeyAndSignatureOffset()), &km.KeyAndSignature, hashAlg)
+}
+
+// BPMCoverage returns the signed and signature regions of a boot policy
+// manifest binary after validating its structure.
+func BPMCoverage(data []byte) (*SignatureCoverage, error) {
+	return DefaultParseOptions().BPMCoverage(data)
+}
+
+// BPMCoverage is BPMCoverage with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) BPMCoverage(data []byte) (*SignatureCoverage, error) {
+	bpm, err := o.ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := bpm.Validate(); err != nil {
+		return nil, &ParseError{Manifest: "BPM", Err: err}
+	}
+	return newSignatureCoverage(data, int(bpm.KeySignatureOffset), &bpm.PMSE.KeySignature, manifest.AlgSHA256)
+}
+
+// SignatureCoverageOf detects whether data is a KM or a BPM and returns its
+// type and signature coverage.
+func SignatureCoverageOf(data []byte) (string, *SignatureCoverage, error) {
+	return DefaultParseOptions().SignatureCoverageOf(data)
+}
+
+// SignatureCoverageOf is SignatureCoverageOf with the options o instead of
+// the DefaultParseOptions.
+func (o ParseOptions) SignatureCoverageOf(data []byte) (string, *SignatureCoverage, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte(key.StructureIDManifest)):
+		c, err := o.KMCoverage(data)
+		return "KM", c, err
+	case bytes.HasPrefix(data, []byte(bootpolicy.StructureIDBPMH)):
+		c, err := o.BPMCoverage(data)
+		return "BPM", c, err
+	}
+	return "", nil, fmt.Errorf("data is neither a KM nor a BPM")
+}
@@ -0,0 +1,83 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestKMCoverage(t *testing.T) {
+	signed := signedTestKM(t)
+	padded, err := PadManifest(signed, uint32(len(signed)+100), 0xff)
+	if err != nil {
+		t.Fatalf("PadManifest() failed: %v", err)
+	}
+	kind, c, err := SignatureCoverageOf(padded)
+	if err != nil {
+		t.Fatalf("SignatureCoverageOf() failed: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(padded))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	if kind != "KM" || c.Signed.Offset != 0 || c.Signed.End() != int(km.KeyAndSignatureOffset()) {
+		t.Fatalf("SignatureCoverageOf() reported %s signed region %s", kind, c.Signed)
+	}
+	if c.KeySignature.Offset != c.Signed.End() || c.KeySignature.End() != len(signed) || c.Trailing.Size != 100 {
+		t.Errorf("SignatureCoverageOf() reported key and signature %s, trailing %s", c.KeySignature, c.Trailing)
+	}
+	if !bytes.Equal(padded[c.Signature.Offset:c.Signature.End()], km.KeyAndSignature.Signature.Data) {
+		t.Errorf("Signature region %s doesn't hold the signature", c.Signature)
+	}
+	if !bytes.Equal(padded[c.Key.Offset:c.Key.End()], km.KeyAndSignature.Key.Data) {
+		t.Errorf("Key region %s doesn't hold the key", c.Key)
+	}
+	if _, err := km.KeyAndSignature.VerifyAuto(c.SignedBytes(padded)); err != nil {
+		t.Errorf("Signature doesn't verify over the reported signed bytes: %v", err)
+	}
+
+	// the last signed byte is covered by the verification, the trailing bytes aren't
+	corrupted := append([]byte{}, padded...)
+	corrupted[c.Signed.End()-1] ^= 0xff
+	if _, err := VerifyKM(corrupted); err == nil {
+		t.Errorf("VerifyKM() succeeded with a corrupted signed region")
+	}
+	corrupted = append([]byte{}, padded...)
+	corrupted[c.Trailing.Offset] ^= 0xff
+	if _, err := VerifyKM(corrupted); err != nil {
+		t.Errorf("VerifyKM() failed with corrupted trailing bytes: %v", err)
+	}
+}
+
+func TestBPMCoverage(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	c, err := BPMCoverage(data)
+	if err != nil {
+		t.Fatalf("BPMCoverage() failed: %v", err)
+	}
+	size, err := BPMSize(data)
+	if err != nil {
+		t.Fatalf("BPMSize() failed: %v", err)
+	}
+	if c.Signed.Size != size.SignedRegion || c.KeySignature.Size != size.KeySignature || c.Trailing.Size != 0 {
+		t.Errorf("BPMCoverage() reported signed region %s and key and signature %s, expected %s", c.Signed, c.KeySignature, size)
+	}
+	if c.Signature.End() != c.KeySignature.End() {
+		t.Errorf("Signature region %s doesn't end the key and signature %s", c.Signature, c.KeySignature)
+	}
+}
+
+func TestSignatureCoverageOfGarbage(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("__ACBP__"),
+		append([]byte("__ACBP__"), bytes.Repeat([]byte{0xff}, 256)...),
+		append([]byte("__KEYM__"), make([]byte, 256)...),
+	} {
+		if kind, c, err := SignatureCoverageOf(data); err == nil {
+			t.Errorf("SignatureCoverageOf(%q...) returned %s coverage %+v", data[:8], kind, c)
+		}
+	}
+}
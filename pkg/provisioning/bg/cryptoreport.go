@@ -0,0 +1,213 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// deprecatedAlgorithms are the algorithms crypto policies don't allow anymore.
+var deprecatedAlgorithms = map[manifest.Algorithm]bool{
+	manifest.AlgSHA1: true,
+}
+
+// cryptoKindTPMHash is the kind of the TPM algorithms an ACM supports. The
+// ACM extends the PCRs of every bank the TPM has, these algorithms are only
+// offered and never flagged as deprecated.
+const cryptoKindTPMHash = "tpm-hash"
+
+// CryptoAlgorithm is a hash, key or signature algorithm found in a firmware
+// image with the locations it is used at.
+type CryptoAlgorithm struct {
+	Algorithm  AlgorithmField `json:"algorithm"`
+	Kind       string         `json:"kind"`
+	Count      int            `json:"count"`
+	Deprecated bool           `json:"deprecated"`
+	Locations  []string       `json:"locations"`
+}
+
+// CryptoReport is the inventory of the algorithms used by the KM, BPM and ACM
+// of a firmware image.
+type CryptoReport struct {
+	Algorithms []CryptoAlgorithm `json:"algorithms"`
+	// Missing lists the structures which are absent or don't parse, with
+	// the reason.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Deprecated returns the deprecated algorithms of the report.
+func (r *CryptoReport) Deprecated() []CryptoAlgorithm {
+	var deprecated []CryptoAlgorithm
+	for _, a := range r.Algorithms {
+		if a.Deprecated {
+			deprecated = append(deprecated, a)
+		}
+	}
+	return deprecated
+}
+
+func (r *CryptoReport) add(kind string, alg manifest.Algorithm, location string) {
+	if alg.IsNull() {
+		return
+	}
+	for idx := range r.Algorithms {
+		a := &r.Algorithms[idx]
+		if a.Kind == kind && a.Algorithm.ID == alg {
+			a.Count++
+			a.Locations = append(a.Locations, location)
+			return
+		}
+	}
+	r.Algorithms = append(r.Algorithms, CryptoAlgorithm{
+		Algorithm:  NewAlgorithmField(alg),
+		Kind:       kind,
+		Count:      1,
+		Deprecated: deprecatedAlgorithms[alg] && kind != cryptoKindTPMHash,
+		Locations:  []string{location},
+	})
+}
+
+func (r *CryptoReport) missing(format string, args ...interface{}) {
+	r.Missing = append(r.Missing, fmt.Sprintf(format, args...))
+}
+
+// CryptoReportOfImage returns the algorithm inventory of the KM, BPM and ACM
+// referenced by the FIT of a firmware image. Structures which are missing are
+// listed as such instead of failing the report.
+func CryptoReportOfImage(image []byte) *CryptoReport {
+	return DefaultParseOptions().CryptoReportOfImage(image)
+}
+
+// CryptoReportOfImage is CryptoReportOfImage with the options o instead of
+// the DefaultParseOptions.
+func (o ParseOptions) CryptoReportOfImage(image []byte) *CryptoReport {
+	bpm, km, acm, err := o.ParseFITEntries(image)
+	if err != nil {
+		r := &CryptoReport{}
+		r.missing("KM, BPM and ACM: %v", err)
+		return r
+	}
+	return o.NewCryptoReport(km, bpm, acm)
+}
+
+// NewCryptoReport returns the algorithm inventory of the given KM, BPM and
+// ACM binaries, any of which may be nil.
+func NewCryptoReport(kmData, bpmData, acmData []byte) *CryptoReport {
+	return DefaultParseOptions().NewCryptoReport(kmData, bpmData, acmData)
+}
+
+// NewCryptoReport is NewCryptoReport with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) NewCryptoReport(kmData, bpmData, acmData []byte) *CryptoReport {
+	r := &CryptoReport{Algorithms: []CryptoAlgorithm{}}
+	r.addKM(o, kmData)
+	r.addBPM(o, bpmData)
+	r.addACM(acmData)
+	return r
+}
+
+func (r *CryptoReport) addKM(o ParseOptions, data []byte) {
+	if len(data) == 0 {
+		r.missing("KM: not present")
+		return
+	}
+	km, err := o.ParseKM(bytes.NewReader(data))
+	if err != nil {
+		r.missing("KM: %v", err)
+		return
+	}
+	r.add("hash", km.PubKeyHashAlg, "KM public key hash algorithm")
+	for idx, h := range km.Hash {
+		r.add("hash", h.Digest.HashAlg, fmt.Sprintf("KM hash[%d] (%s)", idx, h.Usage))
+	}
+	r.addKeySignature("KM", &km.KeyAndSignature)
+}
+
+func (r *CryptoReport) addBPM(o ParseOptions, data []byte) {
+	if len(data) == 0 {
+		r.missing("BPM: not present")
+		return
+	}
+	bpm, err := o.ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		r.missing("BPM: %v", err)
+		return
+	}
+	for idx, se := range bpm.SE {
+		for digestIdx, d := range se.DigestList.List {
+			r.add("hash", d.HashAlg, fmt.Sprintf("BPM SE[%d] IBB digest[%d]", idx, digestIdx))
+		}
+		r.add("hash", se.PostIBBHash.HashAlg, fmt.Sprintf("BPM SE[%d] post IBB hash", idx))
+		r.add("hash", se.OBBHash.HashAlg, fmt.Sprintf("BPM SE[%d] OBB hash", idx))
+	}
+	if bpm.TXTE != nil {
+		for idx, d := range bpm.TXTE.DigestList.List {
+			r.add("hash", d.HashAlg, fmt.Sprintf("BPM TXT digest[%d]", idx))
+		}
+	}
+	r.addKeySignature("BPM", &bpm.PMSE.KeySignature)
+}
+
+func (r *CryptoReport) addKeySignature(name string, ks *manifest.KeySignature) {
+	r.add("key", ks.Key.KeyAlg, fmt.Sprintf("%s signing key (%d bits)", name, ks.Key.KeySize.InBits()))
+	r.add("signature", ks.Signature.SigScheme, fmt.Sprintf("%s signature", name))
+	r.add("hash", ks.Signature.HashAlg, fmt.Sprintf("%s signature hash", name))
+}
+
+func (r *CryptoReport) addACM(data []byte) {
+	if len(data) == 0 {
+		r.missing("ACM: not present")
+		return
+	}
+	header, err := tools.ParseACMHeader(data)
+	if err != nil {
+		r.missing("ACM: %v", err)
+		return
+	}
+	scheme, hashAlg := acmSignatureAlgorithms(header)
+	r.add("key", manifest.AlgRSA, fmt.Sprintf("ACM signing key (%d bits)", header.KeySize*32))
+	r.add("signature", scheme, "ACM signature")
+	r.add("hash", hashAlg, "ACM signature hash")
+	algs, err := ACMHashAlgorithms(data)
+	if err != nil {
+		r.missing("ACM TPM algorithm list: %v", err)
+		return
+	}
+	for _, alg := range algs {
+		r.add(cryptoKindTPMHash, alg, "ACM supported TPM algorithms")
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *CryptoReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// PrettyPrint writes one line per algorithm followed by the missing
+// structures.
+func (r *CryptoReport) PrettyPrint(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Kind\tAlgorithm\tCount\tLocations")
+	for _, a := range r.Algorithms {
+		name := a.Algorithm.Name
+		if a.Deprecated {
+			name += " (deprecated)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", a.Kind, name, a.Count, strings.Join(a.Locations, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, m := range r.Missing {
+		fmt.Fprintf(w, "missing: %s\n", m)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+func TestCryptoReport(t *testing.T) {
+	km, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	r := NewCryptoReport(km, bpm, acm)
+
+	deprecated := r.Deprecated()
+	if len(deprecated) != 1 || deprecated[0].Algorithm.Name != "SHA1" {
+		t.Fatalf("Deprecated() returned %+v, expected SHA1", deprecated)
+	}
+	if !containsString(deprecated[0].Locations, "BPM SE[0] IBB digest[1]") || deprecated[0].Count != len(deprecated[0].Locations) {
+		t.Errorf("SHA1 is reported at %q with count %d, expected the second IBB digest", deprecated[0].Locations, deprecated[0].Count)
+	}
+	// the TPM algorithms the ACM supports aren't used by the manifests
+	r.add(cryptoKindTPMHash, manifest.AlgSHA1, "ACM supported TPM algorithms")
+	if deprecated := r.Deprecated(); len(deprecated) != 1 || containsString(deprecated[0].Locations, "ACM supported TPM algorithms") {
+		t.Errorf("Deprecated() returned %+v, expected SHA1 of the ACM TPM algorithms not to be flagged", deprecated)
+	}
+	var acmSignature bool
+	for _, a := range r.Algorithms {
+		if a.Kind == "signature" && containsString(a.Locations, "ACM signature") {
+			acmSignature = a.Algorithm.Name == "RSASSA"
+		}
+	}
+	if !acmSignature {
+		t.Errorf("the signature of the version 0 ACM header is not reported as RSASSA")
+	}
+
+	var out bytes.Buffer
+	if err := r.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	var parsed CryptoReport
+	if err := json.Unmarshal(out.Bytes(), &parsed); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if len(parsed.Algorithms) != len(r.Algorithms) {
+		t.Errorf("the JSON report holds %d algorithms, expected %d", len(parsed.Algorithms), len(r.Algorithms))
+	}
+	out.Reset()
+	if err := r.PrettyPrint(&out); err != nil {
+		t.Fatalf("PrettyPrint() failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "SHA1 (deprecated)") {
+		t.Errorf("PrettyPrint() doesn't flag SHA1:\n%s", out.String())
+	}
+}
+
+func TestCryptoReportMissing(t *testing.T) {
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	r := NewCryptoReport(nil, bpm, []byte{1, 2, 3})
+	if len(r.Missing) != 2 || !strings.HasPrefix(r.Missing[0], "KM:") || !strings.HasPrefix(r.Missing[1], "ACM:") {
+		t.Errorf("Missing is %q, expected the KM and the ACM", r.Missing)
+	}
+	if len(r.Algorithms) == 0 {
+		t.Errorf("the algorithms of the BPM are missing")
+	}
+}
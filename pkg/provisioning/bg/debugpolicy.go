@@ -0,0 +1,81 @@
+package bg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// ErrDebugEnabled is returned by DebugPolicy.Err if the manifests leave debug
+// interfaces enabled.
+var ErrDebugEnabled = errors.New("debug interfaces are left enabled")
+
+// DebugPolicy is the debug interface posture of a BPM and its ACM.
+//
+// The BPM has no DCI or DAM bits, these are set by the PCH soft straps and
+// the fuses. What the manifests control is whether the S-ACM is a debug
+// build, which keeps the debug interfaces unlocked, and whether devices,
+// including DCI attached debug hosts, can access memory while the IBB runs.
+type DebugPolicy struct {
+	Fields   []TXTField
+	Findings []string
+}
+
+// DecodeDebugPolicy decodes the debug related flags of the IBB elements of
+// the BPM and, if acm is not nil, of the ACM header and reports the flags
+// leaving debug interfaces enabled in a production manifest.
+func DecodeDebugPolicy(bpm *bootpolicy.Manifest, acm *tools.ACMHeader) *DebugPolicy {
+	p := &DebugPolicy{}
+	findingf := func(format string, args ...interface{}) {
+		p.Findings = append(p.Findings, fmt.Sprintf(format, args...))
+	}
+
+	for idx, se := range bpm.SE {
+		name := fmt.Sprintf("SE %d DMA Protection", idx)
+		if se.Flags.DMAProtection() {
+			p.Fields = append(p.Fields, TXTField{Name: name, Value: "enabled", Meaning: "the IBB is protected against DMA"})
+			continue
+		}
+		p.Fields = append(p.Fields, TXTField{Name: name, Value: "disabled", Meaning: "devices and debug hosts can access memory while the IBB runs"})
+		findingf("SE %d leaves DMA protection disabled (SE flags 0x%08x)", idx, uint32(se.Flags))
+	}
+
+	if acm == nil {
+		return p
+	}
+	flags := acm.ParseACMFlags()
+	value, meaning := "production", "debug interfaces are locked by the S-ACM"
+	switch {
+	case flags.DebugSigned:
+		value, meaning = "debug signed", "debug interfaces stay unlocked"
+		findingf("the ACM is debug signed (ACM flags 0x%04x)", acm.Flags)
+	case flags.PreProduction:
+		value, meaning = "pre-production", "debug interfaces stay unlocked"
+		findingf("the ACM is a pre-production build (ACM flags 0x%04x)", acm.Flags)
+	}
+	p.Fields = append(p.Fields, TXTField{Name: "ACM Build", Value: value, Meaning: meaning})
+	return p
+}
+
+// Err returns an error wrapping ErrDebugEnabled listing the findings, if any.
+func (p *DebugPolicy) Err() error {
+	if len(p.Findings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrDebugEnabled, strings.Join(p.Findings, ", "))
+}
+
+// PrettyPrint writes the decoded flags followed by the findings.
+func (p *DebugPolicy) PrettyPrint(w io.Writer) {
+	fmt.Fprintln(w, "Debug policy:")
+	for _, f := range p.Fields {
+		fmt.Fprintf(w, "  %-22s %-15s %s\n", f.Name+":", f.Value, f.Meaning)
+	}
+	for _, finding := range p.Findings {
+		fmt.Fprintf(w, "WARNING: %s\n", finding)
+	}
+}
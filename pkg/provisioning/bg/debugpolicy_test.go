@@ -0,0 +1,57 @@
+package bg
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+func TestDecodeDebugPolicy(t *testing.T) {
+	f, err := os.Open(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+	bpm, err := ParseBPM(f)
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+
+	production := &tools.ACMHeader{}
+	for idx := range bpm.SE {
+		bpm.SE[idx].Flags |= 0x01
+	}
+	p := DecodeDebugPolicy(bpm, production)
+	if len(p.Findings) != 0 || p.Err() != nil {
+		t.Errorf("DecodeDebugPolicy() of a production manifest returned %q", p.Findings)
+	}
+	if len(p.Fields) != len(bpm.SE)+1 {
+		t.Errorf("DecodeDebugPolicy() returned %d fields, expected %d", len(p.Fields), len(bpm.SE)+1)
+	}
+
+	// a debug signed ACM and an SE without DMA protection
+	acm, err := tools.ParseACMHeader(NewStubACM(StubACMParams{}))
+	if err != nil {
+		t.Fatalf("ParseACMHeader() failed: %v", err)
+	}
+	bpm.SE[0].Flags &^= 0x01
+	p = DecodeDebugPolicy(bpm, acm)
+	if len(p.Findings) != 2 {
+		t.Errorf("DecodeDebugPolicy() of debug enabled flags returned %q, expected 2 findings", p.Findings)
+	}
+	if err := p.Err(); !errors.Is(err, ErrDebugEnabled) {
+		t.Errorf("Err() returned %v, expected ErrDebugEnabled", err)
+	}
+
+	preProduction := &tools.ACMHeader{Flags: 1 << 14}
+	p = DecodeDebugPolicy(&bootpolicy.Manifest{}, preProduction)
+	if len(p.Findings) != 1 || p.Fields[0].Value != "pre-production" {
+		t.Errorf("DecodeDebugPolicy() of a pre-production ACM returned %+v", p)
+	}
+	if p = DecodeDebugPolicy(&bootpolicy.Manifest{}, nil); len(p.Fields) != 0 {
+		t.Errorf("DecodeDebugPolicy() without SE and ACM returned %+v", p.Fields)
+	}
+}
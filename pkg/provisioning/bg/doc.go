@@ -0,0 +1,16 @@
+// Package bg generates, signs, stitches and verifies the Key Manifest and the
+// Boot Policy Manifest of Intel BootGuard.
+//
+// Concurrency: the functions of the package may be called concurrently as
+// long as every goroutine works on its own manifests and buffers. Parsing
+// and serializing don't share mutable state, so distinct manifests can be
+// parsed, generated and written in parallel. A manifest is not safe for
+// concurrent use while it is modified, e.g. by RehashRecursive or by
+// stitching its signature.
+//
+// The package has no process-wide settings. The checks, the image layout and
+// the collector of the warnings are selected per call, by the ParseOptions a
+// method is called on and by StitchOptions and LintOptions. The functions of
+// the same name as the ParseOptions methods use the DefaultParseOptions.
+// The functions of the package don't print, except for the Print ones.
+package bg
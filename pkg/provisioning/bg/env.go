@@ -0,0 +1,133 @@
+package bg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// EnvVar is a manifest field as shell variable assignment.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// String returns the assignment, quoting the value if the shell would
+// interpret it.
+func (v EnvVar) String() string {
+	return v.Name + "=" + shellQuote(v.Value)
+}
+
+// KMEnv returns the important fields of the KM as shell variables. Numbers
+// are decimal, algorithms are named as by show-km and hashes are lowercase
+// hex. The variables are, in this order:
+//
+//	BG_KM_REVISION         the KM revision
+//	BG_KM_SVN              the KM SVN
+//	BG_KM_ID               the KM ID
+//	BG_KM_PUBKEY_HASH_ALG  the algorithm of the OEM key hash fused into the FPFs
+//	BG_KM_KEY_ALG          the algorithm of the KM signing key
+//	BG_KM_SIGNED           1 if the KM carries a signature, 0 for a placeholder
+//	BG_KM_BPM_KEY_HASH_ALG the algorithm of the BPM key hash, if the KM has one
+//	BG_KM_BPM_KEY_HASH     the BPM key hash, if the KM has one
+func KMEnv(km *key.Manifest) []EnvVar {
+	vars := []EnvVar{
+		{"BG_KM_REVISION", fmt.Sprint(km.Revision)},
+		{"BG_KM_SVN", fmt.Sprint(km.KMSVN.SVN())},
+		{"BG_KM_ID", fmt.Sprint(km.KMID)},
+		{"BG_KM_PUBKEY_HASH_ALG", km.PubKeyHashAlg.String()},
+		{"BG_KM_KEY_ALG", km.KeyAndSignature.Key.KeyAlg.String()},
+		{"BG_KM_SIGNED", envBool(!IsPlaceholderSignature(km.KeyAndSignature.Signature.Data))},
+	}
+	for _, h := range km.Hash {
+		if h.Usage.IsSet(key.UsageBPMSigningPKD) {
+			vars = append(vars,
+				EnvVar{"BG_KM_BPM_KEY_HASH_ALG", h.Digest.HashAlg.String()},
+				EnvVar{"BG_KM_BPM_KEY_HASH", fmt.Sprintf("%x", h.Digest.HashBuffer)},
+			)
+			break
+		}
+	}
+	return vars
+}
+
+// BPMEnv returns the important fields of the BPM as shell variables in the
+// format of KMEnv. The variables are, in this order:
+//
+//	BG_BPM_REVISION        the BPM revision
+//	BG_BPM_SVN             the BPM SVN
+//	BG_ACM_SVN             the minimum ACM SVN the BPM authorizes
+//	BG_BPM_NEM_STACK_SIZE  the NEM data stack size in bytes
+//	BG_BPM_KEY_ALG         the algorithm of the BPM signing key
+//	BG_BPM_SIG_HASH_ALG    the hash algorithm of the BPM signature
+//	BG_BPM_SIGNED          1 if the BPM carries a signature, 0 for a placeholder
+//	BG_BPM_IBB_ENTRY       the IBB entry point of the first IBBS element in hex
+//	BG_BPM_IBB_DIGEST_*    the IBB digests of the first IBBS element, one per
+//	                       algorithm, e.g. BG_BPM_IBB_DIGEST_SHA256, or
+//	                       BG_BPM_IBB_DIGEST_ALG<id> for unknown algorithms
+func BPMEnv(bpm *bootpolicy.Manifest) []EnvVar {
+	vars := []EnvVar{
+		{"BG_BPM_REVISION", fmt.Sprint(bpm.BPMH.BPMRevision)},
+		{"BG_BPM_SVN", fmt.Sprint(bpm.BPMH.BPMSVN.SVN())},
+		{"BG_ACM_SVN", fmt.Sprint(bpm.BPMH.ACMSVNAuth.SVN())},
+		{"BG_BPM_NEM_STACK_SIZE", fmt.Sprint(bpm.BPMH.NEMDataStack.InBytes())},
+		{"BG_BPM_KEY_ALG", bpm.PMSE.Key.KeyAlg.String()},
+		{"BG_BPM_SIG_HASH_ALG", bpm.PMSE.Signature.HashAlg.String()},
+		{"BG_BPM_SIGNED", envBool(!IsPlaceholderSignature(bpm.PMSE.Signature.Data))},
+	}
+	if len(bpm.SE) > 0 {
+		se := bpm.SE[0]
+		vars = append(vars, EnvVar{"BG_BPM_IBB_ENTRY", fmt.Sprintf("0x%08x", se.IBBEntryPoint)})
+		for _, d := range se.DigestList.List {
+			vars = append(vars, EnvVar{"BG_BPM_IBB_DIGEST_" + envAlgName(d.HashAlg), fmt.Sprintf("%x", d.HashBuffer)})
+		}
+	}
+	return vars
+}
+
+// WriteEnv writes one assignment per line, suitable for sourcing or eval in a
+// POSIX shell.
+func WriteEnv(w io.Writer, vars []EnvVar) error {
+	for _, v := range vars {
+		if _, err := fmt.Fprintln(w, v.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envAlgName returns the name of alg usable in a variable name.
+func envAlgName(alg manifest.Algorithm) string {
+	name := alg.String()
+	if strings.HasPrefix(name, "Alg?") {
+		return fmt.Sprintf("ALG%d", int(alg))
+	}
+	return strings.ToUpper(name)
+}
+
+func envBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// shellQuote returns s single quoted unless it only consists of characters
+// the shell doesn't interpret.
+func shellQuote(s string) string {
+	safe := s != ""
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_-.:/", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,111 @@
+package bg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// sourceEnv evaluates the assignments written by WriteEnv with sh and returns
+// the values of the variables as the shell sees them.
+func sourceEnv(t *testing.T, vars []EnvVar) []string {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell found, skipping the evaluation")
+	}
+	var script bytes.Buffer
+	if err := WriteEnv(&script, vars); err != nil {
+		t.Fatalf("WriteEnv() failed: %v", err)
+	}
+	for _, v := range vars {
+		fmt.Fprintf(&script, "printf '%%s\\n' \"$%s\"\n", v.Name)
+	}
+	out, err := exec.Command(sh, "-c", script.String()).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sh failed to evaluate the assignments: %v\n%s", err, out)
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+}
+
+func TestKMEnv(t *testing.T) {
+	data, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	vars := KMEnv(km)
+	env := make(map[string]string)
+	for idx, value := range sourceEnv(t, vars) {
+		if !strings.HasPrefix(vars[idx].Name, "BG_KM_") {
+			t.Errorf("KMEnv() returned %s without the BG_KM_ prefix", vars[idx].Name)
+		}
+		env[vars[idx].Name] = value
+	}
+	for name, expected := range map[string]string{
+		"BG_KM_REVISION":        fmt.Sprint(km.Revision),
+		"BG_KM_SVN":             fmt.Sprint(km.KMSVN.SVN()),
+		"BG_KM_ID":              fmt.Sprint(km.KMID),
+		"BG_KM_PUBKEY_HASH_ALG": km.PubKeyHashAlg.String(),
+		"BG_KM_KEY_ALG":         km.KeyAndSignature.Key.KeyAlg.String(),
+		"BG_KM_SIGNED":          envBool(!IsPlaceholderSignature(km.KeyAndSignature.Signature.Data)),
+		"BG_KM_BPM_KEY_HASH":    fmt.Sprintf("%x", km.Hash[0].Digest.HashBuffer),
+	} {
+		if env[name] != expected {
+			t.Errorf("%s is %q, expected %q", name, env[name], expected)
+		}
+	}
+}
+
+func TestBPMEnv(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	vars := BPMEnv(bpm)
+	env := make(map[string]string)
+	for idx, value := range sourceEnv(t, vars) {
+		env[vars[idx].Name] = value
+	}
+	expected := map[string]string{
+		"BG_BPM_REVISION":       fmt.Sprint(bpm.BPMRevision),
+		"BG_BPM_SVN":            fmt.Sprint(bpm.BPMSVN.SVN()),
+		"BG_ACM_SVN":            fmt.Sprint(bpm.ACMSVNAuth.SVN()),
+		"BG_BPM_NEM_STACK_SIZE": fmt.Sprint(bpm.NEMDataStack.InBytes()),
+		"BG_BPM_SIG_HASH_ALG":   bpm.PMSE.Signature.HashAlg.String(),
+		"BG_BPM_IBB_ENTRY":      fmt.Sprintf("0x%08x", bpm.SE[0].IBBEntryPoint),
+	}
+	for _, d := range bpm.SE[0].DigestList.List {
+		expected["BG_BPM_IBB_DIGEST_"+strings.ToUpper(d.HashAlg.String())] = fmt.Sprintf("%x", d.HashBuffer)
+	}
+	for name, value := range expected {
+		if env[name] != value {
+			t.Errorf("%s is %q, expected %q", name, env[name], value)
+		}
+	}
+}
+
+func TestEnvVarQuoting(t *testing.T) {
+	vars := []EnvVar{
+		{"BG_PLAIN", "SHA256"},
+		{"BG_EMPTY", ""},
+		{"BG_SPECIAL", `it's $HOME; "quoted"`},
+	}
+	if s := vars[0].String(); s != "BG_PLAIN=SHA256" {
+		t.Errorf("String() returned %s, expected the value unquoted", s)
+	}
+	for idx, value := range sourceEnv(t, vars) {
+		if value != vars[idx].Value {
+			t.Errorf("%s is %q after evaluation, expected %q", vars[idx].Name, value, vars[idx].Value)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package bg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsignedManifest is returned when verifying a KM or BPM which was never
+// signed.
+var ErrUnsignedManifest = errors.New("the manifest is not signed")
+
+// ErrSizeMismatch is returned if a KM, BPM or ACM doesn't have the size it is
+// required to have, e.g. to fit the FIT entry it is stitched into.
+var ErrSizeMismatch = errors.New("size mismatch")
+
+// ErrOutOfBounds is returned if a region the FIT points to doesn't lie fully
+// within the firmware image, e.g. because the flash descriptor doesn't match
+// the size of the image.
+var ErrOutOfBounds = errors.New("region out of the image bounds")
+
+// ErrStructureID is returned if a binary doesn't start with the structure ID
+// of the manifest it is parsed as, e.g. an erased flash region.
+var ErrStructureID = errors.New("unexpected structure ID")
+
+// ErrDuplicateKMID is returned if several KM entries of the FIT reference KMs
+// with the same KM ID. A FIT may hold a KM for each KM ID, the ACM uses the
+// one with the KM ID the fuses select, so it's undefined which of several KMs
+// with that ID it uses.
+var ErrDuplicateKMID = errors.New("FIT: several KMs with the same KM ID")
+
+// ParseError is returned if a KM or BPM binary can't be parsed.
+type ParseError struct {
+	// Manifest is "KM" or "BPM".
+	Manifest string
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Manifest, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// SignatureError is returned if the signature of a KM or BPM doesn't verify.
+type SignatureError struct {
+	// Manifest is "KM" or "BPM".
+	Manifest string
+	Err      error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("invalid %s signature: %v", e.Manifest, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
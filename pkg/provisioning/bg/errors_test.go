@@ -0,0 +1,57 @@
+package bg
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	km, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	var parseErr *ParseError
+	if _, err := ParseKM(bytes.NewReader(bpm)); !errors.As(err, &parseErr) || parseErr.Manifest != "KM" {
+		t.Errorf("ParseKM() of a BPM returned %v, expected a KM ParseError", err)
+	}
+	if _, err := VerifyBPM(bpm[:100]); !errors.As(err, &parseErr) || parseErr.Manifest != "BPM" {
+		t.Errorf("VerifyBPM() of a truncated BPM returned %v, expected a BPM ParseError", err)
+	}
+	if _, err := ParseKM(bytes.NewReader(km)); err != nil {
+		t.Errorf("ParseKM() failed: %v", err)
+	}
+}
+
+func TestSignatureErrors(t *testing.T) {
+	signed := signedTestKM(t)
+	c, err := KMCoverage(signed)
+	if err != nil {
+		t.Fatalf("KMCoverage() failed: %v", err)
+	}
+
+	corrupted := append([]byte{}, signed...)
+	corrupted[c.Signature.Offset] ^= 0xff
+	var sigErr *SignatureError
+	if _, err := VerifyKM(corrupted); !errors.As(err, &sigErr) || sigErr.Manifest != "KM" {
+		t.Errorf("VerifyKM() with a corrupted signature returned %v, expected a KM SignatureError", err)
+	}
+
+	zeroed := append([]byte{}, signed...)
+	for idx := c.Signature.Offset; idx < c.Signature.End(); idx++ {
+		zeroed[idx] = 0
+	}
+	_, err = VerifyKM(zeroed)
+	if !errors.Is(err, ErrUnsignedManifest) || errors.As(err, &sigErr) {
+		t.Errorf("VerifyKM() with a zeroed signature returned %v, expected %v", err, ErrUnsignedManifest)
+	}
+
+	if _, err := PadManifest(signed, uint32(len(signed)-1), 0); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("PadManifest() below the manifest size returned %v, expected %v", err, ErrSizeMismatch)
+	}
+}
@@ -0,0 +1,275 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// FITEntryReport is a FIT entry annotated with its BootGuard role and whether
+// the structure it references parses.
+type FITEntryReport struct {
+	Index   int    `json:"index"`
+	Type    uint16 `json:"type"`
+	Name    string `json:"name"`
+	Address uint64 `json:"address"`
+	Size    uint32 `json:"size"`
+	Role    string `json:"role"`
+	// Checked is set if the referenced structure was parsed, Error is set
+	// if that failed.
+	Checked bool   `json:"checked"`
+	Error   string `json:"error,omitempty"`
+	// Checksum decodes the C_V bit and tells whether the checksum is valid.
+	Checksum        string `json:"checksum"`
+	ChecksumInvalid bool   `json:"checksum_invalid,omitempty"`
+}
+
+// FITReport is the annotated FIT of a firmware image.
+type FITReport struct {
+	Entries []FITEntryReport `json:"entries"`
+	// Duplicates lists the unique entry types appearing more than once.
+	Duplicates string `json:"duplicates,omitempty"`
+}
+
+// Pass returns false if a referenced structure failed to parse, an entry
+// with the C_V bit set has a wrong checksum or a unique entry type is
+// duplicated.
+func (r *FITReport) Pass() bool {
+	if r.Duplicates != "" {
+		return false
+	}
+	for _, e := range r.Entries {
+		if e.Error != "" || e.ChecksumInvalid {
+			return false
+		}
+	}
+	return true
+}
+
+var fitEntryRoles = map[tools.FitEntryType]string{
+	tools.FitHeader:          "FIT header",
+	tools.MCUpdate:           "loaded by the CPU before the ACM can run",
+	tools.StartUpACMod:       "verifies the KM and BPM and measures the IBB",
+	tools.BIOSStartUpMod:     "not used by BootGuard",
+	tools.TPMPolicyRec:       "TPM type and interface policy",
+	tools.TXTPolicyRec:       "TXT enable policy",
+	tools.KeyManifestRec:     "signed by the OEM key fused into the FPF, holds the BPM key hash",
+	tools.BootPolicyManifest: "defines the IBB segments and digests the ACM verifies",
+	tools.UnusedEntry:        "skipped",
+}
+
+// AnnotateFIT lists the FIT entries of a firmware image with their BootGuard
+// role and parses the ACMs, KMs and BPMs they reference.
+func AnnotateFIT(image []byte) (*FITReport, error) {
+	return DefaultParseOptions().AnnotateFIT(image)
+}
+
+// AnnotateFIT is AnnotateFIT with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) AnnotateFIT(image []byte) (*FITReport, error) {
+	entries, err := tools.ExtractFit(image)
+	if err != nil {
+		return nil, err
+	}
+	return o.annotateFIT(image, entries, func(addr uint64) (uint64, error) {
+		return tools.CalcImageOffsetWithLayout(image, addr, o.Layout)
+	}), nil
+}
+
+func (o ParseOptions) annotateFIT(image []byte, entries []tools.FitEntry, imageOffset func(uint64) (uint64, error)) *FITReport {
+	r := &FITReport{}
+	read := fitMemoryReader(image, imageOffset)
+	for idx, entry := range entries {
+		e := FITEntryReport{
+			Index:   idx,
+			Type:    uint16(entry.Type()),
+			Name:    entry.Type().String(),
+			Address: entry.Address,
+			Size:    entry.Size(),
+			Role:    fitEntryRoles[entry.Type()],
+		}
+		if e.Role == "" {
+			e.Role = "not used by BootGuard"
+		}
+		if entry.Type() == tools.FitHeader {
+			// ExtractFit rejects a FIT with a wrong table checksum
+			e.Checksum = "C_V clear, checksum ignored"
+			if entry.CheckSumValid() {
+				e.Checksum = "C_V set, covers the whole table"
+			}
+		} else {
+			status := tools.FitChecksumIgnored
+			if entry.CheckSumValid() {
+				status = tools.FitChecksumInvalid
+				component, err := entry.Component(read)
+				if err != nil {
+					e.Error = fmt.Sprintf("unable to read the checksummed component: %v", err)
+				} else {
+					status = entry.ChecksumStatus(component)
+				}
+			}
+			e.Checksum = status.String()
+			e.ChecksumInvalid = status == tools.FitChecksumInvalid
+		}
+		switch entry.Type() {
+		case tools.StartUpACMod, tools.KeyManifestRec, tools.BootPolicyManifest:
+			e.Checked = true
+			if err := o.checkFITEntry(image, entry, imageOffset); err != nil {
+				e.Error = err.Error()
+			}
+		}
+		r.Entries = append(r.Entries, e)
+	}
+	var duplicates []string
+	if err := tools.VerifyFIT(entries, nil); errors.Is(err, tools.ErrFITDuplicateEntry) {
+		duplicates = append(duplicates, err.Error())
+	}
+	if err := verifyKMIDs(entries, read); err != nil {
+		duplicates = append(duplicates, err.Error())
+	}
+	r.Duplicates = strings.Join(duplicates, "; ")
+	return r
+}
+
+// kmEntriesByID returns the indices of the KM entries of the FIT by the KM ID
+// of the KM they reference. KMs which don't parse are skipped, annotateFIT
+// reports them.
+func kmEntriesByID(entries []tools.FitEntry, read tools.FitMemoryReader) map[uint8][]int {
+	byID := make(map[uint8][]int)
+	for idx := range entries {
+		entry := &entries[idx]
+		if entry.Type() != tools.KeyManifestRec {
+			continue
+		}
+		data, err := entry.Component(read)
+		if err != nil {
+			continue
+		}
+		km, err := ParseKM(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		byID[km.KMID] = append(byID[km.KMID], idx)
+	}
+	return byID
+}
+
+// verifyKMIDs returns an error wrapping ErrDuplicateKMID if several KM
+// entries reference KMs with the same KM ID, e.g. in a double-stitched image.
+func verifyKMIDs(entries []tools.FitEntry, read tools.FitMemoryReader) error {
+	byID := kmEntriesByID(entries, read)
+	var msgs []string
+	for id := 0; id <= 0xff; id++ {
+		if idx := byID[uint8(id)]; len(idx) > 1 {
+			msgs = append(msgs, fmt.Sprintf("KM ID %d in entries %v", id, idx))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrDuplicateKMID, strings.Join(msgs, ", "))
+}
+
+// fitMemoryReader reads the components of FIT entries from the image,
+// imageOffset maps their addresses to image offsets.
+func fitMemoryReader(image []byte, imageOffset func(uint64) (uint64, error)) tools.FitMemoryReader {
+	return func(addr uint64, size uint32) ([]byte, error) {
+		off, err := imageOffset(addr)
+		if err != nil {
+			return nil, err
+		}
+		if off > uint64(len(image)) || uint64(size) > uint64(len(image))-off {
+			return nil, fmt.Errorf("0x%x bytes at address 0x%x exceed the image", size, addr)
+		}
+		return image[off : off+uint64(size)], nil
+	}
+}
+
+// checkFITEntry parses the ACM, KM or BPM a FIT entry references.
+func (o ParseOptions) checkFITEntry(image []byte, entry tools.FitEntry, imageOffset func(uint64) (uint64, error)) error {
+	off, err := imageOffset(entry.Address)
+	if err != nil {
+		return err
+	}
+	if off >= uint64(len(image)) {
+		return fmt.Errorf("address 0x%x is outside of the image", entry.Address)
+	}
+	data := image[off:]
+	switch entry.Type() {
+	case tools.StartUpACMod:
+		if tools.IsACMHeader(data) {
+			size, err := tools.LookupACMSize(data)
+			if err != nil {
+				return err
+			}
+			if size > int64(len(data)) {
+				return fmt.Errorf("ACM of %d bytes exceeds the image", size)
+			}
+			data = data[:size]
+		}
+		acm, err := tools.DecompressACM(data)
+		if err != nil {
+			return err
+		}
+		_, _, _, _, err, err2 := tools.ParseACM(acm)
+		if err == nil {
+			err = err2
+		}
+		return err
+	case tools.KeyManifestRec:
+		if uint64(entry.Size()) > uint64(len(data)) {
+			return fmt.Errorf("KM of %d bytes exceeds the image", entry.Size())
+		}
+		km, err := o.ParseKM(bytes.NewReader(data[:entry.Size()]))
+		if err != nil {
+			return err
+		}
+		return km.Validate()
+	case tools.BootPolicyManifest:
+		if uint64(entry.Size()) > uint64(len(data)) {
+			return fmt.Errorf("BPM of %d bytes exceeds the image", entry.Size())
+		}
+		bpm, err := o.ParseBPM(bytes.NewReader(data[:entry.Size()]))
+		if err != nil {
+			return err
+		}
+		return bpm.Validate()
+	}
+	return nil
+}
+
+// WriteJSON writes the annotated FIT as indented JSON.
+func (r *FITReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// PrettyPrint writes one line per FIT entry.
+func (r *FITReport) PrettyPrint(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tType\tAddress\tSize\tStatus\tChecksum\tRole")
+	for _, e := range r.Entries {
+		status := "-"
+		if e.Checked {
+			status = "OK"
+			if e.Error != "" {
+				status = "FAILED: " + e.Error
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s (0x%02x)\t0x%x\t0x%x\t%s\t%s\t%s\n", e.Index, e.Name, e.Type, e.Address, e.Size, status, e.Checksum, e.Role)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if r.Duplicates != "" {
+		fmt.Fprintf(w, "WARNING: %s\n", r.Duplicates)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package bg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+func TestAnnotateFIT(t *testing.T) {
+	const base = tools.FourGiB - 0x1000
+	km := signedTestKM(t)
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	copy(image[0x100:], km)
+	entries := []tools.FitEntry{
+		fitEntry(tools.FitHeader, 0, 4),
+		fitEntry(tools.MCUpdate, base+0x800, 0),
+		fitEntry(tools.KeyManifestRec, base+0x100, uint32(len(km))),
+		fitEntry(tools.BootPolicyManifest, base+0x800, 0x80),
+	}
+	imageOffset := func(addr uint64) (uint64, error) {
+		return addr - base, nil
+	}
+
+	r := DefaultParseOptions().annotateFIT(image, entries, imageOffset)
+	if len(r.Entries) != len(entries) {
+		t.Fatalf("annotateFIT() returned %d entries, expected %d", len(r.Entries), len(entries))
+	}
+	if r.Entries[1].Checked || r.Entries[1].Name != "Microcode update" {
+		t.Errorf("microcode entry annotated as %+v", r.Entries[1])
+	}
+	if !r.Entries[2].Checked || r.Entries[2].Error != "" {
+		t.Errorf("KM entry annotated as %+v, expected it to parse", r.Entries[2])
+	}
+	// the BPM entry points to erased flash
+	if !r.Entries[3].Checked || !strings.Contains(r.Entries[3].Error, ErrStructureID.Error()) {
+		t.Errorf("BPM entry annotated as %+v, expected a structure ID error", r.Entries[3])
+	}
+	if r.Pass() {
+		t.Errorf("Pass() returned true with an invalid BPM")
+	}
+
+	if r.Entries[2].Checksum != tools.FitChecksumIgnored.String() {
+		t.Errorf("KM entry checksum annotated as %q", r.Entries[2].Checksum)
+	}
+
+	// with the C_V bit set the checksum covers the KM the entry points to
+	checksummed := append([]tools.FitEntry{}, entries[:3]...)
+	checksummed[2].CVType |= 0x80
+	var sum byte
+	for _, b := range km {
+		sum += b
+	}
+	checksummed[2].CheckSum = -sum
+	r = DefaultParseOptions().annotateFIT(image, checksummed, imageOffset)
+	if r.Entries[2].ChecksumInvalid || r.Entries[2].Checksum != tools.FitChecksumValid.String() || !r.Pass() {
+		t.Errorf("annotateFIT() didn't accept the checksum of the KM: %+v", r.Entries[2])
+	}
+	checksummed[2].CheckSum++
+	r = DefaultParseOptions().annotateFIT(image, checksummed, imageOffset)
+	if !r.Entries[2].ChecksumInvalid || r.Entries[2].Checksum != tools.FitChecksumInvalid.String() || r.Pass() {
+		t.Errorf("annotateFIT() didn't report the invalid checksum: %+v", r.Entries[2])
+	}
+
+	duplicated := append(entries[:4:4], fitEntry(tools.BootPolicyManifest, base+0x800, 0x80))
+	r = DefaultParseOptions().annotateFIT(image, duplicated, imageOffset)
+	if r.Duplicates == "" || r.Pass() {
+		t.Errorf("annotateFIT() didn't report the duplicated BPM entry")
+	}
+	otherKM := testKMWithID(t, 1)
+	copy(image[0x400:], otherKM)
+	severalKMs := append(entries[:3:3], fitEntry(tools.KeyManifestRec, base+0x400, uint32(len(otherKM))))
+	r = DefaultParseOptions().annotateFIT(image, severalKMs, imageOffset)
+	if r.Duplicates != "" {
+		t.Errorf("annotateFIT() reported a KM entry for another KM ID as duplicate: %s", r.Duplicates)
+	}
+	sameKMID := append(entries[:3:3], fitEntry(tools.KeyManifestRec, base+0x100, uint32(len(km))))
+	r = DefaultParseOptions().annotateFIT(image, sameKMID, imageOffset)
+	if !strings.Contains(r.Duplicates, ErrDuplicateKMID.Error()) || r.Pass() {
+		t.Errorf("annotateFIT() didn't report the KMs with the same KM ID: %q", r.Duplicates)
+	}
+}
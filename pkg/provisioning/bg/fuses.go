@@ -0,0 +1,68 @@
+package bg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+// ErrFusesNotReadable is returned if the OEM key hash fused into the FPF can't
+// be read on the running system.
+var ErrFusesNotReadable = errors.New("the fused OEM key hash is not readable on this platform")
+
+// ReadFusedOEMKeyHash reads the OEM key hash of algorithm alg fused into the
+// FPF of the running system. The FPF is only readable by the CSME, so the hash
+// is read from the TPM NV index nvIndex. This relies on the firmware mirroring
+// the FPF hash into that index at boot, as some platform firmwares do; nothing
+// in BootGuard itself does, so on other platforms the index doesn't exist or
+// holds another value. The BootGuard capability is checked in MSR_BOOT_GUARD_SACM_INFO first to report
+// platforms without BootGuard. Reading the TPM is aborted with ErrTPMTimeout
+// when ctx is done.
+func ReadFusedOEMKeyHash(ctx context.Context, txtAPI hwapi.APIInterfaces, nvIndex uint32, alg manifest.Algorithm) ([]byte, error) {
+	info, err := txtAPI.BootGuardSACMInfo()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFusesNotReadable, err)
+	}
+	if !info.Capability {
+		return nil, fmt.Errorf("%w: the CPU doesn't support BootGuard", ErrFusesNotReadable)
+	}
+	if nvIndex == 0 {
+		return nil, fmt.Errorf("%w: the FPF is only readable by the CSME, the TPM NV index the firmware mirrors the hash into is required", ErrFusesNotReadable)
+	}
+	h, err := alg.Hash()
+	if err != nil {
+		return nil, err
+	}
+	var hash []byte
+	err = withTPMTimeout(ctx, func() error {
+		tpmCon, err := txtAPI.NewTPM()
+		if err != nil {
+			return fmt.Errorf("no TPM found: %w", err)
+		}
+		defer tpmCon.Close()
+		// TPM 1.2 takes the offset in the index, TPM 2.0 the authorization handle
+		offhandle := nvIndex
+		if tpmCon.Version == hwapi.TPMVersion12 {
+			offhandle = 0
+		}
+		hash, err = txtAPI.NVReadValue(tpmCon, nvIndex, "", uint32(h.Size()), offhandle)
+		return err
+	})
+	if errors.Is(err, ErrTPMTimeout) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read NV index 0x%x: %v", ErrFusesNotReadable, nvIndex, err)
+	}
+	if len(hash) != h.Size() {
+		return nil, fmt.Errorf("NV index 0x%x holds %d bytes, but %s digests are %d bytes", nvIndex, len(hash), alg, h.Size())
+	}
+	if bytes.Equal(hash, make([]byte, len(hash))) {
+		return nil, fmt.Errorf("%w: NV index 0x%x is zero, no OEM key hash is fused", ErrFusesNotReadable, nvIndex)
+	}
+	return hash, nil
+}
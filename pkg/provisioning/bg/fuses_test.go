@@ -0,0 +1,123 @@
+package bg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+// sacmInfoAPI is a hardware API reporting the given MSR_BOOT_GUARD_SACM_INFO.
+type sacmInfoAPI struct {
+	hwapi.APIInterfaces
+	info *hwapi.BootGuardSACMInfo
+}
+
+func (a sacmInfoAPI) BootGuardSACMInfo() (*hwapi.BootGuardSACMInfo, error) {
+	if a.info == nil {
+		return nil, errors.New("MSR not readable")
+	}
+	return a.info, nil
+}
+
+func TestReadFusedOEMKeyHashNotReadable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		info    *hwapi.BootGuardSACMInfo
+		nvIndex uint32
+	}{
+		"msr-not-readable": {nil, 0x1c10103},
+		"no-bootguard":     {&hwapi.BootGuardSACMInfo{}, 0x1c10103},
+		"no-nv-index":      {&hwapi.BootGuardSACMInfo{Capability: true}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadFusedOEMKeyHash(context.Background(), sacmInfoAPI{info: tc.info}, tc.nvIndex, manifest.AlgSHA256)
+			if !errors.Is(err, ErrFusesNotReadable) {
+				t.Errorf("ReadFusedOEMKeyHash() returned %v, expected %v", err, ErrFusesNotReadable)
+			}
+		})
+	}
+}
+
+// fusesAPI is a hardware API of a BootGuard platform whose firmware mirrored the
+// fused hash into the NV index of a TPM of the given version.
+type fusesAPI struct {
+	hwapi.APIInterfaces
+	version   hwapi.TPMVersion
+	nvIndex   uint32
+	hash      []byte
+	offhandle *uint32
+}
+
+func (a fusesAPI) BootGuardSACMInfo() (*hwapi.BootGuardSACMInfo, error) {
+	return &hwapi.BootGuardSACMInfo{Capability: true}, nil
+}
+
+func (a fusesAPI) NewTPM() (*hwapi.TPM, error) {
+	return &hwapi.TPM{Version: a.version, RWC: &fakeTPM12{}}, nil
+}
+
+func (a fusesAPI) NVReadValue(tpmCon *hwapi.TPM, index uint32, password string, size, offhandle uint32) ([]byte, error) {
+	if index != a.nvIndex {
+		return nil, errors.New("NV index not defined")
+	}
+	*a.offhandle = offhandle
+	return a.hash[:size], nil
+}
+
+func TestReadFusedOEMKeyHash(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	oemHash, err := km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg)
+	if err != nil {
+		t.Fatalf("KMPubKeyHash() failed: %v", err)
+	}
+	otherHash := bytes.Repeat([]byte{0x5a}, len(oemHash))
+
+	const nvIndex = 0x1c10103
+	for name, tc := range map[string]struct {
+		version   hwapi.TPMVersion
+		fused     []byte
+		offhandle uint32
+		pass      bool
+	}{
+		"tpm12-match":    {hwapi.TPMVersion12, oemHash, 0, true},
+		"tpm12-mismatch": {hwapi.TPMVersion12, otherHash, 0, false},
+		"tpm20-match":    {hwapi.TPMVersion20, oemHash, nvIndex, true},
+		"tpm20-mismatch": {hwapi.TPMVersion20, otherHash, nvIndex, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var offhandle uint32
+			api := fusesAPI{version: tc.version, nvIndex: nvIndex, hash: tc.fused, offhandle: &offhandle}
+			hash, err := ReadFusedOEMKeyHash(context.Background(), api, nvIndex, km.PubKeyHashAlg)
+			if err != nil {
+				t.Fatalf("ReadFusedOEMKeyHash() failed: %v", err)
+			}
+			if offhandle != tc.offhandle {
+				t.Errorf("NVReadValue() was called with offset/handle 0x%x, expected 0x%x", offhandle, tc.offhandle)
+			}
+			c, err := ReportKeyChain(kmData, bpmData, hash)
+			if err != nil {
+				t.Fatalf("ReportKeyChain() failed: %v", err)
+			}
+			for _, check := range c.Checks.Checks {
+				if check.Check == "oem-key-hash" && check.Pass != tc.pass {
+					t.Errorf("OEM key hash check against the fused hash returned %+v, expected pass: %t", check, tc.pass)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package bg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+// ACMGeneration is the BootGuard generation of an ACM and the manifests it
+// accepts.
+type ACMGeneration string
+
+const (
+	// ACMGenerationCBnT is Converged BootGuard and TXT, with KM version 2.1
+	// and BPM version 2.x structures.
+	ACMGenerationCBnT ACMGeneration = "cbnt"
+	// ACMGenerationLegacy is BootGuard 1.0, with KM and BPM version 1.0
+	// structures.
+	ACMGenerationLegacy ACMGeneration = "legacy"
+)
+
+// ParseACMGeneration parses "cbnt" or "legacy".
+func ParseACMGeneration(s string) (ACMGeneration, error) {
+	switch g := ACMGeneration(strings.ToLower(s)); g {
+	case ACMGenerationCBnT, ACMGenerationLegacy:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown ACM generation %q, expected %q or %q", s, ACMGenerationCBnT, ACMGenerationLegacy)
+}
+
+// ValidateBPM checks that the BPM holds the elements the ACMs of the
+// generation require. CBnT ACMs require an IBBS element with IBB segments and
+// IBB digests, and an OBB hash, if set, holding a digest of its algorithm.
+func (g ACMGeneration) ValidateBPM(bpm *bootpolicy.Manifest) error {
+	if g != ACMGenerationCBnT {
+		return fmt.Errorf("the elements of %s BPMs can't be checked, only %s is implemented", g, ACMGenerationCBnT)
+	}
+	if len(bpm.SE) == 0 {
+		return fmt.Errorf("%s BPMs require an IBBS element", g)
+	}
+	for idx, se := range bpm.SE {
+		if len(se.IBBSegments) == 0 {
+			return fmt.Errorf("IBBS element %d has no IBB segments", idx)
+		}
+		if len(se.DigestList.List) == 0 {
+			return fmt.Errorf("IBBS element %d has no IBB digests", idx)
+		}
+		if se.OBBHash.HashAlg.IsNull() {
+			if len(se.OBBHash.HashBuffer) != 0 {
+				return fmt.Errorf("IBBS element %d has an OBB digest of %d bytes without hash algorithm", idx, len(se.OBBHash.HashBuffer))
+			}
+			continue
+		}
+		hash, err := se.OBBHash.HashAlg.Hash()
+		if err != nil {
+			return fmt.Errorf("IBBS element %d OBB hash: %w", idx, err)
+		}
+		if len(se.OBBHash.HashBuffer) != hash.Size() {
+			return fmt.Errorf("IBBS element %d has a %d bytes OBB digest, but %s digests are %d bytes",
+				idx, len(se.OBBHash.HashBuffer), se.OBBHash.HashAlg, hash.Size())
+		}
+	}
+	return nil
+}
+
+// IBBSegmentAlignment returns the alignment the ACMs of the generation
+// require for the base and the size of measured IBB segments: 16 bytes for
+// CBnT ACMs and 4K pages for BootGuard 1.0 ACMs.
+func (g ACMGeneration) IBBSegmentAlignment() uint32 {
+	if g == ACMGenerationLegacy {
+		return 0x1000
+	}
+	return 0x10
+}
+
+// ValidateIBBAlignment checks that the base and the size of every measured
+// IBB segment of se are aligned as the ACMs of the generation require. A
+// misaligned segment isn't measured as configured, so the IBB digest computed
+// at generation doesn't match what the ACM measures at boot.
+func (g ACMGeneration) ValidateIBBAlignment(se *bootpolicy.SE) error {
+	alignment := g.IBBSegmentAlignment()
+	for idx, seg := range se.IBBSegments {
+		if !seg.IsMeasured() {
+			continue
+		}
+		if seg.Base%alignment != 0 {
+			return fmt.Errorf("IBB segment %d base 0x%x is not aligned to 0x%x as %s ACMs require", idx, seg.Base, alignment, g)
+		}
+		if seg.Size%alignment != 0 {
+			return fmt.Errorf("IBB segment %d at 0x%x has size 0x%x, which is not aligned to 0x%x as %s ACMs require", idx, seg.Base, seg.Size, alignment, g)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package bg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+func TestParseACMGeneration(t *testing.T) {
+	for s, expected := range map[string]ACMGeneration{"cbnt": ACMGenerationCBnT, "CBnT": ACMGenerationCBnT, "legacy": ACMGenerationLegacy} {
+		g, err := ParseACMGeneration(s)
+		if err != nil || g != expected {
+			t.Errorf("ParseACMGeneration(%q) returned %q, %v, expected %q", s, g, err, expected)
+		}
+	}
+	if _, err := ParseACMGeneration("skylake"); err == nil {
+		t.Errorf("ParseACMGeneration() accepted an unknown generation")
+	}
+}
+
+func TestValidateIBBAlignment(t *testing.T) {
+	se := bootpolicy.NewSE()
+	se.IBBSegments = []bootpolicy.IBBSegment{
+		ibbSegment(0xfff00000, 0x80000, 0),
+		ibbSegment(0xfff80000, 0x80000, 0),
+	}
+	for _, g := range []ACMGeneration{ACMGenerationCBnT, ACMGenerationLegacy} {
+		if err := g.ValidateIBBAlignment(se); err != nil {
+			t.Errorf("ValidateIBBAlignment(%s) of page aligned segments failed: %v", g, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		generation ACMGeneration
+		seg        bootpolicy.IBBSegment
+		err        string
+	}{
+		{"CBnT base", ACMGenerationCBnT, ibbSegment(0xfff00008, 0x80000, 0), "base 0xfff00008 is not aligned to 0x10"},
+		{"CBnT size", ACMGenerationCBnT, ibbSegment(0xfff00000, 0x7fff8, 0), "size 0x7fff8, which is not aligned to 0x10"},
+		{"legacy base", ACMGenerationLegacy, ibbSegment(0xfff075c0, 0x1000, 0), "base 0xfff075c0 is not aligned to 0x1000"},
+		{"legacy size", ACMGenerationLegacy, ibbSegment(0xfff00000, 0x80, 0), "size 0x80, which is not aligned to 0x1000"},
+	} {
+		se := bootpolicy.NewSE()
+		se.IBBSegments = []bootpolicy.IBBSegment{tc.seg}
+		err := tc.generation.ValidateIBBAlignment(se)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: ValidateIBBAlignment() returned %v, expected error containing %q", tc.name, err, tc.err)
+		}
+	}
+
+	// the CBnT fixture BPM uses 64 byte aligned segments
+	se = bootpolicy.NewSE()
+	se.IBBSegments = []bootpolicy.IBBSegment{
+		ibbSegment(0xfff075c0, 0x40, 0),
+		ibbSegment(0xfff07d00, 0x80, 0),
+		// not measured segments aren't checked
+		ibbSegment(0xffc00001, 0x3, bootpolicy.IBBSegmentFlagNotMeasured),
+	}
+	if err := ACMGenerationCBnT.ValidateIBBAlignment(se); err != nil {
+		t.Errorf("ValidateIBBAlignment() rejected 64 byte aligned CBnT segments: %v", err)
+	}
+}
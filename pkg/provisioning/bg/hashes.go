@@ -0,0 +1,103 @@
+package bg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func compareValue(check string, stored, recomputed uint64) CheckResult {
+	return CheckResult{
+		Check:    check,
+		Pass:     stored == recomputed,
+		Expected: fmt.Sprintf("0x%x", recomputed),
+		Actual:   fmt.Sprintf("0x%x", stored),
+	}
+}
+
+func compareDigest(check string, stored, recomputed []byte) CheckResult {
+	return CheckResult{
+		Check:    check,
+		Pass:     bytes.Equal(stored, recomputed),
+		Expected: fmt.Sprintf("%x", recomputed),
+		Actual:   fmt.Sprintf("%x", stored),
+	}
+}
+
+func compareElementSize(check string, s manifest.StructInfo, totalSize uint64) CheckResult {
+	return compareValue(check+"-element-size", uint64(s.ElementSize), totalSize)
+}
+
+// CheckKMHashes recomputes the values stored in the KM which depend on other
+// data and compares them with the stored ones, without modifying the KM. If
+// bpm is not nil, the BPM signing key digests of the KM are recomputed from
+// the key of the BPM.
+func CheckKMHashes(km *key.Manifest, bpm *bootpolicy.Manifest) *CheckResults {
+	r := NewCheckResults(compareValue("km-signature-offset", uint64(km.KeyManifestSignatureOffset), km.KeyAndSignatureOffset()))
+	for idx, h := range km.Hash {
+		check := fmt.Sprintf("km-hash[%d]", idx)
+		if hash, err := h.Digest.HashAlg.Hash(); err == nil && hash.Size() != len(h.Digest.HashBuffer) {
+			r.Add(compareValue(check+"-size", uint64(len(h.Digest.HashBuffer)), uint64(hash.Size())))
+			continue
+		}
+		if bpm == nil || h.Usage&key.UsageBPMSigningPKD == 0 {
+			continue
+		}
+		digest, err := bpm.PMSE.Key.BPMPubKeyHash(h.Digest.HashAlg)
+		if err != nil {
+			r.Add(CheckResult{Check: check, Detail: fmt.Sprintf("unable to hash the BPM key: %v", err)})
+			continue
+		}
+		r.Add(compareDigest(check, h.Digest.HashBuffer, digest))
+	}
+	return r
+}
+
+// CheckBPMHashes recomputes the sizes, offsets and, if image is not nil, the IBB
+// digests stored in the BPM and compares them with the stored ones, without
+// modifying the BPM. It reports stale values left by manual edits.
+func CheckBPMHashes(bpm *bootpolicy.Manifest, image []byte) *CheckResults {
+	return DefaultParseOptions().CheckBPMHashes(bpm, image)
+}
+
+// CheckBPMHashes is CheckBPMHashes with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) CheckBPMHashes(bpm *bootpolicy.Manifest, image []byte) *CheckResults {
+	r := NewCheckResults(
+		compareElementSize("bpmh", bpm.BPMH.StructInfo, bpm.BPMH.TotalSize()),
+		compareValue("bpm-key-signature-offset", uint64(bpm.KeySignatureOffset), bpm.PMSEOffset()+bpm.PMSE.KeySignatureOffset()),
+	)
+	for idx := range bpm.SE {
+		se := &bpm.SE[idx]
+		check := fmt.Sprintf("se[%d]", idx)
+		r.Add(compareElementSize(check, se.StructInfo, se.TotalSize()))
+		if image == nil {
+			continue
+		}
+		for _, d := range se.DigestList.List {
+			digestCheck := fmt.Sprintf("%s-ibb-digest-%s", check, d.HashAlg)
+			digest, err := getIBBsDigest(se.IBBSegments, image, d.HashAlg, o.Layout)
+			if err != nil {
+				r.Add(CheckResult{Check: digestCheck, Detail: fmt.Sprintf("unable to hash the IBB segments: %v", err)})
+				continue
+			}
+			r.Add(compareDigest(digestCheck, d.HashBuffer, digest))
+		}
+	}
+	if bpm.TXTE != nil {
+		r.Add(compareElementSize("txte", bpm.TXTE.StructInfo, bpm.TXTE.TotalSize()))
+	}
+	if bpm.Res != nil {
+		r.Add(compareElementSize("reserved", bpm.Res.StructInfo, bpm.Res.TotalSize()))
+	}
+	if bpm.PCDE != nil {
+		r.Add(compareElementSize("pcde", bpm.PCDE.StructInfo, bpm.PCDE.TotalSize()))
+	}
+	if bpm.PME != nil {
+		r.Add(compareElementSize("pme", bpm.PME.StructInfo, bpm.PME.TotalSize()))
+	}
+	return r
+}
@@ -0,0 +1,84 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestCheckKMHashes(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bpm := bootpolicy.NewManifest()
+	if err := bpm.PMSE.Key.SetPubKey(privKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	digest, err := bpm.PMSE.Key.BPMPubKeyHash(manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("BPMPubKeyHash() failed: %v", err)
+	}
+	km := key.NewManifest()
+	km.Hash = []key.Hash{{
+		Usage:  key.UsageBPMSigningPKD,
+		Digest: manifest.HashStructure{HashAlg: manifest.AlgSHA256, HashBuffer: digest},
+	}}
+	km.RehashRecursive()
+
+	if r := CheckKMHashes(km, bpm); !r.Pass || len(r.Checks) != 2 {
+		t.Errorf("CheckKMHashes() of a consistent KM returned %+v", r)
+	}
+
+	km.Hash[0].Digest.HashBuffer[0] ^= 0xff
+	r := CheckKMHashes(km, bpm)
+	failed := r.Failed()
+	if r.Pass || len(failed) != 1 || failed[0].Check != "km-hash[0]" {
+		t.Fatalf("CheckKMHashes() of a corrupted KM hash returned %+v", r)
+	}
+	if failed[0].Expected == failed[0].Actual {
+		t.Errorf("Mismatch reports equal values: %+v", failed[0])
+	}
+}
+
+func TestCheckBPMHashes(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	if r := CheckBPMHashes(bpm, nil); !r.Pass {
+		t.Errorf("CheckBPMHashes() of %s reported mismatches: %+v", testBPMPath, r.Failed())
+	}
+
+	// corrupt the ElementSize of the first SE and the KeySignatureOffset of the BPMH
+	corrupted := append([]byte{}, data...)
+	seOffset := bpm.SEOffset()
+	binary.LittleEndian.PutUint16(corrupted[seOffset+10:], bpm.SE[0].ElementSize+4)
+	binary.LittleEndian.PutUint16(corrupted[12:], bpm.KeySignatureOffset-4)
+	bpm, err = ParseBPM(bytes.NewReader(corrupted))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	r := CheckBPMHashes(bpm, nil)
+	var failedChecks []string
+	for _, c := range r.Failed() {
+		failedChecks = append(failedChecks, c.Check)
+	}
+	if r.Pass || len(failedChecks) != 2 || failedChecks[0] != "bpm-key-signature-offset" || failedChecks[1] != "se[0]-element-size" {
+		t.Errorf("CheckBPMHashes() of a corrupted BPM reported %v", failedChecks)
+	}
+	if bpm.SE[0].ElementSize != binary.LittleEndian.Uint16(corrupted[seOffset+10:]) {
+		t.Errorf("CheckBPMHashes() rehashed the BPM")
+	}
+}
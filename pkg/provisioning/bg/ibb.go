@@ -0,0 +1,123 @@
+package bg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+// IBBSegmentSpan is the address range between two IBB segments, given by
+// their indices in the segment list, which is either a gap not covered by
+// any segment or an overlap covered by both.
+type IBBSegmentSpan struct {
+	Prev int
+	Next int
+	Base uint64
+	Size uint64
+}
+
+// IBBSegmentReport describes the layout of an IBB segment set.
+type IBBSegmentReport struct {
+	// Segments is the segment list as configured.
+	Segments []bootpolicy.IBBSegment
+	// Order are the indices of Segments sorted by base address.
+	Order []int
+	// Gaps are the address ranges between adjacent segments.
+	Gaps []IBBSegmentSpan
+	// Overlaps are the address ranges covered by more than one segment.
+	Overlaps []IBBSegmentSpan
+}
+
+// ReportIBBSegments sorts the IBB segments by address and reports the gaps
+// and overlaps between them.
+func ReportIBBSegments(segs []bootpolicy.IBBSegment) *IBBSegmentReport {
+	r := &IBBSegmentReport{Segments: segs, Order: make([]int, len(segs))}
+	for idx := range segs {
+		r.Order[idx] = idx
+	}
+	sort.SliceStable(r.Order, func(i, j int) bool {
+		return segs[r.Order[i]].Base < segs[r.Order[j]].Base
+	})
+	for i := 1; i < len(r.Order); i++ {
+		// compare with the segment reaching the furthest so far, it may
+		// enclose several of the following segments
+		prev := r.Order[0]
+		for _, idx := range r.Order[1:i] {
+			if segEnd(segs[idx]) > segEnd(segs[prev]) {
+				prev = idx
+			}
+		}
+		next := r.Order[i]
+		prevEnd, nextBase := segEnd(segs[prev]), uint64(segs[next].Base)
+		switch {
+		case nextBase > prevEnd:
+			r.Gaps = append(r.Gaps, IBBSegmentSpan{Prev: prev, Next: next, Base: prevEnd, Size: nextBase - prevEnd})
+		case nextBase < prevEnd:
+			end := segEnd(segs[next])
+			if end > prevEnd {
+				end = prevEnd
+			}
+			r.Overlaps = append(r.Overlaps, IBBSegmentSpan{Prev: prev, Next: next, Base: nextBase, Size: end - nextBase})
+		}
+	}
+	return r
+}
+
+func segEnd(seg bootpolicy.IBBSegment) uint64 {
+	return uint64(seg.Base) + uint64(seg.Size)
+}
+
+// Sorted returns true if the segments are listed in ascending address order.
+func (r *IBBSegmentReport) Sorted() bool {
+	for i, idx := range r.Order {
+		if i != idx {
+			return false
+		}
+	}
+	return true
+}
+
+// Warnings returns a message for every property of the segment set which
+// makes the resulting IBB digest differ from what is likely expected.
+func (r *IBBSegmentReport) Warnings() []string {
+	var warnings []string
+	if !r.Sorted() {
+		warnings = append(warnings, fmt.Sprintf("IBB segments are not in address order (order by address: %v), "+
+			"the IBB digest hashes the measured segments in list order", r.Order))
+	}
+	for _, o := range r.Overlaps {
+		warnings = append(warnings, fmt.Sprintf("IBB segments %d and %d overlap at 0x%x with size 0x%x, "+
+			"the overlap is hashed twice", o.Prev, o.Next, o.Base, o.Size))
+	}
+	for _, g := range r.Gaps {
+		warnings = append(warnings, fmt.Sprintf("gap of size 0x%x at 0x%x between IBB segments %d and %d is not measured",
+			g.Size, g.Base, g.Prev, g.Next))
+	}
+	return warnings
+}
+
+// PrettyPrint writes the segments in address order followed by the warnings.
+func (r *IBBSegmentReport) PrettyPrint(w io.Writer) {
+	fmt.Fprintln(w, "IBB segments in address order:")
+	for _, idx := range r.Order {
+		seg := r.Segments[idx]
+		measured := "measured"
+		if !seg.IsMeasured() {
+			measured = "not measured"
+		}
+		fmt.Fprintf(w, "  %d: 0x%08x - 0x%08x size 0x%x (%s)\n", idx, seg.Base, segEnd(seg), seg.Size, measured)
+	}
+	for _, warning := range r.Warnings() {
+		fmt.Fprintf(w, "WARNING: %s\n", warning)
+	}
+}
+
+// SortIBBSegments sorts the IBB segments by base address, which makes the IBB
+// digest hash the measured segments in address order.
+func SortIBBSegments(segs []bootpolicy.IBBSegment) {
+	sort.SliceStable(segs, func(i, j int) bool {
+		return segs[i].Base < segs[j].Base
+	})
+}
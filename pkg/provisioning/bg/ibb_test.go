@@ -0,0 +1,65 @@
+package bg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+func TestReportIBBSegmentsContiguous(t *testing.T) {
+	r := ReportIBBSegments([]bootpolicy.IBBSegment{
+		ibbSegment(0xffe00000, 0x100000, 0),
+		ibbSegment(0xfff00000, 0x100000, 0),
+	})
+	if !r.Sorted() || len(r.Gaps) != 0 || len(r.Overlaps) != 0 || len(r.Warnings()) != 0 {
+		t.Errorf("ReportIBBSegments() of contiguous segments returned %+v", r)
+	}
+}
+
+func TestReportIBBSegmentsOutOfOrder(t *testing.T) {
+	segs := []bootpolicy.IBBSegment{
+		ibbSegment(0xfff00000, 0x100000, 0),
+		ibbSegment(0xffc00000, 0x100000, 0),
+		ibbSegment(0xffe00000, 0x100000, 0),
+	}
+	r := ReportIBBSegments(segs)
+	if r.Sorted() || r.Order[0] != 1 || r.Order[1] != 2 || r.Order[2] != 0 {
+		t.Errorf("ReportIBBSegments() returned order %v, expected [1 2 0]", r.Order)
+	}
+	if len(r.Gaps) != 1 || r.Gaps[0] != (IBBSegmentSpan{Prev: 1, Next: 2, Base: 0xffd00000, Size: 0x100000}) {
+		t.Errorf("ReportIBBSegments() returned gaps %+v", r.Gaps)
+	}
+	warnings := r.Warnings()
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "not in address order") || !strings.Contains(warnings[1], "gap") {
+		t.Errorf("Warnings() returned %q", warnings)
+	}
+
+	SortIBBSegments(segs)
+	if r := ReportIBBSegments(segs); !r.Sorted() || segs[0].Base != 0xffc00000 || segs[2].Base != 0xfff00000 {
+		t.Errorf("SortIBBSegments() returned %+v", segs)
+	}
+}
+
+func TestReportIBBSegmentsOverlapping(t *testing.T) {
+	r := ReportIBBSegments([]bootpolicy.IBBSegment{
+		ibbSegment(0xffe00000, 0x200000, 0),
+		ibbSegment(0xffe80000, 0x10000, 0),
+		ibbSegment(0xfff80000, 0x80000, 0),
+	})
+	expected := []IBBSegmentSpan{
+		{Prev: 0, Next: 1, Base: 0xffe80000, Size: 0x10000},
+		{Prev: 0, Next: 2, Base: 0xfff80000, Size: 0x80000},
+	}
+	if len(r.Overlaps) != len(expected) || len(r.Gaps) != 0 {
+		t.Fatalf("ReportIBBSegments() returned overlaps %+v, gaps %+v", r.Overlaps, r.Gaps)
+	}
+	for idx := range expected {
+		if r.Overlaps[idx] != expected[idx] {
+			t.Errorf("Overlap %d is %+v, expected %+v", idx, r.Overlaps[idx], expected[idx])
+		}
+	}
+	if warnings := r.Warnings(); len(warnings) != 2 || !strings.Contains(warnings[0], "hashed twice") {
+		t.Errorf("Warnings() returned %q", warnings)
+	}
+}
@@ -0,0 +1,118 @@
+package bg
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// ManifestIDs are the identifiers shared by the KM and BPM of a build. Setting
+// them once in the config keeps them consistent across both manifests.
+type ManifestIDs struct {
+	// KMID is the Key Manifest Identifier.
+	KMID uint8 `json:"km_ID"`
+	// Revision is the revision of both the KM and the BPM.
+	Revision uint8 `json:"revision"`
+}
+
+// ApplyManifestIDs sets the shared identifiers of the config in the KM and
+// BPM. It returns an error if a manifest already sets a different non-zero
+// value.
+func ApplyManifestIDs(bgo *BootGuardOptions) error {
+	ids := bgo.IDs
+	if ids == nil {
+		return nil
+	}
+	km, bpmh := &bgo.KeyManifest, &bgo.BootPolicyManifest.BPMH
+	for _, f := range []struct {
+		name  string
+		field *uint8
+		value uint8
+	}{
+		{"km_ID", &km.KMID, ids.KMID},
+		{"km_Revision", &km.Revision, ids.Revision},
+		{"bpmh_Revision", &bpmh.BPMRevision, ids.Revision},
+	} {
+		if *f.field != 0 && *f.field != f.value {
+			return fmt.Errorf("%s is %d, but the shared identifiers set %d", f.name, *f.field, f.value)
+		}
+		*f.field = f.value
+	}
+	return nil
+}
+
+// CheckManifestIDs checks that the KM references the signing key of the BPM
+// and, if expected is not nil, that the KM ID and the KM and BPM revisions
+// match the expected identifiers.
+func CheckManifestIDs(km *key.Manifest, bpm *bootpolicy.Manifest, expected *ManifestIDs) *CheckResults {
+	results := NewCheckResults(NewCheckResult("km-bpm-key", checkKMReferencesBPMKey(km, bpm), "KM holds the BPM signing key hash"))
+	if expected == nil {
+		return results
+	}
+	for _, c := range []struct {
+		check    string
+		actual   uint8
+		expected uint8
+	}{
+		{"km-id", km.KMID, expected.KMID},
+		{"km-revision", km.Revision, expected.Revision},
+		{"bpm-revision", bpm.BPMH.BPMRevision, expected.Revision},
+	} {
+		result := CheckResult{
+			Check:    c.check,
+			Pass:     c.actual == c.expected,
+			Expected: fmt.Sprintf("%d", c.expected),
+			Actual:   fmt.Sprintf("%d", c.actual),
+		}
+		if !result.Pass {
+			result.Detail = "doesn't match the expected identifier"
+		}
+		results.Add(result)
+	}
+	return results
+}
+
+func checkKMReferencesBPMKey(km *key.Manifest, bpm *bootpolicy.Manifest) error {
+	if bpm.PMSE.KeySignature.Key.KeySize == 0 || len(bpm.PMSE.KeySignature.Key.Data) == 0 {
+		return fmt.Errorf("BPM has no signing key")
+	}
+	ok, err := kmHoldsBPMKeyHash(km, &bpm.PMSE.KeySignature.Key)
+	if err != nil {
+		return fmt.Errorf("unable to hash the BPM signing key: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("KM doesn't hold the hash of the BPM signing key")
+	}
+	return nil
+}
+
+// KMAcceptsKey returns true if the KM holds the hash of pub with the BPM
+// signing usage, i.e. the ACM accepts a BPM signed with the key. The key is
+// hashed with the hash algorithm of every such KM hash.
+func KMAcceptsKey(km *key.Manifest, pub crypto.PublicKey) (bool, error) {
+	var k manifest.Key
+	if err := k.SetPubKey(pub); err != nil {
+		return false, err
+	}
+	return kmHoldsBPMKeyHash(km, &k)
+}
+
+func kmHoldsBPMKeyHash(km *key.Manifest, k *manifest.Key) (bool, error) {
+	for _, h := range km.Hash {
+		if !h.Usage.IsSet(key.UsageBPMSigningPKD) {
+			continue
+		}
+		hash, err := k.BPMPubKeyHash(h.Digest.HashAlg)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(hash, h.Digest.HashBuffer) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,113 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestApplyManifestIDs(t *testing.T) {
+	var bgo BootGuardOptions
+	bgo.IDs = &ManifestIDs{KMID: 3, Revision: 2}
+	if err := ApplyManifestIDs(&bgo); err != nil {
+		t.Fatalf("ApplyManifestIDs() failed: %v", err)
+	}
+	if bgo.KeyManifest.KMID != 3 || bgo.KeyManifest.Revision != 2 || bgo.BootPolicyManifest.BPMH.BPMRevision != 2 {
+		t.Errorf("ApplyManifestIDs() set KM ID %d, KM revision %d, BPM revision %d",
+			bgo.KeyManifest.KMID, bgo.KeyManifest.Revision, bgo.BootPolicyManifest.BPMH.BPMRevision)
+	}
+
+	bgo.IDs.KMID = 4
+	if err := ApplyManifestIDs(&bgo); err == nil {
+		t.Errorf("ApplyManifestIDs() succeeded with a conflicting KM ID")
+	}
+}
+
+func TestCheckManifestIDs(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	bpm.BPMH.BPMRevision = 2
+	bpmKeyHash, err := bpm.PMSE.KeySignature.Key.BPMPubKeyHash(manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("BPMPubKeyHash() failed: %v", err)
+	}
+	km := key.NewManifest()
+	km.KMID = 3
+	km.Revision = 2
+	km.Hash = []key.Hash{{
+		Usage:  key.UsageBPMSigningPKD,
+		Digest: manifest.HashStructure{HashAlg: manifest.AlgSHA256, HashBuffer: bpmKeyHash},
+	}}
+
+	if r := CheckManifestIDs(km, bpm, &ManifestIDs{KMID: 3, Revision: 2}); !r.Pass {
+		t.Fatalf("CheckManifestIDs() failed with matching manifests: %+v", r.Failed())
+	}
+
+	r := CheckManifestIDs(km, bpm, &ManifestIDs{KMID: 4, Revision: 2})
+	if failed := r.Failed(); len(failed) != 1 || failed[0].Check != "km-id" || failed[0].Actual != "3" || failed[0].Expected != "4" {
+		t.Errorf("CheckManifestIDs() with a mismatching KM ID failed %+v", failed)
+	}
+
+	km.Hash[0].Digest.HashBuffer = make([]byte, len(bpmKeyHash))
+	r = CheckManifestIDs(km, bpm, nil)
+	if failed := r.Failed(); len(failed) != 1 || failed[0].Check != "km-bpm-key" {
+		t.Errorf("CheckManifestIDs() with a KM of another BPM key failed %+v", failed)
+	}
+}
+
+func TestKMAcceptsKey(t *testing.T) {
+	bpmKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	var k manifest.Key
+	if err := k.SetPubKey(bpmKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	sha384, err := k.BPMPubKeyHash(manifest.AlgSHA384)
+	if err != nil {
+		t.Fatalf("BPMPubKeyHash() failed: %v", err)
+	}
+	km := key.NewManifest()
+	km.Hash = []key.Hash{
+		{Usage: key.UsageBPMSigningPKD, Digest: manifest.HashStructure{HashAlg: manifest.AlgSHA256, HashBuffer: make([]byte, 32)}},
+		{Usage: key.UsageBPMSigningPKD, Digest: manifest.HashStructure{HashAlg: manifest.AlgSHA384, HashBuffer: sha384}},
+	}
+
+	if ok, err := KMAcceptsKey(km, bpmKey.Public()); err != nil || !ok {
+		t.Errorf("KMAcceptsKey() of the BPM key returned %v, %v", ok, err)
+	}
+	if ok, err := KMAcceptsKey(km, otherKey.Public()); err != nil || ok {
+		t.Errorf("KMAcceptsKey() of another key returned %v, %v", ok, err)
+	}
+
+	// the hash is only accepted with the BPM signing usage
+	km.Hash[1].Usage = key.UsageFITPatchManifestSigningPKD
+	if ok, err := KMAcceptsKey(km, bpmKey.Public()); err != nil || ok {
+		t.Errorf("KMAcceptsKey() of a hash without BPM usage returned %v, %v", ok, err)
+	}
+
+	km.Hash[0].Digest.HashAlg = manifest.AlgRSA
+	if _, err := KMAcceptsKey(km, bpmKey.Public()); err == nil {
+		t.Errorf("KMAcceptsKey() accepted a KM hash of a key algorithm")
+	}
+	if _, err := KMAcceptsKey(km, "no key"); err == nil {
+		t.Errorf("KMAcceptsKey() accepted an invalid public key")
+	}
+}
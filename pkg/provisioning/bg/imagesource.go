@@ -0,0 +1,99 @@
+package bg
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+const (
+	mtdSysfsPath = "/sys/class/mtd"
+	mtdDevPath   = "/dev"
+)
+
+// ImageSource provides the bytes of a firmware image, which then are parsed
+// the same way regardless of where they came from.
+type ImageSource interface {
+	ReadImage() ([]byte, error)
+	// String describes the source in messages.
+	String() string
+}
+
+// FileImageSource reads the firmware image from a file, e.g. a flash dump.
+type FileImageSource string
+
+// ReadImage reads the file.
+func (s FileImageSource) ReadImage() ([]byte, error) {
+	return ioutil.ReadFile(string(s))
+}
+
+func (s FileImageSource) String() string {
+	return string(s)
+}
+
+// FlashImageSource reads the firmware image from the SPI flash of the running
+// system, see ReadFlashImage.
+type FlashImageSource struct{}
+
+// ReadImage reads the SPI flash.
+func (FlashImageSource) ReadImage() ([]byte, error) {
+	return ReadFlashImage()
+}
+
+func (FlashImageSource) String() string {
+	return "SPI flash"
+}
+
+// NewImageSource returns the source of a firmware image given either as the
+// path of a file or by fromFlash to read the SPI flash.
+func NewImageSource(path string, fromFlash bool) (ImageSource, error) {
+	switch {
+	case path != "" && fromFlash:
+		return nil, fmt.Errorf("either a firmware image path or reading the flash can be used, not both")
+	case fromFlash:
+		return FlashImageSource{}, nil
+	case path != "":
+		return FileImageSource(path), nil
+	}
+	return nil, fmt.Errorf("a firmware image path or reading the flash is required")
+}
+
+// ReadFlashImage reads the full SPI flash image through the read-only MTD
+// devices listed in sysfs and returns the first one containing a FIT. It is
+// Linux only and requires root.
+func ReadFlashImage() ([]byte, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("reading the SPI flash is only supported on Linux")
+	}
+	devices, err := ioutil.ReadDir(mtdSysfsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list MTD devices in %s: %w", mtdSysfsPath, err)
+	}
+	var permErr error
+	for _, device := range devices {
+		if strings.HasSuffix(device.Name(), "ro") {
+			continue
+		}
+		image, err := ioutil.ReadFile(filepath.Join(mtdDevPath, device.Name()+"ro"))
+		if err != nil {
+			if errors.Is(err, os.ErrPermission) && permErr == nil {
+				permErr = err
+			}
+			continue
+		}
+		if _, err := tools.ExtractFit(image); err != nil {
+			continue
+		}
+		return image, nil
+	}
+	if permErr != nil {
+		return nil, fmt.Errorf("reading the SPI flash requires root: %w", permErr)
+	}
+	return nil, fmt.Errorf("no MTD device in %s contains a firmware image with a FIT", mtdSysfsPath)
+}
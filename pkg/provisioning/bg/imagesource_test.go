@@ -0,0 +1,35 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestNewImageSource(t *testing.T) {
+	if _, err := NewImageSource("", false); err == nil {
+		t.Errorf("NewImageSource() succeeded without a source")
+	}
+	if _, err := NewImageSource(testBPMPath, true); err == nil {
+		t.Errorf("NewImageSource() succeeded with a path and the flash")
+	}
+	if src, err := NewImageSource("", true); err != nil || src != (FlashImageSource{}) {
+		t.Errorf("NewImageSource() returned %v, %v, expected the flash", src, err)
+	}
+
+	src, err := NewImageSource(testBPMPath, false)
+	if err != nil {
+		t.Fatalf("NewImageSource() failed: %v", err)
+	}
+	image, err := src.ReadImage()
+	if err != nil {
+		t.Fatalf("ReadImage() failed: %v", err)
+	}
+	expected, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.Equal(image, expected) || src.String() != testBPMPath {
+		t.Errorf("ReadImage() of %s returned other bytes", src)
+	}
+}
@@ -0,0 +1,118 @@
+package bg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// KDFPBKDF2 selects PBKDF2 with HMAC-SHA256 to derive the key encryption key.
+	KDFPBKDF2 = "pbkdf2"
+	// KDFScrypt selects scrypt to derive the key encryption key.
+	KDFScrypt = "scrypt"
+
+	// encryptedKeyPEMType is the PEM block type of private keys encrypted with
+	// KDF derived keys. The KDF parameters are stored in the PEM headers.
+	encryptedKeyPEMType = "BG-PROV ENCRYPTED PRIVATE KEY"
+
+	kdfSaltSize = 16
+	kdfKeySize  = 32
+)
+
+// KDFParams selects the key derivation function and its parameters used to
+// derive the AES256 key from the password when encrypting private keys.
+type KDFParams struct {
+	KDF        string
+	Iterations int
+	ScryptN    int
+	ScryptR    int
+	ScryptP    int
+}
+
+// DefaultKDFParams are the parameters used if none are given, following the
+// OWASP recommendation for PBKDF2-HMAC-SHA256.
+var DefaultKDFParams = KDFParams{
+	KDF:        KDFPBKDF2,
+	Iterations: 600000,
+	ScryptN:    1 << 15,
+	ScryptR:    8,
+	ScryptP:    1,
+}
+
+// Validate checks that the parameters select a known KDF with usable values.
+func (p KDFParams) Validate() error {
+	switch p.KDF {
+	case KDFPBKDF2:
+		if p.Iterations < 1 {
+			return fmt.Errorf("PBKDF2 iterations must be positive, but are %d", p.Iterations)
+		}
+	case KDFScrypt:
+		if p.ScryptN <= 1 || p.ScryptN&(p.ScryptN-1) != 0 {
+			return fmt.Errorf("scrypt N must be a power of two greater than 1, but is %d", p.ScryptN)
+		}
+		if p.ScryptR < 1 || p.ScryptP < 1 {
+			return fmt.Errorf("scrypt r and p must be positive, but are %d and %d", p.ScryptR, p.ScryptP)
+		}
+	default:
+		return fmt.Errorf("unknown KDF %q, options are: %s, %s", p.KDF, KDFPBKDF2, KDFScrypt)
+	}
+	return nil
+}
+
+func (p KDFParams) deriveKey(password string, salt []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if p.KDF == KDFScrypt {
+		return scrypt.Key([]byte(password), salt, p.ScryptN, p.ScryptR, p.ScryptP, kdfKeySize)
+	}
+	return pbkdf2.Key([]byte(password), salt, p.Iterations, kdfKeySize, sha256.New), nil
+}
+
+func (p KDFParams) pemHeaders(salt []byte) map[string]string {
+	headers := map[string]string{
+		"KDF":  p.KDF,
+		"Salt": hex.EncodeToString(salt),
+	}
+	if p.KDF == KDFScrypt {
+		headers["N"] = strconv.Itoa(p.ScryptN)
+		headers["R"] = strconv.Itoa(p.ScryptR)
+		headers["P"] = strconv.Itoa(p.ScryptP)
+	} else {
+		headers["Iterations"] = strconv.Itoa(p.Iterations)
+	}
+	return headers
+}
+
+func kdfParamsFromPEMHeaders(headers map[string]string) (KDFParams, []byte, error) {
+	var p KDFParams
+	salt, err := hex.DecodeString(headers["Salt"])
+	if err != nil || len(salt) == 0 {
+		return p, nil, fmt.Errorf("invalid KDF salt %q", headers["Salt"])
+	}
+	atoi := func(name string) int {
+		if err != nil {
+			return 0
+		}
+		var v int
+		if v, err = strconv.Atoi(headers[name]); err != nil {
+			err = fmt.Errorf("invalid KDF parameter %s: %w", name, err)
+		}
+		return v
+	}
+	p.KDF = headers["KDF"]
+	if p.KDF == KDFScrypt {
+		p.ScryptN, p.ScryptR, p.ScryptP = atoi("N"), atoi("R"), atoi("P")
+	} else {
+		p.Iterations = atoi("Iterations")
+	}
+	if err != nil {
+		return p, nil, err
+	}
+	return p, salt, p.Validate()
+}
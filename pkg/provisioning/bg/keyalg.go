@@ -0,0 +1,27 @@
+package bg
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+// CheckSigningKey checks that the signing key matches the key a manifest
+// already declares. A mismatch usually means the wrong key was picked. A key
+// without a size, like the placeholder of an unsigned BPM written by bpm-gen,
+// doesn't declare anything and matches every signing key.
+func CheckSigningKey(declared *manifest.Key, pubKey crypto.PublicKey) error {
+	if declared.KeyAlg.IsNull() || declared.KeySize == 0 {
+		return nil
+	}
+	var signing manifest.Key
+	if err := signing.SetPubKey(pubKey); err != nil {
+		return err
+	}
+	if signing.KeyAlg != declared.KeyAlg || signing.KeySize != declared.KeySize {
+		return fmt.Errorf("signing key is %s with %d bits, but the manifest declares %s with %d bits",
+			signing.KeyAlg, signing.KeySize.InBits(), declared.KeyAlg, declared.KeySize.InBits())
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package bg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+func TestCheckSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsa3072Key, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bpm := bootpolicy.NewManifest()
+	if err := bpm.PMSE.Key.SetPubKey(rsaKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	if err := CheckSigningKey(&bpm.PMSE.Key, rsaKey.Public()); err != nil {
+		t.Errorf("CheckSigningKey() failed with the declared key type: %v", err)
+	}
+	err = CheckSigningKey(&bpm.PMSE.Key, eccKey.Public())
+	if err == nil || !strings.Contains(err.Error(), "manifest declares RSA") {
+		t.Errorf("CheckSigningKey() of an RSA-declared BPM with an ECC key returned %v", err)
+	}
+	if err := CheckSigningKey(&bpm.PMSE.Key, rsa3072Key.Public()); err == nil {
+		t.Errorf("CheckSigningKey() accepted an RSA key of another size")
+	}
+
+	// bpm-gen only sets the key algorithm to make the BPM parseable
+	placeholder := bootpolicy.NewManifest()
+	placeholder.PMSE.Key.KeyAlg = manifest.AlgRSA
+	if err := CheckSigningKey(&placeholder.PMSE.Key, eccKey.Public()); err != nil {
+		t.Errorf("CheckSigningKey() rejected an undeclared key: %v", err)
+	}
+}
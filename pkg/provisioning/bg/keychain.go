@@ -0,0 +1,135 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// KeyChainHash is a key hash of the chain from the fuses to the BPM.
+type KeyChainHash struct {
+	Name    string         `json:"name"`
+	HashAlg AlgorithmField `json:"hash_alg"`
+	Hash    string         `json:"hash,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// KeyChain lists the key hashes linking the OEM key fused into the FPF to
+// the KM and the KM to the BPM side by side, with the checks of every link.
+type KeyChain struct {
+	Hashes []KeyChainHash `json:"hashes"`
+	Checks *CheckResults  `json:"checks"`
+}
+
+// ReportKeyChain reports the chain of key hashes of a KM and BPM: the OEM key
+// hash the fuses have to hold, the BPM key hashes stored in the KM and the
+// hash of the key embedded in the BPM. If oemKeyHash is not nil, the hash of
+// the KM signing key is checked against it, e.g. against the fused value.
+func ReportKeyChain(kmData, bpmData, oemKeyHash []byte) (*KeyChain, error) {
+	return DefaultParseOptions().ReportKeyChain(kmData, bpmData, oemKeyHash)
+}
+
+// ReportKeyChain is ReportKeyChain with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) ReportKeyChain(kmData, bpmData, oemKeyHash []byte) (*KeyChain, error) {
+	km, err := o.ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		return nil, err
+	}
+	bpm, err := o.ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		return nil, err
+	}
+	c := &KeyChain{Checks: NewCheckResults()}
+
+	oemHash, err := km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg)
+	c.Hashes = append(c.Hashes, newKeyChainHash("OEM key hash (FPF)", km.PubKeyHashAlg, oemHash, err))
+	if oemKeyHash != nil {
+		if err != nil {
+			c.Checks.Add(NewCheckResult("oem-key-hash", err, ""))
+		} else {
+			c.Checks.Add(compareDigest("oem-key-hash", oemHash, oemKeyHash))
+		}
+	}
+	kmScheme, err := VerifyKM(kmData)
+	c.Checks.Add(NewCheckResult("km-signature", err, kmScheme.String()))
+
+	// hash the BPM key with every algorithm the KM stores a BPM key hash of
+	var algs []manifest.Algorithm
+	for idx, h := range km.Hash {
+		if !h.Usage.IsSet(key.UsageBPMSigningPKD) {
+			continue
+		}
+		c.Hashes = append(c.Hashes, newKeyChainHash(fmt.Sprintf("KM BPM key hash [%d]", idx), h.Digest.HashAlg, h.Digest.HashBuffer, nil))
+		if !containsAlg(algs, h.Digest.HashAlg) {
+			algs = append(algs, h.Digest.HashAlg)
+		}
+	}
+	if len(algs) == 0 {
+		algs = append(algs, km.PubKeyHashAlg)
+	}
+	for _, alg := range algs {
+		hash, err := bpm.PMSE.KeySignature.Key.BPMPubKeyHash(alg)
+		c.Hashes = append(c.Hashes, newKeyChainHash("BPM key hash", alg, hash, err))
+	}
+	c.Checks.Add(NewCheckResult("km-bpm-key", checkKMReferencesBPMKey(km, bpm), "KM holds the BPM signing key hash"))
+	bpmScheme, err := VerifyBPM(bpmData)
+	c.Checks.Add(NewCheckResult("bpm-signature", err, bpmScheme.String()))
+	return c, nil
+}
+
+func newKeyChainHash(name string, alg manifest.Algorithm, hash []byte, err error) KeyChainHash {
+	h := KeyChainHash{Name: name, HashAlg: NewAlgorithmField(alg)}
+	if err != nil {
+		h.Error = err.Error()
+	} else {
+		h.Hash = fmt.Sprintf("%x", hash)
+	}
+	return h
+}
+
+func containsAlg(algs []manifest.Algorithm, alg manifest.Algorithm) bool {
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the key chain as indented JSON.
+func (c *KeyChain) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// PrettyPrint writes the key hashes side by side followed by the checks.
+func (c *KeyChain) PrettyPrint(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, h := range c.Hashes {
+		value := h.Hash
+		if h.Error != "" {
+			value = "unavailable: " + h.Error
+		}
+		fmt.Fprintf(tw, "%s:\t%s\t%s\n", h.Name, h.HashAlg, value)
+	}
+	fmt.Fprintln(tw)
+	for _, check := range c.Checks.Checks {
+		status := "PASS"
+		if !check.Pass {
+			status = "FAIL"
+		}
+		detail := check.Detail
+		if detail == "" && !check.Pass {
+			detail = fmt.Sprintf("expected %s, got %s", check.Expected, check.Actual)
+		}
+		fmt.Fprintf(tw, "%s:\t%s\t%s\n", check.Check, status, detail)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,79 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+// the key hashes of the KM and BPM fixtures, computed from the raw key data
+const (
+	testOEMKeyHash = "47c1dd21bd12d187997c41c2b4d88218e16df33fb6f2f8f99140f513a56e994a"
+	testBPMKeyHash = "1168ae3333c67fb665945064f8697a511b9744659a091e4133e9117b713bf47b"
+)
+
+func TestReportKeyChain(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	oemHash, err := hex.DecodeString(testOEMKeyHash)
+	if err != nil {
+		t.Fatalf("DecodeString() failed: %v", err)
+	}
+
+	c, err := ReportKeyChain(kmData, bpmData, oemHash)
+	if err != nil {
+		t.Fatalf("ReportKeyChain() failed: %v", err)
+	}
+	sha256 := NewAlgorithmField(manifest.AlgSHA256)
+	expected := []KeyChainHash{
+		{Name: "OEM key hash (FPF)", HashAlg: sha256, Hash: testOEMKeyHash},
+		{Name: "KM BPM key hash [0]", HashAlg: sha256, Hash: testBPMKeyHash},
+		{Name: "BPM key hash", HashAlg: sha256, Hash: testBPMKeyHash},
+	}
+	if !reflect.DeepEqual(c.Hashes, expected) {
+		t.Errorf("ReportKeyChain() returned the hashes %+v, expected %+v", c.Hashes, expected)
+	}
+	checks := map[string]CheckResult{}
+	for _, check := range c.Checks.Checks {
+		checks[check.Check] = check
+	}
+	if !checks["oem-key-hash"].Pass {
+		t.Errorf("OEM key hash check failed with the hash of the KM key: %+v", checks["oem-key-hash"])
+	}
+	if check, ok := checks["km-bpm-key"]; !ok || !check.Pass {
+		t.Errorf("KM to BPM key check returned %+v, expected the KM to hold the BPM key hash", check)
+	}
+
+	// a KM signed with another OEM key breaks the chain at the fuses
+	c, err = ReportKeyChain(kmData, bpmData, make([]byte, len(oemHash)))
+	if err != nil {
+		t.Fatalf("ReportKeyChain() failed: %v", err)
+	}
+	if c.Checks.Pass || c.Checks.Failed()[0].Check != "oem-key-hash" {
+		t.Errorf("ReportKeyChain() with another fused key hash returned %+v", c.Checks)
+	}
+	var out bytes.Buffer
+	if err := c.PrettyPrint(&out); err != nil {
+		t.Fatalf("PrettyPrint() failed: %v", err)
+	}
+	failed := false
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "oem-key-hash:") && strings.Contains(line, "FAIL") {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Errorf("PrettyPrint() doesn't report the failed OEM key hash check:\n%s", out.String())
+	}
+}
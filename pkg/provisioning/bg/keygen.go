@@ -1,36 +1,80 @@
 package bg
 
 import (
+	"bufio"
+	"bytes"
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
 )
 
 const (
 	//Supported RSA bit length of Intel TXT/CBnT technology
 	rsaLen2048 = int(2048)
 	rsaLen3072 = int(3072)
+
+	// StdinPath selects the standard input instead of a file in ReadSecret and ReadPassword
+	StdinPath = "-"
 )
 
+// ReadSecret reads key material from the file at path, or from r if path is StdinPath.
+// The bytes are returned unmodified, so encrypted keys can be passed to DecryptPrivKey.
+func ReadSecret(path string, r io.Reader) ([]byte, error) {
+	if path != StdinPath {
+		return ioutil.ReadFile(path)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret from stdin: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no secret received on stdin")
+	}
+	return data, nil
+}
+
+// ReadPassword returns password, or the first line read from r if password is StdinPath.
+func ReadPassword(password string, r io.Reader) (string, error) {
+	if password != StdinPath {
+		return password, nil
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("unable to read password from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
 // GenRSAKey takes the required keylength, two boolean to decide for KM and BPM key and a path
 // to create a RSA key pair and writes its public and private keys to files.
-func GenRSAKey(len int, password string, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile *os.File) error {
+// The private keys are encrypted with a key derived from password using kdf.
+func GenRSAKey(len int, password string, kdf KDFParams, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile *os.File) error {
+	if password != "" {
+		if err := kdf.Validate(); err != nil {
+			return err
+		}
+	}
 	if len == rsaLen2048 || len == rsaLen3072 {
 		key, err := rsa.GenerateKey(rand.Reader, len)
 		if err != nil {
 			return err
 		}
-		if err := writePrivKeyToFile(key, kmPrivFile, password); err != nil {
+		if err := writePrivKeyToFile(key, kmPrivFile, password, kdf); err != nil {
 			return err
 		}
 
@@ -42,7 +86,7 @@ func GenRSAKey(len int, password string, kmPubFile, kmPrivFile, bpmPubFile, bpmP
 		if err != nil {
 			return err
 		}
-		if err := writePrivKeyToFile(key, bpmPrivFile, password); err != nil {
+		if err := writePrivKeyToFile(key, bpmPrivFile, password, kdf); err != nil {
 			return err
 		}
 
@@ -57,7 +101,8 @@ func GenRSAKey(len int, password string, kmPubFile, kmPrivFile, bpmPubFile, bpmP
 
 // GenECCKey takes the required curve, two boolean to decide for KM and BPM key and a path
 // to create a ECDSA key pair and writes its public and private keys to files.
-func GenECCKey(curve int, password string, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile *os.File) error {
+// The private keys are encrypted with a key derived from password using kdf.
+func GenECCKey(curve int, password string, kdf KDFParams, kmPubFile, kmPrivFile, bpmPubFile, bpmPrivFile *os.File) error {
 	var ellCurve elliptic.Curve
 	switch curve {
 	case 224:
@@ -67,12 +112,17 @@ func GenECCKey(curve int, password string, kmPubFile, kmPrivFile, bpmPubFile, bp
 	default:
 		return fmt.Errorf("Selected ECC algorithm not supported")
 	}
+	if password != "" {
+		if err := kdf.Validate(); err != nil {
+			return err
+		}
+	}
 	key, err := ecdsa.GenerateKey(ellCurve, rand.Reader)
 	if err != nil {
 		return err
 	}
 
-	if err := writePrivKeyToFile(key, kmPrivFile, password); err != nil {
+	if err := writePrivKeyToFile(key, kmPrivFile, password, kdf); err != nil {
 		return err
 	}
 
@@ -85,7 +135,7 @@ func GenECCKey(curve int, password string, kmPubFile, kmPrivFile, bpmPubFile, bp
 		return err
 	}
 
-	if err := writePrivKeyToFile(key, bpmPrivFile, password); err != nil {
+	if err := writePrivKeyToFile(key, bpmPrivFile, password, kdf); err != nil {
 		return err
 	}
 
@@ -96,7 +146,7 @@ func GenECCKey(curve int, password string, kmPubFile, kmPrivFile, bpmPubFile, bp
 	return nil
 }
 
-func writePrivKeyToFile(k crypto.PrivateKey, f *os.File, password string) error {
+func writePrivKeyToFile(k crypto.PrivateKey, f *os.File, password string, kdf KDFParams) error {
 	var key *[]byte
 	b, err := x509.MarshalPKCS8PrivateKey(k)
 	bpemBlock := &pem.Block{
@@ -104,7 +154,7 @@ func writePrivKeyToFile(k crypto.PrivateKey, f *os.File, password string) error
 	}
 	bpem := pem.EncodeToMemory(bpemBlock)
 	if password != "" {
-		encKey, err := encryptPrivFile(&bpem, password)
+		encKey, err := encryptPrivFile(&bpem, password, kdf)
 		if err != nil {
 			return err
 		}
@@ -136,13 +186,18 @@ func writePubKeyToFile(k crypto.PublicKey, f *os.File) error {
 	return nil
 }
 
-func encryptPrivFile(data *[]byte, password string) (*[]byte, error) {
-	// Hash key to select aes-256 -> using SHA256
-	hash := crypto.SHA256.New()
-	hash.Write([]byte(password))
-	hashPW := hash.Sum(nil)
+func encryptPrivFile(data *[]byte, password string, kdf KDFParams) (*[]byte, error) {
+	salt := make([]byte, kdfSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	// Derive the aes-256 key from the password
+	aesKey, err := kdf.deriveKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
 
-	bc, err := aes.NewCipher(hashPW)
+	bc, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return nil, err
 	}
@@ -155,18 +210,37 @@ func encryptPrivFile(data *[]byte, password string) (*[]byte, error) {
 		return nil, err
 	}
 	ct := gcm.Seal(nonce, nonce, *data, nil)
-	return &ct, nil
+	encKey := pem.EncodeToMemory(&pem.Block{
+		Type:    encryptedKeyPEMType,
+		Headers: kdf.pemHeaders(salt),
+		Bytes:   ct,
+	})
+	return &encKey, nil
 }
 
 // DecryptPrivKey takes the encrypted Key as byte slice and the passwort to decrypt the priveate key and returns it with it's type.
+// Keys encrypted with a KDF derived key carry the KDF parameters, keys without them
+// are decrypted with the SHA256 hash of the password used by earlier versions.
 func DecryptPrivKey(data []byte, password string) (crypto.PrivateKey, error) {
 	var plain []byte
 	if password != "" {
 		// Set up the crypto stuff
-		hash := crypto.SHA256.New()
-		hash.Write([]byte(password))
-		hashPW := hash.Sum(nil)
-		aes, err := aes.NewCipher(hashPW)
+		var aesKey []byte
+		if block, _ := pem.Decode(data); block != nil && block.Type == encryptedKeyPEMType {
+			kdf, salt, err := kdfParamsFromPEMHeaders(block.Headers)
+			if err != nil {
+				return nil, err
+			}
+			if aesKey, err = kdf.deriveKey(password, salt); err != nil {
+				return nil, err
+			}
+			data = block.Bytes
+		} else {
+			hash := crypto.SHA256.New()
+			hash.Write([]byte(password))
+			aesKey = hash.Sum(nil)
+		}
+		aes, err := aes.NewCipher(aesKey)
 		if err != nil {
 			return nil, err
 		}
@@ -175,6 +249,9 @@ func DecryptPrivKey(data []byte, password string) (crypto.PrivateKey, error) {
 			return nil, err
 		}
 		nonceSize := aesGCM.NonceSize()
+		if len(data) < nonceSize {
+			return nil, fmt.Errorf("encrypted key is too short")
+		}
 
 		nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 		plain, err = aesGCM.Open(nil, nonce, ciphertext, nil)
@@ -196,35 +273,154 @@ func DecryptPrivKey(data []byte, password string) (crypto.PrivateKey, error) {
 	return key, nil
 }
 
-// ReadPubKey ready a pem encoded RSA/ECC public key file
+// PubKeyFormat is the encoding of a public key file.
+type PubKeyFormat string
+
+const (
+	// PubKeyFormatAuto detects the encodings ParsePubKey supports.
+	PubKeyFormatAuto PubKeyFormat = "auto"
+	// PubKeyFormatRSAModulus is a raw big-endian RSA modulus of 2048 or 3072
+	// bits. It isn't detected automatically, as any blob of that size would
+	// parse as a key.
+	PubKeyFormatRSAModulus PubKeyFormat = "rsa-modulus"
+)
+
+// ReadPubKey reads a RSA/ECC public key file, see ParsePubKey for the supported formats.
 func ReadPubKey(path string) (crypto.PublicKey, error) {
+	return ReadPubKeyFormat(path, PubKeyFormatAuto)
+}
+
+// ReadPubKeyFormat reads a public key file of the given format.
+func ReadPubKeyFormat(path string, format PubKeyFormat) (crypto.PublicKey, error) {
 	raw, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	key, err := ParsePubKeyFormat(raw, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePubKeyFormat parses a public key of the given format. Raw RSA moduli
+// get the public exponent 65537 the manifests store.
+func ParsePubKeyFormat(raw []byte, format PubKeyFormat) (crypto.PublicKey, error) {
+	switch format {
+	case PubKeyFormatAuto, "":
+		return ParsePubKey(raw)
+	case PubKeyFormatRSAModulus:
+		switch len(raw) * 8 {
+		case rsaLen2048, rsaLen3072:
+			if raw[0]&0x80 == 0 {
+				return nil, fmt.Errorf("invalid RSA modulus: the most significant bit of the %d bytes is clear", len(raw))
+			}
+			return &rsa.PublicKey{N: new(big.Int).SetBytes(raw), E: 0x10001}, nil
+		}
+		return nil, fmt.Errorf("invalid RSA modulus of %d bytes, expected %d or %d bytes", len(raw), rsaLen2048/8, rsaLen3072/8)
+	}
+	return nil, fmt.Errorf("unknown public key format '%s', available: %s, %s", format, PubKeyFormatAuto, PubKeyFormatRSAModulus)
+}
+
+// ParsePubKey parses a RSA or ECC public key, auto-detecting its encoding:
+// PEM or DER encoded PKIX (SubjectPublicKeyInfo) and PKCS#1 keys and raw SEC1
+// curve points of P-256, P-384 and P-521 (uncompressed or compressed). Raw RSA
+// moduli are only parsed by ParsePubKeyFormat with PubKeyFormatRSAModulus.
+// The key is returned as *rsa.PublicKey or *ecdsa.PublicKey, Ed25519 keys,
+// which the manifests can't carry, fail with manifest.ErrUnsupportedKeyType.
+func ParsePubKey(raw []byte) (crypto.PublicKey, error) {
+	if bytes.Contains(raw, []byte("-----BEGIN")) {
+		return parsePEMPubKey(raw)
+	}
+	if key, err := x509.ParsePKIXPublicKey(raw); err == nil {
+		return checkPubKeyType(key, "DER PKIX")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(raw); err == nil {
+		return key, nil
+	}
+	if key := parseSEC1Point(raw); key != nil {
+		return key, nil
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(raw); err == nil {
+		return nil, fmt.Errorf("unsupported public key format: DER PKCS#8 private key")
+	}
+	if _, err := x509.ParseCertificate(raw); err == nil {
+		return nil, fmt.Errorf("unsupported public key format: DER certificate")
+	}
+	switch len(raw) * 8 {
+	case rsaLen2048, rsaLen3072:
+		return nil, fmt.Errorf("failed to parse public key: unknown format of %d bytes, select the format %s for a raw RSA modulus", len(raw), PubKeyFormatRSAModulus)
+	}
+	return nil, fmt.Errorf("failed to parse public key: unknown format of %d bytes", len(raw))
+}
+
+func parsePEMPubKey(raw []byte) (crypto.PublicKey, error) {
+	var skipped []string
 	for {
 		block, rest := pem.Decode(raw)
 		if block == nil {
 			break
 		}
-		if !strings.Contains(block.Type, "CERTIFICATE") {
-			if strings.Contains(block.Type, "RSA") {
-				key, err := x509.ParsePKCS1PublicKey(block.Bytes)
-				if err != nil {
-					return nil, fmt.Errorf("Parsing error in x509.ParsePKCS1PublicKey: %v", err)
-				}
-				return key, nil
-			}
+		switch {
+		case block.Type == "PUBLIC KEY":
 			key, err := x509.ParsePKIXPublicKey(block.Bytes)
-			if err == nil {
-				if key, ok := key.(crypto.PublicKey); ok {
-					return key, nil
-				}
-				return nil, fmt.Errorf("found unknown public key type (%T) in PKIX wrapping", key)
+			if err != nil {
+				return nil, fmt.Errorf("Parsing error in x509.ParsePKIXPublicKey: %v", err)
 			}
-			return nil, err
+			return checkPubKeyType(key, "PEM PKIX")
+		case block.Type == "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("Parsing error in x509.ParsePKCS1PublicKey: %v", err)
+			}
+			return key, nil
+		case block.Type == "EC PUBLIC KEY":
+			// not standardized, some tools wrap a PKIX key or a raw point in it
+			if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+				return checkPubKeyType(key, "PEM EC PKIX")
+			}
+			if key := parseSEC1Point(block.Bytes); key != nil {
+				return key, nil
+			}
+			return nil, fmt.Errorf("failed to parse EC PUBLIC KEY block as PKIX or SEC1 point")
+		case strings.Contains(block.Type, "CERTIFICATE"):
+		default:
+			skipped = append(skipped, block.Type)
 		}
 		raw = rest
 	}
+	if len(skipped) != 0 {
+		return nil, fmt.Errorf("unsupported public key format: PEM %s", strings.Join(skipped, ", "))
+	}
 	return nil, fmt.Errorf("failed to parse public key")
 }
+
+func checkPubKeyType(key interface{}, format string) (crypto.PublicKey, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	case ed25519.PublicKey:
+		return nil, fmt.Errorf("%w: Ed25519 in %s", manifest.ErrUnsupportedKeyType, format)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T in %s", key, format)
+	}
+}
+
+// parseSEC1Point parses a raw SEC1 encoded point of the supported curves,
+// the curve is detected by the size of the point.
+func parseSEC1Point(raw []byte) *ecdsa.PublicKey {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		var x, y *big.Int
+		byteLen := (curve.Params().BitSize + 7) / 8
+		switch len(raw) {
+		case 1 + 2*byteLen:
+			x, y = elliptic.Unmarshal(curve, raw)
+		case 1 + byteLen:
+			x, y = elliptic.UnmarshalCompressed(curve, raw)
+		}
+		if x != nil {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	return nil
+}
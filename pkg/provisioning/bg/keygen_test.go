@@ -0,0 +1,228 @@
+package bg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+func TestReadSecretFromStdin(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	f, err := ioutil.TempFile("", "bg-prov-key")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if err := writePrivKeyToFile(key, f, "secret", DefaultKDFParams); err != nil {
+		t.Fatalf("writePrivKeyToFile() failed: %v", err)
+	}
+	f.Close()
+	encKey, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	stdin := bytes.NewReader(encKey)
+	secret, err := ReadSecret(StdinPath, stdin)
+	if err != nil {
+		t.Fatalf("ReadSecret() failed: %v", err)
+	}
+	if !bytes.Equal(secret, encKey) {
+		t.Errorf("ReadSecret() modified the key bytes")
+	}
+	password, err := ReadPassword(StdinPath, bytes.NewReader([]byte("secret\n")))
+	if err != nil {
+		t.Fatalf("ReadPassword() failed: %v", err)
+	}
+	privKey, err := DecryptPrivKey(secret, password)
+	if err != nil {
+		t.Fatalf("DecryptPrivKey() failed: %v", err)
+	}
+	if !key.Equal(privKey) {
+		t.Errorf("DecryptPrivKey() returned a different key")
+	}
+
+	if _, err := ReadSecret(StdinPath, bytes.NewReader(nil)); err == nil {
+		t.Errorf("ReadSecret() succeeded on empty stdin")
+	}
+}
+
+func TestEncryptPrivKeyKDFParams(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	plain := pem.EncodeToMemory(&pem.Block{Bytes: der})
+
+	for _, kdf := range []KDFParams{
+		{KDF: KDFPBKDF2, Iterations: 1000},
+		{KDF: KDFScrypt, ScryptN: 1 << 10, ScryptR: 8, ScryptP: 2},
+	} {
+		encKey, err := encryptPrivFile(&plain, "secret", kdf)
+		if err != nil {
+			t.Fatalf("encryptPrivFile(%s) failed: %v", kdf.KDF, err)
+		}
+		block, _ := pem.Decode(*encKey)
+		if block == nil || block.Headers["KDF"] != kdf.KDF {
+			t.Fatalf("encryptPrivFile(%s) didn't record the KDF", kdf.KDF)
+		}
+		if kdf.KDF == KDFPBKDF2 && block.Headers["Iterations"] != strconv.Itoa(kdf.Iterations) {
+			t.Errorf("encryptPrivFile() recorded %s iterations, expected %d", block.Headers["Iterations"], kdf.Iterations)
+		}
+		privKey, err := DecryptPrivKey(*encKey, "secret")
+		if err != nil {
+			t.Fatalf("DecryptPrivKey(%s) failed: %v", kdf.KDF, err)
+		}
+		if !key.Equal(privKey) {
+			t.Errorf("DecryptPrivKey(%s) returned a different key", kdf.KDF)
+		}
+		if _, err := DecryptPrivKey(*encKey, "wrong"); err == nil {
+			t.Errorf("DecryptPrivKey(%s) succeeded with a wrong password", kdf.KDF)
+		}
+	}
+
+	if _, err := encryptPrivFile(&plain, "secret", KDFParams{KDF: KDFScrypt, ScryptN: 1000, ScryptR: 8, ScryptP: 1}); err == nil {
+		t.Errorf("encryptPrivFile() accepted scrypt N which is not a power of two")
+	}
+}
+
+func TestDecryptPrivKeyLegacy(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	// Keys were encrypted with the SHA256 hash of the password before KDFs were supported
+	hashPW := sha256.Sum256([]byte("secret"))
+	bc, err := aes.NewCipher(hashPW[:])
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(bc)
+	if err != nil {
+		t.Fatalf("Failed to create GCM: %v", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	encKey := gcm.Seal(nonce, nonce, pem.EncodeToMemory(&pem.Block{Bytes: der}), nil)
+
+	privKey, err := DecryptPrivKey(encKey, "secret")
+	if err != nil {
+		t.Fatalf("DecryptPrivKey() failed on legacy key: %v", err)
+	}
+	if !key.Equal(privKey) {
+		t.Errorf("DecryptPrivKey() returned a different key")
+	}
+}
+
+func TestParsePubKeyFormats(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaPKIX, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() failed: %v", err)
+	}
+	ecPKIX, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() failed: %v", err)
+	}
+	rsaPKCS1 := x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+	ecPoint := elliptic.Marshal(elliptic.P256(), ecKey.X, ecKey.Y)
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		key  crypto.PublicKey
+	}{
+		{"PEM PKIX RSA", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPKIX}), &rsaKey.PublicKey},
+		{"PEM PKIX ECDSA", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPKIX}), &ecKey.PublicKey},
+		{"PEM PKCS1", pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: rsaPKCS1}), &rsaKey.PublicKey},
+		{"PEM SEC1 point", pem.EncodeToMemory(&pem.Block{Type: "EC PUBLIC KEY", Bytes: ecPoint}), &ecKey.PublicKey},
+		{"DER PKIX RSA", rsaPKIX, &rsaKey.PublicKey},
+		{"DER PKIX ECDSA", ecPKIX, &ecKey.PublicKey},
+		{"DER PKCS1", rsaPKCS1, &rsaKey.PublicKey},
+		{"SEC1 point", ecPoint, &ecKey.PublicKey},
+		{"SEC1 compressed point", elliptic.MarshalCompressed(elliptic.P256(), ecKey.X, ecKey.Y), &ecKey.PublicKey},
+	} {
+		key, err := ParsePubKey(tc.raw)
+		if err != nil {
+			t.Errorf("%s: ParsePubKey() failed: %v", tc.name, err)
+			continue
+		}
+		if !tc.key.(interface{ Equal(crypto.PublicKey) bool }).Equal(key) {
+			t.Errorf("%s: ParsePubKey() returned a different key", tc.name)
+		}
+	}
+
+	privKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	if _, err := ParsePubKey(privKey); err == nil || !strings.Contains(err.Error(), "RSA PRIVATE KEY") {
+		t.Errorf("ParsePubKey() of a private key returned %v, expected the format name", err)
+	}
+	if _, err := ParsePubKey([]byte("no key")); err == nil {
+		t.Errorf("ParsePubKey() succeeded on garbage")
+	}
+
+	// a raw RSA modulus is only parsed with the explicit format
+	if _, err := ParsePubKey(rsaKey.N.Bytes()); err == nil || !strings.Contains(err.Error(), string(PubKeyFormatRSAModulus)) {
+		t.Errorf("ParsePubKey() of a raw RSA modulus returned %v, expected a hint to the format", err)
+	}
+	key, err := ParsePubKeyFormat(rsaKey.N.Bytes(), PubKeyFormatRSAModulus)
+	if err != nil {
+		t.Fatalf("ParsePubKeyFormat() failed: %v", err)
+	}
+	if !rsaKey.PublicKey.Equal(key) {
+		t.Errorf("ParsePubKeyFormat() returned a different key")
+	}
+	if _, err := ParsePubKeyFormat(rsaPKIX, PubKeyFormatRSAModulus); err == nil {
+		t.Errorf("ParsePubKeyFormat() parsed a DER key as RSA modulus")
+	}
+	if _, err := ParsePubKeyFormat(rsaKey.N.Bytes(), "raw"); err == nil {
+		t.Errorf("ParsePubKeyFormat() accepted an unknown format")
+	}
+
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	edPKIX, err := x509.MarshalPKIXPublicKey(edKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() failed: %v", err)
+	}
+	for _, raw := range [][]byte{edPKIX, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: edPKIX})} {
+		if _, err := ParsePubKey(raw); !errors.Is(err, manifest.ErrUnsupportedKeyType) {
+			t.Errorf("ParsePubKey() of an Ed25519 key returned %v, expected ErrUnsupportedKeyType", err)
+		}
+	}
+}
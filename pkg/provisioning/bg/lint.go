@@ -0,0 +1,71 @@
+package bg
+
+import (
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+// LintOptions select the checks run by LintConfig.
+type LintOptions struct {
+	// Generation is the BootGuard generation the config is for.
+	Generation ACMGeneration
+	// NoAlignChecks and NoNEMCheck skip the checks bpm-gen skips with
+	// --no-align-checks and --no-nem-check.
+	NoAlignChecks bool
+	NoNEMCheck    bool
+	// StrictReserved reports non-zero reserved fields and flags, see
+	// ParseOptions.
+	StrictReserved bool
+}
+
+// LintConfig runs the checks done while generating the KM and BPM on the
+// config alone, without BIOS image or keys. Every problem is reported instead
+// of only the first one. Reserved fields and flags are checked if
+// opts.StrictReserved is set, like ParseKM and ParseBPM do.
+func LintConfig(bgo *BootGuardOptions, opts LintOptions) *CheckResults {
+	// ValidateKMHashAlgs defaults PubKeyHashAlg, which mustn't change the config
+	km := bgo.KeyManifest
+	bpm := &bgo.BootPolicyManifest
+	results := NewCheckResults(
+		NewCheckResult("km-hash-algs", ValidateKMHashAlgs(&km), ""),
+		NewCheckResult("bpm-generation", opts.Generation.ValidateBPM(bpm), string(opts.Generation)),
+		NewCheckResult("migration-todo", CheckMigrationTODO(bgo), ""),
+	)
+	if opts.StrictReserved {
+		results.Add(NewCheckResult("km-reserved", CheckKMReserved(&km), ""))
+		results.Add(NewCheckResult("bpm-reserved", CheckBPMReserved(bpm), ""))
+	}
+	for idx := range bpm.SE {
+		se := &bpm.SE[idx]
+		prefix := fmt.Sprintf("se%d-", idx)
+		results.Add(NewCheckResult(prefix+"ibb-segments", validateIBBSegments(se), ""))
+		results.Add(NewCheckResult(prefix+"ibb-digest-algs", validateIBBDigestAlgs(se), ""))
+		if !opts.NoAlignChecks {
+			results.Add(NewCheckResult(prefix+"addresses", ValidateSEAddresses(se), ""))
+			results.Add(NewCheckResult(prefix+"ibb-alignment", opts.Generation.ValidateIBBAlignment(se), string(opts.Generation)))
+		}
+		if !opts.NoNEMCheck {
+			results.Add(NewCheckResult(prefix+"nem-size", ValidateNEMSize(bpm.BPMH.NEMDataStack, se), ""))
+		}
+	}
+	if len(bgo.OBB) > 0 && len(bpm.SE) > 0 {
+		results.Add(NewCheckResult("obb-region", bgo.OBB.Validate(&bpm.SE[0]), ""))
+	}
+	return results
+}
+
+// validateIBBDigestAlgs checks that the IBB digests of se use known hash
+// algorithms and that digests already set have the size of their algorithm.
+func validateIBBDigestAlgs(se *bootpolicy.SE) error {
+	for idx, d := range se.DigestList.List {
+		hash, err := d.HashAlg.Hash()
+		if err != nil {
+			return fmt.Errorf("IBB digest %d: %w", idx, err)
+		}
+		if len(d.HashBuffer) != 0 && len(d.HashBuffer) != hash.Size() {
+			return fmt.Errorf("IBB digest %d has %d bytes, but %s digests are %d bytes", idx, len(d.HashBuffer), d.HashAlg, hash.Size())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+func lintTestConfig(t *testing.T) *BootGuardOptions {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	return &BootGuardOptions{KeyManifest: *km, BootPolicyManifest: *bpm}
+}
+
+func TestLintConfig(t *testing.T) {
+	bgo := lintTestConfig(t)
+	results := LintConfig(bgo, LintOptions{Generation: ACMGenerationCBnT})
+	if !results.Pass {
+		t.Fatalf("LintConfig() failed on the config of a valid BPM: %+v", results.Failed())
+	}
+
+	se := &bgo.BootPolicyManifest.SE[0]
+	se.IBBMCHBAR = 0x1234
+	se.IBBEntryPoint = 0
+	se.DigestList.List[0].HashAlg = manifest.AlgRSA
+	bgo.KeyManifest.Hash[0].Digest.HashBuffer = []byte{1}
+	bgo.KeyManifest.PubKeyHashAlg = manifest.AlgNull
+	bgo.OBB = OBBRegions{{Base: se.IBBSegments[0].Base, Size: 0x1000}}
+
+	bgo.BootPolicyManifest.BPMH.NEMDataStack = 0
+	results = LintConfig(bgo, LintOptions{Generation: ACMGenerationCBnT})
+	var failed []string
+	for _, r := range results.Failed() {
+		failed = append(failed, r.Check)
+	}
+	expected := []string{"km-hash-algs", "se0-ibb-segments", "se0-ibb-digest-algs", "se0-addresses", "se0-nem-size", "obb-region"}
+	for _, check := range expected {
+		if !containsString(failed, check) {
+			t.Errorf("LintConfig() didn't report the %s problem, failed checks: %v", check, failed)
+		}
+	}
+	if len(failed) != len(expected) {
+		t.Errorf("LintConfig() failed the checks %v, expected %v", failed, expected)
+	}
+	if !bgo.KeyManifest.PubKeyHashAlg.IsNull() {
+		t.Errorf("LintConfig() modified the PubKeyHashAlg of the config")
+	}
+
+	results = LintConfig(bgo, LintOptions{Generation: ACMGenerationCBnT, NoAlignChecks: true, NoNEMCheck: true})
+	for _, r := range results.Checks {
+		if r.Check == "se0-addresses" || r.Check == "se0-ibb-alignment" || r.Check == "se0-nem-size" {
+			t.Errorf("LintConfig() ran the %s check skipped by the options", r.Check)
+		}
+	}
+}
+
+func TestLintConfigGeneration(t *testing.T) {
+	results := LintConfig(lintTestConfig(t), LintOptions{Generation: ACMGenerationLegacy})
+	// BootGuard 1.0 ACMs require page aligned IBB segments, the CBnT fixture's aren't
+	var failed []string
+	for _, r := range results.Failed() {
+		failed = append(failed, r.Check)
+	}
+	if len(failed) != 2 || failed[0] != "bpm-generation" || failed[1] != "se0-ibb-alignment" {
+		t.Errorf("LintConfig() of a legacy config failed the checks %v, expected bpm-generation and se0-ibb-alignment", failed)
+	}
+}
+
+func TestLintConfigStrict(t *testing.T) {
+	// the fixture KM sets a reserved hash usage bit, which ParseKM rejects in strict mode
+	bgo := lintTestConfig(t)
+	results := LintConfig(bgo, LintOptions{Generation: ACMGenerationCBnT, StrictReserved: true})
+	if failed := results.Failed(); len(failed) != 1 || failed[0].Check != "km-reserved" {
+		t.Errorf("LintConfig() with StrictReserved failed the checks %+v, expected km-reserved", failed)
+	}
+}
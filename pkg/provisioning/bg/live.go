@@ -0,0 +1,171 @@
+package bg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// ErrTPMTimeout is returned if the TPM doesn't respond before the deadline,
+// e.g. because it is wedged.
+var ErrTPMTimeout = errors.New("TPM operation timed out")
+
+// LiveMeasurements holds the PCR values read from the TPM of the running system
+// together with the values expected from the manifests of the booted firmware
+// and the ACM policy status of the platform.
+type LiveMeasurements struct {
+	ACMPolicyStatus uint64
+	// Bank is the hash algorithm of the PCR bank the PCRs were read from.
+	Bank         manifest.Algorithm
+	PCR0         []byte
+	ExpectedPCR0 []byte
+	PCR7         []byte
+	// ExpectedPCR7 is only set if the BPM requests authority measurements.
+	ExpectedPCR7     []byte
+	AuthorityMeasure bool
+}
+
+// PCR0Matches returns true if PCR0 equals the value the S-ACM is expected to extend.
+func (m *LiveMeasurements) PCR0Matches() bool {
+	return bytes.Equal(m.PCR0, m.ExpectedPCR0)
+}
+
+// PCR7Matches returns true if PCR7 equals the authority measurement the
+// S-ACM is expected to extend. Firmware which extends further measurements
+// into PCR7 before the PCRs are read, e.g. the Secure Boot variables, doesn't
+// match.
+func (m *LiveMeasurements) PCR7Matches() bool {
+	return bytes.Equal(m.PCR7, m.ExpectedPCR7)
+}
+
+// CheckResults returns the PCR0 comparison and, if the BPM requests authority
+// measurements, the PCR7 comparison as machine-readable results.
+func (m *LiveMeasurements) CheckResults() *CheckResults {
+	r := NewCheckResults(CheckResult{
+		Check:    "pcr0",
+		Pass:     m.PCR0Matches(),
+		Expected: fmt.Sprintf("%x", m.ExpectedPCR0),
+		Actual:   fmt.Sprintf("%x", m.PCR0),
+	})
+	if m.AuthorityMeasure {
+		c := CheckResult{
+			Check:    "pcr7-authority",
+			Pass:     m.PCR7Matches(),
+			Expected: fmt.Sprintf("%x", m.ExpectedPCR7),
+			Actual:   fmt.Sprintf("%x", m.PCR7),
+		}
+		if !c.Pass {
+			c.Detail = "PCR7 doesn't hold the authority measurement of the manifests, or the firmware extended further measurements into it"
+		}
+		r.Add(c)
+	}
+	return r
+}
+
+// VerifyLiveMeasurements reads the ACM policy status from the TXT registers
+// and PCR0 and PCR7 from the TPM and compares the PCRs with the values
+// predicted from the KM, BPM and ACM of the given firmware image and the
+// status. The PCRs are read from the first bank of PCRBanks the BPM has an
+// IBB digest for and the TPM implements. Reading the TPM is aborted with
+// ErrTPMTimeout when ctx is done.
+func VerifyLiveMeasurements(ctx context.Context, txtAPI hwapi.APIInterfaces, image []byte) (*LiveMeasurements, error) {
+	return DefaultParseOptions().VerifyLiveMeasurements(ctx, txtAPI, image)
+}
+
+// VerifyLiveMeasurements is VerifyLiveMeasurements with the options o
+// instead of the DefaultParseOptions.
+func (o ParseOptions) VerifyLiveMeasurements(ctx context.Context, txtAPI hwapi.APIInterfaces, image []byte) (*LiveMeasurements, error) {
+	regs, err := tools.FetchTXTRegs(txtAPI)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the TXT registers: %w", err)
+	}
+	status, err := tools.ReadACMPolicyStatusRaw(regs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the ACM policy status: %w", err)
+	}
+	expected := map[manifest.Algorithm]*LiveMeasurements{}
+	var banks []manifest.Algorithm
+	for _, bank := range PCRBanks {
+		m := LiveMeasurements{ACMPolicyStatus: status, Bank: bank}
+		_, m.ExpectedPCR0, err = o.PredictPCR0(image, status, bank)
+		if errors.Is(err, ErrNoIBBDigest) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, m.ExpectedPCR7, err = o.PredictPCR7(image, status, bank)
+		switch {
+		case err == nil:
+			m.AuthorityMeasure = true
+		case !errors.Is(err, ErrNoAuthorityMeasurement):
+			return nil, err
+		}
+		expected[bank] = &m
+		banks = append(banks, bank)
+	}
+	if len(banks) == 0 {
+		return nil, fmt.Errorf("%w: the BPM has no IBB digest for any of the PCR banks", ErrNoIBBDigest)
+	}
+
+	bank, pcrs, err := readPCRs(ctx, txtAPI, banks)
+	if err != nil {
+		return nil, err
+	}
+	if len(pcrs) < 8 {
+		return nil, fmt.Errorf("TPM returned only %d PCRs", len(pcrs))
+	}
+	m := expected[bank]
+	m.PCR0 = pcrs[0].Digest
+	m.PCR7 = pcrs[7].Digest
+	return m, nil
+}
+
+// readPCRs reads the first of the PCR banks the TPM implements.
+func readPCRs(ctx context.Context, txtAPI hwapi.APIInterfaces, banks []manifest.Algorithm) (manifest.Algorithm, []hwapi.PCR, error) {
+	var bank manifest.Algorithm
+	var pcrs []hwapi.PCR
+	err := withTPMTimeout(ctx, func() error {
+		tpmCon, err := txtAPI.NewTPM()
+		if err != nil {
+			return fmt.Errorf("no TPM found, live measurements can't be verified: %w", err)
+		}
+		defer tpmCon.Close()
+		var errs []string
+		for _, bank = range banks {
+			pcrs, err = tpmCon.ReadPCRs(tpm2.Algorithm(bank))
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", bank, err))
+		}
+		return fmt.Errorf("unable to read a PCR bank the BPM has an IBB digest for: %s", strings.Join(errs, "; "))
+	})
+	if err != nil {
+		return manifest.AlgUnknown, nil, err
+	}
+	return bank, pcrs, nil
+}
+
+// withTPMTimeout runs the TPM operations of op and returns ErrTPMTimeout if
+// they don't complete before ctx is done. A TPM command can't be interrupted,
+// so op is left running in the background and its results are discarded.
+func withTPMTimeout(ctx context.Context, op func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrTPMTimeout, ctx.Err())
+	}
+}
@@ -0,0 +1,266 @@
+package bg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+// wedgedTPMAPI is a hardware API whose TPM never responds.
+type wedgedTPMAPI struct {
+	hwapi.APIInterfaces
+	release chan struct{}
+}
+
+func (a wedgedTPMAPI) NewTPM() (*hwapi.TPM, error) {
+	<-a.release
+	return nil, errors.New("TPM released")
+}
+
+func TestReadPCRsTimeout(t *testing.T) {
+	api := wedgedTPMAPI{release: make(chan struct{})}
+	defer close(api.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := readPCRs(ctx, api, []manifest.Algorithm{manifest.AlgSHA256})
+	if !errors.Is(err, ErrTPMTimeout) {
+		t.Fatalf("readPCRs() returned %v, expected %v", err, ErrTPMTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("readPCRs() returned after %s", elapsed)
+	}
+}
+
+func TestWithTPMTimeoutCompletes(t *testing.T) {
+	expected := errors.New("TPM error")
+	err := withTPMTimeout(context.Background(), func() error {
+		return expected
+	})
+	if err != expected {
+		t.Errorf("withTPMTimeout() returned %v, expected %v", err, expected)
+	}
+}
+
+// txtACMPolicyStatusOffset is the offset of ACM_POLICY_STATUS in the TXT
+// public space.
+const txtACMPolicyStatusOffset = 0x378
+
+// liveAPI is a hardware API with the given ACM policy status in the TXT
+// registers and a TPM holding the given PCR banks: a TPM 1.2 with the SHA1
+// bank only, unless tpm20 is set.
+type liveAPI struct {
+	hwapi.APIInterfaces
+	status uint64
+	tpm20  bool
+	banks  map[manifest.Algorithm]*[24][]byte
+}
+
+func (a liveAPI) ReadPhysBuf(addr int64, buf []byte) error {
+	for idx := range buf {
+		buf[idx] = 0
+	}
+	binary.LittleEndian.PutUint64(buf[txtACMPolicyStatusOffset:], a.status)
+	return nil
+}
+
+func (a liveAPI) NewTPM() (*hwapi.TPM, error) {
+	if a.tpm20 {
+		return &hwapi.TPM{Version: hwapi.TPMVersion20, RWC: &fakeTPM20{banks: a.banks}}, nil
+	}
+	return &hwapi.TPM{Version: hwapi.TPMVersion12, RWC: &fakeTPM12{pcrs: a.banks[manifest.AlgSHA1]}}, nil
+}
+
+// fakeTPM12 answers TPM_PCRRead commands with the given PCRs.
+type fakeTPM12 struct {
+	pcrs     *[24][]byte
+	response bytes.Buffer
+}
+
+func (f *fakeTPM12) Write(cmd []byte) (int, error) {
+	// tag, size, ordinal TPM_ORD_PcrRead and the PCR index
+	if len(cmd) != 14 || binary.BigEndian.Uint32(cmd[6:]) != 0x15 {
+		return 0, fmt.Errorf("unexpected TPM command %x", cmd)
+	}
+	pcr := binary.BigEndian.Uint32(cmd[10:])
+	if f.pcrs == nil || pcr >= uint32(len(f.pcrs)) {
+		return 0, fmt.Errorf("PCR %d doesn't exist", pcr)
+	}
+	f.response.Reset()
+	// tag TPM_TAG_RSP_COMMAND, size and return code TPM_SUCCESS
+	_ = binary.Write(&f.response, binary.BigEndian, struct {
+		Tag        uint16
+		Size       uint32
+		ReturnCode uint32
+	}{0xc4, 10 + 20, 0})
+	f.response.Write(f.pcrs[pcr])
+	return len(cmd), nil
+}
+
+func (f *fakeTPM12) Read(buf []byte) (int, error) {
+	return f.response.Read(buf)
+}
+
+func (f *fakeTPM12) Close() error {
+	return nil
+}
+
+// fakeTPM20 answers TPM2_PCR_Read commands with the PCRs of the given banks,
+// at most 8 PCRs per command like real TPMs.
+type fakeTPM20 struct {
+	banks    map[manifest.Algorithm]*[24][]byte
+	response bytes.Buffer
+}
+
+func (f *fakeTPM20) Write(cmd []byte) (int, error) {
+	// tag, size, command code TPM2_CC_PCR_Read and a TPML_PCR_SELECTION of
+	// one bank with a 3 byte PCR bitmap
+	if len(cmd) != 20 || binary.BigEndian.Uint32(cmd[6:]) != 0x17e || binary.BigEndian.Uint32(cmd[10:]) != 1 || cmd[16] != 3 {
+		return 0, fmt.Errorf("unexpected TPM command %x", cmd)
+	}
+	alg := manifest.Algorithm(binary.BigEndian.Uint16(cmd[14:]))
+	var body bytes.Buffer
+	pcrs, ok := f.banks[alg]
+	if !ok {
+		// TPM_RC_VALUE for the hash algorithm of an unimplemented bank
+		f.respond(0x84, nil)
+		return len(cmd), nil
+	}
+	var selected [3]byte
+	var digests [][]byte
+	for pcr := 0; pcr < 24 && len(digests) < 8; pcr++ {
+		if cmd[17+pcr/8]&(1<<uint(pcr%8)) != 0 {
+			selected[pcr/8] |= 1 << uint(pcr%8)
+			digests = append(digests, pcrs[pcr])
+		}
+	}
+	// pcrUpdateCounter and the TPML_PCR_SELECTION of the returned PCRs
+	_ = binary.Write(&body, binary.BigEndian, struct {
+		UpdateCounter uint32
+		Count         uint32
+		Hash          uint16
+		SizeOfSelect  uint8
+		Select        [3]byte
+		DigestCount   uint32
+	}{0, 1, uint16(alg), 3, selected, uint32(len(digests))})
+	for _, d := range digests {
+		_ = binary.Write(&body, binary.BigEndian, uint16(len(d)))
+		body.Write(d)
+	}
+	f.respond(0, body.Bytes())
+	return len(cmd), nil
+}
+
+func (f *fakeTPM20) respond(rc uint32, body []byte) {
+	f.response.Reset()
+	// tag TPM_ST_NO_SESSIONS, size and response code
+	_ = binary.Write(&f.response, binary.BigEndian, struct {
+		Tag          uint16
+		Size         uint32
+		ResponseCode uint32
+	}{0x8001, uint32(10 + len(body)), rc})
+	f.response.Write(body)
+}
+
+func (f *fakeTPM20) Read(buf []byte) (int, error) {
+	return f.response.Read(buf)
+}
+
+func (f *fakeTPM20) Close() error {
+	return nil
+}
+
+// resetBank returns the PCRs of a bank after reset with the given digest size.
+func resetBank(size int) *[24][]byte {
+	var pcrs [24][]byte
+	for idx := range pcrs {
+		pcrs[idx] = make([]byte, size)
+	}
+	return &pcrs
+}
+
+// liveTestImage returns an image whose BPM requests authority measurements.
+func liveTestImage(t *testing.T) []byte {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	image := vectors[0].BIOS
+	bpm, err := ParseBPM(bytes.NewReader(vectors[0].BPM))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	// the flags don't change the size of the BPM, its signature isn't checked
+	bpm.SE[0].Flags |= 0x04
+	data, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	copy(image[testVectorBPMOffset:], data)
+	return image
+}
+
+func TestVerifyLiveMeasurements(t *testing.T) {
+	image := liveTestImage(t)
+	const status = 0x1234f
+	// the BPM has only a SHA256 IBB digest, so the SHA256 bank is compared
+	_, pcr0, err := PredictPCR0(image, status, manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("PredictPCR0() failed: %v", err)
+	}
+	_, pcr7, err := PredictPCR7(image, status, manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("PredictPCR7() failed: %v", err)
+	}
+	sha256Bank := resetBank(32)
+	sha256Bank[0], sha256Bank[7] = pcr0, pcr7
+	api := liveAPI{status: status, tpm20: true, banks: map[manifest.Algorithm]*[24][]byte{
+		manifest.AlgSHA1:   resetBank(20),
+		manifest.AlgSHA256: sha256Bank,
+	}}
+
+	m, err := VerifyLiveMeasurements(context.Background(), api, image)
+	if err != nil {
+		t.Fatalf("VerifyLiveMeasurements() failed: %v", err)
+	}
+	if m.ACMPolicyStatus != status || !m.AuthorityMeasure || m.Bank != manifest.AlgSHA256 {
+		t.Errorf("VerifyLiveMeasurements() returned %+v", m)
+	}
+	if r := m.CheckResults(); !r.Pass || len(r.Checks) != 2 {
+		t.Errorf("the live measurements of the image don't match: %+v", r)
+	}
+
+	// the PCRs of a platform with another ACM policy status
+	api.status = status + 1
+	if m, err = VerifyLiveMeasurements(context.Background(), api, image); err != nil {
+		t.Fatalf("VerifyLiveMeasurements() failed: %v", err)
+	}
+	if m.PCR0Matches() || m.PCR7Matches() || m.CheckResults().Pass {
+		t.Errorf("VerifyLiveMeasurements() matched the PCRs of another ACM policy status")
+	}
+
+	// the firmware extended further measurements into PCR7
+	api.status = status
+	sha256Bank[7] = bytes.Repeat([]byte{0x01}, 32)
+	if m, err = VerifyLiveMeasurements(context.Background(), api, image); err != nil {
+		t.Fatalf("VerifyLiveMeasurements() failed: %v", err)
+	}
+	if !m.PCR0Matches() || m.PCR7Matches() {
+		t.Errorf("VerifyLiveMeasurements() returned %+v for a modified PCR7", m)
+	}
+
+	// a TPM 1.2 has only the SHA1 bank the BPM has no IBB digest for
+	api.tpm20 = false
+	if _, err = VerifyLiveMeasurements(context.Background(), api, image); err == nil || !strings.Contains(err.Error(), "SHA256") {
+		t.Errorf("VerifyLiveMeasurements() with a TPM 1.2 returned %v, expected the SHA256 bank to be unreadable", err)
+	}
+}
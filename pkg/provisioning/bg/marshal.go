@@ -26,6 +26,20 @@ func WriteBPM(bpm *bootpolicy.Manifest) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
+// PadManifest pads a written manifest with fill bytes up to the given size.
+// The padding is appended after the manifest and doesn't touch its signed region.
+func PadManifest(data []byte, size uint32, fill byte) ([]byte, error) {
+	if uint64(len(data)) > uint64(size) {
+		return nil, fmt.Errorf("%w: manifest size %d exceeds the padding size %d", ErrSizeMismatch, len(data), size)
+	}
+	padded := make([]byte, size)
+	copy(padded, data)
+	for i := len(data); i < len(padded); i++ {
+		padded[i] = fill
+	}
+	return padded, nil
+}
+
 // StitchKM returns a key manifest manifest as byte slice
 func StitchKM(km *key.Manifest, pubKey crypto.PublicKey, signature []byte) ([]byte, error) {
 	if err := km.KeyAndSignature.FillSignature(0, pubKey, signature, km.PubKeyHashAlg); err != nil {
@@ -0,0 +1,234 @@
+package bg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func signedTestKM(t *testing.T) []byte {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	if err := km.KeyAndSignature.Key.SetPubKey(privKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	unsigned, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if err := km.SetSignature(0, privKey, unsigned[:km.KeyAndSignatureOffset()]); err != nil {
+		t.Fatalf("SetSignature() failed: %v", err)
+	}
+	signed, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	return signed
+}
+
+func TestPadManifest(t *testing.T) {
+	signed := signedTestKM(t)
+	for _, fill := range []byte{0x00, 0xff} {
+		padded, err := PadManifest(signed, uint32(len(signed)+256), fill)
+		if err != nil {
+			t.Fatalf("PadManifest() failed: %v", err)
+		}
+		if len(padded) != len(signed)+256 || padded[len(padded)-1] != fill {
+			t.Errorf("PadManifest() returned wrong padding")
+		}
+		if !bytes.Equal(padded[:len(signed)], signed) {
+			t.Errorf("PadManifest() modified the manifest")
+		}
+		if _, err := VerifyKM(padded); err != nil {
+			t.Errorf("VerifyKM() failed on padded KM: %v", err)
+		}
+	}
+	if _, err := PadManifest(signed, uint32(len(signed)-1), 0); err == nil {
+		t.Errorf("PadManifest() succeeded although the manifest exceeds the size")
+	}
+}
+
+func TestKMWithBPMHashAlgDifferentFromPKHashAlg(t *testing.T) {
+	kmKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bpmKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "bpmhashalg")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	bpmPubPath := filepath.Join(dir, "bpm_pub.pem")
+	bpmPub := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&bpmKey.PublicKey)})
+	if err := ioutil.WriteFile(bpmPubPath, bpmPub, 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	kh, err := GetBPMPubHash(bpmPubPath, manifest.AlgSHA384)
+	if err != nil {
+		t.Fatalf("GetBPMPubHash() failed: %v", err)
+	}
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	km.Hash = kh
+	if err := ValidateKMHashAlgs(km); err != nil {
+		t.Fatalf("ValidateKMHashAlgs() failed: %v", err)
+	}
+	if err := CheckKMHashAlgsSupported(km, []manifest.Algorithm{manifest.AlgSHA256, manifest.AlgSHA384}); err != nil {
+		t.Errorf("CheckKMHashAlgsSupported() failed: %v", err)
+	}
+	if err := CheckKMHashAlgsSupported(km, []manifest.Algorithm{manifest.AlgSHA256}); err == nil {
+		t.Errorf("CheckKMHashAlgsSupported() accepted a BPM hash the ACM doesn't support")
+	}
+
+	if err := km.KeyAndSignature.Key.SetPubKey(kmKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	km.RehashRecursive()
+	unsigned, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if err := km.SetSignature(0, kmKey, unsigned[:km.KeyAndSignatureOffset()]); err != nil {
+		t.Fatalf("SetSignature() failed: %v", err)
+	}
+	signed, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if _, err := VerifyKM(signed); err != nil {
+		t.Errorf("VerifyKM() failed: %v", err)
+	}
+
+	bpm := bootpolicy.NewManifest()
+	if err := bpm.PMSE.Key.SetPubKey(bpmKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	parsed, err := ParseKM(bytes.NewReader(signed))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	if parsed.PubKeyHashAlg != manifest.AlgSHA256 || parsed.Hash[0].Digest.HashAlg != manifest.AlgSHA384 {
+		t.Errorf("KM uses %s and %s, expected SHA256 and SHA384", parsed.PubKeyHashAlg, parsed.Hash[0].Digest.HashAlg)
+	}
+	if r := CheckKMHashes(parsed, bpm); !r.Pass {
+		t.Errorf("CheckKMHashes() reported mismatches: %+v", r.Failed())
+	}
+}
+
+func TestSigningInputRoundTrip(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	km.RehashRecursive()
+	unsigned, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	c, err := KMCoverage(unsigned)
+	if err != nil {
+		t.Fatalf("KMCoverage() failed: %v", err)
+	}
+
+	for _, digest := range []bool{false, true} {
+		input, err := c.SigningInput(unsigned, digest)
+		if err != nil {
+			t.Fatalf("SigningInput() failed: %v", err)
+		}
+		var sig []byte
+		if digest {
+			// the signer signs the digest as is
+			sig, err = rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA256, input)
+		} else {
+			// the signer hashes the bytes itself
+			h := sha256.Sum256(input)
+			sig, err = rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA256, h[:])
+		}
+		if err != nil {
+			t.Fatalf("SignPKCS1v15() failed: %v", err)
+		}
+		parsed, err := ParseKM(bytes.NewReader(unsigned))
+		if err != nil {
+			t.Fatalf("ParseKM() failed: %v", err)
+		}
+		signed, err := StitchKM(parsed, privKey.Public(), sig)
+		if err != nil {
+			t.Fatalf("StitchKM() failed: %v", err)
+		}
+		if _, err := VerifyKM(signed); err != nil {
+			t.Errorf("VerifyKM() of the KM signed in digest mode %t failed: %v", digest, err)
+		}
+	}
+}
+
+func TestSigningInputHashAlg(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA384
+	km.RehashRecursive()
+	unsigned, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	c, err := KMCoverage(unsigned)
+	if err != nil {
+		t.Fatalf("KMCoverage() failed: %v", err)
+	}
+	if c.HashAlg != manifest.AlgSHA384 {
+		t.Fatalf("KMCoverage() reported %s for an unsigned SHA384 KM", c.HashAlg)
+	}
+	digest, err := c.SigningInput(unsigned, true)
+	if err != nil {
+		t.Fatalf("SigningInput() failed: %v", err)
+	}
+	h := sha512.Sum384(c.SignedBytes(unsigned))
+	if !bytes.Equal(digest, h[:]) {
+		t.Fatalf("SigningInput() returned %x, expected the SHA384 digest %x", digest, h)
+	}
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA384, digest)
+	if err != nil {
+		t.Fatalf("SignPKCS1v15() failed: %v", err)
+	}
+	signed, err := StitchKM(km, privKey.Public(), sig)
+	if err != nil {
+		t.Fatalf("StitchKM() failed: %v", err)
+	}
+	if _, err := VerifyKM(signed); err != nil {
+		t.Errorf("VerifyKM() of the SHA384 signed KM failed: %v", err)
+	}
+	c, err = KMCoverage(signed)
+	if err != nil {
+		t.Fatalf("KMCoverage() failed: %v", err)
+	}
+	if c.HashAlg != manifest.AlgSHA384 {
+		t.Errorf("KMCoverage() reported %s for a KM signed with SHA384", c.HashAlg)
+	}
+}
@@ -0,0 +1,139 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// ErrMigrationTODO is returned by CheckMigrationTODO if a migrated config
+// still has fields which need manual attention.
+var ErrMigrationTODO = errors.New("the migrated config has unresolved TODO markers")
+
+// LegacyKeyManifest holds the fields of a BootGuard 1.0 Key Manifest.
+type LegacyKeyManifest struct {
+	KMVersion uint8                  `json:"km_Version"`
+	KMSVN     uint8                  `json:"km_SVN"`
+	KMID      uint8                  `json:"km_ID"`
+	BPKey     manifest.HashStructure `json:"km_BPKey"`
+}
+
+// LegacyIBB holds the fields of the IBB element of a BootGuard 1.0 Boot
+// Policy Manifest.
+type LegacyIBB struct {
+	Flags       uint32                 `json:"ibb_Flags"`
+	MCHBAR      uint64                 `json:"ibb_MCHBAR"`
+	VTdBAR      uint64                 `json:"ibb_VTdBAR"`
+	PMRLBase    uint32                 `json:"ibb_PMRLBase"`
+	PMRLLimit   uint32                 `json:"ibb_PMRLLimit"`
+	PMRHBase    uint64                 `json:"ibb_PMRHBase"`
+	PMRHLimit   uint64                 `json:"ibb_PMRHLimit"`
+	PostIBBHash manifest.HashStructure `json:"ibb_PostIBBHash"`
+	EntryPoint  uint32                 `json:"ibb_EntryPoint"`
+	Digest      manifest.HashStructure `json:"ibb_Digest"`
+	Segments    []IbbSegment           `json:"ibb_Segments"`
+}
+
+// LegacyBootPolicyManifest holds the fields of a BootGuard 1.0 Boot Policy
+// Manifest.
+type LegacyBootPolicyManifest struct {
+	BPMRevision  uint8     `json:"bpm_Revision"`
+	BPMSVN       uint8     `json:"bpm_SVN"`
+	ACMSVN       uint8     `json:"bpm_ACMSVN"`
+	NEMDataStack uint16    `json:"bpm_NEMStackSize"`
+	IBB          LegacyIBB `json:"bpm_IBB"`
+	PlatformData []byte    `json:"bpm_PlatformData,omitempty"`
+}
+
+// LegacyBootGuardOptions is the config of a BootGuard 1.0 platform.
+type LegacyBootGuardOptions struct {
+	KeyManifest        LegacyKeyManifest
+	BootPolicyManifest LegacyBootPolicyManifest
+}
+
+// ParseLegacyConfig parses a BootGuard 1.0 config. Unknown keys are rejected,
+// because MigrateConfig couldn't carry them over.
+func ParseLegacyConfig(data []byte) (*LegacyBootGuardOptions, error) {
+	var legacy LegacyBootGuardOptions
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&legacy); err != nil {
+		return nil, fmt.Errorf("unable to parse the legacy config: %w", err)
+	}
+	return &legacy, nil
+}
+
+// MigrateConfig maps a BootGuard 1.0 config to a CBnT config. The IBB element
+// becomes the IBBS element, the platform data the PM element and the BP key
+// hash the BPM signing key hash of the KM. Fields without a CBnT counterpart
+// and the CBnT fields without a legacy source are listed in the TODO of the
+// config instead of being guessed.
+func MigrateConfig(legacy *LegacyBootGuardOptions) *BootGuardOptions {
+	var bgo BootGuardOptions
+	todof := func(format string, args ...interface{}) {
+		bgo.TODO = append(bgo.TODO, fmt.Sprintf(format, args...))
+	}
+
+	lkm := &legacy.KeyManifest
+	km := &bgo.KeyManifest
+	km.Revision = lkm.KMVersion
+	km.KMSVN = manifest.SVN(lkm.KMSVN)
+	km.KMID = lkm.KMID
+	km.Hash = []key.Hash{{
+		Usage:  key.UsageBPMSigningPKD,
+		Digest: lkm.BPKey,
+	}}
+	todof("km_PubKeyHashAlg: the hash algorithm of the OEM key hash in the FPFs, BootGuard 1.0 only used SHA256")
+
+	lbpm := &legacy.BootPolicyManifest
+	bpm := &bgo.BootPolicyManifest
+	bpm.BPMH.BPMRevision = lbpm.BPMRevision
+	bpm.BPMH.BPMSVN = manifest.SVN(lbpm.BPMSVN)
+	bpm.BPMH.ACMSVNAuth = manifest.SVN(lbpm.ACMSVN)
+	bpm.BPMH.NEMDataStack = bootpolicy.Size4K(lbpm.NEMDataStack)
+
+	ibb := &lbpm.IBB
+	se := bootpolicy.NewSE()
+	se.IBBMCHBAR = ibb.MCHBAR
+	se.VTdBAR = ibb.VTdBAR
+	se.DMAProtBase0 = ibb.PMRLBase
+	se.DMAProtLimit0 = ibb.PMRLLimit
+	se.DMAProtBase1 = ibb.PMRHBase
+	se.DMAProtLimit1 = ibb.PMRHLimit
+	se.PostIBBHash = ibb.PostIBBHash
+	se.IBBEntryPoint = ibb.EntryPoint
+	se.DigestList.List = []manifest.HashStructure{ibb.Digest}
+	for _, seg := range ibb.Segments {
+		s := *bootpolicy.NewIBBSegment()
+		s.Base = seg.Offset
+		s.Size = seg.Size
+		s.Flags = seg.Flags
+		se.IBBSegments = append(se.IBBSegments, s)
+	}
+	todof("se_Flags: the legacy IBB flags 0x%08x are not mapped, the CBnT SE flags are defined differently", ibb.Flags)
+	todof("se_PBETValue: CBnT only, set the boot policy enforcement timeout, the default is %d", se.PBETValue)
+	todof("se_OBBHash: CBnT only, set the OBB regions of the config or leave the OBB hash empty")
+	bpm.SE = []bootpolicy.SE{*se}
+
+	if len(lbpm.PlatformData) > 0 {
+		bpm.PME = bootpolicy.NewPM()
+		bpm.PME.Data = lbpm.PlatformData
+	}
+	bpm.TXTE = bootpolicy.NewTXT()
+	todof("TXTE: CBnT only, review the TXT element defaults, e.g. the ACPI and PWRM bases and the PTT CMOS offsets")
+	return &bgo
+}
+
+// CheckMigrationTODO returns an error listing the TODO markers of a migrated
+// config.
+func CheckMigrationTODO(bgo *BootGuardOptions) error {
+	if len(bgo.TODO) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d marker(s), first: %s", ErrMigrationTODO, len(bgo.TODO), bgo.TODO[0])
+}
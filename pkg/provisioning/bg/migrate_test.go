@@ -0,0 +1,93 @@
+package bg
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/legacy_config.json")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	legacy, err := ParseLegacyConfig(data)
+	if err != nil {
+		t.Fatalf("ParseLegacyConfig() failed: %v", err)
+	}
+	bgo := MigrateConfig(legacy)
+
+	km := &bgo.KeyManifest
+	if km.Revision != 16 || km.KMSVN != 2 || km.KMID != 1 || len(km.Hash) != 1 {
+		t.Errorf("MigrateConfig() returned the KM %+v", km)
+	}
+	if h := km.Hash[0]; h.Usage != key.UsageBPMSigningPKD || h.Digest.HashAlg != manifest.AlgSHA256 || len(h.Digest.HashBuffer) != 32 {
+		t.Errorf("MigrateConfig() returned the BP key hash %+v", h)
+	}
+
+	bpm := &bgo.BootPolicyManifest
+	if bpm.BPMH.BPMRevision != 3 || bpm.BPMH.BPMSVN != 1 || bpm.BPMH.ACMSVNAuth != 2 || bpm.BPMH.NEMDataStack != 64 {
+		t.Errorf("MigrateConfig() returned the BPM header %+v", bpm.BPMH)
+	}
+	if len(bpm.SE) != 1 {
+		t.Fatalf("MigrateConfig() returned %d IBBS elements, expected 1", len(bpm.SE))
+	}
+	se := &bpm.SE[0]
+	if se.IBBMCHBAR != 0xfed10000 || se.VTdBAR != 0xfed90000 || se.DMAProtLimit0 != 0x1000000 || se.IBBEntryPoint != 0xfffffff0 {
+		t.Errorf("MigrateConfig() returned the IBBS element %+v", se)
+	}
+	if len(se.IBBSegments) != 2 || se.IBBSegments[1].Base != 0xffff0000 || se.IBBSegments[1].Size != 0x10000 {
+		t.Errorf("MigrateConfig() returned the IBB segments %+v", se.IBBSegments)
+	}
+	if len(se.DigestList.List) != 1 || se.DigestList.List[0].HashAlg != manifest.AlgSHA256 {
+		t.Errorf("MigrateConfig() returned the IBB digests %+v", se.DigestList.List)
+	}
+	if se.Flags != 0 {
+		t.Errorf("MigrateConfig() mapped the legacy IBB flags to 0x%x", se.Flags)
+	}
+	if bpm.PME == nil || string(bpm.PME.Data) != "OEM data" || bpm.TXTE == nil {
+		t.Errorf("MigrateConfig() returned the PM element %+v and TXT element %+v", bpm.PME, bpm.TXTE)
+	}
+
+	// the unmapped IBB flags are kept as TODO marker
+	todo := strings.Join(bgo.TODO, "\n")
+	for _, field := range []string{"km_PubKeyHashAlg", "se_Flags", "0x00000003", "se_PBETValue", "se_OBBHash", "TXTE"} {
+		if !strings.Contains(todo, field) {
+			t.Errorf("MigrateConfig() has no TODO marker for %s: %q", field, bgo.TODO)
+		}
+	}
+	if err := CheckMigrationTODO(bgo); !errors.Is(err, ErrMigrationTODO) {
+		t.Errorf("CheckMigrationTODO() returned %v, expected ErrMigrationTODO", err)
+	}
+	var failed []string
+	for _, r := range LintConfig(bgo, LintOptions{Generation: ACMGenerationCBnT}).Failed() {
+		failed = append(failed, r.Check)
+	}
+	if !containsString(failed, "migration-todo") {
+		t.Errorf("LintConfig() of the migrated config didn't fail migration-todo: %v", failed)
+	}
+
+	// the migrated config is a valid config, including the TODO markers
+	cfg, err := CanonicalConfig(bgo)
+	if err != nil {
+		t.Fatalf("CanonicalConfig() failed: %v", err)
+	}
+	formatted, err := FormatConfig(cfg)
+	if err != nil {
+		t.Fatalf("FormatConfig() of the migrated config failed: %v", err)
+	}
+	if !bytes.Equal(formatted, cfg) {
+		t.Errorf("FormatConfig() changed the migrated config")
+	}
+}
+
+func TestParseLegacyConfigUnknownField(t *testing.T) {
+	if _, err := ParseLegacyConfig([]byte(`{"BootPolicyManifest": {"bpm_Unknown": 1}}`)); err == nil {
+		t.Errorf("ParseLegacyConfig() dropped an unknown field")
+	}
+}
@@ -0,0 +1,185 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// OBBRegion is a region of the OEM boot block of the firmware image, the part
+// of the BIOS verified by the IBB after the ACM measured the IBB segments.
+// CBnT BPMs carry the digest of the OBB in the OBB hash of the IBBS element.
+type OBBRegion struct {
+	// Base is the flash address of the OBB, as the bases of the IBB segments.
+	Base uint32 `json:"base"`
+	Size uint32 `json:"size"`
+	// Flags are IBB segment flags, a region flagged as not measured is not
+	// part of the digest.
+	Flags uint16 `json:"flags,omitempty"`
+	// Algorithm is the hash algorithm of the OBB hash. Regions without
+	// algorithm use the one of the other regions.
+	Algorithm manifest.Algorithm `json:"algorithm,omitempty"`
+}
+
+func (obb OBBRegion) segment() bootpolicy.IBBSegment {
+	seg := *bootpolicy.NewIBBSegment()
+	seg.Base = obb.Base
+	seg.Size = obb.Size
+	seg.Flags = obb.Flags
+	return seg
+}
+
+// Validate checks that the OBB is not empty, ends below 4GiB, has no reserved
+// flags and doesn't overlap the measured IBB segments of se.
+func (obb OBBRegion) Validate(se *bootpolicy.SE) error {
+	if obb.Size == 0 {
+		return fmt.Errorf("OBB at 0x%x has size zero", obb.Base)
+	}
+	if uint64(obb.Base)+uint64(obb.Size) > tools.FourGiB {
+		return fmt.Errorf("OBB at 0x%x with size 0x%x exceeds 4GiB", obb.Base, obb.Size)
+	}
+	if reserved := obb.Flags &^ bootpolicy.IBBSegmentFlagNotMeasured; reserved != 0 {
+		return fmt.Errorf("OBB at 0x%x has reserved flags 0x%x set", obb.Base, reserved)
+	}
+	seg := obb.segment()
+	for idx, ibb := range se.IBBSegments {
+		if !ibb.IsMeasured() {
+			continue
+		}
+		if seg.Contains(ibb.Base) || ibb.Contains(seg.Base) {
+			return fmt.Errorf("OBB at 0x%x overlaps measured IBB segment %d at 0x%x", obb.Base, idx, ibb.Base)
+		}
+	}
+	return nil
+}
+
+// OBBRegions are the regions of the OBB, which are hashed in order into the
+// single OBB hash of the IBBS element.
+type OBBRegions []OBBRegion
+
+// UnmarshalJSON accepts a single region as well as a list of regions, since
+// configs used to hold a single OBB region. Unknown keys are rejected, a
+// misspelled algorithm would silently change the OBB hash.
+func (r *OBBRegions) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var obb OBBRegion
+		if err := dec.Decode(&obb); err != nil {
+			return err
+		}
+		*r = OBBRegions{obb}
+		return nil
+	}
+	return dec.Decode((*[]OBBRegion)(r))
+}
+
+// Validate checks every region against se, that the regions don't overlap
+// each other, that at least one is measured and that they agree on the hash
+// algorithm.
+func (r OBBRegions) Validate(se *bootpolicy.SE) error {
+	var measured bool
+	for idx, obb := range r {
+		if err := obb.Validate(se); err != nil {
+			return fmt.Errorf("OBB region %d: %w", idx, err)
+		}
+		for otherIdx, other := range r[:idx] {
+			if seg := obb.segment(); seg.Contains(other.Base) || other.segment().Contains(seg.Base) {
+				return fmt.Errorf("OBB region %d at 0x%x overlaps OBB region %d at 0x%x", idx, obb.Base, otherIdx, other.Base)
+			}
+		}
+		measured = measured || obb.segment().IsMeasured()
+	}
+	if !measured {
+		return fmt.Errorf("none of the %d OBB regions is measured", len(r))
+	}
+	_, err := r.algorithm(se)
+	return err
+}
+
+// algorithm returns the hash algorithm of the OBB hash: the algorithm of the
+// regions, the current one of the OBB hash of se if the regions don't set one
+// or SHA256 if neither is set.
+func (r OBBRegions) algorithm(se *bootpolicy.SE) (manifest.Algorithm, error) {
+	alg := manifest.AlgNull
+	for idx, obb := range r {
+		switch {
+		case obb.Algorithm.IsNull():
+		case alg.IsNull():
+			alg = obb.Algorithm
+		case obb.Algorithm != alg:
+			return alg, fmt.Errorf("OBB region %d uses %s, but the OBB hash is %s: all regions are hashed into one digest", idx, obb.Algorithm, alg)
+		}
+	}
+	if !alg.IsNull() {
+		if _, err := alg.Hash(); err != nil {
+			return alg, fmt.Errorf("OBB hash: %w", err)
+		}
+		return alg, nil
+	}
+	if !se.OBBHash.HashAlg.IsNull() {
+		return se.OBBHash.HashAlg, nil
+	}
+	return manifest.AlgSHA256, nil
+}
+
+// MeasureOBB sets the OBB hash of se to the digest of the measured regions of
+// the OBB of the BIOS image, hashed in order.
+func MeasureOBB(se *bootpolicy.SE, regions OBBRegions, image []byte) error {
+	return DefaultParseOptions().MeasureOBB(se, regions, image)
+}
+
+// MeasureOBB is MeasureOBB with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) MeasureOBB(se *bootpolicy.SE, regions OBBRegions, image []byte) error {
+	if err := regions.Validate(se); err != nil {
+		return err
+	}
+	alg, err := regions.algorithm(se)
+	if err != nil {
+		return err
+	}
+	segments := make([]bootpolicy.IBBSegment, len(regions))
+	for idx, obb := range regions {
+		segments[idx] = obb.segment()
+	}
+	digest, err := getIBBsDigest(segments, image, alg, o.Layout)
+	if err != nil {
+		return fmt.Errorf("unable to measure the OBB with %s: %w", alg, err)
+	}
+	se.OBBHash.HashAlg = alg
+	se.OBBHash.HashBuffer = digest
+	return nil
+}
+
+// PrintMeasurements writes the IBB digests and segments and the OBB digest of
+// every IBBS element of the BPM, separating what the ACM measures from what
+// the IBB verifies.
+func PrintMeasurements(w io.Writer, bpm *bootpolicy.Manifest) {
+	for idx, se := range bpm.SE {
+		fmt.Fprintf(w, "IBBS element %d:\n", idx)
+		fmt.Fprintf(w, "  IBB entry point: 0x%08x\n", se.IBBEntryPoint)
+		fmt.Fprintln(w, "  IBB digests:")
+		for _, d := range se.DigestList.List {
+			fmt.Fprintf(w, "    %-8s %x\n", d.HashAlg, d.HashBuffer)
+		}
+		fmt.Fprintln(w, "  IBB segments:")
+		for _, seg := range se.IBBSegments {
+			measured := "measured"
+			if !seg.IsMeasured() {
+				measured = "not measured"
+			}
+			fmt.Fprintf(w, "    0x%08x-0x%08x  %s\n", seg.Base, segEnd(seg)-1, measured)
+		}
+		if se.OBBHash.HashAlg.IsNull() {
+			fmt.Fprintln(w, "  OBB digest: not set")
+		} else {
+			fmt.Fprintf(w, "  OBB digest: %-8s %x\n", se.OBBHash.HashAlg, se.OBBHash.HashBuffer)
+		}
+	}
+}
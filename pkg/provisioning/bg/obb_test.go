@@ -0,0 +1,205 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+const testOBBBPMPath = "testdata/bpm_obb.bin"
+
+func TestOBBRoundTrip(t *testing.T) {
+	data, err := ioutil.ReadFile(testOBBBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	expected := sha256.Sum256(bytes.Repeat([]byte{0xff}, 0x10000))
+	obb := bpm.SE[0].OBBHash
+	if obb.HashAlg != manifest.AlgSHA256 || !bytes.Equal(obb.HashBuffer, expected[:]) {
+		t.Fatalf("OBB hash is %s %x, expected %s %x", obb.HashAlg, obb.HashBuffer, manifest.AlgSHA256, expected)
+	}
+	if err := ACMGenerationCBnT.ValidateBPM(bpm); err != nil {
+		t.Errorf("ValidateBPM() failed: %v", err)
+	}
+	written, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(written, data) {
+		t.Errorf("WriteBPM() of the parsed BPM differs from %s", testOBBBPMPath)
+	}
+
+	var out bytes.Buffer
+	PrintMeasurements(&out, bpm)
+	golden, err := ioutil.ReadFile("testdata/measurements_obb.txt")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if out.String() != string(golden) {
+		t.Errorf("PrintMeasurements() doesn't match testdata/measurements_obb.txt:\n%s", out.String())
+	}
+}
+
+func TestValidateBPMCBnT(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		modify func(bpm *bootpolicy.Manifest)
+		valid  bool
+	}{
+		"no-obb": {func(bpm *bootpolicy.Manifest) {}, true},
+		"no-ibbs": {func(bpm *bootpolicy.Manifest) {
+			bpm.SE = nil
+		}, false},
+		"no-ibb-digests": {func(bpm *bootpolicy.Manifest) {
+			bpm.SE[0].DigestList.List = nil
+		}, false},
+		"no-ibb-segments": {func(bpm *bootpolicy.Manifest) {
+			bpm.SE[0].IBBSegments = nil
+		}, false},
+		"short-obb-digest": {func(bpm *bootpolicy.Manifest) {
+			bpm.SE[0].OBBHash.HashAlg = manifest.AlgSHA256
+			bpm.SE[0].OBBHash.HashBuffer = make([]byte, 20)
+		}, false},
+		"obb-digest-without-alg": {func(bpm *bootpolicy.Manifest) {
+			bpm.SE[0].OBBHash.HashBuffer = make([]byte, 32)
+		}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bpm, err := ParseBPM(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ParseBPM() failed: %v", err)
+			}
+			tc.modify(bpm)
+			if err := ACMGenerationCBnT.ValidateBPM(bpm); (err == nil) != tc.valid {
+				t.Errorf("ValidateBPM() returned %v, expected valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestOBBRegionValidate(t *testing.T) {
+	se := bootpolicy.NewSE()
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(0xffff0000, 0x10000, 0)}
+	for name, tc := range map[string]struct {
+		obb   OBBRegion
+		valid bool
+	}{
+		"below-ibb":      {OBBRegion{Base: 0xfff00000, Size: 0xf0000}, true},
+		"empty":          {OBBRegion{Base: 0xfff00000}, false},
+		"overlaps-ibb":   {OBBRegion{Base: 0xfff00000, Size: 0xf8000}, false},
+		"exceeds-4gib":   {OBBRegion{Base: 0xfffff000, Size: 0x2000}, false},
+		"reserved-flags": {OBBRegion{Base: 0xfff00000, Size: 0x1000, Flags: 0x2}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.obb.Validate(se); (err == nil) != tc.valid {
+				t.Errorf("Validate() returned %v, expected valid %v", err, tc.valid)
+			}
+			if tc.valid {
+				return
+			}
+			if err := MeasureOBB(se, OBBRegions{tc.obb}, nil); err == nil {
+				t.Errorf("MeasureOBB() accepted an invalid OBB")
+			}
+			if !se.OBBHash.HashAlg.IsNull() {
+				t.Errorf("MeasureOBB() modified the OBB hash of an invalid OBB")
+			}
+		})
+	}
+}
+
+func TestOBBRegionsValidate(t *testing.T) {
+	se := bootpolicy.NewSE()
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(0xffff0000, 0x10000, 0)}
+	for name, tc := range map[string]struct {
+		regions OBBRegions
+		alg     manifest.Algorithm
+		valid   bool
+	}{
+		"default-alg": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000},
+			{Base: 0xfff20000, Size: 0x10000},
+		}, manifest.AlgSHA256, true},
+		"region-alg": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000},
+			{Base: 0xfff20000, Size: 0x10000, Algorithm: manifest.AlgSHA384},
+		}, manifest.AlgSHA384, true},
+		"not-measured-region": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000},
+			{Base: 0xfff20000, Size: 0x10000, Flags: bootpolicy.IBBSegmentFlagNotMeasured},
+		}, manifest.AlgSHA256, true},
+		"mixed-algs": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000, Algorithm: manifest.AlgSHA256},
+			{Base: 0xfff20000, Size: 0x10000, Algorithm: manifest.AlgSHA384},
+		}, 0, false},
+		"unknown-alg": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000, Algorithm: manifest.AlgRSA},
+		}, 0, false},
+		"overlapping-regions": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x20000},
+			{Base: 0xfff10000, Size: 0x10000},
+		}, 0, false},
+		"overlaps-ibb": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000},
+			{Base: 0xffff8000, Size: 0x1000},
+		}, 0, false},
+		"nothing-measured": {OBBRegions{
+			{Base: 0xfff00000, Size: 0x10000, Flags: bootpolicy.IBBSegmentFlagNotMeasured},
+		}, 0, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.regions.Validate(se)
+			if (err == nil) != tc.valid {
+				t.Fatalf("Validate() returned %v, expected valid %v", err, tc.valid)
+			}
+			if !tc.valid {
+				return
+			}
+			if alg, err := tc.regions.algorithm(se); err != nil || alg != tc.alg {
+				t.Errorf("algorithm() returned %s, %v, expected %s", alg, err, tc.alg)
+			}
+		})
+	}
+}
+
+func TestOBBRegionsConfigRoundTrip(t *testing.T) {
+	regions := OBBRegions{
+		{Base: 0xfff00000, Size: 0x10000, Algorithm: manifest.AlgSHA384},
+		{Base: 0xfff20000, Size: 0x8000, Flags: bootpolicy.IBBSegmentFlagNotMeasured},
+	}
+	data, err := json.Marshal(&BootGuardOptions{OBB: regions})
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var bgo BootGuardOptions
+	if err := json.Unmarshal(data, &bgo); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if !reflect.DeepEqual(bgo.OBB, regions) {
+		t.Errorf("the OBB regions are %+v after the round trip, expected %+v", bgo.OBB, regions)
+	}
+
+	// configs used to hold a single OBB region
+	bgo = BootGuardOptions{}
+	if err := json.Unmarshal([]byte(`{"OBB": {"base": 4293918720, "size": 65536}}`), &bgo); err != nil {
+		t.Fatalf("json.Unmarshal() of a single OBB region failed: %v", err)
+	}
+	if expected := (OBBRegions{{Base: 0xfff00000, Size: 0x10000}}); !reflect.DeepEqual(bgo.OBB, expected) {
+		t.Errorf("the single OBB region is parsed as %+v, expected %+v", bgo.OBB, expected)
+	}
+	if err := json.Unmarshal([]byte(`{"OBB": [{"base": 4293918720, "size": 65536, "algoritm": 12}]}`), &bgo); err == nil {
+		t.Errorf("json.Unmarshal() accepted an OBB region with an unknown key")
+	}
+}
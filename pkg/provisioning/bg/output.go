@@ -0,0 +1,145 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// OutputFormatter renders the structured result of a command, e.g. a
+// CheckResults, Comparison or FITReport.
+type OutputFormatter interface {
+	Format(w io.Writer, result interface{}) error
+}
+
+// PrettyPrinter is implemented by results with a human readable form.
+type PrettyPrinter interface {
+	PrettyPrint(w io.Writer) error
+}
+
+// JSONWriter is implemented by results with their own JSON encoding.
+type JSONWriter interface {
+	WriteJSON(w io.Writer) error
+}
+
+// OutputFormats are the names NewOutputFormatter accepts.
+var OutputFormats = []string{"text", "json", "yaml"}
+
+// NewOutputFormatter returns the formatter of the given name, see
+// OutputFormats.
+func NewOutputFormatter(name string) (OutputFormatter, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return TextFormatter{}, nil
+	case "json":
+		return JSONFormatter{}, nil
+	case "yaml", "yml":
+		return YAMLFormatter{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format '%s', supported: %s", name, strings.Join(OutputFormats, ", "))
+}
+
+// TextFormatter renders results in human readable form with their
+// PrettyPrint method. Results without one are rendered as YAML, which reads
+// well enough.
+type TextFormatter struct{}
+
+// Format implements OutputFormatter.
+func (TextFormatter) Format(w io.Writer, result interface{}) error {
+	if p, ok := result.(PrettyPrinter); ok {
+		return p.PrettyPrint(w)
+	}
+	return YAMLFormatter{}.Format(w, result)
+}
+
+// JSONFormatter renders results as indented JSON, the --json output of the
+// commands.
+type JSONFormatter struct{}
+
+// Format implements OutputFormatter.
+func (JSONFormatter) Format(w io.Writer, result interface{}) error {
+	if j, ok := result.(JSONWriter); ok {
+		return j.WriteJSON(w)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// YAMLFormatter renders results as YAML. The keys, their order and the
+// values are those of the JSON output.
+type YAMLFormatter struct{}
+
+// Format implements OutputFormatter.
+func (YAMLFormatter) Format(w io.Writer, result interface{}) error {
+	var buf bytes.Buffer
+	if err := (JSONFormatter{}).Format(&buf, result); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// decodeOrdered decodes the next JSON value into a yaml.MapSlice, which keeps
+// the order of the keys, a []interface{} or a scalar.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			obj := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err = dec.Token()
+			return obj, err
+		case '[':
+			list := []interface{}{}
+			for dec.More() {
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err = dec.Token()
+			return list, err
+		}
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(tok.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return tok.Float64()
+	}
+	return tok, nil
+}
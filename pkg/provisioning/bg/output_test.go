@@ -0,0 +1,155 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func testOutputResults() *CheckResults {
+	return NewCheckResults(
+		CheckResult{Check: "pcr0", Pass: true, Expected: "ab12", Actual: "ab12"},
+		CheckResult{Check: "km-signature", Detail: "invalid: wrong key"},
+	)
+}
+
+func formatOutput(t *testing.T, name string, result interface{}) string {
+	f, err := NewOutputFormatter(name)
+	if err != nil {
+		t.Fatalf("NewOutputFormatter(%q) failed: %v", name, err)
+	}
+	var out bytes.Buffer
+	if err := f.Format(&out, result); err != nil {
+		t.Fatalf("Format() of the %s formatter failed: %v", name, err)
+	}
+	return out.String()
+}
+
+// yamlAsJSON reads the YAML document back and returns it as JSON with sorted
+// keys, so it can be compared with the JSON output.
+func yamlAsJSON(t *testing.T, doc string) string {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("the YAML output doesn't parse: %v\n%s", err, doc)
+	}
+	data, err := json.Marshal(stringKeys(v))
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	return string(data)
+}
+
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, value := range v {
+			m[key.(string)] = stringKeys(value)
+		}
+		return m
+	case []interface{}:
+		for idx := range v {
+			v[idx] = stringKeys(v[idx])
+		}
+	}
+	return v
+}
+
+// yamlKeys returns the top-level keys of the YAML document in order.
+func yamlKeys(t *testing.T, doc string) []string {
+	var m yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(doc), &m); err != nil {
+		t.Fatalf("the YAML output is no mapping: %v\n%s", err, doc)
+	}
+	var keys []string
+	for _, item := range m {
+		keys = append(keys, item.Key.(string))
+	}
+	return keys
+}
+
+// canonicalJSON returns the JSON document with sorted keys.
+func canonicalJSON(t *testing.T, doc string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("the JSON output doesn't parse: %v", err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestOutputFormatters(t *testing.T) {
+	results := testOutputResults()
+
+	var expectedJSON bytes.Buffer
+	if err := results.WriteJSON(&expectedJSON); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	if out := formatOutput(t, "text", results); out != "OK     pcr0\nFAIL   km-signature: invalid: wrong key\n" {
+		t.Errorf("the text formatter rendered\n%s", out)
+	}
+	if out := formatOutput(t, "json", results); out != expectedJSON.String() {
+		t.Errorf("the json formatter rendered\n%s\nexpected\n%s", out, expectedJSON.String())
+	}
+	out := formatOutput(t, "yaml", results)
+	if yamlAsJSON(t, out) != canonicalJSON(t, expectedJSON.String()) {
+		t.Errorf("the yaml formatter rendered\n%s\nexpected the values of\n%s", out, expectedJSON.String())
+	}
+	if keys := yamlKeys(t, out); len(keys) != 2 || keys[0] != "pass" || keys[1] != "checks" {
+		t.Errorf("the yaml formatter rendered the keys %v, expected those of the JSON output in order", keys)
+	}
+
+	// failed checks without detail show what was expected
+	stale := NewCheckResults(CheckResult{Check: "km-hash", Expected: "ab12", Actual: "cd34"})
+	if out := formatOutput(t, "text", stale); out != "FAIL   km-hash: expected ab12, got cd34\n" {
+		t.Errorf("the text formatter rendered\n%s", out)
+	}
+
+	if _, err := NewOutputFormatter("xml"); err == nil {
+		t.Errorf("NewOutputFormatter() accepted an unknown format")
+	}
+}
+
+func TestYAMLFormatterValues(t *testing.T) {
+	result := struct {
+		Name   string     `json:"name"`
+		Size   int        `json:"size"`
+		Big    uint64     `json:"big"`
+		Offset string     `json:"offset"`
+		Flag   string     `json:"flag"`
+		Empty  []int      `json:"empty"`
+		Nested [][]string `json:"nested"`
+		Unset  *int       `json:"unset"`
+	}{"BPM", 973, 0xffffffffffffffff, "0x10", "true", []int{}, [][]string{{"a", "- b"}}, nil}
+	out := formatOutput(t, "yaml", result)
+	// strings which look like numbers or booleans stay strings
+	var parsed struct {
+		Name   string
+		Size   int
+		Big    uint64
+		Offset string
+		Flag   string
+		Empty  []int
+		Nested [][]string
+		Unset  *int
+	}
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("the YAML output doesn't parse: %v\n%s", err, out)
+	}
+	if parsed.Name != "BPM" || parsed.Size != 973 || parsed.Big != result.Big || parsed.Offset != "0x10" || parsed.Flag != "true" ||
+		len(parsed.Empty) != 0 || len(parsed.Nested) != 1 || parsed.Nested[0][1] != "- b" || parsed.Unset != nil {
+		t.Errorf("the yaml formatter rendered\n%s\nwhich reads back as %+v", out, parsed)
+	}
+	if keys := yamlKeys(t, out); len(keys) != 8 || keys[0] != "name" || keys[7] != "unset" {
+		t.Errorf("the yaml formatter rendered the keys %v, expected those of the JSON output in order", keys)
+	}
+	// results without PrettyPrint are rendered as YAML in text form
+	if text := formatOutput(t, "text", result); text != out {
+		t.Errorf("the text formatter rendered\n%s\nexpected the YAML form", text)
+	}
+}
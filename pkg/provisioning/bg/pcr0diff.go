@@ -0,0 +1,171 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// PCRBanks are the hash algorithms of the PCR banks PCR0 can be predicted for.
+var PCRBanks = []manifest.Algorithm{manifest.AlgSHA1, manifest.AlgSHA256, manifest.AlgSHA384, manifest.AlgSM3_256}
+
+// ErrNoIBBDigest is returned if the BPM has no IBB digest of the hash
+// algorithm of a PCR bank, so PCR0 of the bank can't be predicted.
+var ErrNoIBBDigest = errors.New("the BPM has no IBB digest for the PCR bank")
+
+// ParsePCRBank returns the hash algorithm of the PCR bank of the given name,
+// e.g. sha256.
+func ParsePCRBank(name string) (manifest.Algorithm, error) {
+	var names []string
+	for _, alg := range PCRBanks {
+		if strings.EqualFold(alg.String(), name) {
+			return alg, nil
+		}
+		names = append(names, strings.ToLower(alg.String()))
+	}
+	return manifest.AlgUnknown, fmt.Errorf("unknown PCR bank %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// PredictPCR0 returns the data the S-ACM measures into PCR0 of the bank of
+// hash algorithm bankAlg for a firmware image, and the value of PCR0 after
+// the measurement is extended into the reset PCR. status is the value of the
+// ACM policy status register, which is the same for all images of a
+// platform.
+func PredictPCR0(image []byte, status uint64, bankAlg manifest.Algorithm) (*Pcr0Data, []byte, error) {
+	return DefaultParseOptions().PredictPCR0(image, status, bankAlg)
+}
+
+// PredictPCR0 is PredictPCR0 with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) PredictPCR0(image []byte, status uint64, bankAlg manifest.Algorithm) (*Pcr0Data, []byte, error) {
+	bpmBuf, kmBuf, acmBuf, err := o.ParseFITEntries(image)
+	if err != nil {
+		return nil, nil, err
+	}
+	km, err := o.ParseKM(bytes.NewReader(kmBuf))
+	if err != nil {
+		return nil, nil, err
+	}
+	bpm, err := o.ParseBPM(bytes.NewReader(bpmBuf))
+	if err != nil {
+		return nil, nil, err
+	}
+	acm, _, _, _, err, err2 := tools.ParseACM(acmBuf)
+	if err == nil {
+		err = err2
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse the ACM: %w", err)
+	}
+	data, err := NewPcr0Data(status, km, bpm, acm, bankAlg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data.BPMIBBDigest) == 0 {
+		return nil, nil, fmt.Errorf("%w: no %s IBB digest to measure into the %s bank", ErrNoIBBDigest, bankAlg, bankAlg)
+	}
+	pcr0, err := extendResetPCR(bankAlg, data.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, pcr0, nil
+}
+
+// PCR0Change is a measurement differing between the PCR0 data of two images.
+type PCR0Change struct {
+	// Component is the structure the measurement comes from: ACM, KM, BPM
+	// or IBB.
+	Component   string `json:"component"`
+	Measurement string `json:"measurement"`
+	A           string `json:"a"`
+	B           string `json:"b"`
+}
+
+// PCR0Diff is the comparison of the PCR0 values predicted for two images.
+type PCR0Diff struct {
+	Bank    AlgorithmField `json:"bank"`
+	PCR0A   string         `json:"pcr0_a"`
+	PCR0B   string         `json:"pcr0_b"`
+	Changes []PCR0Change   `json:"changes"`
+}
+
+// Differ returns true if the predicted PCR0 values differ.
+func (d *PCR0Diff) Differ() bool {
+	return d.PCR0A != d.PCR0B
+}
+
+// ComparePCR0 predicts PCR0 of the bank of hash algorithm bankAlg for the
+// firmware images a and b and reports the measurements which differ.
+func ComparePCR0(a, b []byte, status uint64, bankAlg manifest.Algorithm) (*PCR0Diff, error) {
+	return DefaultParseOptions().ComparePCR0(a, b, status, bankAlg)
+}
+
+// ComparePCR0 is ComparePCR0 with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) ComparePCR0(a, b []byte, status uint64, bankAlg manifest.Algorithm) (*PCR0Diff, error) {
+	dataA, pcr0A, err := o.PredictPCR0(a, status, bankAlg)
+	if err != nil {
+		return nil, fmt.Errorf("image A: %w", err)
+	}
+	dataB, pcr0B, err := o.PredictPCR0(b, status, bankAlg)
+	if err != nil {
+		return nil, fmt.Errorf("image B: %w", err)
+	}
+	d := &PCR0Diff{
+		Bank:    NewAlgorithmField(bankAlg),
+		PCR0A:   fmt.Sprintf("%x", pcr0A),
+		PCR0B:   fmt.Sprintf("%x", pcr0B),
+		Changes: []PCR0Change{},
+	}
+	for _, m := range []struct {
+		component, measurement string
+		a, b                   interface{}
+	}{
+		{"ACM", "ACM policy status", dataA.ACMPolicyStatus, dataB.ACMPolicyStatus},
+		{"ACM", "ACM SVN", dataA.ACMSVN, dataB.ACMSVN},
+		{"ACM", "ACM signature", dataA.ACMSignature, dataB.ACMSignature},
+		{"KM", "KM signature", dataA.KMSignature, dataB.KMSignature},
+		{"BPM", "BPM signature", dataA.BPMSignature, dataB.BPMSignature},
+		{"IBB", "IBB digest", dataA.BPMIBBDigest, dataB.BPMIBBDigest},
+	} {
+		valueA, valueB := fmt.Sprintf("%x", m.a), fmt.Sprintf("%x", m.b)
+		if valueA != valueB {
+			d.Changes = append(d.Changes, PCR0Change{Component: m.component, Measurement: m.measurement, A: valueA, B: valueB})
+		}
+	}
+	return d, nil
+}
+
+// WriteJSON writes the comparison as indented JSON.
+func (d *PCR0Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// PrettyPrint writes the predicted values followed by the measurements which
+// differ.
+func (d *PCR0Diff) PrettyPrint(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "PCR0 A (%s):\t%s\n", d.Bank, d.PCR0A)
+	fmt.Fprintf(tw, "PCR0 B (%s):\t%s\n", d.Bank, d.PCR0B)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if !d.Differ() {
+		fmt.Fprintln(w, "PCR0 is the same for both images")
+		return nil
+	}
+	fmt.Fprintln(w, "PCR0 differs, changed measurements:")
+	for _, c := range d.Changes {
+		fmt.Fprintf(w, "  %s: %s\n    A: %s\n    B: %s\n", c.Component, c.Measurement, c.A, c.B)
+	}
+	return nil
+}
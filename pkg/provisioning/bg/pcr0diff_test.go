@@ -0,0 +1,127 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/coreboot/cbfs"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+const testCorebootPath = "testdata/coreboot.bin"
+
+// testPCR0Image returns the coreboot fixture, whose BPM holds SHA1 and SHA256
+// IBB digests, and the offset of the given IBB digest in it.
+func testPCR0Image(t *testing.T, alg manifest.Algorithm) ([]byte, int) {
+	image, err := ioutil.ReadFile(testCorebootPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpmData, _, _, err := ParseFITEntries(image)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() failed: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	for _, d := range bpm.SE[0].DigestList.List {
+		if d.HashAlg == alg {
+			return image, bytes.Index(image, d.HashBuffer)
+		}
+	}
+	t.Fatalf("the fixture BPM has no %s IBB digest", alg)
+	return nil, 0
+}
+
+func TestPredictPCR0(t *testing.T) {
+	image, _ := testPCR0Image(t, manifest.AlgSHA1)
+	data, pcr0, err := PredictPCR0(image, 0x1234, manifest.AlgSHA1)
+	if err != nil {
+		t.Fatalf("PredictPCR0() failed: %v", err)
+	}
+
+	// the SHA1 bank matches the measurement live-verify expects
+	bpmBuf, kmBuf, acmBuf, _ := ParseFITEntries(image)
+	km, _ := ParseKM(bytes.NewReader(kmBuf))
+	bpm, _ := ParseBPM(bytes.NewReader(bpmBuf))
+	acm, _, _, _, _, _ := tools.ParseACM(acmBuf)
+	_, measurement, err := generatePCR0Content(0x1234, km, bpm, acm)
+	if err != nil {
+		t.Fatalf("generatePCR0Content() failed: %v", err)
+	}
+	expected := sha1.Sum(append(make([]byte, sha1.Size), measurement...))
+	if !bytes.Equal(pcr0, expected[:]) {
+		t.Errorf("PredictPCR0() returned %x, expected %x", pcr0, expected)
+	}
+	if data.ACMPolicyStatus != 0x1234 || len(data.BPMIBBDigest) != sha1.Size {
+		t.Errorf("PredictPCR0() returned the PCR0 data %+v", data)
+	}
+
+	if _, pcr0SHA256, err := PredictPCR0(image, 0x1234, manifest.AlgSHA256); err != nil || len(pcr0SHA256) != 32 {
+		t.Errorf("PredictPCR0() of the SHA256 bank returned %x, %v", pcr0SHA256, err)
+	}
+	if _, _, err := PredictPCR0(image, 0, manifest.AlgSHA512); err == nil {
+		t.Errorf("PredictPCR0() accepted a bank without IBB digest")
+	}
+}
+
+func TestComparePCR0(t *testing.T) {
+	a, sha256Offset := testPCR0Image(t, manifest.AlgSHA256)
+	diff, err := ComparePCR0(a, a, 0, manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("ComparePCR0() failed: %v", err)
+	}
+	if diff.Differ() || len(diff.Changes) != 0 {
+		t.Errorf("ComparePCR0() of the same image reported %+v", diff)
+	}
+
+	// a changed SHA256 IBB digest only changes the SHA256 bank
+	b := append([]byte{}, a...)
+	b[sha256Offset] ^= 0xff
+	diff, err = ComparePCR0(a, b, 0, manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("ComparePCR0() failed: %v", err)
+	}
+	if !diff.Differ() || len(diff.Changes) != 1 || diff.Changes[0].Component != "IBB" {
+		t.Errorf("ComparePCR0() of a changed IBB returned %+v, expected an IBB change", diff)
+	}
+	if diff, err := ComparePCR0(a, b, 0, manifest.AlgSHA1); err != nil || diff.Differ() {
+		t.Errorf("ComparePCR0() of the SHA1 bank returned %+v, %v, expected no change", diff, err)
+	}
+
+	// a new ACM SVN changes every bank
+	f, err := cbfs.Lookup(a, CBFSACMName)
+	if err != nil {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	header, err := tools.ParseACMHeader(f.Data)
+	if err != nil {
+		t.Fatalf("ParseACMHeader() failed: %v", err)
+	}
+	header.TxtSVN++
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header)
+	b = append([]byte{}, a...)
+	copy(b[f.Offset:], buf.Bytes())
+	diff, err = ComparePCR0(a, b, 0, manifest.AlgSHA1)
+	if err != nil {
+		t.Fatalf("ComparePCR0() failed: %v", err)
+	}
+	if !diff.Differ() || len(diff.Changes) != 1 || diff.Changes[0].Measurement != "ACM SVN" {
+		t.Errorf("ComparePCR0() of a new ACM SVN returned %+v, expected an ACM SVN change", diff)
+	}
+}
+
+func TestParsePCRBank(t *testing.T) {
+	if alg, err := ParsePCRBank("sha256"); err != nil || alg != manifest.AlgSHA256 {
+		t.Errorf("ParsePCRBank(sha256) returned %s, %v", alg, err)
+	}
+	if _, err := ParsePCRBank("md5"); err == nil {
+		t.Errorf("ParsePCRBank() accepted md5")
+	}
+}
@@ -0,0 +1,138 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// ErrNoAuthorityMeasurement is returned by PredictPCR7 if no IBB element of
+// the BPM requests authority measurements. The S-ACM doesn't extend PCR7 then.
+var ErrNoAuthorityMeasurement = errors.New("the BPM doesn't request authority measurements into PCR7")
+
+// NewPcr7Data returns the authority measurement the S-ACM hashes into PCR7 of
+// the bank of hash algorithm bankAlg: the ACM policy status, the ACM SVN, the
+// SHA256 hash of the ACM signing key, the OEM key hash fused into the FPFs,
+// which is of the KM's PubKeyHashAlg, and the hash of the BPM signing key of
+// bankAlg.
+func NewPcr7Data(status uint64, km *key.Manifest, bpm *bootpolicy.Manifest, acm []byte, bankAlg manifest.Algorithm) (*Pcr7Data, error) {
+	header, err := tools.ParseACMHeader(acm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the ACM: %w", err)
+	}
+	acmKey, err := tools.ACMPublicKey(acm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the ACM key: %w", err)
+	}
+	pcr7 := &Pcr7Data{
+		ACMPolicyStatus: status,
+		ACMSVN:          header.TxtSVN,
+	}
+	copy(pcr7.ACMKeyHash[:], tools.ACMPublicKeyHash(acmKey))
+	if pcr7.BPMKey, err = km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg); err != nil {
+		return nil, fmt.Errorf("unable to hash the KM signing key: %w", err)
+	}
+	if pcr7.BPMKeyHash, err = bpm.PMSE.Key.BPMPubKeyHash(bankAlg); err != nil {
+		return nil, fmt.Errorf("unable to hash the BPM signing key: %w", err)
+	}
+	return pcr7, nil
+}
+
+// Bytes returns the serialized data hashed into PCR7.
+func (d *Pcr7Data) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	// writes to a bytes.Buffer of fixed size data don't fail
+	_ = binary.Write(buf, binary.BigEndian, d.ACMPolicyStatus)
+	_ = binary.Write(buf, binary.LittleEndian, d.ACMSVN)
+	buf.Write(d.ACMKeyHash[:])
+	buf.Write(d.BPMKey)
+	buf.Write(d.BPMKeyHash)
+	return buf.Bytes()
+}
+
+// PCR7Prediction is the predicted BootGuard measurement into PCR7 of a bank.
+type PCR7Prediction struct {
+	Bank        string `json:"bank"`
+	Measurement string `json:"measurement"`
+	PCR7        string `json:"pcr7"`
+}
+
+// NewPCR7Prediction returns the result of PredictPCR7 for the bank of hash
+// algorithm bankAlg.
+func NewPCR7Prediction(bankAlg manifest.Algorithm, data *Pcr7Data, pcr7 []byte) *PCR7Prediction {
+	return &PCR7Prediction{
+		Bank:        bankAlg.String(),
+		Measurement: fmt.Sprintf("0x%x", data.Bytes()),
+		PCR7:        fmt.Sprintf("0x%x", pcr7),
+	}
+}
+
+// PrettyPrint writes the measurement and the PCR7 value.
+func (p *PCR7Prediction) PrettyPrint(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "PCR7 BootGuard measurement: %s\nExpected PCR7 after the S-ACM: %s\n", p.Measurement, p.PCR7)
+	return err
+}
+
+// PredictPCR7 returns the authority measurement the S-ACM extends into PCR7
+// of the bank of hash algorithm bankAlg for a firmware image, and the value
+// of PCR7 after the measurement is extended into the reset PCR. It only
+// covers the BootGuard measurement, the Secure Boot variables and
+// authorities the firmware extends into PCR7 afterwards are not predicted.
+func PredictPCR7(image []byte, status uint64, bankAlg manifest.Algorithm) (*Pcr7Data, []byte, error) {
+	return DefaultParseOptions().PredictPCR7(image, status, bankAlg)
+}
+
+// PredictPCR7 is PredictPCR7 with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) PredictPCR7(image []byte, status uint64, bankAlg manifest.Algorithm) (*Pcr7Data, []byte, error) {
+	bpmBuf, kmBuf, acmBuf, err := o.ParseFITEntries(image)
+	if err != nil {
+		return nil, nil, err
+	}
+	km, err := o.ParseKM(bytes.NewReader(kmBuf))
+	if err != nil {
+		return nil, nil, err
+	}
+	bpm, err := o.ParseBPM(bytes.NewReader(bpmBuf))
+	if err != nil {
+		return nil, nil, err
+	}
+	authorityMeasure := false
+	for _, se := range bpm.SE {
+		authorityMeasure = authorityMeasure || se.Flags.AuthorityMeasure()
+	}
+	if !authorityMeasure {
+		return nil, nil, ErrNoAuthorityMeasurement
+	}
+	data, err := NewPcr7Data(status, km, bpm, acmBuf, bankAlg)
+	if err != nil {
+		return nil, nil, err
+	}
+	pcr7, err := extendResetPCR(bankAlg, data.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, pcr7, nil
+}
+
+// extendResetPCR returns the value of a reset PCR of the bank of hash
+// algorithm bankAlg after the hash of data is extended into it.
+func extendResetPCR(bankAlg manifest.Algorithm, data []byte) ([]byte, error) {
+	h, err := bankAlg.Hash()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	measurement := h.Sum(nil)
+	h.Reset()
+	h.Write(make([]byte, h.Size()))
+	h.Write(measurement)
+	return h.Sum(nil), nil
+}
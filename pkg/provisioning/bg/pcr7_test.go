@@ -0,0 +1,64 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+func TestPredictPCR7(t *testing.T) {
+	// Expected PCR7 values of testdata/coreboot.bin for policy status 0x1234,
+	// computed independently of this package from the raw fixture bytes:
+	// the big endian status, the little endian ACM SVN (1), the SHA256 of
+	// the big endian ACM modulus, the SHA256 of the KM key data as fused and
+	// the bank hash of the BPM key modulus, hashed and extended into a reset
+	// PCR.
+	expected := map[manifest.Algorithm]string{
+		manifest.AlgSHA1:   "0cb4e78cc6538114412dbd5c113979e67212ca48",
+		manifest.AlgSHA256: "3e4d8efd2225beeb80f80e931475c9ccefbe329ae0e85a5c4f374a632d998486",
+	}
+	// the IBB element of the fixture BPM requests authority measurements
+	image, _ := testPCR0Image(t, manifest.AlgSHA1)
+	for alg, pcr7 := range expected {
+		data, predicted, err := PredictPCR7(image, 0x1234, alg)
+		if err != nil {
+			t.Fatalf("PredictPCR7() of the %v bank failed: %v", alg, err)
+		}
+		if hex.EncodeToString(predicted) != pcr7 {
+			t.Errorf("PredictPCR7() of the %v bank returned %x, expected %s", alg, predicted, pcr7)
+		}
+		if p := NewPCR7Prediction(alg, data, predicted); p.PCR7 != "0x"+pcr7 || p.Bank != alg.String() {
+			t.Errorf("NewPCR7Prediction() returned %+v", p)
+		}
+	}
+
+	// the OEM key hash follows the KM's PubKeyHashAlg, which the KM key
+	// doesn't support with anything but SHA256
+	bpmBuf, kmBuf, acmBuf, err := ParseFITEntries(image)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() failed: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmBuf))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmBuf))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	km.PubKeyHashAlg = manifest.AlgSHA384
+	if _, err := NewPcr7Data(0x1234, km, bpm, acmBuf, manifest.AlgSHA1); err == nil {
+		t.Errorf("NewPcr7Data() of a SHA384 KM succeeded, expected the KM's hash algorithm to be used")
+	}
+
+	// without authority measurements the S-ACM leaves PCR7 alone
+	flags := bytes.Index(image, []byte("__IBBS__")) + 16
+	noAuthority := append([]byte{}, image...)
+	noAuthority[flags] &^= 0x04
+	if _, _, err := PredictPCR7(noAuthority, 0, manifest.AlgSHA1); !errors.Is(err, ErrNoAuthorityMeasurement) {
+		t.Errorf("PredictPCR7() without authority measurements returned %v, expected ErrNoAuthorityMeasurement", err)
+	}
+}
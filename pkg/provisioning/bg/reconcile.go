@@ -0,0 +1,61 @@
+package bg
+
+import (
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// signatureSchemes are the signature schemes each key algorithm can be used
+// with.
+var signatureSchemes = map[manifest.Algorithm][]manifest.Algorithm{
+	manifest.AlgRSA: {manifest.AlgRSASSA, manifest.AlgRSAPSS},
+	manifest.AlgECC: {manifest.AlgECDSA},
+	manifest.AlgSM2: {manifest.AlgSM2},
+}
+
+// ReconcileManifests checks that a KM and the BPM it should authorize are
+// consistent: the KM holds the hash of the BPM signing key, the SVNs have no
+// reserved bits set and the declared algorithms agree with each other. It
+// doesn't verify the signatures.
+func ReconcileManifests(km *key.Manifest, bpm *bootpolicy.Manifest) *CheckResults {
+	r := NewCheckResults(NewCheckResult("km-bpm-key", checkKMReferencesBPMKey(km, bpm), "KM holds the BPM signing key hash"))
+
+	for _, svn := range []struct {
+		check string
+		value manifest.SVN
+	}{
+		{"km-svn", km.KMSVN},
+		{"bpm-svn", bpm.BPMH.BPMSVN},
+		{"bpm-acm-svn", bpm.BPMH.ACMSVNAuth},
+	} {
+		var err error
+		if uint8(svn.value) != svn.value.SVN() {
+			err = fmt.Errorf("SVN field 0x%02x has reserved bits set", uint8(svn.value))
+		}
+		r.Add(NewCheckResult(svn.check, err, fmt.Sprintf("%d", svn.value.SVN())))
+	}
+
+	// ValidateKMHashAlgs fills in an unset PubKeyHashAlg
+	kmCopy := *km
+	r.Add(NewCheckResult("km-hash-algs", ValidateKMHashAlgs(&kmCopy), "digest sizes match the hash algorithms"))
+	r.Add(NewCheckResult("km-signature-scheme", checkSignatureScheme(&km.KeyAndSignature), km.KeyAndSignature.Signature.SigScheme.String()))
+	r.Add(NewCheckResult("bpm-signature-scheme", checkSignatureScheme(&bpm.PMSE.KeySignature), bpm.PMSE.KeySignature.Signature.SigScheme.String()))
+	return r
+}
+
+// checkSignatureScheme checks that the signature scheme can be used with the
+// algorithm of the key.
+func checkSignatureScheme(ks *manifest.KeySignature) error {
+	scheme, keyAlg := ks.Signature.SigScheme, ks.Key.KeyAlg
+	schemes, ok := signatureSchemes[keyAlg]
+	if !ok {
+		return fmt.Errorf("unknown key algorithm %s", keyAlg)
+	}
+	if !containsAlg(schemes, scheme) {
+		return fmt.Errorf("signature scheme %s can't be used with a %s key", scheme, keyAlg)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestReconcileManifests(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(signedTestKM(t)))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpmKeyHash, err := bpm.PMSE.KeySignature.Key.BPMPubKeyHash(manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("BPMPubKeyHash() failed: %v", err)
+	}
+	km.Hash = []key.Hash{{
+		Usage:  key.UsageBPMSigningPKD,
+		Digest: manifest.HashStructure{HashAlg: manifest.AlgSHA256, HashBuffer: bpmKeyHash},
+	}}
+
+	if r := ReconcileManifests(km, bpm); !r.Pass {
+		t.Fatalf("ReconcileManifests() failed with a matching pair: %+v", r.Failed())
+	}
+	// the OEM key hash algorithm of the fuses is independent of the KM signature hash
+	sha384 := *km
+	sha384.PubKeyHashAlg = manifest.AlgSHA384
+	if r := ReconcileManifests(&sha384, bpm); !r.Pass {
+		t.Errorf("ReconcileManifests() failed with a SHA384 OEM key hash: %+v", r.Failed())
+	}
+
+	bpm.BPMH.BPMSVN = 0x12
+	km.KeyAndSignature.Signature.SigScheme = manifest.AlgECDSA
+	r := ReconcileManifests(km, bpm)
+	failed := r.Failed()
+	if len(failed) != 2 || failed[0].Check != "bpm-svn" || failed[1].Check != "km-signature-scheme" {
+		t.Errorf("ReconcileManifests() with reserved SVN bits and an ECDSA signed RSA key failed %+v", failed)
+	}
+	if km.PubKeyHashAlg.IsNull() {
+		t.Errorf("ReconcileManifests() modified the KM")
+	}
+
+	km.Hash[0].Digest.HashBuffer = make([]byte, len(bpmKeyHash))
+	if r := ReconcileManifests(km, bpm); r.Checks[0].Pass {
+		t.Errorf("ReconcileManifests() passed km-bpm-key with a KM of another BPM key")
+	}
+}
@@ -0,0 +1,43 @@
+package bg
+
+import (
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// RedactKM zeroes the signature of the KM for sharing it. The signature keeps
+// its size, so the KM still parses and all offsets stay the same. The public
+// key is kept. It returns the names of the zeroed fields.
+func RedactKM(km *key.Manifest) []string {
+	var redacted []string
+	if zeroSignature(&km.KeyAndSignature) {
+		redacted = append(redacted, "KM signature")
+	}
+	return redacted
+}
+
+// RedactBPM zeroes the signature of the BPM and, if clearPM is set, the data
+// of the platform manufacturer element for sharing the BPM. Like RedactKM it
+// keeps the sizes and the public key. It returns the names of the zeroed
+// fields.
+func RedactBPM(bpm *bootpolicy.Manifest, clearPM bool) []string {
+	var redacted []string
+	if zeroSignature(&bpm.PMSE.KeySignature) {
+		redacted = append(redacted, "BPM signature")
+	}
+	if clearPM && bpm.PME != nil && len(bpm.PME.Data) > 0 {
+		bpm.PME.Data = make([]byte, len(bpm.PME.Data))
+		redacted = append(redacted, "PME data")
+	}
+	return redacted
+}
+
+// zeroSignature zeroes the signature data and returns true if there was any.
+func zeroSignature(ks *manifest.KeySignature) bool {
+	if len(ks.Signature.Data) == 0 {
+		return false
+	}
+	ks.Signature.Data = make([]byte, len(ks.Signature.Data))
+	return true
+}
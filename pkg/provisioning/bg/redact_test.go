@@ -0,0 +1,65 @@
+package bg
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRedactBPM(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	if bpm.PME == nil {
+		bpm.PME, err = NewPMElement([]byte("platform serial"))
+		if err != nil {
+			t.Fatalf("NewPMElement() failed: %v", err)
+		}
+		bpm.RehashRecursive()
+	}
+	pmSize := len(bpm.PME.Data)
+	if redacted := RedactBPM(bpm, true); len(redacted) != 2 {
+		t.Errorf("RedactBPM() redacted %q, expected the signature and the PM data", redacted)
+	}
+	out, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+
+	redacted, err := ParseBPM(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("ParseBPM() of the redacted BPM failed: %v", err)
+	}
+	if _, err := VerifyBPM(out); !errors.Is(err, ErrPlaceholderSignature) {
+		t.Errorf("VerifyBPM() of the redacted BPM returned %v, expected %v", err, ErrPlaceholderSignature)
+	}
+	if redacted.PME == nil || len(redacted.PME.Data) != pmSize || !IsPlaceholderSignature(redacted.PME.Data) {
+		t.Errorf("Redacted PM element is %+v, expected %d zero bytes", redacted.PME, pmSize)
+	}
+	if len(redacted.SE) != len(bpm.SE) || redacted.KeySignatureOffset != bpm.KeySignatureOffset {
+		t.Errorf("RedactBPM() changed the structure of the BPM")
+	}
+}
+
+func TestRedactKM(t *testing.T) {
+	km, err := ParseKM(bytes.NewReader(signedTestKM(t)))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	if redacted := RedactKM(km); len(redacted) != 1 {
+		t.Errorf("RedactKM() redacted %q, expected the signature", redacted)
+	}
+	out, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if _, err := VerifyKM(out); !errors.Is(err, ErrPlaceholderSignature) {
+		t.Errorf("VerifyKM() of the redacted KM returned %v, expected %v", err, ErrPlaceholderSignature)
+	}
+}
@@ -0,0 +1,266 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// ReportFile identifies a file consumed or produced by a provisioning run.
+type ReportFile struct {
+	Role   string `json:"role"`
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ReportDigest is a digest recorded in a manifest.
+type ReportDigest struct {
+	Usage     string         `json:"usage,omitempty"`
+	Algorithm AlgorithmField `json:"algorithm"`
+	Digest    string         `json:"digest"`
+}
+
+// ReportSignature describes the signature applied to a manifest.
+type ReportSignature struct {
+	KeyAlgorithm AlgorithmField `json:"key_algorithm"`
+	KeyHash      string         `json:"key_hash,omitempty"`
+	Scheme       AlgorithmField `json:"scheme"`
+	HashAlg      AlgorithmField `json:"hash_algorithm"`
+	Valid        bool           `json:"valid"`
+	// Placeholder is set if the signature is empty or a placeholder.
+	Placeholder bool `json:"placeholder,omitempty"`
+}
+
+// KMReport holds the audit relevant fields of a Key Manifest.
+type KMReport struct {
+	Revision      uint8           `json:"revision"`
+	SVN           uint8           `json:"svn"`
+	ID            uint8           `json:"id"`
+	PubKeyHashAlg AlgorithmField  `json:"pubkey_hash_algorithm"`
+	KeyHashes     []ReportDigest  `json:"key_hashes"`
+	Signature     ReportSignature `json:"signature"`
+}
+
+// BPMReport holds the audit relevant fields of a Boot Policy Manifest.
+type BPMReport struct {
+	Revision   uint8           `json:"revision"`
+	BPMSVN     uint8           `json:"bpm_svn"`
+	ACMSVNAuth uint8           `json:"acm_svn_auth"`
+	IBBDigests []ReportDigest  `json:"ibb_digests"`
+	Signature  ReportSignature `json:"signature"`
+}
+
+// ACMReport holds the audit relevant fields of an ACM header.
+type ACMReport struct {
+	Date    string         `json:"date"`
+	TxtSVN  uint16         `json:"txt_svn"`
+	SeSVN   uint16         `json:"se_svn"`
+	Scheme  AlgorithmField `json:"scheme"`
+	HashAlg AlgorithmField `json:"hash_algorithm"`
+}
+
+// ProvisioningReport records the inputs, the applied manifests and the
+// resulting artifacts of a provisioning run, so it can be audited later.
+type ProvisioningReport struct {
+	Tool      string       `json:"tool"`
+	Version   string       `json:"version"`
+	Commit    string       `json:"commit"`
+	Timestamp time.Time    `json:"timestamp"`
+	Inputs    []ReportFile `json:"inputs"`
+	KM        *KMReport    `json:"km,omitempty"`
+	BPM       *BPMReport   `json:"bpm,omitempty"`
+	ACM       *ACMReport   `json:"acm,omitempty"`
+	Outputs   []ReportFile `json:"outputs"`
+}
+
+// NewProvisioningReport returns an empty report stamped with the tool version and the current time.
+func NewProvisioningReport(tool, version, commit string) *ProvisioningReport {
+	return &ProvisioningReport{
+		Tool:      tool,
+		Version:   version,
+		Commit:    commit,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+func newReportFile(role, path string, data []byte) ReportFile {
+	sum := sha256.Sum256(data)
+	return ReportFile{Role: role, Path: path, Size: len(data), SHA256: fmt.Sprintf("%x", sum)}
+}
+
+func newReportDigest(usage string, h manifest.HashStructure) ReportDigest {
+	return ReportDigest{Usage: usage, Algorithm: NewAlgorithmField(h.HashAlg), Digest: fmt.Sprintf("%x", h.HashBuffer)}
+}
+
+// AddInput records a file consumed by the provisioning run.
+func (r *ProvisioningReport) AddInput(role, path string, data []byte) {
+	r.Inputs = append(r.Inputs, newReportFile(role, path, data))
+}
+
+// AddOutput records a file produced by the provisioning run.
+func (r *ProvisioningReport) AddOutput(role, path string, data []byte) {
+	r.Outputs = append(r.Outputs, newReportFile(role, path, data))
+}
+
+// AddKM records the fields and the signature of a Key Manifest binary.
+func (r *ProvisioningReport) AddKM(data []byte) error {
+	km, err := ParseKM(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	report := &KMReport{
+		Revision:      km.Revision,
+		SVN:           km.KMSVN.SVN(),
+		ID:            km.KMID,
+		PubKeyHashAlg: NewAlgorithmField(km.PubKeyHashAlg),
+	}
+	for _, h := range km.Hash {
+		report.KeyHashes = append(report.KeyHashes, newReportDigest(h.Usage.String(), h.Digest))
+	}
+	ks := &km.KeyAndSignature
+	report.Signature = ReportSignature{
+		KeyAlgorithm: NewAlgorithmField(ks.Key.KeyAlg),
+		Scheme:       NewAlgorithmField(ks.Signature.SigScheme),
+		HashAlg:      NewAlgorithmField(ks.Signature.HashAlg),
+	}
+	if hash, err := ks.Key.KMPubKeyHash(manifest.AlgSHA256); err == nil {
+		report.Signature.KeyHash = fmt.Sprintf("%x", hash)
+	}
+	scheme, err := VerifyKM(data)
+	switch {
+	case err == nil:
+		report.Signature.Scheme = NewAlgorithmField(scheme)
+		report.Signature.Valid = true
+	case errors.Is(err, ErrPlaceholderSignature):
+		report.Signature.Placeholder = true
+	}
+	r.KM = report
+	return nil
+}
+
+// AddBPM records the fields and the signature of a Boot Policy Manifest binary.
+func (r *ProvisioningReport) AddBPM(data []byte) error {
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	report := &BPMReport{
+		Revision:   bpm.BPMH.BPMRevision,
+		BPMSVN:     bpm.BPMH.BPMSVN.SVN(),
+		ACMSVNAuth: bpm.BPMH.ACMSVNAuth.SVN(),
+	}
+	for _, se := range bpm.SE {
+		for _, h := range se.DigestList.List {
+			report.IBBDigests = append(report.IBBDigests, newReportDigest("IBB", h))
+		}
+	}
+	ks := &bpm.PMSE.KeySignature
+	report.Signature = ReportSignature{
+		KeyAlgorithm: NewAlgorithmField(ks.Key.KeyAlg),
+		Scheme:       NewAlgorithmField(ks.Signature.SigScheme),
+		HashAlg:      NewAlgorithmField(ks.Signature.HashAlg),
+	}
+	if hash, err := ks.Key.BPMPubKeyHash(manifest.AlgSHA256); err == nil {
+		report.Signature.KeyHash = fmt.Sprintf("%x", hash)
+	}
+	scheme, err := VerifyBPM(data)
+	switch {
+	case err == nil:
+		report.Signature.Scheme = NewAlgorithmField(scheme)
+		report.Signature.Valid = true
+	case errors.Is(err, ErrPlaceholderSignature):
+		report.Signature.Placeholder = true
+	}
+	r.BPM = report
+	return nil
+}
+
+// AddACM records the security version numbers and the signature algorithms of an ACM binary.
+func (r *ProvisioningReport) AddACM(data []byte) error {
+	header, err := tools.ParseACMHeader(data)
+	if err != nil {
+		return err
+	}
+	scheme, hashAlg := acmSignatureAlgorithms(header)
+	r.ACM = &ACMReport{
+		Date:    fmt.Sprintf("%08x", header.Date),
+		TxtSVN:  header.TxtSVN,
+		SeSVN:   header.SeSVN,
+		Scheme:  NewAlgorithmField(scheme),
+		HashAlg: NewAlgorithmField(hashAlg),
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *ProvisioningReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report in human-readable form.
+func (r *ProvisioningReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Provisioning report\n")
+	fmt.Fprintf(tw, "Tool:\t%s %s (%s)\n", r.Tool, r.Version, r.Commit)
+	fmt.Fprintf(tw, "Timestamp:\t%s\n", r.Timestamp.Format(time.RFC3339))
+	writeReportFiles(tw, "Inputs", r.Inputs)
+	if r.ACM != nil {
+		fmt.Fprintf(tw, "\nACM\n")
+		fmt.Fprintf(tw, "  Date:\t%s\n", r.ACM.Date)
+		fmt.Fprintf(tw, "  TXT SVN:\t%d\n", r.ACM.TxtSVN)
+		fmt.Fprintf(tw, "  SE SVN:\t%d\n", r.ACM.SeSVN)
+	}
+	if r.KM != nil {
+		fmt.Fprintf(tw, "\nKey Manifest\n")
+		fmt.Fprintf(tw, "  Revision:\t%d\n", r.KM.Revision)
+		fmt.Fprintf(tw, "  SVN:\t%d\n", r.KM.SVN)
+		fmt.Fprintf(tw, "  ID:\t%d\n", r.KM.ID)
+		fmt.Fprintf(tw, "  PubKeyHashAlg:\t%s\n", r.KM.PubKeyHashAlg)
+		writeReportDigests(tw, "Key hash", r.KM.KeyHashes)
+		writeReportSignature(tw, r.KM.Signature)
+	}
+	if r.BPM != nil {
+		fmt.Fprintf(tw, "\nBoot Policy Manifest\n")
+		fmt.Fprintf(tw, "  Revision:\t%d\n", r.BPM.Revision)
+		fmt.Fprintf(tw, "  BPMSVN:\t%d\n", r.BPM.BPMSVN)
+		fmt.Fprintf(tw, "  ACMSVNAuth:\t%d\n", r.BPM.ACMSVNAuth)
+		writeReportDigests(tw, "IBB digest", r.BPM.IBBDigests)
+		writeReportSignature(tw, r.BPM.Signature)
+	}
+	writeReportFiles(tw, "Outputs", r.Outputs)
+	return tw.Flush()
+}
+
+func writeReportFiles(w io.Writer, title string, files []ReportFile) {
+	fmt.Fprintf(w, "\n%s\n", title)
+	for _, f := range files {
+		fmt.Fprintf(w, "  %s:\t%s (%d bytes)\n", f.Role, f.Path, f.Size)
+		fmt.Fprintf(w, "  \tSHA256 %s\n", f.SHA256)
+	}
+}
+
+func writeReportDigests(w io.Writer, title string, digests []ReportDigest) {
+	for _, d := range digests {
+		fmt.Fprintf(w, "  %s:\t%s %s %s\n", title, d.Usage, d.Algorithm, d.Digest)
+	}
+}
+
+func writeReportSignature(w io.Writer, s ReportSignature) {
+	fmt.Fprintf(w, "  Signing key:\t%s, hash %s\n", s.KeyAlgorithm, s.KeyHash)
+	if s.Placeholder {
+		fmt.Fprintf(w, "  Signature:\tplaceholder/empty, not signed\n")
+		return
+	}
+	fmt.Fprintf(w, "  Signature:\t%s/%s, valid: %t\n", s.Scheme, s.HashAlg, s.Valid)
+}
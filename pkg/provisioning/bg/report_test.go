@@ -0,0 +1,54 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestProvisioningReport(t *testing.T) {
+	km := signedTestKM(t)
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	report := NewProvisioningReport("bg-prov", "v1.0.0", "abcdef")
+	report.AddInput("KM", "km.bin", km)
+	if err := report.AddKM(km); err != nil {
+		t.Fatalf("AddKM() failed: %v", err)
+	}
+	if err := report.AddBPM(bpm); err != nil {
+		t.Fatalf("AddBPM() failed: %v", err)
+	}
+	if !report.KM.Signature.Valid || report.KM.Signature.KeyHash == "" {
+		t.Errorf("AddKM() didn't record the valid signature: %+v", report.KM.Signature)
+	}
+	if len(report.BPM.IBBDigests) == 0 {
+		t.Errorf("AddBPM() didn't record any IBB digests")
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	var decoded ProvisioningReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if decoded.Inputs[0].SHA256 != report.Inputs[0].SHA256 || decoded.BPM.BPMSVN != report.BPM.BPMSVN {
+		t.Errorf("JSON report doesn't match the original report")
+	}
+
+	buf.Reset()
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() failed: %v", err)
+	}
+	for _, s := range []string{"bg-prov v1.0.0 (abcdef)", report.Inputs[0].SHA256, "ACMSVNAuth"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Text report doesn't contain %q", s)
+		}
+	}
+}
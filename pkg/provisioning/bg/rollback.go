@@ -0,0 +1,41 @@
+package bg
+
+import (
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+// SVNChange describes how a security version number changed between two
+// revisions of a manifest.
+type SVNChange struct {
+	Name string
+	Old  manifest.SVN
+	New  manifest.SVN
+}
+
+// Decreased returns true if the new SVN is lower than the old one.
+func (c SVNChange) Decreased() bool {
+	return c.New.SVN() < c.Old.SVN()
+}
+
+func (c SVNChange) String() string {
+	return fmt.Sprintf("%s: %d -> %d", c.Name, c.Old.SVN(), c.New.SVN())
+}
+
+// CheckBPMRollback compares the SVNs of the previous and the new BPM header.
+// It returns all compared values and an error if any of them was decreased,
+// which would allow rolling back to older, possibly vulnerable, firmware.
+func CheckBPMRollback(prev, next *bootpolicy.BPMH) ([]SVNChange, error) {
+	changes := []SVNChange{
+		{Name: "BPMSVN", Old: prev.BPMSVN, New: next.BPMSVN},
+		{Name: "ACMSVNAuth", Old: prev.ACMSVNAuth, New: next.ACMSVNAuth},
+	}
+	for _, c := range changes {
+		if c.Decreased() {
+			return changes, fmt.Errorf("%s decreased from %d to %d", c.Name, c.Old.SVN(), c.New.SVN())
+		}
+	}
+	return changes, nil
+}
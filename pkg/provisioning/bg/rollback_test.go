@@ -0,0 +1,35 @@
+package bg
+
+import (
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+func TestCheckBPMRollback(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		prev, next   bootpolicy.BPMH
+		wantRollback bool
+	}{
+		{"unchanged", bootpolicy.BPMH{BPMSVN: 2, ACMSVNAuth: 3}, bootpolicy.BPMH{BPMSVN: 2, ACMSVNAuth: 3}, false},
+		{"increased", bootpolicy.BPMH{BPMSVN: 2, ACMSVNAuth: 3}, bootpolicy.BPMH{BPMSVN: 3, ACMSVNAuth: 4}, false},
+		{"bpmsvn decreased", bootpolicy.BPMH{BPMSVN: 2, ACMSVNAuth: 3}, bootpolicy.BPMH{BPMSVN: 1, ACMSVNAuth: 3}, true},
+		{"acmsvn decreased", bootpolicy.BPMH{BPMSVN: 2, ACMSVNAuth: 3}, bootpolicy.BPMH{BPMSVN: 5, ACMSVNAuth: 2}, true},
+		// Only the lower 4 bits hold the security version number.
+		{"reserved bits ignored", bootpolicy.BPMH{BPMSVN: 0x12, ACMSVNAuth: 3}, bootpolicy.BPMH{BPMSVN: 0x03, ACMSVNAuth: 3}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := CheckBPMRollback(&tc.prev, &tc.next)
+			if (err != nil) != tc.wantRollback {
+				t.Fatalf("CheckBPMRollback() error = %v, want rollback %v", err, tc.wantRollback)
+			}
+			if len(changes) != 2 {
+				t.Fatalf("CheckBPMRollback() returned %d changes, want 2", len(changes))
+			}
+			if changes[1].Old != tc.prev.ACMSVNAuth || changes[1].New != tc.next.ACMSVNAuth {
+				t.Errorf("CheckBPMRollback() reported wrong ACMSVNAuth values: %s", changes[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package bg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// CutSidecarVersion is the version of the sidecar format written by
+// WriteCutSidecar. Sidecars of other versions are rejected.
+const CutSidecarVersion = 1
+
+// ErrSidecarMismatch is returned if a cut manifest and its sidecar don't
+// belong together.
+var ErrSidecarMismatch = errors.New("the cut manifest doesn't match its sidecar")
+
+// CutSidecar describes a manifest written with --cut, so the signature is
+// only stitched onto the binary that was sent for signing.
+type CutSidecar struct {
+	Version  int    `json:"version"`
+	Manifest string `json:"manifest"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// CutSidecarPath returns the path of the sidecar of the cut manifest at path.
+func CutSidecarPath(path string) string {
+	return path + ".sidecar.json"
+}
+
+// NewCutSidecar returns the sidecar of the cut manifest data of the given
+// kind ("KM" or "BPM").
+func NewCutSidecar(kind string, data []byte) *CutSidecar {
+	sum := sha256.Sum256(data)
+	return &CutSidecar{
+		Version:  CutSidecarVersion,
+		Manifest: kind,
+		Size:     len(data),
+		SHA256:   hex.EncodeToString(sum[:]),
+	}
+}
+
+// WriteCutSidecar writes the sidecar of the cut manifest data of the given
+// kind next to the manifest written to path.
+func WriteCutSidecar(path, kind string, data []byte) error {
+	sidecar, err := json.MarshalIndent(NewCutSidecar(kind, data), "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(CutSidecarPath(path), append(sidecar, '\n'), 0600)
+}
+
+// ReadCutSidecar reads the sidecar of the cut manifest at path. It returns
+// nil without error if there is none, e.g. for manifests cut by older
+// versions.
+func ReadCutSidecar(path string) (*CutSidecar, error) {
+	data, err := ioutil.ReadFile(CutSidecarPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s CutSidecar
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", CutSidecarPath(path), err)
+	}
+	return &s, nil
+}
+
+// Check returns ErrSidecarMismatch if the sidecar doesn't describe the cut
+// manifest data of the given kind.
+func (s *CutSidecar) Check(kind string, data []byte) error {
+	if s.Version != CutSidecarVersion {
+		return fmt.Errorf("unsupported sidecar version %d, expected %d", s.Version, CutSidecarVersion)
+	}
+	expected := NewCutSidecar(kind, data)
+	if s.Manifest != expected.Manifest {
+		return fmt.Errorf("%w: the sidecar describes a %s, not a %s", ErrSidecarMismatch, s.Manifest, kind)
+	}
+	if s.Size != expected.Size || s.SHA256 != expected.SHA256 {
+		return fmt.Errorf("%w: the sidecar expects %d bytes with SHA256 %s, the manifest has %d bytes with SHA256 %s",
+			ErrSidecarMismatch, s.Size, s.SHA256, expected.Size, expected.SHA256)
+	}
+	return nil
+}
+
+// CheckCutSidecar checks the cut manifest data of the given kind read from
+// path against its sidecar, if there is one.
+func CheckCutSidecar(path, kind string, data []byte) error {
+	s, err := ReadCutSidecar(path)
+	if err != nil || s == nil {
+		return err
+	}
+	return s.Check(kind, data)
+}
@@ -0,0 +1,43 @@
+package bg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCutSidecar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sidecar")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	kmPath := filepath.Join(dir, "km.bin")
+	km := []byte("cut KM")
+	bpm := []byte("cut BPM")
+
+	if err := CheckCutSidecar(kmPath, "KM", km); err != nil {
+		t.Errorf("CheckCutSidecar() without a sidecar returned %v", err)
+	}
+	if err := WriteCutSidecar(kmPath, "KM", km); err != nil {
+		t.Fatalf("WriteCutSidecar() failed: %v", err)
+	}
+	if err := CheckCutSidecar(kmPath, "KM", km); err != nil {
+		t.Errorf("CheckCutSidecar() of the described KM returned %v", err)
+	}
+	if err := CheckCutSidecar(kmPath, "KM", bpm); !errors.Is(err, ErrSidecarMismatch) {
+		t.Errorf("CheckCutSidecar() of another binary returned %v, expected %v", err, ErrSidecarMismatch)
+	}
+	if err := CheckCutSidecar(kmPath, "BPM", km); !errors.Is(err, ErrSidecarMismatch) {
+		t.Errorf("CheckCutSidecar() of another manifest kind returned %v, expected %v", err, ErrSidecarMismatch)
+	}
+
+	if err := ioutil.WriteFile(CutSidecarPath(kmPath), []byte(`{"version": 2}`), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := CheckCutSidecar(kmPath, "KM", km); err == nil {
+		t.Errorf("CheckCutSidecar() accepted an unsupported sidecar version")
+	}
+}
@@ -0,0 +1,31 @@
+package bg
+
+import (
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// SetKMSignaturePlaceholder sets a zeroed signature of the size of the key
+// type in the KM, so the KM has its final size before it is signed and the
+// signature can be spliced in at a fixed offset. The public key of the KM has
+// to be of the key type. The placeholder declares the hash algorithm of the
+// key type, PubKeyHashAlg is the algorithm of the OEM key hash and is left
+// alone.
+func SetKMSignaturePlaceholder(km *key.Manifest, k SigningKeyType) error {
+	declared := &km.KeyAndSignature.Key
+	if declared.KeyAlg != k.KeyAlg || declared.KeySize.InBits() != k.KeyBits {
+		return fmt.Errorf("KM key is %s with %d bits, but the signature placeholder is for %s",
+			declared.KeyAlg, declared.KeySize.InBits(), k.Name)
+	}
+	km.KeyAndSignature.Signature = k.PlaceholderKeySignature().Signature
+	return nil
+}
+
+// SetBPMSignaturePlaceholder sets a zeroed key and signature of the size of
+// the key type in the BPM, so the BPM has its final size before it is signed.
+// bpm-sign checks the signing key against the key type of the placeholder.
+func SetBPMSignaturePlaceholder(bpm *bootpolicy.Manifest, k SigningKeyType) {
+	bpm.PMSE.KeySignature = *k.PlaceholderKeySignature()
+}
@@ -0,0 +1,106 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestSignaturePlaceholder(t *testing.T) {
+	rsa3072, err := ParseSigningKeyType("rsa3072")
+	if err != nil {
+		t.Fatalf("ParseSigningKeyType() failed: %v", err)
+	}
+	if _, err := ParseSigningKeyType("RSA1024"); err == nil {
+		t.Errorf("ParseSigningKeyType() succeeded with an unknown key type")
+	}
+	privKey, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	if err := km.KeyAndSignature.Key.SetPubKey(privKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	if err := SetKMSignaturePlaceholder(km, SigningKeyTypes[0]); err == nil {
+		t.Errorf("SetKMSignaturePlaceholder() succeeded with a placeholder for another key type")
+	}
+	if err := SetKMSignaturePlaceholder(km, rsa3072); err != nil {
+		t.Fatalf("SetKMSignaturePlaceholder() failed: %v", err)
+	}
+	// the placeholder keeps the hash algorithm of the key type
+	if km.KeyAndSignature.Signature.HashAlg != rsa3072.HashAlg || km.PubKeyHashAlg != manifest.AlgSHA256 {
+		t.Errorf("SetKMSignaturePlaceholder() set the signature hash algorithm %v and PubKeyHashAlg %v, expected %v and %v",
+			km.KeyAndSignature.Signature.HashAlg, km.PubKeyHashAlg, rsa3072.HashAlg, manifest.AlgSHA256)
+	}
+	placeholder, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	offset := int(km.KeyAndSignatureOffset())
+	if len(placeholder) != offset+rsa3072.KeySignatureSize() {
+		t.Errorf("KM with placeholder is %d bytes, expected %d bytes", len(placeholder), offset+rsa3072.KeySignatureSize())
+	}
+	if _, err := VerifyKM(placeholder); err == nil {
+		t.Errorf("VerifyKM() succeeded with a placeholder signature")
+	}
+
+	// sign as km-sign does
+	parsed, err := ParseKM(bytes.NewReader(placeholder))
+	if err != nil {
+		t.Fatalf("ParseKM() of the KM with placeholder failed: %v", err)
+	}
+	if err := parsed.SetSignature(0, privKey, placeholder[:offset]); err != nil {
+		t.Fatalf("SetSignature() failed: %v", err)
+	}
+	signed, err := WriteKM(parsed)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if len(signed) != len(placeholder) || !bytes.Equal(signed[:offset], placeholder[:offset]) {
+		t.Errorf("Signing changed the KM beyond the signature: %d bytes, expected %d bytes", len(signed), len(placeholder))
+	}
+	if _, err := VerifyKM(signed); err != nil {
+		t.Errorf("VerifyKM() of the signed KM failed: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	SetBPMSignaturePlaceholder(bpm, rsa3072)
+	placeholder, err = WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if len(placeholder) != int(bpm.KeySignatureOffset)+rsa3072.KeySignatureSize() {
+		t.Errorf("BPM with placeholder is %d bytes, expected %d bytes", len(placeholder), int(bpm.KeySignatureOffset)+rsa3072.KeySignatureSize())
+	}
+
+	// sign as bpm-sign does
+	parsedBPM, err := ParseBPM(bytes.NewReader(placeholder))
+	if err != nil {
+		t.Fatalf("ParseBPM() of the BPM with placeholder failed: %v", err)
+	}
+	if err := CheckSigningKey(&parsedBPM.PMSE.Key, privKey.Public()); err != nil {
+		t.Errorf("CheckSigningKey() failed with the key type of the placeholder: %v", err)
+	}
+	signed = signBPM(t, parsedBPM, privKey)
+	if len(signed) != len(placeholder) || !bytes.Equal(signed[:parsedBPM.KeySignatureOffset], placeholder[:parsedBPM.KeySignatureOffset]) {
+		t.Errorf("Signing changed the BPM beyond the signature: %d bytes, expected %d bytes", len(signed), len(placeholder))
+	}
+	if _, err := VerifyBPM(signed); err != nil {
+		t.Errorf("VerifyBPM() of the signed BPM failed: %v", err)
+	}
+}
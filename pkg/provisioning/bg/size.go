@@ -0,0 +1,90 @@
+package bg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// ManifestSize describes how many bytes a KM or BPM occupies.
+type ManifestSize struct {
+	// File is the size of the binary including any padding.
+	File int
+	// Manifest is the size of the manifest itself.
+	Manifest int
+	// SignedRegion is the size of the part covered by the signature.
+	SignedRegion int
+	// KeySignature is the size of the key and signature structure, which
+	// depends on the key type. It is zero for unsigned manifests.
+	KeySignature int
+}
+
+func (s *ManifestSize) String() string {
+	return fmt.Sprintf("%d bytes (signed region: %d bytes, key and signature: %d bytes, padding: %d bytes)",
+		s.Manifest, s.SignedRegion, s.KeySignature, s.File-s.Manifest)
+}
+
+func newManifestSize(data []byte, signedRegion int, ks *manifest.KeySignature) (*ManifestSize, error) {
+	if signedRegion > len(data) {
+		return nil, fmt.Errorf("manifest is truncated: signed region is %d bytes, but got %d bytes", signedRegion, len(data))
+	}
+	size := &ManifestSize{File: len(data), SignedRegion: signedRegion}
+	if len(data) > signedRegion && len(ks.Signature.Data) > 0 {
+		size.KeySignature = int(ks.TotalSize())
+	}
+	size.Manifest = size.SignedRegion + size.KeySignature
+	if size.Manifest > size.File {
+		return nil, fmt.Errorf("manifest is truncated: expected %d bytes, but got %d bytes", size.Manifest, size.File)
+	}
+	return size, nil
+}
+
+// KMSize returns the sizes of a signed or unsigned key manifest binary.
+func KMSize(data []byte) (*ManifestSize, error) {
+	return DefaultParseOptions().KMSize(data)
+}
+
+// KMSize is KMSize with the options o instead of the DefaultParseOptions.
+func (o ParseOptions) KMSize(data []byte) (*ManifestSize, error) {
+	km, err := o.ParseKM(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return newManifestSize(data, int(km.KeyAndSignatureOffset()), &km.KeyAndSignature)
+}
+
+// BPMSize returns the sizes of a signed or unsigned boot policy manifest binary.
+func BPMSize(data []byte) (*ManifestSize, error) {
+	return DefaultParseOptions().BPMSize(data)
+}
+
+// BPMSize is BPMSize with the options o instead of the DefaultParseOptions.
+func (o ParseOptions) BPMSize(data []byte) (*ManifestSize, error) {
+	bpm, err := o.ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return newManifestSize(data, int(bpm.KeySignatureOffset), &bpm.PMSE.KeySignature)
+}
+
+// ManifestSizeOf detects whether data is a KM or a BPM and returns its type and sizes.
+func ManifestSizeOf(data []byte) (string, *ManifestSize, error) {
+	return DefaultParseOptions().ManifestSizeOf(data)
+}
+
+// ManifestSizeOf is ManifestSizeOf with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) ManifestSizeOf(data []byte) (string, *ManifestSize, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte(key.StructureIDManifest)):
+		size, err := o.KMSize(data)
+		return "KM", size, err
+	case bytes.HasPrefix(data, []byte(bootpolicy.StructureIDBPMH)):
+		size, err := o.BPMSize(data)
+		return "BPM", size, err
+	}
+	return "", nil, fmt.Errorf("data is neither a KM nor a BPM")
+}
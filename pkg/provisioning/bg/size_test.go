@@ -0,0 +1,55 @@
+package bg
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestKMSize(t *testing.T) {
+	signed := signedTestKM(t)
+	size, err := KMSize(signed)
+	if err != nil {
+		t.Fatalf("KMSize() failed: %v", err)
+	}
+	if size.File != len(signed) || size.Manifest != len(signed) {
+		t.Errorf("KMSize() reported %d/%d bytes, file has %d bytes", size.File, size.Manifest, len(signed))
+	}
+	if size.KeySignature == 0 || size.SignedRegion+size.KeySignature != len(signed) {
+		t.Errorf("KMSize() reported inconsistent signed region and signature sizes: %s", size)
+	}
+
+	unsigned := signed[:size.SignedRegion]
+	size, err = KMSize(unsigned)
+	if err != nil {
+		t.Fatalf("KMSize() failed on unsigned KM: %v", err)
+	}
+	if size.Manifest != len(unsigned) || size.KeySignature != 0 {
+		t.Errorf("KMSize() reported wrong sizes for unsigned KM: %s", size)
+	}
+
+	padded, err := PadManifest(signed, uint32(len(signed)+100), 0)
+	if err != nil {
+		t.Fatalf("PadManifest() failed: %v", err)
+	}
+	kind, size, err := ManifestSizeOf(padded)
+	if err != nil {
+		t.Fatalf("ManifestSizeOf() failed: %v", err)
+	}
+	if kind != "KM" || size.File != len(padded) || size.Manifest != len(signed) {
+		t.Errorf("ManifestSizeOf() reported wrong sizes for padded KM: %s %s", kind, size)
+	}
+}
+
+func TestBPMSize(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	kind, size, err := ManifestSizeOf(data)
+	if err != nil {
+		t.Fatalf("ManifestSizeOf() failed: %v", err)
+	}
+	if kind != "BPM" || size.File != len(data) || size.Manifest != len(data) {
+		t.Errorf("ManifestSizeOf() reported %s %s, file has %d bytes", kind, size, len(data))
+	}
+}
@@ -0,0 +1,168 @@
+package bg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// SigningKeyType is a key type a KM or BPM can be signed with.
+type SigningKeyType struct {
+	Name      string
+	KeyAlg    manifest.Algorithm
+	KeyBits   uint16
+	SigScheme manifest.Algorithm
+	HashAlg   manifest.Algorithm
+}
+
+// SigningKeyTypes are the key types supported for signing a KM or BPM.
+var SigningKeyTypes = []SigningKeyType{
+	{"RSA2048", manifest.AlgRSA, 2048, manifest.AlgRSASSA, manifest.AlgSHA256},
+	{"RSA3072", manifest.AlgRSA, 3072, manifest.AlgRSAPSS, manifest.AlgSHA384},
+	{"ECC256", manifest.AlgECC, 256, manifest.AlgECDSA, manifest.AlgSHA256},
+	{"SM2", manifest.AlgSM2, 256, manifest.AlgSM2, manifest.AlgSM3_256},
+}
+
+// ParseSigningKeyType returns the signing key type of the given name, e.g.
+// RSA3072. The name is case insensitive.
+func ParseSigningKeyType(name string) (SigningKeyType, error) {
+	var names []string
+	for _, k := range SigningKeyTypes {
+		if strings.EqualFold(k.Name, name) {
+			return k, nil
+		}
+		names = append(names, k.Name)
+	}
+	return SigningKeyType{}, fmt.Errorf("unknown key type %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// KeySignatureSize returns the size of the key and signature structure of a
+// manifest signed with the key type.
+func (k SigningKeyType) KeySignatureSize() int {
+	return int(k.PlaceholderKeySignature().TotalSize())
+}
+
+// PlaceholderKeySignature returns a key and signature structure of the size
+// of the key type with a zeroed key and signature.
+func (k SigningKeyType) PlaceholderKeySignature() *manifest.KeySignature {
+	ks := manifest.NewKeySignature()
+	ks.Key.KeyAlg = k.KeyAlg
+	ks.Key.KeySize.SetInBits(k.KeyBits)
+	ks.Signature.SigScheme = k.SigScheme
+	ks.Signature.KeySize.SetInBits(k.KeyBits)
+	ks.Signature.HashAlg = k.HashAlg
+	size := int(ks.Key.KeySize.InBytes())
+	if k.KeyAlg == manifest.AlgRSA {
+		// the RSA key data is the exponent followed by the modulus
+		ks.Key.Data = make([]byte, 4+size)
+		ks.Signature.Data = make([]byte, size)
+	} else {
+		// the ECC and SM2 key data is X and Y, the signature is R and S
+		ks.Key.Data = make([]byte, 2*size)
+		ks.Signature.Data = make([]byte, 2*size)
+	}
+	return ks
+}
+
+// KeyTypeSize is the size of a manifest signed with a key type.
+type KeyTypeSize struct {
+	KeyType      string `json:"key_type"`
+	KeySignature int    `json:"key_signature"`
+	Total        int    `json:"total"`
+}
+
+// ManifestSizePlan lists the sizes of a manifest for every signing key type.
+type ManifestSizePlan struct {
+	SignedRegion int           `json:"signed_region"`
+	KeyTypes     []KeyTypeSize `json:"key_types"`
+	// Max is the size with the largest key type, which leaves room for
+	// rotating to any other key type.
+	Max int `json:"max"`
+}
+
+func newManifestSizePlan(signedRegion int) ManifestSizePlan {
+	p := ManifestSizePlan{SignedRegion: signedRegion}
+	for _, k := range SigningKeyTypes {
+		size := KeyTypeSize{KeyType: k.Name, KeySignature: k.KeySignatureSize()}
+		size.Total = signedRegion + size.KeySignature
+		if size.Total > p.Max {
+			p.Max = size.Total
+		}
+		p.KeyTypes = append(p.KeyTypes, size)
+	}
+	return p
+}
+
+// SizePlan are the sizes of the KM, BPM and ACM of a config, which the FIT
+// regions of the firmware have to hold.
+type SizePlan struct {
+	KM  ManifestSizePlan `json:"km"`
+	BPM ManifestSizePlan `json:"bpm"`
+	ACM int              `json:"acm,omitempty"`
+}
+
+// PlanSizes computes the sizes of the KM and BPM generated from the config and
+// of the ACM. The IBB digests are sized by their hash algorithms, so no
+// firmware image is needed. acm may be nil.
+func PlanSizes(bgo *BootGuardOptions, acm []byte) (*SizePlan, error) {
+	if len(bgo.BootPolicyManifest.SE) == 0 {
+		return nil, fmt.Errorf("no IBB segments element (SE) configured")
+	}
+	se := bgo.BootPolicyManifest.SE[0]
+	se.DigestList.List = make([]manifest.HashStructure, len(bgo.BootPolicyManifest.SE[0].DigestList.List))
+	for idx, item := range bgo.BootPolicyManifest.SE[0].DigestList.List {
+		hash, err := item.HashAlg.Hash()
+		if err != nil {
+			return nil, fmt.Errorf("IBB digest %d: %w", idx, err)
+		}
+		se.DigestList.List[idx] = manifest.HashStructure{HashAlg: item.HashAlg, HashBuffer: make([]byte, hash.Size())}
+	}
+	bpm, err := assembleBPM(bgo, &se)
+	if err != nil {
+		return nil, err
+	}
+	bpm.RehashRecursive()
+
+	plan := &SizePlan{
+		KM:  newManifestSizePlan(int(bgo.KeyManifest.KeyAndSignatureOffset())),
+		BPM: newManifestSizePlan(int(bpm.KeySignatureOffset)),
+	}
+	if acm != nil {
+		size, err := tools.LookupACMSize(acm)
+		if err != nil {
+			return nil, err
+		}
+		plan.ACM = int(size)
+	}
+	return plan, nil
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *SizePlan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func (p *ManifestSizePlan) prettyPrint(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s signed region: %d bytes\n", name, p.SignedRegion)
+	for _, k := range p.KeyTypes {
+		fmt.Fprintf(w, "  %-8s %d bytes (key and signature: %d bytes)\n", k.KeyType, k.Total, k.KeySignature)
+	}
+	fmt.Fprintf(w, "  largest: %d bytes\n", p.Max)
+}
+
+// PrettyPrint writes the plan in human readable form.
+func (p *SizePlan) PrettyPrint(w io.Writer) error {
+	p.KM.prettyPrint(w, "KM")
+	p.BPM.prettyPrint(w, "BPM")
+	if p.ACM > 0 {
+		_, err := fmt.Fprintf(w, "ACM: %d bytes\n", p.ACM)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package bg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPlanSizes(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	acm, err := ioutil.ReadFile("../../tools/tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	bgo := &BootGuardOptions{KeyManifest: *km, BootPolicyManifest: *bpm}
+	plan, err := PlanSizes(bgo, acm)
+	if err != nil {
+		t.Fatalf("PlanSizes() failed: %v", err)
+	}
+
+	if plan.KM.SignedRegion != int(km.KeyAndSignatureOffset()) {
+		t.Errorf("KM signed region is %d bytes, expected %d bytes", plan.KM.SignedRegion, km.KeyAndSignatureOffset())
+	}
+	// the generated BPM doesn't carry over the reserved element
+	bpmSigned := int(bpm.KeySignatureOffset)
+	if bpm.Res != nil {
+		bpmSigned -= int(bpm.Res.TotalSize())
+	}
+	if plan.BPM.SignedRegion != bpmSigned {
+		t.Errorf("BPM signed region is %d bytes, expected %d bytes", plan.BPM.SignedRegion, bpmSigned)
+	}
+	if plan.ACM != len(acm) {
+		t.Errorf("ACM size is %d bytes, expected %d bytes", plan.ACM, len(acm))
+	}
+
+	// the size of the fixture key type has to match the fixture
+	keyType := fmt.Sprintf("RSA%d", km.KeyAndSignature.Key.KeySize.InBits())
+	found := false
+	for _, k := range plan.KM.KeyTypes {
+		if k.Total > plan.KM.Max {
+			t.Errorf("KM size with %s exceeds the largest size %d", k.KeyType, plan.KM.Max)
+		}
+		if k.KeyType != keyType {
+			continue
+		}
+		found = true
+		if k.KeySignature != int(km.KeyAndSignature.TotalSize()) {
+			t.Errorf("%s key and signature is %d bytes, the KM has %d bytes", keyType, k.KeySignature, km.KeyAndSignature.TotalSize())
+		}
+	}
+	if !found {
+		t.Errorf("Key type %s of the KM is not planned", keyType)
+	}
+
+	var out bytes.Buffer
+	if err := plan.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"key_type": "RSA3072"`) {
+		t.Errorf("WriteJSON() returned %s", out.String())
+	}
+}
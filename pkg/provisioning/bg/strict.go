@@ -0,0 +1,89 @@
+package bg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+const (
+	svnReservedMask             = manifest.SVN(0xf0)
+	seFlagsReservedMask         = bootpolicy.SEFlags(0xffffffe0)
+	txtControlFlagsReservedMask = bootpolicy.TXTControlFlags(0x7ffffc00)
+	ibbSegmentFlagsReservedMask = ^bootpolicy.IBBSegmentFlagNotMeasured
+	kmHashUsageReservedMask     = ^(key.UsageReserved - 1)
+)
+
+type reservedFields []string
+
+func (r *reservedFields) checkBytes(name string, b []byte) {
+	for _, v := range b {
+		if v != 0 {
+			*r = append(*r, fmt.Sprintf("%s is 0x%x", name, b))
+			return
+		}
+	}
+}
+
+func (r *reservedFields) checkBits(name string, value, mask uint64) {
+	if value&mask != 0 {
+		*r = append(*r, fmt.Sprintf("%s has reserved bits 0x%x set", name, value&mask))
+	}
+}
+
+func (r reservedFields) err(manifestName string) error {
+	if len(r) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s has non-zero reserved fields: %s", manifestName, strings.Join(r, ", "))
+}
+
+// CheckKMReserved returns an error listing all reserved fields of the KM
+// which aren't zero.
+func CheckKMReserved(km *key.Manifest) error {
+	var r reservedFields
+	r.checkBytes("Reserved2", km.Reserved2[:])
+	r.checkBits("KMSVN", uint64(km.KMSVN), uint64(svnReservedMask))
+	for idx, h := range km.Hash {
+		r.checkBits(fmt.Sprintf("Hash[%d].Usage", idx), uint64(h.Usage), uint64(kmHashUsageReservedMask))
+	}
+	return r.err("KM")
+}
+
+// CheckBPMReserved returns an error listing all reserved fields of the BPM
+// which aren't zero.
+func CheckBPMReserved(bpm *bootpolicy.Manifest) error {
+	var r reservedFields
+	r.checkBytes("BPMH.Reserved0", bpm.BPMH.Reserved0[:])
+	r.checkBits("BPMH.BPMSVN", uint64(bpm.BPMH.BPMSVN), uint64(svnReservedMask))
+	r.checkBits("BPMH.ACMSVNAuth", uint64(bpm.BPMH.ACMSVNAuth), uint64(svnReservedMask))
+	for idx, se := range bpm.SE {
+		prefix := fmt.Sprintf("SE[%d]", idx)
+		r.checkBytes(prefix+".Reserved0", se.Reserved0[:])
+		r.checkBytes(prefix+".Reserved1", se.Reserved1[:])
+		r.checkBytes(prefix+".Reserved2", se.Reserved2[:])
+		r.checkBits(prefix+".Flags", uint64(se.Flags), uint64(seFlagsReservedMask))
+		for segIdx, seg := range se.IBBSegments {
+			segPrefix := fmt.Sprintf("%s.IBBSegments[%d]", prefix, segIdx)
+			r.checkBytes(segPrefix+".Reserved", seg.Reserved[:])
+			r.checkBits(segPrefix+".Flags", uint64(seg.Flags), uint64(ibbSegmentFlagsReservedMask))
+		}
+	}
+	if txt := bpm.TXTE; txt != nil {
+		r.checkBytes("TXTE.Reserved0", txt.Reserved0[:])
+		r.checkBytes("TXTE.Reserved1", txt.Reserved1[:])
+		r.checkBytes("TXTE.Reserved2", txt.Reserved2[:])
+		r.checkBytes("TXTE.Reserved3", txt.Reserved3[:])
+		r.checkBits("TXTE.ControlFlags", uint64(txt.ControlFlags), uint64(txtControlFlagsReservedMask))
+	}
+	if bpm.PCDE != nil {
+		r.checkBytes("PCDE.Reserved0", bpm.PCDE.Reserved0[:])
+	}
+	if bpm.PME != nil {
+		r.checkBytes("PME.Reserved0", bpm.PME.Reserved0[:])
+	}
+	return r.err("BPM")
+}
@@ -0,0 +1,89 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestCheckKMReserved(t *testing.T) {
+	km := key.NewManifest()
+	km.Hash = []key.Hash{{Usage: key.UsageBPMSigningPKD}}
+	if err := CheckKMReserved(km); err != nil {
+		t.Fatalf("CheckKMReserved() failed on a clean KM: %v", err)
+	}
+
+	km.Reserved2[1] = 0x01
+	km.KMSVN = 0x12
+	km.Hash[0].Usage |= key.UsageReserved
+	err := CheckKMReserved(km)
+	if err == nil {
+		t.Fatalf("CheckKMReserved() accepted reserved bits")
+	}
+	for _, field := range []string{"Reserved2", "KMSVN", "Hash[0].Usage"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("CheckKMReserved() error doesn't list %s: %v", field, err)
+		}
+	}
+}
+
+func TestCheckBPMReserved(t *testing.T) {
+	newBPM := func() *bootpolicy.Manifest {
+		bpm := bootpolicy.NewManifest()
+		se := bootpolicy.NewSE()
+		se.IBBSegments = append(se.IBBSegments, *bootpolicy.NewIBBSegment())
+		bpm.SE = append(bpm.SE, *se)
+		bpm.TXTE = bootpolicy.NewTXT()
+		return bpm
+	}
+	if err := CheckBPMReserved(newBPM()); err != nil {
+		t.Fatalf("CheckBPMReserved() failed on a clean BPM: %v", err)
+	}
+
+	for _, tc := range []struct {
+		field  string
+		modify func(bpm *bootpolicy.Manifest)
+	}{
+		{"BPMH.Reserved0", func(bpm *bootpolicy.Manifest) { bpm.BPMH.Reserved0[0] = 1 }},
+		{"BPMH.ACMSVNAuth", func(bpm *bootpolicy.Manifest) { bpm.BPMH.ACMSVNAuth = manifest.SVN(0x81) }},
+		{"SE[0].Reserved2", func(bpm *bootpolicy.Manifest) { bpm.SE[0].Reserved2[2] = 1 }},
+		{"SE[0].Flags", func(bpm *bootpolicy.Manifest) { bpm.SE[0].Flags |= 0x100 }},
+		{"SE[0].IBBSegments[0].Flags", func(bpm *bootpolicy.Manifest) { bpm.SE[0].IBBSegments[0].Flags |= 0x2 }},
+		{"TXTE.Reserved3", func(bpm *bootpolicy.Manifest) { bpm.TXTE.Reserved3[0] = 1 }},
+		{"TXTE.ControlFlags", func(bpm *bootpolicy.Manifest) { bpm.TXTE.ControlFlags |= 1 << 20 }},
+	} {
+		bpm := newBPM()
+		tc.modify(bpm)
+		err := CheckBPMReserved(bpm)
+		if err == nil || !strings.Contains(err.Error(), tc.field) {
+			t.Errorf("CheckBPMReserved() returned %v, expected error listing %s", err, tc.field)
+		}
+	}
+}
+
+func TestParseKMStrict(t *testing.T) {
+	golden, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	km.Reserved2[0] = 0xff
+	raw, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if _, err := ParseKM(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("ParseKM() failed in non-strict mode: %v", err)
+	}
+	if _, err := (ParseOptions{StrictReserved: true}).ParseKM(bytes.NewReader(raw)); err == nil {
+		t.Errorf("ParseKM() accepted reserved bits in strict mode")
+	}
+}
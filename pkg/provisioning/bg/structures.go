@@ -45,7 +45,11 @@ type Pcr0Data struct {
 type Pcr7Data struct {
 	ACMPolicyStatus uint64
 	ACMSVN          uint16
-	ACMKeyHash      [32]byte
-	BPMKey          [32]byte
-	BPMKeyHash      []byte
+	// ACMKeyHash is the SHA256 hash of the ACM signing key.
+	ACMKeyHash [32]byte
+	// BPMKey is the OEM key hash, the hash of the KM signing key with the
+	// KM's PubKeyHashAlg.
+	BPMKey []byte
+	// BPMKeyHash is the hash of the BPM signing key.
+	BPMKeyHash []byte
 }
@@ -0,0 +1,120 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+const (
+	// stubACMScratchSize is the scratch size in dwords of the version 0 ACM header.
+	stubACMScratchSize = 143
+	// stubACMDebugSigned is the flag of the ACM header marking a debug signed ACM.
+	stubACMDebugSigned = 1 << 15
+	// stubACMInfoVersion is the version of the chipset ACM info table.
+	stubACMInfoVersion = 6
+	// stubACMVersion is the lowest ACMVersion with a TPM info list.
+	stubACMVersion = 5
+)
+
+// stubACMMarker is written after the ID tables so a stub ACM is recognizable
+// in a hex dump of an image.
+var stubACMMarker = []byte("STUB ACM FOR TESTING, NOT FOR PRODUCTION USE")
+
+// StubACMParams are the fields of a stub ACM.
+type StubACMParams struct {
+	// ChipsetACMType is tools.ACMChipsetTypeBios or tools.ACMChipsetTypeSinit.
+	ChipsetACMType uint8
+	// ModuleSubType are the tools.ACMModuleSubtype* flags. An ANC module has
+	// no chipset ACM info table and thus no ID tables.
+	ModuleSubType   uint16
+	TxtSVN          uint16
+	SeSVN           uint16
+	Chipsets        []tools.ChipsetID
+	Processors      []tools.ProcessorID
+	TPMCapabilities uint32
+	TPMAlgorithms   []manifest.Algorithm
+	// Size is the size of the ACM in bytes, e.g. to replace an ACM of the
+	// same size in an image. If it is too small for the ID tables, the
+	// smallest possible size is used.
+	Size uint32
+}
+
+// NewStubACM returns an ACM with a version 0 header and the ID tables of
+// params which the ACM parsers accept, for tests and demos of the stitch and
+// ACM validation flows. Real ACMs are proprietary and can't be distributed.
+//
+// The stub is not a functional ACM and must never be used in production: it
+// has no code, it is marked debug signed and its public key and signature are
+// zero, thus its signature never verifies.
+func NewStubACM(params StubACMParams) []byte {
+	headerLen := tools.ACMheaderLen
+	header := tools.ACMHeader{
+		ModuleType:    tools.ACMTypeChipset,
+		ModuleSubType: params.ModuleSubType,
+		HeaderLen:     headerLen,
+		HeaderVersion: tools.ACMHeaderVersion0,
+		Flags:         stubACMDebugSigned,
+		ModuleVendor:  tools.ACMVendorIntel,
+		TxtSVN:        params.TxtSVN,
+		SeSVN:         params.SeSVN,
+		KeySize:       uint32(binary.Size(tools.ACMHeader{}.PubKey)) / 4,
+		ScratchSize:   stubACMScratchSize,
+		PubExp:        0x10001,
+	}
+	infoOffset := (headerLen + stubACMScratchSize) * 4
+
+	var body bytes.Buffer
+	if params.ModuleSubType&tools.ACMModuleSubtypeAncModule == 0 {
+		info := tools.ACMInfo{
+			UUID: tools.UUID{
+				Field1: 0x7fc03aaa,
+				Field2: 0x46a7,
+				Field3: 0x18db,
+				Field4: 0xac2e,
+				Field5: [6]uint8{0x69, 0x8f, 0x8d, 0x41, 0x7f, 0x5a},
+			},
+			ChipsetACMType: params.ChipsetACMType,
+			Version:        stubACMInfoVersion,
+			Length:         uint16(binary.Size(tools.ACMInfo{})),
+			ACMVersion:     stubACMVersion,
+		}
+		info.ChipsetIDList = infoOffset + uint32(info.Length)
+		info.ProcessorIDList = info.ChipsetIDList + 4 + uint32(len(params.Chipsets)*binary.Size(tools.ChipsetID{}))
+		info.TPMInfoList = info.ProcessorIDList + 4 + uint32(len(params.Processors)*binary.Size(tools.ProcessorID{}))
+
+		algs := make([]uint16, len(params.TPMAlgorithms))
+		for idx, alg := range params.TPMAlgorithms {
+			// TPM and manifest algorithms share the TCG algorithm IDs
+			algs[idx] = uint16(alg)
+		}
+		for _, field := range []interface{}{
+			&info,
+			uint32(len(params.Chipsets)), params.Chipsets,
+			uint32(len(params.Processors)), params.Processors,
+			params.TPMCapabilities, uint16(len(algs)), algs,
+		} {
+			// writes to a bytes.Buffer of fixed size data don't fail
+			_ = binary.Write(&body, binary.LittleEndian, field)
+		}
+	}
+	body.Write(stubACMMarker)
+
+	size := int(infoOffset) + body.Len()
+	if int(params.Size) > size {
+		size = int(params.Size)
+	}
+	// the size is stored in dwords
+	size = (size + 3) &^ 3
+	header.Size = uint32(size / 4)
+
+	var acm bytes.Buffer
+	acm.Grow(size)
+	_ = binary.Write(&acm, binary.LittleEndian, &header)
+	acm.Write(make([]byte, stubACMScratchSize*4))
+	acm.Write(body.Bytes())
+	acm.Write(make([]byte, size-acm.Len()))
+	return acm.Bytes()
+}
@@ -0,0 +1,97 @@
+package bg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+func TestNewStubACM(t *testing.T) {
+	params := StubACMParams{
+		ChipsetACMType: tools.ACMChipsetTypeBios,
+		ModuleSubType:  tools.ACMModuleSubtypeCapableOfExecuteAtReset,
+		TxtSVN:         2,
+		SeSVN:          1,
+		Chipsets: []tools.ChipsetID{
+			{VendorID: 0x8086, DeviceID: 0xb00, RevisionID: 1},
+			{VendorID: 0x8086, DeviceID: 0xb01},
+		},
+		Processors: []tools.ProcessorID{
+			{FMS: 0x906e0, FMSMask: 0xfff3ff0, PlatformID: 0x2, PlatformMask: 0x1c},
+		},
+		TPMCapabilities: 0x01,
+		TPMAlgorithms:   []manifest.Algorithm{manifest.AlgSHA1, manifest.AlgSHA256},
+		Size:            0x8001,
+	}
+	data := NewStubACM(params)
+	if len(data) != 0x8004 {
+		t.Errorf("NewStubACM() returned %d bytes, expected the size rounded up to dwords 0x8004", len(data))
+	}
+	if size, err := tools.LookupACMSize(data); err != nil || size != int64(len(data)) {
+		t.Errorf("LookupACMSize() returned %d, %v, expected %d", size, err, len(data))
+	}
+
+	acm, chipsets, processors, tpms, err, err2 := tools.ParseACM(data)
+	if err != nil || err2 != nil {
+		t.Fatalf("ParseACM() failed: %v, %v", err, err2)
+	}
+	if ok, err := tools.ValidateACMHeader(&acm.Header); !ok {
+		t.Errorf("ValidateACMHeader() rejected the stub: %v", err)
+	}
+	if acm.Header.TxtSVN != params.TxtSVN || acm.Header.SeSVN != params.SeSVN || acm.Header.ModuleSubType != params.ModuleSubType {
+		t.Errorf("the stub header has TxtSVN %d, SeSVN %d and ModuleSubType %d, expected %d, %d and %d",
+			acm.Header.TxtSVN, acm.Header.SeSVN, acm.Header.ModuleSubType, params.TxtSVN, params.SeSVN, params.ModuleSubType)
+	}
+	if flags := acm.Header.ParseACMFlags(); flags.Production || !flags.DebugSigned {
+		t.Errorf("the stub is not marked debug signed: %+v", flags)
+	}
+	if acm.Info.ChipsetACMType != params.ChipsetACMType {
+		t.Errorf("the stub has ChipsetACMType %d, expected %d", acm.Info.ChipsetACMType, params.ChipsetACMType)
+	}
+	if !reflect.DeepEqual(chipsets.IDList, params.Chipsets) {
+		t.Errorf("the stub has the chipset IDs %+v, expected %+v", chipsets.IDList, params.Chipsets)
+	}
+	if !reflect.DeepEqual(processors.IDList, params.Processors) {
+		t.Errorf("the stub has the processor IDs %+v, expected %+v", processors.IDList, params.Processors)
+	}
+	if tpms.Capabilities != params.TPMCapabilities {
+		t.Errorf("the stub has the TPM capabilities 0x%x, expected 0x%x", tpms.Capabilities, params.TPMCapabilities)
+	}
+	algs, err := ACMHashAlgorithms(data)
+	if err != nil {
+		t.Fatalf("ACMHashAlgorithms() failed: %v", err)
+	}
+	if !reflect.DeepEqual(algs, params.TPMAlgorithms) {
+		t.Errorf("ACMHashAlgorithms() returned %v, expected %v", algs, params.TPMAlgorithms)
+	}
+	if !bytes.Contains(data, stubACMMarker) {
+		t.Errorf("the stub doesn't contain the non-production marker")
+	}
+	if err := tools.VerifyACMSignature(data, nil); err == nil {
+		t.Errorf("VerifyACMSignature() accepted the signature of the stub")
+	}
+}
+
+func TestNewStubACMMinimal(t *testing.T) {
+	for name, params := range map[string]StubACMParams{
+		"empty-tables": {},
+		"anc-module":   {ModuleSubType: tools.ACMModuleSubtypeAncModule},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := NewStubACM(params)
+			acm, _, _, _, err, err2 := tools.ParseACM(data)
+			if err != nil || err2 != nil {
+				t.Fatalf("ParseACM() failed: %v, %v", err, err2)
+			}
+			if ok, err := tools.ValidateACMHeader(&acm.Header); !ok {
+				t.Errorf("ValidateACMHeader() rejected the stub: %v", err)
+			}
+			if int(acm.Header.Size)*4 != len(data) {
+				t.Errorf("the header size 0x%x doesn't match the %d bytes of the stub", acm.Header.Size*4, len(data))
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package bg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// The verdicts of a test vector.
+const (
+	VerdictPass = "pass"
+	VerdictFail = "fail"
+)
+
+// The files WriteFiles writes for a test vector.
+const (
+	TestVectorBIOSFile        = "bios.bin"
+	TestVectorKMFile          = "km.bin"
+	TestVectorBPMFile         = "bpm.bin"
+	TestVectorACMFile         = "acm.bin"
+	TestVectorDescriptionFile = "vector.json"
+)
+
+// The layout of the 64KiB image of a test vector, as offsets into the image.
+// Everything but the IBB segment lies below the IBB.
+const (
+	testVectorImageSize = 0x10000
+	testVectorKMOffset  = 0x1000
+	testVectorBPMOffset = 0x2000
+	testVectorFITOffset = 0x3000
+	testVectorACMOffset = 0x4000
+	testVectorIBBOffset = 0x8000
+	// testVectorSVN is the BPMSVN, KMSVN and ACMSVNAuth of the manifests
+	// and the SVN of the ACM of a valid image.
+	testVectorSVN = 2
+)
+
+// testVectorOutOfScope are the checks the verdict of a test vector doesn't
+// cover: the stub ACM isn't signed.
+var testVectorOutOfScope = []string{VerifyCheckACMSignature}
+
+// fitHeaderAddress is the address field of the FIT header, "_FIT_   ".
+const fitHeaderAddress = 0x2020205f5449465f
+
+// TestVector is a BIOS image with the KM, BPM and ACM stitched into it and
+// the verdict a verifier has to reach for it. The manifests are signed with
+// ephemeral keys and the ACM is a stub, thus a vector is self-contained and
+// only meant to test verifiers.
+type TestVector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Verdict     string `json:"verdict"`
+	// FailedChecks are the checks of VerifyAll which fail for the image.
+	FailedChecks []string `json:"failed_checks,omitempty"`
+	// OutOfScope are the checks the verdict doesn't cover and a verifier
+	// has to skip, e.g. the signature of the stub ACM.
+	OutOfScope []string `json:"out_of_scope"`
+	// OEMKeyHash is the hex encoded hash of the KM signing key, i.e. the OEM
+	// key hash fused into a platform the image is built for.
+	OEMKeyHash string `json:"oem_key_hash"`
+
+	BIOS []byte `json:"-"`
+	KM   []byte `json:"-"`
+	BPM  []byte `json:"-"`
+	ACM  []byte `json:"-"`
+}
+
+// GenerateTestVectors returns a valid image and images failing exactly one
+// check of VerifyAll each: a wrong BPM signature, a stale IBB digest, a BPM
+// signed with a key the KM doesn't hold the hash of and an ACM with an SVN
+// below the ACMSVNAuth of the BPM. New keys are generated for every call.
+func GenerateTestVectors() ([]*TestVector, error) {
+	var keys [3]*rsa.PrivateKey
+	for idx := range keys {
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate a key: %w", err)
+		}
+		keys[idx] = k
+	}
+	kmKey, bpmKey, otherKey := keys[0], keys[1], keys[2]
+
+	valid, err := buildTestVector(kmKey, bpmKey, bpmKey, testVectorSVN)
+	if err != nil {
+		return nil, err
+	}
+	valid.Name = "valid"
+	valid.Description = "A validly signed image with matching IBB digest, KM and BPM keys and ACM SVN."
+	valid.Verdict = VerdictPass
+
+	wrongSignature := valid.modified(testVectorBPMOffset+len(valid.BPM)-1, func(v *TestVector) {
+		v.BPM = append([]byte{}, v.BPM...)
+		v.BPM[len(v.BPM)-1] ^= 0x01
+	})
+	wrongSignature.Name = "wrong-signature"
+	wrongSignature.Description = "The last byte of the BPM signature is flipped, the signature doesn't verify."
+	wrongSignature.Verdict = VerdictFail
+	wrongSignature.FailedChecks = []string{"bpm-signature"}
+
+	staleDigest := valid.modified(testVectorIBBOffset+0x100, nil)
+	staleDigest.Name = "stale-ibb-digest"
+	staleDigest.Description = "A byte of the IBB was modified after the BPM was signed, the IBB digest of the BPM is stale."
+	staleDigest.Verdict = VerdictFail
+	staleDigest.FailedChecks = []string{fmt.Sprintf("se[0]-ibb-digest-%s", manifest.AlgSHA256)}
+
+	mismatched, err := buildTestVector(kmKey, bpmKey, otherKey, testVectorSVN)
+	if err != nil {
+		return nil, err
+	}
+	mismatched.Name = "mismatched-km-bpm"
+	mismatched.Description = "The BPM is validly signed, but with another key than the one whose hash the KM holds."
+	mismatched.Verdict = VerdictFail
+	mismatched.FailedChecks = []string{"km-bpm-key"}
+
+	expired, err := buildTestVector(kmKey, bpmKey, bpmKey, testVectorSVN-1)
+	if err != nil {
+		return nil, err
+	}
+	expired.Name = "expired-svn"
+	expired.Description = fmt.Sprintf("The SVN %d of the ACM is below the ACMSVNAuth %d of the BPM.", testVectorSVN-1, testVectorSVN)
+	expired.Verdict = VerdictFail
+	expired.FailedChecks = []string{"acm-svn"}
+
+	return []*TestVector{valid, wrongSignature, staleDigest, mismatched, expired}, nil
+}
+
+// modified returns a copy of v with the byte at offset of the image flipped.
+// If the byte belongs to a structure of the vector, update flips it there.
+func (v *TestVector) modified(offset int, update func(v *TestVector)) *TestVector {
+	m := *v
+	m.BIOS = append([]byte{}, v.BIOS...)
+	m.BIOS[offset] ^= 0x01
+	if update != nil {
+		update(&m)
+	}
+	return &m
+}
+
+// buildTestVector returns an image whose KM is signed with kmKey and holds
+// the hash of the public key of kmBPMKey, whose BPM is signed with bpmKey and
+// whose stub ACM has the SVN acmSVN.
+func buildTestVector(kmKey, kmBPMKey, bpmKey *rsa.PrivateKey, acmSVN uint16) (*TestVector, error) {
+	image := bytes.Repeat([]byte{0xff}, testVectorImageSize)
+	base := tools.FourGiB - uint64(len(image))
+	for idx := testVectorIBBOffset; idx < len(image); idx++ {
+		image[idx] = byte(idx)
+	}
+	// the FIT pointer is part of the IBB
+	binary.LittleEndian.PutUint32(image[len(image)-0x40:], uint32(base+testVectorFITOffset))
+
+	km, err := signTestVectorKM(kmKey, kmBPMKey.Public())
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign the KM: %w", err)
+	}
+	bpm, err := generateTestVectorBPM(image, bpmKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate the BPM: %w", err)
+	}
+	acm := NewStubACM(StubACMParams{
+		ChipsetACMType: tools.ACMChipsetTypeBios,
+		TxtSVN:         acmSVN,
+		TPMAlgorithms:  []manifest.Algorithm{manifest.AlgSHA256},
+	})
+	fit := []tools.FitEntry{
+		// the size of the header is the number of FIT entries
+		testVectorFITEntry(fitHeaderAddress, tools.FitHeader, 4),
+		testVectorFITEntry(base+testVectorACMOffset, tools.StartUpACMod, 0),
+		testVectorFITEntry(base+testVectorKMOffset, tools.KeyManifestRec, len(km)),
+		testVectorFITEntry(base+testVectorBPMOffset, tools.BootPolicyManifest, len(bpm)),
+	}
+	var fitData bytes.Buffer
+	if err := binary.Write(&fitData, binary.LittleEndian, fit); err != nil {
+		return nil, err
+	}
+	for _, region := range []struct {
+		name   string
+		offset int
+		end    int
+		data   []byte
+	}{
+		{"KM", testVectorKMOffset, testVectorBPMOffset, km},
+		{"BPM", testVectorBPMOffset, testVectorFITOffset, bpm},
+		{"FIT", testVectorFITOffset, testVectorACMOffset, fitData.Bytes()},
+		{"ACM", testVectorACMOffset, testVectorIBBOffset, acm},
+	} {
+		if len(region.data) > region.end-region.offset {
+			return nil, fmt.Errorf("%w: %s of %d bytes exceeds its region of %d bytes",
+				ErrSizeMismatch, region.name, len(region.data), region.end-region.offset)
+		}
+		copy(image[region.offset:], region.data)
+	}
+
+	var kmPubKey manifest.Key
+	if err := kmPubKey.SetPubKey(kmKey.Public()); err != nil {
+		return nil, err
+	}
+	oemKeyHash, err := kmPubKey.KMPubKeyHash(manifest.AlgSHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &TestVector{
+		OutOfScope: testVectorOutOfScope,
+		OEMKeyHash: hex.EncodeToString(oemKeyHash),
+		BIOS:       image,
+		KM:         km,
+		BPM:        bpm,
+		ACM:        acm,
+	}, nil
+}
+
+func testVectorFITEntry(addr uint64, typ tools.FitEntryType, size int) tools.FitEntry {
+	return tools.FitEntry{
+		Address:  addr,
+		OrigSize: [3]uint8{uint8(size), uint8(size >> 8), uint8(size >> 16)},
+		Version:  0x0100,
+		CVType:   uint8(typ),
+	}
+}
+
+// signTestVectorKM returns a KM signed with kmKey which holds the hash of
+// bpmPubKey.
+func signTestVectorKM(kmKey *rsa.PrivateKey, bpmPubKey crypto.PublicKey) ([]byte, error) {
+	var bpmKey manifest.Key
+	if err := bpmKey.SetPubKey(bpmPubKey); err != nil {
+		return nil, err
+	}
+	bpmKeyHash, err := bpmKey.BPMPubKeyHash(manifest.AlgSHA256)
+	if err != nil {
+		return nil, err
+	}
+	km := key.NewManifest()
+	km.KMSVN = testVectorSVN
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	km.Hash = []key.Hash{{
+		Usage:  key.UsageBPMSigningPKD,
+		Digest: manifest.HashStructure{HashAlg: manifest.AlgSHA256, HashBuffer: bpmKeyHash},
+	}}
+	if err := km.KeyAndSignature.Key.SetPubKey(kmKey.Public()); err != nil {
+		return nil, err
+	}
+	km.RehashRecursive()
+	unsigned, err := WriteKM(km)
+	if err != nil {
+		return nil, err
+	}
+	if err := km.SetSignature(0, kmKey, unsigned[:km.KeyAndSignatureOffset()]); err != nil {
+		return nil, err
+	}
+	return WriteKM(km)
+}
+
+// generateTestVectorBPM returns a BPM signed with bpmKey whose IBB is the
+// end of the image from testVectorIBBOffset on.
+func generateTestVectorBPM(image []byte, bpmKey *rsa.PrivateKey) ([]byte, error) {
+	se := bootpolicy.NewSE()
+	se.IBBEntryPoint = 0xfffffff0
+	segment := bootpolicy.NewIBBSegment()
+	segment.Base = uint32(tools.FourGiB - uint64(len(image)) + testVectorIBBOffset)
+	segment.Size = uint32(len(image) - testVectorIBBOffset)
+	se.IBBSegments = []bootpolicy.IBBSegment{*segment}
+	se.DigestList.List = []manifest.HashStructure{{HashAlg: manifest.AlgSHA256}}
+
+	bgo := &BootGuardOptions{}
+	bgo.BootPolicyManifest.BPMSVN = testVectorSVN
+	bgo.BootPolicyManifest.ACMSVNAuth = testVectorSVN
+	// 64KiB hold the IBB
+	bgo.BootPolicyManifest.NEMDataStack = 0x10
+	bgo.BootPolicyManifest.SE = []bootpolicy.SE{*se}
+	bpm, err := GenerateBPMFromImage(bgo, image)
+	if err != nil {
+		return nil, err
+	}
+
+	pmse := bootpolicy.NewSignature()
+	if err := pmse.Key.SetPubKey(bpmKey.Public()); err != nil {
+		return nil, err
+	}
+	bpm.PMSE = *pmse
+	bpm.RehashRecursive()
+	unsigned, err := WriteBPM(bpm)
+	if err != nil {
+		return nil, err
+	}
+	if err := bpm.PMSE.Signature.SetSignature(0, bpmKey, unsigned[:bpm.KeySignatureOffset]); err != nil {
+		return nil, err
+	}
+	return WriteBPM(bpm)
+}
+
+// WriteJSON writes the description of the test vector as indented JSON.
+func (v *TestVector) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// WriteFiles writes the image, the KM, BPM and ACM and the description of
+// the test vector into the directory of its name below dir.
+func (v *TestVector) WriteFiles(dir string) error {
+	dir = filepath.Join(dir, v.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{TestVectorBIOSFile, v.BIOS},
+		{TestVectorKMFile, v.KM},
+		{TestVectorBPMFile, v.BPM},
+		{TestVectorACMFile, v.ACM},
+	} {
+		if err := WriteFileAtomic(filepath.Join(dir, f.name), f.data, 0644); err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomicFunc(filepath.Join(dir, TestVectorDescriptionFile), 0644, func(f *os.File) error {
+		return v.WriteJSON(f)
+	})
+}
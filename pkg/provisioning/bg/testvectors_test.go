@@ -0,0 +1,80 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateTestVectors(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	names := map[string]bool{}
+	for _, v := range vectors {
+		names[v.Name] = true
+		t.Run(v.Name, func(t *testing.T) {
+			oemKeyHash, err := hex.DecodeString(v.OEMKeyHash)
+			if err != nil {
+				t.Fatalf("invalid OEM key hash: %v", err)
+			}
+			// our own verifier reaches the documented verdict
+			r := VerifyAll(v.BIOS, VerifyAllOptions{OEMKeyHash: oemKeyHash, Skip: v.OutOfScope})
+			var failed []string
+			for _, c := range r.Failed() {
+				failed = append(failed, c.Check)
+			}
+			if r.Pass != (v.Verdict == VerdictPass) || !reflect.DeepEqual(failed, v.FailedChecks) {
+				t.Errorf("VerifyAll() failed %v, the vector documents the verdict %s with the failed checks %v",
+					r.Failed(), v.Verdict, v.FailedChecks)
+			}
+		})
+	}
+	for _, name := range []string{"valid", "wrong-signature", "stale-ibb-digest", "mismatched-km-bpm", "expired-svn"} {
+		if !names[name] {
+			t.Errorf("GenerateTestVectors() returned no vector %s", name)
+		}
+	}
+}
+
+func TestTestVectorWriteFiles(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "testvectors")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	v := vectors[1]
+	if err := v.WriteFiles(dir); err != nil {
+		t.Fatalf("WriteFiles() failed: %v", err)
+	}
+	bios, err := ioutil.ReadFile(filepath.Join(dir, v.Name, TestVectorBIOSFile))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.Equal(bios, v.BIOS) {
+		t.Errorf("WriteFiles() wrote another image than the one of the vector")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, v.Name, TestVectorDescriptionFile))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	var desc TestVector
+	if err := json.Unmarshal(data, &desc); err != nil {
+		t.Fatalf("the description doesn't parse: %v", err)
+	}
+	if desc.Name != v.Name || desc.Verdict != VerdictFail || !reflect.DeepEqual(desc.FailedChecks, v.FailedChecks) ||
+		desc.OEMKeyHash != v.OEMKeyHash || !reflect.DeepEqual(desc.OutOfScope, []string{VerifyCheckACMSignature}) {
+		t.Errorf("the description %+v doesn't match the vector", desc)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 
+	"github.com/9elements/converged-security-suite/v2/pkg/coreboot/cbfs"
 	"github.com/9elements/converged-security-suite/v2/pkg/hwapi"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
@@ -19,7 +22,13 @@ import (
 
 // WriteBootGuardStructures takes a firmware image and extracts boot policy manifest, key manifest and acm into seperate files.
 func WriteBootGuardStructures(image []byte, bpmFile, kmFile, acmFile *os.File) error {
-	bpmBuf, kmBuf, acmBuf, err := ParseFITEntries(image)
+	return DefaultParseOptions().WriteBootGuardStructures(image, bpmFile, kmFile, acmFile)
+}
+
+// WriteBootGuardStructures is WriteBootGuardStructures with the options o
+// instead of the DefaultParseOptions.
+func (o ParseOptions) WriteBootGuardStructures(image []byte, bpmFile, kmFile, acmFile *os.File) error {
+	bpmBuf, kmBuf, acmBuf, err := o.ParseFITEntries(image)
 	if err != nil {
 		return err
 	}
@@ -41,8 +50,55 @@ func WriteBootGuardStructures(image []byte, bpmFile, kmFile, acmFile *os.File) e
 	return nil
 }
 
+// BootGuardStructures are the FIT of a firmware image and the structures it
+// references, the structured form of PrintFIT and PrintBootGuardStructures.
+type BootGuardStructures struct {
+	// FIT is nil for images without FIT, e.g. coreboot images holding the
+	// structures in the CBFS only.
+	FIT *FITReport           `json:"fit,omitempty"`
+	KM  *key.Manifest        `json:"km"`
+	BPM *bootpolicy.Manifest `json:"bpm"`
+	ACM *tools.ACMDump       `json:"acm"`
+}
+
+// ReadBootGuardStructures returns the FIT of a firmware image and the KM, BPM
+// and ACM it references.
+func ReadBootGuardStructures(image []byte) (*BootGuardStructures, error) {
+	return DefaultParseOptions().ReadBootGuardStructures(image)
+}
+
+// ReadBootGuardStructures is ReadBootGuardStructures with the options o
+// instead of the DefaultParseOptions.
+func (o ParseOptions) ReadBootGuardStructures(image []byte) (*BootGuardStructures, error) {
+	bpmBuf, kmBuf, acmBuf, err := o.ParseFITEntries(image)
+	if err != nil {
+		return nil, err
+	}
+	s := &BootGuardStructures{}
+	if s.BPM, err = o.ParseBPM(bytes.NewReader(bpmBuf)); err != nil {
+		return nil, err
+	}
+	if s.KM, err = o.ParseKM(bytes.NewReader(kmBuf)); err != nil {
+		return nil, err
+	}
+	if s.ACM, err = tools.NewACMDump(acmBuf); err != nil {
+		return nil, err
+	}
+	if fit, err := o.AnnotateFIT(image); err == nil {
+		s.FIT = fit
+	}
+	return s, nil
+}
+
 // PrintBootGuardStructures takes a firmware image and prints boot policy manifest, key manifest, ACM, chipset, processor and tpm information if available.
+// A warning is issued if the SVN of the ACM is below the ACMSVNAuth of the BPM.
 func PrintBootGuardStructures(image []byte) error {
+	return DefaultParseOptions().PrintBootGuardStructures(image)
+}
+
+// PrintBootGuardStructures is PrintBootGuardStructures with the options o
+// instead of the DefaultParseOptions.
+func (o ParseOptions) PrintBootGuardStructures(image []byte) error {
 	var km *key.Manifest
 	var bpm *bootpolicy.Manifest
 	var acm *tools.ACM
@@ -50,18 +106,18 @@ func PrintBootGuardStructures(image []byte) error {
 	var processors *tools.Processors
 	var tpms *tools.TPMs
 	var err, err2 error
-	bpmBuf, kmBuf, acmBuf, err := ParseFITEntries(image)
+	bpmBuf, kmBuf, acmBuf, err := o.ParseFITEntries(image)
 	if err != nil {
 		return err
 	}
 	reader := bytes.NewReader(bpmBuf)
-	bpm, err = ParseBPM(reader)
+	bpm, err = o.ParseBPM(reader)
 	if err != nil {
 		return err
 	}
 
 	reader = bytes.NewReader(kmBuf)
-	km, err = ParseKM(reader)
+	km, err = o.ParseKM(reader)
 	if err != nil {
 		return err
 	}
@@ -87,28 +143,91 @@ func PrintBootGuardStructures(image []byte) error {
 		processors.PrettyPrint()
 		tpms.PrettyPrint()
 	}
+	if bpm != nil && acm != nil {
+		// the ACM refuses to boot with the BPM, flag it next to the structures
+		if err := CheckACMSVN(bpm, &acm.Header); err != nil {
+			o.warnings().Warnf("%v, the platform won't boot", err)
+		}
+	}
 	return nil
 }
 
 // PrintFIT takes a firmware image and prints the Firmware Interface Table
 func PrintFIT(image []byte) error {
+	return DefaultParseOptions().PrintFIT(image)
+}
+
+// PrintFIT is PrintFIT with the options o instead of the DefaultParseOptions.
+func (o ParseOptions) PrintFIT(image []byte) error {
 	fitEntries, err := tools.ExtractFit(image)
 	if err != nil {
 		return err
 	}
 	fmt.Println("----Firmware Interface Table----")
 	fmt.Println()
+	if status, err := tools.FitTableChecksumStatus(image); err == nil {
+		fmt.Printf("Table checksum: %s\n\n", status)
+	}
 	for idx, entry := range fitEntries {
 		fmt.Printf("Entry %d\n", idx)
 		entry.FancyPrint()
 		fmt.Println()
 	}
+	if err := tools.VerifyFIT(fitEntries, tools.ImageFitMemoryReader(image)); err != nil {
+		o.warnings().Warnf("%v", err)
+	}
+	if err := verifyKMIDs(fitEntries, tools.ImageFitMemoryReader(image)); err != nil {
+		o.warnings().Warnf("%v", err)
+	}
 	fmt.Println()
 	return nil
 }
 
+// CBFS file names coreboot stores the BootGuard structures under.
+const (
+	CBFSBPMName = "boot_policy_manifest.bin"
+	CBFSKMName  = "key_manifest.bin"
+	CBFSACMName = "txt_bios_acm.bin"
+)
+
 // ParseFITEntries takes a firmware image and extract Boot policy manifest, key manifest and acm information.
+// If the FIT doesn't reference them in a coreboot image, they are looked up in the CBFS by file name.
 func ParseFITEntries(image []byte) ([]byte, []byte, []byte, error) {
+	return DefaultParseOptions().ParseFITEntries(image)
+}
+
+// ParseFITEntries extracts the BPM, KM and ACM as the function
+// ParseFITEntries does, mapping the image with the layout of the options.
+func (o ParseOptions) ParseFITEntries(image []byte) ([]byte, []byte, []byte, error) {
+	bpm, km, acm, err := parseFITEntries(image, o.Layout)
+	if err != nil && cbfs.IsCorebootImage(image) {
+		bpm, km, acm, cbfsErr := ParseCBFSEntries(image)
+		if cbfsErr != nil {
+			return nil, nil, nil, fmt.Errorf("%v, and the FIT lookup failed too: %w", cbfsErr, err)
+		}
+		return bpm, km, acm, nil
+	}
+	return bpm, km, acm, err
+}
+
+// ParseCBFSEntries takes a coreboot image and extracts the Boot policy manifest, key manifest and acm
+// from the CBFS files CBFSBPMName, CBFSKMName and CBFSACMName.
+func ParseCBFSEntries(image []byte) ([]byte, []byte, []byte, error) {
+	var bufs [3][]byte
+	for idx, name := range []string{CBFSBPMName, CBFSKMName, CBFSACMName} {
+		f, err := cbfs.Lookup(image, name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if f.Compression != cbfs.CompressionNone {
+			return nil, nil, nil, fmt.Errorf("CBFS file %s is compressed, BootGuard structures must be stored uncompressed", name)
+		}
+		bufs[idx] = f.Data
+	}
+	return bufs[0], bufs[1], bufs[2], nil
+}
+
+func parseFITEntries(image []byte, layout *tools.FlashLayout) ([]byte, []byte, []byte, error) {
 	fitEntries, err := tools.ExtractFit(image)
 	if err != nil {
 		return nil, nil, nil, err
@@ -120,7 +239,7 @@ func ParseFITEntries(image []byte) ([]byte, []byte, []byte, error) {
 			if entry.Size() == 0 {
 				return nil, nil, nil, fmt.Errorf("FIT entry size is zero for BPM")
 			}
-			addr, err := tools.CalcImageOffset(image, entry.Address)
+			addr, err := tools.CalcImageOffsetWithLayout(image, entry.Address, layout)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -135,7 +254,7 @@ func ParseFITEntries(image []byte) ([]byte, []byte, []byte, error) {
 			if entry.Size() == 0 {
 				return nil, nil, nil, fmt.Errorf("FIT entry size is zero for KM")
 			}
-			addr, err := tools.CalcImageOffset(image, entry.Address)
+			addr, err := tools.CalcImageOffsetWithLayout(image, entry.Address, layout)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -147,7 +266,7 @@ func ParseFITEntries(image []byte) ([]byte, []byte, []byte, error) {
 			}
 		}
 		if entry.Type() == tools.StartUpACMod {
-			addr, err := tools.CalcImageOffset(image, entry.Address)
+			addr, err := tools.CalcImageOffsetWithLayout(image, entry.Address, layout)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -180,94 +299,99 @@ func ParseFITEntries(image []byte) ([]byte, []byte, []byte, error) {
 	if len(bpm) == 0 || len(km) == 0 || len(acm) == 0 {
 		return nil, nil, nil, fmt.Errorf("Image has no BPM, KM, ACM")
 	}
+	// the FIT may point to an ACM stored compressed in a firmware file section
+	acm, err = tools.DecompressACM(acm)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to read ACM: %w", err)
+	}
 	return bpm, km, acm, nil
 }
 
-func generatePCR0Content(status uint64, km *key.Manifest, bpm *bootpolicy.Manifest, acm *tools.ACM) (*Pcr0Data, []byte, error) {
-	var err error
-	var pcr0 Pcr0Data
-	buf := new(bytes.Buffer)
-	if err = binary.Write(buf, binary.BigEndian, status); err != nil {
-		return nil, nil, err
+// NewPcr0Data returns the data the S-ACM hashes into PCR0 of the bank of
+// hash algorithm bankAlg: the ACM policy status, the ACM SVN and signature,
+// the KM and BPM signatures and the IBB digests of bankAlg.
+func NewPcr0Data(status uint64, km *key.Manifest, bpm *bootpolicy.Manifest, acm *tools.ACM, bankAlg manifest.Algorithm) (*Pcr0Data, error) {
+	pcr0 := &Pcr0Data{
+		ACMPolicyStatus: status,
+		ACMSVN:          acm.Header.TxtSVN,
+		ACMSignature:    acm.Header.Signature[:],
 	}
-	fmt.Printf("\nStatus: 0x%x\n ", status)
-	if err = binary.Write(buf, binary.LittleEndian, acm.Header.TxtSVN); err != nil {
-		return nil, nil, err
+	var err error
+	if pcr0.KMSignature, err = measuredSignature(&km.KeyAndSignature.Signature); err != nil {
+		return nil, fmt.Errorf("unable to extract KM signature: %w", err)
 	}
-	fmt.Printf("ACM SVN: 0x%x\n ", acm.Header.TxtSVN)
-	if err = binary.Write(buf, binary.LittleEndian, acm.Header.Signature); err != nil {
-		return nil, nil, err
+	if pcr0.BPMSignature, err = measuredSignature(&bpm.PMSE.KeySignature.Signature); err != nil {
+		return nil, fmt.Errorf("unable to extract BPM signature: %w", err)
 	}
-	fmt.Printf("ACM Sig: 0x%x\n ", acm.Header.Signature)
-
-	{
-		kmSignature, err := km.KeyAndSignature.Signature.SignatureData()
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to extract BPM signature: %w", err)
-		}
-		fmt.Printf("KM Sig: %s\n", kmSignature.String())
-		switch kmSignature := kmSignature.(type) {
-		case manifest.SignatureRSAASA:
-			if err = binary.Write(buf, binary.LittleEndian, kmSignature); err != nil {
-				return nil, nil, err
-			}
-		case manifest.SignatureECDSA:
-			if err = binary.Write(buf, binary.LittleEndian, kmSignature.R); err != nil {
-				return nil, nil, err
-			}
-		case manifest.SignatureSM2:
-			if err = binary.Write(buf, binary.LittleEndian, kmSignature.R); err != nil {
-				return nil, nil, err
+	for _, se := range bpm.SE {
+		for _, d := range se.DigestList.List {
+			if d.HashAlg == bankAlg {
+				pcr0.BPMIBBDigest = append(pcr0.BPMIBBDigest, d.HashBuffer...)
 			}
-		default:
-			return nil, nil, fmt.Errorf("unknown KM sig type: %T", kmSignature)
 		}
 	}
+	return pcr0, nil
+}
 
-	{
-		bpmSignature, err := bpm.PMSE.KeySignature.Signature.SignatureData()
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to extract BPM signature: %w", err)
-		}
-		fmt.Printf("BPM Sig: %s\n", bpmSignature.String())
-		switch bpmSignature := bpmSignature.(type) {
-		case manifest.SignatureRSAASA:
-			if err = binary.Write(buf, binary.LittleEndian, bpmSignature); err != nil {
-				return nil, nil, err
-			}
-		case manifest.SignatureECDSA:
-			if err = binary.Write(buf, binary.LittleEndian, bpmSignature.R); err != nil {
-				return nil, nil, err
-			}
-		case manifest.SignatureSM2:
-			if err = binary.Write(buf, binary.LittleEndian, bpmSignature.R); err != nil {
-				return nil, nil, err
-			}
-		default:
-			return nil, nil, fmt.Errorf("unknown BPM sig type: %T", bpmSignature)
-		}
+// measuredSignature returns the part of a manifest signature the S-ACM
+// measures: the whole RSA signature, the R component of ECDSA and SM2 ones.
+// R is of the width of the curve, including leading zero bytes.
+func measuredSignature(sig *manifest.Signature) ([]byte, error) {
+	data, err := sig.SignatureData()
+	if err != nil {
+		return nil, err
 	}
+	switch data := data.(type) {
+	case manifest.SignatureRSAASA:
+		return data, nil
+	case manifest.SignatureECDSA:
+		return bigIntBytes(data.R, len(sig.Data)/2), nil
+	case manifest.SignatureSM2:
+		return bigIntBytes(data.R, len(sig.Data)/2), nil
+	}
+	return nil, fmt.Errorf("unknown sig type: %T", data)
+}
 
-	for _, se := range bpm.SE {
-		for i := 0; i < len(se.DigestList.List); i++ {
-			if se.DigestList.List[i].HashAlg == manifest.AlgSHA1 {
-				if err = binary.Write(buf, binary.LittleEndian, se.DigestList.List[i].HashBuffer); err != nil {
-					return nil, nil, err
-				}
-				fmt.Printf("IBB Hash: 0x%x\n ", se.DigestList.List[i].HashBuffer)
-			}
-		}
+// bigIntBytes returns x as big endian bytes left padded with zeros to size.
+func bigIntBytes(x *big.Int, size int) []byte {
+	b := x.Bytes()
+	if len(b) >= size {
+		return b
 	}
+	return append(make([]byte, size-len(b)), b...)
+}
 
+// Bytes returns the serialized data hashed into PCR0.
+func (d *Pcr0Data) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	// writes to a bytes.Buffer of fixed size data don't fail
+	_ = binary.Write(buf, binary.BigEndian, d.ACMPolicyStatus)
+	_ = binary.Write(buf, binary.LittleEndian, d.ACMSVN)
+	buf.Write(d.ACMSignature)
+	buf.Write(d.KMSignature)
+	buf.Write(d.BPMSignature)
+	buf.Write(d.BPMIBBDigest)
+	return buf.Bytes()
+}
+
+func generatePCR0Content(status uint64, km *key.Manifest, bpm *bootpolicy.Manifest, acm *tools.ACM) (*Pcr0Data, []byte, error) {
+	pcr0, err := NewPcr0Data(status, km, bpm, acm, manifest.AlgSHA1)
+	if err != nil {
+		return nil, nil, err
+	}
 	h := sha1.New()
-	h.Write(buf.Bytes())
-	finalHash := h.Sum(nil)
-	fmt.Printf("PCR-0 pre hash: 0x%x\n", finalHash)
-	return &pcr0, finalHash, nil
+	h.Write(pcr0.Bytes())
+	return pcr0, h.Sum(nil), nil
 }
 
 // PrecalcPCR0 takes a firmware image and ACM Policy status and returns the Pcr0Data structure and its hash.
 func PrecalcPCR0(data []byte, acmPolicySts uint64) (*Pcr0Data, []byte, error) {
+	return DefaultParseOptions().PrecalcPCR0(data, acmPolicySts)
+}
+
+// PrecalcPCR0 is PrecalcPCR0 with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) PrecalcPCR0(data []byte, acmPolicySts uint64) (*Pcr0Data, []byte, error) {
 	fitEntries, err := tools.ExtractFit(data)
 	if err != nil {
 		return nil, nil, err
@@ -277,31 +401,31 @@ func PrecalcPCR0(data []byte, acmPolicySts uint64) (*Pcr0Data, []byte, error) {
 	var acm *tools.ACM
 	for _, entry := range fitEntries {
 		if entry.Type() == tools.BootPolicyManifest {
-			addr, err := tools.CalcImageOffset(data, entry.Address)
+			addr, err := tools.CalcImageOffsetWithLayout(data, entry.Address, o.Layout)
 			if err != nil {
 				return nil, nil, err
 			}
 			reader := bytes.NewReader(data)
 			reader.Seek(int64(addr), io.SeekStart)
-			bpm, err = ParseBPM(reader)
+			bpm, err = o.ParseBPM(reader)
 			if err != nil {
 				return nil, nil, err
 			}
 		}
 		if entry.Type() == tools.KeyManifestRec {
-			addr, err := tools.CalcImageOffset(data, entry.Address)
+			addr, err := tools.CalcImageOffsetWithLayout(data, entry.Address, o.Layout)
 			if err != nil {
 				return nil, nil, err
 			}
 			reader := bytes.NewReader(data)
 			reader.Seek(int64(addr), io.SeekStart)
-			km, err = ParseKM(reader)
+			km, err = o.ParseKM(reader)
 			if err != nil {
 				return nil, nil, err
 			}
 		}
 		if entry.Type() == tools.StartUpACMod {
-			addr, err := tools.CalcImageOffset(data, entry.Address)
+			addr, err := tools.CalcImageOffsetWithLayout(data, entry.Address, o.Layout)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -375,114 +499,185 @@ func CalculateNEMSize(image []byte, bpm *bootpolicy.Manifest, km *key.Manifest,
 	return bootpolicy.NewSize4K(totalSize), nil
 }
 
+// StitchOptions select how AssembleProvisionedBIOS writes the structures.
+type StitchOptions struct {
+	// Fill fills the remainder of the KM and BPM regions with FillByte after
+	// writing a KM or BPM smaller than the region, so no bytes of the previous
+	// structure are left behind. Without it only the structures are written.
+	Fill bool
+	// FillByte is usually 0xFF, as SPI flash reads after an erase.
+	FillByte byte
+	// Layout places images without flash descriptor in the flash, see
+	// tools.CalcImageOffsetWithLayout.
+	Layout *tools.FlashLayout
+}
+
 // StitchFITEntries takes a firmware filename, an acm, a boot policy manifest and a key manifest as byte slices
 // and writes the information into the Firmware Interface Table of the firmware image.
 func StitchFITEntries(biosFilename string, acm, bpm, km []byte) error {
+	return DefaultParseOptions().StitchFITEntries(biosFilename, acm, bpm, km)
+}
+
+// StitchFITEntries is StitchFITEntries with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) StitchFITEntries(biosFilename string, acm, bpm, km []byte) error {
 	image, err := ioutil.ReadFile(biosFilename)
 	if err != nil {
 		return err
 	}
-	fitEntries, err := tools.ExtractFit(image)
+	info, err := os.Stat(biosFilename)
 	if err != nil {
 		return err
 	}
-	file, err := os.OpenFile(biosFilename, os.O_RDWR, 0600)
+	stitched, err := AssembleProvisionedBIOS(image, acm, km, bpm, StitchOptions{Layout: o.Layout})
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	for _, entry := range fitEntries {
-		if entry.Type() == tools.BootPolicyManifest {
+	// Stitch into a copy of the image, so a failure leaves the original intact
+	return WriteFileAtomic(biosFilename, stitched, info.Mode().Perm())
+}
+
+// AssembleProvisionedBIOS returns a copy of the firmware image with the acm, km
+// and bpm written to the places the FIT points to, the in-memory counterpart
+// of StitchFITEntries. Empty structures are left as they are in the image,
+// the remainder of the KM and BPM regions is filled as opts select.
+func AssembleProvisionedBIOS(biosData []byte, acm, km, bpm []byte, opts StitchOptions) ([]byte, error) {
+	fitEntries, err := tools.ExtractFit(biosData)
+	if err != nil {
+		return nil, err
+	}
+	return stitchFIT(biosData, fitEntries, func(addr uint64) (uint64, error) {
+		return tools.CalcImageOffsetWithLayout(biosData, addr, opts.Layout)
+	}, acm, km, bpm, opts)
+}
+
+// stitchFIT writes the structures into a copy of the image, imageOffset maps
+// the addresses of the FIT entries to image offsets. The existing entries are
+// updated, of a KM entry for each KM ID the one with the KM ID of km. Images
+// with duplicated entries of unique types or several KMs with the same KM ID
+// are refused.
+func stitchFIT(biosData []byte, fitEntries []tools.FitEntry, imageOffset func(uint64) (uint64, error), acm, km, bpm []byte, opts StitchOptions) ([]byte, error) {
+	// with duplicated entries it's undefined which one the platform uses
+	if err := tools.VerifyFIT(fitEntries, nil); errors.Is(err, tools.ErrFITDuplicateEntry) {
+		return nil, fmt.Errorf("refusing to stitch: %w", err)
+	}
+	read := fitMemoryReader(biosData, imageOffset)
+	if err := verifyKMIDs(fitEntries, read); err != nil {
+		return nil, fmt.Errorf("refusing to stitch: %w", err)
+	}
+	kmEntry := -1
+	if len(km) > 0 {
+		var err error
+		if kmEntry, err = stitchedKMEntry(fitEntries, read, km); err != nil {
+			return nil, fmt.Errorf("refusing to stitch: %w", err)
+		}
+	}
+	image := append([]byte{}, biosData...)
+	regionOffset := func(name string, addr uint64, size int) (uint64, error) {
+		off, err := imageOffset(addr)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s at 0x%x: %v", ErrOutOfBounds, name, addr, err)
+		}
+		if off > uint64(len(image)) || uint64(size) > uint64(len(image))-off {
+			return 0, fmt.Errorf("%w: %s of %d bytes at address 0x%x, offset 0x%x exceeds the image of %d bytes",
+				ErrOutOfBounds, name, size, addr, off, len(image))
+		}
+		return off, nil
+	}
+	for idx, entry := range fitEntries {
+		switch entry.Type() {
+		case tools.BootPolicyManifest:
 			if len(bpm) <= 0 {
 				continue
 			}
 			if entry.Size() == 0 {
-				return fmt.Errorf("FIT entry size is zero for BPM")
+				return nil, fmt.Errorf("FIT entry size is zero for BPM")
 			}
 			if len(bpm) > int(entry.Size()) {
-				return fmt.Errorf("new BPM bigger than older BPM")
-			}
-			addr, err := tools.CalcImageOffset(image, entry.Address)
-			if err != nil {
-				return err
+				return nil, fmt.Errorf("%w: new BPM of %d bytes is bigger than the FIT entry of %d bytes", ErrSizeMismatch, len(bpm), entry.Size())
 			}
-			_, err = file.Seek(0, 0)
+			off, err := regionOffset("BPM", entry.Address, int(entry.Size()))
 			if err != nil {
-				return err
+				return nil, err
 			}
-			size, err := file.WriteAt(bpm, int64(addr))
-			if err != nil {
-				return err
-			}
-			if size != len(bpm) {
-				return fmt.Errorf("couldn't write new BPM")
-			}
-		}
-		if entry.Type() == tools.KeyManifestRec {
-			if len(km) <= 0 {
+			writeRegion(image[off:off+uint64(entry.Size())], bpm, opts)
+		case tools.KeyManifestRec:
+			if len(km) <= 0 || idx != kmEntry {
 				continue
 			}
 			if entry.Size() == 0 {
-				return fmt.Errorf("FIT entry size is zero for KM")
+				return nil, fmt.Errorf("FIT entry size is zero for KM")
 			}
 			if len(km) > int(entry.Size()) {
-				return fmt.Errorf("new KM bigger than older KM")
+				return nil, fmt.Errorf("%w: new KM of %d bytes is bigger than the FIT entry of %d bytes", ErrSizeMismatch, len(km), entry.Size())
 			}
-			addr, err := tools.CalcImageOffset(image, entry.Address)
+			off, err := regionOffset("KM", entry.Address, int(entry.Size()))
 			if err != nil {
-				return err
-			}
-			_, err = file.Seek(0, 0)
-			if err != nil {
-				return err
-			}
-			size, err := file.WriteAt(km, int64(addr))
-			if err != nil {
-				return err
-			}
-			if size != len(km) {
-				return fmt.Errorf("couldn't write new KM")
+				return nil, err
 			}
-		}
-		if entry.Type() == tools.StartUpACMod {
+			writeRegion(image[off:off+uint64(entry.Size())], km, opts)
+		case tools.StartUpACMod:
 			if len(acm) <= 0 {
 				continue
 			}
-			addr, err := tools.CalcImageOffset(image, entry.Address)
-			if err != nil {
-				return err
-			}
-			_, err = file.Seek(int64(addr), io.SeekStart)
+			off, err := regionOffset("ACM header", entry.Address, 32)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			acmHeader := make([]byte, 32)
-			_, err = file.Read(acmHeader)
+			acmLen, err := tools.LookupACMSize(biosData[off : off+32])
 			if err != nil {
-				return err
-			}
-			acmLen, err := tools.LookupACMSize(acmHeader)
-			if err != nil {
-				return err
+				return nil, err
 			}
 			if acmLen == 0 {
-				return fmt.Errorf("ACM size is wrong")
+				return nil, fmt.Errorf("ACM size is wrong")
 			}
 			if len(acm) != int(acmLen) {
-				return fmt.Errorf("new ACM size doesn't equal old ACM size")
-			}
-			_, err = file.Seek(0, 0)
-			if err != nil {
-				return err
+				return nil, fmt.Errorf("%w: new ACM of %d bytes, the ACM in the image has %d bytes", ErrSizeMismatch, len(acm), acmLen)
 			}
-			size, err := file.WriteAt(acm, int64(addr))
-			if err != nil {
-				return err
-			}
-			if size != len(acm) {
-				return fmt.Errorf("couldn't write new ACM")
+			if off, err = regionOffset("ACM", entry.Address, len(acm)); err != nil {
+				return nil, err
 			}
+			copy(image[off:], acm)
 		}
 	}
-	return nil
+	return image, nil
+}
+
+// stitchedKMEntry returns the index of the KM entry km replaces: the only KM
+// entry, or of several ones, one for each KM ID, the entry of the KM with the
+// KM ID of km. It returns -1 if the FIT holds no KM entry.
+func stitchedKMEntry(fitEntries []tools.FitEntry, read tools.FitMemoryReader, km []byte) (int, error) {
+	var kmEntries []int
+	for idx, entry := range fitEntries {
+		if entry.Type() == tools.KeyManifestRec {
+			kmEntries = append(kmEntries, idx)
+		}
+	}
+	switch len(kmEntries) {
+	case 0:
+		return -1, nil
+	case 1:
+		return kmEntries[0], nil
+	}
+	newKM, err := ParseKM(bytes.NewReader(km))
+	if err != nil {
+		return -1, fmt.Errorf("the FIT holds %d KM entries, the KM ID of the new KM is required to select one: %w", len(kmEntries), err)
+	}
+	idx := kmEntriesByID(fitEntries, read)[newKM.KMID]
+	if len(idx) == 0 {
+		return -1, fmt.Errorf("none of the %d KM entries of the FIT holds a KM with KM ID %d", len(kmEntries), newKM.KMID)
+	}
+	return idx[0], nil
+}
+
+// writeRegion writes data to the start of region and, if opts.Fill is set,
+// fills the rest with opts.FillByte.
+func writeRegion(region, data []byte, opts StitchOptions) {
+	n := copy(region, data)
+	if !opts.Fill {
+		return
+	}
+	for idx := n; idx < len(region); idx++ {
+		region[idx] = opts.FillByte
+	}
 }
@@ -0,0 +1,391 @@
+package bg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+func fitEntry(typ tools.FitEntryType, addr uint64, size uint32) tools.FitEntry {
+	e := tools.FitEntry{Address: addr, CVType: uint8(typ)}
+	e.OrigSize = [3]uint8{uint8(size), uint8(size >> 8), uint8(size >> 16)}
+	return e
+}
+
+// testKMWithID returns an unsigned KM with the KM ID id.
+func testKMWithID(t *testing.T, id uint8) []byte {
+	km := key.NewManifest()
+	km.KMID = id
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	km.RehashRecursive()
+	data, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	return data
+}
+
+func TestStitchFIT(t *testing.T) {
+	const base = tools.FourGiB - 0x1000
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	// the ACM header at 0x800 declares 0x100 bytes
+	binary.LittleEndian.PutUint32(image[0x800+tools.ACMSizeOffset:], 0x100/4)
+	entries := []tools.FitEntry{
+		fitEntry(tools.StartUpACMod, base+0x800, 0),
+		fitEntry(tools.KeyManifestRec, base+0x100, 0x40),
+		fitEntry(tools.BootPolicyManifest, base+0x200, 0x80),
+	}
+	imageOffset := func(addr uint64) (uint64, error) {
+		return addr - base, nil
+	}
+	acm := bytes.Repeat([]byte{0xac}, 0x100)
+	km := bytes.Repeat([]byte{0x4b}, 0x40)
+	bpm := bytes.Repeat([]byte{0xb0}, 0x60)
+
+	stitched, err := stitchFIT(image, entries, imageOffset, acm, km, bpm, StitchOptions{})
+	if err != nil {
+		t.Fatalf("stitchFIT() failed: %v", err)
+	}
+	expected := append([]byte{}, image...)
+	copy(expected[0x800:], acm)
+	copy(expected[0x100:], km)
+	copy(expected[0x200:], bpm)
+	if !bytes.Equal(stitched, expected) {
+		t.Errorf("stitchFIT() didn't write the structures to the FIT addresses")
+	}
+	if image[0x100] != 0xff {
+		t.Errorf("stitchFIT() modified the input image")
+	}
+
+	if _, err := stitchFIT(image, entries, imageOffset, nil, nil, make([]byte, 0x81), StitchOptions{}); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("stitchFIT() with a BPM bigger than the FIT entry returned %v, expected %v", err, ErrSizeMismatch)
+	}
+	if _, err := stitchFIT(image, entries, imageOffset, acm[:0x80], nil, nil, StitchOptions{}); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("stitchFIT() with an ACM of another size returned %v, expected %v", err, ErrSizeMismatch)
+	}
+	duplicated := append(append([]tools.FitEntry{}, entries...), fitEntry(tools.BootPolicyManifest, base+0x300, 0x80))
+	if _, err := stitchFIT(image, duplicated, imageOffset, nil, nil, bpm, StitchOptions{}); !errors.Is(err, tools.ErrFITDuplicateEntry) {
+		t.Errorf("stitchFIT() with a duplicated BPM entry returned %v, expected %v", err, tools.ErrFITDuplicateEntry)
+	}
+}
+
+func TestStitchFITSeveralKMs(t *testing.T) {
+	const base = tools.FourGiB - 0x1000
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	copy(image[0x100:], testKMWithID(t, 1))
+	copy(image[0x300:], testKMWithID(t, 2))
+	entries := []tools.FitEntry{
+		fitEntry(tools.KeyManifestRec, base+0x100, 0x80),
+		fitEntry(tools.KeyManifestRec, base+0x300, 0x80),
+		fitEntry(tools.BootPolicyManifest, base+0x200, 0x80),
+	}
+	imageOffset := func(addr uint64) (uint64, error) {
+		return addr - base, nil
+	}
+
+	bpm := bytes.Repeat([]byte{0xb0}, 0x60)
+	if _, err := stitchFIT(image, entries, imageOffset, nil, nil, bpm, StitchOptions{}); err != nil {
+		t.Errorf("stitchFIT() of a BPM into a FIT with a KM for each KM ID failed: %v", err)
+	}
+	km := testKMWithID(t, 2)
+	km[len(km)-1] ^= 0xff // tell it apart from the KM in the image
+	stitched, err := stitchFIT(image, entries, imageOffset, nil, km, nil, StitchOptions{})
+	if err != nil {
+		t.Fatalf("stitchFIT() of a KM into a FIT with a KM for each KM ID failed: %v", err)
+	}
+	if !bytes.Equal(stitched[0x100:0x180], image[0x100:0x180]) || !bytes.Equal(stitched[0x300:0x300+len(km)], km) {
+		t.Errorf("stitchFIT() didn't replace the KM with the same KM ID")
+	}
+	if _, err := stitchFIT(image, entries, imageOffset, nil, testKMWithID(t, 3), nil, StitchOptions{}); err == nil {
+		t.Errorf("stitchFIT() of a KM with a KM ID missing in the FIT succeeded")
+	}
+
+	copy(image[0x300:], testKMWithID(t, 1))
+	if _, err := stitchFIT(image, entries, imageOffset, nil, nil, bpm, StitchOptions{}); !errors.Is(err, ErrDuplicateKMID) {
+		t.Errorf("stitchFIT() into a FIT with two KMs with the same KM ID returned %v, expected %v", err, ErrDuplicateKMID)
+	}
+}
+
+func TestStitchFITOutOfBounds(t *testing.T) {
+	const base = tools.FourGiB - 0x1000
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	imageOffset := func(addr uint64) (uint64, error) {
+		return addr - base, nil
+	}
+	km := bytes.Repeat([]byte{0x4b}, 0x40)
+	for name, tc := range map[string]struct {
+		entry tools.FitEntry
+		acm   []byte
+		km    []byte
+	}{
+		"km-crossing-the-end": {entry: fitEntry(tools.KeyManifestRec, base+0xfe0, 0x40), km: km},
+		"km-beyond-the-end":   {entry: fitEntry(tools.KeyManifestRec, base+0x2000, 0x40), km: km},
+		"km-below-the-image":  {entry: fitEntry(tools.KeyManifestRec, base-0x1000, 0x40), km: km},
+		"acm-beyond-the-end":  {entry: fitEntry(tools.StartUpACMod, base+0x1000, 0), acm: make([]byte, 0x100)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := stitchFIT(image, []tools.FitEntry{tc.entry}, imageOffset, tc.acm, tc.km, nil, StitchOptions{})
+			if !errors.Is(err, ErrOutOfBounds) {
+				t.Fatalf("stitchFIT() returned %v, expected %v", err, ErrOutOfBounds)
+			}
+			if addr := fmt.Sprintf("0x%x", tc.entry.Address); !strings.Contains(err.Error(), addr) {
+				t.Errorf("stitchFIT() returned %q, expected it to name the address %s", err, addr)
+			}
+		})
+	}
+
+	failing := func(addr uint64) (uint64, error) {
+		return 0, errors.New("no BIOS region")
+	}
+	entries := []tools.FitEntry{fitEntry(tools.KeyManifestRec, base, 0x40)}
+	if _, err := stitchFIT(image, entries, failing, nil, km, nil, StitchOptions{}); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("stitchFIT() with an unmappable address returned %v, expected %v", err, ErrOutOfBounds)
+	}
+}
+
+func TestStitchFITFill(t *testing.T) {
+	const base = tools.FourGiB - 0x1000
+	// regions holding larger KM and BPM stitched before
+	image := bytes.Repeat([]byte{0xee}, 0x1000)
+	entries := []tools.FitEntry{
+		fitEntry(tools.KeyManifestRec, base+0x100, 0x40),
+		fitEntry(tools.BootPolicyManifest, base+0x200, 0x80),
+	}
+	imageOffset := func(addr uint64) (uint64, error) {
+		return addr - base, nil
+	}
+	km := bytes.Repeat([]byte{0x4b}, 0x30)
+	bpm := bytes.Repeat([]byte{0xb0}, 0x60)
+
+	for _, fill := range []byte{0xff, 0x00} {
+		stitched, err := stitchFIT(image, entries, imageOffset, nil, km, bpm, StitchOptions{Fill: true, FillByte: fill})
+		if err != nil {
+			t.Fatalf("stitchFIT() failed: %v", err)
+		}
+		// the regions read as if the structures were written to erased flash
+		expected := append([]byte{}, image...)
+		copy(expected[0x100:0x140], bytes.Repeat([]byte{fill}, 0x40))
+		copy(expected[0x200:0x280], bytes.Repeat([]byte{fill}, 0x80))
+		copy(expected[0x100:], km)
+		copy(expected[0x200:], bpm)
+		if !bytes.Equal(stitched, expected) {
+			t.Errorf("stitchFIT() with fill 0x%02x wrote % x to the KM region and % x to the BPM region",
+				fill, stitched[0x100:0x140], stitched[0x200:0x280])
+		}
+		if stitched[0xff] != 0xee || stitched[0x140] != 0xee || stitched[0x280] != 0xee {
+			t.Errorf("stitchFIT() with fill 0x%02x modified bytes outside the regions", fill)
+		}
+	}
+
+	// without Fill only the structures are written
+	stitched, err := stitchFIT(image, entries, imageOffset, nil, km, bpm, StitchOptions{})
+	if err != nil {
+		t.Fatalf("stitchFIT() failed: %v", err)
+	}
+	if stitched[0x130] != 0xee || stitched[0x260] != 0xee {
+		t.Errorf("stitchFIT() without Fill modified the remainder of the regions")
+	}
+}
+
+func TestStitchFillMeasureIBB(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	image, km := vectors[0].BIOS, vectors[0].KM
+	// an IBB segment covering the KM region, which holds a larger KM before
+	se := bootpolicy.NewSE()
+	se.IBBSegments = []bootpolicy.IBBSegment{ibbSegment(uint32(tools.FourGiB-uint64(len(image))+testVectorKMOffset), uint32(len(km)), 0)}
+	se.DigestList.List = []manifest.HashStructure{{HashAlg: manifest.AlgSHA256}}
+	measure := func(image []byte) []byte {
+		if err := MeasureIBB(se, image); err != nil {
+			t.Fatalf("MeasureIBB() failed: %v", err)
+		}
+		return se.DigestList.List[0].HashBuffer
+	}
+	shorter := km[:len(km)-0x10]
+
+	// the filled region measures as the KM written to erased flash
+	erased := append([]byte{}, image...)
+	copy(erased[testVectorKMOffset:testVectorKMOffset+len(km)], bytes.Repeat([]byte{0xff}, len(km)))
+	copy(erased[testVectorKMOffset:], shorter)
+	stitched, err := AssembleProvisionedBIOS(image, nil, shorter, nil, StitchOptions{Fill: true, FillByte: 0xff})
+	if err != nil {
+		t.Fatalf("AssembleProvisionedBIOS() failed: %v", err)
+	}
+	if expected, digest := measure(erased), measure(stitched); !bytes.Equal(digest, expected) {
+		t.Errorf("the IBB digest of the filled image is %x, expected %x", digest, expected)
+	}
+
+	// without Fill the tail of the previous KM stays in the region
+	stitched, err = AssembleProvisionedBIOS(image, nil, shorter, nil, StitchOptions{})
+	if err != nil {
+		t.Fatalf("AssembleProvisionedBIOS() failed: %v", err)
+	}
+	if !bytes.Equal(measure(stitched), measure(image)) {
+		t.Errorf("the IBB digest without Fill doesn't measure the tail of the previous KM")
+	}
+}
+
+func TestStitchFITEntriesMatchesAssemble(t *testing.T) {
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	f, err := ioutil.TempFile("", "bg-prov-bios")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(image); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	f.Close()
+
+	// an image without FIT is rejected the same way in memory and on disk
+	km := signedTestKM(t)
+	_, memErr := AssembleProvisionedBIOS(image, nil, km, nil, StitchOptions{})
+	fileErr := StitchFITEntries(f.Name(), nil, nil, km)
+	if memErr == nil || fileErr == nil || memErr.Error() != fileErr.Error() {
+		t.Errorf("AssembleProvisionedBIOS() returned %v, StitchFITEntries() returned %v", memErr, fileErr)
+	}
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.Equal(data, image) {
+		t.Errorf("StitchFITEntries() modified the image although it failed")
+	}
+}
+
+func TestAssembleProvisionedBIOS(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	// structures of the same sizes signed with other keys and an ACM with another SVN
+	others, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	image := vectors[0].BIOS
+	original := append([]byte{}, image...)
+	acm, km, bpm := others[4].ACM, others[0].KM, others[0].BPM
+	if bytes.Equal(km, vectors[0].KM) || bytes.Equal(bpm, vectors[0].BPM) || bytes.Equal(acm, vectors[0].ACM) {
+		t.Fatalf("the structures to stitch equal the ones in the image")
+	}
+
+	stitched, err := AssembleProvisionedBIOS(image, acm, km, bpm, StitchOptions{})
+	if err != nil {
+		t.Fatalf("AssembleProvisionedBIOS() failed: %v", err)
+	}
+	if len(stitched) != len(image) {
+		t.Fatalf("AssembleProvisionedBIOS() returned %d bytes, expected %d", len(stitched), len(image))
+	}
+	parsedBPM, parsedKM, parsedACM, err := ParseFITEntries(stitched)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() of the assembled image failed: %v", err)
+	}
+	for name, c := range map[string]struct{ parsed, stitched []byte }{
+		"ACM": {parsedACM, acm},
+		"KM":  {parsedKM, km},
+		"BPM": {parsedBPM, bpm},
+	} {
+		if !bytes.Equal(c.parsed, c.stitched) {
+			t.Errorf("the %s parsed back from the assembled image differs from the stitched one", name)
+		}
+	}
+	// everything but the structures is left as it was
+	expected := append([]byte{}, image...)
+	copy(expected[testVectorKMOffset:], km)
+	copy(expected[testVectorBPMOffset:], bpm)
+	copy(expected[testVectorACMOffset:], acm)
+	if !bytes.Equal(stitched, expected) {
+		t.Errorf("AssembleProvisionedBIOS() modified the image outside of the FIT regions")
+	}
+	if !bytes.Equal(image, original) {
+		t.Errorf("AssembleProvisionedBIOS() modified the input image")
+	}
+}
+
+func TestParseCBFSEntries(t *testing.T) {
+	image, err := ioutil.ReadFile("testdata/coreboot.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	// the fixture has no FIT, the structures are only found in the CBFS
+	if _, err := tools.ExtractFit(image); err == nil {
+		t.Fatalf("the coreboot fixture unexpectedly has a FIT")
+	}
+	bpm, km, acm, err := ParseFITEntries(image)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() failed on a coreboot image: %v", err)
+	}
+	for path, data := range map[string][]byte{testKMPath: km, testBPMPath: bpm} {
+		expected, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("ParseFITEntries() didn't return the content of %s", path)
+		}
+	}
+	if _, _, _, _, err, err2 := tools.ParseACM(acm); err != nil || err2 != nil {
+		t.Errorf("ParseFITEntries() returned an ACM which doesn't parse: %v, %v", err, err2)
+	}
+
+	// a coreboot image without the manifest files reports both lookups
+	_, fitErr := tools.ExtractFit(image)
+	idx := bytes.Index(image, []byte(CBFSKMName))
+	copy(image[idx:], "renamed")
+	_, _, _, err = ParseFITEntries(image)
+	if err == nil || !strings.Contains(err.Error(), CBFSKMName) || !strings.Contains(err.Error(), fitErr.Error()) {
+		t.Errorf("ParseFITEntries() of a coreboot image without KM returned %v", err)
+	}
+}
+
+func TestMeasuredSignature(t *testing.T) {
+	// an ECDSA signature whose R has a leading zero byte, stored little endian
+	sig := manifest.Signature{SigScheme: manifest.AlgECDSA, Data: make([]byte, 64)}
+	for idx := range sig.Data {
+		sig.Data[idx] = byte(idx + 1)
+	}
+	sig.Data[31] = 0
+	measured, err := measuredSignature(&sig)
+	if err != nil {
+		t.Fatalf("measuredSignature() failed: %v", err)
+	}
+	expected := make([]byte, 32)
+	for idx := range expected {
+		expected[idx] = sig.Data[31-idx]
+	}
+	if !bytes.Equal(measured, expected) {
+		t.Errorf("measuredSignature() returned %x, expected %x", measured, expected)
+	}
+}
+
+func TestParseOptionsLayout(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	image := vectors[0].BIOS
+	if _, _, _, err := (ParseOptions{}).ParseFITEntries(image); err != nil {
+		t.Fatalf("ParseFITEntries() of a top-aligned image failed: %v", err)
+	}
+	// the layout of the call places the image below the FIT entries
+	opts := ParseOptions{Layout: &tools.FlashLayout{FlashSize: 0x1000000, RegionOffset: 0xf00000}}
+	if _, _, _, err := opts.ParseFITEntries(image); !errors.Is(err, tools.ErrAddressOutOfImage) {
+		t.Errorf("ParseFITEntries() with another layout returned %v, expected ErrAddressOutOfImage", err)
+	}
+	if _, err := AssembleProvisionedBIOS(image, nil, vectors[0].KM, nil, StitchOptions{Layout: opts.Layout}); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("AssembleProvisionedBIOS() with another layout returned %v, expected ErrOutOfBounds", err)
+	}
+}
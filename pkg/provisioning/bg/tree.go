@@ -0,0 +1,161 @@
+package bg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+// TrustTreeNode is a link of the BootGuard trust chain. Broken holds the
+// reason if the link doesn't hold.
+type TrustTreeNode struct {
+	Label    string           `json:"label"`
+	Broken   string           `json:"broken,omitempty"`
+	Children []*TrustTreeNode `json:"children,omitempty"`
+}
+
+func (n *TrustTreeNode) add(label string, err error) *TrustTreeNode {
+	child := &TrustTreeNode{Label: label}
+	if err != nil {
+		child.Broken = err.Error()
+	}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// IsBroken returns true if the node or one of its descendants is broken.
+func (n *TrustTreeNode) IsBroken() bool {
+	if n.Broken != "" {
+		return true
+	}
+	for _, c := range n.Children {
+		if c.IsBroken() {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTrustTree returns the trust chain of a KM and BPM from the OEM key hash
+// fused into the FPF down to the IBB entry point. If oemKeyHash is not nil,
+// the KM signing key is checked against it. If image is not nil, the IBB
+// digests are recomputed from it.
+func NewTrustTree(kmData, bpmData, image, oemKeyHash []byte) (*TrustTreeNode, error) {
+	return DefaultParseOptions().NewTrustTree(kmData, bpmData, image, oemKeyHash)
+}
+
+// NewTrustTree is NewTrustTree with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) NewTrustTree(kmData, bpmData, image, oemKeyHash []byte) (*TrustTreeNode, error) {
+	km, err := o.ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		return nil, err
+	}
+	bpm, err := o.ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		return nil, err
+	}
+
+	root := &TrustTreeNode{}
+	oemHash, err := km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg)
+	if err == nil {
+		root.Label = fmt.Sprintf("OEM key hash (FPF): %s %x", km.PubKeyHashAlg, oemHash)
+		if oemKeyHash != nil && !bytes.Equal(oemHash, oemKeyHash) {
+			err = fmt.Errorf("the fuses hold %x", oemKeyHash)
+		}
+	} else {
+		root.Label = fmt.Sprintf("OEM key hash (FPF): %s", km.PubKeyHashAlg)
+	}
+	if err != nil {
+		root.Broken = err.Error()
+	}
+
+	label := fmt.Sprintf("KM: ID 0x%x, SVN %d", km.KMID, km.KMSVN.SVN())
+	kmScheme, err := VerifyKM(kmData)
+	if err == nil {
+		label += fmt.Sprintf(", signed with %s", kmScheme)
+	}
+	kmNode := root.add(label, err)
+	for idx, h := range km.Hash {
+		kmNode.add(fmt.Sprintf("hash [%d] %s: %s %x", idx, h.Usage, h.Digest.HashAlg, h.Digest.HashBuffer), nil)
+	}
+
+	label = fmt.Sprintf("BPM: SVN %d, ACM SVN auth %d", bpm.BPMH.BPMSVN.SVN(), bpm.BPMH.ACMSVNAuth.SVN())
+	if bpm.TXTE != nil {
+		label += fmt.Sprintf(", TXT execution profile %s", bpm.TXTE.ControlFlags.ExecutionProfile())
+	}
+	bpmScheme, err := VerifyBPM(bpmData)
+	if err == nil {
+		label += fmt.Sprintf(", signed with %s", bpmScheme)
+	}
+	// the BPM is only trusted if the KM holds the hash of its signing key
+	if keyErr := checkKMReferencesBPMKey(km, bpm); keyErr != nil {
+		err = keyErr
+	}
+	bpmNode := kmNode.add(label, err)
+	for idx := range bpm.SE {
+		o.addSETrustTree(bpmNode, idx, &bpm.SE[idx], image)
+	}
+	return root, nil
+}
+
+func (o ParseOptions) addSETrustTree(parent *TrustTreeNode, idx int, se *bootpolicy.SE, image []byte) {
+	seNode := parent.add(fmt.Sprintf("IBBS element %d", idx), nil)
+	for _, d := range se.DigestList.List {
+		var err error
+		if image != nil {
+			var digest []byte
+			if digest, err = getIBBsDigest(se.IBBSegments, image, d.HashAlg, o.Layout); err == nil && !bytes.Equal(digest, d.HashBuffer) {
+				err = fmt.Errorf("the IBB hashes to %x", digest)
+			}
+		}
+		seNode.add(fmt.Sprintf("IBB digest: %s %x", d.HashAlg, d.HashBuffer), err)
+	}
+	entryPoint := fmt.Errorf("not covered by a measured IBB segment")
+	for _, seg := range se.IBBSegments {
+		measured := "measured"
+		if !seg.IsMeasured() {
+			measured = "not measured"
+		} else if seg.Contains(se.IBBEntryPoint) {
+			entryPoint = nil
+		}
+		seNode.add(fmt.Sprintf("IBB segment 0x%08x-0x%08x %s", seg.Base, segEnd(seg)-1, measured), nil)
+	}
+	seNode.add(fmt.Sprintf("IBB entry point 0x%08x", se.IBBEntryPoint), entryPoint)
+}
+
+const (
+	treeColorRed   = "\x1b[31m"
+	treeColorReset = "\x1b[0m"
+)
+
+// Render writes the tree with one node per line. Broken nodes are marked,
+// and printed in red if color is set, e.g. when writing to a terminal.
+func (n *TrustTreeNode) Render(w io.Writer, color bool) error {
+	return n.render(w, "", "", color)
+}
+
+func (n *TrustTreeNode) render(w io.Writer, prefix, childPrefix string, color bool) error {
+	line := n.Label
+	if n.Broken != "" {
+		line += " [BROKEN: " + n.Broken + "]"
+		if color {
+			line = treeColorRed + line + treeColorReset
+		}
+	}
+	if _, err := fmt.Fprintf(w, "%s%s\n", prefix, line); err != nil {
+		return err
+	}
+	for idx, c := range n.Children {
+		branch, indent := "├── ", "│   "
+		if idx == len(n.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if err := c.render(w, childPrefix+branch, childPrefix+indent, color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
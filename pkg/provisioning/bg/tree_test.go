@@ -0,0 +1,64 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func testTrustTree(t *testing.T, oemKeyHash []byte) *TrustTreeNode {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	tree, err := NewTrustTree(kmData, bpmData, nil, oemKeyHash)
+	if err != nil {
+		t.Fatalf("NewTrustTree() failed: %v", err)
+	}
+	return tree
+}
+
+func TestTrustTreeRender(t *testing.T) {
+	tree := testTrustTree(t, nil)
+	var out bytes.Buffer
+	if err := tree.Render(&out, false); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	golden, err := ioutil.ReadFile("testdata/trust_tree.txt")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if out.String() != string(golden) {
+		t.Errorf("Render() doesn't match testdata/trust_tree.txt:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), treeColorRed) {
+		t.Errorf("Render() without color printed escape sequences")
+	}
+
+	// the fixture BPM isn't signed by the key of the fixture KM
+	if !tree.IsBroken() {
+		t.Errorf("IsBroken() returned false for a tree with a broken BPM signature")
+	}
+	out.Reset()
+	if err := tree.Render(&out, true); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "└── "+treeColorRed+"BPM:") {
+		t.Errorf("Render() with color didn't print the broken BPM in red:\n%s", out.String())
+	}
+}
+
+func TestTrustTreeOEMKeyHash(t *testing.T) {
+	tree := testTrustTree(t, []byte{1, 2, 3})
+	if tree.Broken == "" {
+		t.Errorf("NewTrustTree() accepted a KM not matching the fused OEM key hash")
+	}
+	if !strings.HasPrefix(tree.Broken, "the fuses hold 010203") {
+		t.Errorf("NewTrustTree() reported %q for the mismatching OEM key hash", tree.Broken)
+	}
+}
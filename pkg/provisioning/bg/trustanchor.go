@@ -0,0 +1,44 @@
+package bg
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// ErrTrustAnchorMismatch is returned if a KM is not signed by the key which is
+// expected out of band, even if its signature is valid.
+var ErrTrustAnchorMismatch = errors.New("the KM signing key doesn't match the trust anchor")
+
+// CheckKMTrustAnchor checks that the public key embedded in the KM, which the
+// KM signature is verified with, is the anchor key.
+func CheckKMTrustAnchor(km *key.Manifest, anchor crypto.PublicKey) error {
+	pub, err := km.KeyAndSignature.Key.PubKey()
+	if err != nil {
+		return fmt.Errorf("unable to read the KM signing key: %w", err)
+	}
+	k, ok := pub.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return fmt.Errorf("unable to compare a %T KM signing key", pub)
+	}
+	if !k.Equal(anchor) {
+		return fmt.Errorf("%w: the KM is signed by another key", ErrTrustAnchorMismatch)
+	}
+	return nil
+}
+
+// CheckKMTrustAnchorHash checks that the hash of the KM signing key, as it is
+// fused into the FPF, is the anchor hash.
+func CheckKMTrustAnchorHash(km *key.Manifest, anchor []byte) error {
+	hash, err := km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg)
+	if err != nil {
+		return fmt.Errorf("unable to hash the KM signing key: %w", err)
+	}
+	if !bytes.Equal(hash, anchor) {
+		return fmt.Errorf("%w: the KM signing key hash is %x, expected %x", ErrTrustAnchorMismatch, hash, anchor)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package bg
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestCheckKMTrustAnchor(t *testing.T) {
+	oemKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	km := key.NewManifest()
+	km.PubKeyHashAlg = manifest.AlgSHA256
+	if err := km.KeyAndSignature.Key.SetPubKey(oemKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+
+	if err := CheckKMTrustAnchor(km, oemKey.Public()); err != nil {
+		t.Errorf("CheckKMTrustAnchor() failed for the matching anchor: %v", err)
+	}
+	if err := CheckKMTrustAnchor(km, otherKey.Public()); !errors.Is(err, ErrTrustAnchorMismatch) {
+		t.Errorf("CheckKMTrustAnchor() returned %v for a mismatching anchor, expected ErrTrustAnchorMismatch", err)
+	}
+
+	other := key.NewManifest()
+	other.PubKeyHashAlg = manifest.AlgSHA256
+	if err := other.KeyAndSignature.Key.SetPubKey(otherKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	hash, err := km.KeyAndSignature.Key.KMPubKeyHash(manifest.AlgSHA256)
+	if err != nil {
+		t.Fatalf("KMPubKeyHash() failed: %v", err)
+	}
+	if err := CheckKMTrustAnchorHash(km, hash); err != nil {
+		t.Errorf("CheckKMTrustAnchorHash() failed for the matching anchor: %v", err)
+	}
+	if err := CheckKMTrustAnchorHash(other, hash); !errors.Is(err, ErrTrustAnchorMismatch) {
+		t.Errorf("CheckKMTrustAnchorHash() returned %v for a mismatching anchor, expected ErrTrustAnchorMismatch", err)
+	}
+}
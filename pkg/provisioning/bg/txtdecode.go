@@ -0,0 +1,115 @@
+package bg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+const (
+	// cmosBank0Size is the number of bytes of CMOS bank 0, accessed through
+	// the I/O ports 0x70 and 0x71.
+	cmosBank0Size = 0x80
+	// cmosRTCRegisters is the number of RTC time, alarm and status registers
+	// at the start of CMOS bank 0.
+	cmosRTCRegisters = 0x0e
+	// acpiPMBaseAlignment is the alignment of the ACPI PM I/O base of the PCH.
+	acpiPMBaseAlignment = 0x80
+	// pwrmBaseSize is the size and alignment of the PMC PWRM MMIO range.
+	pwrmBaseSize = 0x10000
+)
+
+// TXTPlatformBases are the ACPI PM I/O base and the PWRM MMIO base of the
+// platform, which the bases in the TXT element are compared against if set.
+type TXTPlatformBases struct {
+	ACPIBase uint16
+	PwrMBase uint32
+}
+
+// TXTField is a field of the TXT element with the meaning of its value.
+type TXTField struct {
+	Name    string
+	Value   string
+	Meaning string
+}
+
+// TXTDecoding annotates the CMOS and ACPI fields of a TXT element with what
+// they point at.
+type TXTDecoding struct {
+	Fields   []TXTField
+	Warnings []string
+}
+
+// DecodeTXT decodes the PTT CMOS offsets, the ACPI base and the PWRM base of
+// the TXT element and flags suspicious values, e.g. CMOS offsets colliding with
+// each other or with the RTC registers.
+func DecodeTXT(txt *bootpolicy.TXT, platform TXTPlatformBases) *TXTDecoding {
+	d := &TXTDecoding{}
+	warnf := func(format string, args ...interface{}) {
+		d.Warnings = append(d.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	for idx, offset := range []uint8{txt.PTTCMOSOffset0, txt.PTTCMOSOffset1} {
+		name := fmt.Sprintf("PTT CMOS Offset %d", idx)
+		d.Fields = append(d.Fields, TXTField{
+			Name:    name,
+			Value:   fmt.Sprintf("0x%02x", offset),
+			Meaning: fmt.Sprintf("CMOS bank 0 byte %d (index port 0x70, data port 0x71), stores the platform wakeup time", offset),
+		})
+		switch {
+		case offset >= cmosBank0Size:
+			warnf("%s 0x%02x is outside CMOS bank 0 (0x00-0x%02x)", name, offset, cmosBank0Size-1)
+		case offset < cmosRTCRegisters:
+			warnf("%s 0x%02x collides with the RTC registers (0x00-0x%02x)", name, offset, cmosRTCRegisters-1)
+		}
+	}
+	if txt.PTTCMOSOffset0 == txt.PTTCMOSOffset1 {
+		warnf("PTT CMOS Offset 0 and 1 are both 0x%02x, the wakeup time bytes collide", txt.PTTCMOSOffset0)
+	}
+
+	base := txt.ACPIBaseOffset
+	d.Fields = append(d.Fields, TXTField{
+		Name:  "ACPI Base Offset",
+		Value: fmt.Sprintf("0x%04x", base),
+		Meaning: fmt.Sprintf("ACPI PM I/O base: PM1_STS 0x%04x, PM1_EN 0x%04x, PM1_CNT 0x%04x, PM1_TMR 0x%04x",
+			base, base+0x02, base+0x04, base+0x08),
+	})
+	switch {
+	case base == 0:
+		warnf("ACPI Base Offset is zero, the ACPI base is not set")
+	case base%acpiPMBaseAlignment != 0:
+		warnf("ACPI Base Offset 0x%04x is not aligned to 0x%x", base, acpiPMBaseAlignment)
+	}
+	if platform.ACPIBase != 0 && base != platform.ACPIBase {
+		warnf("ACPI Base Offset 0x%04x differs from the ACPI base 0x%04x of the platform", base, platform.ACPIBase)
+	}
+
+	pwrm := txt.PwrMBaseOffset
+	d.Fields = append(d.Fields, TXTField{
+		Name:    "ACPI MMIO Offset",
+		Value:   fmt.Sprintf("0x%08x", pwrm),
+		Meaning: fmt.Sprintf("PMC PWRM MMIO base: 0x%08x-0x%08x", pwrm, uint64(pwrm)+pwrmBaseSize-1),
+	})
+	switch {
+	case pwrm == 0:
+		warnf("ACPI MMIO Offset is zero, the PWRM base is not set")
+	case pwrm%pwrmBaseSize != 0:
+		warnf("ACPI MMIO Offset 0x%08x is not aligned to 0x%x", pwrm, pwrmBaseSize)
+	}
+	if platform.PwrMBase != 0 && pwrm != platform.PwrMBase {
+		warnf("ACPI MMIO Offset 0x%08x differs from the PWRM base 0x%08x of the platform", pwrm, platform.PwrMBase)
+	}
+	return d
+}
+
+// PrettyPrint writes the decoded fields followed by the warnings.
+func (d *TXTDecoding) PrettyPrint(w io.Writer) {
+	fmt.Fprintln(w, "TXT element:")
+	for _, f := range d.Fields {
+		fmt.Fprintf(w, "  %-18s %-10s  %s\n", f.Name+":", f.Value, f.Meaning)
+	}
+	for _, warning := range d.Warnings {
+		fmt.Fprintf(w, "WARNING: %s\n", warning)
+	}
+}
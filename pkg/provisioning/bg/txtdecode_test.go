@@ -0,0 +1,55 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDecodeTXTGolden(t *testing.T) {
+	f, err := os.Open(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+	bpm, err := ParseBPM(f)
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	if bpm.TXTE == nil {
+		t.Fatalf("BPM fixture has no TXT element")
+	}
+
+	for _, tc := range []struct {
+		golden   string
+		modify   func()
+		platform TXTPlatformBases
+		warnings int
+	}{
+		{"testdata/txt_decode.txt", func() {}, TXTPlatformBases{}, 0},
+		{"testdata/txt_decode_suspicious.txt", func() {
+			bpm.TXTE.PTTCMOSOffset0 = 0x0a
+			bpm.TXTE.PTTCMOSOffset1 = 0x0a
+			bpm.TXTE.ACPIBaseOffset = 0x0404
+			bpm.TXTE.PwrMBaseOffset = 0xfe001000
+		}, TXTPlatformBases{ACPIBase: 0x1800, PwrMBase: 0xfe000000}, 7},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			tc.modify()
+			d := DecodeTXT(bpm.TXTE, tc.platform)
+			if len(d.Warnings) != tc.warnings {
+				t.Errorf("DecodeTXT() returned warnings %q, expected %d", d.Warnings, tc.warnings)
+			}
+			var out bytes.Buffer
+			d.PrettyPrint(&out)
+			golden, err := ioutil.ReadFile(tc.golden)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if out.String() != string(golden) {
+				t.Errorf("PrettyPrint() doesn't match %s:\n%s", tc.golden, out.String())
+			}
+		})
+	}
+}
@@ -1,51 +1,198 @@
 package bg
 
 import (
+	"bytes"
+	"crypto"
 	"errors"
 	"fmt"
 	"io"
 
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
 	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
 )
 
-// ParseBPM reads from a binary and parses into the boot policy manifest structure
-func ParseBPM(reader io.Reader) (*bootpolicy.Manifest, error) {
+// ErrPlaceholderSignature is returned when verifying a manifest whose signature
+// is empty or a placeholder, e.g. a manifest generated with --cut and padded
+// afterwards, which was never signed.
+var ErrPlaceholderSignature = fmt.Errorf("placeholder/empty signature: %w", ErrUnsignedManifest)
+
+// IsPlaceholderSignature returns true if the signature data is empty or
+// consists only of 0x00 or only of 0xFF bytes, which no real signature does.
+func IsPlaceholderSignature(sig []byte) bool {
+	if len(sig) == 0 {
+		return true
+	}
+	for _, b := range sig {
+		if b != sig[0] {
+			return false
+		}
+	}
+	return sig[0] == 0x00 || sig[0] == 0xFF
+}
+
+// ParseOptions select the checks done while parsing a KM or BPM, the layout
+// of the firmware images and where warnings go. They apply to a single call,
+// so concurrent callers may use different options. The functions of the
+// package which aren't methods of ParseOptions use the DefaultParseOptions.
+type ParseOptions struct {
+	// StrictReserved rejects manifests with non-zero reserved fields, flags
+	// or padding.
+	StrictReserved bool
+	// StrictOrder rejects BPMs whose elements are not in the order of the
+	// document #575623.
+	StrictOrder bool
+	// Layout places images without flash descriptor in the flash, see
+	// tools.CalcImageOffsetWithLayout. It applies to the firmware images
+	// passed to the methods.
+	Layout *tools.FlashLayout
+	// Warnings collects the warnings issued by the methods. They are only
+	// printed to os.Stderr if it is nil.
+	Warnings *WarningCollector
+}
+
+// DefaultParseOptions returns the options of the functions of the package
+// which aren't methods of ParseOptions: the elements order is checked, the
+// reserved fields aren't, and images are mapped by their flash descriptor.
+func DefaultParseOptions() ParseOptions {
+	return ParseOptions{StrictOrder: true}
+}
+
+func (o ParseOptions) warnings() *WarningCollector {
+	return collectorOrDefault(o.Warnings)
+}
+
+// orderCheckReader selects the elements order check of the manifest read
+// from it.
+type orderCheckReader struct {
+	io.Reader
+	strict bool
+}
+
+func (r orderCheckReader) StrictOrderCheck() bool {
+	return r.strict
+}
+
+// readStructureID reads the structure ID the manifest has to start with and
+// returns a reader yielding the whole manifest.
+func readStructureID(reader io.Reader, id string) (io.Reader, error) {
+	var hdr manifest.StructureID
+	if _, err := io.ReadFull(reader, hdr[:]); err != nil {
+		return nil, fmt.Errorf("too short for a structure ID: %w", err)
+	}
+	if hdr.String() != id {
+		return nil, fmt.Errorf("%w: expected '%s', but got %q", ErrStructureID, id, hdr[:])
+	}
+	return io.MultiReader(bytes.NewReader(hdr[:]), reader), nil
+}
+
+// countingReader counts the bytes read from it, unlike the ReadFrom methods
+// of the manifests also if reading fails within a structure.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// readBPM parses the BPM from reader. The BPM may end before its key and
+// signature, as BPMs generated with --cut do, but not within the signed
+// region.
+func readBPM(reader io.Reader, strictOrder bool) (*bootpolicy.Manifest, error) {
+	reader, err := readStructureID(reader, bootpolicy.StructureIDBPMH)
+	if err != nil {
+		return nil, &ParseError{Manifest: "BPM", Err: err}
+	}
+	counter := &countingReader{Reader: reader}
 	bpm := &bootpolicy.Manifest{}
-	_, err := bpm.ReadFrom(reader)
+	_, err = bpm.ReadFrom(orderCheckReader{Reader: counter, strict: strictOrder})
 	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, &ParseError{Manifest: "BPM", Err: err}
+	}
+	if counter.n < int64(bpm.BPMH.KeySignatureOffset) {
+		return nil, &ParseError{Manifest: "BPM", Err: fmt.Errorf("truncated at %d bytes within the signed region of %d bytes: %w",
+			counter.n, bpm.BPMH.KeySignatureOffset, io.ErrUnexpectedEOF)}
+	}
+	return bpm, nil
+}
+
+// readKM parses the KM from reader. The KM may end before its key and
+// signature, as KMs generated with --cut do, but not within the signed
+// region.
+func readKM(reader io.Reader) (*key.Manifest, error) {
+	reader, err := readStructureID(reader, key.StructureIDManifest)
+	if err != nil {
+		return nil, &ParseError{Manifest: "KM", Err: err}
+	}
+	counter := &countingReader{Reader: reader}
+	km := &key.Manifest{}
+	_, err = km.ReadFrom(counter)
+	if err != nil && (!errors.Is(err, io.EOF) || counter.n != int64(km.KeyAndSignatureOffset())) {
+		return nil, &ParseError{Manifest: "KM", Err: err}
+	}
+	return km, nil
+}
+
+// ParseBPM reads from a binary and parses into the boot policy manifest structure
+func (o ParseOptions) ParseBPM(reader io.Reader) (*bootpolicy.Manifest, error) {
+	bpm, err := readBPM(reader, o.StrictOrder)
+	if err != nil {
 		return nil, err
 	}
+	if o.StrictReserved {
+		if err := CheckBPMReserved(bpm); err != nil {
+			return nil, err
+		}
+	}
 	return bpm, nil
 }
 
+// ParseBPM reads from a binary and parses into the boot policy manifest
+// structure with the DefaultParseOptions.
+func ParseBPM(reader io.Reader) (*bootpolicy.Manifest, error) {
+	return DefaultParseOptions().ParseBPM(reader)
+}
+
 // ValidateBPM reads from a binary, parses into the boot policy manifest structure
 // and validates the structure
 func ValidateBPM(reader io.Reader) error {
-	bpm := &bootpolicy.Manifest{}
-	_, err := bpm.ReadFrom(reader)
-	if err != nil && !errors.Is(err, io.EOF) {
+	bpm, err := readBPM(reader, false)
+	if err != nil {
 		return err
 	}
 	return bpm.Validate()
 }
 
 // ParseKM reads from a binary source and parses into the key manifest structure
-func ParseKM(reader io.Reader) (*key.Manifest, error) {
-	km := &key.Manifest{}
-	_, err := km.ReadFrom(reader)
-	if err != nil && !errors.Is(err, io.EOF) {
+func (o ParseOptions) ParseKM(reader io.Reader) (*key.Manifest, error) {
+	km, err := readKM(reader)
+	if err != nil {
 		return nil, err
 	}
+	if o.StrictReserved {
+		if err := CheckKMReserved(km); err != nil {
+			return nil, err
+		}
+	}
 	return km, nil
 }
 
+// ParseKM reads from a binary source and parses into the key manifest
+// structure with the DefaultParseOptions.
+func ParseKM(reader io.Reader) (*key.Manifest, error) {
+	return DefaultParseOptions().ParseKM(reader)
+}
+
 // ValidateKM reads from a binary source, parses into the key manifest structure
 // and validates the structure
 func ValidateKM(reader io.Reader) error {
-	km := &key.Manifest{}
-	_, err := km.ReadFrom(reader)
-	if err != nil && !errors.Is(err, io.EOF) {
+	km, err := readKM(reader)
+	if err != nil {
 		return err
 	}
 	if km.PubKeyHashAlg != km.KeyAndSignature.Signature.HashAlg {
@@ -53,3 +200,88 @@ func ValidateKM(reader io.Reader) error {
 	}
 	return km.Validate()
 }
+
+// VerifyKM parses a signed key manifest, verifies its signature and returns
+// the signature scheme the verification succeeded with.
+func VerifyKM(data []byte) (manifest.Algorithm, error) {
+	return VerifyKMWithKey(data, nil)
+}
+
+// VerifyKMWithKey is VerifyKM with the signature verified against pub instead
+// of the key embedded in the KM, unless pub is nil.
+func VerifyKMWithKey(data []byte, pub crypto.PublicKey) (manifest.Algorithm, error) {
+	return DefaultParseOptions().VerifyKMWithKey(data, pub)
+}
+
+// VerifyKMWithKey is VerifyKMWithKey with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) VerifyKMWithKey(data []byte, pub crypto.PublicKey) (manifest.Algorithm, error) {
+	km, err := o.ParseKM(bytes.NewReader(data))
+	if err != nil {
+		return manifest.AlgUnknown, err
+	}
+	offset := int(km.KeyAndSignatureOffset())
+	if offset > len(data) {
+		return manifest.AlgUnknown, fmt.Errorf("%w: key manifest is truncated", ErrSizeMismatch)
+	}
+	if IsPlaceholderSignature(km.KeyAndSignature.Signature.Data) {
+		return manifest.AlgUnknown, fmt.Errorf("key manifest: %w", ErrPlaceholderSignature)
+	}
+	ks, err := withVerificationKey(km.KeyAndSignature, pub)
+	if err != nil {
+		return manifest.AlgUnknown, err
+	}
+	alg, err := ks.VerifyAuto(data[:offset])
+	if err != nil {
+		return alg, &SignatureError{Manifest: "KM", Err: err}
+	}
+	return alg, nil
+}
+
+// VerifyBPM parses a signed boot policy manifest, verifies its signature and returns
+// the signature scheme the verification succeeded with.
+func VerifyBPM(data []byte) (manifest.Algorithm, error) {
+	return VerifyBPMWithKey(data, nil)
+}
+
+// VerifyBPMWithKey is VerifyBPM with the signature verified against pub
+// instead of the key embedded in the BPM, unless pub is nil.
+func VerifyBPMWithKey(data []byte, pub crypto.PublicKey) (manifest.Algorithm, error) {
+	return DefaultParseOptions().VerifyBPMWithKey(data, pub)
+}
+
+// VerifyBPMWithKey is VerifyBPMWithKey with the options o instead of the
+// DefaultParseOptions.
+func (o ParseOptions) VerifyBPMWithKey(data []byte, pub crypto.PublicKey) (manifest.Algorithm, error) {
+	bpm, err := o.ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		return manifest.AlgUnknown, err
+	}
+	offset := int(bpm.KeySignatureOffset)
+	if offset > len(data) {
+		return manifest.AlgUnknown, fmt.Errorf("%w: boot policy manifest is truncated", ErrSizeMismatch)
+	}
+	if IsPlaceholderSignature(bpm.PMSE.KeySignature.Signature.Data) {
+		return manifest.AlgUnknown, fmt.Errorf("boot policy manifest: %w", ErrPlaceholderSignature)
+	}
+	ks, err := withVerificationKey(bpm.PMSE.KeySignature, pub)
+	if err != nil {
+		return manifest.AlgUnknown, err
+	}
+	alg, err := ks.VerifyAuto(data[:offset])
+	if err != nil {
+		return alg, &SignatureError{Manifest: "BPM", Err: err}
+	}
+	return alg, nil
+}
+
+// withVerificationKey returns ks with its key replaced by pub, unless pub is
+// nil.
+func withVerificationKey(ks manifest.KeySignature, pub crypto.PublicKey) (*manifest.KeySignature, error) {
+	if pub != nil {
+		if err := ks.Key.SetPubKey(pub); err != nil {
+			return nil, fmt.Errorf("unable to use the public key: %w", err)
+		}
+	}
+	return &ks, nil
+}
@@ -0,0 +1,381 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+const (
+	testKMPath  = "../../intel/metadata/manifest/key/testdata/km.bin"
+	testBPMPath = "../../intel/metadata/manifest/bootpolicy/testdata/bpm.bin"
+	// testBPMPMPath is testBPMPath with a platform manufacturer element
+	testBPMPMPath = "../../intel/metadata/manifest/bootpolicy/testdata/bpm_pm.bin"
+	// testBPMUnknownHashAlgPath is testBPMPath with the SM3 IBB digest
+	// declaring the unassigned hash algorithm 0xfe
+	testBPMUnknownHashAlgPath = "../../intel/metadata/manifest/bootpolicy/testdata/bpm_unknown_hash_alg.bin"
+)
+
+func TestParseKMGolden(t *testing.T) {
+	golden, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	out, err := WriteKM(km)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if !bytes.Equal(golden, out) {
+		t.Errorf("ParseKM() -> WriteKM() doesn't reproduce %s", testKMPath)
+	}
+}
+
+func TestParseBPMGolden(t *testing.T) {
+	golden, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	out, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(golden, out) {
+		t.Errorf("ParseBPM() -> WriteBPM() doesn't reproduce %s", testBPMPath)
+	}
+}
+
+func TestParseRejectsNonManifests(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":  {},
+		"short":  []byte("__ACBP"),
+		"text":   []byte("no BPM"),
+		"zeros":  make([]byte, 1024),
+		"erased": bytes.Repeat([]byte{0xff}, 1024),
+	} {
+		if _, err := ParseKM(bytes.NewReader(data)); err == nil {
+			t.Errorf("ParseKM() succeeded with %s input", name)
+		}
+		if _, err := ParseBPM(bytes.NewReader(data)); err == nil {
+			t.Errorf("ParseBPM() succeeded with %s input", name)
+		}
+	}
+
+	km, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if _, err := ParseKM(bytes.NewReader(bpm)); !errors.Is(err, ErrStructureID) {
+		t.Errorf("ParseKM() of a BPM returned %v, expected ErrStructureID", err)
+	}
+	if _, err := ParseBPM(bytes.NewReader(km)); !errors.Is(err, ErrStructureID) {
+		t.Errorf("ParseBPM() of a KM returned %v, expected ErrStructureID", err)
+	}
+
+	// manifests cut at the key and signature parse, but not if truncated
+	// within the signed region
+	parsedKM, err := ParseKM(bytes.NewReader(km))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	cut := int(parsedKM.KeyAndSignatureOffset())
+	if _, err := ParseKM(bytes.NewReader(km[:cut])); err != nil {
+		t.Errorf("ParseKM() of the KM cut at the key and signature failed: %v", err)
+	}
+	if _, err := ParseKM(bytes.NewReader(km[:cut-1])); err == nil {
+		t.Errorf("ParseKM() succeeded with a KM truncated within the signed region")
+	}
+	parsedBPM, err := ParseBPM(bytes.NewReader(bpm))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	cut = int(parsedBPM.KeySignatureOffset)
+	if _, err := ParseBPM(bytes.NewReader(bpm[:cut])); err != nil {
+		t.Errorf("ParseBPM() of the BPM cut at the key and signature failed: %v", err)
+	}
+	if _, err := ParseBPM(bytes.NewReader(bpm[:cut-1])); err == nil {
+		t.Errorf("ParseBPM() succeeded with a BPM truncated within the signed region")
+	}
+}
+
+// testReorderedBPM returns testBPMPath with the TXT element moved before the
+// IBBS element.
+func testReorderedBPM(t *testing.T) []byte {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	se := bytes.Index(data, []byte(bootpolicy.StructureIDSE))
+	txt := bytes.Index(data, []byte(bootpolicy.StructureIDTXT))
+	txtEnd := txt + int(binary.LittleEndian.Uint16(data[txt+10:]))
+	var reordered []byte
+	reordered = append(reordered, data[:se]...)
+	reordered = append(reordered, data[txt:txtEnd]...)
+	reordered = append(reordered, data[se:txt]...)
+	return append(reordered, data[txtEnd:]...)
+}
+
+func TestParseOptions(t *testing.T) {
+	reordered := testReorderedBPM(t)
+	if _, err := (ParseOptions{StrictOrder: true}).ParseBPM(bytes.NewReader(reordered)); err == nil {
+		t.Errorf("ParseBPM() with StrictOrder accepted a BPM with elements out of order")
+	}
+	bpm, err := (ParseOptions{}).ParseBPM(bytes.NewReader(reordered))
+	if err != nil {
+		t.Fatalf("ParseBPM() without StrictOrder failed: %v", err)
+	}
+	if len(bpm.SE) != 1 || bpm.TXTE == nil {
+		t.Errorf("ParseBPM() without StrictOrder returned %d IBBS elements and TXT element %v", len(bpm.SE), bpm.TXTE)
+	}
+}
+
+// TestParseConcurrent parses with different options in parallel, run with
+// -race to detect shared state.
+func TestParseConcurrent(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	reordered := testReorderedBPM(t)
+
+	errs := make(chan error, 64)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(strict bool) {
+			defer wg.Done()
+			opts := ParseOptions{StrictReserved: strict, StrictOrder: strict}
+			// the fixture KM sets a reserved hash usage bit
+			if _, err := opts.ParseKM(bytes.NewReader(kmData)); (err != nil) != strict {
+				errs <- fmt.Errorf("ParseKM() with %+v returned %v", opts, err)
+				return
+			}
+			if _, err := opts.ParseBPM(bytes.NewReader(reordered)); (err != nil) != strict {
+				errs <- fmt.Errorf("ParseBPM() of the reordered BPM with %+v returned %v", opts, err)
+				return
+			}
+			bpm, err := (ParseOptions{StrictOrder: strict}).ParseBPM(bytes.NewReader(bpmData))
+			if err != nil {
+				errs <- fmt.Errorf("ParseBPM() failed: %w", err)
+				return
+			}
+			out, err := WriteBPM(bpm)
+			if err != nil {
+				errs <- fmt.Errorf("WriteBPM() failed: %w", err)
+				return
+			}
+			if !bytes.Equal(out, bpmData) {
+				errs <- fmt.Errorf("ParseBPM() -> WriteBPM() doesn't reproduce %s", testBPMPath)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestParseBPMWithPM(t *testing.T) {
+	golden, err := ioutil.ReadFile(testBPMPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	if bpm.PME == nil {
+		t.Fatalf("ParseBPM() dropped the PM element")
+	}
+	if !bytes.HasPrefix(bpm.PME.Data, []byte("OEM custom data")) || len(bpm.PME.Data) != 32 {
+		t.Errorf("PM data is %x", bpm.PME.Data)
+	}
+	out, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(golden, out) {
+		t.Errorf("ParseBPM() -> WriteBPM() doesn't reproduce %s", testBPMPMPath)
+	}
+}
+
+func TestParseBPMUnknownHashAlg(t *testing.T) {
+	golden, err := ioutil.ReadFile(testBPMUnknownHashAlgPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	digests := bpm.SE[0].DigestList.List
+	if len(digests) != 4 || digests[3].HashAlg != 0xfe || len(digests[3].HashBuffer) != 0x20 {
+		t.Fatalf("ParseBPM() didn't keep the digest of the unknown algorithm: %+v", digests)
+	}
+	if len(bpm.SE[0].IBBSegments) == 0 {
+		t.Errorf("ParseBPM() dropped the IBB segments following the digest")
+	}
+	if s := bpm.PrettyString(0, true); !strings.Contains(s, "unknown hash alg (0xfe)") {
+		t.Errorf("PrettyString() doesn't report the unknown hash algorithm")
+	}
+	out, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(golden, out) {
+		t.Errorf("ParseBPM() -> WriteBPM() doesn't reproduce %s", testBPMUnknownHashAlgPath)
+	}
+}
+
+func addSeedCorpus(f *testing.F, paths ...string) {
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatalf("Failed to read seed %s: %v", path, err)
+		}
+		f.Add(data)
+	}
+}
+
+func FuzzParseKM(f *testing.F) {
+	addSeedCorpus(f, testKMPath, "test_artifacts/km.signed", "test_artifacts/km.unsigned")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Only a graceful error is acceptable, a panic fails the fuzzer.
+		_, _ = ParseKM(bytes.NewReader(data))
+	})
+}
+
+func FuzzParseBPM(f *testing.F) {
+	addSeedCorpus(f, testBPMPath, testBPMUnknownHashAlgPath)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Only a graceful error is acceptable, a panic fails the fuzzer.
+		_, _ = ParseBPM(bytes.NewReader(data))
+	})
+}
+
+// signTestBPM returns the BPM fixture signed with privKey.
+func signTestBPM(t *testing.T, privKey *rsa.PrivateKey) []byte {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	return signBPM(t, bpm, privKey)
+}
+
+// signBPM signs bpm with privKey as bpm-sign does: the PMSE is replaced by
+// one of the key of privKey and the signature covers the BPM up to the
+// KeySignatureOffset.
+func signBPM(t *testing.T, bpm *bootpolicy.Manifest, privKey *rsa.PrivateKey) []byte {
+	pmse := bootpolicy.NewSignature()
+	if err := pmse.Key.SetPubKey(privKey.Public()); err != nil {
+		t.Fatalf("SetPubKey() failed: %v", err)
+	}
+	bpm.PMSE = *pmse
+	unsigned, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if err := bpm.PMSE.Signature.SetSignature(0, privKey, unsigned[:bpm.KeySignatureOffset]); err != nil {
+		t.Fatalf("SetSignature() failed: %v", err)
+	}
+	signed, err := WriteBPM(bpm)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	return signed
+}
+
+func TestVerifyWithKey(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	km := signedTestKM(t)
+	if _, err := VerifyKMWithKey(km, nil); err != nil {
+		t.Errorf("VerifyKMWithKey() with the embedded key failed: %v", err)
+	}
+	parsed, err := ParseKM(bytes.NewReader(km))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	embedded, err := parsed.KeyAndSignature.Key.PubKey()
+	if err != nil {
+		t.Fatalf("PubKey() failed: %v", err)
+	}
+	if _, err := VerifyKMWithKey(km, embedded); err != nil {
+		t.Errorf("VerifyKMWithKey() with the external signing key failed: %v", err)
+	}
+	var sigErr *SignatureError
+	if _, err := VerifyKMWithKey(km, otherKey.Public()); !errors.As(err, &sigErr) {
+		t.Errorf("VerifyKMWithKey() with another external key returned %v, expected a SignatureError", err)
+	}
+
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bpm := signTestBPM(t, privKey)
+	if scheme, err := VerifyBPMWithKey(bpm, nil); err != nil || scheme != manifest.AlgRSASSA {
+		t.Errorf("VerifyBPMWithKey() with the embedded key returned %s, %v", scheme, err)
+	}
+	if _, err := VerifyBPMWithKey(bpm, privKey.Public()); err != nil {
+		t.Errorf("VerifyBPMWithKey() with the external signing key failed: %v", err)
+	}
+	if _, err := VerifyBPMWithKey(bpm, otherKey.Public()); !errors.As(err, &sigErr) {
+		t.Errorf("VerifyBPMWithKey() with another external key returned %v, expected a SignatureError", err)
+	}
+}
+
+func TestVerifyKMPlaceholderSignature(t *testing.T) {
+	signed := signedTestKM(t)
+	c, err := KMCoverage(signed)
+	if err != nil {
+		t.Fatalf("KMCoverage() failed: %v", err)
+	}
+
+	zeroed := append([]byte{}, signed...)
+	for idx := c.Signature.Offset; idx < c.Signature.End(); idx++ {
+		zeroed[idx] = 0
+	}
+	if _, err := VerifyKM(zeroed); !errors.Is(err, ErrPlaceholderSignature) {
+		t.Errorf("VerifyKM() with a zeroed signature returned %v, expected %v", err, ErrPlaceholderSignature)
+	}
+
+	// a corrupted signature is invalid, but not a placeholder
+	corrupted := append([]byte{}, signed...)
+	corrupted[c.Signature.Offset] ^= 0xff
+	if _, err := VerifyKM(corrupted); err == nil || errors.Is(err, ErrPlaceholderSignature) {
+		t.Errorf("VerifyKM() with a corrupted signature returned %v", err)
+	}
+}
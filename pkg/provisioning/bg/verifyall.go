@@ -0,0 +1,194 @@
+package bg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/tools"
+)
+
+// The checks VerifyAll runs, in order. Each one can be skipped by its name.
+const (
+	VerifyCheckFIT          = "fit"
+	VerifyCheckSignatures   = "signatures"
+	VerifyCheckKMBPM        = "km-bpm-binding"
+	VerifyCheckIBBDigest    = "ibb-digest"
+	VerifyCheckACM          = "acm"
+	VerifyCheckACMSignature = "acm-signature"
+	VerifyCheckFuseHash     = "fuse-hash"
+)
+
+// VerifyAllChecks are the names of the checks of VerifyAll.
+var VerifyAllChecks = []string{
+	VerifyCheckFIT, VerifyCheckSignatures, VerifyCheckKMBPM, VerifyCheckIBBDigest,
+	VerifyCheckACM, VerifyCheckACMSignature, VerifyCheckFuseHash,
+}
+
+// VerifyAllOptions select the checks of VerifyAll and the keys and hashes
+// held out of band to check the image against.
+type VerifyAllOptions struct {
+	// KMPubKey and BPMPubKey verify the signatures instead of the keys
+	// embedded in the manifests if set.
+	KMPubKey  crypto.PublicKey
+	BPMPubKey crypto.PublicKey
+	// ACMPubKey is the key the ACM has to be signed with. The key in the ACM
+	// header is used if it isn't set, which only proves the integrity.
+	ACMPubKey *rsa.PublicKey
+	// OEMKeyHash is the OEM key hash fused into the FPF. The fuse-hash check
+	// is skipped if it isn't set.
+	OEMKeyHash []byte
+	// Skip lists the names of the checks not to run, see VerifyAllChecks.
+	Skip []string
+}
+
+// ValidateSkip checks that Skip only names known checks.
+func (o VerifyAllOptions) ValidateSkip() error {
+	for _, name := range o.Skip {
+		if !knownVerifyCheck(name) {
+			return fmt.Errorf("unknown check '%s', available: %s", name, strings.Join(VerifyAllChecks, ", "))
+		}
+	}
+	return nil
+}
+
+func knownVerifyCheck(name string) bool {
+	for _, check := range VerifyAllChecks {
+		if check == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o VerifyAllOptions) skipped(name string) bool {
+	for _, s := range o.Skip {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyAll runs every consistency check of a BIOS image, the gate before
+// shipping it: the FIT integrity, the KM and BPM signatures, the BPM key
+// hash in the KM, the IBB digests, the compatibility of the ACM with the
+// manifests, the ACM signature and, if given, the fused OEM key hash. Skipped checks are
+// reported as such and don't fail the results.
+func VerifyAll(image []byte, opts VerifyAllOptions) *CheckResults {
+	return DefaultParseOptions().VerifyAll(image, opts)
+}
+
+// VerifyAll is VerifyAll with the options o instead of the DefaultParseOptions.
+func (o ParseOptions) VerifyAll(image []byte, opts VerifyAllOptions) *CheckResults {
+	r := NewCheckResults()
+	if opts.skipped(VerifyCheckFIT) {
+		r.Add(skippedCheck(VerifyCheckFIT, "skipped"))
+	} else {
+		r.Add(o.verifyFIT(image))
+	}
+
+	bpmData, kmData, acmData, err := o.ParseFITEntries(image)
+	var parseErr error
+	if err != nil {
+		parseErr = fmt.Errorf("unable to extract the KM, BPM and ACM: %w", err)
+	}
+	run := func(name string, check func() []CheckResult) {
+		switch {
+		case opts.skipped(name):
+			r.Add(skippedCheck(name, "skipped"))
+		case parseErr != nil:
+			r.Add(NewCheckResult(name, parseErr, ""))
+		default:
+			for _, c := range check() {
+				r.Add(c)
+			}
+		}
+	}
+	run(VerifyCheckSignatures, func() []CheckResult {
+		kmScheme, kmErr := o.VerifyKMWithKey(kmData, opts.KMPubKey)
+		bpmScheme, bpmErr := o.VerifyBPMWithKey(bpmData, opts.BPMPubKey)
+		return []CheckResult{
+			NewCheckResult("km-signature", kmErr, kmScheme.String()),
+			NewCheckResult("bpm-signature", bpmErr, bpmScheme.String()),
+		}
+	})
+	run(VerifyCheckKMBPM, func() []CheckResult {
+		km, err := o.ParseKM(bytes.NewReader(kmData))
+		if err != nil {
+			return []CheckResult{NewCheckResult("km-bpm-key", err, "")}
+		}
+		bpm, err := o.ParseBPM(bytes.NewReader(bpmData))
+		if err != nil {
+			return []CheckResult{NewCheckResult("km-bpm-key", err, "")}
+		}
+		return []CheckResult{NewCheckResult("km-bpm-key", checkKMReferencesBPMKey(km, bpm), "KM holds the BPM signing key hash")}
+	})
+	run(VerifyCheckIBBDigest, func() []CheckResult {
+		bpm, err := o.ParseBPM(bytes.NewReader(bpmData))
+		if err != nil {
+			return []CheckResult{NewCheckResult(VerifyCheckIBBDigest, err, "")}
+		}
+		return o.CheckBPMHashes(bpm, image).Checks
+	})
+	run(VerifyCheckACM, func() []CheckResult {
+		return []CheckResult{
+			NewCheckResult("acm-km-hash-algs", o.CheckKMAgainstACM(kmData, acmData), ""),
+			NewCheckResult("acm-ibb-digest-algs", o.CheckBPMHashAlgsAgainstACM(bpmData, acmData), ""),
+			NewCheckResult("acm-svn", o.CheckBPMAgainstACM(bpmData, acmData), ""),
+		}
+	})
+	run(VerifyCheckACMSignature, func() []CheckResult {
+		scheme, err := tools.ACMSignatureScheme(acmData)
+		if err != nil {
+			return []CheckResult{NewCheckResult(VerifyCheckACMSignature, err, "")}
+		}
+		return []CheckResult{NewCheckResult(VerifyCheckACMSignature, tools.VerifyACMSignature(acmData, opts.ACMPubKey), scheme.String())}
+	})
+	if opts.OEMKeyHash == nil && !opts.skipped(VerifyCheckFuseHash) {
+		r.Add(skippedCheck(VerifyCheckFuseHash, "no OEM key hash given"))
+		return r
+	}
+	run(VerifyCheckFuseHash, func() []CheckResult {
+		km, err := o.ParseKM(bytes.NewReader(kmData))
+		if err != nil {
+			return []CheckResult{NewCheckResult("oem-key-hash", err, "")}
+		}
+		hash, err := km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg)
+		if err != nil {
+			return []CheckResult{NewCheckResult("oem-key-hash", err, "")}
+		}
+		return []CheckResult{compareDigest("oem-key-hash", hash, opts.OEMKeyHash)}
+	})
+	return r
+}
+
+// verifyFIT checks that the image has a FIT whose entries reference
+// structures which parse, have valid checksums and aren't duplicated.
+func (o ParseOptions) verifyFIT(image []byte) CheckResult {
+	report, err := o.AnnotateFIT(image)
+	if err != nil {
+		return NewCheckResult(VerifyCheckFIT, fmt.Errorf("unable to read the FIT: %w", err), "")
+	}
+	if report.Pass() {
+		return NewCheckResult(VerifyCheckFIT, nil, fmt.Sprintf("%d entries", len(report.Entries)))
+	}
+	if report.Duplicates != "" {
+		return NewCheckResult(VerifyCheckFIT, fmt.Errorf("duplicated entries: %s", report.Duplicates), "")
+	}
+	for _, e := range report.Entries {
+		switch {
+		case e.Error != "":
+			return NewCheckResult(VerifyCheckFIT, fmt.Errorf("entry %d (%s): %s", e.Index, e.Name, e.Error), "")
+		case e.ChecksumInvalid:
+			return NewCheckResult(VerifyCheckFIT, fmt.Errorf("entry %d (%s) has an invalid checksum", e.Index, e.Name), "")
+		}
+	}
+	return NewCheckResult(VerifyCheckFIT, fmt.Errorf("the FIT is invalid"), "")
+}
+
+func skippedCheck(name, detail string) CheckResult {
+	return CheckResult{Check: name, Pass: true, Skipped: true, Detail: detail}
+}
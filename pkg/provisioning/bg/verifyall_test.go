@@ -0,0 +1,141 @@
+package bg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+)
+
+func verifyAllChecks(r *CheckResults) map[string]CheckResult {
+	checks := map[string]CheckResult{}
+	for _, c := range r.Checks {
+		checks[c.Check] = c
+	}
+	return checks
+}
+
+func TestVerifyAll(t *testing.T) {
+	image, err := ioutil.ReadFile(testCorebootPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	// the fixture has no FIT, ParseFITEntries falls back to its CBFS
+	bpmData, kmData, _, err := ParseFITEntries(image)
+	if err != nil {
+		t.Fatalf("ParseFITEntries() failed: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	oemHash, err := km.KeyAndSignature.Key.KMPubKeyHash(km.PubKeyHashAlg)
+	if err != nil {
+		t.Fatalf("KMPubKeyHash() failed: %v", err)
+	}
+
+	// the signature of the BPM fixture doesn't verify over any part of the
+	// BPM, so verify an image with the BPM signed again instead
+	r := VerifyAll(image, VerifyAllOptions{})
+	if c := verifyAllChecks(r)["bpm-signature"]; c.Pass {
+		t.Errorf("VerifyAll() verified the signature of the BPM fixture: %+v", c)
+	}
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signed := signTestBPM(t, privKey)
+	if len(signed) != len(bpmData) {
+		t.Fatalf("the signed BPM has %d bytes, the BPM of the image %d bytes", len(signed), len(bpmData))
+	}
+	image = append([]byte{}, image...)
+	copy(image[bytes.Index(image, bpmData):], signed)
+
+	// without FIT and with an ACM SVN below the ACMSVNAuth of the BPM
+	r = VerifyAll(image, VerifyAllOptions{})
+	checks := verifyAllChecks(r)
+	if r.Pass || checks[VerifyCheckFIT].Pass || checks["acm-svn"].Pass {
+		t.Errorf("VerifyAll() of the fixture returned %+v", r)
+	}
+	if !checks["km-signature"].Pass || !checks["bpm-signature"].Pass {
+		t.Errorf("VerifyAll() failed to verify the signatures: %+v", r.Failed())
+	}
+	if c := checks[VerifyCheckFuseHash]; !c.Skipped || !c.Pass {
+		t.Errorf("VerifyAll() without OEM key hash returned %+v for the fuse hash", c)
+	}
+
+	opts := VerifyAllOptions{
+		OEMKeyHash: oemHash,
+		Skip:       []string{VerifyCheckFIT, VerifyCheckKMBPM, VerifyCheckIBBDigest, VerifyCheckACM, VerifyCheckACMSignature},
+	}
+	if err := opts.ValidateSkip(); err != nil {
+		t.Fatalf("ValidateSkip() failed: %v", err)
+	}
+	r = VerifyAll(image, opts)
+	checks = verifyAllChecks(r)
+	if !r.Pass || !checks[VerifyCheckFIT].Skipped || !checks[VerifyCheckACM].Skipped || !checks["oem-key-hash"].Pass {
+		t.Errorf("VerifyAll() with the failing checks skipped returned %+v", r)
+	}
+	if _, ok := checks["acm-svn"]; ok {
+		t.Errorf("VerifyAll() ran the skipped ACM checks")
+	}
+
+	// a KM signed with another OEM key than the fused one
+	opts.OEMKeyHash = make([]byte, len(oemHash))
+	r = VerifyAll(image, opts)
+	if c, ok := verifyAllChecks(r)["oem-key-hash"]; r.Pass || !ok || c.Pass || len(r.Failed()) != 1 {
+		t.Errorf("VerifyAll() with another fused key hash returned %+v", r)
+	}
+
+	if err := (VerifyAllOptions{Skip: []string{"pcr0"}}).ValidateSkip(); err == nil {
+		t.Errorf("ValidateSkip() accepted an unknown check")
+	}
+}
+
+func TestVerifyAllIBBDigest(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	digestCheck := fmt.Sprintf("se[0]-ibb-digest-%s", manifest.AlgSHA256)
+
+	checks := verifyAllChecks(VerifyAll(vectors[0].BIOS, VerifyAllOptions{}))
+	c, ok := checks[digestCheck]
+	if !ok || !c.Pass || c.Skipped {
+		t.Errorf("VerifyAll() of a valid image returned %+v for the IBB digest", c)
+	}
+	if c.Expected == "" || c.Expected != c.Actual {
+		t.Errorf("VerifyAll() reported the IBB digest %q, expected it to match the recomputed %q", c.Actual, c.Expected)
+	}
+
+	// a byte of the IBB modified after signing
+	image := append([]byte{}, vectors[0].BIOS...)
+	image[testVectorIBBOffset] ^= 0x01
+	checks = verifyAllChecks(VerifyAll(image, VerifyAllOptions{}))
+	if c := checks[digestCheck]; c.Pass {
+		t.Errorf("VerifyAll() accepted the IBB digest of a modified IBB: %+v", c)
+	}
+}
+
+func TestVerifyAllACMSignature(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors() failed: %v", err)
+	}
+	image := vectors[0].BIOS
+
+	// the stub ACM of the vectors isn't signed
+	r := VerifyAll(image, VerifyAllOptions{})
+	failed := r.Failed()
+	if r.Pass || len(failed) != 1 || failed[0].Check != VerifyCheckACMSignature {
+		t.Errorf("VerifyAll() of an image with an unsigned ACM failed %+v, expected only %s", failed, VerifyCheckACMSignature)
+	}
+	r = VerifyAll(image, VerifyAllOptions{Skip: []string{VerifyCheckACMSignature}})
+	if c := verifyAllChecks(r)[VerifyCheckACMSignature]; !r.Pass || !c.Skipped {
+		t.Errorf("VerifyAll() with the ACM signature skipped returned %+v", r)
+	}
+}
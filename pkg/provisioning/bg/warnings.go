@@ -0,0 +1,91 @@
+package bg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrWarnings is returned by WarningCollector.Err if warnings were issued
+// while warnings are treated as errors.
+var ErrWarnings = errors.New("warnings are treated as errors")
+
+// MaxRecordedWarnings limits the warnings a WarningCollector keeps, so a
+// long-running caller doesn't accumulate them without bound. Warnings beyond
+// the limit are still printed and counted.
+const MaxRecordedWarnings = 1000
+
+// WarningCollector prints and records the validation warnings of a command,
+// so they can be promoted to errors once the command completes. A service
+// handling several requests uses a collector per request, so the warnings of
+// concurrent requests aren't mixed.
+type WarningCollector struct {
+	// Out receives the printed warnings.
+	Out io.Writer
+	// Strict makes Err fail if any warning was issued.
+	Strict bool
+
+	mu       sync.Mutex
+	count    int
+	warnings []string
+}
+
+// Warnf prints a warning to Out and records it. Concurrent warnings are
+// printed one after another.
+func (c *WarningCollector) Warnf(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.Out, "WARNING: %s\n", warning)
+	c.record(warning)
+}
+
+// Add records a warning which was already printed, e.g. as part of a report.
+func (c *WarningCollector) Add(warning string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record(warning)
+}
+
+func (c *WarningCollector) record(warning string) {
+	c.count++
+	if len(c.warnings) < MaxRecordedWarnings {
+		c.warnings = append(c.warnings, warning)
+	}
+}
+
+// Warnings returns the recorded warnings, at most MaxRecordedWarnings.
+func (c *WarningCollector) Warnings() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.warnings...)
+}
+
+// Count returns the number of warnings issued, including the ones beyond
+// MaxRecordedWarnings.
+func (c *WarningCollector) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// Err returns an error wrapping ErrWarnings if Strict is set and warnings were
+// recorded.
+func (c *WarningCollector) Err() error {
+	count := c.Count()
+	if !c.Strict || count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d warning(s) issued", ErrWarnings, count)
+}
+
+// collectorOrDefault returns c, or a collector only printing to os.Stderr if
+// c is nil.
+func collectorOrDefault(c *WarningCollector) *WarningCollector {
+	if c == nil {
+		return &WarningCollector{Out: os.Stderr}
+	}
+	return c
+}
@@ -0,0 +1,52 @@
+package bg
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+)
+
+func TestWarningCollectorStrict(t *testing.T) {
+	// Out of order IBB segments are only warned about.
+	report := ReportIBBSegments([]bootpolicy.IBBSegment{
+		ibbSegment(0xfff00000, 0x100000, 0),
+		ibbSegment(0xffe00000, 0x100000, 0),
+	})
+	for _, strict := range []bool{false, true} {
+		var out bytes.Buffer
+		c := &WarningCollector{Out: &out, Strict: strict}
+		if err := c.Err(); err != nil {
+			t.Errorf("Err() without warnings returned %v", err)
+		}
+		for _, warning := range report.Warnings() {
+			c.Warnf("SE %d: %s", 0, warning)
+		}
+		if !strings.HasPrefix(out.String(), "WARNING: SE 0: IBB segments are not in address order") {
+			t.Errorf("Warnf() printed %q", out.String())
+		}
+		err := c.Err()
+		if strict && !errors.Is(err, ErrWarnings) {
+			t.Errorf("Err() with strict warnings returned %v, expected %v", err, ErrWarnings)
+		}
+		if !strict && err != nil {
+			t.Errorf("Err() returned %v", err)
+		}
+	}
+}
+
+func TestWarningCollectorLimit(t *testing.T) {
+	c := &WarningCollector{Out: ioutil.Discard, Strict: true}
+	for idx := 0; idx < MaxRecordedWarnings+10; idx++ {
+		c.Warnf("warning %d", idx)
+	}
+	if len(c.Warnings()) != MaxRecordedWarnings || c.Count() != MaxRecordedWarnings+10 {
+		t.Errorf("WarningCollector recorded %d of %d warnings, expected %d", len(c.Warnings()), c.Count(), MaxRecordedWarnings)
+	}
+	if err := c.Err(); err == nil || !strings.Contains(err.Error(), "1010 warning(s)") {
+		t.Errorf("Err() returned %v, expected all warnings to be counted", err)
+	}
+}
@@ -0,0 +1,411 @@
+package bg
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// The XML layout of a config, modeled on the configuration of Intel's MEU.
+// It is an approximation: the element names follow the MEU fields, but the
+// layout isn't validated against an MEU release or its samples, so the XML
+// may need adjustments before MEU accepts it. Every field of the options is
+// mapped, including the structure versions and the reserved fields the
+// manifests don't require to be zero, except the keys and signatures, which
+// are set when signing, and the sizes and offsets, which are computed. A
+// missing structure version selects the default one. Binary data, e.g.
+// digests and reserved fields, is hex encoded, all other values are decimal.
+
+type meuConfig struct {
+	XMLName            xml.Name              `xml:"BootGuardConfiguration"`
+	SharedIdentifiers  *ManifestIDs          `xml:"SharedIdentifiers,omitempty"`
+	KeyManifest        meuKeyManifest        `xml:"KeyManifest"`
+	BootPolicyManifest meuBootPolicyManifest `xml:"BootPolicyManifest"`
+}
+
+type meuKeyManifest struct {
+	StructVersion uint8       `xml:"StructVersion,omitempty"`
+	Reserved      string      `xml:"Reserved,omitempty"`
+	Revision      uint8       `xml:"KmVersion"`
+	SVN           uint8       `xml:"KmSvn"`
+	ID            uint8       `xml:"KmId"`
+	PubKeyHashAlg uint16      `xml:"KmPubKeyHashAlg"`
+	Hashes        []meuKMHash `xml:"KmHashes>KmHash"`
+}
+
+type meuKMHash struct {
+	Usage  uint64  `xml:"Usage"`
+	Digest meuHash `xml:"Digest"`
+}
+
+type meuHash struct {
+	HashAlg uint16 `xml:"HashAlg"`
+	Hash    string `xml:"Hash"`
+}
+
+type meuBootPolicyManifest struct {
+	StructVersion    uint8           `xml:"StructVersion,omitempty"`
+	Revision         uint8           `xml:"BpmRevision"`
+	SVN              uint8           `xml:"BpmSvn"`
+	ACMSVN           uint8           `xml:"AcmSvn"`
+	NEMPages         uint16          `xml:"NemPages"`
+	IBBElements      []meuIBB        `xml:"IbbElement"`
+	TXTElement       *meuTXT         `xml:"TxtElement,omitempty"`
+	ReservedElement  *meuDataElement `xml:"ReservedElement,omitempty"`
+	PCDElement       *meuDataElement `xml:"PcdElement,omitempty"`
+	PlatformData     *meuDataElement `xml:"PlatformManufacturerElement,omitempty"`
+	SignatureVersion uint8           `xml:"SignatureElementVersion,omitempty"`
+}
+
+type meuIBB struct {
+	StructVersion uint8           `xml:"StructVersion,omitempty"`
+	Flags         uint32          `xml:"IbbFlags"`
+	PBETValue     uint8           `xml:"PbetValue"`
+	MCHBAR        uint64          `xml:"IbbMchBar"`
+	VTdBAR        uint64          `xml:"VtdBar"`
+	DMAProtBase0  uint32          `xml:"DmaProtBase0"`
+	DMAProtLimit0 uint32          `xml:"DmaProtLimit0"`
+	DMAProtBase1  uint64          `xml:"DmaProtBase1"`
+	DMAProtLimit1 uint64          `xml:"DmaProtLimit1"`
+	PostIBBHash   meuHash         `xml:"PostIbbHash"`
+	EntryPoint    uint32          `xml:"IbbEntryPoint"`
+	Digests       []meuHash       `xml:"IbbDigests>Digest"`
+	OBBHash       meuHash         `xml:"ObbHash"`
+	Segments      []meuIBBSegment `xml:"IbbSegments>IbbSegment"`
+}
+
+type meuIBBSegment struct {
+	Base  uint32 `xml:"Base"`
+	Size  uint32 `xml:"Size"`
+	Flags uint16 `xml:"Flags"`
+}
+
+type meuTXT struct {
+	StructVersion   uint8     `xml:"StructVersion,omitempty"`
+	Reserved        string    `xml:"Reserved,omitempty"`
+	SInitMinSVN     uint8     `xml:"SinitMinSvn"`
+	ControlFlags    uint32    `xml:"TxtFlags"`
+	PwrDownInterval uint16    `xml:"PowerDownInterval"`
+	PTTCMOSOffset0  uint8     `xml:"PttCmosOffset0"`
+	PTTCMOSOffset1  uint8     `xml:"PttCmosOffset1"`
+	ACPIBaseOffset  uint16    `xml:"AcpiBaseOffset"`
+	PwrMBaseOffset  uint32    `xml:"PwrmBaseOffset"`
+	Digests         []meuHash `xml:"TxtDigests>Digest"`
+}
+
+type meuDataElement struct {
+	StructVersion uint8  `xml:"StructVersion,omitempty"`
+	Reserved      string `xml:"Reserved,omitempty"`
+	Data          string `xml:"Data"`
+}
+
+// WriteConfigXML writes the config in an XML layout approximating the one of
+// Intel's Manifest Extension Utility (MEU). See the README for the mapping of
+// the fields.
+func WriteConfigXML(w io.Writer, bgo *BootGuardOptions) error {
+	km, bpm := &bgo.KeyManifest, &bgo.BootPolicyManifest
+	cfg := meuConfig{
+		SharedIdentifiers: bgo.IDs,
+		KeyManifest: meuKeyManifest{
+			StructVersion: km.Version,
+			Reserved:      reservedHex(km.Reserved2[:]),
+			Revision:      km.Revision,
+			SVN:           uint8(km.KMSVN),
+			ID:            km.KMID,
+			PubKeyHashAlg: uint16(km.PubKeyHashAlg),
+		},
+		BootPolicyManifest: meuBootPolicyManifest{
+			StructVersion:    bpm.BPMH.Version,
+			Revision:         bpm.BPMH.BPMRevision,
+			SVN:              uint8(bpm.BPMH.BPMSVN),
+			ACMSVN:           uint8(bpm.BPMH.ACMSVNAuth),
+			NEMPages:         uint16(bpm.BPMH.NEMDataStack),
+			SignatureVersion: bpm.PMSE.StructInfo.Version,
+		},
+	}
+	for _, h := range km.Hash {
+		cfg.KeyManifest.Hashes = append(cfg.KeyManifest.Hashes, meuKMHash{Usage: uint64(h.Usage), Digest: toMEUHash(h.Digest)})
+	}
+	for _, se := range bpm.SE {
+		ibb := meuIBB{
+			StructVersion: se.Version,
+			Flags:         uint32(se.Flags),
+			PBETValue:     uint8(se.PBETValue),
+			MCHBAR:        se.IBBMCHBAR,
+			VTdBAR:        se.VTdBAR,
+			DMAProtBase0:  se.DMAProtBase0,
+			DMAProtLimit0: se.DMAProtLimit0,
+			DMAProtBase1:  se.DMAProtBase1,
+			DMAProtLimit1: se.DMAProtLimit1,
+			PostIBBHash:   toMEUHash(se.PostIBBHash),
+			EntryPoint:    se.IBBEntryPoint,
+			Digests:       toMEUHashes(se.DigestList),
+			OBBHash:       toMEUHash(se.OBBHash),
+		}
+		for _, seg := range se.IBBSegments {
+			ibb.Segments = append(ibb.Segments, meuIBBSegment{Base: seg.Base, Size: seg.Size, Flags: seg.Flags})
+		}
+		cfg.BootPolicyManifest.IBBElements = append(cfg.BootPolicyManifest.IBBElements, ibb)
+	}
+	if txt := bpm.TXTE; txt != nil {
+		cfg.BootPolicyManifest.TXTElement = &meuTXT{
+			StructVersion:   txt.Version,
+			Reserved:        reservedHex(txt.Reserved2[:]),
+			SInitMinSVN:     txt.SInitMinSVNAuth,
+			ControlFlags:    uint32(txt.ControlFlags),
+			PwrDownInterval: uint16(txt.PwrDownInterval),
+			PTTCMOSOffset0:  txt.PTTCMOSOffset0,
+			PTTCMOSOffset1:  txt.PTTCMOSOffset1,
+			ACPIBaseOffset:  txt.ACPIBaseOffset,
+			PwrMBaseOffset:  txt.PwrMBaseOffset,
+			Digests:         toMEUHashes(txt.DigestList),
+		}
+	}
+	if bpm.Res != nil {
+		cfg.BootPolicyManifest.ReservedElement = &meuDataElement{
+			StructVersion: bpm.Res.Version,
+			Data:          hex.EncodeToString(bpm.Res.ReservedData[:]),
+		}
+	}
+	if bpm.PCDE != nil {
+		cfg.BootPolicyManifest.PCDElement = &meuDataElement{
+			StructVersion: bpm.PCDE.Version,
+			Reserved:      reservedHex(bpm.PCDE.Reserved0[:]),
+			Data:          hex.EncodeToString(bpm.PCDE.Data),
+		}
+	}
+	if bpm.PME != nil {
+		cfg.BootPolicyManifest.PlatformData = &meuDataElement{
+			StructVersion: bpm.PME.Version,
+			Data:          hex.EncodeToString(bpm.PME.Data),
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadConfigXML reads a config written in the XML layout of WriteConfigXML. The keys and signatures are left empty.
+func ReadConfigXML(r io.Reader) (*BootGuardOptions, error) {
+	var cfg meuConfig
+	if err := xml.NewDecoder(r).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to decode XML config: %w", err)
+	}
+	var bgo BootGuardOptions
+	bgo.IDs = cfg.SharedIdentifiers
+
+	km := key.NewManifest()
+	km.Version = structVersion(cfg.KeyManifest.StructVersion, km.Version)
+	if err := fromReservedHex(cfg.KeyManifest.Reserved, km.Reserved2[:]); err != nil {
+		return nil, fmt.Errorf("KeyManifest: %w", err)
+	}
+	km.Revision = cfg.KeyManifest.Revision
+	km.KMSVN = manifest.SVN(cfg.KeyManifest.SVN)
+	km.KMID = cfg.KeyManifest.ID
+	km.PubKeyHashAlg = manifest.Algorithm(cfg.KeyManifest.PubKeyHashAlg)
+	for idx, h := range cfg.KeyManifest.Hashes {
+		digest, err := fromMEUHash(h.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("KmHash %d: %w", idx, err)
+		}
+		km.Hash = append(km.Hash, key.Hash{Usage: key.Usage(h.Usage), Digest: digest})
+	}
+	km.RehashRecursive()
+	bgo.KeyManifest = *km
+
+	src := &cfg.BootPolicyManifest
+	bpm := bootpolicy.NewManifest()
+	bpm.BPMH.Version = structVersion(src.StructVersion, bpm.BPMH.Version)
+	bpm.PMSE.StructInfo.Version = structVersion(src.SignatureVersion, bpm.PMSE.StructInfo.Version)
+	bpm.BPMH.BPMRevision = src.Revision
+	bpm.BPMH.BPMSVN = manifest.SVN(src.SVN)
+	bpm.BPMH.ACMSVNAuth = manifest.SVN(src.ACMSVN)
+	bpm.BPMH.NEMDataStack = bootpolicy.Size4K(src.NEMPages)
+	for idx, ibb := range src.IBBElements {
+		se, err := fromMEUIBB(ibb)
+		if err != nil {
+			return nil, fmt.Errorf("IbbElement %d: %w", idx, err)
+		}
+		bpm.SE = append(bpm.SE, *se)
+	}
+	if src.TXTElement != nil {
+		txt := bootpolicy.NewTXT()
+		txt.Version = structVersion(src.TXTElement.StructVersion, txt.Version)
+		if err := fromReservedHex(src.TXTElement.Reserved, txt.Reserved2[:]); err != nil {
+			return nil, fmt.Errorf("TxtElement: %w", err)
+		}
+		txt.SInitMinSVNAuth = src.TXTElement.SInitMinSVN
+		txt.ControlFlags = bootpolicy.TXTControlFlags(src.TXTElement.ControlFlags)
+		txt.PwrDownInterval = bootpolicy.Duration16In5Sec(src.TXTElement.PwrDownInterval)
+		txt.PTTCMOSOffset0 = src.TXTElement.PTTCMOSOffset0
+		txt.PTTCMOSOffset1 = src.TXTElement.PTTCMOSOffset1
+		txt.ACPIBaseOffset = src.TXTElement.ACPIBaseOffset
+		txt.PwrMBaseOffset = src.TXTElement.PwrMBaseOffset
+		digests, err := fromMEUHashes(src.TXTElement.Digests)
+		if err != nil {
+			return nil, fmt.Errorf("TxtElement: %w", err)
+		}
+		txt.DigestList = *digests
+		txt.RehashRecursive()
+		bpm.TXTE = txt
+	}
+	if src.ReservedElement != nil {
+		data, err := hex.DecodeString(src.ReservedElement.Data)
+		if err != nil {
+			return nil, fmt.Errorf("ReservedElement: %w", err)
+		}
+		bpm.Res = bootpolicy.NewReserved()
+		if len(data) != len(bpm.Res.ReservedData) {
+			return nil, fmt.Errorf("ReservedElement: expected %d bytes of data, but got %d", len(bpm.Res.ReservedData), len(data))
+		}
+		bpm.Res.Version = structVersion(src.ReservedElement.StructVersion, bpm.Res.Version)
+		copy(bpm.Res.ReservedData[:], data)
+		bpm.Res.Rehash()
+	}
+	if src.PCDElement != nil {
+		data, err := hex.DecodeString(src.PCDElement.Data)
+		if err != nil {
+			return nil, fmt.Errorf("PcdElement: %w", err)
+		}
+		bpm.PCDE = bootpolicy.NewPCD()
+		bpm.PCDE.Version = structVersion(src.PCDElement.StructVersion, bpm.PCDE.Version)
+		if err := fromReservedHex(src.PCDElement.Reserved, bpm.PCDE.Reserved0[:]); err != nil {
+			return nil, fmt.Errorf("PcdElement: %w", err)
+		}
+		bpm.PCDE.Data = data
+		bpm.PCDE.Rehash()
+	}
+	if src.PlatformData != nil {
+		data, err := hex.DecodeString(src.PlatformData.Data)
+		if err != nil {
+			return nil, fmt.Errorf("PlatformManufacturerElement: %w", err)
+		}
+		if bpm.PME, err = NewPMElement(data); err != nil {
+			return nil, err
+		}
+		bpm.PME.Version = structVersion(src.PlatformData.StructVersion, bpm.PME.Version)
+	}
+	bpm.RehashRecursive()
+	bgo.BootPolicyManifest = *bpm
+
+	if err := ApplyManifestIDs(&bgo); err != nil {
+		return nil, err
+	}
+	return &bgo, nil
+}
+
+func fromMEUIBB(ibb meuIBB) (*bootpolicy.SE, error) {
+	var err error
+	se := bootpolicy.NewSE()
+	se.Version = structVersion(ibb.StructVersion, se.Version)
+	se.Flags = bootpolicy.SEFlags(ibb.Flags)
+	se.PBETValue = bootpolicy.PBETValue(ibb.PBETValue)
+	se.IBBMCHBAR = ibb.MCHBAR
+	se.VTdBAR = ibb.VTdBAR
+	se.DMAProtBase0 = ibb.DMAProtBase0
+	se.DMAProtLimit0 = ibb.DMAProtLimit0
+	se.DMAProtBase1 = ibb.DMAProtBase1
+	se.DMAProtLimit1 = ibb.DMAProtLimit1
+	se.IBBEntryPoint = ibb.EntryPoint
+	if se.PostIBBHash, err = fromMEUHash(ibb.PostIBBHash); err != nil {
+		return nil, fmt.Errorf("PostIbbHash: %w", err)
+	}
+	if se.OBBHash, err = fromMEUHash(ibb.OBBHash); err != nil {
+		return nil, fmt.Errorf("ObbHash: %w", err)
+	}
+	digests, err := fromMEUHashes(ibb.Digests)
+	if err != nil {
+		return nil, fmt.Errorf("IbbDigests: %w", err)
+	}
+	se.DigestList = *digests
+	for _, seg := range ibb.Segments {
+		s := bootpolicy.NewIBBSegment()
+		s.Base, s.Size, s.Flags = seg.Base, seg.Size, seg.Flags
+		se.IBBSegments = append(se.IBBSegments, *s)
+	}
+	se.RehashRecursive()
+	return se, nil
+}
+
+// structVersion returns the structure version v of a config, or def if the
+// config doesn't set it.
+func structVersion(v, def uint8) uint8 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// reservedHex hex encodes a reserved field, or returns "" if it's zero so
+// that the element is omitted.
+func reservedHex(b []byte) string {
+	for _, c := range b {
+		if c != 0 {
+			return hex.EncodeToString(b)
+		}
+	}
+	return ""
+}
+
+// fromReservedHex decodes a reserved field written by reservedHex into dst.
+func fromReservedHex(s string, dst []byte) error {
+	if s == "" {
+		return nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid reserved field %q: %w", s, err)
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("reserved field %q has %d bytes, expected %d", s, len(b), len(dst))
+	}
+	copy(dst, b)
+	return nil
+}
+
+func toMEUHash(h manifest.HashStructure) meuHash {
+	return meuHash{HashAlg: uint16(h.HashAlg), Hash: hex.EncodeToString(h.HashBuffer)}
+}
+
+func toMEUHashes(l manifest.HashList) []meuHash {
+	var hashes []meuHash
+	for _, h := range l.List {
+		hashes = append(hashes, toMEUHash(h))
+	}
+	return hashes
+}
+
+func fromMEUHash(h meuHash) (manifest.HashStructure, error) {
+	buf, err := hex.DecodeString(h.Hash)
+	if err != nil {
+		return manifest.HashStructure{}, fmt.Errorf("invalid hash %q: %w", h.Hash, err)
+	}
+	return manifest.HashStructure{HashAlg: manifest.Algorithm(h.HashAlg), HashBuffer: buf}, nil
+}
+
+func fromMEUHashes(hashes []meuHash) (*manifest.HashList, error) {
+	l := manifest.NewHashList()
+	l.List = make([]manifest.HashStructure, 0, len(hashes))
+	for idx, h := range hashes {
+		hs, err := fromMEUHash(h)
+		if err != nil {
+			return nil, fmt.Errorf("digest %d: %w", idx, err)
+		}
+		l.List = append(l.List, hs)
+	}
+	l.Rehash()
+	return l, nil
+}
@@ -0,0 +1,121 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+func TestConfigXMLRoundTrip(t *testing.T) {
+	kmData, err := ioutil.ReadFile(testKMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	km, err := ParseKM(bytes.NewReader(kmData))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	bpmData, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(bpmData))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	bgo := &BootGuardOptions{KeyManifest: *km, BootPolicyManifest: *bpm}
+
+	var out bytes.Buffer
+	if err := WriteConfigXML(&out, bgo); err != nil {
+		t.Fatalf("WriteConfigXML() failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "<IbbSegment>") {
+		t.Errorf("WriteConfigXML() wrote no IBB segments:\n%s", out.String())
+	}
+	xmlBGO, err := ReadConfigXML(&out)
+	if err != nil {
+		t.Fatalf("ReadConfigXML() failed: %v", err)
+	}
+
+	// the keys and signatures aren't part of the config, km-gen and bpm-gen set them
+	xmlBGO.KeyManifest.KeyAndSignature = bgo.KeyManifest.KeyAndSignature
+	xmlBGO.BootPolicyManifest.PMSE.KeySignature = bgo.BootPolicyManifest.PMSE.KeySignature
+	origCfg, err := MarshalConfig(bgo)
+	if err != nil {
+		t.Fatalf("MarshalConfig() failed: %v", err)
+	}
+	newCfg, err := MarshalConfig(xmlBGO)
+	if err != nil {
+		t.Fatalf("MarshalConfig() failed: %v", err)
+	}
+	if !bytes.Equal(origCfg, newCfg) {
+		t.Errorf("XML config differs from the original config:\n%s\nexpected:\n%s", newCfg, origCfg)
+	}
+	newBPMData, err := WriteBPM(&xmlBGO.BootPolicyManifest)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(bpmData[:len(newBPMData)], newBPMData) {
+		t.Errorf("XML config doesn't reproduce the BPM")
+	}
+
+	bgo.KeyManifest.RehashRecursive()
+	xmlBGO.KeyManifest.RehashRecursive()
+	origKM, err := WriteKM(&bgo.KeyManifest)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	newKM, err := WriteKM(&xmlBGO.KeyManifest)
+	if err != nil {
+		t.Fatalf("WriteKM() failed: %v", err)
+	}
+	if !bytes.Equal(origKM, newKM) {
+		t.Errorf("XML config generates another KM")
+	}
+
+	origBPM, err := assembleBPM(bgo, &bgo.BootPolicyManifest.SE[0])
+	if err != nil {
+		t.Fatalf("assembleBPM() failed: %v", err)
+	}
+	newBPM, err := assembleBPM(xmlBGO, &xmlBGO.BootPolicyManifest.SE[0])
+	if err != nil {
+		t.Fatalf("assembleBPM() failed: %v", err)
+	}
+	origBPM.RehashRecursive()
+	newBPM.RehashRecursive()
+	origBPMData, err := WriteBPM(origBPM)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	newBPMData, err = WriteBPM(newBPM)
+	if err != nil {
+		t.Fatalf("WriteBPM() failed: %v", err)
+	}
+	if !bytes.Equal(origBPMData, newBPMData) {
+		t.Errorf("XML config generates another BPM")
+	}
+}
+
+func TestReadConfigXMLSharedIdentifiers(t *testing.T) {
+	cfg := `<BootGuardConfiguration>
+  <SharedIdentifiers><KMID>3</KMID><Revision>2</Revision></SharedIdentifiers>
+  <KeyManifest><KmSvn>1</KmSvn></KeyManifest>
+  <BootPolicyManifest><BpmSvn>4</BpmSvn></BootPolicyManifest>
+</BootGuardConfiguration>`
+	bgo, err := ReadConfigXML(strings.NewReader(cfg))
+	if err != nil {
+		t.Fatalf("ReadConfigXML() failed: %v", err)
+	}
+	if bgo.KeyManifest.KMID != 3 || bgo.BootPolicyManifest.BPMH.BPMRevision != 2 || bgo.BootPolicyManifest.BPMH.BPMSVN != 4 {
+		t.Errorf("ReadConfigXML() returned KM ID %d, BPM revision %d, BPM SVN %d",
+			bgo.KeyManifest.KMID, bgo.BootPolicyManifest.BPMH.BPMRevision, bgo.BootPolicyManifest.BPMH.BPMSVN)
+	}
+	// a config without structure versions gets the default ones
+	if bgo.KeyManifest.Version != key.NewManifest().Version || bgo.BootPolicyManifest.BPMH.Version != bootpolicy.NewBPMH().Version {
+		t.Errorf("ReadConfigXML() returned KM version 0x%x, BPMH version 0x%x", bgo.KeyManifest.Version, bgo.BootPolicyManifest.BPMH.Version)
+	}
+}
@@ -0,0 +1,98 @@
+package bg
+
+import (
+	"fmt"
+
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/bootpolicy"
+	"github.com/9elements/converged-security-suite/v2/pkg/intel/metadata/manifest/key"
+)
+
+// ZeroField is a field of a generated manifest left at zero, the default of
+// the unset config values and command line flags.
+type ZeroField struct {
+	// Element is the manifest element holding the field, e.g. "SE 0".
+	Element string
+	// Name is the config key of the field.
+	Name string
+	// Suspicious is true if zero is almost certainly a forgotten value
+	// rather than an intended one.
+	Suspicious bool
+	// Reason tells what zero means for the field.
+	Reason string
+}
+
+func (f ZeroField) String() string {
+	return fmt.Sprintf("%s: %s is zero, %s", f.Element, f.Name, f.Reason)
+}
+
+// zeroAudit collects the zero fields of a manifest.
+type zeroAudit []ZeroField
+
+func (a *zeroAudit) check(isZero bool, element, name string, suspicious bool, reason string) {
+	if isZero {
+		*a = append(*a, ZeroField{Element: element, Name: name, Suspicious: suspicious, Reason: reason})
+	}
+}
+
+// AuditKMZeroFields lists the fields of the KM left at zero.
+func AuditKMZeroFields(km *key.Manifest) []ZeroField {
+	var a zeroAudit
+	a.check(km.Revision == 0, "KM", "km_Revision", false, "fine for the first KM of a platform")
+	a.check(km.KMSVN == 0, "KM", "km_SVN", false, "fine until a KM is revoked")
+	a.check(km.KMID == 0, "KM", "km_ID", false, "fine if the fused KM ID is 0")
+	a.check(len(km.Hash) == 0, "KM", "km_hash", true, "the KM authorizes no BPM signing key")
+	return a
+}
+
+// AuditBPMZeroFields lists the fields of the BPM left at zero. Fields the ACM
+// can't work with if zero, e.g. the IBB entry point, are suspicious.
+func AuditBPMZeroFields(bpm *bootpolicy.Manifest) []ZeroField {
+	var a zeroAudit
+	a.check(bpm.BPMRevision == 0, "BPMH", "bpmh_Revision", false, "fine for the first BPM of a platform")
+	a.check(bpm.BPMSVN == 0, "BPMH", "bpmh_SNV", false, "fine until a BPM is revoked")
+	a.check(bpm.ACMSVNAuth == 0, "BPMH", "bpmh_ACMSVN", false, "every ACM SVN is authorized")
+	a.check(bpm.NEMDataStack == 0, "BPMH", "bpmh_NEMStackSize", true, "the IBB gets no NEM data stack")
+	for idx := range bpm.SE {
+		se := &bpm.SE[idx]
+		element := fmt.Sprintf("SE %d", idx)
+		a.check(se.IBBEntryPoint == 0, element, "se_IBBEntry", true, "the ACM jumps to address 0 instead of the IBB")
+		a.check(se.IBBMCHBAR == 0, element, "se_IBBMCHBAR", false, "the ACM keeps the MCHBAR programmed by the platform")
+		a.check(se.VTdBAR == 0, element, "se_VTdBAR", false, "the ACM keeps the VT-d BAR programmed by the platform")
+		a.check(se.PBETValue == 0, element, "se_PBETValue", false, "the BIOS environment timer is disabled")
+		a.check(se.Flags == 0, element, "se_Flags", false, "no optional IBB features are enabled")
+		a.check(se.DMAProtLimit0 == 0, element, "se_DMAProtLimit0", false, "the low DMA protected range is disabled")
+		a.check(se.DMAProtLimit1 == 0, element, "se_DMAProtLimit1", false, "the high DMA protected range is disabled")
+	}
+	if txt := bpm.TXTE; txt != nil {
+		a.check(txt.SInitMinSVNAuth == 0, "TXTE", "txt_SVN", false, "every SINIT ACM SVN is authorized")
+		a.check(txt.ControlFlags == 0, "TXTE", "txt_Flags", false, "no TXT control flags are set")
+		a.check(txt.PwrDownInterval == 0, "TXTE", "tx_PwrDownInterval", false, "the default power down interval is used")
+		a.check(txt.PTTCMOSOffset0 == 0 && txt.PTTCMOSOffset1 == 0, "TXTE", "txt_PTTCMOSOffset0", true,
+			"as is txt_PTTCMOSOffset1, the wakeup time overwrites the RTC seconds register")
+		a.check(txt.ACPIBaseOffset == 0, "TXTE", "txt_ACPIBaseOffset", true, "the ACPI PM I/O base is not set")
+		a.check(txt.PwrMBaseOffset == 0, "TXTE", "txt_PwrMBaseOffset", true, "the PWRM base is not set")
+	}
+	return a
+}
+
+// SuspiciousZeroFields returns the suspicious fields which are not confirmed
+// to be intentionally zero by their config key in allowed.
+func SuspiciousZeroFields(fields []ZeroField, allowed []string) []ZeroField {
+	var suspicious []ZeroField
+	for _, f := range fields {
+		if !f.Suspicious || containsString(allowed, f.Name) {
+			continue
+		}
+		suspicious = append(suspicious, f)
+	}
+	return suspicious
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package bg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func zeroFieldNames(fields []ZeroField) []string {
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestAuditBPMZeroFields(t *testing.T) {
+	data, err := ioutil.ReadFile(testBPMPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	bpm, err := ParseBPM(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBPM() failed: %v", err)
+	}
+	if suspicious := SuspiciousZeroFields(AuditBPMZeroFields(bpm), nil); len(suspicious) != 0 {
+		t.Errorf("the BPM fixture has suspicious zero fields %q", zeroFieldNames(suspicious))
+	}
+
+	bpm.SE[0].IBBEntryPoint = 0
+	fields := AuditBPMZeroFields(bpm)
+	suspicious := SuspiciousZeroFields(fields, nil)
+	if len(suspicious) != 1 || suspicious[0].Name != "se_IBBEntry" || suspicious[0].Element != "SE 0" {
+		t.Fatalf("SuspiciousZeroFields() returned %q, expected the zero IBB entry point", zeroFieldNames(suspicious))
+	}
+	if suspicious := SuspiciousZeroFields(fields, []string{"se_IBBEntry"}); len(suspicious) != 0 {
+		t.Errorf("SuspiciousZeroFields() returned the confirmed fields %q", zeroFieldNames(suspicious))
+	}
+	for _, f := range fields {
+		if f.Name == "bpmh_SNV" && f.Suspicious {
+			t.Errorf("a zero BPMSVN is reported as suspicious")
+		}
+	}
+}
+
+func TestAuditKMZeroFields(t *testing.T) {
+	km, err := ParseKM(bytes.NewReader(signedTestKM(t)))
+	if err != nil {
+		t.Fatalf("ParseKM() failed: %v", err)
+	}
+	suspicious := SuspiciousZeroFields(AuditKMZeroFields(km), nil)
+	if len(suspicious) != 1 || suspicious[0].Name != "km_hash" {
+		t.Errorf("SuspiciousZeroFields() returned %q, expected the empty KM hash list", zeroFieldNames(suspicious))
+	}
+}
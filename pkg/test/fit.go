@@ -307,6 +307,13 @@ func HasFIT(txtAPI hwapi.APIInterfaces, config *tools.Configuration) (bool, erro
 	if fit == nil {
 		return false, fmt.Errorf("FIT-Error: Referenz is nil"), nil
 	}
+	err = tools.VerifyFIT(fit, func(addr uint64, size uint32) ([]byte, error) {
+		buf := make([]byte, size)
+		return buf, txtAPI.ReadPhysBuf(int64(addr), buf)
+	})
+	if err != nil {
+		return false, err, nil
+	}
 	return true, nil, nil
 }
 
@@ -564,21 +571,8 @@ func BIOSACMMatchesChipset(txtAPI hwapi.APIInterfaces, config *tools.Configurati
 		return false, nil, err
 	}
 
-	for _, ch := range chp.IDList {
-		a := ch.VendorID == txt.Vid
-		b := ch.DeviceID == txt.Did
-
-		if a && b {
-			if ch.Flags&1 != 0 {
-				if ch.RevisionID&txt.Rid > 0 {
-					return true, nil, nil
-				}
-			} else {
-				if ch.RevisionID == txt.Rid {
-					return true, nil, nil
-				}
-			}
-		}
+	if chp.Match(txt.Vid, txt.Did, txt.Rid) {
+		return true, nil, nil
 	}
 
 	return false, fmt.Errorf("BIOS StartUp Module and Chipset doens't match"), nil
@@ -602,13 +596,8 @@ func BIOSACMMatchesCPU(txtAPI hwapi.APIInterfaces, config *tools.Configuration)
 
 	fms := txtAPI.CPUSignature()
 
-	for _, cpu := range cpus.IDList {
-		a := fms&cpu.FMSMask == cpu.FMS
-		b := platform&cpu.PlatformMask == cpu.PlatformID
-
-		if a && b {
-			return true, nil, nil
-		}
+	if cpus.Match(fms, platform) {
+		return true, nil, nil
 	}
 
 	return false, fmt.Errorf("BIOS Startup Module and CPU doesn't match"), nil
@@ -702,12 +691,11 @@ func SINITACMcomplyTPMSpec(txtAPI hwapi.APIInterfaces, config *tools.Configurati
 	if err != nil {
 		return false, err, nil
 	}
-	res := (1 >> tpms.Capabilities & (uint32(tools.TPMFamilyDTPM12) | uint32(tools.TPMFamilyDTPMBoth)))
-	if res == 0 && config.TPM == hwapi.TPMVersion12 && testtpmispresent.Result == ResultPass {
+	if tools.ACMSupportsTPM(tpms, tools.TPMFamilyDiscrete12) && config.TPM == hwapi.TPMVersion12 && testtpmispresent.Result == ResultPass {
 		return true, nil, nil
 	}
-	res = (1 >> tpms.Capabilities & (uint32(tools.TPMFamilyDTPM20) | uint32(tools.TPMFamilyDTPMBoth)))
-	if res == 0 && config.TPM == hwapi.TPMVersion20 && testtpmispresent.Result == ResultPass {
+	supports20 := tools.ACMSupportsTPM(tpms, tools.TPMFamilyDiscrete20) || tools.ACMSupportsTPM(tpms, tools.TPMFamilyPTT)
+	if supports20 && config.TPM == hwapi.TPMVersion20 && testtpmispresent.Result == ResultPass {
 		return true, nil, nil
 	}
 	return false, fmt.Errorf("SINIT ACM does not support used TPM"), nil
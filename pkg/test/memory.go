@@ -576,13 +576,8 @@ func SINITMatchesCPU(txtAPI hwapi.APIInterfaces, config *tools.Configuration) (b
 
 	fms := txtAPI.CPUSignature()
 
-	for _, cpu := range cpus.IDList {
-		a := fms&cpu.FMSMask == cpu.FMS
-		b := platform&cpu.PlatformMask == cpu.PlatformID
-
-		if a && b {
-			return true, nil, nil
-		}
+	if cpus.Match(fms, platform) {
+		return true, nil, nil
 	}
 
 	return false, fmt.Errorf("CPU signature not found in SINIT processor ID list"), nil
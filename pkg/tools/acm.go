@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/google/go-tpm/tpm2"
 )
@@ -37,12 +38,24 @@ const (
 	//TPMFamilyIllegal as defined in Document 315168-016 Chapter A.1 Table 16. TPM Capabilities Field
 	TPMFamilyIllegal uint16 = 0x0000
 	//TPMFamilyDTPM12 as defined in Document 315168-016 Chapter A.1 Table 16. TPM Capabilities Field
+	//
+	// Deprecated: the value isn't the bit of the TPM capabilities, use
+	// TPMFamilyDiscrete12 with ACMSupportsTPM.
 	TPMFamilyDTPM12 uint16 = 0x0001
 	//TPMFamilyDTPM20 as defined in Document 315168-016 Chapter A.1 Table 16. TPM Capabilities Field
+	//
+	// Deprecated: the value isn't the bit of the TPM capabilities, use
+	// TPMFamilyDiscrete20 with ACMSupportsTPM.
 	TPMFamilyDTPM20 uint16 = 0x0010
 	//TPMFamilyDTPMBoth combination out of TPMFamilyDTPM12 and TPMFamilyDTPM20
+	//
+	// Deprecated: the value isn't the bits of the TPM capabilities, use
+	// TPMFamilyDiscrete12 and TPMFamilyDiscrete20 with ACMSupportsTPM.
 	TPMFamilyDTPMBoth uint16 = 0x0011
 	//TPMFamilyPTT20 as defined in Document 315168-016 Chapter A.1 Table 16. TPM Capabilities Field
+	//
+	// Deprecated: the value isn't the bit of the TPM capabilities, use
+	// TPMFamilyPTT with ACMSupportsTPM.
 	TPMFamilyPTT20 uint16 = 0x1000
 
 	//ACMUUIDV3 as defined in Document 315168-016 Chapter A.1 Table 10. Chipset AC Module Information Table
@@ -122,6 +135,82 @@ type TPMs struct {
 	AlgID        []tpm2.Algorithm
 }
 
+// TPMFamily is a family of TPMs an ACM can support, as declared by the TPM
+// capabilities of its TPM info list.
+type TPMFamily uint8
+
+const (
+	// TPMFamilyDiscrete12 is a discrete TPM 1.2
+	TPMFamilyDiscrete12 TPMFamily = iota + 1
+	// TPMFamilyDiscrete20 is a discrete TPM 2.0
+	TPMFamilyDiscrete20
+	// TPMFamilyPTT is the firmware TPM 2.0 of the PCH (PTT)
+	TPMFamilyPTT
+)
+
+// tpmFamilyBits are the bits of the TPM capabilities declaring the support
+// of the TPM families as defined in Document 315168-016 Chapter A.1 Table 16.
+var tpmFamilyBits = []struct {
+	family TPMFamily
+	bit    uint32
+}{
+	{TPMFamilyDiscrete12, 1 << 2},
+	{TPMFamilyDiscrete20, 1 << 3},
+	{TPMFamilyPTT, 1 << 5},
+}
+
+func (f TPMFamily) String() string {
+	switch f {
+	case TPMFamilyDiscrete12:
+		return "dTPM 1.2"
+	case TPMFamilyDiscrete20:
+		return "dTPM 2.0"
+	case TPMFamilyPTT:
+		return "PTT"
+	}
+	return fmt.Sprintf("TPMFamily(%d)", uint8(f))
+}
+
+// ParseTPMFamily parses the name of a TPM family: 1.2, 2.0 or ptt.
+func ParseTPMFamily(name string) (TPMFamily, error) {
+	switch strings.ToLower(name) {
+	case "1.2", "tpm1.2", "dtpm1.2":
+		return TPMFamilyDiscrete12, nil
+	case "2.0", "tpm2.0", "dtpm2.0":
+		return TPMFamilyDiscrete20, nil
+	case "ptt", "ftpm":
+		return TPMFamilyPTT, nil
+	}
+	return 0, fmt.Errorf("unknown TPM family '%s', supported: 1.2, 2.0, ptt", name)
+}
+
+// Families returns the TPM families the TPM capabilities declare support
+// for. ACMs before version 5 have no TPM info list and declare none.
+func (t *TPMs) Families() []TPMFamily {
+	var families []TPMFamily
+	for _, b := range tpmFamilyBits {
+		if t.Capabilities&b.bit != 0 {
+			families = append(families, b.family)
+		}
+	}
+	return families
+}
+
+// ACMSupportsTPM returns true if the TPM info list of the ACM declares support
+// for the TPM family. The TPM info list is the one ParseACM returns, the
+// ACMInfo only holds its offset.
+func ACMSupportsTPM(tpms *TPMs, family TPMFamily) bool {
+	if tpms == nil {
+		return false
+	}
+	for _, f := range tpms.Families() {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
 // ACMHeader exports the structure of ACM Header found in the firmware interface table
 type ACMHeader struct {
 	ModuleType      uint16
@@ -217,6 +306,9 @@ func ParseACM(data []byte) (*ACM, *Chipsets, *Processors, *TPMs, error, error) {
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
+	if uint64(acmheader.ScratchSize)*4 > uint64(buf.Len()) {
+		return nil, nil, nil, nil, nil, fmt.Errorf("ACM ScratchSize 0x%x exceeds the remaining data", acmheader.ScratchSize*4)
+	}
 	scratch := make([]byte, acmheader.ScratchSize*4)
 
 	err = binary.Read(buf, binary.LittleEndian, &scratch)
@@ -243,6 +335,9 @@ func ParseACM(data []byte) (*ACM, *Chipsets, *Processors, *TPMs, error, error) {
 		return nil, nil, nil, nil, nil, err
 	}
 
+	if uint64(chipsets.Count)*uint64(binary.Size(ChipsetID{})) > uint64(buf.Len()) {
+		return nil, nil, nil, nil, nil, fmt.Errorf("ACM chipset ID count %d exceeds the remaining data", chipsets.Count)
+	}
 	chipsets.IDList = make([]ChipsetID, chipsets.Count)
 	err = binary.Read(buf, binary.LittleEndian, &chipsets.IDList)
 	if err != nil {
@@ -255,6 +350,9 @@ func ParseACM(data []byte) (*ACM, *Chipsets, *Processors, *TPMs, error, error) {
 		return nil, nil, nil, nil, nil, err
 	}
 
+	if uint64(processors.Count)*uint64(binary.Size(ProcessorID{})) > uint64(buf.Len()) {
+		return nil, nil, nil, nil, nil, fmt.Errorf("ACM processor ID count %d exceeds the remaining data", processors.Count)
+	}
 	processors.IDList = make([]ProcessorID, processors.Count)
 	err = binary.Read(buf, binary.LittleEndian, &processors.IDList)
 	if err != nil {
@@ -289,6 +387,9 @@ func ParseACM(data []byte) (*ACM, *Chipsets, *Processors, *TPMs, error, error) {
 func LookupACMSize(header []byte) (int64, error) {
 	var acmSize uint32
 
+	if len(header) < 32 {
+		return 0, fmt.Errorf("ACM header too short: %d bytes", len(header))
+	}
 	buf := bytes.NewReader(header[:32])
 	buf.Seek(ACMSizeOffset, io.SeekStart)
 	err := binary.Read(buf, binary.LittleEndian, &acmSize)
@@ -423,8 +524,112 @@ func (t *TPMs) PrettyPrint() {
 	fmt.Println("   --TPM Info List--")
 	fmt.Println("      Capabilities:")
 	fmt.Printf("         External Policy: %02x\n", t.Capabilities)
+	for _, f := range t.Families() {
+		fmt.Printf("         Supports %s\n", f)
+	}
 	fmt.Printf("      Algorithms: %d\n", t.Count)
 	for _, algo := range t.AlgID {
 		fmt.Printf("         %v\n", algo.String())
 	}
 }
+
+// ACMDump is an editable representation of an ACM binary. The parsed
+// structures are written over Raw when the binary is reconstructed, thus
+// an unmodified dump reproduces the original bytes.
+type ACMDump struct {
+	Header     ACMHeader
+	Scratch    []byte
+	Info       *ACMInfo    `json:",omitempty"`
+	Chipsets   *Chipsets   `json:",omitempty"`
+	Processors *Processors `json:",omitempty"`
+	TPMs       *TPMs       `json:",omitempty"`
+	Raw        []byte
+}
+
+// NewACMDump parses the ACM binary into an ACMDump
+func NewACMDump(data []byte) (*ACMDump, error) {
+	acm, chipsets, processors, tpms, err, err2 := ParseACM(data)
+	if err != nil {
+		return nil, err
+	}
+	if err2 != nil {
+		return nil, err2
+	}
+	dump := &ACMDump{
+		Header:  acm.Header,
+		Scratch: acm.Scratch,
+		Raw:     append([]byte{}, data...),
+	}
+	if (acm.Header.ModuleSubType & ACMModuleSubtypeAncModule) == 0 {
+		dump.Info = &acm.Info
+		dump.Chipsets = chipsets
+		dump.Processors = processors
+		if acm.Info.ACMVersion >= 5 {
+			dump.TPMs = tpms
+		}
+	}
+	return dump, nil
+}
+
+func writeACMField(raw []byte, offset int64, data interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	if offset < 0 || offset+int64(buf.Len()) > int64(len(raw)) {
+		return fmt.Errorf("ACM field at 0x%x with size 0x%x exceeds the ACM size 0x%x", offset, buf.Len(), len(raw))
+	}
+	copy(raw[offset:], buf.Bytes())
+	return nil
+}
+
+// Bytes reconstructs the ACM binary from the dump. The signature of the
+// result is not valid if any field of the dump was modified.
+func (d *ACMDump) Bytes() ([]byte, error) {
+	raw := append([]byte{}, d.Raw...)
+	offset := int64(binary.Size(d.Header))
+	if err := writeACMField(raw, 0, &d.Header); err != nil {
+		return nil, err
+	}
+	if err := writeACMField(raw, offset, d.Scratch); err != nil {
+		return nil, err
+	}
+	offset += int64(len(d.Scratch))
+	if d.Info == nil {
+		return raw, nil
+	}
+	if err := writeACMField(raw, offset, d.Info); err != nil {
+		return nil, err
+	}
+	if d.Chipsets != nil {
+		offset = int64(d.Info.ChipsetIDList)
+		if err := writeACMField(raw, offset, d.Chipsets.Count); err != nil {
+			return nil, err
+		}
+		if err := writeACMField(raw, offset+4, d.Chipsets.IDList); err != nil {
+			return nil, err
+		}
+	}
+	if d.Processors != nil {
+		offset = int64(d.Info.ProcessorIDList)
+		if err := writeACMField(raw, offset, d.Processors.Count); err != nil {
+			return nil, err
+		}
+		if err := writeACMField(raw, offset+4, d.Processors.IDList); err != nil {
+			return nil, err
+		}
+	}
+	if d.TPMs != nil {
+		offset = int64(d.Info.TPMInfoList)
+		if err := writeACMField(raw, offset, d.TPMs.Capabilities); err != nil {
+			return nil, err
+		}
+		if err := writeACMField(raw, offset+4, d.TPMs.Count); err != nil {
+			return nil, err
+		}
+		if err := writeACMField(raw, offset+6, d.TPMs.AlgID); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// UEFI firmware file section types, see the UEFI PI specification Vol. 3.
+const (
+	sectionTypeCompression = 0x01
+	sectionTypeGUIDDefined = 0x02
+	sectionTypeRaw         = 0x19
+)
+
+// lzmaPropsDefault is the properties byte (lc=3, lp=0, pb=2) starting an LZMA
+// stream as written by the EDK2 LzmaCompress tool.
+const lzmaPropsDefault = 0x5d
+
+// lzmaHeaderSize is the size of the LZMA header: the properties byte, the
+// dictionary size (uint32) and the uncompressed size (uint64).
+const lzmaHeaderSize = 13
+
+// lzmaUnknownSize is the uncompressed size of LZMA streams ending with an end
+// marker.
+const lzmaUnknownSize = ^uint64(0)
+
+// maxACMSize limits the size of a decompressed ACM. ACMs run from the
+// authenticated code RAM carved from the cache and stay well below it.
+const maxACMSize = 1 << 20
+
+// maxACMSectionDepth limits the nesting of sections enclosing an ACM.
+const maxACMSectionDepth = 4
+
+// GUIDs of the GUID defined sections, in the byte order stored in the firmware.
+var (
+	lzmaSectionGUID    = [16]byte{0x98, 0x58, 0x4e, 0xee, 0x14, 0x39, 0x59, 0x42, 0x9d, 0x6e, 0xdc, 0x7b, 0xd7, 0x94, 0x03, 0xcf}
+	lzmaF86SectionGUID = [16]byte{0xbd, 0xe6, 0x2a, 0xd4, 0x52, 0x13, 0xfb, 0x4b, 0x90, 0x9a, 0xca, 0x72, 0xa6, 0xea, 0xe8, 0x89}
+	brotliSectionGUID  = [16]byte{0x50, 0x20, 0x53, 0x3d, 0xda, 0x5c, 0xd0, 0x4f, 0x87, 0x9e, 0x0f, 0x7f, 0x63, 0x0d, 0x5a, 0xfb}
+)
+
+// ErrACMCompressionUnsupported is returned if an ACM is stored compressed with
+// a compression DecompressACM can't decompress.
+var ErrACMCompressionUnsupported = errors.New("unsupported ACM compression")
+
+// IsACMHeader returns true if data starts with the header of an Intel ACM.
+func IsACMHeader(data []byte) bool {
+	var hdr ACMHeader
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &hdr); err != nil {
+		return false
+	}
+	return hdr.ModuleType == ACMTypeChipset && hdr.ModuleVendor == ACMVendorIntel
+}
+
+// DecompressACM returns the ACM stored in data. data is either the ACM
+// itself, a raw LZMA stream or a firmware file section holding it, which may
+// be compressed. A compression other than LZMA is reported as
+// ErrACMCompressionUnsupported.
+func DecompressACM(data []byte) ([]byte, error) {
+	return decompressACM(data, 0)
+}
+
+func decompressACM(data []byte, depth int) ([]byte, error) {
+	if IsACMHeader(data) {
+		return data, nil
+	}
+	if depth > maxACMSectionDepth {
+		return nil, fmt.Errorf("ACM is nested in more than %d sections", maxACMSectionDepth)
+	}
+	if isLZMAStream(data) {
+		if acm, err := decompressLZMA(data); err == nil && IsACMHeader(acm) {
+			return acm, nil
+		}
+	}
+	payload, err := sectionPayload(data)
+	if err != nil {
+		return nil, fmt.Errorf("data is neither an ACM nor a section holding one: %w", err)
+	}
+	return decompressACM(payload, depth+1)
+}
+
+// sectionPayload returns the decompressed content of the firmware file
+// section at the start of data.
+func sectionPayload(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("section header is truncated")
+	}
+	size := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+	hdrLen := uint32(4)
+	if size == 0xffffff {
+		// the extended section header holds the size after the type
+		if len(data) < 8 {
+			return nil, fmt.Errorf("extended section header is truncated")
+		}
+		size = binary.LittleEndian.Uint32(data[4:8])
+		hdrLen = 8
+	}
+	if size < hdrLen || uint64(size) > uint64(len(data)) {
+		return nil, fmt.Errorf("section size 0x%x is invalid for 0x%x bytes", size, len(data))
+	}
+	sec, body := data[:size], data[hdrLen:size]
+
+	switch sec[3] {
+	case sectionTypeRaw:
+		return body, nil
+	case sectionTypeCompression:
+		// UncompressedLength (uint32) and CompressionType (uint8)
+		if len(body) < 5 {
+			return nil, fmt.Errorf("compression section header is truncated")
+		}
+		switch body[4] {
+		case 0x00:
+			return body[5:], nil
+		case 0x01:
+			return nil, fmt.Errorf("EFI standard compression: %w", ErrACMCompressionUnsupported)
+		default:
+			return nil, fmt.Errorf("compression type 0x%x: %w", body[4], ErrACMCompressionUnsupported)
+		}
+	case sectionTypeGUIDDefined:
+		// SectionDefinitionGuid, DataOffset (uint16) and Attributes (uint16)
+		if len(body) < 20 {
+			return nil, fmt.Errorf("GUID defined section header is truncated")
+		}
+		var guid [16]byte
+		copy(guid[:], body[:16])
+		dataOffset := uint32(binary.LittleEndian.Uint16(body[16:18]))
+		if dataOffset < hdrLen+20 || dataOffset > size {
+			return nil, fmt.Errorf("GUID defined section data offset 0x%x is invalid", dataOffset)
+		}
+		switch guid {
+		case lzmaSectionGUID:
+			return decompressLZMA(sec[dataOffset:])
+		case lzmaF86SectionGUID:
+			return nil, fmt.Errorf("LZMA with x86 filter: %w", ErrACMCompressionUnsupported)
+		case brotliSectionGUID:
+			return nil, fmt.Errorf("Brotli: %w", ErrACMCompressionUnsupported)
+		default:
+			return nil, fmt.Errorf("GUID defined section %s: %w", guidString(guid), ErrACMCompressionUnsupported)
+		}
+	}
+	return nil, fmt.Errorf("unexpected section type 0x%x", sec[3])
+}
+
+// isLZMAStream returns true if data starts with the LZMA header written by the
+// EDK2 LzmaCompress tool: the default properties, a dictionary size of a power
+// of two of at least 4K and the uncompressed size of an ACM, or an unknown
+// size for streams ending with an end marker.
+func isLZMAStream(data []byte) bool {
+	if len(data) < lzmaHeaderSize || data[0] != lzmaPropsDefault {
+		return false
+	}
+	dictSize := binary.LittleEndian.Uint32(data[1:5])
+	if dictSize < 0x1000 || dictSize&(dictSize-1) != 0 {
+		return false
+	}
+	size := binary.LittleEndian.Uint64(data[5:13])
+	return size == lzmaUnknownSize || (size != 0 && size <= maxACMSize)
+}
+
+func decompressLZMA(data []byte) ([]byte, error) {
+	r, err := lzma.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read LZMA header: %w", err)
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(r, maxACMSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress LZMA data: %w", err)
+	}
+	if len(out) > maxACMSize {
+		return nil, fmt.Errorf("LZMA data decompresses to more than the 0x%x bytes of an ACM", maxACMSize)
+	}
+	return out, nil
+}
+
+func guidString(g [16]byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(g[0:4]), binary.LittleEndian.Uint16(g[4:6]), binary.LittleEndian.Uint16(g[6:8]), g[8:10], g[10:])
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+func TestDecompressACM(t *testing.T) {
+	acm, err := ioutil.ReadFile("./tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	section, err := ioutil.ReadFile("./tests/sinit_acm_lzma.sec")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	if data, err := DecompressACM(acm); err != nil || !bytes.Equal(data, acm) {
+		t.Errorf("DecompressACM() of a raw ACM failed: %v", err)
+	}
+
+	// the LZMA compressed GUID defined section holds a raw section with the ACM
+	data, err := DecompressACM(section)
+	if err != nil {
+		t.Fatalf("DecompressACM() failed: %v", err)
+	}
+	if !bytes.Equal(data, acm) {
+		t.Fatalf("DecompressACM() returned 0x%x bytes, expected the 0x%x bytes of the ACM", len(data), len(acm))
+	}
+	if _, _, _, _, err, err2 := ParseACM(data); err != nil || err2 != nil {
+		t.Errorf("ParseACM() of the decompressed ACM failed: %v, %v", err, err2)
+	}
+}
+
+func TestDecompressACMUnsupported(t *testing.T) {
+	section, err := ioutil.ReadFile("./tests/sinit_acm_lzma.sec")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	brotli := append([]byte{}, section...)
+	copy(brotli[4:20], brotliSectionGUID[:])
+	if _, err := DecompressACM(brotli); !errors.Is(err, ErrACMCompressionUnsupported) {
+		t.Errorf("DecompressACM() of a Brotli section returned %v, expected %v", err, ErrACMCompressionUnsupported)
+	}
+
+	tiano := []byte{0x0d, 0x00, 0x00, sectionTypeCompression, 0x00, 0x00, 0x02, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff}
+	if _, err := DecompressACM(tiano); !errors.Is(err, ErrACMCompressionUnsupported) {
+		t.Errorf("DecompressACM() of an EFI compressed section returned %v, expected %v", err, ErrACMCompressionUnsupported)
+	}
+
+	if _, err := DecompressACM([]byte("no ACM")); err == nil || errors.Is(err, ErrACMCompressionUnsupported) {
+		t.Errorf("DecompressACM() of garbage returned %v", err)
+	}
+}
+
+func TestDecompressACMLZMAStream(t *testing.T) {
+	acm, err := ioutil.ReadFile("./tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	section, err := ioutil.ReadFile("./tests/sinit_acm_lzma.sec")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	// the LZMA stream of the GUID defined section starts at its data offset
+	stream := section[binary.LittleEndian.Uint16(section[20:22]):]
+	if !isLZMAStream(stream) {
+		t.Fatalf("isLZMAStream() rejected the LZMA stream of the section")
+	}
+	// it holds a raw section with the ACM
+	data, err := decompressLZMA(stream)
+	if err != nil {
+		t.Fatalf("decompressLZMA() failed: %v", err)
+	}
+	if len(data) < 4 || !bytes.Equal(data[4:], acm) {
+		t.Errorf("decompressLZMA() returned 0x%x bytes, expected a raw section with the 0x%x bytes of the ACM", len(data), len(acm))
+	}
+
+	for name, data := range map[string][]byte{
+		"text":       []byte("]not an LZMA stream"),
+		"dictionary": {0x5d, 0x01, 0x02, 0x03, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"size":       {0x5d, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+	} {
+		if isLZMAStream(data) {
+			t.Errorf("isLZMAStream() accepted a %s mismatch", name)
+		}
+	}
+}
+
+func TestDecompressLZMALimit(t *testing.T) {
+	var stream bytes.Buffer
+	w, err := lzma.NewWriter(&stream)
+	if err != nil {
+		t.Fatalf("lzma.NewWriter() failed: %v", err)
+	}
+	if _, err := w.Write(make([]byte, maxACMSize+1)); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err := decompressLZMA(stream.Bytes()); err == nil {
+		t.Errorf("decompressLZMA() returned more than the maximum ACM size")
+	}
+}
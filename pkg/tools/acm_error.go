@@ -0,0 +1,124 @@
+package tools
+
+import "fmt"
+
+// ACMErrorSuccess is the TXT.ERRORCODE value SINIT reports after a successful launch.
+const ACMErrorSuccess = 0xc0000001
+
+// acmErrorKey identifies a software generated ACM error. A zero minor code
+// matches all minor codes of the class and major code.
+type acmErrorKey struct {
+	moduleType uint8
+	class      uint8
+	major      uint8
+	minor      uint16
+}
+
+const (
+	acmModuleBIOS      = 0
+	acmModuleSINIT     = 1
+	acmModuleBootGuard = 3
+)
+
+// acmErrors lists the known software generated error codes, the descriptions
+// match the checks of pkg/test verifying the conditions.
+var acmErrors = map[acmErrorKey]string{
+	{acmModuleSINIT, 0x9, 0x7, 0x1}:  "the ACPI MADT copy doesn't fit into the TXT heap",
+	{acmModuleSINIT, 0x9, 0x7, 0x2}:  "the dynamic ACPI MADT doesn't fit into the TXT heap",
+	{acmModuleSINIT, 0x9, 0x7, 0x3}:  "the ACPI DMAR copy doesn't fit into the TXT heap",
+	{acmModuleSINIT, 0x9, 0xc, 0x0}:  "the ACPI RSDP in the OS to SINIT data points above 4 GiB",
+	{acmModuleSINIT, 0xa, 0x3, 0x1}:  "the ACPI DMAR table has an invalid HPET configuration",
+	{acmModuleSINIT, 0xa, 0x3, 0x2}:  "the ACPI DMAR table has an invalid bus configuration",
+	{acmModuleSINIT, 0xa, 0x3, 0x3}:  "the ACPI DMAR table Azalia device scope is invalid",
+	{acmModuleSINIT, 0xa, 0x3, 0x4}:  "the ACPI DMAR table device scope is missing",
+	{acmModuleSINIT, 0xa, 0x3, 0x5}:  "the ACPI DMAR table has a duplicated HPET scope",
+	{acmModuleSINIT, 0xa, 0x3, 0x6}:  "the ACPI DMAR table DRHD device is invalid",
+	{acmModuleSINIT, 0xa, 0x3, 0x7}:  "the ACPI DMAR table DRHD device scope is invalid",
+	{acmModuleSINIT, 0xa, 0x3, 0x8}:  "the ACPI DMAR table DRHD PCH APIC is missing",
+	{acmModuleSINIT, 0xa, 0x3, 0x9}:  "the ACPI DMAR table DRHD base address is above 4 GiB",
+	{acmModuleSINIT, 0xa, 0x3, 0xa}:  "the ACPI DMAR table DRHD top address is above 4 GiB",
+	{acmModuleSINIT, 0xa, 0x3, 0xb}:  "the ACPI DMAR table DRHD device scope entries are invalid",
+	{acmModuleSINIT, 0xa, 0x3, 0xc}:  "the ACPI DMAR table DRHD device scope length is invalid",
+	{acmModuleSINIT, 0xc, 0x1, 0x0}:  "the ACPI RSDP is missing or has an invalid checksum",
+	{acmModuleSINIT, 0xc, 0x2, 0x0}:  "the ACPI RSDT is missing",
+	{acmModuleSINIT, 0xc, 0x3, 0x0}:  "the ACPI RSDT is invalid",
+	{acmModuleSINIT, 0xc, 0x4, 0x0}:  "the ACPI DMAR is missing",
+	{acmModuleSINIT, 0xc, 0x5, 0x0}:  "the ACPI DMAR is invalid",
+	{acmModuleSINIT, 0xc, 0x7, 0x0}:  "the ACPI MADT is invalid",
+	{acmModuleSINIT, 0xc, 0x8, 0x0}:  "the ACPI RSDP is invalid",
+	{acmModuleSINIT, 0xc, 0x9, 0x0}:  "the ACPI XSDT is missing or invalid",
+	{acmModuleSINIT, 0xc, 0xa, 0x0}:  "the ACPI MCFG is missing",
+	{acmModuleSINIT, 0xc, 0x10, 0x0}: "the ACPI MADT is missing",
+	{acmModuleSINIT, 0x35, 0x4, 0x0}: "the ACPI PWRM BAR is above 4 GiB",
+}
+
+// acmProcessorErrors lists the error codes the processor reports when it
+// fails to launch an ACM.
+var acmProcessorErrors = map[uint32]string{
+	0:  "legacy shutdown",
+	5:  "invalid ACM memory type",
+	6:  "unsupported ACM",
+	7:  "ACM authentication failed",
+	8:  "invalid ACM format",
+	9:  "unexpected HITM",
+	10: "invalid event",
+	11: "invalid JOIN format",
+	12: "unrecoverable machine check",
+	13: "VMX abort",
+	14: "ACM corrupted",
+	15: "invalid VIDB ratio",
+}
+
+func acmModuleName(moduleType uint8) string {
+	switch moduleType {
+	case acmModuleBIOS:
+		return "BIOS ACM"
+	case acmModuleSINIT:
+		return "SINIT"
+	case acmModuleBootGuard:
+		return "Boot Guard"
+	}
+	return fmt.Sprintf("module type %d", moduleType)
+}
+
+// DecodeACMError returns a human-readable description of an ACM error code as
+// reported in TXT.ERRORCODE or the ACM status register, whose fields are
+// decoded by DecodeTXTErrorCode. Unknown codes are reported as "unknown code"
+// with their decoded fields.
+func DecodeACMError(code uint32) string {
+	if code == ACMErrorSuccess {
+		return "success: the ACM completed without error"
+	}
+	e := DecodeTXTErrorCode(code)
+	if !e.ValidInvalid {
+		return fmt.Sprintf("no error: the valid bit of 0x%08x is clear", code)
+	}
+	if !e.ProcessorSoftware {
+		// processor generated error
+		processorCode := code & 0x3fffffff
+		if desc, ok := acmProcessorErrors[processorCode]; ok {
+			return fmt.Sprintf("processor error %d: %s", processorCode, desc)
+		}
+		return fmt.Sprintf("unknown code: processor error %d", processorCode)
+	}
+	if e.SoftwareSource {
+		// the fields below bit 30 are defined by the MLE
+		return fmt.Sprintf("MLE error 0x%x: reported by the measured launch environment, not by the ACM", code&0x3fffffff)
+	}
+
+	key := acmErrorKey{
+		moduleType: e.ModuleType,
+		class:      e.ClassCode,
+		major:      e.MajorErrorCode,
+		minor:      e.MinorErrorCode,
+	}
+	fields := fmt.Sprintf("%s class 0x%x major 0x%x minor 0x%x", acmModuleName(key.moduleType), key.class, key.major, key.minor)
+	if desc, ok := acmErrors[key]; ok {
+		return fields + ": " + desc
+	}
+	key.minor = 0
+	if desc, ok := acmErrors[key]; ok {
+		return fields + ": " + desc
+	}
+	return "unknown code: " + fields
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func acmErrorCode(moduleType, class, major, minor uint32) uint32 {
+	return 1<<31 | 1<<30 | minor<<16 | major<<10 | class<<4 | moduleType
+}
+
+func TestDecodeACMError(t *testing.T) {
+	for _, tc := range []struct {
+		code     uint32
+		expected string
+	}{
+		{ACMErrorSuccess, "success"},
+		{0x00000123, "no error"},
+		{1<<31 | 7, "processor error 7: ACM authentication failed"},
+		{1<<31 | 0x1234, "unknown code: processor error 4660"},
+		{acmErrorCode(1, 0xc, 0x1, 0), "SINIT class 0xc major 0x1 minor 0x0: the ACPI RSDP is missing"},
+		{acmErrorCode(1, 0xc, 0x1, 0x5), "SINIT class 0xc major 0x1 minor 0x5: the ACPI RSDP is missing"},
+		{acmErrorCode(1, 0xa, 0x3, 0x2), "the ACPI DMAR table has an invalid bus configuration"},
+		{acmErrorCode(1, 0xa, 0x3, 0x3f), "unknown code: SINIT class 0xa major 0x3 minor 0x3f"},
+		{acmErrorCode(0, 0x2, 0x1, 0), "unknown code: BIOS ACM class 0x2 major 0x1 minor 0x0"},
+		{acmErrorCode(3, 0x2, 0x1, 0), "unknown code: Boot Guard class 0x2 major 0x1 minor 0x0"},
+		// bit 15 set: the code is defined by the MLE
+		{acmErrorCode(1, 0xc, 0x1, 0) | 1<<15, "MLE error"},
+		// the reserved bits 29:28 aren't part of the minor code
+		{acmErrorCode(1, 0xa, 0x3, 0x2) | 1<<28, "SINIT class 0xa major 0x3 minor 0x2: the ACPI DMAR table has an invalid bus configuration"},
+	} {
+		if desc := DecodeACMError(tc.code); !strings.Contains(desc, tc.expected) {
+			t.Errorf("DecodeACMError(0x%08x) returned %q, expected %q", tc.code, desc, tc.expected)
+		}
+	}
+}
+
+func TestDecodeTXTErrorCode(t *testing.T) {
+	e := DecodeTXTErrorCode(acmErrorCode(3, 0x2a, 0x15, 0xabc) | 1<<15 | 1<<28)
+	expected := TXTErrorCode{
+		ModuleType:        3,
+		ClassCode:         0x2a,
+		MajorErrorCode:    0x15,
+		SoftwareSource:    true,
+		MinorErrorCode:    0xabc,
+		Type1Reserved:     1,
+		ProcessorSoftware: true,
+		ValidInvalid:      true,
+	}
+	if e != expected {
+		t.Errorf("DecodeTXTErrorCode() returned %+v, expected %+v", e, expected)
+	}
+}
@@ -0,0 +1,49 @@
+package tools
+
+// ChipsetIDRevisionMask is set in the flags of a chipset ID if its RevisionID
+// is a mask of the supported revision IDs instead of a single revision ID.
+const ChipsetIDRevisionMask = uint32(1 << 0)
+
+// Matches returns true if the chipset ID supports the chipset with the given
+// vendor, device and revision ID as found in TXT.DIDVID.
+func (c ChipsetID) Matches(vid, did, rid uint16) bool {
+	if c.VendorID != vid || c.DeviceID != did {
+		return false
+	}
+	if c.Flags&ChipsetIDRevisionMask != 0 {
+		return c.RevisionID&rid > 0
+	}
+	return c.RevisionID == rid
+}
+
+// Match returns true if any chipset ID of the list supports the chipset.
+func (c *Chipsets) Match(vid, did, rid uint16) bool {
+	if c == nil {
+		return false
+	}
+	for _, id := range c.IDList {
+		if id.Matches(vid, did, rid) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns true if the processor ID supports the CPU with the given
+// signature (CPUID leaf 1 EAX) and IA32_PLATFORM_ID.
+func (p ProcessorID) Matches(fms uint32, platformID uint64) bool {
+	return fms&p.FMSMask == p.FMS && platformID&p.PlatformMask == p.PlatformID
+}
+
+// Match returns true if any processor ID of the list supports the CPU.
+func (p *Processors) Match(fms uint32, platformID uint64) bool {
+	if p == nil {
+		return false
+	}
+	for _, id := range p.IDList {
+		if id.Matches(fms, platformID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	// register the hash functions used by the signing schemes
+	_ "crypto/sha512"
+)
+
+const (
+	//ACMHeaderVersion0 as defined in Document 315168-016 Chapter A.1 Table 8. Authenticated Code Module Format
+	ACMHeaderVersion0 uint32 = 0x00000000
+	//ACMHeaderVersion3 as defined in Document 315168-017 Chapter A.1 Table 8. Authenticated Code Module Format
+	ACMHeaderVersion3 uint32 = 0x00030000
+
+	// acmPubKeyOffset is the offset of the RSA public key in the ACM header.
+	// The signature covers the header up to this offset and the module body.
+	acmPubKeyOffset  = 0x80
+	acmKeySizeOffset = 0x78
+)
+
+// ACMSigningScheme describes how an ACM header version is signed.
+type ACMSigningScheme struct {
+	HeaderVersion uint32
+	// KeySize is the size of the RSA modulus and signature in bytes.
+	KeySize int
+	// HasPubExp is set if the header stores the public exponent, otherwise it is 0x10001.
+	HasPubExp bool
+	Hash      crypto.Hash
+	PSS       bool
+}
+
+func (s ACMSigningScheme) String() string {
+	padding := "PKCS#1 v1.5"
+	if s.PSS {
+		padding = "PSS"
+	}
+	return fmt.Sprintf("RSA%d %s with %s", s.KeySize*8, padding, s.Hash)
+}
+
+func (s ACMSigningScheme) pubExpOffset() int {
+	return acmPubKeyOffset + s.KeySize
+}
+
+func (s ACMSigningScheme) signatureOffset() int {
+	if s.HasPubExp {
+		return s.pubExpOffset() + 4
+	}
+	return s.pubExpOffset()
+}
+
+// ACMSigningSchemeForHeader returns the signing scheme of the ACM header version.
+func ACMSigningSchemeForHeader(headerVersion uint32) (ACMSigningScheme, error) {
+	switch headerVersion {
+	case ACMHeaderVersion0:
+		return ACMSigningScheme{HeaderVersion: headerVersion, KeySize: 256, HasPubExp: true, Hash: crypto.SHA256}, nil
+	case ACMHeaderVersion3:
+		return ACMSigningScheme{HeaderVersion: headerVersion, KeySize: 384, Hash: crypto.SHA384, PSS: true}, nil
+	}
+	return ACMSigningScheme{}, fmt.Errorf("ACM header version 0x%08x is not supported", headerVersion)
+}
+
+// acmSignedData is the layout of a signed ACM as needed for the verification.
+type acmSignedData struct {
+	scheme    ACMSigningScheme
+	pubKey    *rsa.PublicKey
+	signature []byte
+	covered   []byte
+}
+
+// reverse returns a copy of b in reversed byte order. The ACM stores the RSA
+// key and signature little-endian, crypto/rsa expects them big-endian.
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+func parseACMSignedData(data []byte) (*acmSignedData, error) {
+	if len(data) < acmPubKeyOffset {
+		return nil, fmt.Errorf("ACM too short: %d bytes", len(data))
+	}
+	var hdr struct {
+		ModuleType    uint16
+		ModuleSubType uint16
+		HeaderLen     uint32
+		HeaderVersion uint32
+		_             [12]byte
+		Size          uint32
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("can't read ACM header: %w", err)
+	}
+	scheme, err := ACMSigningSchemeForHeader(hdr.HeaderVersion)
+	if err != nil {
+		return nil, err
+	}
+	keySize := binary.LittleEndian.Uint32(data[acmKeySizeOffset:])
+	if int(keySize)*4 != scheme.KeySize {
+		return nil, fmt.Errorf("ACM key size of 0x%x doesn't match header version 0x%08x", keySize*4, hdr.HeaderVersion)
+	}
+	scratchSize := binary.LittleEndian.Uint32(data[acmKeySizeOffset+4:])
+	sigEnd := scheme.signatureOffset() + scheme.KeySize
+	bodyStart := (uint64(hdr.HeaderLen) + uint64(scratchSize)) * 4
+	size := uint64(hdr.Size) * 4
+	switch {
+	case uint64(len(data)) < size:
+		return nil, fmt.Errorf("ACM size 0x%x exceeds the data of %d bytes", size, len(data))
+	case bodyStart < uint64(sigEnd) || bodyStart > size:
+		return nil, fmt.Errorf("ACM header and scratch size 0x%x are out of range", bodyStart)
+	}
+
+	pubExp := 0x10001
+	if scheme.HasPubExp {
+		pubExp = int(binary.LittleEndian.Uint32(data[scheme.pubExpOffset():]))
+	}
+	covered := make([]byte, 0, acmPubKeyOffset+size-bodyStart)
+	covered = append(covered, data[:acmPubKeyOffset]...)
+	covered = append(covered, data[bodyStart:size]...)
+	return &acmSignedData{
+		scheme: scheme,
+		pubKey: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(reverse(data[acmPubKeyOffset:scheme.pubExpOffset()])),
+			E: pubExp,
+		},
+		signature: reverse(data[scheme.signatureOffset():sigEnd]),
+		covered:   covered,
+	}, nil
+}
+
+// ACMPublicKey returns the RSA public key stored in the ACM header.
+func ACMPublicKey(data []byte) (*rsa.PublicKey, error) {
+	acm, err := parseACMSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	return acm.pubKey, nil
+}
+
+// ACMSignatureScheme returns the signing scheme used by the header version of the ACM.
+func ACMSignatureScheme(data []byte) (ACMSigningScheme, error) {
+	acm, err := parseACMSignedData(data)
+	if err != nil {
+		return ACMSigningScheme{}, err
+	}
+	return acm.scheme, nil
+}
+
+// ACMPublicKeyHash returns the SHA256 hash of the big-endian modulus of pubKey,
+// which is useful to compare the key of an ACM with a known Intel key.
+func ACMPublicKeyHash(pubKey *rsa.PublicKey) []byte {
+	h := sha256.Sum256(pubKey.N.Bytes())
+	return h[:]
+}
+
+// VerifyACMSignature verifies the RSA signature of the ACM. The signature covers
+// the header up to the public key and the module after the header and scratch
+// area. The digest is stored little-endian like the key and the signature.
+// If pubKey is nil, the key from the ACM header is used, which only proves the
+// integrity of the ACM, not that it is signed by Intel. Otherwise the ACM has to
+// carry pubKey.
+func VerifyACMSignature(data []byte, pubKey *rsa.PublicKey) error {
+	acm, err := parseACMSignedData(data)
+	if err != nil {
+		return err
+	}
+	if pubKey != nil && (pubKey.E != acm.pubKey.E || pubKey.N.Cmp(acm.pubKey.N) != 0) {
+		return fmt.Errorf("ACM is signed with another key (SHA256 of the modulus: %x)", ACMPublicKeyHash(acm.pubKey))
+	}
+	h := acm.scheme.Hash.New()
+	h.Write(acm.covered)
+	digest := reverse(h.Sum(nil))
+	if acm.scheme.PSS {
+		err = rsa.VerifyPSS(acm.pubKey, acm.scheme.Hash, digest, acm.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	} else {
+		// the ACM signature has no DigestInfo prefix
+		err = rsa.VerifyPKCS1v15(acm.pubKey, crypto.Hash(0), digest, acm.signature)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid ACM signature: %w", err)
+	}
+	return nil
+}
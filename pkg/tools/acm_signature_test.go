@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func TestVerifyACMSignature(t *testing.T) {
+	for _, path := range []string{"./tests/sinit_acm.bin", "./tests/bios_acm.bin", "./tests/bios_acm2.bin"} {
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if err := VerifyACMSignature(file, nil); err != nil {
+			t.Errorf("VerifyACMSignature(%s) failed: %v", path, err)
+		}
+		pubKey, err := ACMPublicKey(file)
+		if err != nil {
+			t.Fatalf("ACMPublicKey(%s) failed: %v", path, err)
+		}
+		if err := VerifyACMSignature(file, pubKey); err != nil {
+			t.Errorf("VerifyACMSignature(%s) with its own key failed: %v", path, err)
+		}
+
+		tampered := append([]byte{}, file...)
+		tampered[len(tampered)-1] ^= 0xff
+		if err := VerifyACMSignature(tampered, nil); err == nil {
+			t.Errorf("VerifyACMSignature(%s) succeeded on a tampered module", path)
+		}
+		otherKey := &rsa.PublicKey{N: pubKey.N, E: pubKey.E + 2}
+		if err := VerifyACMSignature(file, otherKey); err == nil {
+			t.Errorf("VerifyACMSignature(%s) succeeded with another key", path)
+		}
+	}
+}
+
+func TestVerifyACMSignatureHeaderVersion3(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	scheme, err := ACMSigningSchemeForHeader(ACMHeaderVersion3)
+	if err != nil {
+		t.Fatalf("ACMSigningSchemeForHeader() failed: %v", err)
+	}
+	headerLen := scheme.signatureOffset() + scheme.KeySize
+	data := make([]byte, headerLen+64)
+	binary.LittleEndian.PutUint16(data[0:], ACMTypeChipset)
+	binary.LittleEndian.PutUint32(data[4:], uint32(headerLen/4))
+	binary.LittleEndian.PutUint32(data[8:], ACMHeaderVersion3)
+	binary.LittleEndian.PutUint32(data[ACMSizeOffset:], uint32(len(data)/4))
+	binary.LittleEndian.PutUint32(data[acmKeySizeOffset:], uint32(scheme.KeySize/4))
+	copy(data[acmPubKeyOffset:], reverse(key.N.Bytes()))
+	for i := headerLen; i < len(data); i++ {
+		data[i] = byte(i)
+	}
+
+	h := crypto.SHA384.New()
+	h.Write(data[:acmPubKeyOffset])
+	h.Write(data[headerLen:])
+	sig, err := rsa.SignPSS(rand.Reader, key, crypto.SHA384, reverse(h.Sum(nil)), nil)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	copy(data[scheme.signatureOffset():], reverse(sig))
+
+	if err := VerifyACMSignature(data, &key.PublicKey); err != nil {
+		t.Fatalf("VerifyACMSignature() failed: %v", err)
+	}
+	if s, err := ACMSignatureScheme(data); err != nil || !s.PSS || s.Hash != crypto.SHA384 {
+		t.Errorf("ACMSignatureScheme() returned %v, %v, expected RSA3072 PSS with SHA-384", s, err)
+	}
+	data[headerLen] ^= 0xff
+	if err := VerifyACMSignature(data, &key.PublicKey); err == nil {
+		t.Errorf("VerifyACMSignature() succeeded on a tampered module")
+	}
+}
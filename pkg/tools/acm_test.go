@@ -1,6 +1,8 @@
 package tools
 
 import (
+	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -107,3 +109,99 @@ func TestACMSize2(t *testing.T) {
 		t.Errorf("ACMSize() failed: Wrong size returned, %d", size)
 	}
 }
+
+func TestACMDumpRoundTrip(t *testing.T) {
+	for _, path := range []string{"./tests/sinit_acm.bin", "./tests/bios_acm.bin"} {
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		dump, err := NewACMDump(file)
+		if err != nil {
+			t.Fatalf("NewACMDump() failed: %v", err)
+		}
+		text, err := json.Marshal(dump)
+		if err != nil {
+			t.Fatalf("json.Marshal() failed: %v", err)
+		}
+		var loaded ACMDump
+		if err := json.Unmarshal(text, &loaded); err != nil {
+			t.Fatalf("json.Unmarshal() failed: %v", err)
+		}
+		raw, err := loaded.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() failed: %v", err)
+		}
+		if !bytes.Equal(file, raw) {
+			t.Errorf("%s: round trip doesn't reproduce the original ACM", path)
+		}
+	}
+}
+
+func TestParseACMTruncated(t *testing.T) {
+	file, err := ioutil.ReadFile("./tests/sinit_acm.bin")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	_, _, _, _, err, internalerr := ParseACM(file[:ACMheaderLen*4+16])
+	if err == nil && internalerr == nil {
+		t.Errorf("ParseACM() succeeded on a truncated ACM")
+	}
+	if _, err := LookupACMSize(file[:16]); err == nil {
+		t.Errorf("LookupACMSize() succeeded on a truncated header")
+	}
+}
+
+func FuzzParseACM(f *testing.F) {
+	for _, path := range []string{"./tests/sinit_acm.bin", "./tests/bios_acm.bin"} {
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatalf("Failed to read file: %v", err)
+		}
+		f.Add(file)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Only a graceful error is acceptable, a panic fails the fuzzer.
+		_, _, _, _, _, _ = ParseACM(data)
+		_, _ = LookupACMSize(data)
+	})
+}
+
+func TestACMSupportsTPM(t *testing.T) {
+	for _, tc := range []struct {
+		capabilities uint32
+		families     []TPMFamily
+	}{
+		{0x00, nil},
+		// the extend policy bits declare no TPM family
+		{0x03, nil},
+		{0x05, []TPMFamily{TPMFamilyDiscrete12}},
+		{0x09, []TPMFamily{TPMFamilyDiscrete20}},
+		{0x28, []TPMFamily{TPMFamilyDiscrete20, TPMFamilyPTT}},
+		{0x2d, []TPMFamily{TPMFamilyDiscrete12, TPMFamilyDiscrete20, TPMFamilyPTT}},
+	} {
+		tpms := &TPMs{Capabilities: tc.capabilities}
+		for _, family := range []TPMFamily{TPMFamilyDiscrete12, TPMFamilyDiscrete20, TPMFamilyPTT} {
+			expected := false
+			for _, f := range tc.families {
+				expected = expected || f == family
+			}
+			if supported := ACMSupportsTPM(tpms, family); supported != expected {
+				t.Errorf("ACMSupportsTPM() of the capabilities 0x%x and %s returned %t", tc.capabilities, family, supported)
+			}
+		}
+		if families := tpms.Families(); len(families) != len(tc.families) {
+			t.Errorf("Families() of the capabilities 0x%x returned %v, expected %v", tc.capabilities, families, tc.families)
+		}
+	}
+	if ACMSupportsTPM(nil, TPMFamilyDiscrete20) {
+		t.Errorf("ACMSupportsTPM() without TPM info list returned true")
+	}
+
+	if f, err := ParseTPMFamily("PTT"); err != nil || f != TPMFamilyPTT {
+		t.Errorf("ParseTPMFamily() returned %v, %v", f, err)
+	}
+	if _, err := ParseTPMFamily("3.0"); err == nil {
+		t.Errorf("ParseTPMFamily() accepted an unknown TPM family")
+	}
+}
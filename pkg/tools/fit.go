@@ -3,9 +3,11 @@ package tools
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 )
 
 // For reference check Document 599500 "Firmware Interface Table"
@@ -36,6 +38,29 @@ const (
 	// 0x71 - 0x7E	: IntelReserved
 )
 
+var fitEntryTypeNames = map[FitEntryType]string{
+	FitHeader:           "FIT header",
+	MCUpdate:            "Microcode update",
+	StartUpACMod:        "Startup ACM",
+	BIOSStartUpMod:      "BIOS startup module",
+	TPMPolicyRec:        "TPM policy record",
+	BIOSPolicyRec:       "BIOS policy record",
+	TXTPolicyRec:        "TXT policy record",
+	KeyManifestRec:      "Key Manifest",
+	BootPolicyManifest:  "Boot Policy Manifest",
+	CSESecBoot:          "CSE secure boot",
+	FeaturePolicyDelRec: "Feature policy delivery record",
+	JumpDebugPol:        "Jump debug policy",
+	UnusedEntry:         "Unused entry",
+}
+
+func (t FitEntryType) String() string {
+	if name, ok := fitEntryTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved (type 0x%02x)", uint16(t))
+}
+
 const (
 	fitPointer     uint64 = 0xFFFFFFC0
 	type0MagicWord uint64 = 0x2020205f5449465f
@@ -47,7 +72,7 @@ const (
 type FitEntry struct {
 	Address  uint64
 	OrigSize [3]uint8
-	_        uint8
+	Reserved uint8
 	Version  uint16
 	CVType   uint8
 	CheckSum uint8
@@ -66,7 +91,7 @@ func (fit *FitEntry) FancyPrint() {
 		log.Printf("Component size: 0x%x\n", fit.Size())
 		log.Printf("Version: 0x%x\n", fit.Version)
 		log.Printf("C_V & Type: 0x%x\n", fit.CVType)
-		log.Printf("Checksum: 0x%x\n", fit.CheckSum)
+		log.Printf("Checksum: 0x%x (C_V set: %t)\n", fit.CheckSum, fit.CheckSumValid())
 	}
 }
 
@@ -75,6 +100,129 @@ func (fit *FitEntry) CheckSumValid() bool {
 	return fit.CVType&0x80 != 0
 }
 
+// FitChecksumStatus is the outcome of checking the checksum of a FIT entry
+// or of the whole FIT.
+type FitChecksumStatus int
+
+const (
+	// FitChecksumIgnored means the C_V bit is clear, the checksum field has
+	// no meaning and is ignored by the CPU.
+	FitChecksumIgnored FitChecksumStatus = iota
+	// FitChecksumValid means the C_V bit is set and the checksum is correct.
+	FitChecksumValid
+	// FitChecksumInvalid means the C_V bit is set, but the checksum is wrong.
+	FitChecksumInvalid
+)
+
+func (s FitChecksumStatus) String() string {
+	switch s {
+	case FitChecksumIgnored:
+		return "C_V clear, checksum ignored"
+	case FitChecksumValid:
+		return "C_V set, checksum valid"
+	case FitChecksumInvalid:
+		return "C_V set, checksum invalid"
+	}
+	return fmt.Sprintf("FitChecksumStatus(%d)", int(s))
+}
+
+// FitMemoryReader reads size bytes at the address addr below 4GiB, e.g. from
+// a firmware image or from the physical memory of the running system.
+type FitMemoryReader func(addr uint64, size uint32) ([]byte, error)
+
+// ImageFitMemoryReader returns a FitMemoryReader of a firmware image mapped
+// as CalcImageOffset maps it.
+func ImageFitMemoryReader(image []byte) FitMemoryReader {
+	return func(addr uint64, size uint32) ([]byte, error) {
+		off, err := CalcImageOffset(image, addr)
+		if err != nil {
+			return nil, err
+		}
+		if off > uint64(len(image)) || uint64(size) > uint64(len(image))-off {
+			return nil, fmt.Errorf("0x%x bytes at address 0x%x exceed the image", size, addr)
+		}
+		return image[off : off+uint64(size)], nil
+	}
+}
+
+// Component reads the component the entry points to. The size field of
+// most entry types counts 16 byte units; the KM and BPM entries hold the size
+// in bytes and the sizes of microcode updates and ACMs are read from their
+// headers.
+func (fit *FitEntry) Component(read FitMemoryReader) ([]byte, error) {
+	size := uint32(fit.OrigSize[0]) | uint32(fit.OrigSize[1])<<8 | uint32(fit.OrigSize[2])<<16
+	switch fit.Type() {
+	case KeyManifestRec, BootPolicyManifest:
+	case MCUpdate:
+		// TotalSize of the microcode update header, zero means 2048 bytes
+		hdr, err := read(fit.Address, 0x30)
+		if err != nil {
+			return nil, err
+		}
+		if size = binary.LittleEndian.Uint32(hdr[0x20:]); size == 0 {
+			size = 2048
+		}
+	case StartUpACMod:
+		hdr, err := read(fit.Address, 32)
+		if err != nil {
+			return nil, err
+		}
+		acmSize, err := LookupACMSize(hdr)
+		if err != nil {
+			return nil, err
+		}
+		size = uint32(acmSize)
+	default:
+		size *= 16
+	}
+	return read(fit.Address, size)
+}
+
+// ChecksumStatus checks the checksum of the entry if its C_V bit is set: the
+// bytes of the component the entry points to and the checksum have to add up
+// to zero. The checksum of the FIT header covers the whole table instead, see
+// FitTableChecksumStatus.
+func (fit *FitEntry) ChecksumStatus(component []byte) FitChecksumStatus {
+	if !fit.CheckSumValid() {
+		return FitChecksumIgnored
+	}
+	if checksum8(component)+fit.CheckSum != 0 {
+		return FitChecksumInvalid
+	}
+	return FitChecksumValid
+}
+
+// FitTableChecksumStatus checks the checksum of the FIT of a firmware image
+// if the C_V bit of the FIT header is set: all bytes of the table, the header
+// and all entries, have to add up to zero.
+func FitTableChecksumStatus(data []byte) (FitChecksumStatus, error) {
+	reader := bytes.NewReader(data)
+	hdr, err := GetFitHeader(reader)
+	if err != nil {
+		return FitChecksumIgnored, err
+	}
+	if !hdr.CheckSumValid() {
+		return FitChecksumIgnored, nil
+	}
+	start := len(data) - reader.Len() - binary.Size(hdr)
+	end := start + int(hdr.Size())
+	if end > len(data) {
+		return FitChecksumInvalid, fmt.Errorf("FIT: table of %d bytes at offset 0x%x exceeds the image", hdr.Size(), start)
+	}
+	if checksum8(data[start:end]) != 0 {
+		return FitChecksumInvalid, nil
+	}
+	return FitChecksumValid, nil
+}
+
+func checksum8(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
+
 //Type returns the fit entry type
 func (fit *FitEntry) Type() FitEntryType {
 	return FitEntryType(fit.CVType & 0x7f)
@@ -94,7 +242,7 @@ func GetFitPointer(data []byte) (uint64, error) {
 	return uint64(fitPointer), nil
 }
 
-func readFit(reader io.Reader, fitSize uint32, data []byte) ([]FitEntry, error) {
+func readFit(reader io.Reader, fitSize uint32) ([]FitEntry, error) {
 	var ret []FitEntry
 	for i := 16; i < int(fitSize); i += 16 {
 		ent := FitEntry{}
@@ -105,18 +253,6 @@ func readFit(reader io.Reader, fitSize uint32, data []byte) ([]FitEntry, error)
 		if ent.Type() == UnusedEntry {
 			continue
 		}
-		// Intel's Firmware Interface Table Bios Specification
-		// recommends to clear CheckSumValid bit on all entries
-		if ent.CheckSumValid() {
-			// Validate checksum
-			var cksum byte
-			for j := 0; j < 16; j++ {
-				cksum += data[j+i]
-			}
-			if cksum != 0 {
-				return nil, fmt.Errorf("FIT: Checksum of entry is invalid")
-			}
-		}
 		ret = append(ret, ent)
 	}
 	return ret, nil
@@ -139,7 +275,8 @@ func GetFitHeader(reader io.Reader) (FitEntry, error) {
 	return FitEntry{}, err
 }
 
-// ExtractFit extracts all entries from the fit and checks the checksum
+// ExtractFit extracts all entries from the fit and checks the checksum of the
+// table. The checksums of the entries are checked by VerifyFIT.
 func ExtractFit(data []byte) ([]FitEntry, error) {
 	fit := bytes.NewReader(data)
 	// read FIT header
@@ -148,34 +285,20 @@ func ExtractFit(data []byte) ([]FitEntry, error) {
 		return nil, err
 	}
 	// read rest of the FIT
-	fitTable, err := readFit(fit, hdr.Size(), data)
+	fitTable, err := readFit(fit, hdr.Size())
 	if err != nil {
 		return nil, err
 	}
 
-	var completeFit []FitEntry
-	completeFit = append(completeFit, hdr)
-	completeFit = append(completeFit, fitTable...)
-
 	// Intel's Firmware Interface Table Bios Specification recommends
 	// to set CheckSumValid in the header.
 	// Need to verify the whole table in that case, not only the header
-	if hdr.CheckSumValid() {
-		var cksum byte
-		buf := new(bytes.Buffer)
-		err := binary.Write(buf, binary.BigEndian, completeFit)
-		if err != nil {
-			return nil, fmt.Errorf("FIT: Unable to parse FIT Entries: %v", err)
-		}
-		bufSlice := buf.Bytes()
-
-		for j := 0; j < int(hdr.Size()); j++ {
-			cksum += bufSlice[j]
-		}
-
-		if cksum != 0 {
-			return nil, fmt.Errorf("FIT: Checksum of FIT is invalid")
-		}
+	status, err := FitTableChecksumStatus(data)
+	if err != nil {
+		return nil, err
+	}
+	if status == FitChecksumInvalid {
+		return nil, fmt.Errorf("FIT: Checksum of FIT is invalid")
 	}
 	var lasttype int
 	for i := range fitTable {
@@ -197,6 +320,88 @@ func ExtractFit(data []byte) ([]FitEntry, error) {
 	return fitTable, nil
 }
 
+// ErrFITDuplicateEntry is returned by VerifyFIT if an entry type which must
+// be unique appears more than once, e.g. in a double-stitched image.
+var ErrFITDuplicateEntry = errors.New("FIT: duplicate entry")
+
+// ErrFITChecksum is returned by VerifyFIT if the C_V bit of an entry is set,
+// but its checksum is wrong.
+var ErrFITChecksum = errors.New("FIT: invalid entry checksum")
+
+// uniqueFitEntryTypes are the entry types a FIT must hold at most once, the
+// platform behavior with several of them is undefined. A FIT may hold several
+// KM entries, the ACM uses the one with the KM ID the fuses select.
+var uniqueFitEntryTypes = map[FitEntryType]bool{
+	TPMPolicyRec:       true,
+	TXTPolicyRec:       true,
+	BootPolicyManifest: true,
+	JumpDebugPol:       true,
+}
+
+// DuplicateFitEntries returns the indices of the entries of a unique type
+// by type, for the types which appear more than once.
+func DuplicateFitEntries(entries []FitEntry) map[FitEntryType][]int {
+	indices := make(map[FitEntryType][]int)
+	for idx, entry := range entries {
+		if uniqueFitEntryTypes[entry.Type()] {
+			indices[entry.Type()] = append(indices[entry.Type()], idx)
+		}
+	}
+	for typ, idx := range indices {
+		if len(idx) < 2 {
+			delete(indices, typ)
+		}
+	}
+	return indices
+}
+
+// VerifyFIT checks the FIT entries returned by ExtractFit and returns an
+// error wrapping ErrFITDuplicateEntry for every unique entry type which
+// appears more than once. Without duplicates and with read set, an error
+// wrapping ErrFITChecksum is returned for the entries with the C_V bit set
+// whose component doesn't match the checksum. Intel's Firmware Interface
+// Table Bios Specification recommends to clear the C_V bit of all entries
+// besides the header.
+func VerifyFIT(entries []FitEntry, read FitMemoryReader) error {
+	duplicates := DuplicateFitEntries(entries)
+	if len(duplicates) == 0 {
+		if read == nil {
+			return nil
+		}
+		return verifyFITChecksums(entries, read)
+	}
+	var msgs []string
+	for typ := FitEntryType(0); typ <= UnusedEntry; typ++ {
+		if idx, ok := duplicates[typ]; ok {
+			msgs = append(msgs, fmt.Sprintf("%s (type 0x%02x) in entries %v", typ, uint16(typ), idx))
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrFITDuplicateEntry, strings.Join(msgs, ", "))
+}
+
+func verifyFITChecksums(entries []FitEntry, read FitMemoryReader) error {
+	var msgs []string
+	for idx := range entries {
+		entry := &entries[idx]
+		// the checksum of the header is checked over the table by ExtractFit
+		if entry.Type() == FitHeader || !entry.CheckSumValid() {
+			continue
+		}
+		component, err := entry.Component(read)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s in entry %d: %v", entry.Type(), idx, err))
+			continue
+		}
+		if entry.ChecksumStatus(component) == FitChecksumInvalid {
+			msgs = append(msgs, fmt.Sprintf("%s in entry %d has checksum 0x%02x", entry.Type(), idx, entry.CheckSum))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrFITChecksum, strings.Join(msgs, ", "))
+}
+
 //Size returns the size in bytes of the entry
 func (fit *FitEntry) Size() uint32 {
 
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerifyFITDuplicateBPM(t *testing.T) {
+	entries := []FitEntry{
+		{Address: type0MagicWord, CVType: uint8(FitHeader)},
+		{CVType: uint8(MCUpdate)},
+		{CVType: uint8(MCUpdate)},
+		{CVType: uint8(StartUpACMod)},
+		{CVType: uint8(KeyManifestRec)},
+		{CVType: uint8(BootPolicyManifest)},
+	}
+	if err := VerifyFIT(entries, nil); err != nil {
+		t.Errorf("VerifyFIT() of a valid FIT failed: %v", err)
+	}
+
+	entries = append(entries, FitEntry{CVType: uint8(BootPolicyManifest)})
+	err := VerifyFIT(entries, nil)
+	if !errors.Is(err, ErrFITDuplicateEntry) {
+		t.Fatalf("VerifyFIT() returned %v, expected %v", err, ErrFITDuplicateEntry)
+	}
+	if !strings.Contains(err.Error(), "Boot Policy Manifest (type 0x0c) in entries [5 6]") {
+		t.Errorf("VerifyFIT() returned %q without the duplicated BPM entries", err)
+	}
+	if dup := DuplicateFitEntries(entries); len(dup) != 1 {
+		t.Errorf("DuplicateFitEntries() returned %v, expected only the BPM", dup)
+	}
+}
+
+func TestVerifyFITSeveralKMs(t *testing.T) {
+	entries := []FitEntry{
+		{Address: type0MagicWord, CVType: uint8(FitHeader)},
+		{CVType: uint8(MCUpdate)},
+		{CVType: uint8(StartUpACMod)},
+		{CVType: uint8(KeyManifestRec)},
+		{CVType: uint8(KeyManifestRec)},
+		{CVType: uint8(BootPolicyManifest)},
+	}
+	if err := VerifyFIT(entries, nil); err != nil {
+		t.Errorf("VerifyFIT() of a FIT with a KM for each KM ID failed: %v", err)
+	}
+	if dup := DuplicateFitEntries(entries); len(dup) != 0 {
+		t.Errorf("DuplicateFitEntries() returned %v, expected no duplicates", dup)
+	}
+}
+
+// withChecksum returns the entry with the C_V bit set and the checksum making
+// the bytes of its component add up to zero.
+func withChecksum(entry FitEntry, component []byte) FitEntry {
+	entry.CVType |= 0x80
+	entry.CheckSum = -checksum8(component)
+	return entry
+}
+
+func TestFitEntryChecksum(t *testing.T) {
+	// the image is mapped to 0xffff0000-0xffffffff
+	image := make([]byte, 0x10000)
+	km := image[0x8000:0x8400]
+	for idx := range km {
+		km[idx] = byte(idx)
+	}
+	km[0] = 0x5a
+	entries := []FitEntry{
+		{Address: type0MagicWord, CVType: uint8(FitHeader)},
+		{Address: 0xffff0000, CVType: uint8(StartUpACMod), CheckSum: 0x5a},
+		withChecksum(FitEntry{Address: 0xffff8000, OrigSize: [3]uint8{0x00, 0x04}, CVType: uint8(KeyManifestRec)}, km),
+	}
+	read := ImageFitMemoryReader(image)
+	for idx, expected := range []FitChecksumStatus{FitChecksumIgnored, FitChecksumIgnored, FitChecksumValid} {
+		component, err := entries[idx].Component(read)
+		if idx == 2 && (err != nil || len(component) != len(km)) {
+			t.Fatalf("Component() returned %d bytes, %v, expected the 0x400 bytes of the KM", len(component), err)
+		}
+		if status := entries[idx].ChecksumStatus(component); status != expected {
+			t.Errorf("ChecksumStatus() of entry %d returned %s, expected %s", idx, status, expected)
+		}
+	}
+	// the checksum of an entry with the C_V bit clear is meaningless
+	if err := VerifyFIT(entries, read); err != nil {
+		t.Errorf("VerifyFIT() failed: %v", err)
+	}
+
+	// the checksum covers the component, not the entry
+	entries[2].Version++
+	if err := VerifyFIT(entries, read); err != nil {
+		t.Errorf("VerifyFIT() failed after a change of the entry: %v", err)
+	}
+	km[0x10]++
+	if status := entries[2].ChecksumStatus(km); status != FitChecksumInvalid {
+		t.Errorf("ChecksumStatus() of a modified KM returned %s", status)
+	}
+	err := VerifyFIT(entries, read)
+	if !errors.Is(err, ErrFITChecksum) || !strings.Contains(err.Error(), "Key Manifest in entry 2") {
+		t.Errorf("VerifyFIT() returned %v, expected %v for entry 2", err, ErrFITChecksum)
+	}
+	if err := VerifyFIT(entries, nil); err != nil {
+		t.Errorf("VerifyFIT() checked the checksums without a memory reader: %v", err)
+	}
+}
+
+func TestFitTableChecksum(t *testing.T) {
+	fit := func(hdrCV uint8) []byte {
+		var buf bytes.Buffer
+		hdr := FitEntry{Address: type0MagicWord, OrigSize: [3]uint8{2}, Version: 0x100, CVType: hdrCV}
+		entry := FitEntry{Address: 0xfffe0000, CVType: uint8(StartUpACMod)}
+		_ = binary.Write(&buf, binary.LittleEndian, []FitEntry{hdr, entry})
+		data := buf.Bytes()
+		if hdrCV != 0 {
+			data[15] = -checksum8(data)
+		}
+		return data
+	}
+
+	data := fit(0x80)
+	if status, err := FitTableChecksumStatus(data); err != nil || status != FitChecksumValid {
+		t.Errorf("FitTableChecksumStatus() returned %s, %v, expected %s", status, err, FitChecksumValid)
+	}
+	if _, err := ExtractFit(data); err != nil {
+		t.Errorf("ExtractFit() of a FIT with a valid checksum failed: %v", err)
+	}
+	data[8+16]++
+	if status, _ := FitTableChecksumStatus(data); status != FitChecksumInvalid {
+		t.Errorf("FitTableChecksumStatus() of a modified FIT returned %s", status)
+	}
+	if _, err := ExtractFit(data); err == nil {
+		t.Errorf("ExtractFit() accepted a FIT with an invalid checksum")
+	}
+
+	data = fit(0)
+	data[15] = 0x5a
+	if status, err := FitTableChecksumStatus(data); err != nil || status != FitChecksumIgnored {
+		t.Errorf("FitTableChecksumStatus() returned %s, %v, expected %s", status, err, FitChecksumIgnored)
+	}
+	if _, err := ExtractFit(data); err != nil {
+		t.Errorf("ExtractFit() checked the checksum of a FIT with the C_V bit clear: %v", err)
+	}
+}
@@ -1,18 +1,75 @@
 package tools
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/linuxboot/fiano/pkg/uefi"
 )
 
-// CalcImageOffset returns the offset of a given uefi flash image
+// ErrAddressOutOfImage is returned by CalcImageOffset if the address lies
+// outside the image, e.g. if a partial dump is taken for a full flash image.
+var ErrAddressOutOfImage = errors.New("address is out of the image")
+
+// FlashLayout describes where an image lies in the flash, for images which
+// are no full flash image with a flash descriptor, e.g. a dump of the BIOS
+// region only. The end of the flash is mapped to 4GiB.
+type FlashLayout struct {
+	// FlashSize is the size of the flash chip.
+	FlashSize uint64
+	// RegionOffset is the flash offset of the first byte of the image.
+	RegionOffset uint64
+}
+
+// ImageOffset returns the offset of addr in an image of imageSize bytes.
+func (l *FlashLayout) ImageOffset(imageSize int, addr uint64) (uint64, error) {
+	if l.FlashSize == 0 || l.FlashSize > FourGiB {
+		return 0, fmt.Errorf("invalid flash size 0x%x", l.FlashSize)
+	}
+	if l.RegionOffset+uint64(imageSize) > l.FlashSize {
+		return 0, fmt.Errorf("image of %d bytes at flash offset 0x%x exceeds the flash of 0x%x bytes", imageSize, l.RegionOffset, l.FlashSize)
+	}
+	return checkImageOffset(imageSize, addr, FourGiB-l.FlashSize+l.RegionOffset)
+}
+
+// CalcImageOffset returns the offset of a given uefi flash image. The BIOS
+// region of the flash descriptor has to lie within the image, otherwise the
+// offsets are shifted, e.g. if the descriptor is for a larger flash chip.
+// Images without flash descriptor, e.g. dumps of the BIOS region, are mapped
+// to end at 4GiB, see CalcImageOffsetWithLayout for partial dumps.
 func CalcImageOffset(image []byte, addr uint64) (uint64, error) {
+	return CalcImageOffsetWithLayout(image, addr, nil)
+}
+
+// CalcImageOffsetWithLayout is CalcImageOffset for an image placed in the
+// flash by the given layout. A nil layout maps the image by its flash
+// descriptor, or to end at 4GiB without one.
+func CalcImageOffsetWithLayout(image []byte, addr uint64, layout *FlashLayout) (uint64, error) {
+	if layout != nil {
+		return layout.ImageOffset(len(image), addr)
+	}
+	if _, err := uefi.FindSignature(image); err != nil {
+		return checkImageOffset(len(image), addr, FourGiB-uint64(len(image)))
+	}
 	off, size, err := getBIOSRegion(image)
 	if err != nil {
 		return 0, err
 	}
-	return uint64(off+size) - FourGiB + addr, nil
+	end := uint64(off) + uint64(size)
+	if end > uint64(len(image)) {
+		return 0, fmt.Errorf("BIOS region 0x%x-0x%x of the flash descriptor exceeds the image of %d bytes", off, end, len(image))
+	}
+	return checkImageOffset(len(image), addr, FourGiB-end)
+}
+
+// checkImageOffset returns the offset of addr in an image of imageSize bytes
+// mapped to base.
+func checkImageOffset(imageSize int, addr, base uint64) (uint64, error) {
+	if addr < base || addr-base >= uint64(imageSize) {
+		return 0, fmt.Errorf("%w: 0x%x is not in the image of %d bytes mapped to 0x%x-0x%x",
+			ErrAddressOutOfImage, addr, imageSize, base, base+uint64(imageSize)-1)
+	}
+	return addr - base, nil
 }
 
 func getBIOSRegion(image []byte) (uint32, uint32, error) {
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCalcImageOffsetRegionDump(t *testing.T) {
+	// a dump of the 64KiB BIOS region at the top of a 16MiB flash
+	image := make([]byte, 0x10000)
+	if off, err := CalcImageOffset(image, FourGiB-0x40); err != nil || off != 0xffc0 {
+		t.Errorf("CalcImageOffset() of the FIT pointer returned 0x%x, %v, expected 0xffc0", off, err)
+	}
+	if _, err := CalcImageOffset(image, FourGiB-0x20000); !errors.Is(err, ErrAddressOutOfImage) {
+		t.Errorf("CalcImageOffset() of an address below the dump returned %v, expected ErrAddressOutOfImage", err)
+	}
+
+}
+
+func TestCalcImageOffsetWithLayout(t *testing.T) {
+	image := make([]byte, 0x10000)
+	if off, err := CalcImageOffsetWithLayout(image, FourGiB-0x40, nil); err != nil || off != 0xffc0 {
+		t.Errorf("CalcImageOffsetWithLayout() without layout returned 0x%x, %v, expected 0xffc0", off, err)
+	}
+	layout := &FlashLayout{FlashSize: 0x1000000, RegionOffset: 0x1000000 - 0x10000}
+	if off, err := CalcImageOffsetWithLayout(image, FourGiB-0x40, layout); err != nil || off != 0xffc0 {
+		t.Errorf("CalcImageOffsetWithLayout() with the layout of the dump returned 0x%x, %v, expected 0xffc0", off, err)
+	}
+
+	// a BIOS region which doesn't end at the top of the flash
+	layout = &FlashLayout{FlashSize: 0x1000000, RegionOffset: 0xf00000}
+	if off, err := CalcImageOffsetWithLayout(image, FourGiB-0x100000+0x100, layout); err != nil || off != 0x100 {
+		t.Errorf("CalcImageOffsetWithLayout() with a region at 0xf00000 returned 0x%x, %v, expected 0x100", off, err)
+	}
+	if _, err := CalcImageOffsetWithLayout(image, FourGiB-0x40, layout); !errors.Is(err, ErrAddressOutOfImage) {
+		t.Errorf("CalcImageOffsetWithLayout() of an address above the region returned %v, expected ErrAddressOutOfImage", err)
+	}
+
+	layout = &FlashLayout{FlashSize: 0x8000}
+	if _, err := CalcImageOffsetWithLayout(image, FourGiB-0x40, layout); err == nil {
+		t.Errorf("CalcImageOffsetWithLayout() accepted an image larger than the flash")
+	}
+}